@@ -0,0 +1,114 @@
+package stereoscope
+
+import (
+	"runtime"
+	"runtime/debug"
+	"slices"
+
+	containerdClient "github.com/anchore/stereoscope/internal/containerd"
+	criClient "github.com/anchore/stereoscope/internal/cri"
+	dockerClient "github.com/anchore/stereoscope/internal/docker"
+	"github.com/anchore/stereoscope/internal/fips"
+	podmanClient "github.com/anchore/stereoscope/internal/podman"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+const (
+	modulePath = "github.com/anchore/stereoscope"
+	// unknownVersion is reported when the library version cannot be determined from the binary build info
+	unknownVersion = "(unknown)"
+)
+
+// LibraryInfo describes the stereoscope library as built into the current binary, suitable for including in bug reports
+// and for feature-gating behavior.
+type LibraryInfo struct {
+	// Version is the stereoscope module version (e.g. "v0.0.1", or "(devel)" when built from a local checkout)
+	Version string `json:"version"`
+	// GoVersion is the go version the binary was built with
+	GoVersion string `json:"goVersion"`
+	// Features are the optional capabilities enabled within this build
+	Features Features `json:"features"`
+	// Providers are the names of all available image providers in the order they are attempted
+	Providers []string `json:"providers"`
+	// SocketPaths are the container runtime addresses probed, in order of precedence
+	SocketPaths SocketPaths `json:"socketPaths"`
+}
+
+// Features are the optional capabilities of a stereoscope build.
+type Features struct {
+	// Zstd indicates support for zstd compressed layers
+	Zstd bool `json:"zstd"`
+	// FUSE indicates support for mounting image filesystems with FUSE
+	FUSE bool `json:"fuse"`
+	// Squashfs indicates support for reading squashfs filesystems (e.g. within SIF images)
+	Squashfs bool `json:"squashfs"`
+	// VMDisk indicates support for VM disk images (requires the "vmdisk" build tag)
+	VMDisk bool `json:"vmdisk"`
+	// FIPS indicates that the binary was built with a FIPS validated crypto module (see WithFIPSMode)
+	FIPS bool `json:"fips"`
+}
+
+// SocketPaths are the container runtime addresses probed for each daemon, in order of precedence.
+type SocketPaths struct {
+	Docker     []string `json:"docker"`
+	Podman     []string `json:"podman"`
+	Containerd []string `json:"containerd"`
+	CRI        []string `json:"cri"`
+}
+
+// BuildInfo returns a description of the stereoscope library as built into the current binary.
+func BuildInfo() LibraryInfo {
+	var providers []string
+	for _, p := range ImageProviders(ImageProviderConfig{}) {
+		providers = append(providers, p.Value.Name())
+	}
+
+	return LibraryInfo{
+		Version:   libraryVersion(),
+		GoVersion: runtime.Version(),
+		Features: Features{
+			Zstd: true,
+			// mounting image filesystems is not supported yet
+			FUSE:     false,
+			Squashfs: true,
+			VMDisk:   slices.Contains(providers, image.VMDiskSource),
+			FIPS:     fips.Enabled(),
+		},
+		Providers: providers,
+		SocketPaths: SocketPaths{
+			Docker:     dockerClient.SocketPaths(nil),
+			Podman:     podmanClient.SocketPaths(nil),
+			Containerd: containerdClient.SocketPaths(nil),
+			CRI:        criClient.SocketPaths(nil),
+		},
+	}
+}
+
+// libraryVersion returns the version of the stereoscope module within the binary build info.
+func libraryVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return unknownVersion
+	}
+	return moduleVersion(info, modulePath)
+}
+
+func moduleVersion(info *debug.BuildInfo, path string) string {
+	if info.Main.Path == path {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != path {
+			continue
+		}
+		if dep.Replace != nil {
+			if dep.Replace.Version == "" {
+				// replaced with a local directory
+				return "(devel)"
+			}
+			return dep.Replace.Version
+		}
+		return dep.Version
+	}
+	return unknownVersion
+}
@@ -0,0 +1,74 @@
+package stereoscope
+
+import (
+	"runtime"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func TestBuildInfo(t *testing.T) {
+	info := BuildInfo()
+
+	assert.NotEmpty(t, info.Version)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.True(t, info.Features.Zstd)
+	assert.Contains(t, info.Providers, image.DockerTarballSource)
+	assert.Contains(t, info.Providers, image.OciRegistrySource)
+	require.NotEmpty(t, info.SocketPaths.Docker)
+	assert.NotEmpty(t, info.SocketPaths.Podman)
+	assert.NotEmpty(t, info.SocketPaths.Containerd)
+	assert.NotEmpty(t, info.SocketPaths.CRI)
+}
+
+func Test_moduleVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		info debug.BuildInfo
+		want string
+	}{
+		{
+			name: "main module",
+			info: debug.BuildInfo{Main: debug.Module{Path: modulePath, Version: "(devel)"}},
+			want: "(devel)",
+		},
+		{
+			name: "dependency",
+			info: debug.BuildInfo{
+				Main: debug.Module{Path: "github.com/anchore/syft"},
+				Deps: []*debug.Module{{Path: modulePath, Version: "v0.0.1"}},
+			},
+			want: "v0.0.1",
+		},
+		{
+			name: "replaced dependency",
+			info: debug.BuildInfo{
+				Main: debug.Module{Path: "github.com/anchore/syft"},
+				Deps: []*debug.Module{{Path: modulePath, Version: "v0.0.1", Replace: &debug.Module{Path: "github.com/someone/stereoscope", Version: "v0.0.2"}}},
+			},
+			want: "v0.0.2",
+		},
+		{
+			name: "replaced with a local directory",
+			info: debug.BuildInfo{
+				Main: debug.Module{Path: "github.com/anchore/syft"},
+				Deps: []*debug.Module{{Path: modulePath, Version: "v0.0.1", Replace: &debug.Module{Path: "../stereoscope"}}},
+			},
+			want: "(devel)",
+		},
+		{
+			name: "not found",
+			info: debug.BuildInfo{Main: debug.Module{Path: "github.com/anchore/syft"}},
+			want: unknownVersion,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, moduleVersion(&tt.info, modulePath))
+		})
+	}
+}
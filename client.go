@@ -4,14 +4,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/wagoodman/go-partybus"
 
 	"github.com/anchore/go-collections"
 	"github.com/anchore/go-logger"
 	"github.com/anchore/stereoscope/internal/bus"
+	"github.com/anchore/stereoscope/internal/fips"
+	"github.com/anchore/stereoscope/internal/inflight"
 	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/internal/redact"
 	"github.com/anchore/stereoscope/pkg/file"
 	"github.com/anchore/stereoscope/pkg/image"
 )
@@ -39,6 +47,29 @@ func WithInsecureAllowHTTP() Option {
 	}
 }
 
+// WithMaxParallelism fetches and uncompresses up to the given number of layers at a time when pulling images from a
+// registry (see image.WithMaxParallelism).
+func WithMaxParallelism(n int) Option {
+	return func(c *config) error {
+		if n < 0 {
+			return fmt.Errorf("max parallelism must not be negative: %d", n)
+		}
+		c.Registry.MaxParallelism = n
+		return nil
+	}
+}
+
+// WithLazyPull reads eStargz layers lazily when pulling images from a registry, fetching file contents on demand
+// instead of downloading entire layer blobs (see image.RegistryOptions).
+func WithLazyPull() Option {
+	return func(c *config) error {
+		c.Registry.LazyPull = true
+		return nil
+	}
+}
+
+// WithCredentials authenticates registry requests with the given credentials. Passwords and tokens are redacted from
+// log messages, error strings, and published events while the image is provided.
 func WithCredentials(credentials ...image.RegistryCredentials) Option {
 	return func(c *config) error {
 		c.Registry.Credentials = append(c.Registry.Credentials, credentials...)
@@ -46,6 +77,21 @@ func WithCredentials(credentials ...image.RegistryCredentials) Option {
 	}
 }
 
+// redactRegistrySecrets registers the registry secrets of the given config for redaction, returning a function that
+// redacts the given error and removes the secrets again (such that errors remain redacted once the secrets are removed).
+func redactRegistrySecrets(cfg config) func(err *error) {
+	var secrets []string
+	for _, cred := range cfg.Registry.Credentials {
+		secrets = append(secrets, cred.Password, cred.Token)
+	}
+	redact.Add(secrets...)
+
+	return func(err *error) {
+		*err = redact.Error(*err)
+		redact.Remove(secrets...)
+	}
+}
+
 func WithAdditionalMetadata(metadata ...image.AdditionalMetadata) Option {
 	return func(c *config) error {
 		c.AdditionalMetadata = append(c.AdditionalMetadata, metadata...)
@@ -64,12 +110,370 @@ func WithPlatform(platform string) Option {
 	}
 }
 
+// WithImageSelector selects the image by reference name (the "org.opencontainers.image.ref.name" annotation) or
+// manifest digest when an OCI directory or archive holds more than one image. This is equivalent to a "#" suffix on
+// the path (e.g. "oci-dir:/path#latest"), which takes precedence.
+func WithImageSelector(selector string) Option {
+	return func(c *config) error {
+		c.ImageSelector = selector
+		return nil
+	}
+}
+
+// WithArchiveDigest verifies that the sha256 digest of the given archive file matches the expected digest (either
+// "sha256:<hex>" or the bare hex value) before the archive is processed.
+func WithArchiveDigest(digest string) Option {
+	return func(c *config) error {
+		normalized, err := file.NormalizeDigest(digest)
+		if err != nil {
+			return err
+		}
+		c.ArchiveDigest = normalized
+		return nil
+	}
+}
+
+// WithExpectedDigest pins the provided image to the given digest (either "sha256:<hex>" or the bare hex value): the
+// manifest digest, the digest of the multi-platform index the image was selected from, or the image ID of the image
+// from any source must match the given digest, and the manifest, config, and layer contents must match the digests they
+// are described by. An *image.ErrDigestMismatch is returned otherwise. Only digests computed from the content that was
+// read are considered (see image.Image.VerifyDigest), thus images from a daemon are pinned by the image ID (repo
+// digests reported by a daemon are not verifiable).
+func WithExpectedDigest(digest string) Option {
+	return func(c *config) error {
+		normalized, err := file.NormalizeDigest(digest)
+		if err != nil {
+			return err
+		}
+		c.ExpectedDigest = normalized
+		return nil
+	}
+}
+
+// WithChecksumFile verifies the given archive file against the matching entry within a checksum file in sha256sum
+// format (e.g. a "SHA256SUMS" or "<archive>.sha256" sidecar) before the archive is processed.
+func WithChecksumFile(path string) Option {
+	return func(c *config) error {
+		c.ChecksumFile = path
+		return nil
+	}
+}
+
+// WithStrictSource controls the behavior when an explicitly requested file-based source (e.g. "docker-archive:") is
+// unable to provide the image. By default (strict) this is an error, otherwise content-based detection across all
+// file-based sources is used as a fallback (with a warning).
+func WithStrictSource(strict bool) Option {
+	return func(c *config) error {
+		c.SourceFallback = !strict
+		return nil
+	}
+}
+
+// WithMaxLayers fails providing any image with more than the given number of layers (see image.ErrTooManyLayers).
+func WithMaxLayers(max int) Option {
+	return func(c *config) error {
+		if max < 0 {
+			return fmt.Errorf("max layers must not be negative: %d", max)
+		}
+		c.ReadMetadata = append(c.ReadMetadata, image.WithMaxLayers(max))
+		return nil
+	}
+}
+
+// WithChunkedSquash bounds squash times for deep images by squashing the given number of layers at a time (see
+// image.WithChunkedSquash).
+func WithChunkedSquash(chunkSize int) Option {
+	return func(c *config) error {
+		if chunkSize < 0 {
+			return fmt.Errorf("squash chunk size must not be negative: %d", chunkSize)
+		}
+		c.ReadMetadata = append(c.ReadMetadata, image.WithChunkedSquash(chunkSize))
+		return nil
+	}
+}
+
+// WithPathExcludes excludes all layer entries matching any of the given glob patterns (e.g. "proc/**", "sys/**",
+// "**/*.mp4") from the provided image, such that the excluded contents are never written to disk or indexed (see
+// image.WithPathExcludes).
+func WithPathExcludes(patterns ...string) Option {
+	return func(c *config) error {
+		c.ReadMetadata = append(c.ReadMetadata, image.WithPathExcludes(patterns...))
+		return nil
+	}
+}
+
+// WithTreeLimits bounds the directory depth and entries per directory of all layer trees, protecting against crafted
+// images designed to exhaust resources (see image.TreeLimits and image.ErrTreeLimitExceeded).
+func WithTreeLimits(limits image.TreeLimits) Option {
+	return func(c *config) error {
+		c.ReadMetadata = append(c.ReadMetadata, image.WithTreeLimits(limits))
+		return nil
+	}
+}
+
+// WithPathPolicy sets how layer entries with paths that differ only in Unicode normalization, or that contain control
+// characters, are handled while layers are read (see image.PathPolicy). Anomalous paths are always recorded within the
+// layer metadata (see image.LayerMetadata.PathAnomalies).
+func WithPathPolicy(policy image.PathPolicy) Option {
+	return func(c *config) error {
+		c.ReadMetadata = append(c.ReadMetadata, image.WithPathPolicy(policy))
+		return nil
+	}
+}
+
+// WithTimestampPolicy sets how implausible timestamps of layer entries (e.g. negative times or times in the year 2106)
+// are handled while layers are read, such as clamping them to a plausible range (see image.TimestampPolicy).
+func WithTimestampPolicy(policy image.TimestampPolicy) Option {
+	return func(c *config) error {
+		c.ReadMetadata = append(c.ReadMetadata, image.WithTimestampPolicy(policy))
+		return nil
+	}
+}
+
+// WithFileDigests digests the contents of all regular files while layers are read with sha256 and any of the given
+// additional algorithms, such that files can be looked up by digest (see image.Image.FilesByDigest).
+func WithFileDigests(algorithms ...image.FileDigestAlgorithm) Option {
+	return func(c *config) error {
+		c.FileDigests = append(c.FileDigests, algorithms...)
+		c.ReadMetadata = append(c.ReadMetadata, image.WithFileDigests(algorithms...))
+		return nil
+	}
+}
+
+// WithBestEffortLayers allows for an image to be provided even when some layers fail to be read, where the failed layers
+// have no contents (see image.WithBestEffortLayers and image.Image.FailedLayers).
+func WithBestEffortLayers() Option {
+	return func(c *config) error {
+		c.ReadMetadata = append(c.ReadMetadata, image.WithBestEffortLayers())
+		return nil
+	}
+}
+
+// WithReadOnlyDaemon forbids any mutation of container runtimes: images missing from the docker, podman, or containerd
+// image stores are never pulled (and no tags, snapshots, or content are created). Providers fail with
+// image.ErrDaemonMutation instead.
+func WithReadOnlyDaemon() Option {
+	return func(c *config) error {
+		c.ReadOnlyDaemon = true
+		return nil
+	}
+}
+
+// WithOfflineMode guarantees that images are never fetched over the network (e.g. for air-gapped or compliance
+// environments): providers pulling from registries are not used, and images missing from the docker, podman, or
+// containerd image stores are never pulled. Providers fail with image.ErrOffline instead.
+func WithOfflineMode() Option {
+	return func(c *config) error {
+		c.Offline = true
+		return nil
+	}
+}
+
+// WithRequirements only attempts providers that have all of the given capabilities (e.g. image.PlatformCapability,
+// image.OfflineCapability, or image.DigestCapability), such that providers unable to satisfy the requirements of the
+// caller are never attempted. Requiring image.OfflineCapability implies offline mode (see WithOfflineMode).
+func WithRequirements(capabilities ...string) Option {
+	return func(c *config) error {
+		for _, capability := range capabilities {
+			capability = strings.ToLower(strings.TrimSpace(capability))
+			if capability == "" {
+				return fmt.Errorf("empty provider requirement")
+			}
+			if capability == image.OfflineCapability {
+				c.Offline = true
+			}
+			if !slices.Contains(c.Requirements, capability) {
+				c.Requirements = append(c.Requirements, capability)
+			}
+		}
+		return nil
+	}
+}
+
+// WithEnvOverrides uses the given environment for discovering and connecting to container runtimes (e.g. DOCKER_HOST,
+// CONTAINERD_ADDRESS, CONTAINERD_NAMESPACE, CONTAINER_HOST, and the home and XDG runtime directories) and for resolving
+// registry credentials (DOCKER_CONFIG and the docker config within the home directory) instead of the process
+// environment. This allows for embedding services to be hermetic without mutating the process environment.
+func WithEnvOverrides(env image.EnvOverrides) Option {
+	return func(c *config) error {
+		c.EnvOverrides = &env
+		return nil
+	}
+}
+
+// WithDockerOptions connects to the docker daemon with the given settings (host, TLS certificates, API version, and
+// socket fallbacks), which take precedence over the DOCKER_* variables of the environment (including any given with
+// WithEnvOverrides).
+func WithDockerOptions(opts image.DockerOptions) Option {
+	return func(c *config) error {
+		c.DockerOptions = &opts
+		return nil
+	}
+}
+
+// WithSignatureVerification verifies the cosign signatures of the image against the given policy before it is
+// provided, failing with image.ErrSignatureVerification when there is no satisfying signature. Since signatures are
+// stored within registries, only the registry provider is used to provide the image.
+func WithSignatureVerification(policy image.SignaturePolicy) Option {
+	return func(c *config) error {
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+		c.SignaturePolicy = &policy
+		return nil
+	}
+}
+
+// WithContentTrust resolves tags of the registries within the policy to the digest signed within the Docker Content
+// Trust (Notary v1) data of the repository before pulling, failing with image.ErrContentTrust when the tag is not
+// signed. Since the signed digest is pulled from the registry, only the registry provider is used to provide tags of
+// these registries.
+func WithContentTrust(policy image.ContentTrustPolicy) Option {
+	return func(c *config) error {
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+		c.ContentTrustPolicy = &policy
+		return nil
+	}
+}
+
+// WithStreamingExport consumes the image export stream from the docker, podman, or containerd daemon directly instead
+// of first writing the entire image tar to a temp file. Only the layer blobs are written to disk (uncompressed layers
+// directly into the layer cache), which roughly halves the disk space needed for large images.
+func WithStreamingExport() Option {
+	return func(c *config) error {
+		c.StreamingExport = true
+		return nil
+	}
+}
+
+// WithDaemonExportOptions configures how images are exported from the docker daemon: how long an export may stall
+// before it is requested again (instead of hanging indefinitely), and whether only the platform of the image is
+// exported when the daemon supports it.
+func WithDaemonExportOptions(opts image.DaemonExportOptions) Option {
+	return func(c *config) error {
+		c.DaemonExport = &opts
+		return nil
+	}
+}
+
+// WithCacheDir keeps a persistent, content-addressed cache of uncompressed layers and image configs within the given
+// directory, shared between invocations and between the registry, docker, podman, and containerd providers. Layers
+// found within the cache are not downloaded or exported again (see image.BlobCache). The directory may be shared by
+// concurrent processes on the same host.
+func WithCacheDir(dir string) Option {
+	return func(c *config) error {
+		if dir == "" {
+			return fmt.Errorf("no cache directory given")
+		}
+		c.CacheDir = dir
+		return nil
+	}
+}
+
+// WithCacheSizeLimit bounds the persistent blob cache (see WithCacheDir) to roughly the given number of bytes, evicting
+// the least recently used blobs when the limit is exceeded.
+func WithCacheSizeLimit(maxBytes int64) Option {
+	return func(c *config) error {
+		if maxBytes < 0 {
+			return fmt.Errorf("cache size limit must not be negative: %d", maxBytes)
+		}
+		c.CacheMaxSize = maxBytes
+		return nil
+	}
+}
+
+// WithCacheRetention keeps only the given number of most recently read images per repository within the persistent
+// blob cache (see WithCacheDir), removing the blobs of older images that no retained image shares. This bounds the cache
+// for repositories where tags are frequently pushed with new content (e.g. CI images), even when within the size limit.
+func WithCacheRetention(keepPerRepository int) Option {
+	return func(c *config) error {
+		if keepPerRepository < 1 {
+			return fmt.Errorf("cache retention must keep at least 1 image per repository: %d", keepPerRepository)
+		}
+		c.CacheKeepPerRepository = keepPerRepository
+		return nil
+	}
+}
+
+// WithFIPSMode restricts all digest computation to FIPS approved algorithms. This requires a binary built with a FIPS
+// validated crypto module (GOEXPERIMENT=boringcrypto), and any image described by a digest algorithm that is not
+// FIPS approved results in an error. Requesting file digests with an algorithm that is not FIPS approved (e.g.
+// image.FileDigestXXH64, see WithFileDigests) is rejected before any image is read.
+func WithFIPSMode() Option {
+	return func(c *config) error {
+		if !fips.Enabled() {
+			return fmt.Errorf("FIPS mode requires a binary built with GOEXPERIMENT=boringcrypto")
+		}
+		c.FIPS = true
+		c.ReadMetadata = append(c.ReadMetadata, image.WithFIPSMode())
+		return nil
+	}
+}
+
+// WithDeadlineBudget bounds the time spent providing an image. If the budget expires while image layers are being
+// read, a partial image is returned (see image.Image.Partial) instead of failing outright: the image metadata and the
+// layers read so far (flagged by image.LayerMetadata.Completed), without anything read from the interrupted layer.
+func WithDeadlineBudget(budget time.Duration) Option {
+	return func(c *config) error {
+		if budget < 0 {
+			return fmt.Errorf("deadline budget must not be negative: %s", budget)
+		}
+		c.DeadlineBudget = budget
+		return nil
+	}
+}
+
+// WithClock measures all timed operations (e.g. progress estimation, daemon export stall detection, and the deadline
+// budget) with the given clock instead of the system clock, such that slow operations can be simulated
+// deterministically (see image.ManualClock).
+func WithClock(clock image.Clock) Option {
+	return func(c *config) error {
+		c.Clock = clock
+		return nil
+	}
+}
+
+// WithRetry attempts registry pulls, daemon exports, and containerd pulls up to the given number of attempts when
+// failing with a transient error (e.g. 429 and 5xx responses, or connection resets), waiting for the given backoff
+// before the first retry (doubling for each subsequent retry). Permanent errors (e.g. unauthorized or not found) are
+// not retried. Interrupted registry blob downloads are resumed where they stopped (for registries supporting range
+// requests) instead of starting over.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(c *config) error {
+		if attempts < 1 {
+			return fmt.Errorf("retry attempts must be at least 1: %d", attempts)
+		}
+		if backoff < 0 {
+			return fmt.Errorf("retry backoff must not be negative: %s", backoff)
+		}
+		c.RetryPolicy = &image.RetryPolicy{Attempts: attempts, Backoff: backoff}
+		return nil
+	}
+}
+
+// WithBandwidthLimit limits the total rate at which images are downloaded from registries to the given number of bytes
+// per second (across all concurrent layer downloads), such that shared network links are not saturated.
+func WithBandwidthLimit(bytesPerSec int64) Option {
+	return func(c *config) error {
+		if bytesPerSec <= 0 {
+			return fmt.Errorf("bandwidth limit must be positive: %d", bytesPerSec)
+		}
+		c.BandwidthLimit = bytesPerSec
+		return nil
+	}
+}
+
 // GetImage parses the user provided image string and provides an image object;
 // note: the source where the image should be referenced from is automatically inferred.
+// The errors of all attempted providers are returned when no image is provided, which can be matched against the kinds
+// of provider failures with errors.Is (e.g. image.ErrAuthFailure or image.ErrImageNotFound).
 func GetImage(ctx context.Context, imgStr string, options ...Option) (*image.Image, error) {
 	// look for a known source scheme like docker:
 	source, imgStr := ExtractSchemeSource(imgStr, allProviderTags()...)
-	return getImageFromSource(ctx, imgStr, source, options...)
+	img, err := getImageFromSource(ctx, imgStr, source, options...)
+	return img, redact.Error(err)
 }
 
 // GetImageFromSource returns an image from the explicitly provided source.
@@ -77,50 +481,394 @@ func GetImageFromSource(ctx context.Context, imgStr string, source image.Source,
 	if source == "" {
 		return nil, fmt.Errorf("source not provided, please specify a valid source tag")
 	}
-	return getImageFromSource(ctx, imgStr, source, options...)
+	img, err := getImageFromSource(ctx, imgStr, source, options...)
+	return img, redact.Error(err)
 }
 
-func getImageFromSource(ctx context.Context, imgStr string, source image.Source, options ...Option) (*image.Image, error) {
+func getImageFromSource(ctx context.Context, imgStr string, source image.Source, options ...Option) (_ *image.Image, err error) {
 	log.Debugf("image: source=%+v location=%+v", source, imgStr)
 
+	op := inflight.Start(imgStr, source, inflight.StageResolving)
+	defer op.Done()
+	ctx = inflight.WithOperation(ctx, op)
+
 	// apply ImageProviderConfig config
 	cfg := config{}
 	if err := applyOptions(&cfg, options...); err != nil {
 		return nil, err
 	}
+	defer redactRegistrySecrets(cfg)(&err)
+
+	op.SetStage(inflight.StageVerifying)
+	if err := verifyArchive(imgStr, cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.DockerOptions != nil {
+		cfg.EnvOverrides = cfg.DockerOptions.Apply(cfg.EnvOverrides)
+	}
+
+	ctx, err = contextWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.DeadlineBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = image.ContextWithTimeout(ctx, cfg.DeadlineBudget)
+		defer cancel()
+	}
+
+	allProviders, providers, err := selectProviders(imgStr, source, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	img, provider, errs := provideFirst(ctx, providers.Values())
+	if img == nil && source != "" && cfg.SourceFallback {
+		img, provider, errs = provideFallback(ctx, source, providers, allProviders, errs)
+	}
+
+	if img != nil {
+		return finalizeImage(img, provider, imgStr, cfg, allProviders)
+	}
+	return nil, fmt.Errorf("unable to detect input for '%s', errs: %w", imgStr, errors.Join(errs...))
+}
+
+// contextWithConfig returns the context carrying all settings of the given config that are read by providers.
+func contextWithConfig(ctx context.Context, cfg config) (context.Context, error) {
+	if cfg.ReadOnlyDaemon {
+		ctx = image.ContextWithReadOnlyDaemon(ctx)
+	}
+
+	if cfg.Offline {
+		ctx = image.ContextWithOffline(ctx)
+	}
+
+	if cfg.Clock != nil {
+		ctx = image.ContextWithClock(ctx, cfg.Clock)
+	}
+
+	if cfg.RetryPolicy != nil {
+		ctx = image.ContextWithRetryPolicy(ctx, *cfg.RetryPolicy)
+	}
+
+	if cfg.BandwidthLimit > 0 {
+		ctx = image.ContextWithBandwidthLimiter(ctx, image.NewBandwidthLimiter(cfg.BandwidthLimit))
+	}
+
+	if cfg.EnvOverrides != nil {
+		ctx = image.ContextWithEnvOverrides(ctx, cfg.EnvOverrides)
+	}
+
+	if cfg.CacheDir != "" {
+		cache, err := image.NewBlobCache(cfg.CacheDir, cfg.CacheMaxSize)
+		if err != nil {
+			return nil, err
+		}
+		if err := cache.SetRepositoryRetention(cfg.CacheKeepPerRepository); err != nil {
+			return nil, err
+		}
+		ctx = image.ContextWithBlobCache(ctx, cache)
+	}
+
+	if cfg.StreamingExport {
+		ctx = image.ContextWithStreamingExport(ctx)
+	}
 
+	if cfg.DaemonExport != nil {
+		ctx = image.ContextWithDaemonExportOptions(ctx, *cfg.DaemonExport)
+	}
+
+	if cfg.ImageSelector != "" {
+		ctx = image.ContextWithImageSelector(ctx, cfg.ImageSelector)
+	}
+
+	if cfg.SignaturePolicy != nil {
+		ctx = image.ContextWithSignaturePolicy(ctx, cfg.SignaturePolicy)
+	}
+
+	if cfg.ContentTrustPolicy != nil {
+		ctx = image.ContextWithContentTrustPolicy(ctx, cfg.ContentTrustPolicy)
+	}
+
+	if len(cfg.ReadMetadata) > 0 {
+		ctx = image.ContextWithReadMetadata(ctx, cfg.ReadMetadata...)
+	}
+
+	return ctx, nil
+}
+
+// selectProviders returns all image providers for the given input along with the providers to attempt for the given
+// source (all providers when no source is given).
+func selectProviders(imgStr string, source image.Source, cfg *config) (collections.TaggedValueSet[image.Provider], collections.TaggedValueSet[image.Provider], error) {
 	// select image provider
-	providers := collections.TaggedValueSet[image.Provider]{}.Join(
+	allProviders := collections.TaggedValueSet[image.Provider]{}.Join(
 		ImageProviders(ImageProviderConfig{
-			UserInput: imgStr,
-			Platform:  cfg.Platform,
-			Registry:  cfg.Registry,
+			UserInput:    imgStr,
+			Platform:     cfg.Platform,
+			Registry:     cfg.Registry,
+			EnvOverrides: cfg.EnvOverrides,
 		})...,
 	)
+	providers := allProviders
 	if source != "" {
 		source = strings.ToLower(strings.TrimSpace(source))
 		providers = providers.Select(source)
 		if len(providers) == 0 {
-			return nil, fmt.Errorf("unable to find image providers matching: '%s'", source)
+			return nil, nil, fmt.Errorf("unable to find image providers matching: '%s'", source)
+		}
+	}
+	providers = providersRequested(providers, source)
+
+	// skip providers that cannot possibly handle the input (e.g. file providers for paths that do not exist)
+	providers = providersAccepting(providers, imgStr)
+	if len(providers) == 0 {
+		return nil, nil, fmt.Errorf("no image providers for source %q accept the input %q (not an existing path or a valid image reference)", source, imgStr)
+	}
+
+	if cfg.Offline {
+		// providers that pull from registries are never used, where daemon providers remain available (and never pull)
+		providers = providersWithCapabilities(providers, *cfg, image.OfflineCapability)
+		if len(providers) == 0 {
+			return nil, nil, fmt.Errorf("unable to provide images from source %q in offline mode: %w", source, image.ErrOffline)
 		}
 	}
 
+	if len(cfg.Requirements) > 0 {
+		providers = providersWithCapabilities(providers, *cfg, cfg.Requirements...)
+		if len(providers) == 0 {
+			return nil, nil, fmt.Errorf("no image providers for source %q satisfy the requirements: %s", source, strings.Join(cfg.Requirements, ", "))
+		}
+	}
+
+	if cfg.SignaturePolicy != nil {
+		// signatures can only be verified for images provided from a registry, thus never fall back to other sources
+		providers = providers.Select(image.RegistryTag)
+		cfg.SourceFallback = false
+		if len(providers) == 0 {
+			return nil, nil, fmt.Errorf("signature verification is only supported for registry images (source=%q)", source)
+		}
+	}
+
+	if requiresContentTrust(imgStr, cfg.ContentTrustPolicy) {
+		// the signed digest of the tag is pulled from the registry, thus never fall back to other sources
+		providers = providers.Select(image.RegistryTag)
+		cfg.SourceFallback = false
+		if len(providers) == 0 {
+			return nil, nil, fmt.Errorf("content trust verification is only supported for registry images (source=%q)", source)
+		}
+	}
+
+	return allProviders, providers, nil
+}
+
+// requiresContentTrust reports whether the input is a tag of a registry within the content trust policy (and not an
+// existing path that happens to be a valid tag).
+func requiresContentTrust(imgStr string, policy *image.ContentTrustPolicy) bool {
+	if policy == nil {
+		return false
+	}
+	if _, err := os.Stat(imgStr); err == nil {
+		return false
+	}
+	tag, err := name.NewTag(imgStr)
+	if err != nil {
+		return false
+	}
+	_, ok := policy.ForRegistry(tag.RegistryStr())
+	return ok
+}
+
+// providersRequested returns the given providers without the providers of explicit-only sources (see
+// image.SourceInfo.ExplicitOnly), unless the given source names the provider.
+func providersRequested(providers collections.TaggedValueSet[image.Provider], source image.Source) collections.TaggedValueSet[image.Provider] {
+	var selected collections.TaggedValueSet[image.Provider]
+	for _, provider := range providers {
+		if info, ok := image.LookupSource(provider.Value.Name()); ok && info.ExplicitOnly && !slices.Contains(info.Names(), source) {
+			continue
+		}
+		selected = append(selected, provider)
+	}
+	return selected
+}
+
+// providersWithCapabilities returns the given providers that have all of the given capabilities (see
+// image.SourceInfo.Capabilities). File inputs verified against an expected archive digest (see WithArchiveDigest) also
+// satisfy the digest capability.
+func providersWithCapabilities(providers collections.TaggedValueSet[image.Provider], cfg config, capabilities ...string) collections.TaggedValueSet[image.Provider] {
+	verifiedArchive := cfg.ArchiveDigest != "" || cfg.ChecksumFile != ""
+
+	var selected collections.TaggedValueSet[image.Provider]
+	for _, provider := range providers {
+		info, _ := image.LookupSource(provider.Value.Name())
+		capable := true
+		for _, c := range capabilities {
+			if info.HasCapabilities(c) || (c == image.DigestCapability && verifiedArchive && provider.HasTag(FileTag)) {
+				continue
+			}
+			capable = false
+			break
+		}
+		if capable {
+			selected = append(selected, provider)
+		}
+	}
+	return selected
+}
+
+// providersAccepting returns the given providers that could possibly handle the given input (see
+// image.Capabilities.Accepts), where providers with unknown capabilities are always kept.
+func providersAccepting(providers collections.TaggedValueSet[image.Provider], imgStr string) collections.TaggedValueSet[image.Provider] {
+	var selected collections.TaggedValueSet[image.Provider]
+	for _, provider := range providers {
+		capabilities, ok := image.ProviderCapabilities(provider.Value)
+		if ok && !capabilities.Accepts(imgStr) {
+			log.WithFields("provider", provider.Value.Name(), "input", capabilities.Input).Trace("skipping provider that does not accept the input")
+			continue
+		}
+		selected = append(selected, provider)
+	}
+	return selected
+}
+
+// finalizeImage verifies the provided image (when required) and applies the provider input and any additional
+// metadata from the config.
+func finalizeImage(img *image.Image, provider image.Provider, imgStr string, cfg config, allProviders collections.TaggedValueSet[image.Provider]) (*image.Image, error) {
+	if cfg.FIPS {
+		// note: digests are already verified before any layer is read (see image.WithFIPSMode), this is a final check
+		// of the provided image
+		if err := img.VerifyFIPSDigests(); err != nil {
+			return nil, cleanupFailedImage(img, err)
+		}
+	}
+	if cfg.ExpectedDigest != "" {
+		if err := img.VerifyDigest(cfg.ExpectedDigest); err != nil {
+			return nil, cleanupFailedImage(img, fmt.Errorf("unable to verify image digest: %w", err))
+		}
+		log.WithFields("digest", cfg.ExpectedDigest).Debug("verified image digest")
+	}
+	img.Metadata.ProviderInput = newProviderInput(img, provider, imgStr, allProviders.Select(FileTag, DirTag).HasValue(provider), cfg)
+	err := applyAdditionalMetadata(img, cfg.AdditionalMetadata...)
+	return img, err
+}
+
+// cleanupFailedImage removes the content of an image that failed verification, returning the given error.
+func cleanupFailedImage(img *image.Image, err error) error {
+	if cleanupErr := img.Cleanup(); cleanupErr != nil {
+		log.Warnf("unable to cleanup image: %+v", cleanupErr)
+	}
+	return err
+}
+
+// verifyArchive checks the given input file against the user-supplied digest or checksum file (if any), failing fast
+// on corrupted artifacts.
+func verifyArchive(path string, cfg config) error {
+	if cfg.ArchiveDigest == "" && cfg.ChecksumFile == "" {
+		return nil
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return fmt.Errorf("digest verification is only supported for archive files: %q", path)
+	}
+
+	expected := cfg.ArchiveDigest
+	if cfg.ChecksumFile != "" {
+		fromFile, err := file.DigestFromChecksumFile(cfg.ChecksumFile, path)
+		if err != nil {
+			return err
+		}
+		if expected != "" && expected != fromFile {
+			return fmt.Errorf("provided digest %s does not match checksum file digest %s", expected, fromFile)
+		}
+		expected = fromFile
+	}
+
+	if err := file.VerifyDigest(path, expected); err != nil {
+		return fmt.Errorf("unable to verify archive: %w", err)
+	}
+
+	log.WithFields("path", path, "digest", expected).Debug("verified archive digest")
+	return nil
+}
+
+// provideFirst returns the image from the first provider that is able to provide one (along with that provider) and
+// the errors from all providers attempted.
+func provideFirst(ctx context.Context, providers []image.Provider) (*image.Image, image.Provider, []error) {
 	var errs []error
-	for _, provider := range providers.Values() {
-		img, err := provider.Provide(ctx)
+	op := inflight.FromContext(ctx)
+	for _, provider := range providers {
+		op.SetProvider(provider.Name())
+		op.SetStage(inflight.StageProviding)
+		img, err := image.Provide(ctx, provider)
 		if err != nil {
 			errs = append(errs, err)
 		}
 		if img != nil {
-			err = applyAdditionalMetadata(img, cfg.AdditionalMetadata...)
-			return img, err
+			return img, provider, errs
 		}
 	}
-	return nil, fmt.Errorf("unable to detect input for '%s', errs: %w", imgStr, errors.Join(errs...))
+	return nil, nil, errs
+}
+
+// provideFallback attempts all remaining file-based providers when the providers for an explicitly requested
+// file-based source have failed (e.g. "docker-archive:" was requested for an OCI archive). Non-file sources (e.g. a
+// daemon or registry) never fall back, since the input may be ambiguous between them.
+func provideFallback(ctx context.Context, source image.Source, tried, all collections.TaggedValueSet[image.Provider], errs []error) (*image.Image, image.Provider, []error) {
+	for _, provider := range tried {
+		if !provider.HasTag(FileTag, DirTag) {
+			return nil, nil, errs
+		}
+	}
+
+	var candidates []image.Provider
+	for _, provider := range providersRequested(all.Select(FileTag, DirTag), "") {
+		if !tried.HasValue(provider.Value) {
+			candidates = append(candidates, provider.Value)
+		}
+	}
+
+	op := inflight.FromContext(ctx)
+	for _, provider := range candidates {
+		op.SetProvider(provider.Name())
+		op.SetStage(inflight.StageProviding)
+		img, err := image.Provide(ctx, provider)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if img != nil {
+			log.WithFields("requested", source, "detected", provider.Name()).
+				Warn("image input does not match the requested source, using the detected source instead")
+			return img, provider, errs
+		}
+	}
+	return nil, nil, errs
 }
 
+// SetLogger sets the logger used by stereoscope. All known secrets (see AddSecrets) are redacted from log messages
+// and field values.
 func SetLogger(logger logger.Logger) {
-	log.Log = logger
+	log.Log = log.Redacting(logger)
+}
+
+// AddSecrets registers additional secret values that should never appear within log messages, error strings, or
+// published events (until removed with RemoveSecrets). Registry passwords and tokens provided via WithCredentials are
+// registered automatically while an image is provided.
+func AddSecrets(values ...string) {
+	redact.Add(values...)
+}
+
+// RemoveSecrets unregisters secret values registered with AddSecrets (e.g. once a short-lived token expired).
+func RemoveSecrets(values ...string) {
+	redact.Remove(values...)
+}
+
+// AddSecretPatterns registers additional patterns for secrets that should never appear within log messages, error
+// strings, or published events. When a pattern has capture groups only the captured portions are redacted, otherwise
+// the entire match is redacted.
+func AddSecretPatterns(patterns ...*regexp.Regexp) {
+	redact.AddPatterns(patterns...)
 }
 
 func SetBus(b *partybus.Bus) {
@@ -134,3 +882,19 @@ func Cleanup() {
 		log.Errorf("failed to cleanup tempdir root: %w", err)
 	}
 }
+
+// GetContainerDrift provides an image representing the current filesystem state of the given containerd container
+// (see the containerd-snapshot source) along with all files that were added, modified, or deleted at runtime relative
+// to the underlying container image. Callers are responsible for cleaning up the returned image.
+func GetContainerDrift(ctx context.Context, containerID string, options ...Option) (*image.Image, []image.FileDrift, error) {
+	img, err := GetImageFromSource(ctx, containerID, image.ContainerdSnapshotSource, options...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	drift, err := img.TopLayerDrift()
+	if err != nil {
+		return img, nil, fmt.Errorf("unable to determine drift for container %q: %w", containerID, err)
+	}
+	return img, drift, nil
+}
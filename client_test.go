@@ -0,0 +1,455 @@
+package stereoscope
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anchore/go-collections"
+	"github.com/anchore/go-logger"
+	"github.com/anchore/go-logger/adapter/logrus"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/internal/fips"
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/internal/redact"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/anchore/stereoscope/pkg/image/docker"
+)
+
+func TestGetImageFromSource_SourceFallback(t *testing.T) {
+	// a docker archive, which will be requested as an OCI archive
+	archivePath := writeDockerArchive(t)
+
+	tests := []struct {
+		name    string
+		options []Option
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:    "strict by default",
+			wantErr: require.Error,
+		},
+		{
+			name:    "explicitly strict",
+			options: []Option{WithStrictSource(true)},
+			wantErr: require.Error,
+		},
+		{
+			name:    "fallback to detected source",
+			options: []Option{WithStrictSource(false)},
+			wantErr: require.NoError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provided, err := GetImageFromSource(context.Background(), archivePath, image.OciTarballSource, test.options...)
+			test.wantErr(t, err)
+			if provided != nil {
+				t.Cleanup(func() { _ = provided.Cleanup() })
+				require.Len(t, provided.Layers, 1)
+			}
+		})
+	}
+}
+
+func TestGetImageFromSource_ArchiveDigest(t *testing.T) {
+	archivePath := writeDockerArchive(t)
+
+	contents, err := os.ReadFile(archivePath)
+	require.NoError(t, err)
+	digest := fmt.Sprintf("%x", sha256.Sum256(contents))
+	wrongDigest := fmt.Sprintf("%x", sha256.Sum256([]byte("something else")))
+
+	writeChecksumFile := func(digest string) string {
+		path := filepath.Join(t.TempDir(), "SHA256SUMS")
+		require.NoError(t, os.WriteFile(path, []byte(digest+"  "+filepath.Base(archivePath)+"\n"), 0o644))
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		options []Option
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:    "matching digest",
+			options: []Option{WithArchiveDigest("sha256:" + digest)},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "mismatched digest",
+			options: []Option{WithArchiveDigest(wrongDigest)},
+			wantErr: requireDigestMismatch,
+		},
+		{
+			name:    "matching checksum file",
+			options: []Option{WithChecksumFile(writeChecksumFile(digest))},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "mismatched checksum file",
+			options: []Option{WithChecksumFile(writeChecksumFile(wrongDigest))},
+			wantErr: requireDigestMismatch,
+		},
+		{
+			name:    "invalid digest",
+			options: []Option{WithArchiveDigest("sha256:abc")},
+			wantErr: require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provided, err := GetImageFromSource(context.Background(), archivePath, image.DockerTarballSource, test.options...)
+			test.wantErr(t, err)
+			if provided != nil {
+				t.Cleanup(func() { _ = provided.Cleanup() })
+			}
+		})
+	}
+}
+
+func requireDigestMismatch(t require.TestingT, err error, _ ...interface{}) {
+	var mismatch *file.ErrDigestMismatch
+	require.ErrorAs(t, err, &mismatch)
+}
+
+func writeDockerArchive(t *testing.T) string {
+	t.Helper()
+
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+
+	ref, err := name.NewTag("example.com/test:latest")
+	require.NoError(t, err)
+
+	archivePath := filepath.Join(t.TempDir(), "image.tar")
+	require.NoError(t, tarball.WriteToFile(archivePath, ref, img))
+	return archivePath
+}
+
+func TestGetImageFromSource_ExpectedDigest(t *testing.T) {
+	archivePath := writeDockerArchive(t)
+
+	provided, err := GetImageFromSource(context.Background(), archivePath, image.DockerTarballSource)
+	require.NoError(t, err)
+	id := provided.Metadata.ID
+	require.NoError(t, provided.Cleanup())
+
+	provided, err = GetImageFromSource(context.Background(), archivePath, image.DockerTarballSource, WithExpectedDigest(strings.TrimPrefix(id, "sha256:")))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = provided.Cleanup() })
+	assert.Equal(t, id, provided.Metadata.ID)
+
+	_, err = GetImageFromSource(context.Background(), archivePath, image.DockerTarballSource, WithExpectedDigest("sha256:"+strings.Repeat("0", 64)))
+	var mismatch *image.ErrDigestMismatch
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, "image", mismatch.Subject)
+
+	require.Error(t, applyOptions(&config{}, WithExpectedDigest("latest")))
+}
+
+func TestGetImageFromSource_ErrorKinds(t *testing.T) {
+	dir := t.TempDir()
+	notATar := filepath.Join(t.TempDir(), "image.tar")
+	require.NoError(t, os.WriteFile(notATar, []byte(strings.Repeat("not a tar archive\n", 100)), 0o600))
+
+	tests := []struct {
+		name   string
+		input  string
+		source image.Source
+		want   error
+	}{
+		{name: "directory as archive", input: dir, source: image.DockerTarballSource, want: image.ErrNotAFile},
+		{name: "file as directory", input: notATar, source: image.OciDirectorySource, want: image.ErrNotAFile},
+		{name: "not a tar archive", input: notATar, source: image.DockerTarballSource, want: image.ErrWrongArchiveFormat},
+		{name: "directory without OCI layout", input: dir, source: image.OciDirectorySource, want: image.ErrWrongArchiveFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := GetImageFromSource(context.Background(), tt.input, tt.source)
+			require.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+func TestGetImageFromSource_RedactsCredentials(t *testing.T) {
+	secret := "hunter2-registry-password"
+	input := filepath.Join(t.TempDir(), secret+".tar")
+
+	_, err := GetImageFromSource(context.Background(), input, image.DockerTarballSource,
+		WithCredentials(image.RegistryCredentials{Username: "user", Password: secret, ClientKey: "/path/to/client.key"}),
+	)
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), secret)
+	assert.Contains(t, err.Error(), redact.Replacement+".tar")
+
+	// the secret is only registered while the image is provided
+	assert.Equal(t, secret, redact.Apply(secret))
+}
+
+func TestSetLogger_Redaction(t *testing.T) {
+	l, err := logrus.New(logrus.Config{Level: logger.InfoLevel, Formatter: logrus.DefaultTextFormatter()})
+	require.NoError(t, err)
+	var out bytes.Buffer
+	l.(interface{ SetOutput(io.Writer) }).SetOutput(&out)
+
+	previous := log.Log
+	SetLogger(l)
+	t.Cleanup(func() { log.Log = previous })
+
+	secret := "registry-secret-value"
+	AddSecrets(secret, "a")
+	t.Cleanup(func() { RemoveSecrets(secret, "a") })
+
+	log.Infof("logging in with %s", secret)
+	log.WithFields("password", secret, "attempts", 3).Info("retrying")
+	assert.NotContains(t, out.String(), secret)
+	assert.Contains(t, out.String(), "logging in with "+redact.Replacement)
+	assert.Contains(t, out.String(), "=3")
+	// values too short to be secrets do not rewrite unrelated text
+	assert.Contains(t, out.String(), "attempts")
+
+	// messages at disabled levels are never formatted (nor redacted)
+	var formatted countingStringer
+	log.Debugf("%s", &formatted)
+	log.WithFields("value", &formatted).Trace("disabled")
+	assert.Zero(t, int(formatted))
+	log.Info(&formatted)
+	assert.Equal(t, 1, int(formatted))
+}
+
+// countingStringer counts how often it is formatted.
+type countingStringer int
+
+func (c *countingStringer) String() string {
+	*c++
+	return "formatted"
+}
+
+func TestWithFIPSMode(t *testing.T) {
+	cfg := config{}
+	err := applyOptions(&cfg, WithFIPSMode())
+	if fips.Enabled() {
+		require.NoError(t, err)
+		assert.True(t, cfg.FIPS)
+		return
+	}
+	require.Error(t, err)
+	assert.False(t, cfg.FIPS)
+}
+
+func TestWithFIPSMode_fileDigests(t *testing.T) {
+	// note: FIPS mode is set directly, since the option requires a FIPS enabled binary
+	err := applyOptions(&config{FIPS: true}, WithFileDigests(image.FileDigestSHA256))
+	require.NoError(t, err)
+
+	err = applyOptions(&config{FIPS: true}, WithFileDigests(image.FileDigestXXH64))
+	require.ErrorContains(t, err, "not FIPS approved")
+
+	err = applyOptions(&config{}, WithFileDigests(image.FileDigestXXH64))
+	require.NoError(t, err)
+}
+
+func TestGetImageFromSource_SignatureVerification(t *testing.T) {
+	archivePath := writeDockerArchive(t)
+
+	err := applyOptions(&config{}, WithSignatureVerification(image.SignaturePolicy{}))
+	require.Error(t, err, "policies must accept some signature")
+
+	policy := image.SignaturePolicy{PublicKeys: [][]byte{[]byte("unused")}}
+
+	// signatures are stored within registries, thus other sources are never used
+	_, err = GetImageFromSource(context.Background(), archivePath, image.DockerTarballSource, WithSignatureVerification(policy), WithStrictSource(false))
+	require.ErrorContains(t, err, "only supported for registry images")
+}
+
+type panickingProvider struct{}
+
+func (panickingProvider) Name() string {
+	return "panicking"
+}
+
+func (panickingProvider) Provide(context.Context) (*image.Image, error) {
+	var img *image.Image
+	return img, img.Read()
+}
+
+func Test_provideFirst_recoversPanics(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "image.tar")
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	require.NoError(t, tarball.WriteToFile(archivePath, name.MustParseReference("anchore/test:latest"), img))
+
+	tmpDirGen := file.NewTempDirGenerator("test")
+	t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+	// a misbehaving provider does not prevent the remaining providers from being attempted
+	got, provider, errs := provideFirst(context.Background(), []image.Provider{
+		panickingProvider{},
+		docker.NewArchiveProvider(tmpDirGen, archivePath),
+	})
+	require.NotNil(t, got)
+	assert.Equal(t, image.DockerTarballSource, provider.Name())
+	require.Len(t, errs, 1)
+
+	var panicErr *image.ProviderPanicError
+	require.ErrorAs(t, errs[0], &panicErr)
+	assert.Equal(t, "panicking", panicErr.Provider)
+}
+
+func TestWithOfflineMode(t *testing.T) {
+	cfg := config{}
+	require.NoError(t, applyOptions(&cfg, WithOfflineMode()))
+
+	providers := selectProvidersForInputs(t, "", &cfg)
+	assert.False(t, providers.HasTag(image.RegistryTag))
+	assert.True(t, providers.HasTag(image.DaemonTag))
+	assert.True(t, providers.HasTag(image.FileTag))
+
+	// providers that always pull over the network cannot be requested explicitly
+	_, err := GetImageFromSource(context.Background(), "alpine:latest", image.OciRegistrySource, WithOfflineMode())
+	require.ErrorIs(t, err, image.ErrOffline)
+}
+
+func TestWithRequirements(t *testing.T) {
+	tests := []struct {
+		name         string
+		options      []Option
+		source       image.Source
+		wantSources  []image.Source
+		wantExcluded []image.Source
+		wantErr      require.ErrorAssertionFunc
+	}{
+		{
+			name:         "platform selection",
+			options:      []Option{WithRequirements(image.PlatformCapability)},
+			wantSources:  []image.Source{image.DockerTarballSource, image.OciDirectorySource, image.DockerDaemonSource, image.OciRegistrySource},
+			wantExcluded: []image.Source{image.SingularitySource, image.ContainerdSnapshotSource},
+		},
+		{
+			name:         "offline",
+			options:      []Option{WithRequirements(image.OfflineCapability)},
+			wantSources:  []image.Source{image.DockerTarballSource, image.DockerDaemonSource},
+			wantExcluded: []image.Source{image.OciRegistrySource},
+		},
+		{
+			name:         "verified digests",
+			options:      []Option{WithRequirements(image.DigestCapability)},
+			wantSources:  []image.Source{image.OciRegistrySource},
+			wantExcluded: []image.Source{image.DockerTarballSource, image.DockerDaemonSource},
+		},
+		{
+			name:         "verified digests of archives with an expected digest",
+			options:      []Option{WithRequirements(image.DigestCapability), WithArchiveDigest("sha256:" + strings.Repeat("0", 64))},
+			wantSources:  []image.Source{image.OciRegistrySource, image.DockerTarballSource},
+			wantExcluded: []image.Source{image.DockerDaemonSource},
+		},
+		{
+			name:    "unsatisfiable requirements for the requested source",
+			options: []Option{WithRequirements(image.DigestCapability)},
+			source:  image.DockerDaemonSource,
+			wantErr: require.Error,
+		},
+		{
+			name:    "empty requirement",
+			options: []Option{WithRequirements(" ")},
+			wantErr: require.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+
+			cfg := config{}
+			err := applyOptions(&cfg, tt.options...)
+			if err == nil {
+				_, _, err = selectProviders("alpine:latest", tt.source, &cfg)
+				if err == nil {
+					providers := selectProvidersForInputs(t, tt.source, &cfg)
+					for _, source := range tt.wantSources {
+						assert.True(t, providers.HasTag(source), "expected source %q", source)
+					}
+					for _, source := range tt.wantExcluded {
+						assert.False(t, providers.HasTag(source), "unexpected source %q", source)
+					}
+				}
+			}
+			tt.wantErr(t, err)
+		})
+	}
+
+	cfg := config{}
+	require.NoError(t, applyOptions(&cfg, WithRequirements(image.OfflineCapability)))
+	assert.True(t, cfg.Offline)
+}
+
+// selectProvidersForInputs returns the providers selected for both a reference input and a path input (since each
+// provider only accepts one kind of input), where inputs no provider is selected for are ignored.
+func selectProvidersForInputs(t *testing.T, source image.Source, cfg *config) collections.TaggedValueSet[image.Provider] {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "image.tar")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	var selected collections.TaggedValueSet[image.Provider]
+	for _, input := range []string{"alpine:latest", path} {
+		_, providers, err := selectProviders(input, source, cfg)
+		if err != nil {
+			continue
+		}
+		selected = append(selected, providers...)
+	}
+	return selected
+}
+
+func Test_selectProviders_acceptedInputs(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.tar")
+
+	// file providers are never attempted for paths that do not exist
+	_, _, err := selectProviders(missing, image.DockerTarballSource, &config{})
+	require.ErrorContains(t, err, "accept the input")
+
+	// daemon and registry providers are never attempted for inputs that are not references
+	_, providers, err := selectProviders(missing, "", &config{})
+	require.ErrorContains(t, err, "accept the input")
+	assert.Empty(t, providers)
+
+	_, providers, err = selectProviders("alpine:latest", "", &config{})
+	require.NoError(t, err)
+	assert.True(t, providers.HasTag(image.DaemonTag))
+	assert.False(t, providers.HasTag(image.FileTag))
+}
+
+func Test_selectProviders_explicitOnly(t *testing.T) {
+	dir := t.TempDir()
+
+	// any directory is a root filesystem, thus the rootfs directory source is never detected
+	_, providers, err := selectProviders(dir, "", &config{})
+	require.NoError(t, err)
+	assert.False(t, providers.HasTag(image.RootfsDirectorySource))
+
+	_, providers, err = selectProviders(dir, image.DirTag, &config{})
+	require.NoError(t, err)
+	assert.False(t, providers.HasTag(image.RootfsDirectorySource))
+
+	_, providers, err = selectProviders(dir, image.RootfsDirectorySource, &config{})
+	require.NoError(t, err)
+	require.Len(t, providers, 1)
+	assert.Equal(t, image.RootfsDirectorySource, providers[0].Value.Name())
+}
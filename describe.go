@@ -0,0 +1,44 @@
+package stereoscope
+
+import (
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// ProviderDescription is a machine-readable description of an available image provider, suitable for generating
+// help text and shell completions.
+type ProviderDescription struct {
+	// Name is the source name of the provider, usable as a scheme (e.g. "docker-archive:image.tar")
+	Name string `json:"name"`
+	// DisplayName is a human-readable name for the provider source
+	DisplayName string `json:"displayName"`
+	// Aliases are additional scheme names that select the provider
+	Aliases []string `json:"aliases,omitempty"`
+	// Tags are all tags that select the provider (e.g. "file", "daemon")
+	Tags []string `json:"tags"`
+	// Examples are example user inputs for the provider
+	Examples []string `json:"examples,omitempty"`
+}
+
+// DescribeProviders returns a description of all available image providers in the order they are attempted.
+func DescribeProviders() []ProviderDescription {
+	var out []ProviderDescription
+	for _, provider := range ImageProviders(ImageProviderConfig{}) {
+		name := provider.Value.Name()
+		description := ProviderDescription{
+			Name: name,
+			Tags: provider.Tags,
+		}
+		if info, ok := image.LookupSource(name); ok {
+			description.DisplayName = info.DisplayName
+			description.Aliases = info.Aliases
+			description.Examples = info.Examples
+		}
+		out = append(out, description)
+	}
+	return out
+}
+
+// ProviderTags returns all tags (including source names and aliases) that may be used to select image providers.
+func ProviderTags() []string {
+	return allProviderTags()
+}
@@ -0,0 +1,141 @@
+package stereoscope
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/anchore/go-collections"
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/internal/redact"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// GetImageIndex parses the user provided image string and provides all platform images of a multi-platform image (see
+// image.Index), where each platform image is only read when provided. Only registry and OCI layout (directory and
+// archive) sources provide multi-platform images, where single-platform images are provided as an index with a single
+// entry. All options apply to each platform image, except for the platform (since all platforms are provided).
+func GetImageIndex(ctx context.Context, imgStr string, options ...Option) (*image.Index, error) {
+	source, imgStr := ExtractSchemeSource(imgStr, allProviderTags()...)
+	index, err := getImageIndexFromSource(ctx, imgStr, source, options...)
+	return index, redact.Error(err)
+}
+
+// GetImageIndexFromSource returns all platform images of a multi-platform image from the explicitly provided source
+// (see GetImageIndex).
+func GetImageIndexFromSource(ctx context.Context, imgStr string, source image.Source, options ...Option) (*image.Index, error) {
+	if source == "" {
+		return nil, fmt.Errorf("source not provided, please specify a valid source tag")
+	}
+	index, err := getImageIndexFromSource(ctx, imgStr, source, options...)
+	return index, redact.Error(err)
+}
+
+func getImageIndexFromSource(ctx context.Context, imgStr string, source image.Source, options ...Option) (_ *image.Index, err error) {
+	log.Debugf("image index: source=%+v location=%+v", source, imgStr)
+
+	cfg := config{}
+	if err := applyOptions(&cfg, options...); err != nil {
+		return nil, err
+	}
+	cfg.Platform = nil
+	defer redactRegistrySecrets(cfg)(&err)
+
+	if err := verifyArchive(imgStr, cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.DockerOptions != nil {
+		cfg.EnvOverrides = cfg.DockerOptions.Apply(cfg.EnvOverrides)
+	}
+
+	ctx, cancel, err := indexContext(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	allProviders, providers, err := selectProviders(imgStr, source, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, provider := range providers.Values() {
+		indexProvider, ok := provider.(image.IndexProvider)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), image.ErrNotIndexProvider))
+			continue
+		}
+
+		index, err := image.ProvideIndex(ctx, indexProvider)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for idx, entry := range index.Entries {
+			index.Entries[idx] = image.NewIndexEntry(entry.Descriptor, entry.Platform, &indexEntryProvider{
+				entry:        entry,
+				rawIndex:     index.RawManifest,
+				provider:     provider,
+				imgStr:       imgStr,
+				cfg:          cfg,
+				allProviders: allProviders,
+			})
+		}
+		return index, nil
+	}
+	return nil, fmt.Errorf("unable to detect image index for '%s', errs: %w", imgStr, errors.Join(errs...))
+}
+
+// indexContext returns the context carrying the settings of the given config, bounded by the deadline budget (if any).
+func indexContext(ctx context.Context, cfg config) (context.Context, context.CancelFunc, error) {
+	ctx, err := contextWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.DeadlineBudget > 0 {
+		ctx, cancel := image.ContextWithTimeout(ctx, cfg.DeadlineBudget)
+		return ctx, cancel, nil
+	}
+	return ctx, func() {}, nil
+}
+
+// indexEntryProvider provides a platform image of an index with the settings from the options given for the index
+// (since the image is provided with the context of the caller).
+type indexEntryProvider struct {
+	entry image.IndexEntry
+	// rawIndex is the index manifest the entry is from (nil for single-platform images)
+	rawIndex     []byte
+	provider     image.Provider
+	imgStr       string
+	cfg          config
+	allProviders collections.TaggedValueSet[image.Provider]
+}
+
+func (p *indexEntryProvider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *indexEntryProvider) Provide(ctx context.Context) (_ *image.Image, err error) {
+	defer redactRegistrySecrets(p.cfg)(&err)
+
+	ctx, cancel, err := indexContext(ctx, p.cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	img, err := p.entry.Provide(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.rawIndex) > 0 {
+		// note: the index is known before any image is verified against a pinned index digest (see WithExpectedDigest)
+		if err := image.WithIndex(p.rawIndex)(img); err != nil {
+			return nil, err
+		}
+	}
+	return finalizeImage(img, p.provider, p.imgStr, p.cfg, p.allProviders)
+}
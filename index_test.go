@@ -0,0 +1,49 @@
+package stereoscope
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func TestGetImageIndex(t *testing.T) {
+	amd64Img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	arm64Img, err := random.Image(64, 2)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	_, err = layout.Write(dir, mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: amd64Img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: arm64Img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+	))
+	require.NoError(t, err)
+
+	// the platform option is ignored, since all platforms are provided
+	idx, err := GetImageIndex(context.Background(), "oci-dir:"+dir, WithPlatform("linux/s390x"), WithAdditionalMetadata(image.WithTags("app:latest")))
+	require.NoError(t, err)
+	require.Len(t, idx.Entries, 2)
+
+	var layers []int
+	require.NoError(t, idx.ForEach(context.Background(), func(e image.IndexEntry, img *image.Image) error {
+		layers = append(layers, len(img.Layers))
+		assert.Equal(t, image.OciDirectorySource, img.Metadata.ProviderInput.Source)
+		require.Len(t, img.Metadata.Tags, 1)
+		assert.Equal(t, "app:latest", img.Metadata.Tags[0].String())
+		return nil
+	}))
+	assert.Equal(t, []int{1, 2}, layers)
+
+	// sources that only provide single images
+	_, err = GetImageIndexFromSource(context.Background(), "alpine:latest", image.DockerDaemonSource)
+	require.ErrorIs(t, err, image.ErrNotIndexProvider)
+}
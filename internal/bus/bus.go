@@ -1,6 +1,10 @@
 package bus
 
-import "github.com/wagoodman/go-partybus"
+import (
+	"github.com/wagoodman/go-partybus"
+
+	"github.com/anchore/stereoscope/internal/redact"
+)
 
 var publisher partybus.Publisher
 var active bool
@@ -12,8 +16,17 @@ func SetPublisher(p partybus.Publisher) {
 	}
 }
 
+// Publish sends the given event to the publisher (if any), redacting all known secrets from the event source, value,
+// and error (see redact.Value).
 func Publish(event partybus.Event) {
 	if active {
-		publisher.Publish(event)
+		publisher.Publish(redactEvent(event))
 	}
 }
+
+func redactEvent(event partybus.Event) partybus.Event {
+	event.Source = redact.Value(event.Source)
+	event.Value = redact.Value(event.Value)
+	event.Error = redact.Error(event.Error)
+	return event
+}
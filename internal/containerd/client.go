@@ -2,21 +2,26 @@ package containerd
 
 import (
 	"fmt"
-	"os"
 
-	"github.com/adrg/xdg"
 	"github.com/containerd/containerd"
 	"github.com/containerd/containerd/defaults"
 	"github.com/containerd/containerd/namespaces"
 	"github.com/spf13/afero"
 
+	"github.com/anchore/stereoscope/internal/environ"
 	"github.com/anchore/stereoscope/internal/log"
 )
 
 var ErrNoSocketAddress = fmt.Errorf("no socket address")
 
 func GetClient() (*containerd.Client, error) {
-	client, err := containerd.New(Address())
+	return GetClientWithOverrides(nil)
+}
+
+// GetClientWithOverrides creates a containerd client for the address found within the given environment (see
+// AddressWithOverrides) instead of the process environment.
+func GetClientWithOverrides(env *environ.Overrides) (*containerd.Client, error) {
+	client, err := containerd.New(AddressWithOverrides(env))
 	if err != nil {
 		return nil, err
 	}
@@ -24,7 +29,13 @@ func GetClient() (*containerd.Client, error) {
 }
 
 func Address() string {
-	address, err := getAddress(afero.NewOsFs(), xdg.RuntimeDir, defaults.DefaultAddress)
+	return AddressWithOverrides(nil)
+}
+
+// AddressWithOverrides returns the containerd socket address from CONTAINERD_ADDRESS within the given environment,
+// otherwise the first rootless or default socket found.
+func AddressWithOverrides(env *environ.Overrides) string {
+	address, err := getAddress(afero.NewOsFs(), env, env.XDGRuntimeDir(), defaults.DefaultAddress)
 	if err != nil {
 		return ""
 	}
@@ -32,7 +43,13 @@ func Address() string {
 }
 
 func Namespace() string {
-	namespace := os.Getenv("CONTAINERD_NAMESPACE")
+	return NamespaceWithOverrides(nil)
+}
+
+// NamespaceWithOverrides returns the containerd namespace from CONTAINERD_NAMESPACE within the given environment
+// (otherwise the default namespace).
+func NamespaceWithOverrides(env *environ.Overrides) string {
+	namespace := env.Getenv("CONTAINERD_NAMESPACE")
 	if namespace == "" {
 		namespace = namespaces.Default
 	}
@@ -40,9 +57,9 @@ func Namespace() string {
 	return namespace
 }
 
-func getAddress(fs afero.Fs, xdgRuntimeDir, defaultSocketPath string) (string, error) {
+func getAddress(fs afero.Fs, env *environ.Overrides, xdgRuntimeDir, defaultSocketPath string) (string, error) {
 	var addr string
-	if v, found := os.LookupEnv("CONTAINERD_ADDRESS"); found && v != "" {
+	if v, found := env.LookupEnv("CONTAINERD_ADDRESS"); found && v != "" {
 		addr = v
 	}
 
@@ -50,15 +67,7 @@ func getAddress(fs afero.Fs, xdgRuntimeDir, defaultSocketPath string) (string, e
 		return addr, nil
 	}
 
-	candidateAddresses := []string{
-		// default rootless address
-		rootlessSocketPath(fs, xdgRuntimeDir),
-
-		// typically accessible to only root, but last ditch effort
-		defaultSocketPath,
-	}
-
-	for _, candidate := range candidateAddresses {
+	for _, candidate := range candidateSocketPaths(fs, xdgRuntimeDir, defaultSocketPath) {
 		if candidate == "" {
 			continue
 		}
@@ -77,6 +86,31 @@ func getAddress(fs afero.Fs, xdgRuntimeDir, defaultSocketPath string) (string, e
 	return addr, nil
 }
 
+// SocketPaths returns the containerd socket addresses probed for the given environment, in order of precedence (the
+// CONTAINERD_ADDRESS address, the rootless socket, and the default socket).
+func SocketPaths(env *environ.Overrides) []string {
+	var paths []string
+	if v, found := env.LookupEnv("CONTAINERD_ADDRESS"); found && v != "" {
+		paths = append(paths, v)
+	}
+	for _, candidate := range candidateSocketPaths(afero.NewOsFs(), env.XDGRuntimeDir(), defaults.DefaultAddress) {
+		if candidate != "" {
+			paths = append(paths, candidate)
+		}
+	}
+	return paths
+}
+
+func candidateSocketPaths(fs afero.Fs, xdgRuntimeDir, defaultSocketPath string) []string {
+	return []string{
+		// default rootless address
+		rootlessSocketPath(fs, xdgRuntimeDir),
+
+		// typically accessible to only root, but last ditch effort
+		defaultSocketPath,
+	}
+}
+
 func rootlessSocketPath(fs afero.Fs, xdgRuntimeDir string) string {
 	// look for rootless address (fallback to default if not found)
 	//export CONTAINERD_ADDRESS=/proc/$(cat $XDG_RUNTIME_DIR/containerd-rootless/child_pid)/root/run/containerd/containerd.sock
@@ -6,6 +6,9 @@ import (
 
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/internal/environ"
 )
 
 func Test_getAddress(t *testing.T) {
@@ -85,7 +88,7 @@ func Test_getAddress(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Setenv("CONTAINERD_ADDRESS", tt.args.containerHostEnvVar)
 			fs := afero.NewBasePathFs(afero.NewOsFs(), "test-fixtures")
-			got, err := getAddress(fs, tt.args.xdgRuntimeDir, tt.args.defaultSocketPath)
+			got, err := getAddress(fs, nil, tt.args.xdgRuntimeDir, tt.args.defaultSocketPath)
 			if !tt.wantErr(t, err, fmt.Sprintf("getAddress(%v)", tt.args.xdgRuntimeDir)) {
 				return
 			}
@@ -93,3 +96,24 @@ func Test_getAddress(t *testing.T) {
 		})
 	}
 }
+
+func Test_getAddress_Overrides(t *testing.T) {
+	t.Setenv("CONTAINERD_ADDRESS", "/process/containerd.sock")
+	fs := afero.NewBasePathFs(afero.NewOsFs(), "test-fixtures")
+
+	got, err := getAddress(fs, &environ.Overrides{Vars: map[string]string{"CONTAINERD_ADDRESS": "/override/containerd.sock"}}, "/xdg-runtime", "/default/containerd.sock")
+	require.NoError(t, err)
+	assert.Equal(t, "/override/containerd.sock", got)
+
+	got, err = getAddress(fs, &environ.Overrides{Hermetic: true}, "/xdg-runtime", "/default/containerd.sock")
+	require.NoError(t, err)
+	assert.Equal(t, "/proc/42/root/run/containerd/containerd.sock", got)
+}
+
+func TestNamespaceWithOverrides(t *testing.T) {
+	t.Setenv("CONTAINERD_NAMESPACE", "process")
+
+	assert.Equal(t, "process", NamespaceWithOverrides(nil))
+	assert.Equal(t, "override", NamespaceWithOverrides(&environ.Overrides{Vars: map[string]string{"CONTAINERD_NAMESPACE": "override"}}))
+	assert.Equal(t, "default", NamespaceWithOverrides(&environ.Overrides{Hermetic: true}))
+}
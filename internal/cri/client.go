@@ -0,0 +1,128 @@
+package cri
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+
+	"github.com/anchore/stereoscope/internal/environ"
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+// EndpointEnv is the environment variable used by crictl (and here) to select the CRI endpoint.
+const EndpointEnv = "CONTAINER_RUNTIME_ENDPOINT"
+
+// defaultEndpoints are the CRI sockets of containerd and CRI-O, in order of precedence.
+var defaultEndpoints = []string{
+	"unix:///run/containerd/containerd.sock",
+	"unix:///run/crio/crio.sock",
+	"unix:///var/run/crio/crio.sock",
+}
+
+const connectTimeout = 10 * time.Second
+
+// Client is a connection to the Kubernetes CRI API of the container runtime on a node.
+type Client struct {
+	conn *grpc.ClientConn
+	// Endpoint is the CRI endpoint connected to (e.g. unix:///run/containerd/containerd.sock)
+	Endpoint string
+	// RuntimeName is the name of the runtime serving the CRI API (e.g. "containerd" or "cri-o")
+	RuntimeName string
+	Runtime     runtimeapi.RuntimeServiceClient
+	Images      runtimeapi.ImageServiceClient
+}
+
+// GetClientWithOverrides connects to the CRI endpoint from CONTAINER_RUNTIME_ENDPOINT within the given environment,
+// otherwise to the first containerd or CRI-O socket found.
+func GetClientWithOverrides(ctx context.Context, env *environ.Overrides) (*Client, error) {
+	endpoints := SocketPaths(env)
+	if env.Getenv(EndpointEnv) == "" {
+		endpoints = existingSockets(endpoints)
+	}
+
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no CRI socket found (set %s)", EndpointEnv)
+	}
+
+	var errs error
+	for _, endpoint := range endpoints {
+		log.WithFields("endpoint", endpoint).Trace("trying CRI endpoint")
+		c, err := newClient(ctx, endpoint)
+		if err == nil {
+			return c, nil
+		}
+		errs = errors.Join(errs, fmt.Errorf("%s: %w", endpoint, err))
+	}
+	return nil, fmt.Errorf("unable to connect to CRI API: %w", errs)
+}
+
+func newClient(ctx context.Context, endpoint string) (*Client, error) {
+	if !strings.HasPrefix(endpoint, "unix://") {
+		return nil, fmt.Errorf("unsupported CRI endpoint (only unix sockets are supported)")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, endpoint,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	c := Client{
+		conn:     conn,
+		Endpoint: endpoint,
+		Runtime:  runtimeapi.NewRuntimeServiceClient(conn),
+		Images:   runtimeapi.NewImageServiceClient(conn),
+	}
+
+	version, err := c.Runtime.Version(ctx, &runtimeapi.VersionRequest{})
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("unable to get CRI runtime version: %w", err)
+	}
+	c.RuntimeName = version.RuntimeName
+
+	log.WithFields("endpoint", endpoint, "runtime", version.RuntimeName, "version", version.RuntimeVersion).Debug("connected to CRI API")
+	return &c, nil
+}
+
+// SocketPath is the filesystem path of the CRI endpoint socket.
+func (c *Client) SocketPath() string {
+	return strings.TrimPrefix(c.Endpoint, "unix://")
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// SocketPaths returns the CRI endpoints probed for the given environment, in order of precedence.
+func SocketPaths(env *environ.Overrides) []string {
+	if endpoint := env.Getenv(EndpointEnv); endpoint != "" {
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "unix://" + endpoint
+		}
+		return []string{endpoint}
+	}
+	return append([]string{}, defaultEndpoints...)
+}
+
+func existingSockets(endpoints []string) []string {
+	var out []string
+	for _, endpoint := range endpoints {
+		if _, err := os.Stat(strings.TrimPrefix(endpoint, "unix://")); err == nil {
+			out = append(out, endpoint)
+		}
+	}
+	return out
+}
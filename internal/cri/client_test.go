@@ -0,0 +1,38 @@
+package cri
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anchore/stereoscope/internal/environ"
+)
+
+func TestSocketPaths(t *testing.T) {
+	tests := []struct {
+		name string
+		env  *environ.Overrides
+		want []string
+	}{
+		{
+			name: "defaults",
+			env:  &environ.Overrides{Hermetic: true},
+			want: defaultEndpoints,
+		},
+		{
+			name: "endpoint",
+			env:  &environ.Overrides{Hermetic: true, Vars: map[string]string{EndpointEnv: "unix:///run/k3s/containerd/containerd.sock"}},
+			want: []string{"unix:///run/k3s/containerd/containerd.sock"},
+		},
+		{
+			name: "endpoint without scheme",
+			env:  &environ.Overrides{Hermetic: true, Vars: map[string]string{EndpointEnv: "/run/crio/crio.sock"}},
+			want: []string{"unix:///run/crio/crio.sock"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SocketPaths(tt.env))
+		})
+	}
+}
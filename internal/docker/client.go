@@ -2,67 +2,167 @@ package docker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 
 	"github.com/docker/cli/cli/connhelper"
 	"github.com/docker/docker/client"
-	"github.com/mitchellh/go-homedir"
+	"github.com/docker/go-connections/tlsconfig"
+
+	"github.com/anchore/stereoscope/internal/environ"
+	"github.com/anchore/stereoscope/internal/log"
 )
 
 func GetClient() (*client.Client, error) {
-	var clientOpts = []client.Opt{
-		client.FromEnv,
-		client.WithAPIVersionNegotiation(),
-	}
+	return GetClientWithOverrides(nil)
+}
 
-	host := os.Getenv("DOCKER_HOST")
-	if strings.HasPrefix(host, "ssh") {
-		var (
-			helper *connhelper.ConnectionHelper
-			err    error
-		)
+// EnvSocketPaths lists the daemon addresses (or unix socket paths) to probe, in order, when the default daemon is
+// unavailable and DOCKER_HOST is not set, separated by commas. This replaces the built-in
+// fallbacks (e.g. the Docker Desktop sockets within the home directory).
+const EnvSocketPaths = "STEREOSCOPE_DOCKER_SOCKETS"
 
-		helper, err = connhelper.GetConnectionHelper(host)
+// sshFlags are passed to ssh when connecting to a remote docker daemon (DOCKER_HOST=ssh://...), such that an
+// unreachable host results in an error instead of an indefinite hang.
+var sshFlags = []string{"-o", "ConnectTimeout=30"}
 
-		if err != nil {
-			return nil, fmt.Errorf("failed to fetch docker connection helper: %w", err)
-		}
-		clientOpts = append(clientOpts, func(c *client.Client) error {
-			httpClient := &http.Client{
-				Transport: &http.Transport{
-					DialContext: helper.Dialer,
-				},
-			}
-			return client.WithHTTPClient(httpClient)(c)
-		})
-		clientOpts = append(clientOpts, client.WithHost(helper.Host))
-		clientOpts = append(clientOpts, client.WithDialContext(helper.Dialer))
+// GetClientWithOverrides creates a docker client configured from the given environment (DOCKER_HOST, DOCKER_CERT_PATH,
+// DOCKER_TLS_VERIFY, and DOCKER_API_VERSION) instead of the process environment.
+func GetClientWithOverrides(env *environ.Overrides) (*client.Client, error) {
+	var clientOpts = []client.Opt{
+		fromEnv(env),
+		client.WithAPIVersionNegotiation(),
 	}
 
-	if os.Getenv("DOCKER_TLS_VERIFY") != "" && os.Getenv("DOCKER_CERT_PATH") == "" {
-		err := os.Setenv("DOCKER_CERT_PATH", "~/.docker")
-		if err != nil {
-			return nil, fmt.Errorf("failed create docker client: %w", err)
-		}
+	if host := env.Getenv(client.EnvOverrideHost); strings.HasPrefix(host, "ssh://") {
+		return getSSHClient(host, clientOpts...)
 	}
 
-	possibleSocketPaths := possibleSocketPaths(runtime.GOOS)
-	for _, socketPath := range possibleSocketPaths {
+	var errs error
+	for _, socketPath := range candidateSocketPaths(runtime.GOOS, env) {
+		if path, ok := strings.CutPrefix(socketPath, "unix://"); ok && !fileExists(path) {
+			log.WithFields("path", path).Trace("skipping missing docker socket")
+			continue
+		}
 		dockerClient, err := newClient(socketPath, clientOpts...)
 		if err == nil {
-			err := checkConnection(dockerClient)
+			err = checkConnection(dockerClient)
 			if err == nil {
 				return dockerClient, nil // Successfully connected
 			}
+			_ = dockerClient.Close()
 		}
+		errs = errors.Join(errs, err)
+	}
+
+	return nil, fmt.Errorf("failed to connect to Docker daemon. Ensure Docker is running and accessible: %w", errs)
+}
+
+// getSSHClient creates a docker client for a remote daemon reached over ssh (e.g. DOCKER_HOST=ssh://user@host). All
+// API requests (including streamed image saves) are tunneled through "ssh <host> docker system dial-stdio", thus the
+// remote host requires docker 18.09 or later, and the local ssh client configuration and agent are used for
+// authentication (the local socket paths are never tried as a fallback).
+func getSSHClient(host string, opts ...client.Opt) (*client.Client, error) {
+	helper, err := connhelper.GetConnectionHelperWithSSHOpts(host, sshFlags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch docker connection helper: %w", err)
+	}
+
+	opts = append(opts,
+		client.WithHTTPClient(&http.Client{
+			Transport: &http.Transport{
+				DialContext: helper.Dialer,
+			},
+		}),
+		client.WithHost(helper.Host),
+		client.WithDialContext(helper.Dialer),
+	)
+
+	dockerClient, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, err
 	}
 
-	// If both attempts failed
-	return nil, fmt.Errorf("failed to connect to Docker daemon. Ensure Docker is running and accessible")
+	if err := checkConnection(dockerClient); err != nil {
+		_ = dockerClient.Close()
+		return nil, fmt.Errorf("failed to connect to Docker daemon over ssh (%s): %w", host, err)
+	}
+	return dockerClient, nil
+}
+
+// envTLS enables TLS without verification of the daemon certificate (as with the docker CLI).
+const envTLS = "DOCKER_TLS"
+
+// fromEnv is equivalent to client.FromEnv, but reads from the given environment. TLS is used when a cert path is given
+// or when DOCKER_TLS or DOCKER_TLS_VERIFY are set, where the certs are expected within ~/.docker when no cert path is
+// given (any missing files are then skipped, as with the docker CLI).
+func fromEnv(env *environ.Overrides) client.Opt {
+	return func(c *client.Client) error {
+		tlsOptions, ok := tlsOptionsFromEnv(env)
+		if ok {
+			tlsc, err := tlsconfig.Client(tlsOptions)
+			if err != nil {
+				return fmt.Errorf("failed create docker client: %w", err)
+			}
+			err = client.WithHTTPClient(&http.Client{
+				Transport:     &http.Transport{TLSClientConfig: tlsc},
+				CheckRedirect: client.CheckRedirect,
+			})(c)
+			if err != nil {
+				return err
+			}
+		}
+
+		if host := env.Getenv(client.EnvOverrideHost); host != "" {
+			if err := client.WithHost(host)(c); err != nil {
+				return err
+			}
+		}
+
+		return client.WithVersion(env.Getenv(client.EnvOverrideAPIVersion))(c)
+	}
+}
+
+// tlsOptionsFromEnv returns the TLS configuration for the daemon connection and whether TLS should be used at all.
+func tlsOptionsFromEnv(env *environ.Overrides) (tlsconfig.Options, bool) {
+	certPath := env.Getenv(client.EnvOverrideCertPath)
+	verify := env.Getenv(client.EnvTLSVerify) != ""
+	if certPath == "" && !verify && env.Getenv(envTLS) == "" {
+		return tlsconfig.Options{}, false
+	}
+
+	explicitCertPath := certPath != ""
+	if !explicitCertPath {
+		certPath = filepath.Join(env.Home(), ".docker")
+	}
+
+	options := tlsconfig.Options{
+		CAFile:             filepath.Join(certPath, "ca.pem"),
+		CertFile:           filepath.Join(certPath, "cert.pem"),
+		KeyFile:            filepath.Join(certPath, "key.pem"),
+		InsecureSkipVerify: !verify,
+	}
+
+	if !explicitCertPath {
+		if !fileExists(options.CAFile) {
+			options.CAFile = ""
+		}
+		if !fileExists(options.CertFile) || !fileExists(options.KeyFile) {
+			options.CertFile = ""
+			options.KeyFile = ""
+		}
+	}
+	return options, true
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
 }
 
 func checkConnection(dockerClient *client.Client) error {
@@ -82,18 +182,72 @@ func newClient(socket string, opts ...client.Opt) (*client.Client, error) {
 	return client.NewClientWithOpts(opts...)
 }
 
-func possibleSocketPaths(os string) []string {
+// SocketPaths returns the docker daemon addresses probed for the given environment, in order of precedence (where the
+// client default is DOCKER_HOST when set, otherwise the platform default socket).
+func SocketPaths(env *environ.Overrides) []string {
+	var paths []string
+	for _, p := range candidateSocketPaths(runtime.GOOS, env) {
+		if p == "" {
+			p = client.DefaultDockerHost
+			if host := env.Getenv(client.EnvOverrideHost); host != "" {
+				p = host
+			}
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// candidateSocketPaths returns the daemon addresses to probe, where the client default ("") is always first. An
+// explicit DOCKER_HOST never falls back to other daemons, otherwise the fallbacks are the configured addresses (see
+// EnvSocketPaths) or the well-known sockets for the platform.
+func candidateSocketPaths(goos string, env *environ.Overrides) []string {
+	if env.Getenv(client.EnvOverrideHost) != "" {
+		return []string{""}
+	}
+
+	configured := env.Getenv(EnvSocketPaths)
+	if configured == "" {
+		return possibleSocketPaths(goos, env.Home())
+	}
+
+	paths := []string{""}
+	for _, p := range strings.Split(configured, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "://") {
+			p = "unix://" + p
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+func possibleSocketPaths(os, home string) []string {
+	if home == "" && os != "windows" {
+		return []string{""}
+	}
+
 	switch os {
 	case "darwin":
-		hDir, err := homedir.Dir()
-		if err != nil {
-			return []string{""}
-		}
 		return []string{
 			"", // try the client default first
-			fmt.Sprintf("unix://%s/Library/Containers/com.docker.docker/Data/docker.raw.sock", hDir),
+			// docker desktop 4.13+ (when the /var/run/docker.sock symlink has not been installed)
+			fmt.Sprintf("unix://%s/.docker/run/docker.sock", home),
+			fmt.Sprintf("unix://%s/Library/Containers/com.docker.docker/Data/docker.raw.sock", home),
+		}
+	case "windows":
+		return []string{
+			"", // try the client default first (the docker engine pipe)
+			"npipe:////./pipe/dockerDesktopLinuxEngine",
 		}
 	default:
-		return []string{""} // try the client default first
+		return []string{
+			"", // try the client default first
+			// docker desktop for linux
+			fmt.Sprintf("unix://%s/.docker/desktop/docker.sock", home),
+		}
 	}
 }
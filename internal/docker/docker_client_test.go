@@ -1,11 +1,20 @@
 package docker
 
 import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
 	"github.com/docker/docker/client"
+
+	"github.com/anchore/stereoscope/internal/environ"
 )
 
 func Test_newClient(t *testing.T) {
@@ -68,13 +77,31 @@ func Test_possibleSocketPaths(t *testing.T) {
 		{
 			name:     "Test possibleSocketPaths returns the correct default location for darwin",
 			provided: "darwin",
-			expected: []string{"", "Library/Containers/com.docker.docker/Data/docker.raw.sock"},
+			expected: []string{
+				"",
+				"/Users/someone/.docker/run/docker.sock",
+				"/Users/someone/Library/Containers/com.docker.docker/Data/docker.raw.sock",
+			},
+		},
+		{
+			name:     "Test possibleSocketPaths returns the docker desktop socket for linux",
+			provided: "linux",
+			expected: []string{"", "/Users/someone/.docker/desktop/docker.sock"},
+		},
+		{
+			name:     "Test possibleSocketPaths returns the docker desktop engine pipe for windows",
+			provided: "windows",
+			expected: []string{"", "npipe:////./pipe/dockerDesktopLinuxEngine"},
 		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			for i, socketPath := range possibleSocketPaths(c.provided) {
+			paths := possibleSocketPaths(c.provided, "/Users/someone")
+			if len(paths) != len(c.expected) {
+				t.Fatalf("possibleSocketPaths() = %v, want %v", paths, c.expected)
+			}
+			for i, socketPath := range paths {
 				if !strings.HasSuffix(socketPath, c.expected[i]) {
 					t.Errorf("possibleSocketPaths() = %v, want %v", socketPath, c.expected[i])
 				}
@@ -82,3 +109,261 @@ func Test_possibleSocketPaths(t *testing.T) {
 		})
 	}
 }
+
+func Test_candidateSocketPaths(t *testing.T) {
+	cases := []struct {
+		name     string
+		vars     map[string]string
+		expected []string
+	}{
+		{
+			name:     "built-in fallbacks",
+			vars:     map[string]string{"HOME": "/Users/someone"},
+			expected: []string{"", "unix:///Users/someone/.docker/run/docker.sock", "unix:///Users/someone/Library/Containers/com.docker.docker/Data/docker.raw.sock"},
+		},
+		{
+			name:     "explicit host does not fall back",
+			vars:     map[string]string{"HOME": "/Users/someone", "DOCKER_HOST": "unix:///custom/docker.sock"},
+			expected: []string{""},
+		},
+		{
+			name:     "configured fallbacks replace the built-in fallbacks",
+			vars:     map[string]string{"HOME": "/Users/someone", EnvSocketPaths: "/custom/docker.sock,, tcp://localhost:2375"},
+			expected: []string{"", "unix:///custom/docker.sock", "tcp://localhost:2375"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			env := &environ.Overrides{Vars: c.vars, Hermetic: true}
+			got := candidateSocketPaths("darwin", env)
+			if strings.Join(got, ",") != strings.Join(c.expected, ",") {
+				t.Errorf("candidateSocketPaths() = %v, want %v", got, c.expected)
+			}
+		})
+	}
+}
+
+func Test_fromEnv(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "unix:///var/PROCESS/docker.sock")
+
+	cases := []struct {
+		name         string
+		env          *environ.Overrides
+		expectedHost string
+	}{
+		{
+			name:         "process environment",
+			expectedHost: "unix:///var/PROCESS/docker.sock",
+		},
+		{
+			name: "overridden host",
+			env: &environ.Overrides{
+				Vars: map[string]string{"DOCKER_HOST": "tcp://localhost:2375"},
+			},
+			expectedHost: "tcp://localhost:2375",
+		},
+		{
+			name:         "hermetic",
+			env:          &environ.Overrides{Hermetic: true},
+			expectedHost: client.DefaultDockerHost,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client, err := newClient("", fromEnv(c.env))
+			if err != nil {
+				t.Fatalf("newClient() error = %v", err)
+			}
+
+			if client.DaemonHost() != c.expectedHost {
+				t.Errorf("newClient() = %v, want %v", client.DaemonHost(), c.expectedHost)
+			}
+		})
+	}
+}
+
+func Test_fromEnv_tls(t *testing.T) {
+	home := t.TempDir()
+
+	cases := []struct {
+		name       string
+		vars       map[string]string
+		wantTLS    bool
+		wantVerify bool
+		wantErr    bool
+	}{
+		{
+			name: "no tls",
+			vars: map[string]string{"DOCKER_HOST": "tcp://localhost:2375"},
+		},
+		{
+			name:    "tls without verification",
+			vars:    map[string]string{"DOCKER_HOST": "tcp://localhost:2376", "DOCKER_TLS": "1"},
+			wantTLS: true,
+		},
+		{
+			name:       "tls verification with missing default certs",
+			vars:       map[string]string{"DOCKER_HOST": "tcp://localhost:2376", "DOCKER_TLS_VERIFY": "1"},
+			wantTLS:    true,
+			wantVerify: true,
+		},
+		{
+			name:    "missing explicit cert path",
+			vars:    map[string]string{"DOCKER_HOST": "tcp://localhost:2376", "DOCKER_CERT_PATH": filepath.Join(home, "missing")},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dockerClient, err := newClient("", fromEnv(&environ.Overrides{Vars: c.vars, HomeDir: home, Hermetic: true}))
+			if c.wantErr {
+				if err == nil {
+					t.Fatal("newClient() expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newClient() error = %v", err)
+			}
+			if dockerClient.DaemonHost() != c.vars["DOCKER_HOST"] {
+				t.Errorf("newClient() = %v, want %v", dockerClient.DaemonHost(), c.vars["DOCKER_HOST"])
+			}
+
+			transport, ok := dockerClient.HTTPClient().Transport.(*http.Transport)
+			if !ok {
+				t.Fatalf("unexpected transport %T", dockerClient.HTTPClient().Transport)
+			}
+			if gotTLS := transport.TLSClientConfig != nil; gotTLS != c.wantTLS {
+				t.Fatalf("TLS = %v, want %v", gotTLS, c.wantTLS)
+			}
+			if c.wantTLS && transport.TLSClientConfig.InsecureSkipVerify == c.wantVerify {
+				t.Errorf("InsecureSkipVerify = %v, want %v", transport.TLSClientConfig.InsecureSkipVerify, !c.wantVerify)
+			}
+		})
+	}
+}
+
+func Test_GetClientWithOverrides_ssh(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ssh client is a shell script")
+	}
+
+	// a fake remote daemon, reachable only through the fake ssh client
+	dir, err := os.MkdirTemp("", "docker-ssh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	listener, err := net.Listen("unix", filepath.Join(dir, "docker.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := strings.Repeat("image tar contents", 1024*64)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("API-Version", "1.43")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_ping"):
+			_, _ = w.Write([]byte("OK"))
+		case strings.HasSuffix(r.URL.Path, "/images/get"):
+			_, _ = w.Write([]byte(saved))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	argsFile := filepath.Join(dir, "args")
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\nexec " + executable + " -test.run=^TestHelperProcess$\n"
+	if err := os.WriteFile(filepath.Join(dir, "ssh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("STEREOSCOPE_TEST_DIAL_STDIO", listener.Addr().String())
+
+	c, err := GetClientWithOverrides(&environ.Overrides{
+		Vars: map[string]string{"DOCKER_HOST": "ssh://someone@remote-host"},
+	})
+	if err != nil {
+		t.Fatalf("GetClientWithOverrides() error = %v", err)
+	}
+	defer c.Close()
+
+	reader, err := c.ImageSave(context.Background(), []string{"alpine:latest"})
+	if err != nil {
+		t.Fatalf("ImageSave() error = %v", err)
+	}
+	defer reader.Close()
+
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("unable to read image save stream: %v", err)
+	}
+	if string(got) != saved {
+		t.Errorf("ImageSave() streamed %d bytes, want %d", len(got), len(saved))
+	}
+
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"ConnectTimeout=30", "someone", "remote-host", "docker system dial-stdio"} {
+		if !strings.Contains(string(args), want) {
+			t.Errorf("ssh args %q do not contain %q", strings.TrimSpace(string(args)), want)
+		}
+	}
+}
+
+func Test_GetClientWithOverrides_sshUnreachable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ssh client is a shell script")
+	}
+
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho 'ssh: connect to host remote-host port 22: Connection refused' >&2\nexit 255\n"
+	if err := os.WriteFile(filepath.Join(dir, "ssh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	_, err := GetClientWithOverrides(&environ.Overrides{
+		Vars: map[string]string{"DOCKER_HOST": "ssh://someone@remote-host"},
+	})
+	if err == nil {
+		t.Fatal("GetClientWithOverrides() expected an error")
+	}
+	if !strings.Contains(err.Error(), "over ssh (ssh://someone@remote-host)") {
+		t.Errorf("GetClientWithOverrides() error = %v", err)
+	}
+}
+
+// TestHelperProcess is not a real test: it stands in for "docker system dial-stdio" on a remote host when executed by
+// the fake ssh client, proxying stdio to the unix socket given in the environment.
+func TestHelperProcess(t *testing.T) {
+	socket := os.Getenv("STEREOSCOPE_TEST_DIAL_STDIO")
+	if socket == "" {
+		return
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	go func() {
+		_, _ = io.Copy(conn, os.Stdin)
+		_ = conn.(*net.UnixConn).CloseWrite()
+	}()
+	_, _ = io.Copy(os.Stdout, conn)
+	os.Exit(0)
+}
@@ -0,0 +1,96 @@
+package environ
+
+import (
+	"context"
+	"os"
+
+	"github.com/adrg/xdg"
+)
+
+// Overrides is an explicit view of the environment that image providers read from (environment variables, the home
+// directory, and the XDG runtime directory). A nil Overrides is the process environment.
+type Overrides struct {
+	// Vars take precedence over the process environment (e.g. DOCKER_HOST, CONTAINERD_ADDRESS, CONTAINER_HOST)
+	Vars map[string]string
+	// Hermetic ignores the process environment entirely (only Vars and the directories given here are used)
+	Hermetic bool
+	// HomeDir overrides the user home directory (e.g. for docker and podman configuration)
+	HomeDir string
+	// RuntimeDir overrides the XDG runtime directory (e.g. for rootless podman and containerd sockets)
+	RuntimeDir string
+}
+
+// LookupEnv returns the value of the given environment variable and whether it is set.
+func (o *Overrides) LookupEnv(key string) (string, bool) {
+	if o == nil {
+		return os.LookupEnv(key)
+	}
+	if v, ok := o.Vars[key]; ok {
+		return v, true
+	}
+	if o.Hermetic {
+		return "", false
+	}
+	return os.LookupEnv(key)
+}
+
+// WithVar returns a copy of the overrides (of the process environment when nil) where the given variable is set.
+func (o *Overrides) WithVar(key, value string) *Overrides {
+	out := Overrides{Vars: make(map[string]string)}
+	if o != nil {
+		out = *o
+		out.Vars = make(map[string]string, len(o.Vars)+1)
+		for k, v := range o.Vars {
+			out.Vars[k] = v
+		}
+	}
+	out.Vars[key] = value
+	return &out
+}
+
+// Getenv returns the value of the given environment variable (empty when not set).
+func (o *Overrides) Getenv(key string) string {
+	v, _ := o.LookupEnv(key)
+	return v
+}
+
+// Home returns the user home directory.
+func (o *Overrides) Home() string {
+	if o != nil && o.HomeDir != "" {
+		return o.HomeDir
+	}
+	if v, ok := o.LookupEnv("HOME"); ok && v != "" {
+		return v
+	}
+	if o != nil && o.Hermetic {
+		return ""
+	}
+	return xdg.Home
+}
+
+// XDGRuntimeDir returns the XDG runtime directory.
+func (o *Overrides) XDGRuntimeDir() string {
+	if o != nil && o.RuntimeDir != "" {
+		return o.RuntimeDir
+	}
+	if v, ok := o.LookupEnv("XDG_RUNTIME_DIR"); ok && v != "" {
+		return v
+	}
+	if o != nil && o.Hermetic {
+		return ""
+	}
+	return xdg.RuntimeDir
+}
+
+type overridesKey struct{}
+
+// WithOverrides returns a context carrying the given environment overrides.
+func WithOverrides(ctx context.Context, o *Overrides) context.Context {
+	return context.WithValue(ctx, overridesKey{}, o)
+}
+
+// FromContext returns the environment overrides carried by the given context (or nil, the process environment).
+func FromContext(ctx context.Context) *Overrides {
+	o, _ := ctx.Value(overridesKey{}).(*Overrides)
+	return o
+}
@@ -0,0 +1,103 @@
+package environ
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverrides_LookupEnv(t *testing.T) {
+	t.Setenv("STEREOSCOPE_TEST_SET", "process")
+	t.Setenv("STEREOSCOPE_TEST_OVERRIDDEN", "process")
+
+	tests := []struct {
+		name       string
+		overrides  *Overrides
+		key        string
+		want       string
+		wantExists bool
+	}{
+		{
+			name:       "nil is the process environment",
+			key:        "STEREOSCOPE_TEST_SET",
+			want:       "process",
+			wantExists: true,
+		},
+		{
+			name:       "overridden",
+			overrides:  &Overrides{Vars: map[string]string{"STEREOSCOPE_TEST_OVERRIDDEN": "override"}},
+			key:        "STEREOSCOPE_TEST_OVERRIDDEN",
+			want:       "override",
+			wantExists: true,
+		},
+		{
+			name:       "falls back to the process environment",
+			overrides:  &Overrides{Vars: map[string]string{"STEREOSCOPE_TEST_OVERRIDDEN": "override"}},
+			key:        "STEREOSCOPE_TEST_SET",
+			want:       "process",
+			wantExists: true,
+		},
+		{
+			name:      "hermetic ignores the process environment",
+			overrides: &Overrides{Hermetic: true},
+			key:       "STEREOSCOPE_TEST_SET",
+		},
+		{
+			name:       "explicitly empty",
+			overrides:  &Overrides{Vars: map[string]string{"STEREOSCOPE_TEST_SET": ""}},
+			key:        "STEREOSCOPE_TEST_SET",
+			wantExists: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, exists := tt.overrides.LookupEnv(tt.key)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantExists, exists)
+		})
+	}
+}
+
+func TestOverrides_Directories(t *testing.T) {
+	t.Setenv("HOME", "/home/process")
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/process")
+
+	var process *Overrides
+	assert.Equal(t, "/home/process", process.Home())
+	assert.Equal(t, "/run/user/process", process.XDGRuntimeDir())
+
+	explicit := &Overrides{HomeDir: "/home/explicit", RuntimeDir: "/run/user/explicit"}
+	assert.Equal(t, "/home/explicit", explicit.Home())
+	assert.Equal(t, "/run/user/explicit", explicit.XDGRuntimeDir())
+
+	fromVars := &Overrides{Vars: map[string]string{"HOME": "/home/var", "XDG_RUNTIME_DIR": "/run/user/var"}, Hermetic: true}
+	assert.Equal(t, "/home/var", fromVars.Home())
+	assert.Equal(t, "/run/user/var", fromVars.XDGRuntimeDir())
+
+	hermetic := &Overrides{Hermetic: true}
+	assert.Empty(t, hermetic.Home())
+	assert.Empty(t, hermetic.XDGRuntimeDir())
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Nil(t, FromContext(context.Background()))
+
+	o := &Overrides{Hermetic: true}
+	assert.Same(t, o, FromContext(WithOverrides(context.Background(), o)))
+}
+
+func TestOverrides_WithVar(t *testing.T) {
+	t.Setenv("STEREOSCOPE_TEST_SET", "process")
+
+	fromProcess := (*Overrides)(nil).WithVar("STEREOSCOPE_TEST_ADDED", "added")
+	assert.Equal(t, "added", fromProcess.Getenv("STEREOSCOPE_TEST_ADDED"))
+	assert.Equal(t, "process", fromProcess.Getenv("STEREOSCOPE_TEST_SET"))
+
+	original := &Overrides{Hermetic: true, HomeDir: "/home/someone", Vars: map[string]string{"A": "a"}}
+	updated := original.WithVar("B", "b")
+	assert.Equal(t, map[string]string{"A": "a", "B": "b"}, updated.Vars)
+	assert.Equal(t, "/home/someone", updated.Home())
+	assert.Empty(t, updated.Getenv("STEREOSCOPE_TEST_SET"))
+	assert.Equal(t, map[string]string{"A": "a"}, original.Vars)
+}
@@ -0,0 +1,7 @@
+package fips
+
+// Enabled indicates if the binary was built with a FIPS 140 validated crypto module backing the standard library (via
+// GOEXPERIMENT=boringcrypto), in which case all digest computation is performed by the validated module.
+func Enabled() bool {
+	return boringEnabled()
+}
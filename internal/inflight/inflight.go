@@ -0,0 +1,152 @@
+package inflight
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// stages of an image provide
+const (
+	StageResolving = "resolving"
+	StageVerifying = "verifying"
+	StageProviding = "providing"
+	StageReading   = "reading"
+)
+
+// Status is a point-in-time snapshot of an in-flight operation.
+type Status struct {
+	ID           uint64
+	Input        string
+	Source       string
+	Provider     string
+	Stage        string
+	Started      time.Time
+	StageStarted time.Time
+	Bytes        int64
+}
+
+// Operation tracks the state of a single in-flight image provide. All methods are safe to call on a nil Operation.
+type Operation struct {
+	id      uint64
+	input   string
+	source  string
+	started time.Time
+	bytes   atomic.Int64
+
+	lock         sync.RWMutex
+	provider     string
+	stage        string
+	stageStarted time.Time
+}
+
+var (
+	lock       sync.RWMutex
+	nextID     uint64
+	operations = make(map[uint64]*Operation)
+)
+
+// Start begins tracking a new operation, which must be ended with Operation.Done.
+func Start(input, source, stage string) *Operation {
+	now := time.Now()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	nextID++
+	o := &Operation{
+		id:           nextID,
+		input:        input,
+		source:       source,
+		started:      now,
+		stage:        stage,
+		stageStarted: now,
+	}
+	operations[o.id] = o
+	return o
+}
+
+// All returns the status of all in-flight operations (oldest first).
+func All() []Status {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	out := make([]Status, 0, len(operations))
+	for _, o := range operations {
+		out = append(out, o.Status())
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// Done stops tracking the operation.
+func (o *Operation) Done() {
+	if o == nil {
+		return
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	delete(operations, o.id)
+}
+
+// SetStage records the current stage of the operation.
+func (o *Operation) SetStage(stage string) {
+	if o == nil {
+		return
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.stage = stage
+	o.stageStarted = time.Now()
+}
+
+// SetProvider records the provider currently being attempted.
+func (o *Operation) SetProvider(provider string) {
+	if o == nil {
+		return
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.provider = provider
+}
+
+// AddBytes records additional content bytes processed by the operation.
+func (o *Operation) AddBytes(n int64) {
+	if o == nil {
+		return
+	}
+	o.bytes.Add(n)
+}
+
+// Status returns a snapshot of the operation state.
+func (o *Operation) Status() Status {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	return Status{
+		ID:           o.id,
+		Input:        o.input,
+		Source:       o.source,
+		Provider:     o.provider,
+		Stage:        o.stage,
+		Started:      o.started,
+		StageStarted: o.stageStarted,
+		Bytes:        o.bytes.Load(),
+	}
+}
+
+type operationKey struct{}
+
+// WithOperation returns a context carrying the given operation.
+func WithOperation(ctx context.Context, o *Operation) context.Context {
+	return context.WithValue(ctx, operationKey{}, o)
+}
+
+// FromContext returns the operation carried by the given context (or nil).
+func FromContext(ctx context.Context) *Operation {
+	o, _ := ctx.Value(operationKey{}).(*Operation)
+	return o
+}
@@ -0,0 +1,49 @@
+package inflight
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperation(t *testing.T) {
+	first := Start("alpine:latest", "docker", StageResolving)
+	second := Start("image.tar", "", StageResolving)
+
+	ctx := WithOperation(context.Background(), second)
+	op := FromContext(ctx)
+	require.Same(t, second, op)
+
+	op.SetProvider("docker-archive")
+	op.SetStage(StageReading)
+	op.AddBytes(10)
+	op.AddBytes(5)
+
+	all := All()
+	require.Len(t, all, 2)
+	assert.Equal(t, "alpine:latest", all[0].Input)
+	assert.Equal(t, StageResolving, all[0].Stage)
+
+	assert.Equal(t, "image.tar", all[1].Input)
+	assert.Equal(t, "docker-archive", all[1].Provider)
+	assert.Equal(t, StageReading, all[1].Stage)
+	assert.Equal(t, int64(15), all[1].Bytes)
+	assert.False(t, all[1].StageStarted.Before(all[1].Started))
+
+	first.Done()
+	second.Done()
+	assert.Empty(t, All())
+}
+
+func TestOperation_nil(t *testing.T) {
+	op := FromContext(context.Background())
+	assert.Nil(t, op)
+
+	// all operations are no-ops on a nil operation
+	op.SetStage(StageReading)
+	op.SetProvider("docker-archive")
+	op.AddBytes(10)
+	op.Done()
+}
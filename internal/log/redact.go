@@ -0,0 +1,126 @@
+package log
+
+import (
+	"fmt"
+
+	"github.com/anchore/go-logger"
+	"github.com/anchore/stereoscope/internal/redact"
+)
+
+// Redacting wraps the given logger such that all known secrets are redacted from log messages and field values.
+func Redacting(l logger.Logger) logger.Logger {
+	if l == nil {
+		return nil
+	}
+	if _, ok := l.(*redactingLogger); ok {
+		return l
+	}
+	return &redactingLogger{
+		redactingMessageLogger: redactingMessageLogger{log: l},
+		log:                    l,
+	}
+}
+
+type redactingLogger struct {
+	redactingMessageLogger
+	log logger.Logger
+}
+
+func (l *redactingLogger) WithFields(fields ...interface{}) logger.MessageLogger {
+	return &redactingMessageLogger{log: l.log.WithFields(redactFields(fields)...)}
+}
+
+func (l *redactingLogger) Nested(fields ...interface{}) logger.Logger {
+	return Redacting(l.log.Nested(redactFields(fields)...))
+}
+
+type redactingMessageLogger struct {
+	log logger.MessageLogger
+}
+
+func (l *redactingMessageLogger) Errorf(format string, args ...interface{}) {
+	l.log.Error(redactedMessage{format: format, args: args})
+}
+
+func (l *redactingMessageLogger) Error(args ...interface{}) {
+	l.log.Error(redactedMessage{args: args})
+}
+
+func (l *redactingMessageLogger) Warnf(format string, args ...interface{}) {
+	l.log.Warn(redactedMessage{format: format, args: args})
+}
+
+func (l *redactingMessageLogger) Warn(args ...interface{}) {
+	l.log.Warn(redactedMessage{args: args})
+}
+
+func (l *redactingMessageLogger) Infof(format string, args ...interface{}) {
+	l.log.Info(redactedMessage{format: format, args: args})
+}
+
+func (l *redactingMessageLogger) Info(args ...interface{}) {
+	l.log.Info(redactedMessage{args: args})
+}
+
+func (l *redactingMessageLogger) Debugf(format string, args ...interface{}) {
+	l.log.Debug(redactedMessage{format: format, args: args})
+}
+
+func (l *redactingMessageLogger) Debug(args ...interface{}) {
+	l.log.Debug(redactedMessage{args: args})
+}
+
+func (l *redactingMessageLogger) Tracef(format string, args ...interface{}) {
+	l.log.Trace(redactedMessage{format: format, args: args})
+}
+
+func (l *redactingMessageLogger) Trace(args ...interface{}) {
+	l.log.Trace(redactedMessage{args: args})
+}
+
+// redactedMessage is a log message that is only formatted (and redacted) once the logger writes it, such that messages
+// at disabled levels are never redacted.
+type redactedMessage struct {
+	// format is the format of the args (the args are formatted as with fmt.Sprint when empty)
+	format string
+	args   []interface{}
+}
+
+func (m redactedMessage) String() string {
+	if m.format == "" {
+		return redact.Apply(fmt.Sprint(m.args...))
+	}
+	return redact.Apply(fmt.Sprintf(m.format, m.args...))
+}
+
+// redactedValue is a log field value that is only formatted (and redacted) once the logger writes it.
+type redactedValue struct {
+	value interface{}
+}
+
+func (v redactedValue) String() string {
+	return redact.Apply(fmt.Sprintf("%+v", v.value))
+}
+
+// MarshalText formats the value as a string for structured (e.g. JSON) log output.
+func (v redactedValue) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// redactFields wraps all field values (but not keys) such that they are redacted when written. Numbers and booleans
+// cannot hold secrets and are kept as-is.
+func redactFields(fields []interface{}) []interface{} {
+	out := make([]interface{}, len(fields))
+	for i, f := range fields {
+		out[i] = f
+		if i%2 == 0 || f == nil {
+			continue
+		}
+		switch f.(type) {
+		case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			continue
+		}
+		out[i] = redactedValue{value: f}
+	}
+	return out
+}
@@ -3,14 +3,14 @@ package podman
 import (
 	"context"
 	"fmt"
-	"os"
+	"path/filepath"
 	"time"
 
-	"github.com/adrg/xdg"
 	"github.com/docker/docker/client"
 	"github.com/pkg/errors"
 	"github.com/spf13/afero"
 
+	"github.com/anchore/stereoscope/internal/environ"
 	"github.com/anchore/stereoscope/internal/log"
 )
 
@@ -22,22 +22,29 @@ var (
 const defaultSocketPath = "/run/podman/podman.sock"
 
 func ClientOverSSH() (*client.Client, error) {
+	return ClientOverSSHWithOverrides(nil)
+}
+
+// ClientOverSSHWithOverrides creates a podman client over SSH configured from the given environment (CONTAINER_HOST,
+// CONTAINER_SSHKEY, CONTAINER_PASSPHRASE, and the containers.conf and known_hosts files within the home directory).
+func ClientOverSSHWithOverrides(env *environ.Overrides) (*client.Client, error) {
 	var clientOpts = []client.Opt{
 		client.WithAPIVersionNegotiation(),
 	}
 
-	host, identity := getSSHAddress(afero.NewOsFs(), configPaths)
+	home := env.Home()
+	host, identity := getSSHAddress(afero.NewOsFs(), configPaths(home))
 
-	if v, found := os.LookupEnv("CONTAINER_HOST"); found && v != "" {
+	if v, found := env.LookupEnv("CONTAINER_HOST"); found && v != "" {
 		host = v
 	}
 
-	if v, found := os.LookupEnv("CONTAINER_SSHKEY"); found && v != "" {
+	if v, found := env.LookupEnv("CONTAINER_SSHKEY"); found && v != "" {
 		identity = v
 	}
 
 	passPhrase := ""
-	if v, found := os.LookupEnv("CONTAINER_PASSPHRASE"); found {
+	if v, found := env.LookupEnv("CONTAINER_PASSPHRASE"); found {
 		passPhrase = v
 	}
 
@@ -45,6 +52,9 @@ func ClientOverSSH() (*client.Client, error) {
 	if err != nil {
 		return nil, err
 	}
+	if home != "" {
+		sshConf.knownHostsPath = filepath.Join(home, ".ssh", "known_hosts")
+	}
 
 	httpClient, err := httpClientOverSSH(sshConf)
 	if err != nil {
@@ -70,11 +80,17 @@ func ClientOverSSH() (*client.Client, error) {
 }
 
 func ClientOverUnixSocket() (*client.Client, error) {
+	return ClientOverUnixSocketWithOverrides(nil)
+}
+
+// ClientOverUnixSocketWithOverrides creates a podman client over a unix socket found within the given environment
+// (CONTAINER_HOST, the containers.conf files within the home directory, or the XDG runtime directory).
+func ClientOverUnixSocketWithOverrides(env *environ.Overrides) (*client.Client, error) {
 	var clientOpts = []client.Opt{
 		client.WithAPIVersionNegotiation(),
 	}
 
-	addr, err := getContainerHostAddress(afero.NewOsFs(), configPaths, xdg.RuntimeDir, defaultSocketPath)
+	addr, err := getContainerHostAddress(afero.NewOsFs(), env, configPaths(env.Home()), env.XDGRuntimeDir(), defaultSocketPath)
 	if err != nil {
 		return nil, err
 	}
@@ -93,9 +109,9 @@ func ClientOverUnixSocket() (*client.Client, error) {
 	return c, err
 }
 
-func getContainerHostAddress(fs afero.Fs, configPaths []string, xdgRuntimeDir, defaultSocketPath string) (string, error) {
+func getContainerHostAddress(fs afero.Fs, env *environ.Overrides, configPaths []string, xdgRuntimeDir, defaultSocketPath string) (string, error) {
 	var addr string
-	if v, found := os.LookupEnv("CONTAINER_HOST"); found && v != "" {
+	if v, found := env.LookupEnv("CONTAINER_HOST"); found && v != "" {
 		addr = v
 	} else {
 		addr = getUnixSocketAddressFromConfig(fs, configPaths)
@@ -106,15 +122,7 @@ func getContainerHostAddress(fs afero.Fs, configPaths []string, xdgRuntimeDir, d
 	}
 
 	// in some cases there might not be any config file, in which case we can try guessing (the same way the podman CLI does)
-	candidateAddresses := []string{
-		// default rootless address for the podman-system-service
-		fmt.Sprintf("%s/podman/podman.sock", xdgRuntimeDir),
-
-		// typically accessible to only root, but last ditch effort
-		defaultSocketPath,
-	}
-
-	for _, candidate := range candidateAddresses {
+	for _, candidate := range candidateSocketPaths(xdgRuntimeDir, defaultSocketPath) {
 		log.WithFields("path", candidate).Trace("trying podman socket")
 		_, err := fs.Stat(candidate)
 		if err == nil {
@@ -131,11 +139,40 @@ func getContainerHostAddress(fs afero.Fs, configPaths []string, xdgRuntimeDir, d
 }
 
 func GetClient() (*client.Client, error) {
-	c, err := ClientOverUnixSocket()
+	return GetClientWithOverrides(nil)
+}
+
+// GetClientWithOverrides creates a podman client (over a unix socket, otherwise over SSH) configured from the given
+// environment instead of the process environment.
+func GetClientWithOverrides(env *environ.Overrides) (*client.Client, error) {
+	c, err := ClientOverUnixSocketWithOverrides(env)
 	if err == nil {
 		return c, nil
 	}
 	log.WithFields("error", err).Trace("unable to connect to podman via unix socket")
 
-	return ClientOverSSH()
+	return ClientOverSSHWithOverrides(env)
+}
+
+// SocketPaths returns the podman socket addresses probed for the given environment, in order of precedence (the
+// CONTAINER_HOST address, the address from containers.conf, and the well-known socket paths).
+func SocketPaths(env *environ.Overrides) []string {
+	var paths []string
+	if v, found := env.LookupEnv("CONTAINER_HOST"); found && v != "" {
+		paths = append(paths, v)
+	}
+	if addr := getUnixSocketAddressFromConfig(afero.NewOsFs(), configPaths(env.Home())); addr != "" {
+		paths = append(paths, addr)
+	}
+	return append(paths, candidateSocketPaths(env.XDGRuntimeDir(), defaultSocketPath)...)
+}
+
+func candidateSocketPaths(xdgRuntimeDir, defaultSocketPath string) []string {
+	return []string{
+		// default rootless address for the podman-system-service
+		fmt.Sprintf("%s/podman/podman.sock", xdgRuntimeDir),
+
+		// typically accessible to only root, but last ditch effort
+		defaultSocketPath,
+	}
 }
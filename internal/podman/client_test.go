@@ -84,7 +84,7 @@ func Test_getContainerHostAddress(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Setenv("CONTAINER_HOST", tt.args.containerHostEnvVar)
 			fs := afero.NewBasePathFs(afero.NewOsFs(), "test-fixtures")
-			got, err := getContainerHostAddress(fs, tt.args.configPaths, tt.args.xdgRuntimeDir, tt.args.defaultSocketPath)
+			got, err := getContainerHostAddress(fs, nil, tt.args.configPaths, tt.args.xdgRuntimeDir, tt.args.defaultSocketPath)
 			if !tt.wantErr(t, err, fmt.Sprintf("getContainerHostAddress(%v, %v)", tt.args.configPaths, tt.args.xdgRuntimeDir)) {
 				return
 			}
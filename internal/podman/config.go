@@ -5,26 +5,25 @@ import (
 	"net/url"
 	"path/filepath"
 
-	"github.com/adrg/xdg"
 	"github.com/pelletier/go-toml"
 	"github.com/spf13/afero"
 )
 
-var (
-	// configFile is the default dir + container config used by podman.
-	configFile = filepath.Join("containers", "containers.conf")
+// configFile is the default dir + container config used by podman.
+var configFile = filepath.Join("containers", "containers.conf")
 
-	// configPaths holds a list of config files, they are sorted from
-	// the least to the most relevant during reading.
-	configPaths = []string{
+// configPaths returns a list of config files for the given home directory, they are sorted from
+// the least to the most relevant during reading.
+func configPaths(home string) []string {
+	return []string{
 		// holds the default containers config path
 		filepath.Join("usr", "share", configFile),
 		// holds the default config path overridden by the root user
 		filepath.Join("etc", configFile),
 		// holds the container config path overridden by the rootless user
-		filepath.Join(xdg.Home, ".config", configFile),
+		filepath.Join(home, ".config", configFile),
 	}
-)
+}
 
 type containersConfig struct {
 	Engine engine `toml:"engine"`
@@ -0,0 +1,138 @@
+package podman
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/afero"
+
+	"github.com/anchore/stereoscope/internal/environ"
+)
+
+// Connection is a podman service destination: a unix socket or ssh address of the podman REST API along with the ssh
+// identity used to connect (when over ssh).
+type Connection struct {
+	// Name is the name of the connection within containers.conf (empty for connections from the environment or
+	// well-known socket paths)
+	Name     string
+	URI      string
+	Identity string
+}
+
+// Connections returns the podman service destinations for the given environment in order of precedence: the
+// CONTAINER_HOST address, the connection named by CONTAINER_CONNECTION, the active service within containers.conf,
+// all other containers.conf service destinations (by name), and the well-known rootless and rootful socket paths.
+// Destinations are not probed, thus any of them may be unavailable.
+func Connections(env *environ.Overrides) []Connection {
+	return connections(afero.NewOsFs(), env, configPaths(env.Home()), env.XDGRuntimeDir(), defaultSocketPath)
+}
+
+func connections(fs afero.Fs, env *environ.Overrides, paths []string, xdgRuntimeDir, defaultSocketPath string) []Connection {
+	var out []Connection
+	seen := make(map[string]struct{})
+	add := func(c Connection) {
+		if c.URI == "" {
+			return
+		}
+		if _, ok := seen[c.URI]; ok {
+			return
+		}
+		seen[c.URI] = struct{}{}
+		out = append(out, c)
+	}
+
+	if v, found := env.LookupEnv("CONTAINER_HOST"); found && v != "" {
+		add(Connection{URI: v, Identity: env.Getenv("CONTAINER_SSHKEY")})
+	}
+
+	cc := mergeContainerConfigs(fs, paths)
+	destination := func(name string) {
+		if d, ok := cc.Engine.ServiceDestinations[name]; ok {
+			add(Connection{Name: name, URI: d.URI, Identity: d.Identity})
+		}
+	}
+
+	if v, found := env.LookupEnv("CONTAINER_CONNECTION"); found && v != "" {
+		destination(v)
+	}
+	destination(cc.Engine.ActiveService)
+
+	var names []string
+	for name := range cc.Engine.ServiceDestinations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		destination(name)
+	}
+
+	for _, candidate := range candidateSocketPaths(xdgRuntimeDir, defaultSocketPath) {
+		if _, err := fs.Stat(candidate); err == nil {
+			add(Connection{URI: fmt.Sprintf("unix://%s", candidate)})
+		}
+	}
+
+	return out
+}
+
+// mergeContainerConfigs reads the engine configuration from the given containers.conf files, where later files take
+// precedence over earlier ones (the active service is replaced and service destinations are merged by name).
+func mergeContainerConfigs(fs afero.Fs, paths []string) containersConfig {
+	merged := containersConfig{
+		Engine: engine{
+			ServiceDestinations: make(map[string]serviceDestination),
+		},
+	}
+	for _, p := range paths {
+		cc, err := parseContainerConfig(fs, p)
+		if err != nil || cc == nil {
+			continue
+		}
+		if cc.Engine.ActiveService != "" {
+			merged.Engine.ActiveService = cc.Engine.ActiveService
+		}
+		for name, d := range cc.Engine.ServiceDestinations {
+			merged.Engine.ServiceDestinations[name] = d
+		}
+	}
+	return merged
+}
+
+// HTTPClient creates an HTTP client for the podman REST API at the given connection (requests are made to the
+// "http://d" host, see ClientOverSSHWithOverrides). The CONTAINER_PASSPHRASE for ssh identities and the known_hosts
+// file within the home directory are read from the given environment.
+func (c Connection) HTTPClient(env *environ.Overrides) (*http.Client, error) {
+	u, err := url.Parse(c.URI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid podman connection %q: %w", c.URI, err)
+	}
+
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		return &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		}, nil
+	case "ssh":
+		sshConf, err := newSSHConf(c.URI, c.Identity, env.Getenv("CONTAINER_PASSPHRASE"))
+		if err != nil {
+			return nil, err
+		}
+		if home := env.Home(); home != "" {
+			sshConf.knownHostsPath = filepath.Join(home, ".ssh", "known_hosts")
+		}
+		return httpClientOverSSH(sshConf)
+	default:
+		return nil, fmt.Errorf("unsupported podman connection scheme %q", u.Scheme)
+	}
+}
@@ -0,0 +1,80 @@
+package podman
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/internal/environ"
+)
+
+func Test_connections(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/etc/containers.conf", []byte(`
+[engine]
+active_service = "system"
+
+[engine.service_destinations.system]
+uri = "unix:///run/podman/system.sock"
+
+[engine.service_destinations.remote]
+uri = "ssh://core@remote:22/run/podman/podman.sock"
+identity = "/etc/id_ed25519"
+`), 0600))
+	require.NoError(t, afero.WriteFile(fs, "/home/user/containers.conf", []byte(`
+[engine]
+active_service = "user"
+
+[engine.service_destinations.user]
+uri = "unix:///run/user/1000/podman/podman.sock"
+
+[engine.service_destinations.remote]
+uri = "ssh://user@remote:22/run/user/1000/podman/podman.sock"
+identity = "/home/user/id_ed25519"
+`), 0600))
+	require.NoError(t, afero.WriteFile(fs, "/run/user/1000/podman/podman.sock", nil, 0600))
+	require.NoError(t, afero.WriteFile(fs, "/run/podman/podman.sock", nil, 0600))
+
+	paths := []string{"/etc/containers.conf", "/home/user/containers.conf"}
+
+	tests := []struct {
+		name string
+		vars map[string]string
+		want []Connection
+	}{
+		{
+			name: "active service first",
+			want: []Connection{
+				{Name: "user", URI: "unix:///run/user/1000/podman/podman.sock"},
+				{Name: "remote", URI: "ssh://user@remote:22/run/user/1000/podman/podman.sock", Identity: "/home/user/id_ed25519"},
+				{Name: "system", URI: "unix:///run/podman/system.sock"},
+				{URI: "unix:///run/podman/podman.sock"},
+			},
+		},
+		{
+			name: "CONTAINER_HOST then CONTAINER_CONNECTION",
+			vars: map[string]string{
+				"CONTAINER_HOST":       "ssh://root@other:22/run/podman/podman.sock",
+				"CONTAINER_SSHKEY":     "/key",
+				"CONTAINER_CONNECTION": "system",
+			},
+			want: []Connection{
+				{URI: "ssh://root@other:22/run/podman/podman.sock", Identity: "/key"},
+				{Name: "system", URI: "unix:///run/podman/system.sock"},
+				{Name: "user", URI: "unix:///run/user/1000/podman/podman.sock"},
+				{Name: "remote", URI: "ssh://user@remote:22/run/user/1000/podman/podman.sock", Identity: "/home/user/id_ed25519"},
+				{URI: "unix:///run/podman/podman.sock"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			env := &environ.Overrides{Vars: test.vars, Hermetic: true}
+			got := connections(fs, env, paths, "/run/user/1000", "/run/podman/podman.sock")
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
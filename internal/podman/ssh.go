@@ -8,11 +8,9 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"path/filepath"
 	"strconv"
 	"time"
 
-	"github.com/docker/docker/pkg/homedir"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/knownhosts"
@@ -28,6 +26,9 @@ type sshClientConfig struct {
 	secure        bool
 	username      string
 	password      string
+	// knownHostsPath is the known_hosts file used to verify the host key (the callers resolve ~/.ssh/known_hosts
+	// within the environment overrides, none when there is no home directory)
+	knownHostsPath string
 }
 
 func newSSHConf(address, identity, passPhrase string) (*sshClientConfig, error) {
@@ -122,8 +123,7 @@ func getSSHCallback(params *sshClientConfig) ssh.HostKeyCallback {
 		return cb
 	}
 
-	knownHosts := filepath.Join(homedir.Get(), ".ssh", "known_hosts")
-	key := hostKey(params.host, knownHosts)
+	key := hostKey(params.host, params.knownHostsPath)
 	if key != nil {
 		cb = ssh.FixedHostKey(key)
 	}
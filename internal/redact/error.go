@@ -0,0 +1,27 @@
+package redact
+
+// Error wraps the given error such that all secrets known to the global store are redacted from the error string. The
+// error string is redacted immediately, thus remains redacted once secrets are removed from the store. The original
+// error remains available to errors.Is and errors.As.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*redactedError); ok {
+		return err
+	}
+	return &redactedError{err: err, msg: Apply(err.Error())}
+}
+
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,52 @@
+package redact
+
+import "reflect"
+
+// Value returns the given value (e.g. an event payload) with all secrets known to the global store redacted: strings,
+// errors, and the exported string fields (including string slices and nested struct values) of struct values, which are
+// copied. Pointers, maps, interfaces, and all other values are returned as-is, since these may be shared with the
+// caller (e.g. progress monitors).
+func Value(v any) any {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return Apply(t)
+	case error:
+		return Error(t)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Struct {
+		return v
+	}
+	out := reflect.New(rv.Type()).Elem()
+	out.Set(rv)
+	redactFields(out)
+	return out.Interface()
+}
+
+func redactFields(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if !f.CanSet() {
+			continue
+		}
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(Apply(f.String()))
+		case reflect.Struct:
+			redactFields(f)
+		case reflect.Slice:
+			if f.Type().Elem().Kind() != reflect.String || f.Len() == 0 {
+				continue
+			}
+			// note: the slice is copied, since its backing array is shared with the caller
+			redacted := reflect.MakeSlice(f.Type(), f.Len(), f.Len())
+			for j := 0; j < f.Len(); j++ {
+				redacted.Index(j).SetString(Apply(f.Index(j).String()))
+			}
+			f.Set(redacted)
+		}
+	}
+}
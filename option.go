@@ -3,6 +3,7 @@ package stereoscope
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/anchore/stereoscope/pkg/image"
 )
@@ -13,6 +14,54 @@ type config struct {
 	Registry           image.RegistryOptions
 	AdditionalMetadata []image.AdditionalMetadata
 	Platform           *image.Platform
+	DeadlineBudget     time.Duration
+	// ArchiveDigest is the expected sha256 digest of an archive input
+	ArchiveDigest string
+	// ExpectedDigest is the digest the provided image is pinned to (see image.Image.VerifyDigest)
+	ExpectedDigest string
+	// ChecksumFile is a sha256sum formatted file containing the expected digest of an archive input
+	ChecksumFile string
+	// SourceFallback allows for content-based detection when the explicitly requested source fails
+	SourceFallback bool
+	// ReadMetadata is applied to the image before any layers are read (e.g. layer limits)
+	ReadMetadata []image.AdditionalMetadata
+	// ReadOnlyDaemon forbids any mutation of container runtimes (e.g. pulling images into the daemon store)
+	ReadOnlyDaemon bool
+	// Offline forbids any network access (registry providers are not used, and daemons never pull missing images)
+	Offline bool
+	// Requirements are the capabilities providers must have to be attempted (see image.SourceInfo.Capabilities)
+	Requirements []string
+	// FIPS restricts all digest computation and verification to FIPS approved algorithms
+	FIPS bool
+	// FileDigests are the additional algorithms the contents of all regular files are digested with (see WithFileDigests)
+	FileDigests []image.FileDigestAlgorithm
+	// EnvOverrides is the environment used for daemon discovery instead of the process environment (when set)
+	EnvOverrides *image.EnvOverrides
+	// DockerOptions are explicit docker daemon connection settings, taking precedence over the environment (when set)
+	DockerOptions *image.DockerOptions
+	// StreamingExport consumes daemon image exports as a stream instead of writing the full image tar to disk first
+	StreamingExport bool
+	// DaemonExport configures stall detection and platform selection for docker daemon exports (defaults when nil)
+	DaemonExport *image.DaemonExportOptions
+	// SignaturePolicy is the cosign signature policy images must satisfy (signatures are not verified when nil)
+	SignaturePolicy *image.SignaturePolicy
+	// ContentTrustPolicy describes the registries whose tags are verified with Docker Content Trust (none when nil)
+	ContentTrustPolicy *image.ContentTrustPolicy
+	// ImageSelector selects the image by reference name or digest from OCI layouts holding more than one image
+	ImageSelector string
+	// CacheDir is the root of the persistent blob cache shared between invocations (no cache when empty)
+	CacheDir string
+	// CacheMaxSize is the maximum size of the persistent blob cache in bytes (unbounded when zero)
+	CacheMaxSize int64
+	// CacheKeepPerRepository is the number of most recently read images retained per repository within the persistent
+	// blob cache (unbounded when zero)
+	CacheKeepPerRepository int
+	// Clock measures timed operations instead of the system clock (when set)
+	Clock image.Clock
+	// RetryPolicy retries network operations failing with transient errors (the defaults of each source when nil)
+	RetryPolicy *image.RetryPolicy
+	// BandwidthLimit is the maximum rate of registry downloads in bytes per second (unlimited when zero)
+	BandwidthLimit int64
 }
 
 func applyOptions(cfg *config, options ...Option) error {
@@ -24,6 +73,21 @@ func applyOptions(cfg *config, options ...Option) error {
 			return fmt.Errorf("unable to parse option: %w", err)
 		}
 	}
+	if err := cfg.validate(); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	return nil
+}
+
+// validate checks for options that conflict with each other (regardless of the order the options are given in).
+func (c config) validate() error {
+	if c.FIPS {
+		for _, algorithm := range c.FileDigests {
+			if !image.IsFIPSApprovedDigestAlgorithm(string(algorithm)) {
+				return fmt.Errorf("file digest algorithm %q is not FIPS approved", algorithm)
+			}
+		}
+	}
 	return nil
 }
 
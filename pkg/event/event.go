@@ -9,5 +9,7 @@ const (
 	PullContainerdImage partybus.EventType = "pull-containerd-image-event"
 	FetchImage          partybus.EventType = "fetch-image-event"
 	ReadImage           partybus.EventType = "read-image-event"
+	ReadImageIndex      partybus.EventType = "read-image-index-event"
 	ReadLayer           partybus.EventType = "read-layer-event"
+	ExportImage         partybus.EventType = "export-image-event"
 )
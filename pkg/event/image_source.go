@@ -0,0 +1,22 @@
+package event
+
+// ImageSource describes where an image is fetched from, published as the source of the FetchImage, PullDockerImage,
+// and PullContainerdImage events.
+type ImageSource struct {
+	// Image is the reference of the image being fetched (e.g. "docker.io/library/alpine:latest").
+	Image string
+	// Provider is the name of the provider fetching the image (e.g. "docker", "podman", or "containerd").
+	Provider string
+	// Digest is the digest the image resolved to within the provider (the image ID for docker daemons, the target
+	// manifest or index digest for containerd). Empty when not yet known (e.g. while the image is pulled).
+	Digest string
+	// Pulled indicates the image was pulled from a registry by the daemon, as opposed to being present already.
+	Pulled bool
+	// Namespace is the containerd namespace of the image (empty for other providers).
+	Namespace string
+}
+
+// String returns the image reference, as published as the event source before structured sources were introduced.
+func (s ImageSource) String() string {
+	return s.Image
+}
@@ -37,14 +37,46 @@ func checkEventType(actual, expected partybus.EventType) error {
 	return nil
 }
 
+// imageName returns the image reference the event source describes, where sources are either structured (see
+// event.ImageSource) or the plain image reference.
+func imageName(e partybus.Event) (string, error) {
+	switch source := e.Source.(type) {
+	case event.ImageSource:
+		return source.Image, nil
+	case string:
+		return source, nil
+	}
+	return "", newPayloadErr(e.Type, "Source", e.Source)
+}
+
+// ParseImageSource returns the details of the image being fetched from a FetchImage, PullDockerImage, or
+// PullContainerdImage event (e.g. the provider, resolved digest, and whether the image was pulled). Events carrying only
+// the image reference are described by the reference alone.
+func ParseImageSource(e partybus.Event) (*event.ImageSource, error) {
+	switch e.Type {
+	case event.FetchImage, event.PullDockerImage, event.PullContainerdImage:
+	default:
+		return nil, newPayloadErr(event.FetchImage, "Type", e.Type)
+	}
+
+	if source, ok := e.Source.(event.ImageSource); ok {
+		return &source, nil
+	}
+	imgName, err := imageName(e)
+	if err != nil {
+		return nil, err
+	}
+	return &event.ImageSource{Image: imgName}, nil
+}
+
 func ParsePullDockerImage(e partybus.Event) (string, *docker.PullStatus, error) {
 	if err := checkEventType(e.Type, event.PullDockerImage); err != nil {
 		return "", nil, err
 	}
 
-	imgName, ok := e.Source.(string)
-	if !ok {
-		return "", nil, newPayloadErr(e.Type, "Source", e.Source)
+	imgName, err := imageName(e)
+	if err != nil {
+		return "", nil, err
 	}
 
 	pullStatus, ok := e.Value.(*docker.PullStatus)
@@ -60,9 +92,9 @@ func ParsePullContainerdImage(e partybus.Event) (string, *containerd.PullStatus,
 		return "", nil, err
 	}
 
-	imgName, ok := e.Source.(string)
-	if !ok {
-		return "", nil, newPayloadErr(e.Type, "Source", e.Source)
+	imgName, err := imageName(e)
+	if err != nil {
+		return "", nil, err
 	}
 
 	pullStatus, ok := e.Value.(*containerd.PullStatus)
@@ -78,9 +110,9 @@ func ParseFetchImage(e partybus.Event) (string, progress.StagedProgressable, err
 		return "", nil, err
 	}
 
-	imgName, ok := e.Source.(string)
-	if !ok {
-		return "", nil, newPayloadErr(e.Type, "Source", e.Source)
+	imgName, err := imageName(e)
+	if err != nil {
+		return "", nil, err
 	}
 
 	prog, ok := e.Value.(progress.StagedProgressable)
@@ -109,6 +141,44 @@ func ParseReadImage(e partybus.Event) (*image.Metadata, progress.Progressable, e
 	return &imgMetadata, prog, nil
 }
 
+// ParseReadImageIndex returns the index and the combined progress of reading all images within it (see
+// image.IndexProgress).
+func ParseReadImageIndex(e partybus.Event) (*image.Index, progress.StagedProgressable, error) {
+	if err := checkEventType(e.Type, event.ReadImageIndex); err != nil {
+		return nil, nil, err
+	}
+
+	index, ok := e.Source.(*image.Index)
+	if !ok {
+		return nil, nil, newPayloadErr(e.Type, "Source", e.Source)
+	}
+
+	prog, ok := e.Value.(progress.StagedProgressable)
+	if !ok {
+		return nil, nil, newPayloadErr(e.Type, "Value", e.Value)
+	}
+
+	return index, prog, nil
+}
+
+func ParseExportImage(e partybus.Event) (*image.Metadata, progress.Progressable, error) {
+	if err := checkEventType(e.Type, event.ExportImage); err != nil {
+		return nil, nil, err
+	}
+
+	imgMetadata, ok := e.Source.(image.Metadata)
+	if !ok {
+		return nil, nil, newPayloadErr(e.Type, "Source", e.Source)
+	}
+
+	prog, ok := e.Value.(progress.Progressable)
+	if !ok {
+		return nil, nil, newPayloadErr(e.Type, "Value", e.Value)
+	}
+
+	return &imgMetadata, prog, nil
+}
+
 func ParseReadLayer(e partybus.Event) (*image.LayerMetadata, progress.Monitorable, error) {
 	if err := checkEventType(e.Type, event.ReadLayer); err != nil {
 		return nil, nil, err
@@ -0,0 +1,98 @@
+package parsers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wagoodman/go-partybus"
+	"github.com/wagoodman/go-progress"
+
+	"github.com/anchore/stereoscope/pkg/event"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func TestParseImageSource(t *testing.T) {
+	source := event.ImageSource{
+		Image:     "docker.io/library/alpine:latest",
+		Provider:  "containerd",
+		Digest:    "sha256:abc",
+		Pulled:    true,
+		Namespace: "k8s.io",
+	}
+	prog := progress.StagedProgressable(&struct {
+		progress.Stager
+		progress.Progressable
+	}{
+		Stager:       &progress.Stage{},
+		Progressable: progress.NewManual(1),
+	})
+
+	tests := []struct {
+		name    string
+		event   partybus.Event
+		want    *event.ImageSource
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:  "structured source",
+			event: partybus.Event{Type: event.FetchImage, Source: source, Value: prog},
+			want:  &source,
+		},
+		{
+			name:  "image reference source",
+			event: partybus.Event{Type: event.FetchImage, Source: "alpine:latest", Value: prog},
+			want:  &event.ImageSource{Image: "alpine:latest"},
+		},
+		{
+			name:    "unsupported event",
+			event:   partybus.Event{Type: event.ReadImage, Source: source},
+			wantErr: require.Error,
+		},
+		{
+			name:    "bad source",
+			event:   partybus.Event{Type: event.PullContainerdImage, Source: 42},
+			wantErr: require.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+			got, err := ParseImageSource(tt.event)
+			tt.wantErr(t, err)
+			assert.Equal(t, tt.want, got)
+			if err != nil {
+				return
+			}
+
+			// the image reference is still available from the event specific parser
+			imgName, _, err := ParseFetchImage(tt.event)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.Image, imgName)
+		})
+	}
+}
+
+func TestParseReadImageIndex(t *testing.T) {
+	index := &image.Index{Digest: "sha256:abc"}
+	prog := progress.StagedProgressable(&struct {
+		progress.Stager
+		progress.Progressable
+	}{
+		Stager:       &progress.Stage{Current: "linux/amd64 (1 of 2)"},
+		Progressable: progress.NewManual(2),
+	})
+
+	gotIndex, gotProg, err := ParseReadImageIndex(partybus.Event{Type: event.ReadImageIndex, Source: index, Value: prog})
+	require.NoError(t, err)
+	assert.Same(t, index, gotIndex)
+	assert.Equal(t, "linux/amd64 (1 of 2)", gotProg.Stage())
+
+	_, _, err = ParseReadImageIndex(partybus.Event{Type: event.ReadImageIndex, Source: "alpine:latest", Value: prog})
+	require.Error(t, err)
+
+	_, _, err = ParseReadImageIndex(partybus.Event{Type: event.ReadImage, Source: index, Value: prog})
+	require.Error(t, err)
+}
@@ -0,0 +1,245 @@
+package file
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PAX records carrying POSIX ACLs in the text form written by star and GNU tar (--acls), and the names of the extended
+// attributes holding POSIX ACLs in binary form (as captured by tools recording all xattrs, e.g. docker and buildkit).
+const (
+	paxACLAccess    = "SCHILY.acl.access"
+	paxACLDefault   = "SCHILY.acl.default"
+	xattrACLAccess  = "system.posix_acl_access"
+	xattrACLDefault = "system.posix_acl_default"
+)
+
+// ACLTag identifies the kind of a POSIX ACL entry.
+type ACLTag string
+
+const (
+	// ACLUserObj is the entry of the owning user (equivalent to the user mode bits)
+	ACLUserObj ACLTag = "user_obj"
+	// ACLUser is the entry of a named user
+	ACLUser ACLTag = "user"
+	// ACLGroupObj is the entry of the owning group (equivalent to the group mode bits when there is no mask)
+	ACLGroupObj ACLTag = "group_obj"
+	// ACLGroup is the entry of a named group
+	ACLGroup ACLTag = "group"
+	// ACLMask is the upper bound of the permissions granted to named users, the owning group, and named groups
+	ACLMask ACLTag = "mask"
+	// ACLOther is the entry of all other users (equivalent to the other mode bits)
+	ACLOther ACLTag = "other"
+)
+
+// ACLPerms are the permissions granted by a POSIX ACL entry.
+type ACLPerms uint8
+
+const (
+	ACLExecute ACLPerms = 1 << iota
+	ACLWrite
+	ACLRead
+)
+
+// String returns the permissions in the form shown by getfacl (e.g. "r-x").
+func (p ACLPerms) String() string {
+	out := []byte("---")
+	if p&ACLRead != 0 {
+		out[0] = 'r'
+	}
+	if p&ACLWrite != 0 {
+		out[1] = 'w'
+	}
+	if p&ACLExecute != 0 {
+		out[2] = 'x'
+	}
+	return string(out)
+}
+
+// ACLEntry is a single entry of a POSIX ACL.
+type ACLEntry struct {
+	Tag ACLTag
+	// ID is the user or group ID of named user and group entries (-1 for all other entries, or when only the name of
+	// the user or group is recorded)
+	ID int
+	// Name is the user or group name of named user and group entries (when recorded)
+	Name  string
+	Perms ACLPerms
+}
+
+// ACL is the POSIX access control list of a file, as recorded within the layer tar.
+type ACL struct {
+	// Access are the entries controlling access to the file
+	Access []ACLEntry
+	// Default are the entries inherited by files created within a directory (directories only)
+	Default []ACLEntry
+}
+
+// Extended indicates the ACL grants permissions beyond what the mode bits of the file describe (any named user or group
+// entries, or inherited default entries).
+func (a ACL) Extended() bool {
+	if len(a.Default) > 0 {
+		return true
+	}
+	for _, e := range a.Access {
+		switch e.Tag {
+		case ACLUser, ACLGroup, ACLMask:
+			return true
+		}
+	}
+	return false
+}
+
+// aclFromPAXRecords returns the POSIX ACL recorded within the given PAX records (nil when there is none).
+func aclFromPAXRecords(records map[string]string) (*ACL, error) {
+	var acl ACL
+	var err error
+	if acl.Access, err = aclEntriesFromPAXRecords(records, paxACLAccess, xattrACLAccess); err != nil {
+		return nil, fmt.Errorf("invalid access ACL: %w", err)
+	}
+	if acl.Default, err = aclEntriesFromPAXRecords(records, paxACLDefault, xattrACLDefault); err != nil {
+		return nil, fmt.Errorf("invalid default ACL: %w", err)
+	}
+	if acl.Access == nil && acl.Default == nil {
+		return nil, nil
+	}
+	return &acl, nil
+}
+
+func aclEntriesFromPAXRecords(records map[string]string, textKey, xattrName string) ([]ACLEntry, error) {
+	if value, ok := records[textKey]; ok {
+		return parseACLText(value)
+	}
+	value, ok, err := xattrFromPAXRecords(records, xattrName)
+	if !ok || err != nil {
+		return nil, err
+	}
+	return parseACLXattr(value)
+}
+
+// parseACLText parses the text form of an ACL as written by star and GNU tar, where entries are separated by commas
+// (or newlines) in the form "tag:qualifier:perms", optionally followed by the numeric ID of the qualifier (e.g.
+// "user::rwx,user:app:r-x:1000,group::r--,mask::r-x,other::---").
+func parseACLText(value string) ([]ACLEntry, error) {
+	entries := []ACLEntry{}
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' }) {
+		field = strings.TrimSpace(field)
+		if field == "" || strings.HasPrefix(field, "#") {
+			continue
+		}
+
+		parts := strings.Split(field, ":")
+		if len(parts) < 3 || len(parts) > 4 {
+			return nil, fmt.Errorf("invalid ACL entry %q", field)
+		}
+
+		perms, err := parseACLPerms(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid ACL entry %q: %w", field, err)
+		}
+		entry := ACLEntry{ID: -1, Perms: perms}
+
+		qualifier := parts[1]
+		named := qualifier != ""
+		switch parts[0] {
+		case "user", "u":
+			entry.Tag = ACLUserObj
+			if named {
+				entry.Tag = ACLUser
+			}
+		case "group", "g":
+			entry.Tag = ACLGroupObj
+			if named {
+				entry.Tag = ACLGroup
+			}
+		case "mask", "m":
+			entry.Tag = ACLMask
+		case "other", "o":
+			entry.Tag = ACLOther
+		default:
+			return nil, fmt.Errorf("invalid ACL entry tag %q", parts[0])
+		}
+
+		if named {
+			if id, err := strconv.Atoi(qualifier); err == nil {
+				entry.ID = id
+			} else {
+				entry.Name = qualifier
+			}
+		}
+		if len(parts) == 4 {
+			id, err := strconv.Atoi(parts[3])
+			if err != nil {
+				return nil, fmt.Errorf("invalid ACL entry %q: %w", field, err)
+			}
+			entry.ID = id
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func parseACLPerms(value string) (ACLPerms, error) {
+	var perms ACLPerms
+	for _, c := range value {
+		switch c {
+		case 'r':
+			perms |= ACLRead
+		case 'w':
+			perms |= ACLWrite
+		case 'x':
+			perms |= ACLExecute
+		case '-':
+		default:
+			return 0, fmt.Errorf("invalid permission %q", c)
+		}
+	}
+	return perms, nil
+}
+
+// binary POSIX ACL extended attribute format (see linux/posix_acl_xattr.h)
+const (
+	aclXattrVersion   = 2
+	aclXattrEntrySize = 8
+	aclUndefinedID    = 0xFFFFFFFF
+)
+
+var aclXattrTags = map[uint16]ACLTag{
+	0x01: ACLUserObj,
+	0x02: ACLUser,
+	0x04: ACLGroupObj,
+	0x08: ACLGroup,
+	0x10: ACLMask,
+	0x20: ACLOther,
+}
+
+// parseACLXattr parses the value of a "system.posix_acl_access" or "system.posix_acl_default" extended attribute: a
+// little-endian version header followed by (tag, perms, id) entries.
+func parseACLXattr(value []byte) ([]ACLEntry, error) {
+	if len(value) < 4 || (len(value)-4)%aclXattrEntrySize != 0 {
+		return nil, fmt.Errorf("invalid ACL xattr length %d", len(value))
+	}
+	if version := binary.LittleEndian.Uint32(value[:4]); version != aclXattrVersion {
+		return nil, fmt.Errorf("unsupported ACL xattr version %d", version)
+	}
+
+	entries := []ACLEntry{}
+	for b := value[4:]; len(b) > 0; b = b[aclXattrEntrySize:] {
+		tag, ok := aclXattrTags[binary.LittleEndian.Uint16(b[0:2])]
+		if !ok {
+			return nil, fmt.Errorf("invalid ACL xattr entry tag %#x", binary.LittleEndian.Uint16(b[0:2]))
+		}
+		entry := ACLEntry{
+			Tag:   tag,
+			ID:    -1,
+			Perms: ACLPerms(binary.LittleEndian.Uint16(b[2:4]) & 0x7),
+		}
+		if id := binary.LittleEndian.Uint32(b[4:8]); (tag == ACLUser || tag == ACLGroup) && id != aclUndefinedID {
+			entry.ID = int(id)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
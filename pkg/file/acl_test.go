@@ -0,0 +1,136 @@
+package file
+
+import (
+	"archive/tar"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMetadata_ACL(t *testing.T) {
+	extended := []ACLEntry{
+		{Tag: ACLUserObj, ID: -1, Perms: ACLRead | ACLWrite},
+		{Tag: ACLUser, ID: 1000, Perms: ACLRead | ACLWrite | ACLExecute},
+		{Tag: ACLGroupObj, ID: -1, Perms: ACLRead},
+		{Tag: ACLMask, ID: -1, Perms: ACLRead | ACLWrite | ACLExecute},
+		{Tag: ACLOther, ID: -1, Perms: ACLRead},
+	}
+	xattr := aclXattr(t, extended)
+
+	tests := []struct {
+		name    string
+		records map[string]string
+		want    *ACL
+	}{
+		{
+			name: "no ACL",
+		},
+		{
+			name:    "star text form",
+			records: map[string]string{paxACLAccess: "user::rw-,user:1000:rwx,group::r--,mask::rwx,other::r--"},
+			want:    &ACL{Access: extended},
+		},
+		{
+			name: "star text form with names and IDs",
+			records: map[string]string{
+				paxACLAccess:  "user::rwx,user:app:r-x:1000,group::r-x,group:wheel:rwx:10,mask::rwx,other::---",
+				paxACLDefault: "u::rwx,g::r-x,o::r-x",
+			},
+			want: &ACL{
+				Access: []ACLEntry{
+					{Tag: ACLUserObj, ID: -1, Perms: ACLRead | ACLWrite | ACLExecute},
+					{Tag: ACLUser, ID: 1000, Name: "app", Perms: ACLRead | ACLExecute},
+					{Tag: ACLGroupObj, ID: -1, Perms: ACLRead | ACLExecute},
+					{Tag: ACLGroup, ID: 10, Name: "wheel", Perms: ACLRead | ACLWrite | ACLExecute},
+					{Tag: ACLMask, ID: -1, Perms: ACLRead | ACLWrite | ACLExecute},
+					{Tag: ACLOther, ID: -1},
+				},
+				Default: []ACLEntry{
+					{Tag: ACLUserObj, ID: -1, Perms: ACLRead | ACLWrite | ACLExecute},
+					{Tag: ACLGroupObj, ID: -1, Perms: ACLRead | ACLExecute},
+					{Tag: ACLOther, ID: -1, Perms: ACLRead | ACLExecute},
+				},
+			},
+		},
+		{
+			name:    "xattr",
+			records: map[string]string{"SCHILY.xattr." + xattrACLAccess: string(xattr)},
+			want:    &ACL{Access: extended},
+		},
+		{
+			name:    "libarchive xattr",
+			records: map[string]string{"LIBARCHIVE.xattr." + xattrACLDefault: base64.RawStdEncoding.EncodeToString(xattr)},
+			want:    &ACL{Default: extended},
+		},
+		{
+			name:    "invalid ACL is ignored",
+			records: map[string]string{paxACLAccess: "user::rwz"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tar.Header{Name: "etc/shadow", Typeflag: tar.TypeReg, Mode: 0640, PAXRecords: tt.records}
+			assert.Equal(t, tt.want, NewMetadata(header, strings.NewReader("")).ACL)
+		})
+	}
+}
+
+func Test_parseACLXattr_invalid(t *testing.T) {
+	valid := aclXattr(t, []ACLEntry{{Tag: ACLOther, ID: -1, Perms: ACLRead}})
+
+	_, err := parseACLXattr(valid[:len(valid)-1])
+	require.Error(t, err)
+
+	unsupported := append([]byte{}, valid...)
+	unsupported[0] = 1
+	_, err = parseACLXattr(unsupported)
+	require.Error(t, err)
+
+	unknownTag := append([]byte{}, valid...)
+	unknownTag[4] = 0x40
+	_, err = parseACLXattr(unknownTag)
+	require.Error(t, err)
+}
+
+func TestACL_Extended(t *testing.T) {
+	minimal := []ACLEntry{
+		{Tag: ACLUserObj, ID: -1, Perms: ACLRead | ACLWrite},
+		{Tag: ACLGroupObj, ID: -1, Perms: ACLRead},
+		{Tag: ACLOther, ID: -1, Perms: ACLRead},
+	}
+	assert.False(t, ACL{Access: minimal}.Extended())
+	assert.True(t, ACL{Access: append(minimal, ACLEntry{Tag: ACLGroup, ID: 10, Perms: ACLWrite})}.Extended())
+	assert.True(t, ACL{Access: minimal, Default: minimal}.Extended())
+}
+
+func TestACLPerms_String(t *testing.T) {
+	assert.Equal(t, "---", ACLPerms(0).String())
+	assert.Equal(t, "r-x", (ACLRead | ACLExecute).String())
+	assert.Equal(t, "rwx", (ACLRead | ACLWrite | ACLExecute).String())
+}
+
+// aclXattr encodes the given entries as the value of a POSIX ACL extended attribute.
+func aclXattr(t *testing.T, entries []ACLEntry) []byte {
+	t.Helper()
+
+	tags := map[ACLTag]uint16{}
+	for k, v := range aclXattrTags {
+		tags[v] = k
+	}
+
+	out := binary.LittleEndian.AppendUint32(nil, aclXattrVersion)
+	for _, e := range entries {
+		id := uint32(aclUndefinedID)
+		if e.ID >= 0 {
+			id = uint32(e.ID)
+		}
+		out = binary.LittleEndian.AppendUint16(out, tags[e.Tag])
+		out = binary.LittleEndian.AppendUint16(out, uint16(e.Perms))
+		out = binary.LittleEndian.AppendUint32(out, id)
+	}
+	return out
+}
@@ -0,0 +1,153 @@
+package file
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// xattrCapability is the extended attribute holding the file capabilities of an executable.
+const xattrCapability = "security.capability"
+
+// file capability extended attribute format (see linux/capability.h)
+const (
+	vfsCapRevisionMask   = 0xFF000000
+	vfsCapFlagsEffective = 0x000001
+	vfsCapRevision1      = 0x01000000
+	vfsCapRevision2      = 0x02000000
+	vfsCapRevision3      = 0x03000000
+)
+
+// capabilityNames are the names of all known capabilities, indexed by capability number.
+var capabilityNames = []string{
+	"cap_chown",
+	"cap_dac_override",
+	"cap_dac_read_search",
+	"cap_fowner",
+	"cap_fsetid",
+	"cap_kill",
+	"cap_setgid",
+	"cap_setuid",
+	"cap_setpcap",
+	"cap_linux_immutable",
+	"cap_net_bind_service",
+	"cap_net_broadcast",
+	"cap_net_admin",
+	"cap_net_raw",
+	"cap_ipc_lock",
+	"cap_ipc_owner",
+	"cap_sys_module",
+	"cap_sys_rawio",
+	"cap_sys_chroot",
+	"cap_sys_ptrace",
+	"cap_sys_pacct",
+	"cap_sys_admin",
+	"cap_sys_boot",
+	"cap_sys_nice",
+	"cap_sys_resource",
+	"cap_sys_time",
+	"cap_sys_tty_config",
+	"cap_mknod",
+	"cap_lease",
+	"cap_audit_write",
+	"cap_audit_control",
+	"cap_setfcap",
+	"cap_mac_override",
+	"cap_mac_admin",
+	"cap_syslog",
+	"cap_wake_alarm",
+	"cap_block_suspend",
+	"cap_audit_read",
+	"cap_perfmon",
+	"cap_bpf",
+	"cap_checkpoint_restore",
+}
+
+// Capabilities are the file capabilities of an executable (as set with setcap), granted to processes executing the
+// file.
+type Capabilities struct {
+	// Permitted is the set of capabilities permitted to the process (bit N is capability N)
+	Permitted uint64
+	// Inheritable is the set of capabilities inherited from the executing process (bit N is capability N)
+	Inheritable uint64
+	// Effective indicates the permitted capabilities are raised in the effective set of the process
+	Effective bool
+	// RootID is the user ID of the root user of the user namespace the capabilities apply to (namespaced capabilities
+	// only, -1 otherwise)
+	RootID int
+}
+
+// PermittedNames returns the names of the permitted capabilities (e.g. "cap_net_bind_service").
+func (c Capabilities) PermittedNames() []string {
+	return capabilitySetNames(c.Permitted)
+}
+
+// InheritableNames returns the names of the inheritable capabilities (e.g. "cap_net_bind_service").
+func (c Capabilities) InheritableNames() []string {
+	return capabilitySetNames(c.Inheritable)
+}
+
+func capabilitySetNames(set uint64) []string {
+	var names []string
+	for bit := 0; bit < 64; bit++ {
+		if set&(1<<bit) == 0 {
+			continue
+		}
+		if bit < len(capabilityNames) {
+			names = append(names, capabilityNames[bit])
+		} else {
+			// capabilities newer than known here are named the same way as by libcap
+			names = append(names, fmt.Sprintf("cap_%d", bit))
+		}
+	}
+	return names
+}
+
+// capabilitiesFromPAXRecords returns the file capabilities recorded within the given PAX records (nil when there are
+// none).
+func capabilitiesFromPAXRecords(records map[string]string) (*Capabilities, error) {
+	value, ok, err := xattrFromPAXRecords(records, xattrCapability)
+	if !ok || err != nil {
+		return nil, err
+	}
+	return parseCapabilityXattr(value)
+}
+
+// parseCapabilityXattr parses the value of a "security.capability" extended attribute: a little-endian revision and
+// flags field, followed by the low (and for revision 2 and 3, high) 32 bits of the permitted and inheritable sets, and
+// for revision 3 the root user ID of the user namespace.
+func parseCapabilityXattr(value []byte) (*Capabilities, error) {
+	if len(value) < 4 {
+		return nil, fmt.Errorf("invalid capability xattr length %d", len(value))
+	}
+	magic := binary.LittleEndian.Uint32(value[:4])
+
+	var size int
+	switch revision := magic & vfsCapRevisionMask; revision {
+	case vfsCapRevision1:
+		size = 12
+	case vfsCapRevision2:
+		size = 20
+	case vfsCapRevision3:
+		size = 24
+	default:
+		return nil, fmt.Errorf("unsupported capability xattr revision %#x", revision)
+	}
+	if len(value) != size {
+		return nil, fmt.Errorf("invalid capability xattr length %d (expected %d)", len(value), size)
+	}
+
+	caps := Capabilities{
+		Permitted:   uint64(binary.LittleEndian.Uint32(value[4:8])),
+		Inheritable: uint64(binary.LittleEndian.Uint32(value[8:12])),
+		Effective:   magic&vfsCapFlagsEffective != 0,
+		RootID:      -1,
+	}
+	if size >= 20 {
+		caps.Permitted |= uint64(binary.LittleEndian.Uint32(value[12:16])) << 32
+		caps.Inheritable |= uint64(binary.LittleEndian.Uint32(value[16:20])) << 32
+	}
+	if size == 24 {
+		caps.RootID = int(binary.LittleEndian.Uint32(value[20:24]))
+	}
+	return &caps, nil
+}
@@ -0,0 +1,77 @@
+package file
+
+import (
+	"archive/tar"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMetadata_Capabilities(t *testing.T) {
+	netBindService := uint64(1) << 10
+	netRaw := uint64(1) << 13
+
+	tests := []struct {
+		name    string
+		records map[string]string
+		want    *Capabilities
+	}{
+		{
+			name: "no capabilities",
+		},
+		{
+			name:    "revision 2",
+			records: map[string]string{"SCHILY.xattr.security.capability": string(capabilityXattr(vfsCapRevision2|vfsCapFlagsEffective, netBindService|netRaw, 0))},
+			want:    &Capabilities{Permitted: netBindService | netRaw, Effective: true, RootID: -1},
+		},
+		{
+			name:    "revision 3 (namespaced)",
+			records: map[string]string{"SCHILY.xattr.security.capability": string(capabilityXattr(vfsCapRevision3, netBindService, 1<<40, 100000))},
+			want:    &Capabilities{Permitted: netBindService, Inheritable: 1 << 40, RootID: 100000},
+		},
+		{
+			name:    "revision 1",
+			records: map[string]string{"SCHILY.xattr.security.capability": string(capabilityXattr(vfsCapRevision1, netRaw, 0)[:12])},
+			want:    &Capabilities{Permitted: netRaw, RootID: -1},
+		},
+		{
+			name:    "invalid capabilities are ignored",
+			records: map[string]string{"SCHILY.xattr.security.capability": string(capabilityXattr(vfsCapRevision2, netRaw, 0)[:16])},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tar.Header{Name: "usr/bin/ping", Typeflag: tar.TypeReg, Mode: 0755, PAXRecords: tt.records}
+			assert.Equal(t, tt.want, NewMetadata(header, strings.NewReader("")).Capabilities)
+		})
+	}
+}
+
+func Test_parseCapabilityXattr_unsupportedRevision(t *testing.T) {
+	_, err := parseCapabilityXattr(capabilityXattr(0x04000000, 1, 0))
+	require.ErrorContains(t, err, "unsupported capability xattr revision")
+}
+
+func TestCapabilities_Names(t *testing.T) {
+	caps := Capabilities{Permitted: 1<<0 | 1<<10 | 1<<50, Inheritable: 1 << 40}
+	assert.Equal(t, []string{"cap_chown", "cap_net_bind_service", "cap_50"}, caps.PermittedNames())
+	assert.Equal(t, []string{"cap_checkpoint_restore"}, caps.InheritableNames())
+	assert.Empty(t, Capabilities{}.PermittedNames())
+}
+
+// capabilityXattr encodes a "security.capability" xattr value of revision 2 layout (with the root ID appended when
+// given), where revision 1 values are the first 12 bytes.
+func capabilityXattr(magic uint32, permitted, inheritable uint64, rootID ...uint32) []byte {
+	out := binary.LittleEndian.AppendUint32(nil, magic)
+	out = binary.LittleEndian.AppendUint32(out, uint32(permitted))
+	out = binary.LittleEndian.AppendUint32(out, uint32(inheritable))
+	out = binary.LittleEndian.AppendUint32(out, uint32(permitted>>32))
+	out = binary.LittleEndian.AppendUint32(out, uint32(inheritable>>32))
+	for _, id := range rootID {
+		out = binary.LittleEndian.AppendUint32(out, id)
+	}
+	return out
+}
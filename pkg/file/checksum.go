@@ -0,0 +1,110 @@
+package file
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const sha256Prefix = "sha256:"
+
+// ErrDigestMismatch is returned when the contents of a file do not match the expected digest.
+type ErrDigestMismatch struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *ErrDigestMismatch) Error() string {
+	return fmt.Sprintf("digest mismatch for %q: expected %s, got %s", e.Path, e.Expected, e.Actual)
+}
+
+// NormalizeDigest returns the given sha256 digest in "sha256:<hex>" form. Both the bare hex form (as used in sha256sum
+// files) and the prefixed form are accepted.
+func NormalizeDigest(digest string) (string, error) {
+	hexDigest := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(digest), sha256Prefix))
+	if len(hexDigest) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid sha256 digest: %q", digest)
+	}
+	if _, err := hex.DecodeString(hexDigest); err != nil {
+		return "", fmt.Errorf("invalid sha256 digest: %q", digest)
+	}
+	return sha256Prefix + hexDigest, nil
+}
+
+// DigestFromChecksumFile returns the sha256 digest for the given target path from a checksum file in sha256sum format
+// ("<hex>  <name>" or "<hex> *<name>" per line). Entries are matched by the target's base name. A checksum file with a
+// single entry without a name applies to any target.
+func DigestFromChecksumFile(checksumPath, targetPath string) (string, error) {
+	fh, err := os.Open(checksumPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to open checksum file: %w", err)
+	}
+	defer fh.Close()
+
+	target := filepath.Base(targetPath)
+
+	var entries int
+	var unnamed string
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries++
+
+		fields := strings.SplitN(line, " ", 2)
+		digest := fields[0]
+		if len(fields) == 1 {
+			unnamed = digest
+			continue
+		}
+
+		// the "*" prefix indicates binary mode
+		name := strings.TrimPrefix(strings.TrimSpace(fields[1]), "*")
+		if name == target || filepath.Base(name) == target {
+			return NormalizeDigest(digest)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("unable to read checksum file: %w", err)
+	}
+
+	if entries == 1 && unnamed != "" {
+		return NormalizeDigest(unnamed)
+	}
+
+	return "", fmt.Errorf("no checksum found for %q in %q", target, checksumPath)
+}
+
+// VerifyDigest checks that the sha256 digest of the file at the given path matches the expected digest, returning an
+// ErrDigestMismatch when it does not.
+func VerifyDigest(path, expected string) error {
+	expected, err := NormalizeDigest(expected)
+	if err != nil {
+		return err
+	}
+
+	fh, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open file to verify: %w", err)
+	}
+	defer fh.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, fh); err != nil {
+		return fmt.Errorf("unable to read file to verify: %w", err)
+	}
+
+	actual := sha256Prefix + hex.EncodeToString(hasher.Sum(nil))
+	if actual != expected {
+		return &ErrDigestMismatch{Path: path, Expected: expected, Actual: actual}
+	}
+	return nil
+}
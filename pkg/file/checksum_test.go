@@ -0,0 +1,101 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sha256 of "hello\n"
+const helloDigest = "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+
+func TestVerifyDigest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "image.tar")
+	require.NoError(t, os.WriteFile(path, []byte("hello\n"), 0o644))
+
+	tests := []struct {
+		name     string
+		expected string
+		wantErr  require.ErrorAssertionFunc
+	}{
+		{
+			name:     "bare hex",
+			expected: helloDigest,
+			wantErr:  require.NoError,
+		},
+		{
+			name:     "prefixed and upper case",
+			expected: "sha256:" + "5891B5B522D5DF086D0FF0B110FBD9D21BB4FC7163AF34D08286A2E846F6BE03",
+			wantErr:  require.NoError,
+		},
+		{
+			name:     "mismatch",
+			expected: "sha256:" + "0000000000000000000000000000000000000000000000000000000000000000",
+			wantErr: func(t require.TestingT, err error, _ ...interface{}) {
+				var mismatch *ErrDigestMismatch
+				require.ErrorAs(t, err, &mismatch)
+			},
+		},
+		{
+			name:     "invalid digest",
+			expected: "sha256:abc",
+			wantErr:  require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.wantErr(t, VerifyDigest(path, test.expected))
+		})
+	}
+}
+
+func TestDigestFromChecksumFile(t *testing.T) {
+	other := "0000000000000000000000000000000000000000000000000000000000000000"
+
+	tests := []struct {
+		name     string
+		contents string
+		want     string
+		wantErr  require.ErrorAssertionFunc
+	}{
+		{
+			name:     "text mode entry",
+			contents: other + "  other.tar\n" + helloDigest + "  image.tar\n",
+			want:     "sha256:" + helloDigest,
+		},
+		{
+			name:     "binary mode entry with directory",
+			contents: helloDigest + " *dist/image.tar\n",
+			want:     "sha256:" + helloDigest,
+		},
+		{
+			name:     "single unnamed entry",
+			contents: helloDigest + "\n",
+			want:     "sha256:" + helloDigest,
+		},
+		{
+			name:     "no matching entry",
+			contents: other + "  other.tar\n",
+			wantErr:  require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.wantErr == nil {
+				test.wantErr = require.NoError
+			}
+
+			checksumPath := filepath.Join(t.TempDir(), "SHA256SUMS")
+			require.NoError(t, os.WriteFile(checksumPath, []byte(test.contents), 0o644))
+
+			got, err := DigestFromChecksumFile(checksumPath, "/some/path/image.tar")
+			test.wantErr(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Compression is a compression format detected from the leading (magic) bytes of a stream.
+type Compression string
+
+const (
+	Uncompressed Compression = ""
+	Gzip         Compression = "gzip"
+	Bzip2        Compression = "bzip2"
+	Xz           Compression = "xz"
+	Zstd         Compression = "zstd"
+)
+
+var compressionMagic = []struct {
+	compression Compression
+	magic       []byte
+}{
+	{compression: Gzip, magic: []byte{0x1f, 0x8b}},
+	{compression: Bzip2, magic: []byte{'B', 'Z', 'h'}},
+	{compression: Xz, magic: []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{compression: Zstd, magic: []byte{0x28, 0xb5, 0x2f, 0xfd}},
+}
+
+// DetectCompression returns the compression format of the given stream without consuming it (the returned reader
+// must be used in place of the given reader).
+func DetectCompression(reader io.Reader) (Compression, io.Reader, error) {
+	buffered := bufio.NewReader(reader)
+	header, err := buffered.Peek(6)
+	if err != nil && err != io.EOF {
+		return Uncompressed, buffered, err
+	}
+
+	for _, m := range compressionMagic {
+		if bytes.HasPrefix(header, m.magic) {
+			return m.compression, buffered, nil
+		}
+	}
+	return Uncompressed, buffered, nil
+}
+
+// NewDecompressingReader returns a reader of the uncompressed contents of the given stream, detecting the compression
+// format (gzip, bzip2, xz, or zstd) automatically. Uncompressed streams are returned as-is.
+func NewDecompressingReader(reader io.Reader) (io.ReadCloser, error) {
+	compression, reader, err := DetectCompression(reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to detect compression: %w", err)
+	}
+
+	switch compression {
+	case Gzip:
+		return gzip.NewReader(reader)
+	case Bzip2:
+		return io.NopCloser(bzip2.NewReader(reader)), nil
+	case Xz:
+		r, err := xz.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(r), nil
+	case Zstd:
+		r, err := zstd.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return r.IOReadCloser(), nil
+	default:
+		return io.NopCloser(reader), nil
+	}
+}
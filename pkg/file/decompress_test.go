@@ -0,0 +1,78 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+func TestNewDecompressingReader(t *testing.T) {
+	const contents = "some contents to compress"
+
+	tests := []struct {
+		name     string
+		compress func(io.Writer) io.WriteCloser
+		want     Compression
+	}{
+		{
+			name: "uncompressed",
+			want: Uncompressed,
+		},
+		{
+			name: "gzip",
+			compress: func(w io.Writer) io.WriteCloser {
+				return gzip.NewWriter(w)
+			},
+			want: Gzip,
+		},
+		{
+			name: "xz",
+			compress: func(w io.Writer) io.WriteCloser {
+				zw, err := xz.NewWriter(w)
+				require.NoError(t, err)
+				return zw
+			},
+			want: Xz,
+		},
+		{
+			name: "zstd",
+			compress: func(w io.Writer) io.WriteCloser {
+				zw, err := zstd.NewWriter(w)
+				require.NoError(t, err)
+				return zw
+			},
+			want: Zstd,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if tt.compress != nil {
+				w := tt.compress(buf)
+				_, err := w.Write([]byte(contents))
+				require.NoError(t, err)
+				require.NoError(t, w.Close())
+			} else {
+				buf.WriteString(contents)
+			}
+
+			compression, _, err := DetectCompression(bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, compression)
+
+			reader, err := NewDecompressingReader(bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			got, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, contents, string(got))
+		})
+	}
+}
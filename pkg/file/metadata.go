@@ -28,6 +28,25 @@ type Metadata struct {
 	GroupID         int
 	Type            Type
 	MIMEType        string
+	// Timestamps are the modification, access, and change times of the file (as far as known from the source)
+	Timestamps Timestamps
+	// ACL is the POSIX access control list of the file (nil when none is recorded, e.g. within tar entries without ACL
+	// PAX records, or from sources other than tar)
+	ACL *ACL
+	// SELinuxContext is the SELinux security context of the file, as recorded within the security.selinux xattr (empty
+	// when none is recorded)
+	SELinuxContext string
+	// Capabilities are the file capabilities of the file, as recorded within the security.capability xattr (nil when
+	// none are recorded)
+	Capabilities *Capabilities
+}
+
+// Timestamps are the times recorded for a file, where times that are not recorded (e.g. access and change times of
+// tar entries without PAX or GNU headers) are the zero time.
+type Timestamps struct {
+	Modified time.Time
+	Accessed time.Time
+	Changed  time.Time
 }
 
 type ManualInfo struct {
@@ -63,6 +82,19 @@ func (m ManualInfo) Sys() any {
 }
 
 func NewMetadata(header tar.Header, content io.Reader) Metadata {
+	acl, err := aclFromPAXRecords(header.PAXRecords)
+	if err != nil {
+		log.Warnf("unable to parse ACL of %q: %+v", header.Name, err)
+	}
+	selinuxContext, err := selinuxContextFromPAXRecords(header.PAXRecords)
+	if err != nil {
+		log.Warnf("unable to parse SELinux context of %q: %+v", header.Name, err)
+	}
+	capabilities, err := capabilitiesFromPAXRecords(header.PAXRecords)
+	if err != nil {
+		log.Warnf("unable to parse file capabilities of %q: %+v", header.Name, err)
+	}
+
 	return Metadata{
 		FileInfo:        header.FileInfo(),
 		Path:            path.Clean(DirSeparator + header.Name),
@@ -71,6 +103,14 @@ func NewMetadata(header tar.Header, content io.Reader) Metadata {
 		UserID:          header.Uid,
 		GroupID:         header.Gid,
 		MIMEType:        MIMEType(content),
+		Timestamps: Timestamps{
+			Modified: header.ModTime,
+			Accessed: header.AccessTime,
+			Changed:  header.ChangeTime,
+		},
+		ACL:            acl,
+		SELinuxContext: selinuxContext,
+		Capabilities:   capabilities,
 	}
 }
 
@@ -112,6 +152,7 @@ func NewMetadataFromSquashFSFile(path string, f *squashfs.File) (Metadata, error
 		UserID:          -1,
 		GroupID:         -1,
 		Type:            ty,
+		Timestamps:      Timestamps{Modified: fi.ModTime()},
 	}
 
 	if f.IsRegular() {
@@ -148,9 +189,10 @@ func NewMetadataFromPath(path string, info os.FileInfo) Metadata {
 		Path:     path,
 		Type:     ty,
 		// unsupported across platforms
-		UserID:   uid,
-		GroupID:  gid,
-		MIMEType: mimeType,
+		UserID:     uid,
+		GroupID:    gid,
+		MIMEType:   mimeType,
+		Timestamps: Timestamps{Modified: info.ModTime()},
 	}
 }
 
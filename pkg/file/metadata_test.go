@@ -4,6 +4,7 @@
 package file
 
 import (
+	"archive/tar"
 	"io"
 	"os"
 	"strings"
@@ -200,3 +201,22 @@ func TestFileMetadataFromPath(t *testing.T) {
 		})
 	}
 }
+
+func TestNewMetadata_Timestamps(t *testing.T) {
+	modified := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	accessed := modified.Add(time.Hour)
+	changed := modified.Add(2 * time.Hour)
+
+	m := NewMetadata(tar.Header{
+		Name:       "etc/hostname",
+		Typeflag:   tar.TypeReg,
+		ModTime:    modified,
+		AccessTime: accessed,
+		ChangeTime: changed,
+	}, nil)
+	assert.Equal(t, Timestamps{Modified: modified, Accessed: accessed, Changed: changed}, m.Timestamps)
+
+	// access and change times are only known when recorded
+	m = NewMetadata(tar.Header{Name: "etc/hostname", Typeflag: tar.TypeReg, ModTime: modified}, nil)
+	assert.Equal(t, Timestamps{Modified: modified}, m.Timestamps)
+}
@@ -0,0 +1,19 @@
+package file
+
+import (
+	"strings"
+)
+
+// xattrSELinux is the extended attribute holding the SELinux security context of a file.
+const xattrSELinux = "security.selinux"
+
+// selinuxContextFromPAXRecords returns the SELinux security context (e.g. "system_u:object_r:bin_t:s0") recorded within
+// the given PAX records (empty when there is none).
+func selinuxContextFromPAXRecords(records map[string]string) (string, error) {
+	value, ok, err := xattrFromPAXRecords(records, xattrSELinux)
+	if !ok || err != nil {
+		return "", err
+	}
+	// the kernel stores the context NUL terminated
+	return strings.TrimRight(string(value), "\x00"), nil
+}
@@ -0,0 +1,42 @@
+package file
+
+import (
+	"archive/tar"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMetadata_SELinuxContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		records map[string]string
+		want    string
+	}{
+		{
+			name: "unlabeled",
+		},
+		{
+			name:    "NUL terminated xattr",
+			records: map[string]string{"SCHILY.xattr.security.selinux": "system_u:object_r:bin_t:s0\x00"},
+			want:    "system_u:object_r:bin_t:s0",
+		},
+		{
+			name:    "libarchive xattr",
+			records: map[string]string{"LIBARCHIVE.xattr.security.selinux": base64.StdEncoding.EncodeToString([]byte("system_u:object_r:etc_t:s0\x00"))},
+			want:    "system_u:object_r:etc_t:s0",
+		},
+		{
+			name:    "invalid libarchive xattr is ignored",
+			records: map[string]string{"LIBARCHIVE.xattr.security.selinux": "!!!"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tar.Header{Name: "bin/sh", Typeflag: tar.TypeReg, Mode: 0755, PAXRecords: tt.records}
+			assert.Equal(t, tt.want, NewMetadata(header, strings.NewReader("")).SELinuxContext)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package file
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// xattrFromPAXRecords returns the value of the given extended attribute as recorded within PAX records, either as-is
+// (SCHILY.xattr.<name>, written by GNU tar, docker, and buildkit) or base64 encoded (LIBARCHIVE.xattr.<name>).
+func xattrFromPAXRecords(records map[string]string, name string) ([]byte, bool, error) {
+	if value, ok := records["SCHILY.xattr."+name]; ok {
+		return []byte(value), true, nil
+	}
+	value, ok := records["LIBARCHIVE.xattr."+name]
+	if !ok {
+		return nil, false, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		// libarchive may omit the padding
+		if raw, err = base64.RawStdEncoding.DecodeString(value); err != nil {
+			return nil, true, fmt.Errorf("invalid base64 value of xattr %q: %w", name, err)
+		}
+	}
+	return raw, true, nil
+}
@@ -211,55 +211,78 @@ func (t *FileTree) node(p file.Path, strategy linkResolutionStrategy) (*nodeAcce
 		}, nil
 	}
 
+	currentNode, err := t.linkResolver().resolve(normalizedPath, strategy)
+	if currentNode != nil {
+		currentNode.RequestPath = normalizedPath
+	}
+	return currentNode, err
+}
+
+// linkResolver resolves the links within paths of a tree, given the lookup of nodes within the tree (without any link
+// resolution). This is the single implementation of link resolution for FileTrees and any other LinkReader.
+type linkResolver struct {
+	// lookup returns the node at the given path, which is not found when missing from the tree
+	lookup func(p file.Path) (*nodeAccess, error)
+	// byRealPath is set when the lookup never finds a path with links within its ancestors (as with FileTree, where all
+	// nodes are indexed by real path), such that a path found as is needs no further resolution
+	byRealPath bool
+}
+
+func (t *FileTree) linkResolver() linkResolver {
+	return linkResolver{
+		lookup: func(p file.Path) (*nodeAccess, error) {
+			return t.node(p, linkResolutionStrategy{})
+		},
+		byRealPath: true,
+	}
+}
+
+// resolve returns the node at the given (normalized) path following links as described by the strategy. Paths that
+// are not found are returned without a FileNode, where the request path is resolved as far as possible.
+func (r linkResolver) resolve(normalizedPath file.Path, strategy linkResolutionStrategy) (*nodeAccess, error) {
 	var currentNode *nodeAccess
 	var err error
 	if strategy.FollowAncestorLinks {
-		currentNode, err = t.resolveAncestorLinks(normalizedPath, nil, maxLinkResolutionDepth)
+		currentNode, err = r.resolveAncestorLinks(normalizedPath, nil, maxLinkResolutionDepth)
 		if err != nil {
-			if currentNode != nil {
-				currentNode.RequestPath = normalizedPath
-			}
 			return currentNode, err
 		}
 	} else {
-		n := t.tree.Node(nodeID)
-		if n != nil {
-			currentNode = &nodeAccess{
-				RequestPath: normalizedPath,
-				FileNode:    n.(*filenode.FileNode),
-			}
+		currentNode, err = r.lookup(normalizedPath)
+		if err != nil {
+			return nil, err
+		}
+		if !currentNode.HasFileNode() {
+			currentNode = nil
 		}
 	}
 
 	// link resolution has come up with nothing, return what we have so far
 	if !currentNode.HasFileNode() {
-		if currentNode != nil {
-			currentNode.RequestPath = normalizedPath
-		}
 		return currentNode, nil
 	}
 
 	if strategy.FollowBasenameLinks {
-		currentNode, err = t.resolveNodeLinks(currentNode, !strategy.DoNotFollowDeadBasenameLinks, nil, maxLinkResolutionDepth)
-	}
-	if currentNode != nil {
-		currentNode.RequestPath = normalizedPath
+		currentNode, err = r.resolveNodeLinks(currentNode, !strategy.DoNotFollowDeadBasenameLinks, nil, maxLinkResolutionDepth)
 	}
-
 	return currentNode, err
 }
 
 // return FileNode of the basename in the given path (no resolution is done at or past the basename). Note: it is
 // assumed that the given path has already been normalized.
-func (t *FileTree) resolveAncestorLinks(path file.Path, currentlyResolvingLinkPaths file.PathCountSet, maxLinkDepth int) (*nodeAccess, error) {
-	// performance optimization... see if there is a node at the path (as if it is a real path). If so,
-	// use it, otherwise, continue with ancestor resolution
-	currentNodeAccess, err := t.node(path, linkResolutionStrategy{})
-	if err != nil {
-		return nil, err
-	}
-	if currentNodeAccess.HasFileNode() {
-		return currentNodeAccess, nil
+func (r linkResolver) resolveAncestorLinks(path file.Path, currentlyResolvingLinkPaths file.PathCountSet, maxLinkDepth int) (*nodeAccess, error) {
+	var currentNodeAccess *nodeAccess
+	var err error
+	if r.byRealPath {
+		// performance optimization... see if there is a node at the path (as if it is a real path). If so,
+		// use it, otherwise, continue with ancestor resolution
+		currentNodeAccess, err = r.lookup(path)
+		if err != nil {
+			return nil, err
+		}
+		if currentNodeAccess.HasFileNode() {
+			return currentNodeAccess, nil
+		}
 	}
 
 	var pathParts = strings.Split(string(path), file.DirSeparator)
@@ -278,7 +301,7 @@ func (t *FileTree) resolveAncestorLinks(path file.Path, currentlyResolvingLinkPa
 		currentPathStr = string(currentPath)
 
 		// fetch the Node with NO link resolution strategy
-		currentNodeAccess, err = t.node(currentPath, linkResolutionStrategy{})
+		currentNodeAccess, err = r.lookup(currentPath)
 		if err != nil {
 			// should never occur
 			return nil, err
@@ -289,6 +312,7 @@ func (t *FileTree) resolveAncestorLinks(path file.Path, currentlyResolvingLinkPa
 			// 1. the current path is really invalid and we should return NIL indicating that it cannot be resolved.
 			// 2. the current path is a link? no, this isn't possible since we are iterating through constituent paths
 			//      in order, so we are guaranteed to hit parent links in which we should adjust the search path accordingly.
+			currentNodeAccess.RequestPath = joinPath(currentPath, pathParts[idx+1:])
 			return currentNodeAccess, nil
 		}
 
@@ -307,14 +331,16 @@ func (t *FileTree) resolveAncestorLinks(path file.Path, currentlyResolvingLinkPa
 		// links until the next Node is resolved (or not).
 		isLastPart := idx == len(pathParts)-1
 		if !isLastPart && currentNodeAccess.FileNode.IsLink() {
-			currentNodeAccess, err = t.resolveNodeLinks(currentNodeAccess, true, currentlyResolvingLinkPaths, maxLinkDepth)
+			currentNodeAccess, err = r.resolveNodeLinks(currentNodeAccess, true, currentlyResolvingLinkPaths, maxLinkDepth)
 			if err != nil {
 				// only expected to happen on cycles
 				return currentNodeAccess, err
 			}
-			if currentNodeAccess.HasFileNode() {
-				currentPath = currentNodeAccess.FileNode.RealPath
+			if !currentNodeAccess.HasFileNode() {
+				// the link is dead, thus nothing exists beneath it
+				return &nodeAccess{RequestPath: joinPath(currentNodeAccess.RequestPath, pathParts[idx+1:])}, nil
 			}
+			currentPath = currentNodeAccess.FileNode.RealPath
 			currentPathStr = string(currentPath)
 		}
 	}
@@ -323,10 +349,18 @@ func (t *FileTree) resolveAncestorLinks(path file.Path, currentlyResolvingLinkPa
 	return currentNodeAccess, nil
 }
 
+// joinPath appends the given path elements to the given path.
+func joinPath(p file.Path, parts []string) file.Path {
+	if len(parts) == 0 {
+		return p
+	}
+	return file.Path(strings.TrimSuffix(string(p), file.DirSeparator) + file.DirSeparator + strings.Join(parts, file.DirSeparator))
+}
+
 // resolveNodeLinks takes the given FileNode and resolves all links at the base of the real path for the node (this implies
 // that NO ancestors are considered).
 // nolint: funlen
-func (t *FileTree) resolveNodeLinks(n *nodeAccess, followDeadBasenameLinks bool, currentlyResolvingLinkPaths file.PathCountSet, maxLinkDepth int) (*nodeAccess, error) {
+func (r linkResolver) resolveNodeLinks(n *nodeAccess, followDeadBasenameLinks bool, currentlyResolvingLinkPaths file.PathCountSet, maxLinkDepth int) (*nodeAccess, error) {
 	if n == nil {
 		return nil, fmt.Errorf("cannot resolve links with nil Node given")
 	}
@@ -400,7 +434,7 @@ func (t *FileTree) resolveNodeLinks(n *nodeAccess, followDeadBasenameLinks bool,
 		// get the next Node (based on the next path)
 		// attempted paths maintains state across calls to resolveAncestorLinks
 		currentlyResolvingLinkPaths.Add(nextPath)
-		currentNodeAccess, err = t.resolveAncestorLinks(nextPath, currentlyResolvingLinkPaths, maxLinkDepth)
+		currentNodeAccess, err = r.resolveAncestorLinks(nextPath, currentlyResolvingLinkPaths, maxLinkDepth)
 		if err != nil {
 			if currentNodeAccess != nil {
 				currentNodeAccess.LeafLinkResolution = append(currentNodeAccess.LeafLinkResolution, nodePath...)
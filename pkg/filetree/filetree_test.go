@@ -1182,7 +1182,7 @@ func TestFileTreeMaxLinkDepth(t *testing.T) {
 	currentNode, err := tr.node("/usr/local/bin/ksh", rs)
 	require.NoError(t, err)
 
-	_, err = tr.resolveNodeLinks(currentNode, !rs.DoNotFollowDeadBasenameLinks, nil, 2)
+	_, err = tr.linkResolver().resolveNodeLinks(currentNode, !rs.DoNotFollowDeadBasenameLinks, nil, 2)
 	require.Error(t, err, "should have gotten an error on resolution of a dead cycle")
 	// require certain error
 	if err != ErrLinkResolutionDepth {
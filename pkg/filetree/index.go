@@ -35,6 +35,11 @@ type IndexWriter interface {
 	Add(f file.Reference, m file.Metadata)
 }
 
+// IndexRemover is an index that entries can be removed from (e.g. the entries of a layer that failed to be read).
+type IndexRemover interface {
+	Remove(f file.Reference)
+}
+
 // Index represents all file metadata and source tracing for all files contained within the image layer
 // blobs (i.e. everything except for the image index/manifest/metadata files).
 type index struct {
@@ -114,6 +119,47 @@ func (c *index) Add(f file.Reference, m file.Metadata) {
 	}
 }
 
+// Remove deletes the IndexEntry of the given file reference (if any).
+func (c *index) Remove(f file.Reference) {
+	c.Lock()
+	defer c.Unlock()
+
+	id := f.ID()
+	entry, ok := c.index[id]
+	if !ok {
+		return
+	}
+	delete(c.index, id)
+
+	removeID := func(sets map[string]file.IDSet, key string) {
+		if set, ok := sets[key]; ok {
+			set.Remove(id)
+			if len(set) == 0 {
+				delete(sets, key)
+			}
+		}
+	}
+
+	removeID(c.byMIMEType, entry.Metadata.MIMEType)
+
+	basename := path.Base(string(f.RealPath))
+	removeID(c.byBasename, basename)
+	if _, ok := c.byBasename[basename]; !ok {
+		c.basenames.Remove(basename)
+	}
+
+	for _, ext := range fileExtensions(string(f.RealPath)) {
+		removeID(c.byExtension, ext)
+	}
+
+	if set, ok := c.byFileType[entry.Metadata.Type]; ok {
+		set.Remove(id)
+		if len(set) == 0 {
+			delete(c.byFileType, entry.Metadata.Type)
+		}
+	}
+}
+
 // Exists indicates if the given file reference exists in the index.
 func (c *index) Exists(f file.Reference) bool {
 	c.RLock()
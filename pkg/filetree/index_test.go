@@ -800,3 +800,30 @@ func TestFileCatalog_GetBasenames(t *testing.T) {
 		})
 	}
 }
+
+func TestFileCatalog_Remove(t *testing.T) {
+	fileIndex := commonIndexFixture(t)
+
+	entries, err := fileIndex.GetByBasename("file-3.txt")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	ref := entries[0].Reference
+
+	fileIndex.(IndexRemover).Remove(ref)
+	// removing an entry that is not indexed is a no-op
+	fileIndex.(IndexRemover).Remove(ref)
+
+	assert.False(t, fileIndex.Exists(ref))
+	assert.NotContains(t, fileIndex.Basenames(), "file-3.txt")
+
+	entries, err = fileIndex.GetByExtension(".txt")
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotEqual(t, ref.ID(), entry.ID())
+	}
+	assert.Len(t, entries, 2)
+
+	entries, err = fileIndex.GetByMIMEType("text/plain")
+	require.NoError(t, err)
+	assert.Len(t, entries, 5)
+}
@@ -0,0 +1,129 @@
+package filetree
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree/filenode"
+)
+
+var _ LinkReader = (*FileTree)(nil)
+var _ LinkReader = (*DirectoryLinkReader)(nil)
+
+// LinkReader is the minimal view of a tree-like structure (e.g. a FileTree or an unpacked image root filesystem)
+// needed to resolve paths within it (see ResolvePath).
+type LinkReader interface {
+	// ReadLink describes the entry at the given absolute path, which never has links within its ancestors. The
+	// destination is the raw link destination when the entry is a link (empty otherwise).
+	ReadLink(p file.Path) (destination string, exists bool, err error)
+}
+
+// ResolvePath resolves all links within the given path as if the root of the given tree were the root of the
+// filesystem (as with chroot), the same way paths are resolved within image trees:
+//   - absolute link destinations are relative to the tree root
+//   - relative link destinations are relative to the directory of the link
+//   - ".." never escapes the tree root ("/.." is "/")
+//
+// Links within all ancestors of the path are always followed, however, a link at the basename is only followed with
+// FollowBasenameLinks (where DoNotFollowDeadBasenameLinks returns the last link that exists instead of a path that
+// does not). The resolved path may not exist within the tree. Link cycles are reported with ErrLinkCycleDetected.
+func ResolvePath(tree LinkReader, p file.Path, options ...LinkResolutionOption) (file.Path, error) {
+	strategy := newLinkResolutionStrategy(options...)
+	strategy.FollowAncestorLinks = true
+
+	var resolver linkResolver
+	if t, ok := tree.(*FileTree); ok {
+		resolver = t.linkResolver()
+	} else {
+		resolver = readerLinkResolver(tree)
+	}
+
+	n, err := resolver.resolve(p.Normalize(), strategy)
+	switch {
+	case err != nil:
+		return "", err
+	case n.HasFileNode():
+		return n.FileNode.RealPath, nil
+	case n != nil:
+		return n.RequestPath, nil
+	}
+	return p.Normalize(), nil
+}
+
+// readerLinkResolver resolves links within the given tree with the same algorithm used for FileTrees, where each entry
+// described by the tree is a node (without a reference to any file contents).
+func readerLinkResolver(tree LinkReader) linkResolver {
+	return linkResolver{
+		lookup: func(p file.Path) (*nodeAccess, error) {
+			p = p.Normalize()
+			destination, exists, err := tree.ReadLink(p)
+			if err != nil || !exists {
+				return &nodeAccess{RequestPath: p}, err
+			}
+
+			// note: the reference only marks the node as known (as with any node added to a FileTree)
+			ref := &file.Reference{RealPath: p}
+			n := filenode.NewFile(p, ref)
+			if destination != "" {
+				n = filenode.NewSymLink(p, file.Path(destination), ref)
+			}
+			return &nodeAccess{RequestPath: p, FileNode: n}, nil
+		},
+	}
+}
+
+// ReadLink describes the node at the given path without any link resolution (see LinkReader).
+func (t *FileTree) ReadLink(p file.Path) (string, bool, error) {
+	n := t.tree.Node(filenode.IDByPath(p.Normalize()))
+	if n == nil {
+		return "", false, nil
+	}
+	fn := n.(*filenode.FileNode)
+	if !fn.IsLink() {
+		return "", true, nil
+	}
+	return string(fn.LinkPath), true, nil
+}
+
+// DirectoryLinkReader is a LinkReader for a root filesystem within a directory (e.g. an unpacked image), allowing for
+// paths to be resolved within the directory without ever escaping it (see ResolvePath).
+type DirectoryLinkReader struct {
+	root string
+}
+
+// NewDirectoryLinkReader creates a LinkReader for the root filesystem within the given directory.
+func NewDirectoryLinkReader(root string) *DirectoryLinkReader {
+	return &DirectoryLinkReader{root: root}
+}
+
+// ReadLink describes the entry at the given path relative to the root directory without following any links.
+func (r *DirectoryLinkReader) ReadLink(p file.Path) (string, bool, error) {
+	realPath := r.Path(p)
+
+	info, err := os.Lstat(realPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, syscall.ENOTDIR):
+		return "", false, nil
+	case err != nil:
+		return "", false, err
+	}
+
+	if info.Mode()&fs.ModeSymlink == 0 {
+		return "", true, nil
+	}
+
+	destination, err := os.Readlink(realPath)
+	if err != nil {
+		return "", false, err
+	}
+	return filepath.ToSlash(destination), true, nil
+}
+
+// Path returns the location of the given path (as resolved by ResolvePath) on disk.
+func (r *DirectoryLinkReader) Path(p file.Path) string {
+	return filepath.Join(r.root, filepath.FromSlash(string(p.Normalize())))
+}
@@ -0,0 +1,127 @@
+package filetree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func TestResolvePath(t *testing.T) {
+	tree := New()
+	for _, p := range []file.Path{"/usr/bin/env", "/etc/passwd", "/real/file.txt"} {
+		_, err := tree.AddFile(p)
+		require.NoError(t, err)
+	}
+	for link, dest := range map[file.Path]file.Path{
+		"/bin":                "usr/bin",
+		"/escape/abs":         "/../../../etc/passwd",
+		"/escape/rel":         "../../../../etc/passwd",
+		"/link-to-dir":        "/real",
+		"/link-to-link":       "link-to-dir/file.txt",
+		"/dead":               "/does/not/exist",
+		"/link-to-dead":       "dead",
+		"/real/sibling":       "./file.txt",
+		"/cycle/a":            "b",
+		"/cycle/b":            "a",
+		"/nested/up":          "../link-to-dir/../etc",
+		"/ancestor/link":      "/link-to-dir",
+		"/ancestor/link-file": "link/file.txt",
+	} {
+		_, err := tree.AddSymLink(link, dest)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		path    file.Path
+		options []LinkResolutionOption
+		want    file.Path
+		wantErr error
+	}{
+		{path: "/bin/env", want: "/usr/bin/env"},
+		// the basename is not followed by default
+		{path: "/bin", want: "/bin"},
+		{path: "/bin", options: []LinkResolutionOption{FollowBasenameLinks}, want: "/usr/bin"},
+		// links never escape the root
+		{path: "/escape/abs", options: []LinkResolutionOption{FollowBasenameLinks}, want: "/etc/passwd"},
+		{path: "/escape/rel", options: []LinkResolutionOption{FollowBasenameLinks}, want: "/etc/passwd"},
+		{path: "/../../bin/../etc/passwd", want: "/etc/passwd"},
+		{path: "/link-to-link", options: []LinkResolutionOption{FollowBasenameLinks}, want: "/real/file.txt"},
+		{path: "/real/sibling", options: []LinkResolutionOption{FollowBasenameLinks}, want: "/real/file.txt"},
+		{path: "/ancestor/link-file", options: []LinkResolutionOption{FollowBasenameLinks}, want: "/real/file.txt"},
+		// destinations are cleaned lexically (consistent with FileTree link resolution)
+		{path: "/nested/up", options: []LinkResolutionOption{FollowBasenameLinks}, want: "/etc"},
+		{path: "/link-to-dead", options: []LinkResolutionOption{FollowBasenameLinks}, want: "/does/not/exist"},
+		{path: "/link-to-dead", options: []LinkResolutionOption{FollowBasenameLinks, DoNotFollowDeadBasenameLinks}, want: "/dead"},
+		// paths that do not exist are resolved as far as possible
+		{path: "/link-to-dead/child", want: "/does/not/exist/child"},
+		{path: "/link-to-dir/missing/child", want: "/real/missing/child"},
+		{path: "/cycle/a", options: []LinkResolutionOption{FollowBasenameLinks}, wantErr: ErrLinkCycleDetected},
+		{path: "/cycle/a/child", wantErr: ErrLinkCycleDetected},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.path), func(t *testing.T) {
+			got, err := ResolvePath(tree, tt.path, tt.options...)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			// resolution is consistent with the FileTree itself
+			exists, res, err := tree.File(tt.path, tt.options...)
+			require.NoError(t, err)
+			if exists && res.HasReference() {
+				assert.Equal(t, res.Reference.RealPath, got)
+			}
+		})
+	}
+}
+
+func TestResolvePath_Directory(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "etc"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "etc", "passwd"), []byte("inside"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "passwd"), []byte("outside"), 0o644))
+
+	require.NoError(t, os.Symlink("/etc/passwd", filepath.Join(root, "abs")))
+	require.NoError(t, os.Symlink("../../../../../../etc/passwd", filepath.Join(root, "rel")))
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "host")))
+	require.NoError(t, os.Symlink("/etc", filepath.Join(root, "dir")))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "regular"), []byte("file"), 0o644))
+
+	reader := NewDirectoryLinkReader(root)
+
+	tests := []struct {
+		path file.Path
+		want file.Path
+	}{
+		{path: "/abs", want: "/etc/passwd"},
+		{path: "/rel", want: "/etc/passwd"},
+		{path: "/dir/passwd", want: "/etc/passwd"},
+		// absolute destinations are relative to the root, never the host
+		{path: "/host/passwd", want: file.Path(filepath.ToSlash(outside) + "/passwd")},
+		// paths through regular files never exist
+		{path: "/regular/child", want: "/regular/child"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.path), func(t *testing.T) {
+			got, err := ResolvePath(reader, tt.path, FollowBasenameLinks)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.True(t, filepath.IsAbs(reader.Path(got)))
+			assert.Contains(t, reader.Path(got), root)
+		})
+	}
+
+	contents, err := os.ReadFile(reader.Path("/etc/passwd"))
+	require.NoError(t, err)
+	assert.Equal(t, "inside", string(contents))
+}
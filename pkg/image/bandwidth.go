@@ -0,0 +1,91 @@
+package image
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+type bandwidthLimiterKey struct{}
+
+// BandwidthLimiter is a token bucket limiting the rate at which downloaded bytes are read, shared by all concurrent
+// downloads of an image (e.g. registry layer downloads) such that the configured rate is the total rate. Time is
+// measured by the clock of the context of each read (see ClockFromContext).
+type BandwidthLimiter struct {
+	lock        sync.Mutex
+	bytesPerSec int64
+	tokens      float64
+	last        time.Time
+}
+
+// NewBandwidthLimiter creates a limiter for the given rate, which allows bursts of up to one second worth of bytes.
+func NewBandwidthLimiter(bytesPerSec int64) *BandwidthLimiter {
+	return &BandwidthLimiter{
+		bytesPerSec: bytesPerSec,
+		tokens:      float64(bytesPerSec),
+	}
+}
+
+// ContextWithBandwidthLimiter returns a context that carries the limiter for downloads.
+func ContextWithBandwidthLimiter(ctx context.Context, limiter *BandwidthLimiter) context.Context {
+	return context.WithValue(ctx, bandwidthLimiterKey{}, limiter)
+}
+
+// BandwidthLimiterFromContext returns the limiter for downloads carried by the context (nil when downloads are not
+// limited).
+func BandwidthLimiterFromContext(ctx context.Context) *BandwidthLimiter {
+	limiter, _ := ctx.Value(bandwidthLimiterKey{}).(*BandwidthLimiter)
+	return limiter
+}
+
+// WaitN accounts for n bytes that have been read, waiting until the rate allows for them (or the context is done).
+func (l *BandwidthLimiter) WaitN(ctx context.Context, n int) error {
+	clock := ClockFromContext(ctx)
+
+	l.lock.Lock()
+	now := clock.Now()
+	if !l.last.IsZero() {
+		if elapsed := now.Sub(l.last); elapsed > 0 {
+			l.tokens = min(float64(l.bytesPerSec), l.tokens+elapsed.Seconds()*float64(l.bytesPerSec))
+		}
+	}
+	l.last = now
+	// reserve the bytes, such that concurrent readers wait in turn
+	l.tokens -= float64(n)
+	deficit := -l.tokens
+	l.lock.Unlock()
+
+	if deficit <= 0 {
+		return nil
+	}
+	return sleep(ctx, time.Duration(deficit/float64(l.bytesPerSec)*float64(time.Second)))
+}
+
+// Reader returns a reader limited by the limiter (the given reader when the limiter is nil).
+func (l *BandwidthLimiter) Reader(ctx context.Context, reader io.Reader) io.Reader {
+	if l == nil {
+		return reader
+	}
+	return &limitedReader{ctx: ctx, reader: reader, limiter: l}
+}
+
+type limitedReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *BandwidthLimiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	// reads are no larger than a burst, so the rate is not exceeded for long
+	if int64(len(p)) > r.limiter.bytesPerSec {
+		p = p[:r.limiter.bytesPerSec]
+	}
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
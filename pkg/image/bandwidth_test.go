@@ -0,0 +1,74 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBandwidthLimiter_WaitN(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	ctx := ContextWithClock(context.Background(), clock)
+	limiter := NewBandwidthLimiter(100)
+
+	// a burst of up to one second worth of bytes is allowed
+	require.NoError(t, limiter.WaitN(ctx, 100))
+
+	done := make(chan error)
+	go func() {
+		done <- limiter.WaitN(ctx, 50)
+	}()
+
+	require.Eventually(t, func() bool {
+		clock.lock.Lock()
+		defer clock.lock.Unlock()
+		return len(clock.timers) == 1
+	}, 5*time.Second, time.Millisecond)
+
+	clock.Advance(400 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("wait returned before the rate allowed for the bytes")
+	default:
+	}
+
+	clock.Advance(100 * time.Millisecond)
+	require.NoError(t, <-done)
+
+	// bytes are allowed again as time passes
+	clock.Advance(time.Second)
+	require.NoError(t, limiter.WaitN(ctx, 100))
+}
+
+func TestBandwidthLimiter_WaitN_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(ContextWithClock(context.Background(), NewManualClock(time.Now())))
+	limiter := NewBandwidthLimiter(10)
+	require.NoError(t, limiter.WaitN(ctx, 10))
+
+	cancel()
+	require.ErrorIs(t, limiter.WaitN(ctx, 10), context.Canceled)
+}
+
+func TestBandwidthLimiter_Reader(t *testing.T) {
+	var limiter *BandwidthLimiter
+	reader := bytes.NewReader([]byte("unlimited"))
+	assert.Equal(t, reader, limiter.Reader(context.Background(), reader))
+
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	limiter = NewBandwidthLimiter(1 << 20)
+	got, err := io.ReadAll(limiter.Reader(context.Background(), bytes.NewReader(data)))
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	// reads are no larger than a burst
+	limiter = NewBandwidthLimiter(4)
+	buf := make([]byte, 10)
+	n, err := limiter.Reader(context.Background(), bytes.NewReader(data)).Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+}
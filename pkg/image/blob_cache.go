@@ -0,0 +1,401 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+var blobDigestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// BlobCache is a persistent, content-addressed cache of uncompressed layer tars (by diff ID) and image configs (by
+// config digest) that is shared between invocations and providers. Layers found within the cache are not downloaded
+// from registries again, and images whose config and layers are all cached are not exported from the docker or
+// containerd daemons again.
+//
+// When a maximum size is given, the least recently used blobs are evicted whenever a new blob is added and the cache
+// exceeds the maximum size. Blobs are always linked (or copied, when linking is not possible) out of the cache before
+// use, so eviction never affects images that have already been read. Older images of a repository can also expire from
+// the cache regardless of its size (see SetRepositoryRetention).
+//
+// The cache directory may be shared by concurrent processes on the same host: blobs are only ever added atomically,
+// and blobs are only removed while no other process is adding or linking blobs (see cacheLock).
+type BlobCache struct {
+	root    string
+	maxSize int64
+	// fileLock excludes other processes sharing the cache directory
+	fileLock cacheLock
+	// keepPerRepository is the number of most recently read images retained per repository (unbounded when zero)
+	keepPerRepository int
+	lock              sync.Mutex
+}
+
+// NewBlobCache creates (or reuses) a blob cache within the given directory. When maxSize is positive then the cache is
+// bounded to roughly the given number of bytes.
+func NewBlobCache(dir string, maxSize int64) (*BlobCache, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("no blob cache directory given")
+	}
+	if maxSize < 0 {
+		return nil, fmt.Errorf("blob cache size limit must not be negative: %d", maxSize)
+	}
+
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, "blobs", "sha256"), 0o700); err != nil {
+		return nil, fmt.Errorf("unable to create blob cache directory: %w", err)
+	}
+
+	return &BlobCache{
+		root:     root,
+		maxSize:  maxSize,
+		fileLock: cacheLock{path: filepath.Join(root, blobCacheLockFile)},
+	}, nil
+}
+
+// Dir is the root directory of the cache.
+func (c *BlobCache) Dir() string {
+	return c.root
+}
+
+func (c *BlobCache) path(digest string) (string, error) {
+	if !blobDigestPattern.MatchString(digest) {
+		return "", fmt.Errorf("unsupported blob digest: %q", digest)
+	}
+	algorithm, hex, _ := strings.Cut(digest, ":")
+	return filepath.Join(c.root, "blobs", algorithm, hex), nil
+}
+
+// Has indicates that the blob with the given digest is within the cache.
+func (c *BlobCache) Has(digest string) bool {
+	p, err := c.path(digest)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(p)
+	return err == nil
+}
+
+// Open the blob with the given digest, marking it as recently used.
+func (c *BlobCache) Open(digest string) (io.ReadCloser, error) {
+	p, err := c.path(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	// note: the lock is only needed while opening, since removing the blob does not affect open files
+	unlock, err := c.fileLock.shared()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	c.touch(p)
+	return os.Open(p)
+}
+
+// Get returns the contents of the blob with the given digest, marking it as recently used.
+func (c *BlobCache) Get(digest string) ([]byte, error) {
+	reader, err := c.Open(digest)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// Put adds the contents of the given reader to the cache, failing if the contents do not match the given digest.
+func (c *BlobCache) Put(digest string, reader io.Reader) error {
+	p, err := c.path(digest)
+	if err != nil {
+		return err
+	}
+
+	if err := c.put(p, digest, reader); err != nil {
+		return err
+	}
+	return c.evict(p)
+}
+
+// put writes the contents of the given reader to the given blob path (while holding a shared lock of the cache).
+func (c *BlobCache) put(p, digest string, reader io.Reader) error {
+	unlock, err := c.fileLock.shared()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	fh, err := os.CreateTemp(filepath.Dir(p), ".partial-*")
+	if err != nil {
+		return fmt.Errorf("unable to create blob cache entry: %w", err)
+	}
+	defer os.Remove(fh.Name())
+
+	hasher := sha256.New()
+	_, err = io.Copy(io.MultiWriter(fh, hasher), reader)
+	if closeErr := fh.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write blob cache entry: %w", err)
+	}
+
+	if actual := fmt.Sprintf("sha256:%x", hasher.Sum(nil)); actual != digest {
+		return fmt.Errorf("blob digest mismatch: expected %q but got %q", digest, actual)
+	}
+
+	if err := os.Rename(fh.Name(), p); err != nil {
+		return fmt.Errorf("unable to add blob cache entry: %w", err)
+	}
+	return nil
+}
+
+// Link makes the blob with the given digest available at the given path (as a hard link when possible, otherwise as a
+// copy), marking it as recently used.
+func (c *BlobCache) Link(digest, dest string) error {
+	p, err := c.path(digest)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := c.fileLock.shared()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	c.touch(p)
+
+	if err := os.Link(p, dest); err == nil {
+		return nil
+	}
+
+	src, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// copy to an intermediate file such that a failed copy is never mistaken for the complete blob
+	partialPath := dest + ".partial"
+	fh, err := os.Create(partialPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fh, src)
+	if closeErr := fh.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(partialPath)
+		return err
+	}
+	return os.Rename(partialPath, dest)
+}
+
+// Size returns the total size of all blobs within the cache.
+func (c *BlobCache) Size() (int64, error) {
+	entries, err := c.entries()
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, e := range entries {
+		size += e.size
+	}
+	return size, nil
+}
+
+// Image returns an image composed entirely of cached blobs for the image with the given config digest, which is only
+// available when the config and all layers of the image are within the cache.
+func (c *BlobCache) Image(configDigest string) (v1.Image, bool) {
+	if c == nil || !c.Has(configDigest) {
+		return nil, false
+	}
+
+	rawConfig, err := c.Get(configDigest)
+	if err != nil {
+		return nil, false
+	}
+
+	cfg, err := v1.ParseConfigFile(bytes.NewReader(rawConfig))
+	if err != nil {
+		log.WithFields("digest", configDigest, "error", err).Debug("unable to parse cached image config")
+		return nil, false
+	}
+
+	for _, diffID := range cfg.RootFS.DiffIDs {
+		if !c.Has(diffID.String()) {
+			return nil, false
+		}
+	}
+
+	img, err := partial.UncompressedToImage(&cachedImage{cache: c, rawConfig: rawConfig, config: cfg})
+	if err != nil {
+		return nil, false
+	}
+	return img, true
+}
+
+type blobCacheEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func (c *BlobCache) entries() ([]blobCacheEntry, error) {
+	var entries []blobCacheEntry
+	err := filepath.WalkDir(filepath.Join(c.root, "blobs"), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// concurrently evicted
+				return nil
+			}
+			return err
+		}
+		entries = append(entries, blobCacheEntry{path: p, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	return entries, err
+}
+
+// evict removes the least recently used blobs (never the given blob) until the cache is within the maximum size.
+func (c *BlobCache) evict(keep string) error {
+	if c.maxSize <= 0 {
+		return nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	unlock, err := c.fileLock.exclusive()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := c.entries()
+	if err != nil {
+		return fmt.Errorf("unable to read blob cache: %w", err)
+	}
+
+	var size int64
+	for _, e := range entries {
+		size += e.size
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+
+	for _, e := range entries {
+		if size <= c.maxSize {
+			break
+		}
+		if e.path == keep {
+			continue
+		}
+		if err := os.Remove(e.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("unable to evict blob cache entry: %w", err)
+		}
+		log.WithFields("path", e.path, "size", e.size).Trace("evicted blob cache entry")
+		size -= e.size
+	}
+	return nil
+}
+
+// touch marks the given blob as recently used (for eviction).
+func (c *BlobCache) touch(p string) {
+	now := time.Now()
+	if err := os.Chtimes(p, now, now); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.WithFields("path", p, "error", err).Trace("unable to update blob cache entry access time")
+	}
+}
+
+// cachedImage is a partial.UncompressedImageCore for an image where the config and all layers are within a blob cache.
+type cachedImage struct {
+	cache     *BlobCache
+	rawConfig []byte
+	config    *v1.ConfigFile
+}
+
+var _ partial.UncompressedImageCore = (*cachedImage)(nil)
+
+func (i *cachedImage) RawConfigFile() ([]byte, error) {
+	return i.rawConfig, nil
+}
+
+func (i *cachedImage) MediaType() (types.MediaType, error) {
+	return types.DockerManifestSchema2, nil
+}
+
+func (i *cachedImage) LayerByDiffID(h v1.Hash) (partial.UncompressedLayer, error) {
+	for _, diffID := range i.config.RootFS.DiffIDs {
+		if diffID == h {
+			return &cachedLayer{cache: i.cache, diffID: h}, nil
+		}
+	}
+	return nil, fmt.Errorf("diff id %q not found", h)
+}
+
+// cachedLayer is a partial.UncompressedLayer for an uncompressed layer tar within a blob cache.
+type cachedLayer struct {
+	cache  *BlobCache
+	diffID v1.Hash
+}
+
+func (l *cachedLayer) DiffID() (v1.Hash, error) {
+	return l.diffID, nil
+}
+
+func (l *cachedLayer) Uncompressed() (io.ReadCloser, error) {
+	return l.cache.Open(l.diffID.String())
+}
+
+func (l *cachedLayer) MediaType() (types.MediaType, error) {
+	return types.DockerUncompressedLayer, nil
+}
+
+type blobCacheKey struct{}
+
+// ContextWithBlobCache returns a context where layers and image configs are read from (and written to) the given
+// persistent blob cache.
+func ContextWithBlobCache(ctx context.Context, cache *BlobCache) context.Context {
+	return context.WithValue(ctx, blobCacheKey{}, cache)
+}
+
+// BlobCacheFromContext returns the blob cache for the given context (nil when there is none, see ContextWithBlobCache).
+func BlobCacheFromContext(ctx context.Context) *BlobCache {
+	cache, _ := ctx.Value(blobCacheKey{}).(*BlobCache)
+	return cache
+}
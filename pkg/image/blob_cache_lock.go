@@ -0,0 +1,51 @@
+package image
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+// blobCacheLockFile is the name of the lock file within the root of a blob cache.
+const blobCacheLockFile = ".lock"
+
+// cacheLock is an advisory lock of a blob cache directory that is shared between processes (using flock on unix and
+// LockFileEx on windows). Blobs are added and linked out of the cache while holding a shared lock, where blobs are
+// only removed (and repository records are only updated) while holding an exclusive lock, such that concurrent
+// processes sharing the cache never remove a blob that is being linked or lose record updates. Locks are held by open
+// file handles, thus the operating system releases the locks of processes that exit without unlocking (no stale
+// locks remain).
+type cacheLock struct {
+	path string
+}
+
+// shared acquires a shared lock, blocking while an exclusive lock is held, returning the function releasing the lock.
+func (l cacheLock) shared() (func(), error) {
+	return l.acquire(false)
+}
+
+// exclusive acquires an exclusive lock, blocking while any lock is held, returning the function releasing the lock.
+func (l cacheLock) exclusive() (func(), error) {
+	return l.acquire(true)
+}
+
+func (l cacheLock) acquire(exclusive bool) (func(), error) {
+	// note: each acquisition uses its own file handle, such that locks also exclude goroutines of the same process
+	fh, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open blob cache lock: %w", err)
+	}
+
+	if err := lockFile(fh, exclusive); err != nil {
+		_ = fh.Close()
+		return nil, fmt.Errorf("unable to lock blob cache: %w", err)
+	}
+
+	return func() {
+		if err := unlockFile(fh); err != nil {
+			log.WithFields("path", l.path, "error", err).Trace("unable to unlock blob cache")
+		}
+		_ = fh.Close()
+	}, nil
+}
@@ -0,0 +1,71 @@
+package image
+
+import (
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_cacheLock(t *testing.T) {
+	lock := cacheLock{path: filepath.Join(t.TempDir(), blobCacheLockFile)}
+
+	// shared locks do not exclude each other...
+	unlockFirst, err := lock.shared()
+	require.NoError(t, err)
+	unlockSecond, err := lock.shared()
+	require.NoError(t, err)
+
+	// ...but exclude an exclusive lock until all are released
+	var acquired atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		unlock, err := lock.exclusive()
+		if err != nil {
+			t.Errorf("unable to acquire exclusive lock: %v", err)
+			return
+		}
+		acquired.Store(true)
+		time.Sleep(20 * time.Millisecond)
+		unlock()
+	}()
+
+	assert.Never(t, acquired.Load, 50*time.Millisecond, 5*time.Millisecond)
+	unlockFirst()
+	assert.Never(t, acquired.Load, 20*time.Millisecond, 5*time.Millisecond)
+	unlockSecond()
+	assert.Eventually(t, acquired.Load, time.Second, 5*time.Millisecond)
+	<-done
+}
+
+func TestBlobCache_sharedDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	// caches of concurrent processes use the same lock file within the shared directory
+	first, err := NewBlobCache(dir, 0)
+	require.NoError(t, err)
+	second, err := NewBlobCache(dir, 0)
+	require.NoError(t, err)
+
+	digest := putBlob(t, first, "contents")
+	dest := filepath.Join(t.TempDir(), "blob")
+
+	unlock, err := first.fileLock.exclusive()
+	require.NoError(t, err)
+
+	var linked atomic.Bool
+	go func() {
+		if err := second.Link(digest, dest); err == nil {
+			linked.Store(true)
+		}
+	}()
+
+	// blobs are not linked while another cache removes blobs
+	assert.Never(t, linked.Load, 50*time.Millisecond, 5*time.Millisecond)
+	unlock()
+	assert.Eventually(t, linked.Load, time.Second, 5*time.Millisecond)
+}
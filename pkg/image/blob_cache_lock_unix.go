@@ -0,0 +1,28 @@
+//go:build !windows
+
+package image
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile acquires an advisory lock of the given file (see cacheLock).
+func lockFile(fh *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	for {
+		err := syscall.Flock(int(fh.Fd()), how)
+		if !errors.Is(err, syscall.EINTR) {
+			return err
+		}
+	}
+}
+
+// unlockFile releases the advisory lock of the given file.
+func unlockFile(fh *os.File) error {
+	return syscall.Flock(int(fh.Fd()), syscall.LOCK_UN)
+}
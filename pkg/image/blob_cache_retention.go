@@ -0,0 +1,265 @@
+package image
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+// RepositoryImage is an image read for a repository while using a blob cache (see BlobCache.RecordImage).
+type RepositoryImage struct {
+	// ID is the config digest of the image
+	ID string `json:"id"`
+	// Layers are the diff IDs of the layers of the image
+	Layers []string `json:"layers"`
+	// LastUsed is the last time the image was read for the repository
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+// repositoryRecord is the record of all images read for a single repository, persisted within the cache directory.
+type repositoryRecord struct {
+	Repository string            `json:"repository"`
+	Images     []RepositoryImage `json:"images"`
+}
+
+// SetRepositoryRetention keeps only the given number of most recently read images per repository within the cache
+// (unbounded when zero), where the blobs of older images are removed unless another retained image shares them. This
+// bounds the cache for repositories where tags are frequently pushed with new content (e.g. CI images), independent of
+// the maximum size of the cache.
+func (c *BlobCache) SetRepositoryRetention(keep int) error {
+	if keep < 0 {
+		return fmt.Errorf("blob cache repository retention must not be negative: %d", keep)
+	}
+	c.keepPerRepository = keep
+	return nil
+}
+
+// RecordImage records that the given image was read for the given repository (e.g. "docker.io/library/alpine"),
+// removing the images of the repository that exceed the retention of the cache (see SetRepositoryRetention).
+func (c *BlobCache) RecordImage(repository string, img RepositoryImage) error {
+	if repository == "" || !blobDigestPattern.MatchString(img.ID) {
+		return fmt.Errorf("invalid image record for repository %q: %q", repository, img.ID)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	unlock, err := c.fileLock.exclusive()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	record, err := c.readRepositoryRecord(c.repositoryRecordPath(repository))
+	if err != nil {
+		return err
+	}
+	record.Repository = repository
+
+	images := []RepositoryImage{img}
+	for _, existing := range record.Images {
+		if existing.ID != img.ID {
+			images = append(images, existing)
+		}
+	}
+	sort.SliceStable(images, func(i, j int) bool {
+		return images[i].LastUsed.After(images[j].LastUsed)
+	})
+
+	var expired []RepositoryImage
+	if c.keepPerRepository > 0 && len(images) > c.keepPerRepository {
+		expired = images[c.keepPerRepository:]
+		images = images[:c.keepPerRepository]
+	}
+	record.Images = images
+
+	if err := c.writeRepositoryRecord(record); err != nil {
+		return err
+	}
+
+	if len(expired) == 0 {
+		return nil
+	}
+	return c.removeExpired(repository, expired)
+}
+
+// RepositoryImages returns the images recorded for the given repository, most recently read first.
+func (c *BlobCache) RepositoryImages(repository string) ([]RepositoryImage, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	unlock, err := c.fileLock.shared()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	record, err := c.readRepositoryRecord(c.repositoryRecordPath(repository))
+	if err != nil {
+		return nil, err
+	}
+	return record.Images, nil
+}
+
+// removeExpired removes the blobs of the given expired images, unless the blobs are referenced by any image still
+// retained for any repository.
+func (c *BlobCache) removeExpired(repository string, expired []RepositoryImage) error {
+	retained, err := c.retainedBlobs()
+	if err != nil {
+		return err
+	}
+
+	for _, img := range expired {
+		for _, digest := range append([]string{img.ID}, img.Layers...) {
+			if retained[digest] {
+				continue
+			}
+			p, err := c.path(digest)
+			if err != nil {
+				continue
+			}
+			if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("unable to remove expired blob cache entry: %w", err)
+			}
+		}
+		log.WithFields("repository", repository, "image", img.ID).Trace("removed expired image from blob cache")
+	}
+	return nil
+}
+
+// retainedBlobs returns the digests of all blobs referenced by the images recorded for any repository.
+func (c *BlobCache) retainedBlobs() (map[string]bool, error) {
+	paths, err := filepath.Glob(filepath.Join(c.root, "repositories", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	retained := make(map[string]bool)
+	for _, p := range paths {
+		record, err := c.readRepositoryRecord(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, img := range record.Images {
+			retained[img.ID] = true
+			for _, layer := range img.Layers {
+				retained[layer] = true
+			}
+		}
+	}
+	return retained, nil
+}
+
+// repositoryRecordPath is the path of the record of the given repository (named by digest, since repository names
+// are not valid file names).
+func (c *BlobCache) repositoryRecordPath(repository string) string {
+	return filepath.Join(c.root, "repositories", fmt.Sprintf("%x.json", sha256.Sum256([]byte(repository))))
+}
+
+func (c *BlobCache) readRepositoryRecord(p string) (*repositoryRecord, error) {
+	record := &repositoryRecord{}
+	contents, err := os.ReadFile(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return record, nil
+		}
+		return nil, fmt.Errorf("unable to read blob cache repository record: %w", err)
+	}
+	if err := json.Unmarshal(contents, record); err != nil {
+		return nil, fmt.Errorf("unable to parse blob cache repository record %q: %w", p, err)
+	}
+	return record, nil
+}
+
+func (c *BlobCache) writeRepositoryRecord(record *repositoryRecord) error {
+	p := c.repositoryRecordPath(record.Repository)
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return fmt.Errorf("unable to create blob cache repository directory: %w", err)
+	}
+
+	contents, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	// write to an intermediate file such that concurrent invocations never read a partial record
+	fh, err := os.CreateTemp(filepath.Dir(p), ".partial-*")
+	if err != nil {
+		return fmt.Errorf("unable to create blob cache repository record: %w", err)
+	}
+	defer os.Remove(fh.Name())
+
+	_, err = fh.Write(contents)
+	if closeErr := fh.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write blob cache repository record: %w", err)
+	}
+	return os.Rename(fh.Name(), p)
+}
+
+// recordCachedImage records the image for each repository it is known by within the given blob cache (if any), such
+// that older images of the same repositories expire from the cache (see BlobCache.SetRepositoryRetention).
+func (i *Image) recordCachedImage(ctx context.Context, cache *BlobCache) {
+	if cache == nil {
+		return
+	}
+
+	repositories := i.repositories()
+	if len(repositories) == 0 {
+		return
+	}
+
+	cfg, err := i.image.ConfigFile()
+	if err != nil {
+		log.WithFields("error", err).Debug("unable to read image config for blob cache")
+		return
+	}
+
+	record := RepositoryImage{
+		ID:       i.Metadata.ID,
+		LastUsed: ClockFromContext(ctx).Now().UTC(),
+	}
+	for _, diffID := range cfg.RootFS.DiffIDs {
+		record.Layers = append(record.Layers, diffID.String())
+	}
+
+	for _, repository := range repositories {
+		if err := cache.RecordImage(repository, record); err != nil {
+			log.WithFields("repository", repository, "error", err).Debug("unable to record image within blob cache")
+		}
+	}
+}
+
+// repositories returns the names of the repositories the image is known by (from its tags and repo digests).
+func (i *Image) repositories() []string {
+	var repositories []string
+	add := func(repository string) {
+		if !slices.Contains(repositories, repository) {
+			repositories = append(repositories, repository)
+		}
+	}
+
+	for _, tag := range i.Metadata.Tags {
+		add(tag.Context().Name())
+	}
+	for _, repoDigest := range i.Metadata.RepoDigests {
+		if digest, err := name.NewDigest(repoDigest); err == nil {
+			add(digest.Context().Name())
+		}
+	}
+	return repositories
+}
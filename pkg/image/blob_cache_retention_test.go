@@ -0,0 +1,104 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// putBlob adds the given contents to the cache, returning the digest of the contents.
+func putBlob(t *testing.T, cache *BlobCache, contents string) string {
+	t.Helper()
+	digest, _, err := v1.SHA256(bytes.NewReader([]byte(contents)))
+	require.NoError(t, err)
+	require.NoError(t, cache.Put(digest.String(), bytes.NewReader([]byte(contents))))
+	return digest.String()
+}
+
+func TestBlobCache_RepositoryRetention(t *testing.T) {
+	cache, err := NewBlobCache(t.TempDir(), 0)
+	require.NoError(t, err)
+	require.NoError(t, cache.SetRepositoryRetention(2))
+
+	const repository = "example.com/ci/app"
+	shared := putBlob(t, cache, "shared base layer")
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	var images []RepositoryImage
+	for i := 0; i < 3; i++ {
+		img := RepositoryImage{
+			ID:       putBlob(t, cache, fmt.Sprintf("config %d", i)),
+			Layers:   []string{shared, putBlob(t, cache, fmt.Sprintf("app layer %d", i))},
+			LastUsed: start.Add(time.Duration(i) * time.Hour),
+		}
+		images = append(images, img)
+		require.NoError(t, cache.RecordImage(repository, img))
+	}
+
+	// the oldest image expired, where the layer shared with retained images remains
+	assert.False(t, cache.Has(images[0].ID))
+	assert.False(t, cache.Has(images[0].Layers[1]))
+	assert.True(t, cache.Has(shared))
+	for _, img := range images[1:] {
+		assert.True(t, cache.Has(img.ID))
+		assert.True(t, cache.Has(img.Layers[1]))
+	}
+
+	got, err := cache.RepositoryImages(repository)
+	require.NoError(t, err)
+	assert.Equal(t, []RepositoryImage{images[2], images[1]}, got)
+
+	// reading an image again makes it the most recent
+	images[1].LastUsed = start.Add(10 * time.Hour)
+	require.NoError(t, cache.RecordImage(repository, images[1]))
+	got, err = cache.RepositoryImages(repository)
+	require.NoError(t, err)
+	assert.Equal(t, []RepositoryImage{images[1], images[2]}, got)
+
+	// blobs retained for other repositories are never removed
+	other := RepositoryImage{ID: images[2].ID, Layers: images[2].Layers, LastUsed: start}
+	require.NoError(t, cache.RecordImage("example.com/other", other))
+	for i := 0; i < 2; i++ {
+		require.NoError(t, cache.RecordImage(repository, RepositoryImage{
+			ID:       putBlob(t, cache, fmt.Sprintf("newer config %d", i)),
+			LastUsed: start.Add(time.Duration(20+i) * time.Hour),
+		}))
+	}
+	assert.False(t, cache.Has(images[1].ID))
+	assert.True(t, cache.Has(images[2].ID))
+	assert.True(t, cache.Has(images[2].Layers[1]))
+
+	require.Error(t, cache.SetRepositoryRetention(-1))
+	require.Error(t, cache.RecordImage("", images[0]))
+}
+
+func TestBlobCache_ReadImage_recordsRepositories(t *testing.T) {
+	cache, err := NewBlobCache(t.TempDir(), 0)
+	require.NoError(t, err)
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	ctx := ContextWithClock(ContextWithBlobCache(context.Background(), cache), NewManualClock(now))
+
+	layer := tarLayer(t, map[string]string{"etc/os-release": "ID=test\n"})
+	v1Img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	diffID, err := layer.DiffID()
+	require.NoError(t, err)
+
+	tag, err := name.NewTag("example.com/app:latest")
+	require.NoError(t, err)
+	img := New(v1Img, nil, t.TempDir(), WithTags(tag.String()), WithRepoDigests("example.com/app@sha256:"+fmt.Sprintf("%064d", 0)))
+	require.NoError(t, img.ReadContext(ctx))
+
+	got, err := cache.RepositoryImages("example.com/app")
+	require.NoError(t, err)
+	assert.Equal(t, []RepositoryImage{{ID: img.Metadata.ID, Layers: []string{diffID.String()}, LastUsed: now}}, got)
+}
@@ -0,0 +1,165 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingLayer counts the number of times the uncompressed layer contents are requested (e.g. downloaded).
+type countingLayer struct {
+	v1.Layer
+	reads int
+}
+
+func (l *countingLayer) Uncompressed() (io.ReadCloser, error) {
+	l.reads++
+	return l.Layer.Uncompressed()
+}
+
+func TestBlobCache_ReadImage(t *testing.T) {
+	cache, err := NewBlobCache(t.TempDir(), 0)
+	require.NoError(t, err)
+	ctx := ContextWithBlobCache(context.Background(), cache)
+
+	layer := &countingLayer{Layer: tarLayer(t, map[string]string{
+		"etc/os-release": "ID=test\n",
+		"proc/1/status":  "running",
+	})}
+	v1Img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+
+	diffID, err := layer.DiffID()
+	require.NoError(t, err)
+
+	// the first read populates the cache...
+	img := New(v1Img, nil, t.TempDir())
+	require.NoError(t, img.ReadContext(ctx))
+	assert.Equal(t, 1, layer.reads)
+	assert.True(t, cache.Has(diffID.String()))
+	assert.True(t, cache.Has(img.Metadata.ID))
+
+	// ...such that subsequent reads never fetch the layer again
+	img = New(v1Img, nil, t.TempDir())
+	require.NoError(t, img.ReadContext(ctx))
+	assert.Equal(t, 1, layer.reads)
+	assert.True(t, img.SquashedTree().HasPath("/etc/os-release"))
+
+	// even when the layer is filtered
+	img = New(v1Img, nil, t.TempDir(), WithPathExcludes("proc/**"))
+	require.NoError(t, img.ReadContext(ctx))
+	assert.Equal(t, 1, layer.reads)
+	assert.True(t, img.SquashedTree().HasPath("/etc/os-release"))
+	assert.False(t, img.SquashedTree().HasPath("/proc/1/status"))
+
+	// the entire image can be composed from the cache
+	cached, ok := cache.Image(img.Metadata.ID)
+	require.True(t, ok)
+
+	img = New(cached, nil, t.TempDir())
+	require.NoError(t, img.ReadContext(ctx))
+	assert.Equal(t, 1, layer.reads)
+	contents, err := img.OpenPathFromSquash("/etc/os-release")
+	require.NoError(t, err)
+	b, err := io.ReadAll(contents)
+	require.NoError(t, err)
+	assert.Equal(t, "ID=test\n", string(b))
+}
+
+func TestBlobCache_Image_Missing(t *testing.T) {
+	cache, err := NewBlobCache(t.TempDir(), 0)
+	require.NoError(t, err)
+
+	rawConfig := []byte(`{"rootfs":{"type":"layers","diff_ids":["sha256:` + strings.Repeat("a", 64) + `"]}}`)
+	configDigest, _, err := v1.SHA256(bytes.NewReader(rawConfig))
+	require.NoError(t, err)
+
+	_, ok := cache.Image(configDigest.String())
+	assert.False(t, ok, "config is not cached")
+
+	require.NoError(t, cache.Put(configDigest.String(), bytes.NewReader(rawConfig)))
+	_, ok = cache.Image(configDigest.String())
+	assert.False(t, ok, "layer is not cached")
+
+	var nilCache *BlobCache
+	_, ok = nilCache.Image(configDigest.String())
+	assert.False(t, ok)
+}
+
+func TestBlobCache_Put(t *testing.T) {
+	cache, err := NewBlobCache(t.TempDir(), 0)
+	require.NoError(t, err)
+
+	contents := []byte("contents")
+	digest, _, err := v1.SHA256(bytes.NewReader(contents))
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Put(digest.String(), bytes.NewReader(contents)))
+	got, err := cache.Get(digest.String())
+	require.NoError(t, err)
+	assert.Equal(t, contents, got)
+
+	// contents are verified
+	other := "sha256:" + strings.Repeat("b", 64)
+	require.ErrorContains(t, cache.Put(other, bytes.NewReader(contents)), "digest mismatch")
+	assert.False(t, cache.Has(other))
+
+	// digests are validated (never used as arbitrary paths)
+	require.Error(t, cache.Put("sha256:../../etc/passwd", bytes.NewReader(contents)))
+	assert.False(t, cache.Has("sha256:../../etc/passwd"))
+
+	// linked blobs are independent of the cache
+	dest := filepath.Join(t.TempDir(), "blob")
+	require.NoError(t, cache.Link(digest.String(), dest))
+	assert.FileExists(t, dest)
+}
+
+func TestBlobCache_Eviction(t *testing.T) {
+	cache, err := NewBlobCache(t.TempDir(), 25)
+	require.NoError(t, err)
+
+	var digests []string
+	for i := 0; i < 3; i++ {
+		contents := []byte(fmt.Sprintf("contents of blob %d", i))
+		digest, _, err := v1.SHA256(bytes.NewReader(contents))
+		require.NoError(t, err)
+		digests = append(digests, digest.String())
+
+		require.NoError(t, cache.Put(digest.String(), bytes.NewReader(contents)))
+
+		// ensure a distinct (and stable) order of use
+		p, err := cache.path(digest.String())
+		require.NoError(t, err)
+		when := time.Now().Add(time.Duration(i-10) * time.Minute)
+		require.NoError(t, os.Chtimes(p, when, when))
+	}
+
+	// each blob is 18 bytes, thus only the most recently added blob remains
+	assert.False(t, cache.Has(digests[0]))
+	assert.False(t, cache.Has(digests[1]))
+	assert.True(t, cache.Has(digests[2]))
+
+	size, err := cache.Size()
+	require.NoError(t, err)
+	assert.Equal(t, int64(18), size)
+}
+
+func TestNewBlobCache_InvalidConfig(t *testing.T) {
+	_, err := NewBlobCache("", 0)
+	require.Error(t, err)
+
+	_, err = NewBlobCache(t.TempDir(), -1)
+	require.Error(t, err)
+}
@@ -0,0 +1,172 @@
+package image
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+const (
+	buildpacksLabelPrefix          = "io.buildpacks."
+	buildpacksBuildMetadataLabel   = "io.buildpacks.build.metadata"
+	buildpacksLifecycleLabel       = "io.buildpacks.lifecycle.metadata"
+	buildpacksProjectMetadataLabel = "io.buildpacks.project.metadata"
+	buildpacksStackIDLabel         = "io.buildpacks.stack.id"
+
+	ociBaseNameAnnotation   = "org.opencontainers.image.base.name"
+	ociBaseDigestAnnotation = "org.opencontainers.image.base.digest"
+
+	koDataPathEnv = "KO_DATA_PATH"
+)
+
+// reproducibleEpochs are the fixed creation times used by reproducible image builders (ko uses the unix epoch while
+// the buildpacks lifecycle uses 1980-01-01, the earliest time representable in a zip archive).
+var reproducibleEpochs = []time.Time{
+	time.Unix(0, 0).UTC(),
+	time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC),
+	time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC),
+}
+
+// BuildpacksMetadata is the metadata found on images built by Cloud Native Buildpacks (from io.buildpacks.* labels).
+type BuildpacksMetadata struct {
+	// Labels contains all raw io.buildpacks.* labels
+	Labels map[string]string
+	// StackID is the stack the image was built on (deprecated by the platform spec, but still commonly set)
+	StackID string
+	// Buildpacks are the buildpacks that contributed to the image
+	Buildpacks []Buildpack
+	// Processes are the process types that the launcher may run
+	Processes []BuildpackProcess
+	// RunImage is the reference to the run image (base image) the app layers were placed on
+	RunImage string
+	// SBOMLayerDigest is the diff ID of the layer containing the buildpack-provided SBOMs (if any)
+	SBOMLayerDigest string
+}
+
+// Buildpack is a single buildpack that contributed to an image.
+type Buildpack struct {
+	ID       string `json:"id"`
+	Version  string `json:"version"`
+	Homepage string `json:"homepage,omitempty"`
+}
+
+// BuildpackProcess is a single process type defined by a buildpack.
+type BuildpackProcess struct {
+	Type    string   `json:"type"`
+	Command any      `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	Direct  bool     `json:"direct"`
+	Default bool     `json:"default,omitempty"`
+}
+
+// KoMetadata is the metadata found on images built by ko.
+type KoMetadata struct {
+	// DataPath is the directory within the image that contains the kodata of the application
+	DataPath string
+	// BaseImageName is the reference of the base image the application was placed on (from manifest annotations)
+	BaseImageName string
+	// BaseImageDigest is the manifest digest of the base image the application was placed on (from manifest annotations)
+	BaseImageDigest string
+}
+
+// ReproducibleTimestamps indicates that the image creation time is a fixed epoch used by reproducible builders (e.g.
+// ko and buildpacks) and does not reflect when the image was actually built.
+func (m Metadata) ReproducibleTimestamps() bool {
+	return isReproducibleEpoch(m.Config.Created.Time)
+}
+
+func readBuildpacksMetadata(config v1.ConfigFile) *BuildpacksMetadata {
+	labels := make(map[string]string)
+	for k, v := range config.Config.Labels {
+		if strings.HasPrefix(k, buildpacksLabelPrefix) {
+			labels[k] = v
+		}
+	}
+
+	if len(labels) == 0 {
+		return nil
+	}
+
+	m := &BuildpacksMetadata{
+		Labels:  labels,
+		StackID: labels[buildpacksStackIDLabel],
+	}
+
+	if raw, ok := labels[buildpacksBuildMetadataLabel]; ok {
+		var build struct {
+			Buildpacks []Buildpack        `json:"buildpacks"`
+			Processes  []BuildpackProcess `json:"processes"`
+		}
+		if err := json.Unmarshal([]byte(raw), &build); err != nil {
+			log.WithFields("label", buildpacksBuildMetadataLabel, "error", err).Debug("unable to parse buildpacks label")
+		} else {
+			m.Buildpacks = build.Buildpacks
+			m.Processes = build.Processes
+		}
+	}
+
+	if raw, ok := labels[buildpacksLifecycleLabel]; ok {
+		var lifecycle struct {
+			RunImage struct {
+				Reference string `json:"reference"`
+				Image     string `json:"image"`
+			} `json:"runImage"`
+			Stack struct {
+				RunImage struct {
+					Image string `json:"image"`
+				} `json:"runImage"`
+			} `json:"stack"`
+			SBOM *struct {
+				SHA string `json:"sha"`
+			} `json:"sbom"`
+		}
+		if err := json.Unmarshal([]byte(raw), &lifecycle); err != nil {
+			log.WithFields("label", buildpacksLifecycleLabel, "error", err).Debug("unable to parse buildpacks label")
+		} else {
+			m.RunImage = firstNonEmpty(lifecycle.RunImage.Image, lifecycle.RunImage.Reference, lifecycle.Stack.RunImage.Image)
+			if lifecycle.SBOM != nil {
+				m.SBOMLayerDigest = lifecycle.SBOM.SHA
+			}
+		}
+	}
+
+	return m
+}
+
+func readKoMetadata(config v1.ConfigFile, manifest *v1.Manifest) *KoMetadata {
+	var dataPath string
+	for _, env := range config.Config.Env {
+		if k, v, ok := strings.Cut(env, "="); ok && k == koDataPathEnv {
+			dataPath = v
+		}
+	}
+
+	// note: the base image annotations are not exclusive to ko, thus are only considered for images with ko data
+	if dataPath == "" {
+		return nil
+	}
+
+	m := &KoMetadata{
+		DataPath: dataPath,
+	}
+
+	if manifest != nil {
+		m.BaseImageName = manifest.Annotations[ociBaseNameAnnotation]
+		m.BaseImageDigest = manifest.Annotations[ociBaseDigestAnnotation]
+	}
+
+	return m
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
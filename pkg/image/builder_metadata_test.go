@@ -0,0 +1,132 @@
+package image
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_readBuildpacksMetadata(t *testing.T) {
+	config := v1.ConfigFile{
+		Config: v1.Config{
+			Labels: map[string]string{
+				"io.buildpacks.stack.id":           "io.buildpacks.stacks.jammy",
+				"io.buildpacks.build.metadata":     `{"buildpacks":[{"id":"paketo-buildpacks/go","version":"4.0.0"}],"processes":[{"type":"web","command":"/layers/app","direct":true,"default":true}]}`,
+				"io.buildpacks.lifecycle.metadata": `{"runImage":{"reference":"index.docker.io/paketobuildpacks/run@sha256:abc"},"sbom":{"sha":"sha256:def"}}`,
+				"org.opencontainers.image.title":   "not-a-buildpacks-label",
+			},
+		},
+	}
+
+	got := readBuildpacksMetadata(config)
+	require.NotNil(t, got)
+	assert.Len(t, got.Labels, 3)
+	assert.Equal(t, "io.buildpacks.stacks.jammy", got.StackID)
+	assert.Equal(t, []Buildpack{{ID: "paketo-buildpacks/go", Version: "4.0.0"}}, got.Buildpacks)
+	require.Len(t, got.Processes, 1)
+	assert.Equal(t, "web", got.Processes[0].Type)
+	assert.Equal(t, "index.docker.io/paketobuildpacks/run@sha256:abc", got.RunImage)
+	assert.Equal(t, "sha256:def", got.SBOMLayerDigest)
+
+	assert.Nil(t, readBuildpacksMetadata(v1.ConfigFile{}))
+}
+
+func Test_readKoMetadata(t *testing.T) {
+	config := v1.ConfigFile{
+		Config: v1.Config{
+			Env: []string{"PATH=/usr/bin", "KO_DATA_PATH=/var/run/ko"},
+		},
+	}
+	manifest := &v1.Manifest{
+		Annotations: map[string]string{
+			"org.opencontainers.image.base.name":   "cgr.dev/chainguard/static:latest",
+			"org.opencontainers.image.base.digest": "sha256:abc",
+		},
+	}
+
+	assert.Equal(t, &KoMetadata{
+		DataPath:        "/var/run/ko",
+		BaseImageName:   "cgr.dev/chainguard/static:latest",
+		BaseImageDigest: "sha256:abc",
+	}, readKoMetadata(config, manifest))
+
+	assert.Nil(t, readKoMetadata(v1.ConfigFile{}, manifest))
+}
+
+func TestMetadata_ReproducibleTimestamps(t *testing.T) {
+	tests := []struct {
+		created time.Time
+		want    bool
+	}{
+		{created: time.Time{}, want: true},
+		{created: time.Unix(0, 0), want: true},
+		{created: time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC), want: true},
+		{created: time.Date(2023, time.March, 4, 5, 6, 7, 0, time.UTC), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.created.String(), func(t *testing.T) {
+			m := Metadata{Config: v1.ConfigFile{Created: v1.Time{Time: tt.created}}}
+			assert.Equal(t, tt.want, m.ReproducibleTimestamps())
+		})
+	}
+}
+
+func TestImage_Read_DuplicateLayers(t *testing.T) {
+	// buildpacks images may contain the same (metadata) layer more than once
+	layer, err := random.Layer(64, types.DockerLayer)
+	require.NoError(t, err)
+
+	v1Img, err := mutate.AppendLayers(empty.Image, layer, layer)
+	require.NoError(t, err)
+
+	img := New(v1Img, nil, t.TempDir())
+	require.NoError(t, img.Read())
+	assert.Len(t, img.Layers, 2)
+	assert.Equal(t, img.Layers[0].Metadata.Digest, img.Layers[1].Metadata.Digest)
+}
+
+func TestImage_Read_DuplicateEmptyLayers(t *testing.T) {
+	// ko and buildpacks images may repeat the same empty (metadata) layer, which must still be matched with the
+	// history entry at its own position
+	emptyLayer := tarLayer(t, nil)
+	v1Img, err := mutate.AppendLayers(empty.Image,
+		tarLayer(t, map[string]string{"etc/os-release": "ID=test\n"}),
+		emptyLayer,
+		tarLayer(t, map[string]string{"app": "payload"}),
+		emptyLayer,
+	)
+	require.NoError(t, err)
+
+	cfg, err := v1Img.ConfigFile()
+	require.NoError(t, err)
+	cfg = cfg.DeepCopy()
+	cfg.History = []v1.History{
+		{CreatedBy: "ADD rootfs.tar /"},
+		{CreatedBy: "ENV PATH=/bin", EmptyLayer: true},
+		{CreatedBy: "RUN mkdir -p /workspace"},
+		{CreatedBy: "COPY app /app"},
+		{CreatedBy: "LABEL io.buildpacks.stack.id=test", EmptyLayer: true},
+		{CreatedBy: "RUN mkdir -p /layers"},
+	}
+	v1Img, err = mutate.ConfigFile(v1Img, cfg)
+	require.NoError(t, err)
+
+	img := New(v1Img, nil, t.TempDir())
+	require.NoError(t, img.Read())
+	require.Len(t, img.Layers, 4)
+	require.Equal(t, img.Layers[1].Metadata.Digest, img.Layers[3].Metadata.Digest)
+
+	wantHistory := []int{0, 2, 3, 5}
+	for idx, layer := range img.Layers {
+		assert.Equal(t, wantHistory[idx], layer.Metadata.HistoryIndex, "layer %d", idx)
+		assert.Equal(t, cfg.History[wantHistory[idx]].CreatedBy, layer.Metadata.CreatedBy, "layer %d", idx)
+		assert.Equal(t, idx, img.Metadata.History[layer.Metadata.HistoryIndex].LayerIndex, "layer %d", idx)
+	}
+}
@@ -0,0 +1,321 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+const (
+	// BundleConfigFile is the name of the runtime configuration within an OCI runtime bundle.
+	BundleConfigFile = "config.json"
+	// BundleRootfsDir is the name of the root filesystem directory within an OCI runtime bundle.
+	BundleRootfsDir = "rootfs"
+)
+
+// defaultBundleCapabilities are the capabilities of the bundle process, matching the defaults of container runtimes
+// (e.g. docker and containerd).
+var defaultBundleCapabilities = []string{
+	"CAP_CHOWN",
+	"CAP_DAC_OVERRIDE",
+	"CAP_FSETID",
+	"CAP_FOWNER",
+	"CAP_MKNOD",
+	"CAP_NET_RAW",
+	"CAP_SETGID",
+	"CAP_SETUID",
+	"CAP_SETFCAP",
+	"CAP_SETPCAP",
+	"CAP_NET_BIND_SERVICE",
+	"CAP_SYS_CHROOT",
+	"CAP_KILL",
+	"CAP_AUDIT_WRITE",
+}
+
+// BundleOptions configures the OCI runtime bundle written by Image.ExportBundle.
+type BundleOptions struct {
+	// Unpack configures how the root filesystem is unpacked. When ID mappings are given the bundle runs within a user
+	// namespace with the same mappings (e.g. for rootless runtimes).
+	Unpack UnpackOptions
+	// Args overrides the process arguments (the entrypoint followed by the cmd of the image config when empty)
+	Args []string
+	// Env is appended to the environment of the image config (overriding variables of the same name)
+	Env []string
+	// Terminal attaches a terminal to the process (e.g. for "runc run" from an interactive shell)
+	Terminal bool
+	// Network shares the network namespace of the host instead of running within an isolated (and unconfigured)
+	// network namespace
+	Network bool
+	// ReadonlyRootfs mounts the root filesystem read-only
+	ReadonlyRootfs bool
+	// Hostname is the hostname within the container (the short image ID when empty)
+	Hostname string
+}
+
+// ExportBundle writes an OCI runtime bundle for the image to the given destination directory: the squashed filesystem
+// unpacked to the "rootfs" directory (see UnpackRootfs), and a "config.json" runtime configuration derived from the
+// image config (see BundleSpec). The bundle can be run directly with an OCI runtime (e.g. "runc run -b <dest> <id>").
+func (i *Image) ExportBundle(dest string, opts BundleOptions) error {
+	spec, err := i.BundleSpec(opts)
+	if err != nil {
+		return err
+	}
+
+	rootfs := filepath.Join(dest, BundleRootfsDir)
+	if entries, err := os.ReadDir(rootfs); err == nil && len(entries) > 0 {
+		return fmt.Errorf("bundle rootfs %q is not empty", rootfs)
+	}
+
+	if err := i.UnpackRootfs(rootfs, opts.Unpack); err != nil {
+		return fmt.Errorf("unable to unpack bundle rootfs: %w", err)
+	}
+
+	contents, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode bundle config: %w", err)
+	}
+	return os.WriteFile(filepath.Join(dest, BundleConfigFile), contents, 0o644)
+}
+
+// BundleSpec returns the runtime configuration of an OCI runtime bundle for the image (see ExportBundle), following
+// the conversion of the image config described by the OCI image spec: the process runs the entrypoint and cmd as the
+// configured user (resolved within the image, including supplementary groups) within the working directory and
+// environment of the config, and the config labels (as well as the platform, author, creation time, exposed ports, and
+// stop signal) are annotations. The remaining configuration matches the defaults of container runtimes.
+func (i *Image) BundleSpec(opts BundleOptions) (*specs.Spec, error) {
+	cfg := i.Metadata.Config
+	if cfg.OS != "" && cfg.OS != "linux" {
+		return nil, fmt.Errorf("runtime bundles are only supported for linux images (image os=%q)", cfg.OS)
+	}
+
+	args := opts.Args
+	if len(args) == 0 {
+		args = append(append([]string{}, cfg.Config.Entrypoint...), cfg.Config.Cmd...)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("image config has no entrypoint or cmd (args must be given)")
+	}
+
+	db, err := i.UserDatabase()
+	if err != nil {
+		return nil, err
+	}
+	uid, gid, err := db.Resolve(cfg.Config.User)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve image user: %w", err)
+	}
+
+	cwd := cfg.Config.WorkingDir
+	if cwd == "" {
+		cwd = "/"
+	}
+
+	hostname := opts.Hostname
+	if hostname == "" {
+		hostname = strings.TrimPrefix(i.Metadata.ID, "sha256:")
+		hostname = hostname[:min(len(hostname), 12)]
+	}
+
+	spec := &specs.Spec{
+		Version: specs.Version,
+		Process: &specs.Process{
+			Terminal: opts.Terminal,
+			User: specs.User{
+				UID:            uint32(uid),
+				GID:            uint32(gid),
+				AdditionalGids: supplementaryGroups(db, uid, gid),
+			},
+			Args: args,
+			Env:  bundleEnv(db, uid, cfg.Config.Env, opts),
+			Cwd:  cwd,
+			Capabilities: &specs.LinuxCapabilities{
+				Bounding:  defaultBundleCapabilities,
+				Effective: defaultBundleCapabilities,
+				Permitted: defaultBundleCapabilities,
+			},
+			Rlimits: []specs.POSIXRlimit{
+				{Type: "RLIMIT_NOFILE", Hard: 1024, Soft: 1024},
+			},
+			NoNewPrivileges: true,
+		},
+		Root: &specs.Root{
+			Path:     BundleRootfsDir,
+			Readonly: opts.ReadonlyRootfs,
+		},
+		Hostname:    hostname,
+		Mounts:      defaultBundleMounts(),
+		Annotations: bundleAnnotations(i.Metadata),
+		Linux: &specs.Linux{
+			Namespaces: bundleNamespaces(opts),
+			Resources: &specs.LinuxResources{
+				Devices: []specs.LinuxDeviceCgroup{{Allow: false, Access: "rwm"}},
+			},
+			MaskedPaths: []string{
+				"/proc/acpi",
+				"/proc/asound",
+				"/proc/kcore",
+				"/proc/keys",
+				"/proc/latency_stats",
+				"/proc/timer_list",
+				"/proc/timer_stats",
+				"/proc/sched_debug",
+				"/proc/scsi",
+				"/sys/firmware",
+			},
+			ReadonlyPaths: []string{
+				"/proc/bus",
+				"/proc/fs",
+				"/proc/irq",
+				"/proc/sys",
+				"/proc/sysrq-trigger",
+			},
+		},
+	}
+
+	if len(opts.Unpack.UIDMappings) > 0 || len(opts.Unpack.GIDMappings) > 0 {
+		spec.Linux.UIDMappings = bundleIDMappings(opts.Unpack.UIDMappings)
+		spec.Linux.GIDMappings = bundleIDMappings(opts.Unpack.GIDMappings)
+	}
+
+	return spec, nil
+}
+
+// bundleEnv returns the process environment: the config environment (with the default PATH and the HOME of the user
+// when not set) followed by the given overrides.
+func bundleEnv(db *UserDatabase, uid int, configEnv []string, opts BundleOptions) []string {
+	var env []string
+	set := func(entry string) {
+		name, _, _ := strings.Cut(entry, "=")
+		env = slices.DeleteFunc(env, func(e string) bool {
+			n, _, _ := strings.Cut(e, "=")
+			return n == name
+		})
+		env = append(env, entry)
+	}
+
+	for _, entry := range configEnv {
+		set(entry)
+	}
+	if _, ok := lookupConfigEnv(env, "PATH"); !ok {
+		set("PATH=" + defaultExecPath)
+	}
+	if _, ok := lookupConfigEnv(env, "HOME"); !ok {
+		home := "/"
+		if user := db.UserByID(uid); user != nil && user.Home != "" {
+			home = user.Home
+		}
+		set("HOME=" + home)
+	}
+	if opts.Terminal {
+		if _, ok := lookupConfigEnv(env, "TERM"); !ok {
+			set("TERM=xterm")
+		}
+	}
+	for _, entry := range opts.Env {
+		set(entry)
+	}
+	return env
+}
+
+// supplementaryGroups returns the groups the user is a member of (other than the primary group).
+func supplementaryGroups(db *UserDatabase, uid, gid int) []uint32 {
+	user := db.UserByID(uid)
+	if user == nil {
+		return nil
+	}
+
+	var gids []uint32
+	for _, group := range db.Groups {
+		if group.GID == gid || !slices.Contains(group.Members, user.Name) || slices.Contains(gids, uint32(group.GID)) {
+			continue
+		}
+		gids = append(gids, uint32(group.GID))
+	}
+	return gids
+}
+
+// bundleAnnotations returns the annotations of the image config as described by the conversion of the OCI image spec.
+func bundleAnnotations(metadata Metadata) map[string]string {
+	cfg := metadata.Config
+	annotations := make(map[string]string)
+	for k, v := range cfg.Config.Labels {
+		annotations[k] = v
+	}
+
+	set := func(key, value string) {
+		if value != "" {
+			annotations[key] = value
+		}
+	}
+	set("org.opencontainers.image.os", cfg.OS)
+	set("org.opencontainers.image.architecture", cfg.Architecture)
+	set("org.opencontainers.image.variant", cfg.Variant)
+	set("org.opencontainers.image.os.version", cfg.OSVersion)
+	set("org.opencontainers.image.author", cfg.Author)
+	set("org.opencontainers.image.stopSignal", cfg.Config.StopSignal)
+	if !cfg.Created.IsZero() {
+		set("org.opencontainers.image.created", cfg.Created.UTC().Format(time.RFC3339))
+	}
+	if len(cfg.Config.ExposedPorts) > 0 {
+		var ports []string
+		for port := range cfg.Config.ExposedPorts {
+			ports = append(ports, port)
+		}
+		sort.Strings(ports)
+		set("org.opencontainers.image.exposedPorts", strings.Join(ports, ","))
+	}
+
+	if len(annotations) == 0 {
+		return nil
+	}
+	return annotations
+}
+
+func bundleNamespaces(opts BundleOptions) []specs.LinuxNamespace {
+	namespaces := []specs.LinuxNamespace{
+		{Type: specs.PIDNamespace},
+		{Type: specs.IPCNamespace},
+		{Type: specs.UTSNamespace},
+		{Type: specs.MountNamespace},
+		{Type: specs.CgroupNamespace},
+	}
+	if !opts.Network {
+		namespaces = append(namespaces, specs.LinuxNamespace{Type: specs.NetworkNamespace})
+	}
+	if len(opts.Unpack.UIDMappings) > 0 || len(opts.Unpack.GIDMappings) > 0 {
+		namespaces = append(namespaces, specs.LinuxNamespace{Type: specs.UserNamespace})
+	}
+	return namespaces
+}
+
+func bundleIDMappings(mappings []IDMapping) []specs.LinuxIDMapping {
+	var out []specs.LinuxIDMapping
+	for _, m := range mappings {
+		out = append(out, specs.LinuxIDMapping{
+			ContainerID: uint32(m.ContainerID),
+			HostID:      uint32(m.HostID),
+			Size:        uint32(m.Size),
+		})
+	}
+	return out
+}
+
+// defaultBundleMounts are the mounts of the bundle, matching the defaults of container runtimes.
+func defaultBundleMounts() []specs.Mount {
+	return []specs.Mount{
+		{Destination: "/proc", Type: "proc", Source: "proc", Options: []string{"nosuid", "noexec", "nodev"}},
+		{Destination: "/dev", Type: "tmpfs", Source: "tmpfs", Options: []string{"nosuid", "strictatime", "mode=755", "size=65536k"}},
+		{Destination: "/dev/pts", Type: "devpts", Source: "devpts", Options: []string{"nosuid", "noexec", "newinstance", "ptmxmode=0666", "mode=0620", "gid=5"}},
+		{Destination: "/dev/shm", Type: "tmpfs", Source: "shm", Options: []string{"nosuid", "noexec", "nodev", "mode=1777", "size=65536k"}},
+		{Destination: "/dev/mqueue", Type: "mqueue", Source: "mqueue", Options: []string{"nosuid", "noexec", "nodev"}},
+		{Destination: "/sys", Type: "sysfs", Source: "sysfs", Options: []string{"nosuid", "noexec", "nodev", "ro"}},
+		{Destination: "/sys/fs/cgroup", Type: "cgroup", Source: "cgroup", Options: []string{"nosuid", "noexec", "nodev", "relatime", "ro"}},
+	}
+}
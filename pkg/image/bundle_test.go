@@ -0,0 +1,168 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_ExportBundle(t *testing.T) {
+	img := newBundleTestImage(t, v1.Config{
+		User:         "app",
+		Entrypoint:   []string{"/app/server"},
+		Cmd:          []string{"--port", "8080"},
+		Env:          []string{"PATH=/app:/usr/bin", "MODE=prod"},
+		WorkingDir:   "/app",
+		Labels:       map[string]string{"org.example.team": "scanning"},
+		ExposedPorts: map[string]struct{}{"8080/tcp": {}, "443/tcp": {}},
+		StopSignal:   "SIGTERM",
+	})
+
+	dest := t.TempDir()
+	require.NoError(t, img.ExportBundle(dest, BundleOptions{Env: []string{"MODE=debug"}}))
+
+	contents, err := os.ReadFile(filepath.Join(dest, "rootfs", "app", "server"))
+	require.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh\n", string(contents))
+
+	raw, err := os.ReadFile(filepath.Join(dest, "config.json"))
+	require.NoError(t, err)
+	var spec specs.Spec
+	require.NoError(t, json.Unmarshal(raw, &spec))
+
+	assert.Equal(t, specs.Version, spec.Version)
+	assert.Equal(t, "rootfs", spec.Root.Path)
+	assert.Equal(t, []string{"/app/server", "--port", "8080"}, spec.Process.Args)
+	assert.Equal(t, "/app", spec.Process.Cwd)
+	assert.Equal(t, specs.User{UID: 1000, GID: 1000, AdditionalGids: []uint32{10}}, spec.Process.User)
+	assert.Equal(t, []string{"PATH=/app:/usr/bin", "HOME=/home/app", "MODE=debug"}, spec.Process.Env)
+	assert.Equal(t, "scanning", spec.Annotations["org.example.team"])
+	assert.Equal(t, "443/tcp,8080/tcp", spec.Annotations["org.opencontainers.image.exposedPorts"])
+	assert.Equal(t, "SIGTERM", spec.Annotations["org.opencontainers.image.stopSignal"])
+	assert.Contains(t, spec.Linux.Namespaces, specs.LinuxNamespace{Type: specs.NetworkNamespace})
+	assert.Empty(t, spec.Linux.UIDMappings)
+
+	// the rootfs is never written over
+	require.ErrorContains(t, img.ExportBundle(dest, BundleOptions{}), "is not empty")
+}
+
+func TestImage_BundleSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  v1.Config
+		opts    BundleOptions
+		want    func(t *testing.T, spec *specs.Spec)
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:   "root user with default environment",
+			config: v1.Config{Cmd: []string{"sh"}},
+			opts:   BundleOptions{Terminal: true, Network: true},
+			want: func(t *testing.T, spec *specs.Spec) {
+				assert.Equal(t, specs.User{}, spec.Process.User)
+				assert.Equal(t, "/", spec.Process.Cwd)
+				assert.Equal(t, []string{"PATH=" + defaultExecPath, "HOME=/root", "TERM=xterm"}, spec.Process.Env)
+				assert.NotContains(t, spec.Linux.Namespaces, specs.LinuxNamespace{Type: specs.NetworkNamespace})
+			},
+		},
+		{
+			name:   "args override the config",
+			config: v1.Config{Entrypoint: []string{"/app/server"}},
+			opts:   BundleOptions{Args: []string{"/bin/sh", "-c", "id"}},
+			want: func(t *testing.T, spec *specs.Spec) {
+				assert.Equal(t, []string{"/bin/sh", "-c", "id"}, spec.Process.Args)
+			},
+		},
+		{
+			name:   "rootless mappings",
+			config: v1.Config{Cmd: []string{"sh"}},
+			opts: BundleOptions{Unpack: UnpackOptions{
+				UIDMappings: []IDMapping{{ContainerID: 0, HostID: 1000, Size: 1}},
+				GIDMappings: []IDMapping{{ContainerID: 0, HostID: 1000, Size: 1}},
+			}},
+			want: func(t *testing.T, spec *specs.Spec) {
+				assert.Contains(t, spec.Linux.Namespaces, specs.LinuxNamespace{Type: specs.UserNamespace})
+				assert.Equal(t, []specs.LinuxIDMapping{{ContainerID: 0, HostID: 1000, Size: 1}}, spec.Linux.UIDMappings)
+				assert.Equal(t, []specs.LinuxIDMapping{{ContainerID: 0, HostID: 1000, Size: 1}}, spec.Linux.GIDMappings)
+			},
+		},
+		{
+			name:    "no command",
+			config:  v1.Config{},
+			wantErr: require.Error,
+		},
+		{
+			name:    "unknown user",
+			config:  v1.Config{User: "missing", Cmd: []string{"sh"}},
+			wantErr: require.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+
+			spec, err := newBundleTestImage(t, tt.config).BundleSpec(tt.opts)
+			tt.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			tt.want(t, spec)
+		})
+	}
+}
+
+// newBundleTestImage creates a single layer image with the given config, an application user (within a supplementary
+// group), and an executable at /app/server.
+func newBundleTestImage(t *testing.T, cfg v1.Config) *Image {
+	t.Helper()
+
+	files := []struct {
+		header   tar.Header
+		contents string
+	}{
+		{header: tar.Header{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0755}},
+		{header: tar.Header{Name: "etc/passwd", Typeflag: tar.TypeReg, Mode: 0644}, contents: "root:x:0:0:root:/root:/bin/sh\napp:x:1000:1000::/home/app:/bin/sh\n"},
+		{header: tar.Header{Name: "etc/group", Typeflag: tar.TypeReg, Mode: 0644}, contents: "root:x:0:\nwheel:x:10:app\napp:x:1000:app\n"},
+		{header: tar.Header{Name: "app/", Typeflag: tar.TypeDir, Mode: 0755}},
+		{header: tar.Header{Name: "app/server", Typeflag: tar.TypeReg, Mode: 0755}, contents: "#!/bin/sh\n"},
+	}
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, f := range files {
+		h := f.header
+		h.Size = int64(len(f.contents))
+		require.NoError(t, tw.WriteHeader(&h))
+		_, err := tw.Write([]byte(f.contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	})
+	require.NoError(t, err)
+
+	v1Img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	v1Img, err = mutate.Config(v1Img, cfg)
+	require.NoError(t, err)
+
+	img := New(v1Img, nil, t.TempDir())
+	require.NoError(t, img.Read())
+	return img
+}
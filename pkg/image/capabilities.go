@@ -0,0 +1,117 @@
+package image
+
+import (
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// InputKind is the kind of user input an image source accepts.
+type InputKind string
+
+const (
+	// PathInput is a file or directory on the local filesystem (optionally followed by a "#" or "@" image selector)
+	PathInput InputKind = "path"
+	// ReferenceInput is an image reference, image ID, or container ID resolved by a daemon or registry
+	ReferenceInput InputKind = "reference"
+)
+
+// registry authentication methods used by image sources when pulling images
+const (
+	// BasicAuth is a username and password (see RegistryCredentials)
+	BasicAuth = "basic"
+	// TokenAuth is a bearer token (see RegistryCredentials)
+	TokenAuth = "token"
+	// KeychainAuth is the docker config file and the credential helpers configured within it
+	KeychainAuth = "keychain"
+	// ClientCertAuth is a TLS client certificate (see RegistryCredentials)
+	ClientCertAuth = "client-cert"
+)
+
+// registryAuthMethods are the authentication methods of sources using RegistryOptions to pull images.
+var registryAuthMethods = []string{BasicAuth, TokenAuth, KeychainAuth, ClientCertAuth}
+
+// identifierPattern matches image and container IDs (which are not always valid image references, e.g. uppercase
+// container names).
+var identifierPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Capabilities describes the features supported by an image provider (see ProviderCapabilities).
+type Capabilities struct {
+	// Source is the source of the provider
+	Source Source
+	// Input is the kind of user input the provider accepts (unknown when empty)
+	Input InputKind
+	// Platform indicates the provider selects the image for a requested platform (see PlatformCapability)
+	Platform bool
+	// PullOnMissing indicates the provider pulls images missing from the store (see PullOnMissingCapability)
+	PullOnMissing bool
+	// Offline indicates the provider is able to provide images without network access (see OfflineCapability)
+	Offline bool
+	// Digest indicates content digests are verified while the image is read (see DigestCapability)
+	Digest bool
+	// Auth are the registry authentication methods used when pulling images (e.g. BasicAuth)
+	Auth []string
+}
+
+// CapabilityReporter is implemented by providers that report their own capabilities, instead of the capabilities of
+// their registered source (see ProviderCapabilities).
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// ProviderCapabilities returns the capabilities of the given provider, as reported by the provider itself (see
+// CapabilityReporter) or as registered for the source of the same name (see RegisterSource). False is returned when
+// the capabilities of the provider are unknown.
+func ProviderCapabilities(provider Provider) (Capabilities, bool) {
+	if reporter, ok := provider.(CapabilityReporter); ok {
+		return reporter.Capabilities(), true
+	}
+
+	info, ok := LookupSource(provider.Name())
+	if !ok {
+		return Capabilities{}, false
+	}
+	return Capabilities{
+		Source:        info.Source,
+		Input:         info.Input,
+		Platform:      info.HasCapabilities(PlatformCapability),
+		PullOnMissing: info.HasCapabilities(PullOnMissingCapability),
+		Offline:       info.HasCapabilities(OfflineCapability),
+		Digest:        info.HasCapabilities(DigestCapability),
+		Auth:          slices.Clone(info.Auth),
+	}, true
+}
+
+// Accepts indicates the given user input could possibly be handled by a provider with these capabilities: path inputs
+// must exist on the local filesystem (ignoring any image selector suffix), and reference inputs must be a valid image
+// reference or ID. This is a fast check before attempting a provider, where providers may still fail to provide an
+// image for inputs that are accepted.
+func (c Capabilities) Accepts(input string) bool {
+	switch c.Input {
+	case PathInput:
+		if pathExists(input) {
+			return true
+		}
+		for _, sep := range []string{"#", "@"} {
+			if idx := strings.LastIndex(input, sep); idx > 0 && pathExists(input[:idx]) {
+				return true
+			}
+		}
+		return false
+	case ReferenceInput:
+		if _, err := name.ParseReference(input); err == nil {
+			return true
+		}
+		return identifierPattern.MatchString(input)
+	default:
+		return true
+	}
+}
+
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
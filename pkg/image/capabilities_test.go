@@ -0,0 +1,120 @@
+package image
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type namedProvider string
+
+func (p namedProvider) Name() string {
+	return string(p)
+}
+
+func (p namedProvider) Provide(context.Context) (*Image, error) {
+	return nil, nil
+}
+
+type reportingProvider struct {
+	namedProvider
+}
+
+func (p reportingProvider) Capabilities() Capabilities {
+	return Capabilities{Source: "custom", Input: PathInput}
+}
+
+func TestProviderCapabilities(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider Provider
+		want     Capabilities
+		wantOK   bool
+	}{
+		{
+			name:     "registry",
+			provider: namedProvider(OciRegistrySource),
+			want: Capabilities{
+				Source:   OciRegistrySource,
+				Input:    ReferenceInput,
+				Platform: true,
+				Digest:   true,
+				Auth:     []string{BasicAuth, TokenAuth, KeychainAuth, ClientCertAuth},
+			},
+			wantOK: true,
+		},
+		{
+			name:     "docker daemon",
+			provider: namedProvider(DockerDaemonSource),
+			want: Capabilities{
+				Source:        DockerDaemonSource,
+				Input:         ReferenceInput,
+				Platform:      true,
+				PullOnMissing: true,
+				Offline:       true,
+				Auth:          []string{KeychainAuth},
+			},
+			wantOK: true,
+		},
+		{
+			name:     "archive",
+			provider: namedProvider(SingularitySource),
+			want: Capabilities{
+				Source:  SingularitySource,
+				Input:   PathInput,
+				Offline: true,
+			},
+			wantOK: true,
+		},
+		{
+			name:     "reported by the provider",
+			provider: reportingProvider{namedProvider: "custom"},
+			want:     Capabilities{Source: "custom", Input: PathInput},
+			wantOK:   true,
+		},
+		{
+			name:     "unknown source",
+			provider: namedProvider("unknown"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ProviderCapabilities(tt.provider)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCapabilities_Accepts(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "image.tar")
+	require.NoError(t, os.WriteFile(archive, nil, 0o600))
+
+	tests := []struct {
+		name  string
+		input InputKind
+		value string
+		want  bool
+	}{
+		{name: "existing file", input: PathInput, value: archive, want: true},
+		{name: "existing directory with selector", input: PathInput, value: dir + "#latest", want: true},
+		{name: "existing directory with digest", input: PathInput, value: dir + "@sha256:abc", want: true},
+		{name: "missing path", input: PathInput, value: filepath.Join(dir, "missing.tar")},
+		{name: "reference as path", input: PathInput, value: "alpine:latest"},
+		{name: "reference", input: ReferenceInput, value: "docker.io/library/alpine:latest", want: true},
+		{name: "image ID", input: ReferenceInput, value: "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", want: true},
+		{name: "container name", input: ReferenceInput, value: "MyContainer", want: true},
+		{name: "path as reference", input: ReferenceInput, value: archive},
+		{name: "unknown input kind", value: "anything", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Capabilities{Input: tt.input}.Accepts(tt.value))
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package image
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type clockKey struct{}
+
+type contentContextKey struct{}
+
+// Clock is the source of time for timed operations (e.g. progress estimation, daemon export stall detection, and the
+// deadline budget), which may be replaced to simulate time deterministically (e.g. a slow network, see ManualClock).
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls the given function (within its own goroutine, or synchronously for a ManualClock) once the
+	// duration has elapsed
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a pending call created by Clock.AfterFunc (see time.Timer).
+type Timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// SystemClock is the Clock of the system (see the time package).
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// ContextWithClock returns a context that carries the clock to use for timed operations.
+func ContextWithClock(ctx context.Context, clock Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, clock)
+}
+
+// ClockFromContext returns the clock carried by the context (the system clock when none).
+func ClockFromContext(ctx context.Context) Clock {
+	if clock, ok := ctx.Value(clockKey{}).(Clock); ok && clock != nil {
+		return clock
+	}
+	return SystemClock
+}
+
+// ContextWithTimeout returns a context that is canceled once the given duration has elapsed on the clock of the
+// context (see context.WithTimeout). When the clock is not the system clock, the context has no deadline and
+// context.Cause reports context.DeadlineExceeded once the duration has elapsed. The given context remains available
+// from the returned context (see ContentContext).
+func ContextWithTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	content := ContentContext(ctx)
+	clock := ClockFromContext(ctx)
+	if clock == SystemClock {
+		ctx, cancel := context.WithTimeout(ctx, d)
+		return context.WithValue(ctx, contentContextKey{}, content), cancel
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	timer := clock.AfterFunc(d, func() {
+		cancel(context.DeadlineExceeded)
+	})
+	return context.WithValue(ctx, contentContextKey{}, content), func() {
+		timer.Stop()
+		cancel(context.Canceled)
+	}
+}
+
+// ContentContext returns the context that reads of image contents made after the image has been provided (e.g. lazily
+// fetched file contents) are bound to: the given context without any timeout applied by ContextWithTimeout, since such
+// timeouts only bound providing the image (see WithDeadlineBudget).
+func ContentContext(ctx context.Context) context.Context {
+	if content, ok := ctx.Value(contentContextKey{}).(context.Context); ok {
+		return content
+	}
+	return ctx
+}
+
+// ManualClock is a Clock that only advances when told to (see Advance), calling the functions of all timers that are
+// due synchronously. This allows for simulating slow operations deterministically.
+type ManualClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	timers []*manualTimer
+}
+
+// NewManualClock creates a clock at the given time.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	t := &manualTimer{clock: c, f: f, when: c.now.Add(d), active: true}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by the given duration, calling the functions of all timers that became due (in the
+// order they are due) before returning.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	c.now = c.now.Add(d)
+
+	var due, pending []*manualTimer
+	for _, t := range c.timers {
+		switch {
+		case !t.active:
+			continue
+		case !t.when.After(c.now):
+			t.active = false
+			due = append(due, t)
+		default:
+			pending = append(pending, t)
+		}
+	}
+	c.timers = pending
+	c.lock.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].when.Before(due[j].when)
+	})
+	for _, t := range due {
+		t.f()
+	}
+}
+
+type manualTimer struct {
+	clock  *ManualClock
+	f      func()
+	when   time.Time
+	active bool
+}
+
+func (t *manualTimer) Stop() bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	wasActive := t.active
+	t.active = false
+	return wasActive
+}
+
+func (t *manualTimer) Reset(d time.Duration) bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	wasActive := t.active
+	t.when = t.clock.now.Add(d)
+	t.active = true
+	if !wasActive {
+		t.clock.timers = append(t.clock.timers, t)
+	}
+	return wasActive
+}
+
+// TimedProgress estimates the progress of an operation that is expected to take the given duration (in milliseconds)
+// as measured by a clock, reporting completion once the duration has elapsed or when set completed.
+type TimedProgress struct {
+	clock    Clock
+	start    time.Time
+	duration time.Duration
+	complete atomic.Bool
+}
+
+// NewTimedProgress starts estimating the progress of an operation expected to take the given duration, as measured by
+// the clock of the given context.
+func NewTimedProgress(ctx context.Context, duration time.Duration) *TimedProgress {
+	clock := ClockFromContext(ctx)
+	return &TimedProgress{
+		clock:    clock,
+		start:    clock.Now(),
+		duration: duration,
+	}
+}
+
+func (p *TimedProgress) Current() int64 {
+	if p.complete.Load() {
+		return p.duration.Milliseconds()
+	}
+	current := p.clock.Now().Sub(p.start).Milliseconds()
+	if current > p.duration.Milliseconds() {
+		p.complete.Store(true)
+		current = p.duration.Milliseconds()
+	}
+	return current
+}
+
+func (p *TimedProgress) Size() int64 {
+	return p.duration.Milliseconds()
+}
+
+func (p *TimedProgress) Error() error {
+	return nil
+}
+
+func (p *TimedProgress) SetCompleted() {
+	p.complete.Store(true)
+}
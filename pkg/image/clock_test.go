@@ -0,0 +1,101 @@
+package image
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+
+	var fired []string
+	clock.AfterFunc(2*time.Second, func() { fired = append(fired, "second") })
+	clock.AfterFunc(time.Second, func() { fired = append(fired, "first") })
+	stopped := clock.AfterFunc(time.Second, func() { fired = append(fired, "stopped") })
+	reset := clock.AfterFunc(time.Second, func() { fired = append(fired, "reset") })
+
+	assert.True(t, stopped.Stop())
+	assert.True(t, reset.Reset(5*time.Second))
+
+	clock.Advance(500 * time.Millisecond)
+	assert.Empty(t, fired)
+
+	clock.Advance(2 * time.Second)
+	assert.Equal(t, []string{"first", "second"}, fired)
+	assert.Equal(t, start.Add(2500*time.Millisecond), clock.Now())
+
+	clock.Advance(3 * time.Second)
+	assert.Equal(t, []string{"first", "second", "reset"}, fired)
+
+	// fired timers may be rescheduled
+	assert.False(t, reset.Reset(time.Second))
+	clock.Advance(time.Second)
+	assert.Equal(t, []string{"first", "second", "reset", "reset"}, fired)
+}
+
+func TestClockFromContext(t *testing.T) {
+	assert.Equal(t, SystemClock, ClockFromContext(context.Background()))
+
+	clock := NewManualClock(time.Now())
+	assert.Equal(t, clock, ClockFromContext(ContextWithClock(context.Background(), clock)))
+}
+
+func TestContextWithTimeout(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	ctx, cancel := ContextWithTimeout(ContextWithClock(context.Background(), clock), time.Minute)
+	defer cancel()
+
+	clock.Advance(59 * time.Second)
+	require.NoError(t, ctx.Err())
+	assert.False(t, deadlineExceeded(ctx))
+
+	clock.Advance(time.Second)
+	require.Error(t, ctx.Err())
+	assert.ErrorIs(t, context.Cause(ctx), context.DeadlineExceeded)
+	assert.True(t, deadlineExceeded(ctx))
+
+	// canceled before the timeout is not a deadline
+	ctx, cancel = ContextWithTimeout(ContextWithClock(context.Background(), clock), time.Minute)
+	cancel()
+	assert.False(t, deadlineExceeded(ctx))
+}
+
+func TestTimedProgress(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	p := NewTimedProgress(ContextWithClock(context.Background(), clock), 10*time.Second)
+
+	assert.Equal(t, int64(10000), p.Size())
+	assert.Equal(t, int64(0), p.Current())
+
+	clock.Advance(4 * time.Second)
+	assert.Equal(t, int64(4000), p.Current())
+
+	// the estimate never exceeds the expected duration
+	clock.Advance(time.Minute)
+	assert.Equal(t, int64(10000), p.Current())
+
+	p = NewTimedProgress(ContextWithClock(context.Background(), clock), 10*time.Second)
+	p.SetCompleted()
+	assert.Equal(t, int64(10000), p.Current())
+}
+
+func TestContentContext(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	defer cancelParent()
+	assert.Equal(t, parent, ContentContext(parent))
+
+	ctx, cancel := ContextWithTimeout(parent, time.Minute)
+	cancel()
+	require.Error(t, ctx.Err())
+
+	// the timeout does not apply to content reads, but the cancellation of the given context does
+	content := ContentContext(ctx)
+	require.NoError(t, content.Err())
+	cancelParent()
+	require.Error(t, content.Err())
+}
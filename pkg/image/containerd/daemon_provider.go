@@ -3,7 +3,9 @@ package containerd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"path"
@@ -12,6 +14,7 @@ import (
 
 	"github.com/containerd/containerd"
 	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/images"
 	"github.com/containerd/containerd/images/archive"
 	"github.com/containerd/containerd/namespaces"
@@ -19,12 +22,14 @@ import (
 	"github.com/containerd/containerd/remotes/docker"
 	"github.com/containerd/containerd/remotes/docker/config"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/wagoodman/go-partybus"
 	"github.com/wagoodman/go-progress"
 
 	"github.com/anchore/stereoscope/internal/bus"
 	containerdClient "github.com/anchore/stereoscope/internal/containerd"
+	"github.com/anchore/stereoscope/internal/environ"
 	"github.com/anchore/stereoscope/internal/log"
 	"github.com/anchore/stereoscope/pkg/event"
 	"github.com/anchore/stereoscope/pkg/file"
@@ -51,6 +56,10 @@ func NewDaemonProvider(tmpDirGen *file.TempDirGenerator, registryOptions image.R
 
 var mb = math.Pow(2, 20)
 
+// maxRemovalRetries is the number of times an image removed from containerd during export is exported again (when
+// the image is still present, see ErrImageGarbageCollected).
+const maxRemovalRetries = 1
+
 // daemonImageProvider is an image.Provider capable of fetching and representing a docker image from the containerd daemon API
 type daemonImageProvider struct {
 	imageStr        string
@@ -65,15 +74,15 @@ func (p *daemonImageProvider) Name() string {
 }
 
 type daemonProvideProgress struct {
-	EstimateProgress *progress.TimedProgress
+	EstimateProgress *image.TimedProgress
 	ExportProgress   *progress.Manual
 	Stage            *progress.Stage
 }
 
 func (p *daemonImageProvider) Provide(ctx context.Context) (*image.Image, error) {
-	client, err := containerdClient.GetClient()
+	client, err := containerdClient.GetClientWithOverrides(environ.FromContext(ctx))
 	if err != nil {
-		return nil, fmt.Errorf("containerd not available: %w", err)
+		return nil, image.MarkError(fmt.Errorf("containerd not available: %w", err), image.ErrDaemonUnavailable)
 	}
 
 	defer func() {
@@ -84,23 +93,49 @@ func (p *daemonImageProvider) Provide(ctx context.Context) (*image.Image, error)
 
 	ctx = namespaces.WithNamespace(ctx, p.namespace)
 
-	resolvedImage, resolvedPlatform, err := p.pullImageIfMissing(ctx, client)
+	resolvedImage, resolvedPlatform, pulled, err := p.pullImageIfMissing(ctx, client)
 	if err != nil {
 		return nil, err
 	}
 
-	tarFileName, err := p.saveImage(ctx, client, resolvedImage)
+	metadata := withMetadata(resolvedPlatform, p.imageStr)
+	if usage, err := daemonUsage(ctx, client, resolvedImage); err == nil {
+		metadata = append(metadata, image.WithDaemonUsage(*usage))
+	} else {
+		log.WithFields("image", resolvedImage, "error", err).Trace("unable to fetch image usage from containerd")
+	}
+
+	if img, ok := p.cachedImage(ctx, client, resolvedImage); ok {
+		log.WithFields("image", resolvedImage).Debug("using image from blob cache instead of exporting from containerd")
+		return p.provideCached(ctx, img, metadata...)
+	}
+
+	source := event.ImageSource{Image: resolvedImage, Provider: Daemon, Pulled: pulled, Namespace: p.namespace}
+
+	if image.IsStreamingExport(ctx) {
+		return p.streamImage(ctx, client, source, metadata...)
+	}
+
+	tarFileName, err := p.saveImage(ctx, client, source)
 	if err != nil {
 		return nil, err
 	}
 
 	// use the existing tarball provider to process what was pulled from the containerd daemon
-	return stereoscopeDocker.NewArchiveProvider(p.tmpDirGen, tarFileName, withMetadata(resolvedPlatform, p.imageStr)...).
+	return stereoscopeDocker.NewArchiveProvider(p.tmpDirGen, tarFileName, metadata...).
 		Provide(ctx)
 }
 
 // pull a containerd image
 func (p *daemonImageProvider) pull(ctx context.Context, client *containerd.Client, resolvedImage string) (containerd.Image, error) {
+	action := fmt.Sprintf("pull containerd image=%q", resolvedImage)
+	if err := image.CheckNetworkAccess(ctx, action); err != nil {
+		return nil, err
+	}
+	if err := image.CheckDaemonMutation(ctx, action); err != nil {
+		return nil, err
+	}
+
 	var platformStr string
 	if p.platform != nil {
 		platformStr = p.platform.String()
@@ -115,7 +150,7 @@ func (p *daemonImageProvider) pull(ctx context.Context, client *containerd.Clien
 	// publish a pull event on the bus, allowing for read-only consumption of status
 	bus.Publish(partybus.Event{
 		Type:   event.PullContainerdImage,
-		Source: resolvedImage,
+		Source: event.ImageSource{Image: resolvedImage, Provider: Daemon, Pulled: true, Namespace: p.namespace},
 		Value:  newPullStatus(client, ongoing).start(ctx),
 	})
 
@@ -138,8 +173,14 @@ func (p *daemonImageProvider) pull(ctx context.Context, client *containerd.Clien
 	}
 	options = append(options, containerd.WithImageHandler(h))
 
-	// note: this will return an image object with the platform correctly set (if it exists)
-	resp, err := client.Pull(ctx, resolvedImage, options...)
+	// note: this will return an image object with the platform correctly set (if it exists). Pulls failing with a
+	// transient error are attempted again according to the retry policy, where content that was already fetched is
+	// reused from the content store.
+	var resp containerd.Image
+	err = image.Retry(ctx, "containerd pull", func() (err error) {
+		resp, err = client.Pull(ctx, resolvedImage, options...)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("pull failed: %w", err)
 	}
@@ -156,28 +197,11 @@ func (p *daemonImageProvider) pullOptions(ctx context.Context, ref name.Referenc
 		Tracker: docker.NewInMemoryTracker(),
 	}
 
-	if p.registryOptions.Keychain != nil {
-		log.Warn("keychain registry option provided but is not supported for containerd daemon image provider")
-	}
-
-	var hostOptions config.HostOptions
-
-	if len(p.registryOptions.Credentials) > 0 {
-		hostOptions.Credentials = func(host string) (string, string, error) {
-			// TODO: how should a bearer token be handled here?
-
-			auth := p.registryOptions.Authenticator(host)
-			if auth != nil {
-				cfg, err := auth.Authorization()
-				if err != nil {
-					return "", "", fmt.Errorf("unable to get credentials for host=%q: %w", host, err)
-				}
-				log.WithFields("registry", host).Trace("found credentials")
-				return cfg.Username, cfg.Password, nil
-			}
-			log.WithFields("registry", host).Trace("no credentials found")
-			return "", "", nil
-		}
+	// note: credentials are resolved the same as with the OCI registry provider (explicit credentials, then the
+	// keychain), including bearer tokens and identity tokens
+	auth := newRegistryAuth(ctx, p.registryOptions)
+	hostOptions := config.HostOptions{
+		Credentials: auth.credentials,
 	}
 
 	switch p.registryOptions.InsecureUseHTTP {
@@ -198,7 +222,7 @@ func (p *daemonImageProvider) pullOptions(ctx context.Context, ref name.Referenc
 		hostOptions.DefaultTLS = tlsConfig
 	}
 
-	dockerOptions.Hosts = config.ConfigureHosts(ctx, hostOptions)
+	dockerOptions.Hosts = withTokenAuthorizer(config.ConfigureHosts(ctx, hostOptions), auth)
 
 	options = append(options, containerd.WithResolver(docker.NewResolver(dockerOptions)))
 
@@ -260,7 +284,12 @@ func (p *daemonImageProvider) resolveImage(ctx context.Context, client *containe
 			return "", nil, fmt.Errorf("unable to parse platform: %w", err)
 		}
 		platformMatcher := platforms.NewMatcher(platformObj)
+		var attestations int
 		for _, manifestDesc := range index.Manifests {
+			if isAttestationManifest(manifestDesc) {
+				attestations++
+				continue
+			}
 			if manifestDesc.Platform == nil {
 				continue
 			}
@@ -269,13 +298,37 @@ func (p *daemonImageProvider) resolveImage(ctx context.Context, client *containe
 			}
 		}
 
+		if attestations > 0 {
+			log.WithFields("image", imageStr, "count", attestations).Warn("skipped attestation manifests while selecting platform")
+		}
+
 		// no manifest found for the platform we want
-		return imageStr, nil, fmt.Errorf("no manifest found in manifest list for platform %q", p.platform.String())
+		return imageStr, nil, image.MarkError(fmt.Errorf("no manifest found in manifest list for platform %q", p.platform.String()), image.ErrPlatformMismatch)
 	}
 
 	return "", nil, fmt.Errorf("unexpected mediaType for image: %q", desc.MediaType)
 }
 
+// isAttestationManifest indicates that the given index entry is an attestation manifest attached by buildkit (which
+// should never be selected as the image for a platform).
+func isAttestationManifest(desc ocispec.Descriptor) bool {
+	return image.IsAttestationManifest(v1.Descriptor{
+		Annotations: desc.Annotations,
+		Platform:    ociPlatform(desc.Platform),
+	}, nil)
+}
+
+func ociPlatform(p *ocispec.Platform) *v1.Platform {
+	if p == nil {
+		return nil
+	}
+	return &v1.Platform{
+		OS:           p.OS,
+		Architecture: p.Architecture,
+		Variant:      p.Variant,
+	}
+}
+
 func (p *daemonImageProvider) fetchManifest(ctx context.Context, client *containerd.Client, desc ocispec.Descriptor) (*ocispec.Manifest, error) {
 	switch desc.MediaType {
 	case images.MediaTypeDockerSchema2Manifest, ocispec.MediaTypeImageManifest:
@@ -318,7 +371,9 @@ func (p *daemonImageProvider) fetchPlatformFromConfig(ctx context.Context, clien
 	return &cfg, nil
 }
 
-func (p *daemonImageProvider) pullImageIfMissing(ctx context.Context, client *containerd.Client) (string, *platforms.Platform, error) {
+// pullImageIfMissing returns the resolved image name and platform, pulling the image when it is missing from the
+// namespace. Reports whether the image was pulled.
+func (p *daemonImageProvider) pullImageIfMissing(ctx context.Context, client *containerd.Client) (string, *platforms.Platform, bool, error) {
 	p.imageStr = checkRegistryHostMissing(p.imageStr)
 
 	// try to get the image first before pulling
@@ -329,23 +384,32 @@ func (p *daemonImageProvider) pullImageIfMissing(ctx context.Context, client *co
 		imageStr = p.imageStr
 	}
 
+	var pulled bool
 	if err != nil {
+		notFound := errdefs.IsNotFound(err)
 		_, err := p.pull(ctx, client, imageStr)
 		if err != nil {
-			return "", nil, err
+			if notFound {
+				err = image.WithHint(err, image.Hint{
+					ID:      image.HintContainerdNamespace,
+					Message: fmt.Sprintf("the image was not found within the containerd namespace %q, set CONTAINERD_NAMESPACE to the namespace holding the image (e.g. 'k8s.io' for kubernetes)", p.namespace),
+				})
+			}
+			return "", nil, false, err
 		}
+		pulled = true
 
 		resolvedImage, resolvedPlatform, err = p.resolveImage(ctx, client, imageStr)
 		if err != nil {
-			return "", nil, fmt.Errorf("unable to resolve image after pull: %w", err)
+			return "", nil, false, fmt.Errorf("unable to resolve image after pull: %w", err)
 		}
 	}
 
 	if err := p.validatePlatform(resolvedPlatform); err != nil {
-		return "", nil, fmt.Errorf("platform validation failed: %w", err)
+		return "", nil, false, image.MarkError(fmt.Errorf("platform validation failed: %w", err), image.ErrPlatformMismatch)
 	}
 
-	return resolvedImage, resolvedPlatform, nil
+	return resolvedImage, resolvedPlatform, pulled, nil
 }
 
 func (p *daemonImageProvider) validatePlatform(platform *platforms.Platform) error {
@@ -373,7 +437,7 @@ func (p *daemonImageProvider) validatePlatform(platform *platforms.Platform) err
 }
 
 // save the image from the containerd daemon to a tar file
-func (p *daemonImageProvider) saveImage(ctx context.Context, client *containerd.Client, resolvedImage string) (string, error) {
+func (p *daemonImageProvider) saveImage(ctx context.Context, client *containerd.Client, source event.ImageSource) (string, error) {
 	imageTempDir, err := p.tmpDirGen.NewDirectory("containerd-daemon-image")
 	if err != nil {
 		return "", err
@@ -391,14 +455,93 @@ func (p *daemonImageProvider) saveImage(ctx context.Context, client *containerd.
 		}
 	}()
 
+	for attempt := 0; ; attempt++ {
+		err := p.export(ctx, client, source, tempTarFile)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrImageGarbageCollected) || attempt >= maxRemovalRetries {
+			return "", err
+		}
+		// the image can be exported again when it is still present (e.g. the content of the previous image was
+		// collected after the image was retagged), otherwise the image is gone for good
+		if _, getErr := client.GetImage(ctx, source.Image); getErr != nil {
+			return "", err
+		}
+		log.WithFields("image", source.Image, "error", err).Warn("image was removed from containerd during export, exporting the image again")
+		if err := resetFile(tempTarFile); err != nil {
+			return "", fmt.Errorf("unable to reset temp file for image: %w", err)
+		}
+	}
+
+	return tempTarFile.Name(), nil
+}
+
+// cachedImage returns the image composed entirely of blobs from the blob cache (when all are cached, see
+// image.BlobCache.Image).
+func (p *daemonImageProvider) cachedImage(ctx context.Context, client *containerd.Client, resolvedImage string) (v1.Image, bool) {
+	cache := image.BlobCacheFromContext(ctx)
+	if cache == nil {
+		return nil, false
+	}
+
+	img, err := client.GetImage(ctx, resolvedImage)
+	if err != nil {
+		return nil, false
+	}
+
+	platformComparer, err := exportPlatformComparer(p.platform)
+	if err != nil {
+		return nil, false
+	}
+
+	config, err := images.Config(ctx, client.ContentStore(), img.Target(), platformComparer)
+	if err != nil {
+		log.WithFields("image", resolvedImage, "error", err).Trace("unable to resolve image config from containerd")
+		return nil, false
+	}
+
+	return cache.Image(config.Digest.String())
+}
+
+// provideCached provides the given image composed of blobs from the blob cache (see image.BlobCache.Image).
+func (p *daemonImageProvider) provideCached(ctx context.Context, img v1.Image, metadata ...image.AdditionalMetadata) (*image.Image, error) {
+	contentTempDir, err := p.tmpDirGen.NewDirectory("containerd-cached-image")
+	if err != nil {
+		return nil, err
+	}
+
+	out := image.New(img, p.tmpDirGen, contentTempDir, metadata...)
+	if err := out.ReadContext(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// streamImage provides the image by consuming the containerd export stream directly, without first writing the entire
+// image tar to disk.
+func (p *daemonImageProvider) streamImage(ctx context.Context, client *containerd.Client, source event.ImageSource, metadata ...image.AdditionalMetadata) (*image.Image, error) {
+	reader, writer := io.Pipe()
+	defer reader.Close()
+
+	go func() {
+		writer.CloseWithError(p.export(ctx, client, source, writer))
+	}()
+
+	return stereoscopeDocker.NewStreamArchiveProvider(p.tmpDirGen, reader, nil, metadata...).
+		Provide(ctx)
+}
+
+// export writes the image (for the configured platform only) as a docker archive to the given writer.
+func (p *daemonImageProvider) export(ctx context.Context, client *containerd.Client, source event.ImageSource, writer io.Writer) error {
 	is := client.ImageService()
 	exportOpts := []archive.ExportOpt{
-		archive.WithImage(is, resolvedImage),
+		archive.WithImage(is, source.Image),
 	}
 
-	img, err := client.GetImage(ctx, resolvedImage)
+	img, err := client.GetImage(ctx, source.Image)
 	if err != nil {
-		return "", fmt.Errorf("unable to fetch image from containerd: %w", err)
+		return fmt.Errorf("unable to fetch image from containerd: %w", err)
 	}
 
 	size, err := img.Size(ctx)
@@ -409,12 +552,13 @@ func (p *daemonImageProvider) saveImage(ctx context.Context, client *containerd.
 
 	platformComparer, err := exportPlatformComparer(p.platform)
 	if err != nil {
-		return "", err
+		return err
 	}
 
 	exportOpts = append(exportOpts, archive.WithPlatform(platformComparer))
 
-	providerProgress := p.trackSaveProgress(size)
+	source.Digest = img.Target().Digest.String()
+	providerProgress := p.trackSaveProgress(ctx, size, source)
 	defer func() {
 		// NOTE: progress trackers should complete at the end of this function
 		// whether the function errors or succeeds.
@@ -424,13 +568,30 @@ func (p *daemonImageProvider) saveImage(ctx context.Context, client *containerd.
 
 	providerProgress.Stage.Current = "requesting image from containerd"
 
+	// the image may be deleted or garbage collected while it is exported, which is detected to fail precisely
+	exported := img.Metadata()
+	watcher := watchRemoval(ctx, client, p.namespace, exported.Name, imageDigests(ctx, client.ContentStore(), exported, platformComparer))
+
 	// containerd export (save) does not return till fully complete
-	err = client.Export(ctx, tempTarFile, exportOpts...)
+	err = client.Export(ctx, writer, exportOpts...)
+	observed := watcher.stop()
 	if err != nil {
-		return "", fmt.Errorf("unable to save image tar for image=%q: %w", img.Name(), err)
+		if removal := removalAfterFailure(ctx, is, exported, observed, err); removal != "" {
+			return fmt.Errorf("%w (%s): %v", ErrImageGarbageCollected, removal, err)
+		}
+		return fmt.Errorf("unable to save image tar for image=%q: %w", img.Name(), err)
 	}
 
-	return tempTarFile.Name(), nil
+	return nil
+}
+
+// resetFile truncates the file and moves to its start, such that it can be written again.
+func resetFile(fh *os.File) error {
+	if err := fh.Truncate(0); err != nil {
+		return err
+	}
+	_, err := fh.Seek(0, io.SeekStart)
+	return err
 }
 
 func exportPlatformComparer(platform *image.Platform) (platforms.MatchComparer, error) {
@@ -450,12 +611,12 @@ func exportPlatformComparer(platform *image.Platform) (platforms.MatchComparer,
 	return platforms.OnlyStrict(platformObj), nil
 }
 
-func (p *daemonImageProvider) trackSaveProgress(size int64) *daemonProvideProgress {
+func (p *daemonImageProvider) trackSaveProgress(ctx context.Context, size int64, source event.ImageSource) *daemonProvideProgress {
 	// docker image save clocks in at ~40MB/sec on my laptop... mileage may vary, of course :shrug:
 	sec := float64(size) / (mb * 40)
 	approxSaveTime := time.Duration(sec*1000) * time.Millisecond
 
-	estimateSaveProgress := progress.NewTimedProgress(approxSaveTime)
+	estimateSaveProgress := image.NewTimedProgress(ctx, approxSaveTime)
 	exportProgress := progress.NewManual(1)
 	aggregateProgress := progress.NewAggregator(progress.DefaultStrategy, estimateSaveProgress, exportProgress)
 
@@ -464,7 +625,7 @@ func (p *daemonImageProvider) trackSaveProgress(size int64) *daemonProvideProgre
 
 	bus.Publish(partybus.Event{
 		Type:   event.FetchImage,
-		Source: p.imageStr,
+		Source: source,
 		Value: progress.StagedProgressable(&struct {
 			progress.Stager
 			progress.Progressable
@@ -504,6 +665,33 @@ func withMetadata(platform *platforms.Platform, ref string) (metadata []image.Ad
 	return metadata
 }
 
+// daemonUsage reports the image sizes as shown by "ctr image ls" (size) and "ctr image usage" (content and snapshots).
+func daemonUsage(ctx context.Context, client *containerd.Client, resolvedImage string) (*image.DaemonUsage, error) {
+	img, err := client.GetImage(ctx, resolvedImage)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := image.DaemonUsage{
+		VirtualSize: -1,
+		SharedSize:  -1,
+	}
+
+	if usage.Size, err = img.Size(ctx); err != nil {
+		return nil, fmt.Errorf("unable to get image size: %w", err)
+	}
+
+	if usage.ContentSize, err = img.Usage(ctx); err != nil {
+		return nil, fmt.Errorf("unable to get image content usage: %w", err)
+	}
+
+	if usage.SnapshotSize, err = img.Usage(ctx, containerd.WithSnapshotUsage()); err != nil {
+		return nil, fmt.Errorf("unable to get image snapshot usage: %w", err)
+	}
+
+	return &usage, nil
+}
+
 // if image doesn't have host set, add docker hub by default
 func checkRegistryHostMissing(imageName string) string {
 	parts := strings.Split(imageName, "/")
@@ -0,0 +1,153 @@
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/containerd/containerd/remotes/docker"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// registryAuth resolves the credentials for registry hosts the same way as the OCI registry provider: explicit
+// registry credentials are used first, then the keychain from the registry options, and then the default keychain
+// (the docker config and credential helpers of the environment overrides, when given). Resolved credentials are cached
+// per host.
+type registryAuth struct {
+	options  image.RegistryOptions
+	keychain authn.Keychain
+
+	lock    sync.Mutex
+	configs map[string]*authn.AuthConfig
+}
+
+func newRegistryAuth(ctx context.Context, options image.RegistryOptions) *registryAuth {
+	return &registryAuth{
+		options:  options,
+		keychain: options.ResolveKeychain(ctx),
+		configs:  make(map[string]*authn.AuthConfig),
+	}
+}
+
+// authConfig returns the credentials for the given host (nil for anonymous access).
+func (r *registryAuth) authConfig(host string) (*authn.AuthConfig, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if cfg, ok := r.configs[host]; ok {
+		return cfg, nil
+	}
+
+	auth := r.options.Authenticator(host)
+	if auth == nil {
+		registry, err := name.NewRegistry(keychainRegistry(host))
+		if err != nil {
+			return nil, fmt.Errorf("invalid registry host=%q: %w", host, err)
+		}
+
+		auth, err = r.keychain.Resolve(registry)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve credentials for host=%q: %w", host, err)
+		}
+	}
+
+	var cfg *authn.AuthConfig
+	if auth != nil && auth != authn.Anonymous {
+		var err error
+		cfg, err = auth.Authorization()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get credentials for host=%q: %w", host, err)
+		}
+		log.WithFields("registry", host).Trace("found credentials")
+	} else {
+		log.WithFields("registry", host).Trace("no credentials found")
+	}
+
+	r.configs[host] = cfg
+	return cfg, nil
+}
+
+// credentials returns the username and secret that containerd uses for basic auth and for fetching bearer tokens from
+// the token service of the registry. Identity tokens are returned as the secret with an empty username, which
+// containerd exchanges for a bearer token as an OAuth refresh token.
+func (r *registryAuth) credentials(host string) (string, string, error) {
+	cfg, err := r.authConfig(host)
+	if err != nil || cfg == nil {
+		return "", "", err
+	}
+
+	if cfg.IdentityToken != "" {
+		return "", cfg.IdentityToken, nil
+	}
+	return cfg.Username, cfg.Password, nil
+}
+
+// registryToken returns the bearer token to use as-is for the given host (empty when there is none).
+func (r *registryAuth) registryToken(host string) (string, error) {
+	cfg, err := r.authConfig(host)
+	if err != nil || cfg == nil {
+		return "", err
+	}
+	return cfg.RegistryToken, nil
+}
+
+// keychainRegistry returns the registry name that keychains know the given host by (containerd resolves docker hub
+// to registry-1.docker.io, which is the default registry to keychains).
+func keychainRegistry(host string) string {
+	if host == "registry-1.docker.io" {
+		return name.DefaultRegistry
+	}
+	return host
+}
+
+// tokenAuthorizer authorizes requests to hosts with a bearer token (see image.RegistryCredentials.Token) by sending
+// the token directly, since containerd only supports fetching tokens from the token service of a registry. Requests to
+// all other hosts are authorized by the wrapped authorizer.
+type tokenAuthorizer struct {
+	docker.Authorizer
+	auth *registryAuth
+}
+
+func (a *tokenAuthorizer) Authorize(ctx context.Context, req *http.Request) error {
+	token, err := a.auth.registryToken(req.URL.Host)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return a.Authorizer.Authorize(ctx, req)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (a *tokenAuthorizer) AddResponses(ctx context.Context, responses []*http.Response) error {
+	host := responses[len(responses)-1].Request.URL.Host
+	token, err := a.auth.registryToken(host)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return a.Authorizer.AddResponses(ctx, responses)
+	}
+	// the token is fixed, thus there is nothing to retry with
+	return fmt.Errorf("bearer token rejected by registry host=%q: %w", host, docker.ErrInvalidAuthorization)
+}
+
+// withTokenAuthorizer wraps the authorizers of all hosts resolved by the given hosts function with a tokenAuthorizer.
+func withTokenAuthorizer(hosts docker.RegistryHosts, auth *registryAuth) docker.RegistryHosts {
+	return func(host string) ([]docker.RegistryHost, error) {
+		registryHosts, err := hosts(host)
+		if err != nil {
+			return nil, err
+		}
+		for idx := range registryHosts {
+			registryHosts[idx].Authorizer = &tokenAuthorizer{Authorizer: registryHosts[idx].Authorizer, auth: auth}
+		}
+		return registryHosts, nil
+	}
+}
@@ -0,0 +1,176 @@
+package containerd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/containerd/containerd/remotes/docker"
+	"github.com/containerd/containerd/remotes/docker/config"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// staticKeychain resolves the same authenticator for all registries, recording the registries asked for.
+type staticKeychain struct {
+	auth     authn.Authenticator
+	resolved []string
+}
+
+func (k *staticKeychain) Resolve(resource authn.Resource) (authn.Authenticator, error) {
+	k.resolved = append(k.resolved, resource.RegistryStr())
+	return k.auth, nil
+}
+
+func Test_registryAuth_credentials(t *testing.T) {
+	tests := []struct {
+		name         string
+		options      image.RegistryOptions
+		host         string
+		wantUsername string
+		wantSecret   string
+		wantToken    string
+	}{
+		{
+			name: "basic auth credentials",
+			options: image.RegistryOptions{
+				Credentials: []image.RegistryCredentials{{Authority: "registry.example.com", Username: "user", Password: "pass"}},
+			},
+			host:         "registry.example.com",
+			wantUsername: "user",
+			wantSecret:   "pass",
+		},
+		{
+			name: "bearer token credentials",
+			options: image.RegistryOptions{
+				Credentials: []image.RegistryCredentials{{Authority: "registry.example.com", Token: "token"}},
+			},
+			host:      "registry.example.com",
+			wantToken: "token",
+		},
+		{
+			name: "identity token from an authenticator",
+			options: image.RegistryOptions{
+				Credentials: []image.RegistryCredentials{{
+					Authority:     "registry.example.com",
+					Authenticator: authn.FromConfig(authn.AuthConfig{Username: "<token>", IdentityToken: "refresh"}),
+				}},
+			},
+			host:       "registry.example.com",
+			wantSecret: "refresh",
+		},
+		{
+			name: "keychain",
+			options: image.RegistryOptions{
+				Keychain: &staticKeychain{auth: &authn.Basic{Username: "keychain-user", Password: "keychain-pass"}},
+			},
+			host:         "registry.example.com",
+			wantUsername: "keychain-user",
+			wantSecret:   "keychain-pass",
+		},
+		{
+			name: "credentials for other registries use the keychain",
+			options: image.RegistryOptions{
+				Credentials: []image.RegistryCredentials{{Authority: "other.example.com", Username: "user", Password: "pass"}},
+				Keychain:    &staticKeychain{auth: authn.Anonymous},
+			},
+			host: "registry.example.com",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			auth := newRegistryAuth(context.Background(), test.options)
+
+			username, secret, err := auth.credentials(test.host)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantUsername, username)
+			assert.Equal(t, test.wantSecret, secret)
+
+			token, err := auth.registryToken(test.host)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantToken, token)
+		})
+	}
+}
+
+func Test_registryAuth_dockerHubKeychain(t *testing.T) {
+	keychain := &staticKeychain{auth: authn.Anonymous}
+	auth := newRegistryAuth(context.Background(), image.RegistryOptions{Keychain: keychain})
+
+	_, _, err := auth.credentials("registry-1.docker.io")
+	require.NoError(t, err)
+	_, _, err = auth.credentials("registry-1.docker.io")
+	require.NoError(t, err)
+
+	// resolved once (cached), by the name keychains know docker hub by
+	assert.Equal(t, []string{"index.docker.io"}, keychain.resolved)
+}
+
+func Test_tokenAuthorizer(t *testing.T) {
+	const manifestDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="https://auth.example.com/token",service="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/manifests/latest") {
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Header().Set("Docker-Content-Digest", manifestDigest)
+			w.Header().Set("Content-Length", "2")
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:    "token is sent as-is",
+			token:   "secret-token",
+			wantErr: require.NoError,
+		},
+		{
+			name:  "rejected token",
+			token: "wrong-token",
+			wantErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.ErrorIs(t, err, docker.ErrInvalidAuthorization)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			auth := newRegistryAuth(context.Background(), image.RegistryOptions{
+				Credentials: []image.RegistryCredentials{{Authority: host, Token: test.token}},
+			})
+
+			ctx := context.Background()
+			hosts := config.ConfigureHosts(ctx, config.HostOptions{
+				DefaultScheme: "http",
+				Credentials:   auth.credentials,
+			})
+			resolver := docker.NewResolver(docker.ResolverOptions{Hosts: withTokenAuthorizer(hosts, auth)})
+
+			_, desc, err := resolver.Resolve(ctx, host+"/app:latest")
+			test.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, manifestDigest, desc.Digest.String())
+		})
+	}
+}
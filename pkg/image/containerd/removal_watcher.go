@@ -0,0 +1,161 @@
+package containerd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	eventstypes "github.com/containerd/containerd/api/events"
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/events"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/platforms"
+	"github.com/containerd/typeurl/v2"
+	"github.com/scylladb/go-set/strset"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+// ErrImageGarbageCollected is returned when the image, or content of the image, was removed from containerd (e.g.
+// deleted and garbage collected, or garbage collected after the image was retagged) while it was exported.
+var ErrImageGarbageCollected = errors.New("image was garbage collected during export")
+
+// eventSubscriber subscribes to containerd events (see containerd.Client.Subscribe).
+type eventSubscriber interface {
+	Subscribe(ctx context.Context, filters ...string) (<-chan *events.Envelope, <-chan error)
+}
+
+// removalWatcher observes the containerd events of a namespace for the deletion of an image, or of any content of the
+// image, while the image is exported.
+type removalWatcher struct {
+	name    string
+	digests *strset.Set
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	lock    sync.Mutex
+	removal string
+}
+
+// watchRemoval subscribes to the image and content deletion events of the namespace for the given image and content
+// digests until the watcher is stopped.
+func watchRemoval(ctx context.Context, subscriber eventSubscriber, namespace, name string, digests []string) *removalWatcher {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &removalWatcher{
+		name:    name,
+		digests: strset.New(digests...),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	ns := strconv.Quote(namespace)
+	envelopes, errs := subscriber.Subscribe(ctx,
+		fmt.Sprintf(`namespace==%s,topic=="/images/delete"`, ns),
+		fmt.Sprintf(`namespace==%s,topic=="/content/delete"`, ns),
+	)
+	go w.run(ctx, envelopes, errs)
+	return w
+}
+
+func (w *removalWatcher) run(ctx context.Context, envelopes <-chan *events.Envelope, errs <-chan error) {
+	defer close(w.done)
+	for {
+		select {
+		case envelope, ok := <-envelopes:
+			if !ok {
+				return
+			}
+			w.observe(envelope)
+		case err, ok := <-errs:
+			if ok && err != nil && ctx.Err() == nil {
+				log.WithFields("image", w.name, "error", err).Trace("unable to watch containerd events during export")
+			}
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *removalWatcher) observe(envelope *events.Envelope) {
+	if envelope == nil || envelope.Event == nil {
+		return
+	}
+	event, err := typeurl.UnmarshalAny(envelope.Event)
+	if err != nil {
+		log.WithFields("topic", envelope.Topic, "error", err).Trace("unable to decode containerd event")
+		return
+	}
+
+	switch e := event.(type) {
+	case *eventstypes.ImageDelete:
+		if e.Name == w.name {
+			w.record(fmt.Sprintf("image %q was deleted", e.Name))
+		}
+	case *eventstypes.ContentDelete:
+		if w.digests.Has(e.Digest) {
+			w.record(fmt.Sprintf("content %s of image %q was deleted", e.Digest, w.name))
+		}
+	}
+}
+
+func (w *removalWatcher) record(removal string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.removal == "" {
+		log.WithFields("image", w.name).Debugf("%s during export", removal)
+		w.removal = removal
+	}
+}
+
+// stop ends the subscription, returning the first observed removal (empty when none was observed).
+func (w *removalWatcher) stop() string {
+	w.cancel()
+	<-w.done
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.removal
+}
+
+// imageDigests returns the digests of the content of the image exported for the given platform: the target (e.g. an
+// index), the platform manifest, the config, and the layers.
+func imageDigests(ctx context.Context, store content.Provider, img images.Image, platform platforms.MatchComparer) []string {
+	digests := []string{img.Target.Digest.String()}
+
+	manifest, err := images.Manifest(ctx, store, img.Target, platform)
+	if err != nil {
+		log.WithFields("image", img.Name, "error", err).Trace("unable to resolve image manifest from containerd")
+		return digests
+	}
+	digests = append(digests, manifest.Config.Digest.String())
+	for _, layer := range manifest.Layers {
+		digests = append(digests, layer.Digest.String())
+	}
+	return digests
+}
+
+// removalAfterFailure describes why the export of the image failed with the given error when the image was removed
+// during the export: either a removal observed by the watcher, or (since the garbage collector removes content without
+// publishing events) content that is missing because the image is no longer present or was retagged. An empty
+// description is returned when the failure is unrelated to the removal of the image.
+func removalAfterFailure(ctx context.Context, store images.Store, exported images.Image, observed string, err error) string {
+	if observed != "" {
+		return observed
+	}
+	if !errdefs.IsNotFound(err) {
+		return ""
+	}
+
+	current, getErr := store.Get(ctx, exported.Name)
+	switch {
+	case errdefs.IsNotFound(getErr):
+		return fmt.Sprintf("image %q was deleted", exported.Name)
+	case getErr == nil && current.Target.Digest != exported.Target.Digest:
+		return fmt.Sprintf("image %q was retagged to %s", exported.Name, current.Target.Digest)
+	}
+	return ""
+}
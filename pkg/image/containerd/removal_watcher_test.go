@@ -0,0 +1,132 @@
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	eventstypes "github.com/containerd/containerd/api/events"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/events"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/typeurl/v2"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_removalWatcher(t *testing.T) {
+	layer := "sha256:" + strings.Repeat("a", 64)
+
+	tests := []struct {
+		name   string
+		events []any
+		want   string
+	}{
+		{
+			name:   "no removal",
+			events: []any{&eventstypes.ImageDelete{Name: "docker.io/library/other:latest"}, &eventstypes.ContentDelete{Digest: "sha256:" + strings.Repeat("b", 64)}},
+		},
+		{
+			name:   "image deleted",
+			events: []any{&eventstypes.ImageDelete{Name: "docker.io/library/app:latest"}},
+			want:   `image "docker.io/library/app:latest" was deleted`,
+		},
+		{
+			name:   "content deleted",
+			events: []any{&eventstypes.ContentDelete{Digest: layer}, &eventstypes.ImageDelete{Name: "docker.io/library/app:latest"}},
+			want:   fmt.Sprintf(`content %s of image "docker.io/library/app:latest" was deleted`, layer),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subscriber := &fakeSubscriber{envelopes: make(chan *events.Envelope)}
+			w := watchRemoval(context.Background(), subscriber, "k8s.io", "docker.io/library/app:latest", []string{layer})
+			assert.Equal(t, []string{`namespace=="k8s.io",topic=="/images/delete"`, `namespace=="k8s.io",topic=="/content/delete"`}, subscriber.filters)
+
+			for _, event := range tt.events {
+				encoded, err := typeurl.MarshalAny(event)
+				require.NoError(t, err)
+				subscriber.envelopes <- &events.Envelope{Namespace: "k8s.io", Event: encoded}
+			}
+			assert.Equal(t, tt.want, w.stop())
+		})
+	}
+}
+
+func Test_removalAfterFailure(t *testing.T) {
+	exported := images.Image{
+		Name:   "docker.io/library/app:latest",
+		Target: ocispec.Descriptor{Digest: digest.Digest("sha256:" + strings.Repeat("a", 64))},
+	}
+	retagged := exported
+	retagged.Target.Digest = digest.Digest("sha256:" + strings.Repeat("b", 64))
+	notFound := fmt.Errorf("content digest %s: %w", exported.Target.Digest, errdefs.ErrNotFound)
+
+	tests := []struct {
+		name     string
+		current  *images.Image
+		observed string
+		err      error
+		want     string
+	}{
+		{
+			name:     "observed removal",
+			current:  &exported,
+			observed: "image was deleted",
+			err:      fmt.Errorf("export failed"),
+			want:     "image was deleted",
+		},
+		{
+			name: "missing content of deleted image",
+			err:  notFound,
+			want: `image "docker.io/library/app:latest" was deleted`,
+		},
+		{
+			name:    "missing content of retagged image",
+			current: &retagged,
+			err:     notFound,
+			want:    fmt.Sprintf(`image "docker.io/library/app:latest" was retagged to %s`, retagged.Target.Digest),
+		},
+		{
+			name:    "missing content of unchanged image",
+			current: &exported,
+			err:     notFound,
+		},
+		{
+			name: "unrelated failure",
+			err:  fmt.Errorf("connection reset"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := fakeImageStore{current: tt.current}
+			assert.Equal(t, tt.want, removalAfterFailure(context.Background(), store, exported, tt.observed, tt.err))
+		})
+	}
+}
+
+type fakeSubscriber struct {
+	filters   []string
+	envelopes chan *events.Envelope
+}
+
+func (s *fakeSubscriber) Subscribe(_ context.Context, filters ...string) (<-chan *events.Envelope, <-chan error) {
+	s.filters = filters
+	return s.envelopes, make(chan error)
+}
+
+// fakeImageStore is an image store holding (at most) a single image.
+type fakeImageStore struct {
+	images.Store
+	current *images.Image
+}
+
+func (s fakeImageStore) Get(_ context.Context, name string) (images.Image, error) {
+	if s.current == nil || s.current.Name != name {
+		return images.Image{}, fmt.Errorf("image %q: %w", name, errdefs.ErrNotFound)
+	}
+	return *s.current, nil
+}
@@ -0,0 +1,262 @@
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/diff"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/mount"
+	"github.com/containerd/containerd/namespaces"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	containerdClient "github.com/anchore/stereoscope/internal/containerd"
+	"github.com/anchore/stereoscope/internal/environ"
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/event"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+const Snapshot image.Source = image.ContainerdSnapshotSource
+
+// NewSnapshotProvider creates a new provider instance that represents the current filesystem state of a containerd
+// container (the container image plus all runtime changes). The given reference may be a container ID or a snapshot
+// key within the default snapshotter (in which case the entire snapshot is represented as a single layer).
+func NewSnapshotProvider(tmpDirGen *file.TempDirGenerator, namespace string, reference string, platform *image.Platform) image.Provider {
+	if namespace == "" {
+		namespace = namespaces.Default
+	}
+
+	return &snapshotImageProvider{
+		reference: reference,
+		tmpDirGen: tmpDirGen,
+		platform:  platform,
+		namespace: namespace,
+	}
+}
+
+// snapshotImageProvider is an image.Provider capable of representing a containerd container (or bare snapshot) as an image
+type snapshotImageProvider struct {
+	reference string
+	tmpDirGen *file.TempDirGenerator
+	platform  *image.Platform
+	namespace string
+}
+
+func (p *snapshotImageProvider) Name() string {
+	return Snapshot
+}
+
+func (p *snapshotImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	if p.reference == "" {
+		return nil, fmt.Errorf("no container ID or snapshot key provided")
+	}
+
+	// diffing requires temporary leases, snapshot views, and content within the containerd stores
+	if err := image.CheckDaemonMutation(ctx, "diff containerd snapshots"); err != nil {
+		return nil, err
+	}
+
+	client, err := containerdClient.GetClientWithOverrides(environ.FromContext(ctx))
+	if err != nil {
+		return nil, image.MarkError(fmt.Errorf("containerd not available: %w", err), image.ErrDaemonUnavailable)
+	}
+
+	defer func() {
+		if err := client.Close(); err != nil {
+			log.Errorf("unable to close containerd client: %+v", err)
+		}
+	}()
+
+	ctx = namespaces.WithNamespace(ctx, p.namespace)
+
+	// hold a lease for all content created while diffing so that it is not garbage collected out from under us
+	ctx, done, err := client.WithLease(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create containerd lease: %w", err)
+	}
+	defer func() {
+		if err := done(ctx); err != nil {
+			log.Warnf("unable to release containerd lease: %+v", err)
+		}
+	}()
+
+	container, err := client.LoadContainer(ctx, p.reference)
+	switch {
+	case err == nil:
+		return p.provideContainer(ctx, client, container)
+	case errdefs.IsNotFound(err):
+		log.WithFields("reference", p.reference).Trace("no containerd container found, treating reference as a snapshot key")
+		return p.provideSnapshot(ctx, client, containerd.DefaultSnapshotter, p.reference)
+	default:
+		return nil, fmt.Errorf("unable to load containerd container %q: %w", p.reference, err)
+	}
+}
+
+// provideContainer represents the container image with an additional top layer that contains all runtime changes
+func (p *snapshotImageProvider) provideContainer(ctx context.Context, client *containerd.Client, container containerd.Container) (*image.Image, error) {
+	info, err := container.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get container info: %w", err)
+	}
+
+	if info.SnapshotKey == "" || info.Image == "" {
+		return nil, fmt.Errorf("container %q does not have an image-based root filesystem", info.ID)
+	}
+
+	snapshotter := info.Snapshotter
+	if snapshotter == "" {
+		snapshotter = containerd.DefaultSnapshotter
+	}
+
+	base := &daemonImageProvider{
+		imageStr:  info.Image,
+		tmpDirGen: p.tmpDirGen,
+		platform:  p.platform,
+		namespace: p.namespace,
+	}
+
+	tarFileName, err := base.saveImage(ctx, client, event.ImageSource{Image: info.Image, Provider: Snapshot, Namespace: p.namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	baseImg, err := tarball.ImageFromPath(tarFileName, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read container image %q: %w", info.Image, err)
+	}
+
+	diffPath, err := p.diffSnapshot(ctx, client, snapshotter, info.SnapshotKey, false)
+	if err != nil {
+		return nil, err
+	}
+
+	diffLayer, err := tarball.LayerFromFile(diffPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read container diff layer: %w", err)
+	}
+
+	img, err := mutate.AppendLayers(baseImg, diffLayer)
+	if err != nil {
+		return nil, fmt.Errorf("unable to append container diff layer: %w", err)
+	}
+
+	return p.read(ctx, img, withMetadata(nil, info.Image)...)
+}
+
+// provideSnapshot represents the entire filesystem of the given snapshot as a single layer image
+func (p *snapshotImageProvider) provideSnapshot(ctx context.Context, client *containerd.Client, snapshotter, key string) (*image.Image, error) {
+	diffPath, err := p.diffSnapshot(ctx, client, snapshotter, key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	layer, err := tarball.LayerFromFile(diffPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read snapshot layer: %w", err)
+	}
+
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create image from snapshot: %w", err)
+	}
+
+	var metadata []image.AdditionalMetadata
+	if p.platform != nil {
+		metadata = append(metadata, image.WithPlatform(p.platform.String()))
+	}
+
+	return p.read(ctx, img, metadata...)
+}
+
+// diffSnapshot writes the changes within the given snapshot relative to its parent (or relative to nothing when
+// full=true) as a layer tar to a temp directory.
+func (p *snapshotImageProvider) diffSnapshot(ctx context.Context, client *containerd.Client, snapshotter, key string, full bool) (string, error) {
+	sn := client.SnapshotService(snapshotter)
+
+	info, err := sn.Stat(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("unable to find snapshot %q (snapshotter=%q): %w", key, snapshotter, err)
+	}
+
+	upper, err := sn.Mounts(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("unable to get mounts for snapshot %q: %w", key, err)
+	}
+
+	parent := info.Parent
+	if full {
+		parent = ""
+	}
+
+	viewKey := fmt.Sprintf("stereoscope-view-%s-%d", key, time.Now().UnixNano())
+	lower, err := sn.View(ctx, viewKey, parent)
+	if err != nil {
+		return "", fmt.Errorf("unable to create view of parent snapshot %q: %w", parent, err)
+	}
+	defer func() {
+		if err := sn.Remove(ctx, viewKey); err != nil {
+			log.Warnf("unable to remove snapshot view %q: %+v", viewKey, err)
+		}
+	}()
+
+	return p.compare(ctx, client, lower, upper)
+}
+
+func (p *snapshotImageProvider) compare(ctx context.Context, client *containerd.Client, lower, upper []mount.Mount) (string, error) {
+	desc, err := client.DiffService().Compare(ctx, lower, upper, diff.WithMediaType(ocispec.MediaTypeImageLayerGzip))
+	if err != nil {
+		return "", fmt.Errorf("unable to diff snapshot: %w", err)
+	}
+
+	ra, err := client.ContentStore().ReaderAt(ctx, desc)
+	if err != nil {
+		return "", fmt.Errorf("unable to read snapshot diff: %w", err)
+	}
+	defer ra.Close()
+
+	tempDir, err := p.tmpDirGen.NewDirectory("containerd-snapshot-diff")
+	if err != nil {
+		return "", err
+	}
+
+	fh, err := os.Create(path.Join(tempDir, "layer.tar.gz"))
+	if err != nil {
+		return "", fmt.Errorf("unable to create temp file for snapshot diff: %w", err)
+	}
+	defer func() {
+		if err := fh.Close(); err != nil {
+			log.Errorf("unable to close temp file (%s): %w", fh.Name(), err)
+		}
+	}()
+
+	if _, err := io.Copy(fh, content.NewReader(ra)); err != nil {
+		return "", fmt.Errorf("unable to write snapshot diff: %w", err)
+	}
+
+	return fh.Name(), nil
+}
+
+func (p *snapshotImageProvider) read(ctx context.Context, img v1.Image, metadata ...image.AdditionalMetadata) (*image.Image, error) {
+	contentTempDir, err := p.tmpDirGen.NewDirectory("containerd-snapshot-image")
+	if err != nil {
+		return nil, err
+	}
+
+	out := image.New(img, p.tmpDirGen, contentTempDir, metadata...)
+	if err := out.ReadContext(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,126 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/scylladb/go-set/strset"
+)
+
+// DockerHubNotaryServer is the notary server holding the trust data of Docker Hub repositories.
+const DockerHubNotaryServer = "https://notary.docker.io"
+
+// ErrContentTrust is returned when the tag of an image is not signed with Docker Content Trust.
+var ErrContentTrust = errors.New("content trust verification failed")
+
+// ContentTrustPolicy describes the registries whose tags are verified with Docker Content Trust (Notary v1) before
+// pulling (see ContextWithContentTrustPolicy). The tag is resolved to the manifest digest signed within the trust data
+// of the repository, which is pulled instead of the (mutable) tag.
+type ContentTrustPolicy struct {
+	Registries []ContentTrustRegistry
+
+	// TrustDir is the directory where the root metadata of each repository is persisted once trusted. The root metadata
+	// of repositories of registries without pinned root keys is trusted on first use, and every later root metadata
+	// must be a rotation signed by the keys of the persisted root metadata (of the same or a newer version). Required
+	// unless all registries pin their root keys.
+	TrustDir string
+}
+
+// ContentTrustRegistry describes the notary server holding the trust data of the repositories of a registry.
+type ContentTrustRegistry struct {
+	// Authority is the registry (e.g. "docker.io" or "registry.example.com:5000"), where an empty authority applies to
+	// all registries without a more specific entry
+	Authority string
+
+	// Server is the URL of the notary server (defaults to DockerHubNotaryServer for Docker Hub)
+	Server string
+
+	// RootKeyIDs pin the root keys of the trust data, where the root metadata must be signed by any of these keys
+	// (e.g. the root key ID shown by 'docker trust inspect'). When not given, the root metadata is trusted on first use
+	// (see ContentTrustPolicy.TrustDir).
+	RootKeyIDs []string
+}
+
+// Validate returns an error when the policy does not describe the notary server (over https) of every registry, or
+// how the root metadata of every registry is trusted.
+func (p ContentTrustPolicy) Validate() error {
+	if len(p.Registries) == 0 {
+		return fmt.Errorf("content trust policy requires at least one registry")
+	}
+
+	authorities := strset.New()
+	for _, r := range p.Registries {
+		if authorities.Has(r.Authority) {
+			return fmt.Errorf("content trust policy has multiple entries for registry %q", r.Authority)
+		}
+		authorities.Add(r.Authority)
+
+		if len(r.RootKeyIDs) == 0 && p.TrustDir == "" {
+			return fmt.Errorf("content trust policy requires pinned root keys or a trust directory for registry %q", r.Authority)
+		}
+
+		server := r.Server
+		if server == "" {
+			if !isDockerHub(r.Authority) {
+				return fmt.Errorf("content trust policy requires a notary server for registry %q", r.Authority)
+			}
+			continue
+		}
+		u, err := url.Parse(server)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("invalid notary server URL %q for registry %q (an https URL is required)", server, r.Authority)
+		}
+	}
+	return nil
+}
+
+// ForRegistry returns the content trust configuration of the given registry (with the notary server defaulted),
+// reporting false when tags of the registry are not verified.
+func (p ContentTrustPolicy) ForRegistry(registry string) (ContentTrustRegistry, bool) {
+	var fallback *ContentTrustRegistry
+	for i, r := range p.Registries {
+		switch {
+		case r.Authority == registry, isDockerHub(r.Authority) && isDockerHub(registry):
+			return r.withDefaultServer(registry), true
+		case r.Authority == "":
+			fallback = &p.Registries[i]
+		}
+	}
+	if fallback == nil {
+		return ContentTrustRegistry{}, false
+	}
+	r := fallback.withDefaultServer(registry)
+	return r, r.Server != ""
+}
+
+func (r ContentTrustRegistry) withDefaultServer(registry string) ContentTrustRegistry {
+	if r.Server == "" && isDockerHub(registry) {
+		r.Server = DockerHubNotaryServer
+	}
+	return r
+}
+
+func isDockerHub(registry string) bool {
+	switch registry {
+	case "docker.io", "index.docker.io", "registry-1.docker.io":
+		return true
+	}
+	return false
+}
+
+type contentTrustPolicyKey struct{}
+
+// ContextWithContentTrustPolicy returns a context where registry providers resolve tags of the registries within the
+// policy to the digest signed within the Docker Content Trust data of the repository before pulling, failing with
+// ErrContentTrust when the tag is not signed.
+func ContextWithContentTrustPolicy(ctx context.Context, policy *ContentTrustPolicy) context.Context {
+	return context.WithValue(ctx, contentTrustPolicyKey{}, policy)
+}
+
+// ContentTrustPolicyFromContext returns the content trust policy tags must satisfy (nil when tags are not verified).
+func ContentTrustPolicyFromContext(ctx context.Context) *ContentTrustPolicy {
+	policy, _ := ctx.Value(contentTrustPolicyKey{}).(*ContentTrustPolicy)
+	return policy
+}
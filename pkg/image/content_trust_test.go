@@ -0,0 +1,89 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentTrustPolicy_ForRegistry(t *testing.T) {
+	policy := ContentTrustPolicy{Registries: []ContentTrustRegistry{
+		{Authority: "docker.io", RootKeyIDs: []string{"abc"}},
+		{Authority: "registry.example.com", Server: "https://notary.example.com"},
+		{Server: "https://notary.internal"},
+	}, TrustDir: t.TempDir()}
+	require.NoError(t, policy.Validate())
+
+	tests := []struct {
+		registry   string
+		wantServer string
+	}{
+		{registry: "index.docker.io", wantServer: DockerHubNotaryServer},
+		{registry: "registry.example.com", wantServer: "https://notary.example.com"},
+		{registry: "other.example.com", wantServer: "https://notary.internal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.registry, func(t *testing.T) {
+			trust, ok := policy.ForRegistry(tt.registry)
+			require.True(t, ok)
+			assert.Equal(t, tt.wantServer, trust.Server)
+		})
+	}
+
+	_, ok := ContentTrustPolicy{Registries: []ContentTrustRegistry{{Authority: "docker.io"}}, TrustDir: t.TempDir()}.ForRegistry("registry.example.com")
+	assert.False(t, ok)
+}
+
+func TestContentTrustPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  ContentTrustPolicy
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:    "docker hub with default server",
+			policy:  ContentTrustPolicy{Registries: []ContentTrustRegistry{{Authority: "docker.io"}}, TrustDir: "/trust"},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "pinned root keys without a trust directory",
+			policy:  ContentTrustPolicy{Registries: []ContentTrustRegistry{{Authority: "docker.io", RootKeyIDs: []string{"abc"}}}},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "neither pinned root keys nor a trust directory",
+			policy:  ContentTrustPolicy{Registries: []ContentTrustRegistry{{Authority: "docker.io"}}},
+			wantErr: require.Error,
+		},
+		{
+			name:    "plain http server",
+			policy:  ContentTrustPolicy{Registries: []ContentTrustRegistry{{Authority: "registry.example.com", Server: "http://notary.example.com"}}, TrustDir: "/trust"},
+			wantErr: require.Error,
+		},
+		{
+			name:    "no registries",
+			wantErr: require.Error,
+		},
+		{
+			name:    "missing server",
+			policy:  ContentTrustPolicy{Registries: []ContentTrustRegistry{{Authority: "registry.example.com"}}, TrustDir: "/trust"},
+			wantErr: require.Error,
+		},
+		{
+			name:    "invalid server",
+			policy:  ContentTrustPolicy{Registries: []ContentTrustRegistry{{Authority: "registry.example.com", Server: "notary.example.com"}}, TrustDir: "/trust"},
+			wantErr: require.Error,
+		},
+		{
+			name:    "duplicate registry",
+			policy:  ContentTrustPolicy{Registries: []ContentTrustRegistry{{Authority: "docker.io"}, {Authority: "docker.io"}}, TrustDir: "/trust"},
+			wantErr: require.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.wantErr(t, tt.policy.Validate())
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package cri
+
+import (
+	"context"
+	"fmt"
+
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+
+	criClient "github.com/anchore/stereoscope/internal/cri"
+	"github.com/anchore/stereoscope/internal/environ"
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/anchore/stereoscope/pkg/image/containerd"
+	"github.com/anchore/stereoscope/pkg/image/crio"
+)
+
+const Daemon image.Source = image.CRIDaemonSource
+
+const (
+	containerdRuntime = "containerd"
+	crioRuntime       = "cri-o"
+	// k8sNamespace is the containerd namespace that the containerd CRI plugin keeps all images within
+	k8sNamespace = "k8s.io"
+)
+
+// NewDaemonProvider creates a new provider instance for an image already present on a Kubernetes node, found through
+// the CRI API of the node container runtime (see CONTAINER_RUNTIME_ENDPOINT). The CRI API does not allow for exporting
+// images, thus the image contents are read from the runtime behind the CRI endpoint (the containerd API on the same
+// socket, or the CRI-O containers-storage). Images are never pulled.
+func NewDaemonProvider(tmpDirGen *file.TempDirGenerator, registryOptions image.RegistryOptions, imageStr string, platform *image.Platform) image.Provider {
+	return &daemonImageProvider{
+		imageStr:        imageStr,
+		tmpDirGen:       tmpDirGen,
+		platform:        platform,
+		registryOptions: registryOptions,
+	}
+}
+
+// daemonImageProvider is an image.Provider for images listed by the CRI API of a Kubernetes node.
+type daemonImageProvider struct {
+	imageStr        string
+	tmpDirGen       *file.TempDirGenerator
+	platform        *image.Platform
+	registryOptions image.RegistryOptions
+}
+
+func (p *daemonImageProvider) Name() string {
+	return Daemon
+}
+
+// Provide an image object that represents the image found through the CRI API.
+func (p *daemonImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	if p.imageStr == "" {
+		return nil, fmt.Errorf("no image name or ID provided")
+	}
+
+	env := environ.FromContext(ctx)
+	client, err := criClient.GetClientWithOverrides(ctx, env)
+	if err != nil {
+		return nil, image.MarkError(fmt.Errorf("CRI API not available: %w", err), image.ErrDaemonUnavailable)
+	}
+	defer func() {
+		if err := client.Close(); err != nil {
+			log.Errorf("unable to close CRI client: %+v", err)
+		}
+	}()
+
+	status, err := client.Images.ImageStatus(ctx, &runtimeapi.ImageStatusRequest{
+		Image: &runtimeapi.ImageSpec{Image: p.imageStr},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get image status from CRI API: %w", err)
+	}
+
+	if status.Image == nil {
+		return nil, fmt.Errorf("image %q not found on node (%s)", p.imageStr, client.Endpoint)
+	}
+
+	ref := imageReference(status.Image)
+	log.WithFields("image", p.imageStr, "id", status.Image.Id, "reference", ref, "runtime", client.RuntimeName).Debug("found image through CRI API")
+
+	// the image must be provided as found on the node, never pulled into the runtime (e.g. when the requested platform
+	// is missing from the image present on the node)
+	ctx = image.ContextWithReadOnlyDaemon(ctx)
+
+	var provider image.Provider
+	switch client.RuntimeName {
+	case containerdRuntime:
+		ctx = environ.WithOverrides(ctx, env.WithVar("CONTAINERD_ADDRESS", client.SocketPath()))
+		provider = containerd.NewDaemonProvider(p.tmpDirGen, p.registryOptions, k8sNamespace, ref, p.platform)
+	case crioRuntime:
+		provider = crio.NewDaemonProvider(p.tmpDirGen, status.Image.Id, p.platform)
+	default:
+		return nil, fmt.Errorf("unsupported CRI runtime %q (only %q and %q are supported)", client.RuntimeName, containerdRuntime, crioRuntime)
+	}
+
+	return provider.Provide(ctx)
+}
+
+// imageReference returns the most specific name of the image known to the runtime.
+func imageReference(img *runtimeapi.Image) string {
+	switch {
+	case len(img.RepoTags) > 0:
+		return img.RepoTags[0]
+	case len(img.RepoDigests) > 0:
+		return img.RepoDigests[0]
+	default:
+		return img.Id
+	}
+}
+
+// ImageSummary describes an image present on a Kubernetes node.
+type ImageSummary struct {
+	ID          string
+	RepoTags    []string
+	RepoDigests []string
+	// Size is the size of the image in bytes (as reported by the runtime)
+	Size uint64
+}
+
+// ListImages returns all images present on the node, as reported by the CRI API (see NewDaemonProvider).
+func ListImages(ctx context.Context) ([]ImageSummary, error) {
+	client, err := criClient.GetClientWithOverrides(ctx, environ.FromContext(ctx))
+	if err != nil {
+		return nil, image.MarkError(fmt.Errorf("CRI API not available: %w", err), image.ErrDaemonUnavailable)
+	}
+	defer func() {
+		if err := client.Close(); err != nil {
+			log.Errorf("unable to close CRI client: %+v", err)
+		}
+	}()
+
+	resp, err := client.Images.ListImages(ctx, &runtimeapi.ListImagesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list images from CRI API: %w", err)
+	}
+
+	var out []ImageSummary
+	for _, img := range resp.Images {
+		out = append(out, ImageSummary{
+			ID:          img.Id,
+			RepoTags:    img.RepoTags,
+			RepoDigests: img.RepoDigests,
+			Size:        img.Size_,
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,172 @@
+package cri
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func TestDaemonProvider_Provide(t *testing.T) {
+	images := []*runtimeapi.Image{
+		{Id: "sha256:abc", RepoTags: []string{"docker.io/library/app:v1"}, RepoDigests: []string{"docker.io/library/app@sha256:def"}, Size_: 42},
+	}
+
+	tests := []struct {
+		name    string
+		runtime string
+		input   string
+		wantErr string
+	}{
+		{
+			name:    "image not on node",
+			runtime: containerdRuntime,
+			input:   "app:v2",
+			wantErr: `image "app:v2" not found on node`,
+		},
+		{
+			name:    "unsupported runtime",
+			runtime: "fake-runtime",
+			input:   "app:v1",
+			wantErr: `unsupported CRI runtime "fake-runtime"`,
+		},
+		{
+			// the fake runtime does not serve the containerd API, thus the image is not found within containerd
+			name:    "delegates to containerd without pulling",
+			runtime: containerdRuntime,
+			input:   "app:v1",
+			wantErr: image.ErrDaemonMutation.Error(),
+		},
+		{
+			name:    "delegates to cri-o",
+			runtime: crioRuntime,
+			input:   "app:v1",
+			wantErr: "containers-storage not available",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := fakeCRI(t, tt.runtime, images)
+
+			tmpDirGen := file.NewTempDirGenerator("cri-test")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			_, err := NewDaemonProvider(tmpDirGen, image.RegistryOptions{}, tt.input, nil).Provide(ctx)
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestListImages(t *testing.T) {
+	ctx := fakeCRI(t, containerdRuntime, []*runtimeapi.Image{
+		{Id: "sha256:abc", RepoTags: []string{"docker.io/library/app:v1"}, Size_: 42},
+		{Id: "sha256:123", RepoDigests: []string{"quay.io/org/tool@sha256:456"}, Size_: 7},
+	})
+
+	got, err := ListImages(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []ImageSummary{
+		{ID: "sha256:abc", RepoTags: []string{"docker.io/library/app:v1"}, Size: 42},
+		{ID: "sha256:123", RepoDigests: []string{"quay.io/org/tool@sha256:456"}, Size: 7},
+	}, got)
+}
+
+func Test_imageReference(t *testing.T) {
+	tests := []struct {
+		name string
+		img  *runtimeapi.Image
+		want string
+	}{
+		{
+			name: "tag",
+			img:  &runtimeapi.Image{Id: "sha256:abc", RepoTags: []string{"app:v1"}, RepoDigests: []string{"app@sha256:def"}},
+			want: "app:v1",
+		},
+		{
+			name: "digest",
+			img:  &runtimeapi.Image{Id: "sha256:abc", RepoDigests: []string{"app@sha256:def"}},
+			want: "app@sha256:def",
+		},
+		{
+			name: "ID",
+			img:  &runtimeapi.Image{Id: "sha256:abc"},
+			want: "sha256:abc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, imageReference(tt.img))
+		})
+	}
+}
+
+// fakeCRI serves the CRI API for the given images, returning a context that directs all CRI clients (and the
+// delegated CRI-O provider) to the fake runtime.
+func fakeCRI(t *testing.T, runtimeName string, images []*runtimeapi.Image) context.Context {
+	t.Helper()
+
+	// note: unix socket paths are limited in length, thus t.TempDir is not used
+	dir, err := os.MkdirTemp("", "cri")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "cri.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	runtimeapi.RegisterRuntimeServiceServer(srv, &fakeRuntimeService{name: runtimeName})
+	runtimeapi.RegisterImageServiceServer(srv, &fakeImageService{images: images})
+	go func() { _ = srv.Serve(listener) }()
+	t.Cleanup(srv.Stop)
+
+	storageConf := filepath.Join(dir, "storage.conf")
+	require.NoError(t, os.WriteFile(storageConf, []byte(fmt.Sprintf("[storage]\ngraphroot = %q\n", dir)), 0600))
+
+	return image.ContextWithEnvOverrides(context.Background(), &image.EnvOverrides{
+		Hermetic: true,
+		Vars: map[string]string{
+			"CONTAINER_RUNTIME_ENDPOINT": "unix://" + socket,
+			"CONTAINERS_STORAGE_CONF":    storageConf,
+		},
+	})
+}
+
+type fakeRuntimeService struct {
+	runtimeapi.UnimplementedRuntimeServiceServer
+	name string
+}
+
+func (s *fakeRuntimeService) Version(context.Context, *runtimeapi.VersionRequest) (*runtimeapi.VersionResponse, error) {
+	return &runtimeapi.VersionResponse{RuntimeName: s.name, RuntimeVersion: "1.0.0", RuntimeApiVersion: "v1"}, nil
+}
+
+type fakeImageService struct {
+	runtimeapi.UnimplementedImageServiceServer
+	images []*runtimeapi.Image
+}
+
+func (s *fakeImageService) ListImages(context.Context, *runtimeapi.ListImagesRequest) (*runtimeapi.ListImagesResponse, error) {
+	return &runtimeapi.ListImagesResponse{Images: s.images}, nil
+}
+
+func (s *fakeImageService) ImageStatus(_ context.Context, req *runtimeapi.ImageStatusRequest) (*runtimeapi.ImageStatusResponse, error) {
+	for _, img := range s.images {
+		for _, tag := range img.RepoTags {
+			if tag == req.Image.Image || tag == "docker.io/library/"+req.Image.Image {
+				return &runtimeapi.ImageStatusResponse{Image: img}, nil
+			}
+		}
+	}
+	return &runtimeapi.ImageStatusResponse{}, nil
+}
@@ -0,0 +1,226 @@
+package crio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/anchore/stereoscope/internal/environ"
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+const Daemon image.Source = image.CrioDaemonSource
+
+// NewDaemonProvider creates a new provider instance for an image within the local containers-storage used by CRI-O
+// (by default the overlay storage at /var/lib/containers/storage, see /etc/containers/storage.conf). Images are read
+// directly from storage, thus CRI-O does not need to be running and images are never pulled.
+func NewDaemonProvider(tmpDirGen *file.TempDirGenerator, imageStr string, platform *image.Platform) image.Provider {
+	return &daemonImageProvider{
+		imageStr:  imageStr,
+		tmpDirGen: tmpDirGen,
+		platform:  platform,
+	}
+}
+
+// daemonImageProvider is an image.Provider for images within containers-storage (as used by CRI-O).
+type daemonImageProvider struct {
+	imageStr  string
+	tmpDirGen *file.TempDirGenerator
+	platform  *image.Platform
+}
+
+func (p *daemonImageProvider) Name() string {
+	return Daemon
+}
+
+// Provide an image object that represents the image within containers-storage.
+func (p *daemonImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	if p.imageStr == "" {
+		return nil, fmt.Errorf("no image name or ID provided")
+	}
+
+	s, err := openStore(environ.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := s.find(p.imageStr)
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithFields("image", p.imageStr, "id", found.ID, "root", s.root).Debug("providing image from containers-storage")
+
+	img, rawManifest, err := newStorageImage(s, *found)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read image config: %w", err)
+	}
+
+	if p.platform != nil && !p.platform.Matches(cfg.OS, cfg.Architecture, cfg.Variant) {
+		return nil, image.MarkError(fmt.Errorf("image %q does not match the requested platform %q (found %s)", p.imageStr, p.platform.String(), cfg.Platform().String()), image.ErrPlatformMismatch)
+	}
+
+	metadata := []image.AdditionalMetadata{
+		image.WithTags(found.Names...),
+		image.WithRepoDigests(found.repoDigests()...),
+		image.WithArchitecture(cfg.Architecture, cfg.Variant),
+		image.WithOS(cfg.OS),
+	}
+	if rawManifest != nil {
+		metadata = append(metadata, image.WithManifest(rawManifest))
+	}
+
+	contentTempDir, err := p.tmpDirGen.NewDirectory("crio-image")
+	if err != nil {
+		return nil, err
+	}
+
+	out := image.New(img, p.tmpDirGen, contentTempDir, metadata...)
+	if err := out.ReadContext(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// newStorageImage creates an image from the layers within containers-storage, along with the raw image manifest when
+// the manifest stored for the image describes the image config.
+func newStorageImage(s *store, img storageImage) (v1.Image, []byte, error) {
+	rawConfig, err := s.bigData(img, "sha256:"+img.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read config for image %q: %w", img.ID, err)
+	}
+
+	chain, err := s.layerChain(img)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	core := &storageImageCore{
+		rawConfig: rawConfig,
+		mediaType: types.DockerManifestSchema2,
+		layers:    make(map[v1.Hash]*storageLayerCore),
+	}
+
+	rawManifest := imageManifest(s, img, rawConfig)
+	if rawManifest != nil {
+		if manifest, err := v1.ParseManifest(bytes.NewReader(rawManifest)); err == nil && manifest.MediaType != "" {
+			core.mediaType = manifest.MediaType
+		}
+	}
+
+	for _, l := range chain {
+		diffID, err := v1.NewHash(l.UncompressedDigest)
+		if err != nil {
+			return nil, nil, fmt.Errorf("layer %q has no valid uncompressed digest: %w", l.ID, err)
+		}
+		core.layers[diffID] = &storageLayerCore{store: s, layer: l, diffID: diffID}
+	}
+
+	uncompressed, err := partial.UncompressedToImage(core)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if rawManifest == nil {
+		return uncompressed, nil, nil
+	}
+
+	return &storageV1Image{Image: uncompressed, rawManifest: rawManifest}, rawManifest, nil
+}
+
+// imageManifest returns the manifest stored for the image, but only when it is an image manifest referencing the given
+// image config (not a manifest list or a manifest for another platform).
+func imageManifest(s *store, img storageImage, rawConfig []byte) []byte {
+	rawManifest, err := s.bigData(img, manifestKey)
+	if err != nil {
+		log.WithFields("id", img.ID, "error", err).Trace("no manifest found in containers-storage")
+		return nil
+	}
+
+	manifest, err := v1.ParseManifest(bytes.NewReader(rawManifest))
+	if err != nil {
+		return nil
+	}
+
+	configDigest, _, err := v1.SHA256(bytes.NewReader(rawConfig))
+	if err != nil || manifest.Config.Digest != configDigest {
+		return nil
+	}
+	return rawManifest
+}
+
+// storageImageCore is a partial.UncompressedImageCore for an image within containers-storage.
+type storageImageCore struct {
+	rawConfig []byte
+	mediaType types.MediaType
+	layers    map[v1.Hash]*storageLayerCore
+}
+
+var _ partial.UncompressedImageCore = (*storageImageCore)(nil)
+
+func (c *storageImageCore) RawConfigFile() ([]byte, error) {
+	return c.rawConfig, nil
+}
+
+func (c *storageImageCore) MediaType() (types.MediaType, error) {
+	return c.mediaType, nil
+}
+
+func (c *storageImageCore) LayerByDiffID(h v1.Hash) (partial.UncompressedLayer, error) {
+	l, ok := c.layers[h]
+	if !ok {
+		return nil, fmt.Errorf("layer with diff ID %v not found in containers-storage", h)
+	}
+	return l, nil
+}
+
+// storageLayerCore is a partial.UncompressedLayer for a layer within containers-storage.
+type storageLayerCore struct {
+	store  *store
+	layer  storageLayer
+	diffID v1.Hash
+}
+
+func (l *storageLayerCore) DiffID() (v1.Hash, error) {
+	return l.diffID, nil
+}
+
+func (l *storageLayerCore) Uncompressed() (io.ReadCloser, error) {
+	return l.store.openLayer(l.layer)
+}
+
+func (l *storageLayerCore) MediaType() (types.MediaType, error) {
+	return types.DockerLayer, nil
+}
+
+// storageV1Image is an image that describes itself with the manifest stored within containers-storage, which avoids
+// recompressing every layer to compute a manifest.
+type storageV1Image struct {
+	v1.Image
+	rawManifest []byte
+}
+
+func (i *storageV1Image) RawManifest() ([]byte, error) {
+	return i.rawManifest, nil
+}
+
+func (i *storageV1Image) Manifest() (*v1.Manifest, error) {
+	return v1.ParseManifest(bytes.NewReader(i.rawManifest))
+}
+
+func (i *storageV1Image) Digest() (v1.Hash, error) {
+	h, _, err := v1.SHA256(bytes.NewReader(i.rawManifest))
+	return h, err
+}
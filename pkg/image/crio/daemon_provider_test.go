@@ -0,0 +1,286 @@
+package crio
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vbatts/tar-split/tar/asm"
+	tarStorage "github.com/vbatts/tar-split/tar/storage"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func TestDaemonProvider_Provide(t *testing.T) {
+	root := newTestStore(t)
+	base := root.addLayer(t, "", map[string]string{"etc/os-release": "ID=test\n", "etc/secret": "token=abc\n"})
+	top := root.addLayer(t, base.ID, map[string]string{"etc/.wh.secret": "", "app/run.sh": "#!/bin/sh\n"})
+	id := root.addImage(t, top, "linux", "amd64", "docker.io/library/app:v1")
+
+	tests := []struct {
+		name     string
+		input    string
+		platform string
+		wantErr  require.ErrorAssertionFunc
+	}{
+		{
+			name:    "short name",
+			input:   "app:v1",
+			wantErr: require.NoError,
+		},
+		{
+			name:    "image ID",
+			input:   "sha256:" + id,
+			wantErr: require.NoError,
+		},
+		{
+			name:    "image ID prefix",
+			input:   id[:12],
+			wantErr: require.NoError,
+		},
+		{
+			name:    "missing tag",
+			input:   "app:v2",
+			wantErr: require.Error,
+		},
+		{
+			name:     "platform mismatch",
+			input:    "app:v1",
+			platform: "linux/arm64",
+			wantErr:  require.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var platform *image.Platform
+			if tt.platform != "" {
+				var err error
+				platform, err = image.NewPlatform(tt.platform)
+				require.NoError(t, err)
+			}
+
+			tmpDirGen := file.NewTempDirGenerator("crio-test")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			img, err := NewDaemonProvider(tmpDirGen, tt.input, platform).Provide(root.context())
+			tt.wantErr(t, err)
+			if err != nil {
+				return
+			}
+
+			assert.Equal(t, "sha256:"+id, img.Metadata.ID)
+			require.Len(t, img.Metadata.Tags, 1)
+			assert.Equal(t, "index.docker.io/library/app:v1", img.Metadata.Tags[0].Name())
+			require.Len(t, img.Layers, 2)
+			assert.Equal(t, base.UncompressedDigest, img.Layers[0].Metadata.Digest)
+			assert.Equal(t, top.UncompressedDigest, img.Layers[1].Metadata.Digest)
+
+			contents, err := img.OpenPathFromSquash("/etc/os-release")
+			require.NoError(t, err)
+			b, err := io.ReadAll(contents)
+			require.NoError(t, err)
+			assert.Equal(t, "ID=test\n", string(b))
+
+			assert.False(t, img.SquashedTree().HasPath("/etc/secret"))
+			assert.True(t, img.SquashedTree().HasPath("/app/run.sh"))
+		})
+	}
+}
+
+func Test_openStore(t *testing.T) {
+	t.Run("unsupported driver", func(t *testing.T) {
+		conf := filepath.Join(t.TempDir(), "storage.conf")
+		require.NoError(t, os.WriteFile(conf, []byte("[storage]\ndriver = \"vfs\"\n"), 0600))
+
+		_, err := openStore(&image.EnvOverrides{Hermetic: true, Vars: map[string]string{"CONTAINERS_STORAGE_CONF": conf}})
+		require.ErrorContains(t, err, "unsupported containers-storage driver")
+	})
+
+	t.Run("missing graph root", func(t *testing.T) {
+		conf := filepath.Join(t.TempDir(), "storage.conf")
+		require.NoError(t, os.WriteFile(conf, []byte(fmt.Sprintf("[storage]\ngraphroot = %q\n", t.TempDir())), 0600))
+
+		_, err := openStore(&image.EnvOverrides{Hermetic: true, Vars: map[string]string{"CONTAINERS_STORAGE_CONF": conf}})
+		require.ErrorContains(t, err, "containers-storage not available")
+	})
+}
+
+func Test_store_find(t *testing.T) {
+	s := &store{}
+	images := []storageImage{
+		{ID: "abc111", Names: []string{"docker.io/library/alpine:latest"}, Digest: "sha256:aaaa"},
+		{ID: "abc222", Names: []string{"localhost/built:dev"}},
+		{ID: "def333", Names: []string{"quay.io/org/app:v1"}},
+	}
+
+	root := t.TempDir()
+	s.root, s.driver = root, overlayDriver
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "overlay-images"), 0755))
+	writeJSON(t, s.imagesPath(), images)
+
+	tests := []struct {
+		input   string
+		want    string
+		wantErr string
+	}{
+		{input: "alpine", want: "abc111"},
+		{input: "docker.io/library/alpine:latest", want: "abc111"},
+		{input: "alpine@sha256:aaaa", wantErr: "unable to find image"},
+		{input: "built:dev", want: "abc222"},
+		{input: "quay.io/org/app:v1", want: "def333"},
+		{input: "def333", want: "def333"},
+		{input: "sha256:def", want: "def333"},
+		{input: "abc", wantErr: "ambiguous"},
+		{input: "app:v1", wantErr: "unable to find image"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := s.find(tt.input)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.ID)
+		})
+	}
+}
+
+func Test_bigDataFileName(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "manifest", want: "manifest"},
+		{key: "sha256:abc", want: "=c2hhMjU2OmFiYw=="},
+		{key: "manifest-sha256:abc", want: "=bWFuaWZlc3Qtc2hhMjU2OmFiYw=="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.want, bigDataFileName(tt.key))
+		})
+	}
+}
+
+func Test_storageImage_repoDigests(t *testing.T) {
+	img := storageImage{
+		Digest: "sha256:aaaa",
+		Names:  []string{"docker.io/library/alpine:latest", "docker.io/library/alpine:3", "quay.io/org/app:v1"},
+	}
+	assert.Equal(t, []string{"docker.io/library/alpine@sha256:aaaa", "quay.io/org/app@sha256:aaaa"}, img.repoDigests())
+}
+
+// testStore is an overlay graph root laid out the same way as containers-storage.
+type testStore struct {
+	conf   string
+	root   string
+	layers []storageLayer
+}
+
+func newTestStore(t *testing.T) *testStore {
+	dir := t.TempDir()
+	s := testStore{
+		conf: filepath.Join(dir, "storage.conf"),
+		root: filepath.Join(dir, "storage"),
+	}
+
+	for _, d := range []string{"overlay", "overlay-images", "overlay-layers"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(s.root, d), 0755))
+	}
+	require.NoError(t, os.WriteFile(s.conf, []byte(fmt.Sprintf("[storage]\ndriver = \"overlay\"\ngraphroot = %q\n", s.root)), 0600))
+	return &s
+}
+
+func (s *testStore) context() context.Context {
+	return image.ContextWithEnvOverrides(context.Background(), &image.EnvOverrides{
+		Hermetic: true,
+		Vars:     map[string]string{"CONTAINERS_STORAGE_CONF": s.conf},
+	})
+}
+
+// addLayer stores the layer the same way that containers-storage does: the layer files within a diff directory and
+// the tar headers (and padding) within the tar-split metadata.
+func (s *testStore) addLayer(t *testing.T, parent string, files map[string]string) storageLayer {
+	id := fmt.Sprintf("%064d", len(s.layers)+1)
+	diffDir := filepath.Join(s.root, "overlay", id, "diff")
+	require.NoError(t, os.MkdirAll(diffDir, 0755))
+
+	var names []string
+	for n := range files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	layerTar := &bytes.Buffer{}
+	tw := tar.NewWriter(layerTar)
+	for _, n := range names {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: n, Mode: 0644, Size: int64(len(files[n])), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(files[n]))
+		require.NoError(t, err)
+
+		require.NoError(t, os.MkdirAll(filepath.Join(diffDir, filepath.Dir(n)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(diffDir, n), []byte(files[n]), 0644))
+	}
+	require.NoError(t, tw.Close())
+
+	diffID, size, err := v1.SHA256(bytes.NewReader(layerTar.Bytes()))
+	require.NoError(t, err)
+
+	tarSplit, err := os.Create(filepath.Join(s.root, "overlay-layers", id+".tar-split.gz"))
+	require.NoError(t, err)
+	gz := gzip.NewWriter(tarSplit)
+	stream, err := asm.NewInputTarStream(bytes.NewReader(layerTar.Bytes()), tarStorage.NewJSONPacker(gz), nil)
+	require.NoError(t, err)
+	_, err = io.Copy(io.Discard, stream)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, tarSplit.Close())
+
+	l := storageLayer{ID: id, Parent: parent, UncompressedDigest: diffID.String(), UncompressedSize: size}
+	s.layers = append(s.layers, l)
+	writeJSON(t, filepath.Join(s.root, "overlay-layers", "layers.json"), s.layers)
+	return l
+}
+
+// addImage stores an image config (keyed by the image ID) referencing all layers up to the given top layer.
+func (s *testStore) addImage(t *testing.T, top storageLayer, goos, arch string, names ...string) string {
+	cfg := v1.ConfigFile{OS: goos, Architecture: arch, RootFS: v1.RootFS{Type: "layers"}}
+	for _, l := range s.layers {
+		h, err := v1.NewHash(l.UncompressedDigest)
+		require.NoError(t, err)
+		cfg.RootFS.DiffIDs = append(cfg.RootFS.DiffIDs, h)
+		if l.ID == top.ID {
+			break
+		}
+	}
+
+	rawConfig, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	id, _, err := v1.SHA256(bytes.NewReader(rawConfig))
+	require.NoError(t, err)
+
+	imgDir := filepath.Join(s.root, "overlay-images", id.Hex)
+	require.NoError(t, os.MkdirAll(imgDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(imgDir, bigDataFileName(id.String())), rawConfig, 0644))
+
+	writeJSON(t, filepath.Join(s.root, "overlay-images", "images.json"), []storageImage{{ID: id.Hex, Names: names, TopLayer: top.ID}})
+	return id.Hex
+}
+
+func writeJSON(t *testing.T, path string, v any) {
+	contents, err := json.Marshal(v)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, contents, 0644))
+}
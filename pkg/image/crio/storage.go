@@ -0,0 +1,338 @@
+package crio
+
+import (
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pelletier/go-toml"
+	"github.com/vbatts/tar-split/tar/asm"
+	tarStorage "github.com/vbatts/tar-split/tar/storage"
+
+	"github.com/anchore/stereoscope/internal/environ"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+const (
+	// defaultStorageConf is the containers-storage configuration used by CRI-O (and podman when run as root)
+	defaultStorageConf = "/etc/containers/storage.conf"
+	// defaultGraphRoot is where containers-storage keeps all images and layers when not configured otherwise
+	defaultGraphRoot = "/var/lib/containers/storage"
+	overlayDriver    = "overlay"
+	// manifestKey is the big data key of the image manifest within containers-storage
+	manifestKey = "manifest"
+)
+
+// storageConfig is the relevant subset of containers-storage configuration (storage.conf).
+type storageConfig struct {
+	Storage struct {
+		Driver    string `toml:"driver"`
+		GraphRoot string `toml:"graphroot"`
+	} `toml:"storage"`
+}
+
+// storageImage is an image record within <graphroot>/<driver>-images/images.json.
+type storageImage struct {
+	ID       string   `json:"id"`
+	Digest   string   `json:"digest,omitempty"`
+	Digests  []string `json:"digests,omitempty"`
+	Names    []string `json:"names,omitempty"`
+	TopLayer string   `json:"layer,omitempty"`
+}
+
+// storageLayer is a layer record within <graphroot>/<driver>-layers/layers.json.
+type storageLayer struct {
+	ID                 string `json:"id"`
+	Parent             string `json:"parent,omitempty"`
+	UncompressedDigest string `json:"diff-digest,omitempty"`
+	UncompressedSize   int64  `json:"diff-size,omitempty"`
+}
+
+// store is a read-only view of an overlay containers-storage graph root. Note: storage locks are not taken, thus images
+// that are being pulled or removed while being read may fail to be provided.
+type store struct {
+	root   string
+	driver string
+}
+
+// openStore finds the containers-storage graph root from the storage configuration (CONTAINERS_STORAGE_CONF or
+// /etc/containers/storage.conf), falling back to the default graph root.
+func openStore(env *environ.Overrides) (*store, error) {
+	confPath := defaultStorageConf
+	if v := env.Getenv("CONTAINERS_STORAGE_CONF"); v != "" {
+		confPath = v
+	}
+
+	s := store{
+		root:   defaultGraphRoot,
+		driver: overlayDriver,
+	}
+
+	contents, err := os.ReadFile(confPath)
+	switch {
+	case err == nil:
+		var cfg storageConfig
+		if err := toml.Unmarshal(contents, &cfg); err != nil {
+			return nil, fmt.Errorf("unable to parse containers-storage config %q: %w", confPath, err)
+		}
+		if cfg.Storage.GraphRoot != "" {
+			s.root = cfg.Storage.GraphRoot
+		}
+		if cfg.Storage.Driver != "" {
+			s.driver = cfg.Storage.Driver
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("unable to read containers-storage config %q: %w", confPath, err)
+	}
+
+	if s.driver != overlayDriver {
+		return nil, fmt.Errorf("unsupported containers-storage driver %q (only %q is supported)", s.driver, overlayDriver)
+	}
+
+	if _, err := os.Stat(s.imagesPath()); err != nil {
+		return nil, image.MarkError(fmt.Errorf("containers-storage not available at %q: %w", s.root, err), image.ErrDaemonUnavailable)
+	}
+
+	return &s, nil
+}
+
+func (s *store) imagesPath() string {
+	return filepath.Join(s.root, s.driver+"-images", "images.json")
+}
+
+func (s *store) images() ([]storageImage, error) {
+	var images []storageImage
+	if err := readJSON(s.imagesPath(), &images); err != nil {
+		return nil, fmt.Errorf("unable to read containers-storage images: %w", err)
+	}
+	return images, nil
+}
+
+// layers returns all layers by ID (including volatile layers, which are tracked separately).
+func (s *store) layers() (map[string]storageLayer, error) {
+	out := make(map[string]storageLayer)
+	for _, f := range []string{"layers.json", "volatile-layers.json"} {
+		var layers []storageLayer
+		err := readJSON(filepath.Join(s.root, s.driver+"-layers", f), &layers)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read containers-storage layers: %w", err)
+		}
+		for _, l := range layers {
+			out[l.ID] = l
+		}
+	}
+	return out, nil
+}
+
+// layerChain returns all layers of the image starting from the base layer.
+func (s *store) layerChain(img storageImage) ([]storageLayer, error) {
+	all, err := s.layers()
+	if err != nil {
+		return nil, err
+	}
+
+	var chain []storageLayer
+	for id := img.TopLayer; id != ""; {
+		l, ok := all[id]
+		if !ok {
+			return nil, fmt.Errorf("layer %q of image %q not found in containers-storage", id, img.ID)
+		}
+		chain = append([]storageLayer{l}, chain...)
+		id = l.Parent
+		if len(chain) > len(all) {
+			return nil, fmt.Errorf("layer cycle found for image %q", img.ID)
+		}
+	}
+	return chain, nil
+}
+
+// bigData reads the image big data item with the given key (e.g. the manifest or config).
+func (s *store) bigData(img storageImage, key string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.root, s.driver+"-images", img.ID, bigDataFileName(key)))
+}
+
+// openLayer reassembles the original (uncompressed) layer tar from the tar-split metadata and the layer diff
+// directory, resulting in the same contents (and digest) as the layer that was pulled.
+func (s *store) openLayer(l storageLayer) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(s.root, s.driver+"-layers", l.ID+".tar-split.gz"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open tar-split metadata for layer %q: %w", l.ID, err)
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("unable to read tar-split metadata for layer %q: %w", l.ID, err)
+	}
+
+	diffDir := filepath.Join(s.root, s.driver, l.ID, "diff")
+	stream := asm.NewOutputTarStream(tarStorage.NewPathFileGetter(diffDir), tarStorage.NewJSONUnpacker(gz))
+
+	return &layerReader{ReadCloser: stream, closers: []io.Closer{gz, f}}, nil
+}
+
+// layerReader closes the tar-split metadata along with the reassembled tar stream.
+type layerReader struct {
+	io.ReadCloser
+	closers []io.Closer
+}
+
+func (r *layerReader) Close() error {
+	err := r.ReadCloser.Close()
+	for _, c := range r.closers {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+// find returns the image matching the given user input, which may be an image ID (or unique ID prefix), a name
+// (e.g. "alpine:latest" or "quay.io/org/app:v1"), or a name with a digest.
+func (s *store) find(input string) (*storageImage, error) {
+	images, err := s.images()
+	if err != nil {
+		return nil, err
+	}
+
+	id := strings.TrimPrefix(input, "sha256:")
+	var byPrefix []storageImage
+	for _, img := range images {
+		if img.ID == id {
+			return &img, nil
+		}
+		if len(id) >= 3 && strings.HasPrefix(img.ID, id) {
+			byPrefix = append(byPrefix, img)
+		}
+	}
+
+	switch len(byPrefix) {
+	case 0:
+	case 1:
+		return &byPrefix[0], nil
+	default:
+		return nil, fmt.Errorf("image ID prefix %q is ambiguous (matches %d images)", id, len(byPrefix))
+	}
+
+	// short names are resolved against docker hub, then against "localhost" (where locally built images are named)
+	for _, registry := range []string{name.DefaultRegistry, "localhost"} {
+		ref, err := name.ParseReference(input, name.WithDefaultRegistry(registry))
+		if err != nil {
+			return nil, fmt.Errorf("unable to find image %q in containers-storage: %w", input, err)
+		}
+
+		for _, img := range images {
+			if img.matches(ref) {
+				return &img, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("unable to find image %q in containers-storage", input)
+}
+
+// matches indicates if the image is known by the given reference.
+func (img storageImage) matches(ref name.Reference) bool {
+	for _, n := range img.Names {
+		named, err := parseName(n)
+		if err != nil {
+			continue
+		}
+
+		if named.Context().Name() != ref.Context().Name() {
+			continue
+		}
+
+		switch r := ref.(type) {
+		case name.Tag:
+			if named.Identifier() == r.TagStr() {
+				return true
+			}
+		case name.Digest:
+			for _, d := range img.allDigests() {
+				if d == r.DigestStr() {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (img storageImage) allDigests() []string {
+	if img.Digest == "" {
+		return img.Digests
+	}
+	return append([]string{img.Digest}, img.Digests...)
+}
+
+// repoDigests returns "<repository>@<digest>" for each named repository of the image.
+func (img storageImage) repoDigests() []string {
+	if img.Digest == "" {
+		return nil
+	}
+
+	var out []string
+	seen := make(map[string]struct{})
+	for _, n := range img.Names {
+		named, err := parseName(n)
+		if err != nil {
+			continue
+		}
+		// names are stored fully qualified (e.g. "docker.io/library/alpine:latest"), which is preserved here
+		repo := strings.TrimSuffix(strings.TrimSuffix(n, "@"+named.Identifier()), ":"+named.Identifier())
+		repoDigest := fmt.Sprintf("%s@%s", repo, img.Digest)
+		if _, ok := seen[repoDigest]; ok {
+			continue
+		}
+		seen[repoDigest] = struct{}{}
+		out = append(out, repoDigest)
+	}
+	return out
+}
+
+// parseName parses a fully qualified image name as stored within containers-storage. Note: "localhost" is not
+// recognized as a registry host unless it is the default registry (it would otherwise be treated as a docker hub
+// namespace).
+func parseName(n string) (name.Reference, error) {
+	if rest, ok := strings.CutPrefix(n, "localhost/"); ok {
+		return name.ParseReference(rest, name.WithDefaultRegistry("localhost"))
+	}
+	return name.ParseReference(n)
+}
+
+// bigDataFileName is the name of the file that the big data item with the given key is stored in, where keys with
+// characters outside of [a-z0-9.] are base64 encoded (mirroring containers-storage, including that a single invalid
+// trailing character does not result in encoding).
+func bigDataFileName(key string) string {
+	reader := strings.NewReader(key)
+	for reader.Len() > 0 {
+		ch, size, err := reader.ReadRune()
+		if err != nil || size != 1 {
+			break
+		}
+		if ch != '.' && (ch < '0' || ch > '9') && (ch < 'a' || ch > 'z') {
+			break
+		}
+	}
+	if reader.Len() > 0 {
+		return "=" + base64.StdEncoding.EncodeToString([]byte(key))
+	}
+	return key
+}
+
+func readJSON(path string, v any) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(contents, v)
+}
@@ -0,0 +1,67 @@
+package image
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// DefaultExportStallTimeout is the longest time without receiving data from a daemon image export before the
+	// export is considered stalled.
+	DefaultExportStallTimeout = 5 * time.Minute
+
+	// DefaultExportRetries is the number of times a stalled daemon image export is requested again.
+	DefaultExportRetries = 2
+)
+
+// DaemonExportOptions configures how images are exported from the docker daemon (see ContextWithDaemonExportOptions).
+type DaemonExportOptions struct {
+	// StallTimeout is the longest time without receiving data from the export stream before the export is considered
+	// stalled (DefaultExportStallTimeout when zero, never when negative).
+	StallTimeout time.Duration
+
+	// Retries is the number of times a stalled export is requested again (DefaultExportRetries when zero, never when
+	// negative). Exports are always restarted from the beginning, since the daemon API cannot resume an export.
+	Retries int
+
+	// PlatformOnly exports only the platform of the image being provided instead of all platforms within the image
+	// store, when the daemon supports it (API version 1.48 and later).
+	PlatformOnly bool
+}
+
+// StallTimeoutOrDefault returns the effective stall timeout (zero when stall detection is disabled).
+func (o DaemonExportOptions) StallTimeoutOrDefault() time.Duration {
+	switch {
+	case o.StallTimeout < 0:
+		return 0
+	case o.StallTimeout == 0:
+		return DefaultExportStallTimeout
+	}
+	return o.StallTimeout
+}
+
+// RetriesOrDefault returns the effective number of retries for stalled exports.
+func (o DaemonExportOptions) RetriesOrDefault() int {
+	switch {
+	case o.Retries < 0:
+		return 0
+	case o.Retries == 0:
+		return DefaultExportRetries
+	}
+	return o.Retries
+}
+
+type daemonExportOptionsKey struct{}
+
+// ContextWithDaemonExportOptions returns a context where the docker daemon provider exports images with the given
+// options.
+func ContextWithDaemonExportOptions(ctx context.Context, opts DaemonExportOptions) context.Context {
+	return context.WithValue(ctx, daemonExportOptionsKey{}, opts)
+}
+
+// DaemonExportOptionsFromContext returns the daemon export options of the given context (the defaults when none were
+// given, see ContextWithDaemonExportOptions).
+func DaemonExportOptionsFromContext(ctx context.Context) DaemonExportOptions {
+	opts, _ := ctx.Value(daemonExportOptionsKey{}).(DaemonExportOptions)
+	return opts
+}
@@ -0,0 +1,78 @@
+package image_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/anchore/stereoscope/pkg/imagetest"
+)
+
+func readDeepImage(ctx context.Context, t *testing.T, cfg imagetest.DeepImageConfig, metadata ...image.AdditionalMetadata) (*image.Image, error) {
+	t.Helper()
+	tmpDirGen := file.NewTempDirGenerator("deep-image")
+	t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+	img := image.New(imagetest.DeepImage(t, cfg), tmpDirGen, t.TempDir(), metadata...)
+	return img, img.ReadContext(ctx)
+}
+
+func TestImage_MaxLayers(t *testing.T) {
+	cfg := imagetest.DeepImageConfig{Layers: 10, FilesPerLayer: 2, Seed: 1}
+
+	_, err := readDeepImage(context.Background(), t, cfg, image.WithMaxLayers(5))
+	var target *image.ErrTooManyLayers
+	require.ErrorAs(t, err, &target)
+	assert.Equal(t, 10, target.Layers)
+	assert.Equal(t, 5, target.Max)
+
+	// the limit may also be provided through the context
+	ctx := image.ContextWithReadMetadata(context.Background(), image.WithMaxLayers(5))
+	_, err = readDeepImage(ctx, t, cfg)
+	require.ErrorAs(t, err, &target)
+
+	img, err := readDeepImage(context.Background(), t, cfg, image.WithMaxLayers(10))
+	require.NoError(t, err)
+	assert.Len(t, img.Layers, 10)
+}
+
+func TestImage_ChunkedSquash(t *testing.T) {
+	for _, seed := range []int64{1, 2, 3} {
+		cfg := imagetest.DeepImageConfig{
+			Layers:        image.DeepImageLayerThreshold + 13,
+			FilesPerLayer: 4,
+			Paths:         12,
+			Seed:          seed,
+		}
+
+		expected, err := readDeepImage(context.Background(), t, cfg)
+		require.NoError(t, err)
+
+		chunked, err := readDeepImage(context.Background(), t, cfg, image.WithChunkedSquash(16))
+		require.NoError(t, err)
+
+		// the final squash must be identical regardless of the squash strategy
+		assert.ElementsMatch(t, expected.SquashedTree().AllRealPaths(), chunked.SquashedTree().AllRealPaths(), "seed=%d", seed)
+
+		// squash trees are exact at chunk boundaries
+		for idx := 15; idx < len(chunked.Layers); idx += 16 {
+			assert.ElementsMatch(t, expected.Layers[idx].SquashedTree.AllRealPaths(), chunked.Layers[idx].SquashedTree.AllRealPaths(), "seed=%d layer=%d", seed, idx)
+		}
+	}
+}
+
+func TestImage_ChunkedSquash_shallowImage(t *testing.T) {
+	cfg := imagetest.DeepImageConfig{Layers: 5, FilesPerLayer: 3, Seed: 1}
+
+	img, err := readDeepImage(context.Background(), t, cfg, image.WithChunkedSquash(16))
+	require.NoError(t, err)
+
+	// shallow images are always squashed one layer at a time
+	for idx := 1; idx < len(img.Layers); idx++ {
+		assert.NotSame(t, img.Layers[idx-1].SquashedTree, img.Layers[idx].SquashedTree)
+	}
+}
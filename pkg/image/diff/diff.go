@@ -0,0 +1,156 @@
+package diff
+
+import (
+	"fmt"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// ChangeType describes how a path differs between two filesystems.
+type ChangeType string
+
+const (
+	Added    ChangeType = "added"
+	Modified ChangeType = "modified"
+	Removed  ChangeType = "removed"
+)
+
+// FileState describes a file within one of the compared filesystems.
+type FileState struct {
+	Reference file.Reference
+	Metadata  file.Metadata
+	// Digest is the sha256 digest of the file contents ("sha256:<hex>"), only set for regular files.
+	Digest string
+	// Layer is the digest of the layer the file was read from.
+	Layer string
+}
+
+// FileChange is a single path that differs between two filesystems.
+type FileChange struct {
+	Path file.Path
+	Type ChangeType
+	// Before is the file within the base filesystem (nil for added paths and implied directories).
+	Before *FileState
+	// After is the file within the compared filesystem (nil for removed paths and implied directories).
+	After *FileState
+}
+
+// Result is the set of changes between two filesystems, in path order.
+type Result struct {
+	Changes []FileChange
+}
+
+// Added returns the paths added to the base filesystem.
+func (r Result) Added() []FileChange {
+	return r.ofType(Added)
+}
+
+// Modified returns the paths that exist in both filesystems but differ in metadata or contents.
+func (r Result) Modified() []FileChange {
+	return r.ofType(Modified)
+}
+
+// Removed returns the paths removed from the base filesystem.
+func (r Result) Removed() []FileChange {
+	return r.ofType(Removed)
+}
+
+func (r Result) ofType(t ChangeType) []FileChange {
+	var changes []FileChange
+	for _, c := range r.Changes {
+		if c.Type == t {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// Images computes the changes of the squashed filesystem of the given image relative to the squashed filesystem of the
+// base image (e.g. to detect drift of an image from its base image).
+func Images(base, img *image.Image) (*Result, error) {
+	if base == nil || img == nil || base.FileCatalog == nil || img.FileCatalog == nil {
+		return nil, fmt.Errorf("image has not been read")
+	}
+	return trees(base.SquashedTree(), base.FileCatalog, img.SquashedTree(), img.FileCatalog)
+}
+
+// Layers computes the changes of the filesystem of the image as of the layer at index "to" relative to the filesystem
+// as of the layer at index "from" (the squashed trees of both layers), where "from" is typically a lower layer.
+func Layers(img *image.Image, from, to int) (*Result, error) {
+	if img == nil || img.FileCatalog == nil {
+		return nil, fmt.Errorf("image has not been read")
+	}
+	for _, idx := range []int{from, to} {
+		if idx < 0 || idx >= len(img.Layers) {
+			return nil, fmt.Errorf("layer index %d is out of range (image has %d layers)", idx, len(img.Layers))
+		}
+	}
+	return trees(img.Layers[from].SquashedTree, img.FileCatalog, img.Layers[to].SquashedTree, img.FileCatalog)
+}
+
+// trees describes the changes between the given trees (see image.CompareTrees) with the details of the files on either
+// side of each change.
+func trees(base filetree.Reader, baseCatalog image.FileCatalogReader, current filetree.Reader, currentCatalog image.FileCatalogReader) (*Result, error) {
+	drift, err := image.CompareTrees(base, baseCatalog, current, currentCatalog)
+	if err != nil {
+		return nil, err
+	}
+
+	result := Result{Changes: make([]FileChange, 0, len(drift))}
+	for _, d := range drift {
+		change := FileChange{Path: d.Path}
+		switch d.Type {
+		case image.DriftAdded:
+			change.Type = Added
+		case image.DriftModified:
+			change.Type = Modified
+		case image.DriftDeleted:
+			change.Type = Removed
+		default:
+			return nil, fmt.Errorf("unknown change type %q for path %q", d.Type, d.Path)
+		}
+
+		if change.Type != Added {
+			if change.Before, err = fileState(base, baseCatalog, d.Path); err != nil {
+				return nil, err
+			}
+		}
+		if change.Type != Removed {
+			if change.After, err = fileState(current, currentCatalog, d.Path); err != nil {
+				return nil, err
+			}
+		}
+		result.Changes = append(result.Changes, change)
+	}
+	return &result, nil
+}
+
+// fileState returns the details of the file at the given path within the tree (nil for implied directories).
+func fileState(tree filetree.Reader, catalog image.FileCatalogReader, p file.Path) (*FileState, error) {
+	_, res, err := tree.File(p)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve path %q: %w", p, err)
+	}
+	if res == nil || !res.HasReference() {
+		return nil, nil
+	}
+	ref := *res.Reference
+
+	entry, err := catalog.Get(ref)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get metadata for %q: %w", p, err)
+	}
+
+	state := FileState{Reference: ref, Metadata: entry.Metadata}
+	if layer := catalog.Layer(ref); layer != nil {
+		state.Layer = layer.Metadata.Digest
+	}
+	if entry.Metadata.Type == file.TypeRegular {
+		if state.Digest, err = image.ContentDigest(catalog, ref); err != nil {
+			return nil, fmt.Errorf("unable to digest contents of %q: %w", p, err)
+		}
+	}
+	return &state, nil
+}
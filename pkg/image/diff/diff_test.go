@@ -0,0 +1,182 @@
+package diff
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+type testEntry struct {
+	name     string
+	mode     int64
+	contents string
+}
+
+func TestImages(t *testing.T) {
+	base := newTestImage(t, []testEntry{
+		{name: "etc/"},
+		{name: "etc/os-release", contents: "ID=test\n"},
+		{name: "etc/hostname", contents: "base\n"},
+		{name: "etc/passwd", contents: "root:x:0:0\n"},
+	})
+	current := newTestImage(t, []testEntry{
+		{name: "etc/"},
+		{name: "etc/os-release", contents: "ID=test\n"},
+		{name: "etc/hostname", contents: "current\n"},
+		{name: "usr/bin/app", mode: 0755, contents: "#!/bin/sh\n"},
+	})
+
+	result, err := Images(base, current)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"/etc/hostname:modified", "/etc/passwd:removed", "/usr:added", "/usr/bin:added", "/usr/bin/app:added"}, summarize(result.Changes))
+
+	modified := result.Modified()
+	require.Len(t, modified, 1)
+	require.NotNil(t, modified[0].Before)
+	require.NotNil(t, modified[0].After)
+	assert.Equal(t, digest("base\n"), modified[0].Before.Digest)
+	assert.Equal(t, digest("current\n"), modified[0].After.Digest)
+	assert.Equal(t, base.Layers[0].Metadata.Digest, modified[0].Before.Layer)
+	assert.Equal(t, current.Layers[0].Metadata.Digest, modified[0].After.Layer)
+
+	removed := result.Removed()
+	require.Len(t, removed, 1)
+	assert.Nil(t, removed[0].After)
+	assert.Equal(t, digest("root:x:0:0\n"), removed[0].Before.Digest)
+
+	added := result.Added()
+	require.Len(t, added, 3)
+	// parent directories without an entry in the layer are implied
+	assert.Nil(t, added[0].After)
+	require.NotNil(t, added[2].After)
+	assert.Equal(t, os.FileMode(0755), added[2].After.Metadata.Mode().Perm())
+	assert.Equal(t, digest("#!/bin/sh\n"), added[2].After.Digest)
+}
+
+func TestLayers(t *testing.T) {
+	img := newTestImage(t,
+		[]testEntry{{name: "app/"}, {name: "app/config", contents: "v1"}, {name: "app/cache", contents: "data"}},
+		[]testEntry{{name: "app/config", contents: "v2"}, {name: "app/.wh.cache"}},
+		[]testEntry{{name: "app/log", contents: "started"}},
+	)
+
+	tests := []struct {
+		name     string
+		from, to int
+		want     []string
+		wantErr  require.ErrorAssertionFunc
+	}{
+		{
+			name: "adjacent layers",
+			from: 0,
+			to:   1,
+			want: []string{"/app/cache:removed", "/app/config:modified"},
+		},
+		{
+			name: "across layers",
+			from: 0,
+			to:   2,
+			want: []string{"/app/cache:removed", "/app/config:modified", "/app/log:added"},
+		},
+		{
+			name: "reversed",
+			from: 2,
+			to:   0,
+			want: []string{"/app/cache:added", "/app/config:modified", "/app/log:removed"},
+		},
+		{
+			name: "same layer",
+			from: 1,
+			to:   1,
+		},
+		{
+			name:    "out of range",
+			from:    0,
+			to:      3,
+			wantErr: require.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+			result, err := Layers(img, tt.from, tt.to)
+			tt.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tt.want, summarize(result.Changes))
+		})
+	}
+}
+
+func TestImages_notRead(t *testing.T) {
+	_, err := Images(&image.Image{}, &image.Image{})
+	require.Error(t, err)
+}
+
+func summarize(changes []FileChange) []string {
+	var out []string
+	for _, c := range changes {
+		out = append(out, fmt.Sprintf("%s:%s", c.Path, c.Type))
+	}
+	return out
+}
+
+func digest(contents string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(contents)))
+}
+
+// newTestImage creates an image with a layer for each of the given sets of entries, where names ending in "/" are
+// directories and all other entries are regular files.
+func newTestImage(t *testing.T, layers ...[]testEntry) *image.Image {
+	t.Helper()
+
+	var v1Layers []v1.Layer
+	for _, entries := range layers {
+		buf := &bytes.Buffer{}
+		tw := tar.NewWriter(buf)
+		for _, e := range entries {
+			hdr := &tar.Header{Name: e.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(e.contents))}
+			if e.name[len(e.name)-1] == '/' {
+				hdr.Typeflag, hdr.Mode = tar.TypeDir, 0755
+			}
+			if e.mode != 0 {
+				hdr.Mode = e.mode
+			}
+			require.NoError(t, tw.WriteHeader(hdr))
+			_, err := tw.Write([]byte(e.contents))
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+
+		content := buf.Bytes()
+		layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(content)), nil
+		})
+		require.NoError(t, err)
+		v1Layers = append(v1Layers, layer)
+	}
+
+	v1Img, err := mutate.AppendLayers(empty.Image, v1Layers...)
+	require.NoError(t, err)
+
+	img := image.New(v1Img, nil, t.TempDir())
+	require.NoError(t, img.Read())
+	return img
+}
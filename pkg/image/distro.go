@@ -0,0 +1,292 @@
+package image
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+)
+
+// maxDistroFileSize bounds how much of each release file is read (release files are tiny, but may be crafted).
+const maxDistroFileSize = 64 * 1024
+
+// Distro describes the operating system distribution of an image, as identified from release files within the squashed
+// tree (e.g. /etc/os-release). Fields follow the os-release format (see os-release(5)).
+type Distro struct {
+	// ID is the lower-case identifier of the distro (e.g. "alpine", "debian", "rhel")
+	ID string
+	// IDLike are the identifiers of closely related distros (e.g. "rhel fedora" for centos)
+	IDLike []string
+	// Name is the name of the distro without version information (e.g. "Alpine Linux")
+	Name string
+	// PrettyName is the name of the distro suitable for presentation (e.g. "Debian GNU/Linux 12 (bookworm)")
+	PrettyName string
+	// Version is the version of the distro suitable for presentation (e.g. "12 (bookworm)")
+	Version string
+	// VersionID is the machine-readable version of the distro (e.g. "3.19.1" or "12")
+	VersionID string
+	// VersionCodename is the release codename of the distro (e.g. "bookworm")
+	VersionCodename string
+	// Source is the path of the release file the distro was identified from
+	Source string
+}
+
+func (d Distro) String() string {
+	if d.PrettyName != "" {
+		return d.PrettyName
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %s", d.ID, d.VersionID))
+}
+
+// releaseFile is a distro-specific release file, which may describe the distro when there is no os-release file (or
+// when the os-release file is missing the version).
+type releaseFile struct {
+	path string
+	id   string
+	// presence indicates that only the existence of the file is considered (the contents are never read)
+	presence bool
+	parse    func(contents string) *Distro
+}
+
+var (
+	osReleasePaths = []string{"/etc/os-release", "/usr/lib/os-release"}
+
+	releaseFiles = []releaseFile{
+		{path: "/etc/alpine-release", id: "alpine", parse: versionOnly("alpine", "Alpine Linux")},
+		{path: "/etc/debian_version", id: "debian", parse: versionOnly("debian", "Debian GNU/Linux")},
+		{path: "/etc/centos-release", id: "centos", parse: parseRedHatRelease},
+		{path: "/etc/redhat-release", id: "rhel", parse: parseRedHatRelease},
+		{path: "/etc/system-release", parse: parseRedHatRelease},
+		{path: "/etc/lsb-release", parse: parseLSBRelease},
+		{path: "/bin/busybox", id: "busybox", presence: true, parse: func(string) *Distro { return &Distro{ID: "busybox", Name: "BusyBox"} }},
+	}
+
+	redHatReleasePattern = regexp.MustCompile(`^(?P<name>.+?)\s+release\s+(?P<version>[0-9][^\s]*)(?:\s+\((?P<codename>[^)]*)\))?`)
+)
+
+// IdentifyDistro identifies the operating system distribution from release files within the given (squashed) tree,
+// preferring os-release files over distro-specific release files. Nil is returned when the distro cannot be identified.
+func IdentifyDistro(tree filetree.Reader, catalog FileCatalogReader) *Distro {
+	var distro *Distro
+	for _, p := range osReleasePaths {
+		ref, ok := regularFileReference(tree, catalog, p)
+		if !ok {
+			continue
+		}
+		contents, ok := readReleaseFile(catalog, *ref, p)
+		if !ok {
+			continue
+		}
+		if distro = parseOSRelease(contents); distro != nil {
+			distro.Source = p
+			break
+		}
+	}
+
+	for _, rf := range releaseFiles {
+		if distro != nil && (distro.VersionID != "" || rf.id != distro.ID) {
+			// distro-specific release files only supplement a missing version (e.g. debian testing)
+			continue
+		}
+
+		ref, ok := regularFileReference(tree, catalog, rf.path)
+		if !ok {
+			continue
+		}
+
+		var contents string
+		if !rf.presence {
+			if contents, ok = readReleaseFile(catalog, *ref, rf.path); !ok {
+				continue
+			}
+		}
+
+		found := rf.parse(contents)
+		if found == nil {
+			continue
+		}
+
+		if distro != nil {
+			distro.VersionID = found.VersionID
+			break
+		}
+
+		found.Source = rf.path
+		return found
+	}
+
+	return distro
+}
+
+// regularFileReference returns the regular file at the given path (following links).
+func regularFileReference(tree filetree.Reader, catalog FileCatalogReader, p string) (*file.Reference, bool) {
+	exists, res, err := tree.File(file.Path(p), filetree.FollowBasenameLinks)
+	if err != nil || !exists || res == nil || !res.HasReference() {
+		return nil, false
+	}
+
+	entry, err := catalog.Get(*res.Reference)
+	if err != nil || entry.Metadata.Type != file.TypeRegular {
+		return nil, false
+	}
+	return res.Reference, true
+}
+
+func readReleaseFile(catalog FileCatalogReader, ref file.Reference, p string) (string, bool) {
+	reader, err := catalog.Open(ref)
+	if err != nil {
+		log.WithFields("path", p, "error", err).Trace("unable to read release file")
+		return "", false
+	}
+	defer reader.Close()
+
+	contents, err := io.ReadAll(io.LimitReader(reader, maxDistroFileSize))
+	if err != nil {
+		log.WithFields("path", p, "error", err).Trace("unable to read release file")
+		return "", false
+	}
+	return string(contents), true
+}
+
+// parseOSRelease parses the contents of an os-release file (see os-release(5)).
+func parseOSRelease(contents string) *Distro {
+	values := parseEnvFile(contents)
+	if len(values) == 0 {
+		return nil
+	}
+
+	d := Distro{
+		ID:              strings.ToLower(values["ID"]),
+		Name:            values["NAME"],
+		PrettyName:      values["PRETTY_NAME"],
+		Version:         values["VERSION"],
+		VersionID:       values["VERSION_ID"],
+		VersionCodename: values["VERSION_CODENAME"],
+	}
+	if idLike := strings.Fields(strings.ToLower(values["ID_LIKE"])); len(idLike) > 0 {
+		d.IDLike = idLike
+	}
+
+	if d.ID == "" && d.Name == "" {
+		return nil
+	}
+	if d.ID == "" {
+		// the default ID per os-release(5)
+		d.ID = "linux"
+	}
+	return &d
+}
+
+// parseLSBRelease parses the contents of an /etc/lsb-release file.
+func parseLSBRelease(contents string) *Distro {
+	values := parseEnvFile(contents)
+	if values["DISTRIB_ID"] == "" {
+		return nil
+	}
+	return &Distro{
+		ID:              strings.ToLower(values["DISTRIB_ID"]),
+		Name:            values["DISTRIB_ID"],
+		PrettyName:      values["DISTRIB_DESCRIPTION"],
+		VersionID:       values["DISTRIB_RELEASE"],
+		VersionCodename: values["DISTRIB_CODENAME"],
+	}
+}
+
+// parseRedHatRelease parses the contents of release files such as "CentOS Linux release 7.9.2009 (Core)".
+func parseRedHatRelease(contents string) *Distro {
+	line := strings.TrimSpace(firstLine(contents))
+	match := redHatReleasePattern.FindStringSubmatch(line)
+	if match == nil {
+		return nil
+	}
+
+	name := match[redHatReleasePattern.SubexpIndex("name")]
+	return &Distro{
+		ID:              redHatReleaseID(name),
+		Name:            name,
+		PrettyName:      line,
+		VersionID:       match[redHatReleasePattern.SubexpIndex("version")],
+		VersionCodename: match[redHatReleasePattern.SubexpIndex("codename")],
+	}
+}
+
+func redHatReleaseID(name string) string {
+	lower := strings.ToLower(name)
+	for _, candidate := range []struct{ contains, id string }{
+		{"centos", "centos"},
+		{"red hat", "rhel"},
+		{"fedora", "fedora"},
+		{"amazon", "amzn"},
+		{"oracle", "ol"},
+		{"rocky", "rocky"},
+		{"alma", "almalinux"},
+	} {
+		if strings.Contains(lower, candidate.contains) {
+			return candidate.id
+		}
+	}
+	return strings.Fields(lower)[0]
+}
+
+// versionOnly parses release files that contain only the version of the distro (e.g. "3.19.1").
+func versionOnly(id, name string) func(string) *Distro {
+	return func(contents string) *Distro {
+		version := strings.TrimSpace(firstLine(contents))
+		if version == "" {
+			return nil
+		}
+		return &Distro{
+			ID:         id,
+			Name:       name,
+			PrettyName: fmt.Sprintf("%s %s", name, version),
+			VersionID:  version,
+		}
+	}
+}
+
+// parseEnvFile parses shell-compatible variable assignments (as used by os-release and lsb-release files).
+func parseEnvFile(contents string) map[string]string {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader([]byte(contents)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		values[strings.TrimSpace(key)] = unquote(strings.TrimSpace(value))
+	}
+	return values
+}
+
+func unquote(value string) string {
+	if len(value) < 2 {
+		return value
+	}
+	switch value[0] {
+	case '"':
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+		return strings.Trim(value, `"`)
+	case '\'':
+		return strings.Trim(value, "'")
+	}
+	return value
+}
+
+func firstLine(contents string) string {
+	line, _, _ := strings.Cut(contents, "\n")
+	return line
+}
@@ -0,0 +1,111 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentifyDistro(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  *Distro
+	}{
+		{
+			name: "alpine os-release",
+			files: map[string]string{
+				"etc/os-release": `NAME="Alpine Linux"
+ID=alpine
+VERSION_ID=3.19.1
+PRETTY_NAME="Alpine Linux v3.19"
+HOME_URL="https://alpinelinux.org/"
+`,
+				"etc/alpine-release": "3.19.1\n",
+			},
+			want: &Distro{ID: "alpine", Name: "Alpine Linux", PrettyName: "Alpine Linux v3.19", VersionID: "3.19.1", Source: "/etc/os-release"},
+		},
+		{
+			name: "os-release in /usr/lib",
+			files: map[string]string{
+				"usr/lib/os-release": "ID=ubuntu\nID_LIKE=debian\nVERSION_ID=\"22.04\"\nVERSION=\"22.04.4 LTS (Jammy Jellyfish)\"\nVERSION_CODENAME=jammy\n",
+			},
+			want: &Distro{ID: "ubuntu", IDLike: []string{"debian"}, Version: "22.04.4 LTS (Jammy Jellyfish)", VersionID: "22.04", VersionCodename: "jammy", Source: "/usr/lib/os-release"},
+		},
+		{
+			name: "missing os-release version is supplemented",
+			files: map[string]string{
+				"etc/os-release":     "PRETTY_NAME=\"Debian GNU/Linux trixie/sid\"\nNAME=\"Debian GNU/Linux\"\nID=debian\n",
+				"etc/debian_version": "trixie/sid\n",
+			},
+			want: &Distro{ID: "debian", Name: "Debian GNU/Linux", PrettyName: "Debian GNU/Linux trixie/sid", VersionID: "trixie/sid", Source: "/etc/os-release"},
+		},
+		{
+			name: "alpine release file only",
+			files: map[string]string{
+				"etc/alpine-release": "3.5.2\n",
+			},
+			want: &Distro{ID: "alpine", Name: "Alpine Linux", PrettyName: "Alpine Linux 3.5.2", VersionID: "3.5.2", Source: "/etc/alpine-release"},
+		},
+		{
+			name: "centos release file only",
+			files: map[string]string{
+				"etc/centos-release": "CentOS release 6.10 (Final)\n",
+			},
+			want: &Distro{ID: "centos", Name: "CentOS", PrettyName: "CentOS release 6.10 (Final)", VersionID: "6.10", VersionCodename: "Final", Source: "/etc/centos-release"},
+		},
+		{
+			name: "lsb-release only",
+			files: map[string]string{
+				"etc/lsb-release": "DISTRIB_ID=Ubuntu\nDISTRIB_RELEASE=14.04\nDISTRIB_CODENAME=trusty\nDISTRIB_DESCRIPTION=\"Ubuntu 14.04.6 LTS\"\n",
+			},
+			want: &Distro{ID: "ubuntu", Name: "Ubuntu", PrettyName: "Ubuntu 14.04.6 LTS", VersionID: "14.04", VersionCodename: "trusty", Source: "/etc/lsb-release"},
+		},
+		{
+			name: "busybox",
+			files: map[string]string{
+				"bin/busybox": "\x7fELF",
+			},
+			want: &Distro{ID: "busybox", Name: "BusyBox", Source: "/bin/busybox"},
+		},
+		{
+			name: "unidentifiable",
+			files: map[string]string{
+				"app/main": "binary",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v1Img, err := mutate.AppendLayers(empty.Image, tarLayer(t, tt.files))
+			require.NoError(t, err)
+
+			img := New(v1Img, nil, t.TempDir())
+			require.NoError(t, img.Read())
+
+			assert.Equal(t, tt.want, img.Metadata.Distro)
+		})
+	}
+}
+
+func Test_parseOSRelease(t *testing.T) {
+	got := parseOSRelease(`# comment
+NAME='Quoted Linux'
+PRETTY_NAME="Escaped \"Linux\""
+
+ID_LIKE="RHEL fedora"
+`)
+	require.NotNil(t, got)
+	assert.Equal(t, "linux", got.ID)
+	assert.Equal(t, "Quoted Linux", got.Name)
+	assert.Equal(t, `Escaped "Linux"`, got.PrettyName)
+	assert.Equal(t, []string{"rhel", "fedora"}, got.IDLike)
+
+	assert.Nil(t, parseOSRelease("garbage"))
+	assert.Equal(t, "Escaped \"Linux\"", got.String())
+	assert.Equal(t, "alpine 3.19.1", Distro{ID: "alpine", VersionID: "3.19.1"}.String())
+}
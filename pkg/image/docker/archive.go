@@ -0,0 +1,291 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+const (
+	manifestFile  = "manifest.json"
+	ociLayoutFile = "oci-layout"
+	ociIndexFile  = "index.json"
+)
+
+// archiveContents summarizes the entries within a docker archive. Archives may be in the legacy shape (a manifest.json
+// referencing layer tars) or in the OCI image layout shape emitted by "docker save" in docker >= 25 (an index.json and
+// blobs/ directory, typically alongside a manifest.json for compatibility). Layer blobs may be uncompressed, gzip, or
+// zstd compressed.
+type archiveContents struct {
+	hasManifest  bool
+	hasOCILayout bool
+	// compression is the compression format of each regular file within the archive
+	compression map[string]file.Compression
+}
+
+// readArchiveContents reads the shape of the docker archive at the given path.
+func readArchiveContents(tarPath string) (*archiveContents, error) {
+	f, err := os.Open(tarPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	contents := archiveContents{
+		compression: make(map[string]file.Compression),
+	}
+
+	var hasLayoutFile, hasIndex bool
+	err = file.IterateTar(f, func(entry file.TarFileEntry) error {
+		if entry.Header.Typeflag != 0 && entry.Header.Typeflag != '0' {
+			return nil
+		}
+
+		switch entry.Header.Name {
+		case manifestFile:
+			contents.hasManifest = true
+		case ociLayoutFile:
+			hasLayoutFile = true
+		case ociIndexFile:
+			hasIndex = true
+		}
+
+		compression, _, err := file.DetectCompression(entry.Reader)
+		if err != nil {
+			return fmt.Errorf("unable to read %q: %w", entry.Header.Name, err)
+		}
+		contents.compression[entry.Header.Name] = compression
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	contents.hasOCILayout = hasLayoutFile && hasIndex
+	return &contents, nil
+}
+
+// mixedLayerCompression indicates that some (but not all) of the given layers are compressed. Archive readers
+// typically decide if all layers are compressed based on the first layer alone.
+func (c archiveContents) mixedLayerCompression(layers []string) bool {
+	var compressed, uncompressed bool
+	for _, l := range layers {
+		if c.compression[l] == file.Uncompressed {
+			uncompressed = true
+		} else {
+			compressed = true
+		}
+	}
+	return compressed && uncompressed
+}
+
+// layerMediaType returns the most appropriate layer media type for the layer at the given path.
+func (c archiveContents) layerMediaType(layerPath string) types.MediaType {
+	return compressionMediaType(c.compression[layerPath])
+}
+
+// compressionMediaType returns the most appropriate layer media type for a layer blob with the given compression.
+func compressionMediaType(compression file.Compression) types.MediaType {
+	switch compression {
+	case file.Zstd:
+		return types.OCILayerZStd
+	case file.Uncompressed:
+		return types.DockerUncompressedLayer
+	default:
+		return types.DockerLayer
+	}
+}
+
+// ociLayoutManifest returns the image manifest referenced by the index.json of an OCI layout shaped docker archive,
+// which is only returned when the index references a single image whose config matches the given config.
+func ociLayoutManifest(tarPath string, rawConfig []byte) ([]byte, error) {
+	rawIndex, err := readFromTar(tarPath, ociIndexFile)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := v1.ParseIndexManifest(bytes.NewReader(rawIndex))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", ociIndexFile, err)
+	}
+
+	if len(index.Manifests) != 1 || !index.Manifests[0].MediaType.IsImage() {
+		return nil, fmt.Errorf("expected a single image manifest within %s (found %d)", ociIndexFile, len(index.Manifests))
+	}
+
+	digest := index.Manifests[0].Digest
+	rawManifest, err := readFromTar(tarPath, fmt.Sprintf("blobs/%s/%s", digest.Algorithm, digest.Hex))
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := v1.ParseManifest(bytes.NewReader(rawManifest))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse image manifest %q: %w", digest, err)
+	}
+
+	configDigest, _, err := v1.SHA256(bytes.NewReader(rawConfig))
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest.Config.Digest != configDigest {
+		return nil, fmt.Errorf("image manifest %q does not reference the archive image config", digest)
+	}
+
+	return rawManifest, nil
+}
+
+func blobDigest(tarPath, entryPath string) (v1.Hash, int64, error) {
+	l := compressedArchiveLayer{tarPath: tarPath, layerPath: entryPath}
+	reader, err := l.Compressed()
+	if err != nil {
+		return v1.Hash{}, 0, err
+	}
+	defer reader.Close()
+
+	return v1.SHA256(reader)
+}
+
+func readFromTar(tarPath, entryPath string) ([]byte, error) {
+	f, err := os.Open(tarPath)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := file.ReaderFromTar(f, entryPath)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// compressedArchiveImage is a partial.CompressedImageCore for docker archives where layer compression varies between
+// layers (where each layer is decompressed based on its own contents).
+type compressedArchiveImage struct {
+	tarPath     string
+	rawConfig   []byte
+	rawManifest []byte
+	layers      map[v1.Hash]compressedArchiveLayer
+}
+
+var _ partial.CompressedImageCore = (*compressedArchiveImage)(nil)
+
+// newCompressedArchiveImage creates an image for the given docker archive manifest entry, computing the digest of all
+// layer blobs as they exist within the archive.
+func newCompressedArchiveImage(tarPath string, entry tarball.Descriptor, rawConfig []byte, contents *archiveContents) (*compressedArchiveImage, error) {
+	cfgHash, cfgSize, err := v1.SHA256(bytes.NewReader(rawConfig))
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.DockerManifestSchema2,
+		Config: v1.Descriptor{
+			MediaType: types.DockerConfigJSON,
+			Size:      cfgSize,
+			Digest:    cfgHash,
+		},
+	}
+
+	img := compressedArchiveImage{
+		tarPath:   tarPath,
+		rawConfig: rawConfig,
+		layers:    make(map[v1.Hash]compressedArchiveLayer),
+	}
+
+	for _, layerPath := range entry.Layers {
+		digest, size, err := blobDigest(tarPath, layerPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read layer %q: %w", layerPath, err)
+		}
+
+		desc := v1.Descriptor{
+			MediaType: contents.layerMediaType(layerPath),
+			Size:      size,
+			Digest:    digest,
+		}
+		manifest.Layers = append(manifest.Layers, desc)
+		img.layers[digest] = compressedArchiveLayer{
+			tarPath:   tarPath,
+			layerPath: layerPath,
+			desc:      desc,
+		}
+	}
+
+	img.rawManifest, err = json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithFields("layers", len(entry.Layers)).Trace("reading docker archive with mixed layer compression")
+	return &img, nil
+}
+
+func (i *compressedArchiveImage) RawConfigFile() ([]byte, error) {
+	return i.rawConfig, nil
+}
+
+func (i *compressedArchiveImage) MediaType() (types.MediaType, error) {
+	return types.DockerManifestSchema2, nil
+}
+
+func (i *compressedArchiveImage) RawManifest() ([]byte, error) {
+	return i.rawManifest, nil
+}
+
+func (i *compressedArchiveImage) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	l, ok := i.layers[h]
+	if !ok {
+		return nil, fmt.Errorf("blob %v not found", h)
+	}
+	return &l, nil
+}
+
+// compressedArchiveLayer is a single layer blob within a docker archive. Note: the "compressed" contents may be
+// uncompressed, which is accounted for when reading the uncompressed contents (see partial.CompressedToLayer).
+type compressedArchiveLayer struct {
+	tarPath   string
+	layerPath string
+	desc      v1.Descriptor
+}
+
+func (l *compressedArchiveLayer) Digest() (v1.Hash, error) {
+	return l.desc.Digest, nil
+}
+
+func (l *compressedArchiveLayer) Compressed() (io.ReadCloser, error) {
+	f, err := os.Open(l.tarPath)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := file.ReaderFromTar(f, l.layerPath)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return reader, nil
+}
+
+func (l *compressedArchiveLayer) Size() (int64, error) {
+	return l.desc.Size, nil
+}
+
+func (l *compressedArchiveLayer) MediaType() (types.MediaType, error) {
+	return l.desc.MediaType, nil
+}
@@ -0,0 +1,292 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func TestTarballProvider_ArchiveShapes(t *testing.T) {
+	tests := []struct {
+		name             string
+		compressions     []file.Compression
+		legacyManifest   bool
+		ociLayout        bool
+		wantManifestFrom string
+	}{
+		{
+			name:           "legacy archive with zstd layers",
+			compressions:   []file.Compression{file.Zstd, file.Zstd},
+			legacyManifest: true,
+		},
+		{
+			name:           "legacy archive with mixed layer compression",
+			compressions:   []file.Compression{file.Uncompressed, file.Zstd},
+			legacyManifest: true,
+		},
+		{
+			name:             "docker 25 archive with OCI layout and manifest.json",
+			compressions:     []file.Compression{file.Uncompressed, file.Uncompressed},
+			legacyManifest:   true,
+			ociLayout:        true,
+			wantManifestFrom: "index",
+		},
+		{
+			name:             "OCI layout without manifest.json",
+			compressions:     []file.Compression{file.Uncompressed, file.Zstd},
+			ociLayout:        true,
+			wantManifestFrom: "index",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			archivePath, manifestDigest := writeTestArchive(t, test.compressions, test.legacyManifest, test.ociLayout)
+
+			tmpDirGen := file.NewTempDirGenerator("tempDir")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			img, err := NewArchiveProvider(tmpDirGen, archivePath).Provide(context.Background())
+			require.NoError(t, err)
+			require.Len(t, img.Layers, len(test.compressions))
+
+			for idx := range test.compressions {
+				reader, err := img.OpenPathFromSquash(file.Path(fmt.Sprintf("/layer-%d.txt", idx)))
+				require.NoError(t, err)
+				contents, err := io.ReadAll(reader)
+				require.NoError(t, err)
+				assert.Equal(t, fmt.Sprintf("contents of layer %d", idx), string(contents))
+			}
+
+			if test.wantManifestFrom == "index" {
+				assert.Equal(t, manifestDigest, img.Metadata.ManifestDigest)
+			}
+		})
+	}
+}
+
+// writeTestArchive writes a docker archive with one layer (containing a single file) per given compression, returning
+// the archive path and the digest of the image manifest.
+func writeTestArchive(t *testing.T, compressions []file.Compression, legacyManifest, ociLayout bool) (string, string) {
+	t.Helper()
+
+	blobs := map[string][]byte{}
+	addBlob := func(contents []byte) v1.Hash {
+		digest, _, err := v1.SHA256(bytes.NewReader(contents))
+		require.NoError(t, err)
+		blobs[fmt.Sprintf("blobs/sha256/%s", digest.Hex)] = contents
+		return digest
+	}
+
+	cfg := v1.ConfigFile{
+		Architecture: "amd64",
+		OS:           "linux",
+		RootFS:       v1.RootFS{Type: "layers"},
+	}
+
+	manifest := v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIManifestSchema1,
+	}
+
+	var layerPaths []string
+	for idx, compression := range compressions {
+		layer := tarWith(t, fmt.Sprintf("layer-%d.txt", idx), fmt.Sprintf("contents of layer %d", idx))
+		diffID, _, err := v1.SHA256(bytes.NewReader(layer))
+		require.NoError(t, err)
+		cfg.RootFS.DiffIDs = append(cfg.RootFS.DiffIDs, diffID)
+
+		mediaType := types.OCIUncompressedLayer
+		if compression == file.Zstd {
+			encoder, err := zstd.NewWriter(nil)
+			require.NoError(t, err)
+			layer = encoder.EncodeAll(layer, nil)
+			mediaType = types.OCILayerZStd
+		}
+
+		digest := addBlob(layer)
+		layerPaths = append(layerPaths, fmt.Sprintf("blobs/sha256/%s", digest.Hex))
+		manifest.Layers = append(manifest.Layers, v1.Descriptor{MediaType: mediaType, Digest: digest, Size: int64(len(layer))})
+	}
+
+	rawConfig, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	configDigest := addBlob(rawConfig)
+	manifest.Config = v1.Descriptor{MediaType: types.OCIConfigJSON, Digest: configDigest, Size: int64(len(rawConfig))}
+
+	rawManifest, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	manifestDigest := addBlob(rawManifest)
+
+	files := map[string][]byte{}
+	if ociLayout {
+		for p, contents := range blobs {
+			files[p] = contents
+		}
+		files["oci-layout"] = []byte(`{"imageLayoutVersion":"1.0.0"}`)
+		files["index.json"], err = json.Marshal(v1.IndexManifest{
+			SchemaVersion: 2,
+			MediaType:     types.OCIImageIndex,
+			Manifests: []v1.Descriptor{
+				{MediaType: types.OCIManifestSchema1, Digest: manifestDigest, Size: int64(len(rawManifest))},
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	if legacyManifest {
+		for p, contents := range blobs {
+			files[p] = contents
+		}
+		files["manifest.json"], err = json.Marshal([]map[string]interface{}{
+			{
+				"Config":   fmt.Sprintf("blobs/sha256/%s", configDigest.Hex),
+				"RepoTags": []string{"example.com/test:latest"},
+				"Layers":   layerPaths,
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	archivePath := filepath.Join(t.TempDir(), "image.tar")
+	fh, err := os.Create(archivePath)
+	require.NoError(t, err)
+	defer fh.Close()
+
+	tw := tar.NewWriter(fh)
+	for _, dir := range []string{"blobs/", "blobs/sha256/"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: dir, Mode: 0o755, Typeflag: tar.TypeDir}))
+	}
+	for p, contents := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: p, Mode: 0o644, Size: int64(len(contents)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write(contents)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	return archivePath, manifestDigest.String()
+}
+
+func tarWith(t *testing.T, name, contents string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(contents)), Typeflag: tar.TypeReg}))
+	_, err := tw.Write([]byte(contents))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func TestTarballProvider_Platform(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "images.tar")
+	images := map[name.Tag]v1.Image{}
+	for _, platform := range []v1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64", Variant: "v8"},
+	} {
+		img, err := random.Image(64, 1)
+		require.NoError(t, err)
+		cfg, err := img.ConfigFile()
+		require.NoError(t, err)
+		cfg.OS, cfg.Architecture, cfg.Variant = platform.OS, platform.Architecture, platform.Variant
+		img, err = mutate.ConfigFile(img, cfg)
+		require.NoError(t, err)
+
+		ref, err := name.NewTag("example.com/test:" + platform.Architecture)
+		require.NoError(t, err)
+		images[ref] = img
+	}
+	require.NoError(t, tarball.MultiWriteToFile(archivePath, images))
+
+	tests := []struct {
+		name     string
+		platform string
+		wantArch string
+		wantErr  require.ErrorAssertionFunc
+	}{
+		{
+			name:    "no platform with multiple images",
+			wantErr: require.Error,
+		},
+		{
+			name:     "select amd64",
+			platform: "linux/amd64",
+			wantArch: "amd64",
+		},
+		{
+			name:     "select arm64 (normalized variant)",
+			platform: "linux/arm64",
+			wantArch: "arm64",
+		},
+		{
+			name:     "unavailable platform",
+			platform: "linux/s390x",
+			wantErr:  require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.wantErr == nil {
+				test.wantErr = require.NoError
+			}
+
+			var platform *image.Platform
+			if test.platform != "" {
+				var err error
+				platform, err = image.NewPlatform(test.platform)
+				require.NoError(t, err)
+			}
+
+			tmpDirGen := file.NewTempDirGenerator("tempDir")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			img, err := NewPlatformArchiveProvider(tmpDirGen, archivePath, platform).Provide(context.Background())
+			test.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, test.wantArch, img.Metadata.Config.Architecture)
+			require.Len(t, img.Metadata.Tags, 1)
+			assert.Equal(t, "example.com/test:"+test.wantArch, img.Metadata.Tags[0].String())
+		})
+	}
+
+	// a single image archive with a mismatched platform cannot be satisfied
+	single := filepath.Join(t.TempDir(), "image.tar")
+	for ref, img := range images {
+		if ref.TagStr() == "amd64" {
+			require.NoError(t, tarball.WriteToFile(single, ref, img))
+		}
+	}
+
+	platform, err := image.NewPlatform("linux/arm64")
+	require.NoError(t, err)
+
+	tmpDirGen := file.NewTempDirGenerator("tempDir")
+	t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+	_, err = NewPlatformArchiveProvider(tmpDirGen, single, platform).Provide(context.Background())
+	require.ErrorContains(t, err, "does not match the requested platform")
+}
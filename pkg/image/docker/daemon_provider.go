@@ -11,19 +11,24 @@ import (
 	"math"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/docker/cli/cli/config"
 	configTypes "github.com/docker/cli/cli/config/types"
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/wagoodman/go-partybus"
 	"github.com/wagoodman/go-progress"
 
 	"github.com/anchore/stereoscope/internal/bus"
 	"github.com/anchore/stereoscope/internal/docker"
+	"github.com/anchore/stereoscope/internal/environ"
 	"github.com/anchore/stereoscope/internal/log"
 	"github.com/anchore/stereoscope/pkg/event"
 	"github.com/anchore/stereoscope/pkg/file"
@@ -34,13 +39,21 @@ const Daemon image.Source = image.DockerDaemonSource
 
 // NewDaemonProvider creates a new provider instance for a specific image that will later be cached to the given directory
 func NewDaemonProvider(tmpDirGen *file.TempDirGenerator, imageStr string, platform *image.Platform) image.Provider {
-	return NewAPIClientProvider(Daemon, tmpDirGen, imageStr, platform, func() (client.APIClient, error) {
-		return docker.GetClient()
+	return NewEnvAPIClientProvider(Daemon, tmpDirGen, imageStr, platform, func(env *image.EnvOverrides) (client.APIClient, error) {
+		return docker.GetClientWithOverrides(env)
 	})
 }
 
 // NewAPIClientProvider creates a new provider for the provided Docker client.APIClient
 func NewAPIClientProvider(name string, tmpDirGen *file.TempDirGenerator, imageStr string, platform *image.Platform, newClient apiClientCreator) image.Provider {
+	return NewEnvAPIClientProvider(name, tmpDirGen, imageStr, platform, func(*image.EnvOverrides) (client.APIClient, error) {
+		return newClient()
+	})
+}
+
+// NewEnvAPIClientProvider creates a new provider for a Docker client.APIClient created from the environment overrides
+// of the provide context (see image.ContextWithEnvOverrides), which are nil when the process environment should be used.
+func NewEnvAPIClientProvider(name string, tmpDirGen *file.TempDirGenerator, imageStr string, platform *image.Platform, newClient envAPIClientCreator) image.Provider {
 	return &daemonImageProvider{
 		name:         name,
 		tmpDirGen:    tmpDirGen,
@@ -52,11 +65,13 @@ func NewAPIClientProvider(name string, tmpDirGen *file.TempDirGenerator, imageSt
 
 type apiClientCreator func() (client.APIClient, error)
 
+type envAPIClientCreator func(env *image.EnvOverrides) (client.APIClient, error)
+
 // daemonImageProvider is an image.Provider capable of fetching and representing a docker image from the docker daemon API
 type daemonImageProvider struct {
 	name         string
 	tmpDirGen    *file.TempDirGenerator
-	newAPIClient apiClientCreator
+	newAPIClient envAPIClientCreator
 	imageStr     string
 	platform     *image.Platform
 }
@@ -66,15 +81,15 @@ func (p *daemonImageProvider) Name() string {
 }
 
 type daemonProvideProgress struct {
-	SaveProgress *progress.TimedProgress
+	SaveProgress *image.TimedProgress
 	CopyProgress *progress.Writer
 	Stage        *progress.AtomicStage
 }
 
 //nolint:staticcheck
-func (p *daemonImageProvider) trackSaveProgress(ctx context.Context, apiClient client.APIClient, imageRef string) (*daemonProvideProgress, error) {
+func (p *daemonImageProvider) trackSaveProgress(ctx context.Context, apiClient client.APIClient, source event.ImageSource) (*daemonProvideProgress, error) {
 	// fetch the expected image size to estimate and measure progress
-	inspect, _, err := apiClient.ImageInspectWithRaw(ctx, imageRef)
+	inspect, _, err := apiClient.ImageInspectWithRaw(ctx, source.Image)
 	if err != nil {
 		return nil, fmt.Errorf("unable to inspect image: %w", err)
 	}
@@ -88,7 +103,7 @@ func (p *daemonImageProvider) trackSaveProgress(ctx context.Context, apiClient c
 	sec := float64(inspect.VirtualSize) / (mb * 125)
 	approxSaveTime := time.Duration(sec*1000) * time.Millisecond
 
-	estimateSaveProgress := progress.NewTimedProgress(approxSaveTime)
+	estimateSaveProgress := image.NewTimedProgress(ctx, approxSaveTime)
 	copyProgress := progress.NewSizedWriter(inspect.VirtualSize)
 	aggregateProgress := progress.NewAggregator(progress.NormalizeStrategy, estimateSaveProgress, copyProgress)
 
@@ -97,7 +112,7 @@ func (p *daemonImageProvider) trackSaveProgress(ctx context.Context, apiClient c
 
 	bus.Publish(partybus.Event{
 		Type:   event.FetchImage,
-		Source: imageRef,
+		Source: source,
 		Value: progress.StagedProgressable(&struct {
 			progress.Stager
 			*progress.Aggregator
@@ -116,6 +131,14 @@ func (p *daemonImageProvider) trackSaveProgress(ctx context.Context, apiClient c
 
 // pull a docker image
 func (p *daemonImageProvider) pull(ctx context.Context, client client.APIClient, imageRef string) error {
+	action := fmt.Sprintf("pull %s image=%q", p.name, imageRef)
+	if err := image.CheckNetworkAccess(ctx, action); err != nil {
+		return err
+	}
+	if err := image.CheckDaemonMutation(ctx, action); err != nil {
+		return err
+	}
+
 	log.Debugf("pulling %s image=%q", p.name, imageRef)
 
 	status := newPullStatus()
@@ -126,11 +149,11 @@ func (p *daemonImageProvider) pull(ctx context.Context, client client.APIClient,
 	// publish a pull event on the bus, allowing for read-only consumption of status
 	bus.Publish(partybus.Event{
 		Type:   event.PullDockerImage,
-		Source: imageRef,
+		Source: event.ImageSource{Image: imageRef, Provider: p.name, Pulled: true},
 		Value:  status,
 	})
 
-	options, err := p.pullOptions(imageRef)
+	options, err := p.pullOptions(ctx, imageRef)
 	if err != nil {
 		return err
 	}
@@ -162,13 +185,13 @@ func (p *daemonImageProvider) pull(ctx context.Context, client client.APIClient,
 	return nil
 }
 
-func (p *daemonImageProvider) pullOptions(imageRef string) (types.ImagePullOptions, error) {
+func (p *daemonImageProvider) pullOptions(ctx context.Context, imageRef string) (types.ImagePullOptions, error) {
 	options := types.ImagePullOptions{
 		Platform: p.platform.String(),
 	}
 
 	// note: this will search the default config dir and allow for a DOCKER_CONFIG override
-	cfg, err := config.Load("")
+	cfg, err := config.Load(configDir(environ.FromContext(ctx)))
 	if err != nil {
 		return options, fmt.Errorf("failed to load docker config: %w", err)
 	}
@@ -216,6 +239,18 @@ func (p *daemonImageProvider) pullOptions(imageRef string) (types.ImagePullOptio
 	return options, nil
 }
 
+// configDir returns the docker config directory for the given environment overrides (empty for the process
+// environment, where the default config dir and any DOCKER_CONFIG override are used).
+func configDir(env *environ.Overrides) string {
+	if env == nil {
+		return ""
+	}
+	if dir := env.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	return filepath.Join(env.Home(), ".docker")
+}
+
 func authURL(imageRef string, dockerhubWorkaround bool) (string, error) {
 	ref, err := name.ParseReference(imageRef)
 	if err != nil {
@@ -234,11 +269,30 @@ func authURL(imageRef string, dockerhubWorkaround bool) (string, error) {
 	return url, nil
 }
 
+// connectionHint marks the given daemon connection error as image.ErrDaemonUnavailable, attaching a remediation hint
+// (when the cause is known).
+func (p *daemonImageProvider) connectionHint(err error) error {
+	marked := image.MarkError(err, image.ErrDaemonUnavailable)
+	switch {
+	case errors.Is(err, os.ErrPermission) && p.name == Daemon:
+		return image.WithHint(marked, image.Hint{
+			ID:      image.HintDockerPermission,
+			Message: "add the user to the docker group (e.g. 'sudo usermod -aG docker $USER', then log in again) or use a rootless docker daemon",
+		})
+	case client.IsErrConnectionFailed(err) || errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED):
+		return image.WithHint(marked, image.Hint{
+			ID:      image.HintDaemonUnavailable,
+			Message: fmt.Sprintf("ensure the %s daemon is running and reachable (e.g. check DOCKER_HOST or the active docker context)", p.name),
+		})
+	}
+	return marked
+}
+
 // Provide an image object that represents the cached docker image tar fetched from a docker daemon.
 func (p *daemonImageProvider) Provide(ctx context.Context) (*image.Image, error) {
-	apiClient, err := p.newAPIClient()
+	apiClient, err := p.newAPIClient(environ.FromContext(ctx))
 	if err != nil {
-		return nil, fmt.Errorf("%s not available: %w", p.name, err)
+		return nil, p.connectionHint(fmt.Errorf("%s not available: %w", p.name, err))
 	}
 
 	defer func() {
@@ -252,10 +306,10 @@ func (p *daemonImageProvider) Provide(ctx context.Context) (*image.Image, error)
 
 	pong, err := apiClient.Ping(c2)
 	if err != nil || pong.APIVersion == "" {
-		return nil, fmt.Errorf("unable to get %s API response: %w", p.name, err)
+		return nil, p.connectionHint(fmt.Errorf("unable to get %s API response: %w", p.name, err))
 	}
 
-	imageRef, err := p.pullImageIfMissing(ctx, apiClient)
+	imageRef, pulled, err := p.pullImageIfMissing(ctx, apiClient)
 	if err != nil {
 		return nil, err
 	}
@@ -271,19 +325,34 @@ func (p *daemonImageProvider) Provide(ctx context.Context) (*image.Image, error)
 		return nil, err
 	}
 
-	tarFileName, err := p.saveImage(ctx, apiClient, imageRef)
+	metadata := append(withInspectMetadata(inspectResult), image.WithDaemonUsage(daemonUsage(ctx, apiClient, imageRef, inspectResult)))
+
+	// the docker image ID is the digest of the image config (when not using the containerd image store)
+	if img, ok := image.BlobCacheFromContext(ctx).Image(inspectResult.ID); ok {
+		log.WithFields("image", imageRef).Debug("using image from blob cache instead of exporting from the daemon")
+		return p.provideCached(ctx, img, metadata...)
+	}
+
+	req := newExportRequest(ctx, imageRef, pong.APIVersion, inspectResult)
+	source := event.ImageSource{Image: imageRef, Provider: p.name, Digest: inspectResult.ID, Pulled: pulled}
+
+	if image.IsStreamingExport(ctx) {
+		return p.streamImage(ctx, apiClient, req, source, metadata...)
+	}
+
+	tarFileName, err := p.saveImage(ctx, apiClient, req, source)
 	if err != nil {
 		return nil, err
 	}
 
 	// use the existing tarball provider to process what was pulled from the docker daemon
-	return NewArchiveProvider(p.tmpDirGen, tarFileName, withInspectMetadata(inspectResult)...).
+	return NewArchiveProvider(p.tmpDirGen, tarFileName, metadata...).
 		Provide(ctx)
 }
 
-func (p *daemonImageProvider) saveImage(ctx context.Context, apiClient client.APIClient, imageRef string) (string, error) {
+func (p *daemonImageProvider) saveImage(ctx context.Context, apiClient client.APIClient, req exportRequest, source event.ImageSource) (string, error) {
 	// save the image from the docker daemon to a tar file
-	providerProgress, err := p.trackSaveProgress(ctx, apiClient, imageRef)
+	providerProgress, err := p.trackSaveProgress(ctx, apiClient, source)
 	if err != nil {
 		return "", fmt.Errorf("unable to trace image save progress: %w", err)
 	}
@@ -312,30 +381,35 @@ func (p *daemonImageProvider) saveImage(ctx context.Context, apiClient client.AP
 	}()
 
 	providerProgress.Stage.Set(fmt.Sprintf("requesting image from %s", p.name))
-	readCloser, err := apiClient.ImageSave(ctx, []string{imageRef})
-	if err != nil {
-		return "", fmt.Errorf("unable to save image tar: %w", err)
-	}
-	defer func() {
-		err := readCloser.Close()
-		if err != nil {
-			log.Errorf("unable to close temp file (%s): %w", tempTarFile.Name(), err)
+	var nBytes int64
+	err = p.exportImage(ctx, apiClient, req, func(reader io.Reader) error {
+		// NOTE: The image save progress is only a guess (a timer counting up to a particular time where
+		// the overall progress would be considered at 50%). It's logical to adjust the first image save timer
+		// to complete when the image save operation returns. The defer statement is a fallback in case the numbers
+		// from the docker daemon don't line up (as we saw when metadata and actual size differ)
+		// or there is a problem that causes us to return early with an error.
+		providerProgress.SaveProgress.SetCompleted()
+
+		// discard any partial contents from a stalled export
+		if _, err := tempTarFile.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if err := tempTarFile.Truncate(0); err != nil {
+			return err
 		}
-	}()
 
-	// NOTE: The image save progress is only a guess (a timer counting up to a particular time where
-	// the overall progress would be considered at 50%). It's logical to adjust the first image save timer
-	// to complete when the image save operation returns. The defer statement is a fallback in case the numbers
-	// from the docker daemon don't line up (as we saw when metadata and actual size differ)
-	// or there is a problem that causes us to return early with an error.
-	providerProgress.SaveProgress.SetCompleted()
-
-	// save the image contents to the temp file
-	// note: this is the same image that will be used to querying image content during analysis
-	providerProgress.Stage.Set("saving image to disk")
-	nBytes, err := io.Copy(io.MultiWriter(tempTarFile, providerProgress.CopyProgress), readCloser)
+		// save the image contents to the temp file
+		// note: this is the same image that will be used to querying image content during analysis
+		providerProgress.Stage.Set("saving image to disk")
+		var err error
+		nBytes, err = io.Copy(io.MultiWriter(tempTarFile, providerProgress.CopyProgress), reader)
+		if err != nil {
+			return fmt.Errorf("unable to save image to tar: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("unable to save image to tar: %w", err)
+		return "", err
 	}
 	if nBytes == 0 {
 		return "", errors.New("cannot provide an empty image")
@@ -343,10 +417,55 @@ func (p *daemonImageProvider) saveImage(ctx context.Context, apiClient client.AP
 	return tempTarFile.Name(), nil
 }
 
-func (p *daemonImageProvider) pullImageIfMissing(ctx context.Context, apiClient client.APIClient) (imageRef string, err error) {
+// provideCached provides the given image composed of blobs from the blob cache (see image.BlobCache.Image).
+func (p *daemonImageProvider) provideCached(ctx context.Context, img v1.Image, metadata ...image.AdditionalMetadata) (*image.Image, error) {
+	contentTempDir, err := p.tmpDirGen.NewDirectory(fmt.Sprintf("%s-cached-image", p.name))
+	if err != nil {
+		return nil, err
+	}
+
+	out := image.New(img, p.tmpDirGen, contentTempDir, metadata...)
+	if err := out.ReadContext(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// streamImage provides the image by consuming the image save stream from the daemon directly, without first writing
+// the entire image tar to disk.
+func (p *daemonImageProvider) streamImage(ctx context.Context, apiClient client.APIClient, req exportRequest, source event.ImageSource, metadata ...image.AdditionalMetadata) (*image.Image, error) {
+	providerProgress, err := p.trackSaveProgress(ctx, apiClient, source)
+	if err != nil {
+		return nil, fmt.Errorf("unable to trace image save progress: %w", err)
+	}
+	defer func() {
+		providerProgress.SaveProgress.SetCompleted()
+		providerProgress.CopyProgress.SetComplete()
+	}()
+
+	providerProgress.Stage.Set(fmt.Sprintf("requesting image from %s", p.name))
+	var out *image.Image
+	err = p.exportImage(ctx, apiClient, req, func(reader io.Reader) error {
+		providerProgress.SaveProgress.SetCompleted()
+
+		providerProgress.Stage.Set("streaming image to disk")
+		var err error
+		out, err = NewStreamArchiveProvider(p.tmpDirGen, io.TeeReader(reader, providerProgress.CopyProgress), nil, metadata...).
+			Provide(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// pullImageIfMissing returns the reference of the image within the daemon, pulling the image when it is missing (or
+// present for a different platform than requested). Reports whether the image was pulled.
+func (p *daemonImageProvider) pullImageIfMissing(ctx context.Context, apiClient client.APIClient) (imageRef string, pulled bool, err error) {
 	imageRef, originalImageRef, err := image.ParseReference(p.imageStr)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 
 	// check if the image exists locally
@@ -360,21 +479,22 @@ func (p *daemonImageProvider) pullImageIfMissing(ctx context.Context, apiClient
 	if err != nil {
 		if client.IsErrNotFound(err) {
 			if err = p.pull(ctx, apiClient, imageRef); err != nil {
-				return imageRef, err
+				return imageRef, false, err
 			}
-		} else {
-			return imageRef, fmt.Errorf("unable to inspect existing image: %w", err)
+			return imageRef, true, nil
 		}
-	} else {
-		// looks like the image exists, but if the platform doesn't match what the user specified, we may need to
-		// pull the image again with the correct platform specifier, which will override the local tag.
-		if err = p.validatePlatform(inspectResult); err != nil {
-			if err = p.pull(ctx, apiClient, imageRef); err != nil {
-				return imageRef, err
-			}
+		return imageRef, false, fmt.Errorf("unable to inspect existing image: %w", err)
+	}
+
+	// looks like the image exists, but if the platform doesn't match what the user specified, we may need to
+	// pull the image again with the correct platform specifier, which will override the local tag.
+	if err = p.validatePlatform(inspectResult); err != nil {
+		if err = p.pull(ctx, apiClient, imageRef); err != nil {
+			return imageRef, false, err
 		}
+		return imageRef, true, nil
 	}
-	return imageRef, nil
+	return imageRef, false, nil
 }
 
 func (p *daemonImageProvider) validatePlatform(i types.ImageInspect) error {
@@ -384,11 +504,11 @@ func (p *daemonImageProvider) validatePlatform(i types.ImageInspect) error {
 	}
 
 	if i.Os != p.platform.OS {
-		return fmt.Errorf("image has unexpected OS %q, which differs from the user specified PS %q", i.Os, p.platform.OS)
+		return image.MarkError(fmt.Errorf("image has unexpected OS %q, which differs from the user specified PS %q", i.Os, p.platform.OS), image.ErrPlatformMismatch)
 	}
 
 	if i.Architecture != p.platform.Architecture {
-		return fmt.Errorf("image has unexpected architecture %q, which differs from the user specified architecture %q", i.Architecture, p.platform.Architecture)
+		return image.MarkError(fmt.Errorf("image has unexpected architecture %q, which differs from the user specified architecture %q", i.Architecture, p.platform.Architecture), image.ErrPlatformMismatch)
 	}
 
 	// note: there is no architecture variant captured in inspect responses
@@ -406,6 +526,34 @@ func withInspectMetadata(i types.ImageInspect) (metadata []image.AdditionalMetad
 	return metadata
 }
 
+// daemonUsage reports the image sizes as shown by "docker images" (where the shared size is a best-effort lookup).
+func daemonUsage(ctx context.Context, apiClient client.APIClient, imageRef string, i types.ImageInspect) image.DaemonUsage {
+	usage := image.DaemonUsage{
+		Size:         i.Size,
+		VirtualSize:  i.VirtualSize, //nolint:staticcheck // still reported by older daemons
+		SharedSize:   -1,
+		ContentSize:  -1,
+		SnapshotSize: -1,
+	}
+
+	summaries, err := apiClient.ImageList(ctx, types.ImageListOptions{
+		SharedSize: true,
+		Filters:    filters.NewArgs(filters.Arg("reference", imageRef)),
+	})
+	if err != nil {
+		log.WithFields("image", imageRef, "error", err).Trace("unable to fetch image shared size")
+		return usage
+	}
+
+	for _, summary := range summaries {
+		if summary.ID == i.ID {
+			usage.SharedSize = summary.SharedSize
+			break
+		}
+	}
+	return usage
+}
+
 func encodeCredentials(authConfig configTypes.AuthConfig) (string, error) {
 	buffer := &bytes.Buffer{}
 	encoder := json.NewEncoder(buffer)
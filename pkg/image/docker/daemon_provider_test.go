@@ -1,13 +1,26 @@
 package docker
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
 	"testing"
 
 	configTypes "github.com/docker/cli/cli/config/types"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
 )
 
 func TestEncodeCredentials(t *testing.T) {
@@ -85,3 +98,232 @@ func Test_authURL(t *testing.T) {
 		})
 	}
 }
+
+// missingImageClient is a daemon client where no images exist (all other calls panic).
+type missingImageClient struct {
+	client.APIClient
+	pulled bool
+}
+
+func (c *missingImageClient) Ping(context.Context) (types.Ping, error) {
+	return types.Ping{APIVersion: "1.43"}, nil
+}
+
+func (c *missingImageClient) ImageInspectWithRaw(context.Context, string) (types.ImageInspect, []byte, error) {
+	return types.ImageInspect{}, nil, errdefs.NotFound(errors.New("no such image"))
+}
+
+func (c *missingImageClient) ImagePull(context.Context, string, types.ImagePullOptions) (io.ReadCloser, error) {
+	c.pulled = true
+	return nil, errors.New("pull attempted")
+}
+
+func (c *missingImageClient) Close() error {
+	return nil
+}
+
+func Test_daemonImageProvider_readOnly(t *testing.T) {
+	tests := []struct {
+		name       string
+		readOnly   bool
+		offline    bool
+		wantErr    error
+		wantPulled bool
+	}{
+		{
+			name:       "pulls missing images by default",
+			wantPulled: true,
+		},
+		{
+			name:     "never pulls in read-only mode",
+			readOnly: true,
+			wantErr:  image.ErrDaemonMutation,
+		},
+		{
+			name:    "never pulls in offline mode",
+			offline: true,
+			wantErr: image.ErrOffline,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &missingImageClient{}
+			tmpDirGen := file.NewTempDirGenerator("test")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			ctx := context.Background()
+			if tt.readOnly {
+				ctx = image.ContextWithReadOnlyDaemon(ctx)
+			}
+			if tt.offline {
+				ctx = image.ContextWithOffline(ctx)
+			}
+
+			provider := NewAPIClientProvider(Daemon, tmpDirGen, "anchore/test:latest", nil, func() (client.APIClient, error) {
+				return c, nil
+			})
+			_, err := provider.Provide(ctx)
+			require.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			assert.Equal(t, tt.wantPulled, c.pulled)
+		})
+	}
+}
+
+// pingErrClient is a daemon client that fails to respond to pings with the given error (all other calls panic).
+type pingErrClient struct {
+	client.APIClient
+	err error
+}
+
+func (c *pingErrClient) Ping(context.Context) (types.Ping, error) {
+	return types.Ping{}, c.err
+}
+
+func (c *pingErrClient) Close() error {
+	return nil
+}
+
+func Test_daemonImageProvider_connectionHint(t *testing.T) {
+	permissionErr := &url.Error{Op: "Get", URL: "http://docker/_ping", Err: &net.OpError{Op: "dial", Net: "unix", Err: os.NewSyscallError("connect", syscall.EACCES)}}
+
+	tests := []struct {
+		name     string
+		source   image.Source
+		err      error
+		wantHint string
+	}{
+		{name: "docker permission denied", source: Daemon, err: permissionErr, wantHint: image.HintDockerPermission},
+		{name: "podman permission denied", source: image.PodmanDaemonSource, err: permissionErr},
+		{name: "daemon not running", source: Daemon, err: client.ErrorConnectionFailed("unix:///var/run/docker.sock"), wantHint: image.HintDaemonUnavailable},
+		{name: "other", source: Daemon, err: errors.New("bad response")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDirGen := file.NewTempDirGenerator("test")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			provider := NewAPIClientProvider(tt.source, tmpDirGen, "anchore/test:latest", nil, func() (client.APIClient, error) {
+				return &pingErrClient{err: tt.err}, nil
+			})
+			_, err := provider.Provide(context.Background())
+			require.ErrorIs(t, err, tt.err)
+
+			hints := image.Hints(err)
+			if tt.wantHint == "" {
+				assert.Empty(t, hints)
+				return
+			}
+			require.Len(t, hints, 1)
+			assert.Equal(t, tt.wantHint, hints[0].ID)
+		})
+	}
+}
+
+// imageListClient is a daemon client that lists the given image summaries (all other calls panic).
+type imageListClient struct {
+	client.APIClient
+	summaries []types.ImageSummary
+	err       error
+}
+
+func (c *imageListClient) ImageList(_ context.Context, options types.ImageListOptions) ([]types.ImageSummary, error) {
+	if !options.SharedSize {
+		return nil, errors.New("shared size not requested")
+	}
+	return c.summaries, c.err
+}
+
+func Test_daemonUsage(t *testing.T) {
+	inspect := types.ImageInspect{ID: "sha256:abc", Size: 100, VirtualSize: 100}
+
+	tests := []struct {
+		name   string
+		client *imageListClient
+		want   image.DaemonUsage
+	}{
+		{
+			name: "shared size found",
+			client: &imageListClient{summaries: []types.ImageSummary{
+				{ID: "sha256:other", SharedSize: 1},
+				{ID: "sha256:abc", SharedSize: 42},
+			}},
+			want: image.DaemonUsage{Size: 100, VirtualSize: 100, SharedSize: 42, ContentSize: -1, SnapshotSize: -1},
+		},
+		{
+			name:   "shared size unavailable",
+			client: &imageListClient{err: errors.New("not supported")},
+			want:   image.DaemonUsage{Size: 100, VirtualSize: 100, SharedSize: -1, ContentSize: -1, SnapshotSize: -1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, daemonUsage(context.Background(), tt.client, "anchore/test:latest", inspect))
+		})
+	}
+}
+
+func TestNewEnvAPIClientProvider(t *testing.T) {
+	env := &image.EnvOverrides{
+		Vars:     map[string]string{"DOCKER_HOST": "tcp://localhost:2375"},
+		Hermetic: true,
+	}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		wantEnv *image.EnvOverrides
+	}{
+		{
+			name: "process environment",
+			ctx:  context.Background(),
+		},
+		{
+			name:    "environment overrides from context",
+			ctx:     image.ContextWithEnvOverrides(context.Background(), env),
+			wantEnv: env,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotEnv *image.EnvOverrides
+			provider := NewEnvAPIClientProvider(Daemon, file.NewTempDirGenerator("test"), "anchore/test:latest", nil, func(env *image.EnvOverrides) (client.APIClient, error) {
+				gotEnv = env
+				return nil, errors.New("no daemon")
+			})
+			_, err := provider.Provide(tt.ctx)
+			require.Error(t, err)
+			assert.Same(t, tt.wantEnv, gotEnv)
+		})
+	}
+}
+
+func Test_configDir(t *testing.T) {
+	tests := []struct {
+		name string
+		env  *image.EnvOverrides
+		want string
+	}{
+		{
+			name: "process environment",
+			want: "",
+		},
+		{
+			name: "explicit docker config",
+			env:  &image.EnvOverrides{Vars: map[string]string{"DOCKER_CONFIG": "/somewhere/.docker"}},
+			want: "/somewhere/.docker",
+		},
+		{
+			name: "home directory",
+			env:  &image.EnvOverrides{HomeDir: "/home/someone", Hermetic: true},
+			want: "/home/someone/.docker",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, configDir(tt.env))
+		})
+	}
+}
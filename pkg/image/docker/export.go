@@ -0,0 +1,205 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/versions"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// platformExportAPIVersion is the first daemon API version that supports exporting a single platform of an image.
+const platformExportAPIVersion = "1.48"
+
+// errExportStalled is returned when no data has been received from the daemon export stream within the stall timeout.
+var errExportStalled = errors.New("image export from daemon stalled")
+
+// exportRequest describes the image export to request from the daemon.
+type exportRequest struct {
+	imageRef string
+	// apiVersion is the API version of the daemon, which may be newer than the API version of the client
+	apiVersion string
+	// platform limits the export to a single platform of the image (all platforms are exported when nil)
+	platform *ocispec.Platform
+}
+
+// newExportRequest creates the export request for the given (inspected) image. Only the platform of the image is
+// requested when configured (see image.DaemonExportOptions) and supported by the daemon.
+func newExportRequest(ctx context.Context, imageRef, apiVersion string, i types.ImageInspect) exportRequest {
+	req := exportRequest{imageRef: imageRef, apiVersion: apiVersion}
+	if !image.DaemonExportOptionsFromContext(ctx).PlatformOnly {
+		return req
+	}
+	if versions.LessThan(apiVersion, platformExportAPIVersion) {
+		log.WithFields("image", imageRef, "apiVersion", apiVersion).Debug("daemon does not support platform exports, exporting all platforms")
+		return req
+	}
+	req.platform = &ocispec.Platform{OS: i.Os, Architecture: i.Architecture, Variant: i.Variant}
+	return req
+}
+
+// exportImage requests the image export from the daemon and passes the export stream to the given consumer. When no
+// data is received from the daemon within the stall timeout the export is requested again (from the beginning, since
+// the daemon API cannot resume an export), up to the configured number of retries (see image.DaemonExportOptions).
+// Exports failing with a transient error are requested again according to the retry policy (see image.RetryPolicy).
+func (p *daemonImageProvider) exportImage(ctx context.Context, apiClient client.APIClient, req exportRequest, consume func(io.Reader) error) error {
+	opts := image.DaemonExportOptionsFromContext(ctx)
+	timeout := opts.StallTimeoutOrDefault()
+	retries := opts.RetriesOrDefault()
+
+	return image.Retry(ctx, p.name+" image export", func() error {
+		for attempt := 0; ; attempt++ {
+			err := p.exportImageOnce(ctx, apiClient, req, timeout, consume)
+			if err == nil || !errors.Is(err, errExportStalled) || attempt >= retries {
+				return err
+			}
+			log.WithFields("image", req.imageRef, "timeout", timeout, "attempt", attempt+1).Warnf("%s image export stalled, requesting the export again", p.name)
+		}
+	})
+}
+
+func (p *daemonImageProvider) exportImageOnce(ctx context.Context, apiClient client.APIClient, req exportRequest, timeout time.Duration, consume func(io.Reader) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	watchdog := newStallWatchdog(image.ClockFromContext(ctx), timeout, cancel)
+	defer watchdog.stop()
+
+	readCloser, err := requestExport(ctx, apiClient, req)
+	if err != nil {
+		return watchdog.err(fmt.Errorf("unable to save image tar: %w", err))
+	}
+	defer func() {
+		if err := readCloser.Close(); err != nil {
+			log.Errorf("unable to close %s image save stream: %+v", p.name, err)
+		}
+	}()
+
+	return watchdog.err(consume(&stallReader{reader: readCloser, watchdog: watchdog}))
+}
+
+// requestExport requests the export stream of the image from the daemon.
+func requestExport(ctx context.Context, apiClient client.APIClient, req exportRequest) (io.ReadCloser, error) {
+	if req.platform == nil {
+		return apiClient.ImageSave(ctx, []string{req.imageRef})
+	}
+	return platformImageSave(ctx, apiClient, req)
+}
+
+// platformImageSave requests the export of a single platform of the image. This is not supported by the daemon client
+// in use, thus the request is made directly using the transport of the client (with the API version of the daemon).
+func platformImageSave(ctx context.Context, apiClient client.APIClient, req exportRequest) (io.ReadCloser, error) {
+	hostURL, err := client.ParseHostURL(apiClient.DaemonHost())
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := apiClient.HTTPClient()
+
+	u := url.URL{Scheme: "http", Host: hostURL.Host, Path: path.Join(hostURL.Path, "/v"+req.apiVersion, "/images/get")}
+	switch hostURL.Scheme {
+	case "unix", "npipe":
+		// the host is not used for local sockets, but must be valid (as with the daemon client)
+		u.Host = "docker"
+	default:
+		if t, ok := httpClient.Transport.(*http.Transport); ok && t.TLSClientConfig != nil {
+			u.Scheme = "https"
+		}
+	}
+
+	platform, err := json.Marshal(req.platform)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = url.Values{"names": {req.imageRef}, "platform": {string(platform)}}.Encode()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, errdefs.FromStatusCode(fmt.Errorf("unexpected status %d from daemon: %s", resp.StatusCode, strings.TrimSpace(string(body))), resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// stallWatchdog cancels a daemon request when it has not been kicked (i.e. no data was received) within the timeout.
+type stallWatchdog struct {
+	timer   image.Timer
+	timeout time.Duration
+	stalled atomic.Bool
+}
+
+func newStallWatchdog(clock image.Clock, timeout time.Duration, cancel context.CancelFunc) *stallWatchdog {
+	w := &stallWatchdog{timeout: timeout}
+	if timeout > 0 {
+		w.timer = clock.AfterFunc(timeout, func() {
+			w.stalled.Store(true)
+			cancel()
+		})
+	}
+	return w
+}
+
+func (w *stallWatchdog) kick() {
+	if w.timer != nil {
+		w.timer.Reset(w.timeout)
+	}
+}
+
+func (w *stallWatchdog) stop() {
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+}
+
+// err returns errExportStalled (wrapping the given error) when the request was canceled by the watchdog.
+func (w *stallWatchdog) err(err error) error {
+	if err != nil && w.stalled.Load() {
+		return fmt.Errorf("%w (no data received for %s): %v", errExportStalled, w.timeout, err)
+	}
+	return err
+}
+
+// stallReader kicks the watchdog whenever data is read from the export stream.
+type stallReader struct {
+	reader   io.Reader
+	watchdog *stallWatchdog
+}
+
+func (r *stallReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.watchdog.kick()
+	}
+	switch {
+	case errors.Is(err, io.EOF):
+		// the consumer may still be processing the export after the stream has been read entirely
+		r.watchdog.stop()
+	case err != nil:
+		err = r.watchdog.err(err)
+	}
+	return n, err
+}
@@ -0,0 +1,168 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// stallingSaveClient is a daemon client where the first image exports stall after sending a few bytes (for the given
+// duration on the clock, or until the request is canceled), after which exports complete (all other calls panic).
+type stallingSaveClient struct {
+	client.APIClient
+	clock    *image.ManualClock
+	stall    time.Duration
+	stalls   int
+	requests atomic.Int32
+}
+
+func (c *stallingSaveClient) ImageSave(ctx context.Context, _ []string) (io.ReadCloser, error) {
+	if int(c.requests.Add(1)) > c.stalls {
+		return io.NopCloser(strings.NewReader("image contents")), nil
+	}
+	return io.NopCloser(io.MultiReader(strings.NewReader("partial"), &stallingReader{ctx: ctx, clock: c.clock, stall: c.stall})), nil
+}
+
+// stallingReader advances the clock by the stall duration, then blocks until the context is done.
+type stallingReader struct {
+	ctx   context.Context
+	clock *image.ManualClock
+	stall time.Duration
+}
+
+func (r *stallingReader) Read([]byte) (int, error) {
+	r.clock.Advance(r.stall)
+	<-r.ctx.Done()
+	return 0, r.ctx.Err()
+}
+
+func Test_daemonImageProvider_exportImage(t *testing.T) {
+	tests := []struct {
+		name         string
+		stalls       int
+		opts         image.DaemonExportOptions
+		want         string
+		wantRequests int32
+		wantErr      require.ErrorAssertionFunc
+	}{
+		{
+			name:         "no stall",
+			opts:         image.DaemonExportOptions{StallTimeout: 30 * time.Second},
+			want:         "image contents",
+			wantRequests: 1,
+		},
+		{
+			name:         "export requested again after stalling",
+			stalls:       2,
+			opts:         image.DaemonExportOptions{StallTimeout: 30 * time.Second},
+			want:         "image contents",
+			wantRequests: 3,
+		},
+		{
+			name:         "retries exhausted",
+			stalls:       2,
+			opts:         image.DaemonExportOptions{StallTimeout: 30 * time.Second, Retries: -1},
+			wantRequests: 1,
+			wantErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.ErrorIs(t, err, errExportStalled)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+			clock := image.NewManualClock(time.Now())
+			c := &stallingSaveClient{clock: clock, stall: time.Minute, stalls: tt.stalls}
+			p := &daemonImageProvider{name: Daemon}
+
+			var got string
+			ctx := image.ContextWithClock(context.Background(), clock)
+			ctx = image.ContextWithDaemonExportOptions(ctx, tt.opts)
+			err := p.exportImage(ctx, c, exportRequest{imageRef: "anchore/test:latest"}, func(reader io.Reader) error {
+				contents, err := io.ReadAll(reader)
+				got = string(contents)
+				return err
+			})
+			tt.wantErr(t, err)
+			assert.Equal(t, tt.wantRequests, c.requests.Load())
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// hostClient is a daemon client for the daemon at the given host (all other calls panic).
+type hostClient struct {
+	client.APIClient
+	host string
+}
+
+func (c *hostClient) DaemonHost() string {
+	return c.host
+}
+
+func (c *hostClient) HTTPClient() *http.Client {
+	return http.DefaultClient
+}
+
+func (c *hostClient) ImageSave(context.Context, []string) (io.ReadCloser, error) {
+	return nil, errors.New("all platforms requested")
+}
+
+func Test_requestExport_platform(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1.48/images/get" || r.URL.Query().Get("names") != "anchore/test:latest" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("platform") != `{"architecture":"arm64","os":"linux","variant":"v8"}` {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("unexpected platform"))
+			return
+		}
+		_, _ = w.Write([]byte("arm64 image"))
+	}))
+	t.Cleanup(server.Close)
+
+	c := &hostClient{host: "tcp://" + strings.TrimPrefix(server.URL, "http://")}
+	inspect := types.ImageInspect{Os: "linux", Architecture: "arm64", Variant: "v8"}
+	ctx := image.ContextWithDaemonExportOptions(context.Background(), image.DaemonExportOptions{PlatformOnly: true})
+
+	req := newExportRequest(ctx, "anchore/test:latest", "1.48", inspect)
+	require.Equal(t, &ocispec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, req.platform)
+
+	readCloser, err := requestExport(ctx, c, req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = readCloser.Close() })
+	contents, err := io.ReadAll(readCloser)
+	require.NoError(t, err)
+	assert.Equal(t, "arm64 image", string(contents))
+
+	// older daemons export all platforms
+	req = newExportRequest(ctx, "anchore/test:latest", "1.47", inspect)
+	assert.Nil(t, req.platform)
+	_, err = requestExport(ctx, c, req)
+	assert.ErrorContains(t, err, "all platforms requested")
+
+	// platform exports must be asked for
+	req = newExportRequest(context.Background(), "anchore/test:latest", "1.48", inspect)
+	assert.Nil(t, req.platform)
+}
@@ -70,7 +70,7 @@ func extractManifest(tarPath string) (*dockerManifest, error) {
 }
 
 // generateOCIManifest takes a docker manifest and a path to the tar and generates an OCI manifest derived from the given arguments and the docker config.
-func generateOCIManifest(tarPath string, manifest *dockerManifest) (*v1.Manifest, []byte, error) {
+func generateOCIManifest(tarPath string, manifest *dockerManifest, contents *archiveContents) (*v1.Manifest, []byte, error) {
 	f, err := os.Open(tarPath)
 	if err != nil {
 		return nil, nil, err
@@ -111,6 +111,13 @@ func generateOCIManifest(tarPath string, manifest *dockerManifest) (*v1.Manifest
 	}
 
 	theManifest, err := assembleOCIManifest(configContents, layerSizes)
+	if err == nil && contents != nil {
+		for idx, layerTarPath := range manifest.parsed[0].Layers {
+			if idx < len(theManifest.Layers) && contents.compression[layerTarPath] == file.Zstd {
+				theManifest.Layers[idx].MediaType = types.OCILayerZStd
+			}
+		}
+	}
 
 	return theManifest, configContents, err
 }
@@ -0,0 +1,404 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// NewStreamArchiveProvider creates a new provider for a docker archive read from the given stream (e.g. the export
+// stream of "docker image save" or a containerd export), without first writing the archive to disk. Each blob within
+// the archive is written once: uncompressed layers are written directly into the layer cache of the image, and
+// compressed layers are kept as-is until read. The archive must contain a manifest.json describing a single image.
+func NewStreamArchiveProvider(tmpDirGen *file.TempDirGenerator, reader io.Reader, platform *image.Platform, additionalMetadata ...image.AdditionalMetadata) image.Provider {
+	return &streamImageProvider{
+		tmpDirGen:          tmpDirGen,
+		reader:             reader,
+		platform:           platform,
+		additionalMetadata: additionalMetadata,
+	}
+}
+
+// streamImageProvider is an image.Provider for a docker archive that is consumed as a stream.
+type streamImageProvider struct {
+	tmpDirGen          *file.TempDirGenerator
+	reader             io.Reader
+	platform           *image.Platform
+	additionalMetadata []image.AdditionalMetadata
+}
+
+func (p *streamImageProvider) Name() string {
+	return Archive
+}
+
+// Provide an image object that represents the docker archive read from the stream.
+func (p *streamImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	contentTempDir, err := p.tmpDirGen.NewDirectory("docker-stream-image")
+	if err != nil {
+		return nil, err
+	}
+
+	blobDir := filepath.Join(contentTempDir, "blobs")
+	if err := os.Mkdir(blobDir, 0700); err != nil {
+		return nil, err
+	}
+
+	archive, err := readArchiveStream(p.reader, blobDir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read docker archive stream: %w", err)
+	}
+
+	img, err := archive.image(contentTempDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validatePlatform(img.image, p.platform); err != nil {
+		return nil, err
+	}
+
+	metadata := []image.AdditionalMetadata{
+		image.WithTags(img.tags...),
+		image.WithConfig(img.rawConfig),
+		image.WithManifest(img.rawManifest),
+	}
+
+	// apply user-supplied metadata last to override any default behavior
+	metadata = append(metadata, p.additionalMetadata...)
+
+	out := image.New(img.image, p.tmpDirGen, contentTempDir, metadata...)
+	if err := out.ReadContext(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// streamedBlob is a single regular file from the archive stream, written to its own file.
+type streamedBlob struct {
+	path        string
+	digest      v1.Hash
+	size        int64
+	compression file.Compression
+}
+
+// streamedArchive is the set of all regular files (by archive path) read from a docker archive stream.
+type streamedArchive struct {
+	blobs map[string]*streamedBlob
+	// links are archive paths that are symlinks to other archive paths (e.g. "<id>/layer.tar" -> "../blobs/sha256/<digest>")
+	links map[string]string
+}
+
+// readArchiveStream writes every regular file within the archive stream to the given directory.
+func readArchiveStream(reader io.Reader, dir string) (*streamedArchive, error) {
+	archive := streamedArchive{
+		blobs: make(map[string]*streamedBlob),
+		links: make(map[string]string),
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean(hdr.Name)
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			blob, err := writeBlob(tr, filepath.Join(dir, strconv.Itoa(len(archive.blobs))))
+			if err != nil {
+				return nil, fmt.Errorf("unable to write %q: %w", name, err)
+			}
+			archive.blobs[name] = blob
+		case tar.TypeSymlink:
+			archive.links[name] = path.Join(path.Dir(name), hdr.Linkname)
+		}
+	}
+
+	// the archive may be padded beyond the end of the tar, which must be consumed for the writer to complete
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return nil, err
+	}
+
+	return &archive, nil
+}
+
+func writeBlob(reader io.Reader, dest string) (*streamedBlob, error) {
+	compression, reader, err := file.DetectCompression(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	fh, err := os.Create(dest)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(fh, hasher), reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamedBlob{
+		path:        dest,
+		digest:      v1.Hash{Algorithm: "sha256", Hex: fmt.Sprintf("%x", hasher.Sum(nil))},
+		size:        size,
+		compression: compression,
+	}, nil
+}
+
+// blob returns the regular file at the given archive path (following symlinks).
+func (a *streamedArchive) blob(name string) (*streamedBlob, error) {
+	name = path.Clean(name)
+	for i := 0; i <= len(a.links); i++ {
+		if b, ok := a.blobs[name]; ok {
+			return b, nil
+		}
+		target, ok := a.links[name]
+		if !ok {
+			break
+		}
+		name = target
+	}
+	return nil, fmt.Errorf("file %q not found in docker archive", name)
+}
+
+func (a *streamedArchive) read(name string) ([]byte, error) {
+	b, err := a.blob(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(b.path)
+}
+
+// streamedImage is the single image within a docker archive stream.
+type streamedImage struct {
+	image       v1.Image
+	tags        []string
+	rawConfig   []byte
+	rawManifest []byte
+}
+
+// image creates the image described by the archive manifest.json, moving all uncompressed layers into the layer cache
+// within the given content cache directory.
+func (a *streamedArchive) image(contentCacheDir string) (*streamedImage, error) {
+	rawManifest, err := a.read(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+
+	theManifest, err := newManifest(rawManifest)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(theManifest.parsed) != 1 {
+		return nil, ErrMultipleManifests
+	}
+	entry := theManifest.parsed[0]
+
+	rawConfig, err := a.read(entry.Config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read docker config: %w", err)
+	}
+
+	cfg, err := v1.ParseConfigFile(bytes.NewReader(rawConfig))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse docker config: %w", err)
+	}
+
+	core := streamedImageCore{
+		rawConfig: rawConfig,
+		mediaType: types.DockerManifestSchema2,
+		layers:    make(map[v1.Hash]*streamedLayer),
+	}
+
+	var descriptors []v1.Descriptor
+	for idx, layerPath := range entry.Layers {
+		blob, err := a.blob(layerPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read layer: %w", err)
+		}
+
+		// the uncompressed layer tar is exactly what would otherwise be written to the layer cache
+		if blob.compression == file.Uncompressed && idx < len(cfg.RootFS.DiffIDs) && blob.digest == cfg.RootFS.DiffIDs[idx] {
+			cachePath := image.LayerCachePath(contentCacheDir, blob.digest.String())
+			if blob.path != cachePath {
+				if err := os.Rename(blob.path, cachePath); err != nil {
+					return nil, fmt.Errorf("unable to move layer into the layer cache: %w", err)
+				}
+				blob.path = cachePath
+			}
+		}
+
+		desc := v1.Descriptor{
+			MediaType: compressionMediaType(blob.compression),
+			Size:      blob.size,
+			Digest:    blob.digest,
+		}
+		descriptors = append(descriptors, desc)
+		core.layers[blob.digest] = &streamedLayer{blob: blob, desc: desc}
+	}
+
+	core.rawManifest, err = a.manifest(rawConfig, core.layers)
+	if err != nil {
+		return nil, err
+	}
+
+	if core.rawManifest == nil {
+		core.rawManifest, err = generateManifest(rawConfig, descriptors)
+		if err != nil {
+			return nil, err
+		}
+	} else if manifest, err := v1.ParseManifest(bytes.NewReader(core.rawManifest)); err == nil && manifest.MediaType != "" {
+		core.mediaType = manifest.MediaType
+	}
+
+	img, err := partial.CompressedToImage(&core)
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamedImage{
+		image:       img,
+		tags:        theManifest.allTags(),
+		rawConfig:   rawConfig,
+		rawManifest: core.rawManifest,
+	}, nil
+}
+
+// manifest returns the original image manifest from the OCI layout within the archive (docker >= 25 and containerd
+// exports), but only when it describes the given config and all of its layers are within the archive (nil otherwise).
+func (a *streamedArchive) manifest(rawConfig []byte, layers map[v1.Hash]*streamedLayer) ([]byte, error) {
+	rawIndex, err := a.read(ociIndexFile)
+	if err != nil {
+		return nil, nil
+	}
+
+	index, err := v1.ParseIndexManifest(bytes.NewReader(rawIndex))
+	if err != nil || len(index.Manifests) != 1 || !index.Manifests[0].MediaType.IsImage() {
+		return nil, nil
+	}
+
+	digest := index.Manifests[0].Digest
+	rawManifest, err := a.read(fmt.Sprintf("blobs/%s/%s", digest.Algorithm, digest.Hex))
+	if err != nil {
+		return nil, nil
+	}
+
+	manifest, err := v1.ParseManifest(bytes.NewReader(rawManifest))
+	if err != nil {
+		return nil, nil
+	}
+
+	configDigest, _, err := v1.SHA256(bytes.NewReader(rawConfig))
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest.Config.Digest != configDigest {
+		return nil, nil
+	}
+
+	for _, l := range manifest.Layers {
+		if _, ok := layers[l.Digest]; !ok {
+			log.WithFields("digest", l.Digest.String()).Trace("image manifest within docker archive references a missing layer")
+			return nil, nil
+		}
+	}
+	return rawManifest, nil
+}
+
+// generateManifest creates an image manifest for the given config and layer blobs (as they exist within the archive).
+func generateManifest(rawConfig []byte, layers []v1.Descriptor) ([]byte, error) {
+	cfgHash, cfgSize, err := v1.SHA256(bytes.NewReader(rawConfig))
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.DockerManifestSchema2,
+		Config: v1.Descriptor{
+			MediaType: types.DockerConfigJSON,
+			Size:      cfgSize,
+			Digest:    cfgHash,
+		},
+		Layers: layers,
+	})
+}
+
+// streamedImageCore is a partial.CompressedImageCore for an image read from a docker archive stream.
+type streamedImageCore struct {
+	rawConfig   []byte
+	rawManifest []byte
+	mediaType   types.MediaType
+	layers      map[v1.Hash]*streamedLayer
+}
+
+var _ partial.CompressedImageCore = (*streamedImageCore)(nil)
+
+func (i *streamedImageCore) RawConfigFile() ([]byte, error) {
+	return i.rawConfig, nil
+}
+
+func (i *streamedImageCore) MediaType() (types.MediaType, error) {
+	return i.mediaType, nil
+}
+
+func (i *streamedImageCore) RawManifest() ([]byte, error) {
+	return i.rawManifest, nil
+}
+
+func (i *streamedImageCore) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	l, ok := i.layers[h]
+	if !ok {
+		return nil, fmt.Errorf("blob %v not found", h)
+	}
+	return l, nil
+}
+
+// streamedLayer is a single layer blob written from a docker archive stream. Note: the "compressed" contents may be
+// uncompressed, which is accounted for when reading the uncompressed contents (see partial.CompressedToLayer).
+type streamedLayer struct {
+	blob *streamedBlob
+	desc v1.Descriptor
+}
+
+func (l *streamedLayer) Digest() (v1.Hash, error) {
+	return l.desc.Digest, nil
+}
+
+func (l *streamedLayer) Compressed() (io.ReadCloser, error) {
+	return os.Open(l.blob.path)
+}
+
+func (l *streamedLayer) Size() (int64, error) {
+	return l.desc.Size, nil
+}
+
+func (l *streamedLayer) MediaType() (types.MediaType, error) {
+	return l.desc.MediaType, nil
+}
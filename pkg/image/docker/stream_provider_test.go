@@ -0,0 +1,163 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func TestStreamArchiveProvider(t *testing.T) {
+	tests := []struct {
+		name             string
+		compressions     []file.Compression
+		ociLayout        bool
+		wantManifestFrom string
+	}{
+		{
+			name:         "legacy archive with uncompressed layers",
+			compressions: []file.Compression{file.Uncompressed, file.Uncompressed},
+		},
+		{
+			name:         "legacy archive with mixed layer compression",
+			compressions: []file.Compression{file.Uncompressed, file.Zstd},
+		},
+		{
+			name:             "docker 25 archive with OCI layout and manifest.json",
+			compressions:     []file.Compression{file.Uncompressed, file.Zstd},
+			ociLayout:        true,
+			wantManifestFrom: "index",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			archivePath, manifestDigest := writeTestArchive(t, test.compressions, true, test.ociLayout)
+
+			fh, err := os.Open(archivePath)
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = fh.Close() })
+
+			tmpDirGen := file.NewTempDirGenerator("tempDir")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			img, err := NewStreamArchiveProvider(tmpDirGen, fh, nil).Provide(context.Background())
+			require.NoError(t, err)
+			require.Len(t, img.Layers, len(test.compressions))
+
+			for idx := range test.compressions {
+				reader, err := img.OpenPathFromSquash(file.Path(fmt.Sprintf("/layer-%d.txt", idx)))
+				require.NoError(t, err)
+				contents, err := io.ReadAll(reader)
+				require.NoError(t, err)
+				assert.Equal(t, fmt.Sprintf("contents of layer %d", idx), string(contents))
+			}
+
+			require.Len(t, img.Metadata.Tags, 1)
+			assert.Equal(t, "example.com/test:latest", img.Metadata.Tags[0].Name())
+
+			if test.wantManifestFrom == "index" {
+				assert.Equal(t, manifestDigest, img.Metadata.ManifestDigest)
+			}
+		})
+	}
+}
+
+func TestStreamArchive_UncompressedLayersWrittenToLayerCache(t *testing.T) {
+	archivePath, _ := writeTestArchive(t, []file.Compression{file.Uncompressed, file.Zstd}, true, false)
+
+	fh, err := os.Open(archivePath)
+	require.NoError(t, err)
+	defer fh.Close()
+
+	contentDir := t.TempDir()
+	blobDir := filepath.Join(contentDir, "blobs")
+	require.NoError(t, os.Mkdir(blobDir, 0700))
+
+	archive, err := readArchiveStream(fh, blobDir)
+	require.NoError(t, err)
+
+	img, err := archive.image(contentDir)
+	require.NoError(t, err)
+
+	layers, err := img.image.Layers()
+	require.NoError(t, err)
+	require.Len(t, layers, 2)
+
+	uncompressed, err := layers[0].DiffID()
+	require.NoError(t, err)
+	assert.FileExists(t, image.LayerCachePath(contentDir, uncompressed.String()))
+
+	compressed, err := layers[1].DiffID()
+	require.NoError(t, err)
+	assert.NoFileExists(t, image.LayerCachePath(contentDir, compressed.String()))
+
+	// the uncompressed layer was moved (not copied) into the layer cache
+	entries, err := os.ReadDir(blobDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, len(archive.blobs)-1)
+}
+
+func TestStreamArchiveProvider_Images(t *testing.T) {
+	tests := []struct {
+		name    string
+		images  int
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:   "single image with gzip layers",
+			images: 1,
+		},
+		{
+			name:    "multiple images",
+			images:  2,
+			wantErr: func(t require.TestingT, err error, _ ...interface{}) { require.ErrorIs(t, err, ErrMultipleManifests) },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.wantErr == nil {
+				test.wantErr = require.NoError
+			}
+
+			images := map[name.Tag]v1.Image{}
+			for i := 0; i < test.images; i++ {
+				img, err := random.Image(64, 2)
+				require.NoError(t, err)
+				ref, err := name.NewTag(fmt.Sprintf("example.com/test:%d", i))
+				require.NoError(t, err)
+				images[ref] = img
+			}
+
+			archivePath := filepath.Join(t.TempDir(), "images.tar")
+			require.NoError(t, tarball.MultiWriteToFile(archivePath, images))
+
+			fh, err := os.Open(archivePath)
+			require.NoError(t, err)
+			defer fh.Close()
+
+			tmpDirGen := file.NewTempDirGenerator("tempDir")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			img, err := NewStreamArchiveProvider(tmpDirGen, fh, nil).Provide(context.Background())
+			test.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			assert.Len(t, img.Layers, 2)
+		})
+	}
+}
@@ -1,25 +1,37 @@
 package docker
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 
 	"github.com/anchore/stereoscope/internal/log"
 	"github.com/anchore/stereoscope/pkg/file"
 	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/anchore/stereoscope/pkg/image/oci"
 )
 
 const Archive image.Source = image.DockerTarballSource
 
 // NewArchiveProvider creates a new provider able to resolve docker tarball archives
 func NewArchiveProvider(tmpDirGen *file.TempDirGenerator, path string, additionalMetadata ...image.AdditionalMetadata) image.Provider {
+	return NewPlatformArchiveProvider(tmpDirGen, path, nil, additionalMetadata...)
+}
+
+// NewPlatformArchiveProvider creates a new provider able to resolve docker tarball archives, selecting the image for
+// the given platform when the archive contains multiple images. An error is raised when the archive does not contain
+// an image for the given platform.
+func NewPlatformArchiveProvider(tmpDirGen *file.TempDirGenerator, path string, platform *image.Platform, additionalMetadata ...image.AdditionalMetadata) image.Provider {
 	return &tarballImageProvider{
 		tmpDirGen:          tmpDirGen,
 		path:               path,
+		platform:           platform,
 		additionalMetadata: additionalMetadata,
 	}
 }
@@ -30,6 +42,7 @@ var ErrMultipleManifests = fmt.Errorf("cannot process multiple docker manifests"
 type tarballImageProvider struct {
 	tmpDirGen          *file.TempDirGenerator
 	path               string
+	platform           *image.Platform
 	additionalMetadata []image.AdditionalMetadata
 }
 
@@ -37,33 +50,56 @@ func (p *tarballImageProvider) Name() string {
 	return Archive
 }
 
-// Provide an image object that represents the docker image tar at the configured location on disk.
-func (p *tarballImageProvider) Provide(_ context.Context) (*image.Image, error) {
-	img, err := tarball.ImageFromPath(p.path, nil)
+// Provide an image object that represents the docker image tar at the configured location on disk. Both legacy
+// archives and OCI image layout shaped archives (from docker >= 25) are supported, with uncompressed, gzip, or zstd
+// compressed layers.
+func (p *tarballImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	contents, err := readArchiveContents(p.path)
 	if err != nil {
-		// raise a more controlled error for when there are multiple images within the given tar (from https://github.com/anchore/grype/issues/215)
-		if err.Error() == "tarball must contain only a single image to be used with tarball.Image" {
-			return nil, ErrMultipleManifests
-		}
 		return nil, fmt.Errorf("unable to provide image from tarball: %w", err)
 	}
 
-	// make a best-effort to generate an OCI manifest and gets tags, but ultimately this should be considered optional
-	var rawOCIManifest []byte
-	var rawConfig []byte
-	var ociManifest *v1.Manifest
-	var metadata []image.AdditionalMetadata
+	if !contents.hasManifest {
+		if contents.hasOCILayout {
+			return p.provideOCILayout(ctx)
+		}
+		return nil, image.MarkError(fmt.Errorf("unable to provide image from tarball: no %s or OCI image layout found", manifestFile), image.ErrWrongArchiveFormat)
+	}
 
 	theManifest, err := extractManifest(p.path)
 	if err != nil {
 		log.Warnf("could not extract manifest: %+v", err)
 	}
 
+	var selected bool
+	if theManifest != nil && len(theManifest.parsed) > 1 && p.platform != nil {
+		theManifest, err = selectPlatformManifest(p.path, theManifest, p.platform)
+		if err != nil {
+			return nil, err
+		}
+		selected = true
+	}
+
+	img, err := p.archiveImage(contents, theManifest, selected)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validatePlatform(img, p.platform); err != nil {
+		return nil, err
+	}
+
+	// make a best-effort to generate an OCI manifest and gets tags, but ultimately this should be considered optional
+	var rawOCIManifest []byte
+	var rawConfig []byte
+	var ociManifest *v1.Manifest
+	var metadata []image.AdditionalMetadata
+
 	if theManifest != nil {
 		// given that we have a manifest, continue processing to get the tags and OCI manifest
 		metadata = append(metadata, image.WithTags(theManifest.allTags()...))
 
-		ociManifest, rawConfig, err = generateOCIManifest(p.path, theManifest)
+		ociManifest, rawConfig, err = generateOCIManifest(p.path, theManifest, contents)
 		if err != nil {
 			log.Warnf("failed to generate OCI manifest from docker archive: %+v", err)
 		}
@@ -74,6 +110,17 @@ func (p *tarballImageProvider) Provide(_ context.Context) (*image.Image, error)
 		}
 	}
 
+	if contents.hasOCILayout && rawConfig != nil {
+		// prefer the original image manifest over a generated one when the archive has an OCI layout (docker >= 25)
+		rawOCIManifest, err = ociLayoutManifest(p.path, rawConfig)
+		if err != nil {
+			log.WithFields("error", err).Trace("unable to use image manifest from OCI layout within docker archive")
+		} else {
+			metadata = append(metadata, image.WithManifest(rawOCIManifest))
+			ociManifest = nil
+		}
+	}
+
 	if ociManifest != nil {
 		rawOCIManifest, err = json.Marshal(&ociManifest)
 		if err != nil {
@@ -92,9 +139,104 @@ func (p *tarballImageProvider) Provide(_ context.Context) (*image.Image, error)
 	}
 
 	out := image.New(img, p.tmpDirGen, contentTempDir, metadata...)
-	err = out.Read()
+	err = out.ReadContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return out, err
 }
+
+// archiveImage reads the single image within the docker archive (or the image selected from the manifest).
+func (p *tarballImageProvider) archiveImage(contents *archiveContents, theManifest *dockerManifest, selected bool) (v1.Image, error) {
+	// the tarball package decides if all layers are compressed based on the first layer only, and can only select
+	// an image from a multi-image archive by tag
+	if theManifest != nil && len(theManifest.parsed) == 1 && (selected || contents.mixedLayerCompression(theManifest.parsed[0].Layers)) {
+		rawConfig, err := readFromTar(p.path, theManifest.parsed[0].Config)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read docker config: %w", err)
+		}
+
+		core, err := newCompressedArchiveImage(p.path, theManifest.parsed[0], rawConfig, contents)
+		if err != nil {
+			return nil, fmt.Errorf("unable to provide image from tarball: %w", err)
+		}
+		return partial.CompressedToImage(core)
+	}
+
+	img, err := tarball.ImageFromPath(p.path, nil)
+	if err != nil {
+		// raise a more controlled error for when there are multiple images within the given tar (from https://github.com/anchore/grype/issues/215)
+		if err.Error() == "tarball must contain only a single image to be used with tarball.Image" {
+			return nil, ErrMultipleManifests
+		}
+		return nil, fmt.Errorf("unable to provide image from tarball: %w", err)
+	}
+	return img, nil
+}
+
+// provideOCILayout provides the image from a docker archive with an OCI image layout but without a legacy
+// manifest.json (which is read the same as an OCI archive).
+func (p *tarballImageProvider) provideOCILayout(ctx context.Context) (*image.Image, error) {
+	log.WithFields("path", p.path).Debug("docker archive has no manifest.json, reading OCI image layout")
+
+	out, err := oci.NewPlatformArchiveProvider(p.tmpDirGen, p.path, p.platform).Provide(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// apply user-supplied metadata last to override any default behavior
+	for _, fn := range p.additionalMetadata {
+		if err := fn(out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// selectPlatformManifest narrows the given multi-image archive manifest to the single image for the given platform.
+func selectPlatformManifest(tarPath string, theManifest *dockerManifest, platform *image.Platform) (*dockerManifest, error) {
+	var matches tarball.Manifest
+	var available []string
+	for _, entry := range theManifest.parsed {
+		rawConfig, err := readFromTar(tarPath, entry.Config)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read docker config %q: %w", entry.Config, err)
+		}
+
+		cfg, err := v1.ParseConfigFile(bytes.NewReader(rawConfig))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse docker config %q: %w", entry.Config, err)
+		}
+
+		if platform.Matches(cfg.OS, cfg.Architecture, cfg.Variant) {
+			matches = append(matches, entry)
+		}
+		available = append(available, cfg.Platform().String())
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, image.MarkError(fmt.Errorf("no image found in docker archive for platform %q (available: %s)", platform.String(), strings.Join(available, ", ")), image.ErrPlatformMismatch)
+	case 1:
+		log.WithFields("platform", platform.String(), "config", matches[0].Config).Debug("selected image from docker archive")
+		return &dockerManifest{parsed: matches}, nil
+	}
+	return nil, fmt.Errorf("%w: %d images found for platform %q", ErrMultipleManifests, len(matches), platform.String())
+}
+
+// validatePlatform ensures that the image satisfies the given platform (when the image config describes a platform).
+func validatePlatform(img v1.Image, platform *image.Platform) error {
+	if platform == nil {
+		return nil
+	}
+
+	cfg, err := img.ConfigFile()
+	if err != nil || cfg == nil || cfg.Architecture == "" {
+		return nil
+	}
+
+	if !platform.Matches(cfg.OS, cfg.Architecture, cfg.Variant) {
+		return image.MarkError(fmt.Errorf("image platform %q does not match the requested platform %q", cfg.Platform().String(), platform.String()), image.ErrPlatformMismatch)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+const repositoriesFile = "repositories"
+
+// Writer writes images provided from any image source as docker archives, as written by "docker save" (a manifest.json
+// and repositories file along with the config and uncompressed layer tars), usable with "docker load" and as a
+// "docker-archive:" input (e.g. to convert an OCI layout to a docker archive).
+type Writer struct {
+	// Tags are the repo tags of the image within the archive. Defaults to the tags of the image, where images without
+	// tags are written untagged.
+	Tags []string
+}
+
+// WriteArchive writes the image as a docker archive to the given writer.
+func (w Writer) WriteArchive(out io.Writer, img *image.Image) error {
+	if img == nil || img.V1Image() == nil {
+		return fmt.Errorf("image has no content to write")
+	}
+	content := img.V1Image()
+
+	tags, err := w.tags(img)
+	if err != nil {
+		return err
+	}
+
+	rawConfig, err := content.RawConfigFile()
+	if err != nil {
+		return fmt.Errorf("unable to read image config: %w", err)
+	}
+	configDigest, err := content.ConfigName()
+	if err != nil {
+		return fmt.Errorf("unable to determine image config digest: %w", err)
+	}
+	layers, err := content.Layers()
+	if err != nil {
+		return fmt.Errorf("unable to read image layers: %w", err)
+	}
+
+	entry := tarball.Descriptor{
+		Config:   configDigest.Hex + ".json",
+		RepoTags: []string{},
+	}
+	for _, tag := range tags {
+		entry.RepoTags = append(entry.RepoTags, familiarName(tag.Repository)+":"+tag.TagStr())
+	}
+
+	tw := tar.NewWriter(out)
+	if err := writeEntry(tw, entry.Config, rawConfig); err != nil {
+		return err
+	}
+
+	var chainID v1.Hash
+	for idx, layer := range layers {
+		diffID, err := layer.DiffID()
+		if err != nil {
+			return fmt.Errorf("unable to determine diff ID of layer %d: %w", idx, err)
+		}
+		chainID = nextChainID(chainID, diffID, idx == 0)
+
+		layerPath, err := writeLayer(tw, chainID.Hex, layer)
+		if err != nil {
+			return fmt.Errorf("unable to write layer %s: %w", diffID, err)
+		}
+		entry.Layers = append(entry.Layers, layerPath)
+	}
+
+	rawManifest, err := json.Marshal(tarball.Manifest{entry})
+	if err != nil {
+		return err
+	}
+	if err := writeEntry(tw, manifestFile, rawManifest); err != nil {
+		return err
+	}
+
+	if len(tags) > 0 && len(layers) > 0 {
+		rawRepositories, err := json.Marshal(repositories(tags, chainID.Hex))
+		if err != nil {
+			return err
+		}
+		if err := writeEntry(tw, repositoriesFile, rawRepositories); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// tags returns the parsed repo tags to write the image with.
+func (w Writer) tags(img *image.Image) ([]name.Tag, error) {
+	if len(w.Tags) == 0 {
+		return img.Metadata.Tags, nil
+	}
+
+	var tags []name.Tag
+	for _, tagStr := range w.Tags {
+		tag, err := name.NewTag(tagStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image tag %q: %w", tagStr, err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// nextChainID returns the chain ID of a layer from the chain ID of its parent layer, which is also the layer ID of
+// the layer within archives written by "docker save".
+func nextChainID(parent, diffID v1.Hash, first bool) v1.Hash {
+	if first {
+		return diffID
+	}
+	return v1.Hash{
+		Algorithm: "sha256",
+		Hex:       fmt.Sprintf("%x", sha256.Sum256([]byte(parent.String()+" "+diffID.String()))),
+	}
+}
+
+// writeLayer writes the uncompressed tar of the layer under the directory of the given layer ID, returning the path of
+// the layer tar within the archive. The layer is spooled to a temporary file first since the size of the uncompressed
+// layer must be known before it is written.
+func writeLayer(tw *tar.Writer, id string, layer v1.Layer) (string, error) {
+	reader, err := layer.Uncompressed()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	fh, err := os.CreateTemp("", "stereoscope-layer-*.tar")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(fh.Name())
+	defer fh.Close()
+
+	size, err := io.Copy(fh, reader)
+	if err != nil {
+		return "", err
+	}
+	if _, err := fh.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	if err := tw.WriteHeader(&tar.Header{Name: id + "/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		return "", err
+	}
+	layerPath := id + "/layer.tar"
+	if err := tw.WriteHeader(&tar.Header{Name: layerPath, Typeflag: tar.TypeReg, Mode: 0644, Size: size}); err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(tw, fh); err != nil {
+		return "", err
+	}
+	return layerPath, nil
+}
+
+func writeEntry(tw *tar.Writer, name string, content []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}); err != nil {
+		return fmt.Errorf("unable to write %q: %w", name, err)
+	}
+	if _, err := io.Copy(tw, bytes.NewReader(content)); err != nil {
+		return fmt.Errorf("unable to write %q: %w", name, err)
+	}
+	return nil
+}
+
+// repositories returns the contents of the (legacy) repositories file, mapping each tag of each repository to the
+// ID of the top layer of the image.
+func repositories(tags []name.Tag, topLayerID string) map[string]map[string]string {
+	repos := make(map[string]map[string]string)
+	for _, tag := range tags {
+		repo := familiarName(tag.Repository)
+		if repos[repo] == nil {
+			repos[repo] = make(map[string]string)
+		}
+		repos[repo][tag.TagStr()] = topLayerID
+	}
+	return repos
+}
+
+// familiarName returns the repository name as shown by docker, where the Docker Hub registry and "library/" namespace
+// are left out (e.g. "alpine" instead of "index.docker.io/library/alpine").
+func familiarName(repo name.Repository) string {
+	if repo.RegistryStr() != name.DefaultRegistry {
+		return repo.Name()
+	}
+	return strings.TrimPrefix(repo.RepositoryStr(), "library/")
+}
@@ -0,0 +1,129 @@
+package docker
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func TestWriter(t *testing.T) {
+	v1Img, err := random.Image(1024, 2)
+	require.NoError(t, err)
+
+	img := image.New(v1Img, nil, t.TempDir(), image.WithTags("alpine:latest", "example.com/app:v1"))
+	require.NoError(t, img.Read())
+
+	tests := []struct {
+		name      string
+		writer    Writer
+		wantTags  []string
+		wantRepos []string
+	}{
+		{
+			name:      "tags of the image",
+			wantTags:  []string{"alpine:latest", "example.com/app:v1"},
+			wantRepos: []string{"alpine", "example.com/app"},
+		},
+		{
+			name:      "given tags",
+			writer:    Writer{Tags: []string{"docker.io/anchore/app:v2"}},
+			wantTags:  []string{"anchore/app:v2"},
+			wantRepos: []string{"anchore/app"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), "image.tar")
+			fh, err := os.Create(archive)
+			require.NoError(t, err)
+			require.NoError(t, tt.writer.WriteArchive(fh, img))
+			require.NoError(t, fh.Close())
+
+			contents := readArchiveFiles(t, archive)
+			manifest, err := newManifest(contents[manifestFile])
+			require.NoError(t, err)
+			require.Len(t, manifest.parsed, 1)
+			assert.Equal(t, tt.wantTags, manifest.parsed[0].RepoTags)
+			require.Len(t, manifest.parsed[0].Layers, 2)
+
+			var repos map[string]map[string]string
+			require.NoError(t, json.Unmarshal(contents[repositoriesFile], &repos))
+			for _, repo := range tt.wantRepos {
+				assert.Contains(t, repos, repo)
+			}
+
+			generator := file.NewTempDirGenerator("docker-writer")
+			defer generator.Cleanup()
+
+			written, err := NewArchiveProvider(generator, archive).Provide(context.Background())
+			require.NoError(t, err)
+			defer written.Cleanup()
+			assert.Equal(t, img.Metadata.ID, written.Metadata.ID)
+			assert.Len(t, written.Layers, 2)
+			for idx, layer := range written.Layers {
+				assert.Equal(t, img.Layers[idx].Metadata.Digest, layer.Metadata.Digest)
+			}
+		})
+	}
+
+	t.Run("no content", func(t *testing.T) {
+		require.Error(t, Writer{}.WriteArchive(io.Discard, &image.Image{}))
+	})
+
+	t.Run("invalid tag", func(t *testing.T) {
+		require.Error(t, Writer{Tags: []string{"Invalid:Tag:"}}.WriteArchive(io.Discard, img))
+	})
+}
+
+func Test_familiarName(t *testing.T) {
+	tests := map[string]string{
+		"alpine":                          "alpine",
+		"docker.io/library/alpine":        "alpine",
+		"index.docker.io/anchore/syft":    "anchore/syft",
+		"ghcr.io/anchore/syft":            "ghcr.io/anchore/syft",
+		"localhost:5000/library/registry": "localhost:5000/library/registry",
+	}
+	for input, want := range tests {
+		t.Run(input, func(t *testing.T) {
+			repo, err := name.NewRepository(input)
+			require.NoError(t, err)
+			assert.Equal(t, want, familiarName(repo))
+		})
+	}
+}
+
+// readArchiveFiles returns the contents of the regular files within the archive at the given path.
+func readArchiveFiles(t *testing.T, path string) map[string][]byte {
+	t.Helper()
+	fh, err := os.Open(path)
+	require.NoError(t, err)
+	defer fh.Close()
+
+	contents := make(map[string][]byte)
+	tr := tar.NewReader(fh)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		contents[hdr.Name], err = io.ReadAll(tr)
+		require.NoError(t, err)
+	}
+	return contents
+}
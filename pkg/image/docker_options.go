@@ -0,0 +1,64 @@
+package image
+
+import "strings"
+
+// dockerSocketPathsVar lists the fallback daemon addresses probed by the docker client (see DockerOptions.SocketPaths).
+const dockerSocketPathsVar = "STEREOSCOPE_DOCKER_SOCKETS"
+
+// DockerOptions are explicit docker daemon connection settings, which take precedence over the DOCKER_* environment
+// variables (see Apply).
+type DockerOptions struct {
+	// Host is the daemon address, e.g. "unix:///var/run/docker.sock", "npipe:////./pipe/docker_engine",
+	// "tcp://host:2376", or "ssh://user@host"
+	Host string
+	// CertPath is the directory holding the TLS CA ("ca.pem") and client certificate ("cert.pem" and "key.pem")
+	CertPath string
+	// TLS connects to the daemon over TLS without verifying the daemon certificate (unless TLSVerify is set)
+	TLS bool
+	// TLSVerify connects to the daemon over TLS, verifying the daemon certificate
+	TLSVerify bool
+	// APIVersion pins the daemon API version instead of negotiating it
+	APIVersion string
+	// SocketPaths are the daemon addresses (or unix socket paths) to try, in order, when the default daemon is
+	// unavailable and no host is configured. These replace the built-in fallbacks (e.g. the Docker Desktop sockets
+	// within the home directory).
+	SocketPaths []string
+}
+
+// Apply returns the given environment (the process environment when nil) with the options applied as DOCKER_*
+// variables. When a host is given, the TLS settings of the environment are never used for it: only the TLS settings
+// given here apply, such that the environment configuration for another daemon does not leak into the connection.
+func (o DockerOptions) Apply(env *EnvOverrides) *EnvOverrides {
+	if o.APIVersion != "" {
+		env = env.WithVar("DOCKER_API_VERSION", o.APIVersion)
+	}
+	if len(o.SocketPaths) > 0 {
+		env = env.WithVar(dockerSocketPathsVar, strings.Join(o.SocketPaths, ","))
+	}
+
+	if o.Host != "" {
+		env = env.WithVar("DOCKER_HOST", o.Host)
+		env = env.WithVar("DOCKER_CERT_PATH", o.CertPath)
+		env = env.WithVar("DOCKER_TLS", boolVar(o.TLS))
+		return env.WithVar("DOCKER_TLS_VERIFY", boolVar(o.TLSVerify))
+	}
+
+	if o.CertPath != "" {
+		env = env.WithVar("DOCKER_CERT_PATH", o.CertPath)
+	}
+	if o.TLS {
+		env = env.WithVar("DOCKER_TLS", boolVar(o.TLS))
+	}
+	if o.TLSVerify {
+		env = env.WithVar("DOCKER_TLS_VERIFY", boolVar(o.TLSVerify))
+	}
+	return env
+}
+
+// boolVar returns the environment variable value docker uses for the given flag (set when not empty).
+func boolVar(b bool) string {
+	if b {
+		return "1"
+	}
+	return ""
+}
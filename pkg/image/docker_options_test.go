@@ -0,0 +1,81 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDockerOptions_Apply(t *testing.T) {
+	env := &EnvOverrides{
+		Vars: map[string]string{
+			"DOCKER_HOST":       "tcp://remote:2376",
+			"DOCKER_CERT_PATH":  "/certs",
+			"DOCKER_TLS_VERIFY": "1",
+		},
+		Hermetic: true,
+	}
+
+	tests := []struct {
+		name string
+		opts DockerOptions
+		want map[string]string
+	}{
+		{
+			name: "no options",
+			want: env.Vars,
+		},
+		{
+			name: "explicit host ignores environment tls settings",
+			opts: DockerOptions{Host: "npipe:////./pipe/docker_engine"},
+			want: map[string]string{
+				"DOCKER_HOST":       "npipe:////./pipe/docker_engine",
+				"DOCKER_CERT_PATH":  "",
+				"DOCKER_TLS":        "",
+				"DOCKER_TLS_VERIFY": "",
+			},
+		},
+		{
+			name: "explicit host with tls",
+			opts: DockerOptions{Host: "tcp://other:2376", CertPath: "/other-certs", TLSVerify: true, APIVersion: "1.43"},
+			want: map[string]string{
+				"DOCKER_HOST":        "tcp://other:2376",
+				"DOCKER_CERT_PATH":   "/other-certs",
+				"DOCKER_TLS":         "",
+				"DOCKER_TLS_VERIFY":  "1",
+				"DOCKER_API_VERSION": "1.43",
+			},
+		},
+		{
+			name: "tls settings for the environment host",
+			opts: DockerOptions{CertPath: "/other-certs"},
+			want: map[string]string{
+				"DOCKER_HOST":       "tcp://remote:2376",
+				"DOCKER_CERT_PATH":  "/other-certs",
+				"DOCKER_TLS_VERIFY": "1",
+			},
+		},
+		{
+			name: "socket fallbacks",
+			opts: DockerOptions{SocketPaths: []string{"/a/docker.sock", "/b/docker.sock"}},
+			want: map[string]string{
+				"DOCKER_HOST":                "tcp://remote:2376",
+				"DOCKER_CERT_PATH":           "/certs",
+				"DOCKER_TLS_VERIFY":          "1",
+				"STEREOSCOPE_DOCKER_SOCKETS": "/a/docker.sock,/b/docker.sock",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.opts.Apply(env)
+			assert.True(t, got.Hermetic)
+			assert.Equal(t, tt.want, got.Vars)
+		})
+	}
+
+	// the process environment is overridden when no environment is given
+	got := DockerOptions{Host: "unix:///custom/docker.sock"}.Apply(nil)
+	assert.False(t, got.Hermetic)
+	assert.Equal(t, "unix:///custom/docker.sock", got.Getenv("DOCKER_HOST"))
+}
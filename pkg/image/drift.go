@@ -0,0 +1,177 @@
+package image
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+)
+
+// DriftType describes how a path has changed relative to a base filesystem.
+type DriftType string
+
+const (
+	DriftAdded    DriftType = "added"
+	DriftModified DriftType = "modified"
+	DriftDeleted  DriftType = "deleted"
+)
+
+// FileDrift is a single path that differs between a base filesystem and a current filesystem.
+type FileDrift struct {
+	Path file.Path
+	Type DriftType
+	// Reference is the file within the current filesystem (nil for deleted paths or implicit directories).
+	Reference *file.Reference
+}
+
+// DriftFrom reports all paths that were added, modified, or deleted within this image squash tree relative to the
+// given base image squash tree.
+func (i *Image) DriftFrom(base *Image) ([]FileDrift, error) {
+	if i.FileCatalog == nil || base.FileCatalog == nil {
+		return nil, fmt.Errorf("image has not been read")
+	}
+	return CompareTrees(base.SquashedTree(), base.FileCatalog, i.SquashedTree(), i.FileCatalog)
+}
+
+// TopLayerDrift reports all paths that were added, modified, or deleted by the top layer of the image relative to the
+// squash of all layers below it. This is useful for images where the top layer represents runtime changes (e.g. from
+// the containerd snapshot provider).
+func (i *Image) TopLayerDrift() ([]FileDrift, error) {
+	if i.FileCatalog == nil {
+		return nil, fmt.Errorf("image has not been read")
+	}
+
+	if len(i.Layers) < 2 {
+		return nil, fmt.Errorf("image must have at least two layers to determine drift (has %d)", len(i.Layers))
+	}
+
+	base := i.Layers[len(i.Layers)-2].SquashedTree
+	current := i.Layers[len(i.Layers)-1].SquashedTree
+	return CompareTrees(base, i.FileCatalog, current, i.FileCatalog)
+}
+
+// CompareTrees reports all paths that were added, modified, or deleted within the current tree relative to the base
+// tree. Files are considered modified when any metadata differs, or when regular file contents differ.
+func CompareTrees(base filetree.Reader, baseCatalog FileCatalogReader, current filetree.Reader, currentCatalog FileCatalogReader) ([]FileDrift, error) {
+	var drift []FileDrift
+
+	for _, p := range current.AllRealPaths() {
+		_, currentRes, err := current.File(p)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve path %q: %w", p, err)
+		}
+		currentRef := resolutionReference(currentRes)
+
+		if !base.HasPath(p) {
+			drift = append(drift, FileDrift{Path: p, Type: DriftAdded, Reference: currentRef})
+			continue
+		}
+
+		_, baseRes, err := base.File(p)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve path %q: %w", p, err)
+		}
+
+		modified, err := isModified(resolutionReference(baseRes), baseCatalog, currentRef, currentCatalog)
+		if err != nil {
+			return nil, fmt.Errorf("unable to compare path %q: %w", p, err)
+		}
+
+		if modified {
+			drift = append(drift, FileDrift{Path: p, Type: DriftModified, Reference: currentRef})
+		}
+	}
+
+	for _, p := range base.AllRealPaths() {
+		if !current.HasPath(p) {
+			drift = append(drift, FileDrift{Path: p, Type: DriftDeleted})
+		}
+	}
+
+	sort.SliceStable(drift, func(i, j int) bool {
+		return drift[i].Path < drift[j].Path
+	})
+
+	return drift, nil
+}
+
+func resolutionReference(res *file.Resolution) *file.Reference {
+	if res == nil || !res.HasReference() {
+		return nil
+	}
+	return res.Reference
+}
+
+func isModified(baseRef *file.Reference, baseCatalog FileCatalogReader, currentRef *file.Reference, currentCatalog FileCatalogReader) (bool, error) {
+	switch {
+	case baseRef == nil && currentRef == nil:
+		// both are implicit directories
+		return false, nil
+	case baseRef == nil || currentRef == nil:
+		return true, nil
+	case baseRef.ID() == currentRef.ID():
+		return false, nil
+	}
+
+	baseEntry, err := baseCatalog.Get(*baseRef)
+	if err != nil {
+		return false, err
+	}
+
+	currentEntry, err := currentCatalog.Get(*currentRef)
+	if err != nil {
+		return false, err
+	}
+
+	b, c := baseEntry.Metadata, currentEntry.Metadata
+	if b.Type != c.Type ||
+		b.Mode() != c.Mode() ||
+		b.UserID != c.UserID ||
+		b.GroupID != c.GroupID ||
+		b.LinkDestination != c.LinkDestination ||
+		b.Size() != c.Size() {
+		return true, nil
+	}
+
+	if c.Type != file.TypeRegular {
+		return false, nil
+	}
+
+	baseDigest, err := ContentDigest(baseCatalog, *baseRef)
+	if err != nil {
+		return false, err
+	}
+
+	currentDigest, err := ContentDigest(currentCatalog, *currentRef)
+	if err != nil {
+		return false, err
+	}
+
+	return baseDigest != currentDigest, nil
+}
+
+// ContentDigest returns the sha256 digest of the contents of the given regular file ("sha256:<hex>"). The digest
+// recorded while reading the layers is used when the file was digested (see WithFileDigests), otherwise the contents
+// are read and digested.
+func ContentDigest(catalog FileCatalogReader, ref file.Reference) (string, error) {
+	if c, ok := catalog.(*FileCatalog); ok {
+		if digest := c.digests.sha256(ref); digest != "" {
+			return digest, nil
+		}
+	}
+
+	reader, err := catalog.Open(ref)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, reader); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%x", FileDigestSHA256, hasher.Sum(nil)), nil
+}
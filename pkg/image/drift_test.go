@@ -0,0 +1,43 @@
+package image
+
+import (
+	"archive/tar"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func TestImage_DriftFrom(t *testing.T) {
+	base := newTestImage(t, []tar.Header{
+		{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "etc/os-release", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "etc/hostname", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "etc/passwd", Typeflag: tar.TypeReg, Mode: 0644},
+	}, "ID=test\n")
+
+	current := newTestImage(t, []tar.Header{
+		{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "etc/os-release", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "etc/hostname", Typeflag: tar.TypeReg, Mode: 0600},
+		{Name: "tmp/", Typeflag: tar.TypeDir, Mode: 0777},
+		{Name: "tmp/dropped", Typeflag: tar.TypeReg, Mode: 0755},
+	}, "ID=test\n")
+
+	drift, err := current.DriftFrom(base)
+	require.NoError(t, err)
+
+	var got []FileDrift
+	for _, d := range drift {
+		got = append(got, FileDrift{Path: d.Path, Type: d.Type})
+	}
+
+	assert.Equal(t, []FileDrift{
+		{Path: file.Path("/etc/hostname"), Type: DriftModified},
+		{Path: file.Path("/etc/passwd"), Type: DriftDeleted},
+		{Path: file.Path("/tmp"), Type: DriftAdded},
+		{Path: file.Path("/tmp/dropped"), Type: DriftAdded},
+	}, got)
+}
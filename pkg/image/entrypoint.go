@@ -0,0 +1,200 @@
+package image
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+)
+
+// defaultExecPath is the PATH used by container runtimes when the image config does not set one.
+const defaultExecPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// shells are the names of shells recognized when determining if a command is in shell form (e.g. `sh -c "..."`).
+var shells = map[string]struct{}{
+	"sh":   {},
+	"ash":  {},
+	"bash": {},
+	"dash": {},
+	"zsh":  {},
+}
+
+// Executable is the program that a container started from an image would run, as resolved within the image tree.
+type Executable struct {
+	// Command is the effective command of the image (the entrypoint followed by the cmd)
+	Command []string
+	// Shell indicates the command is in shell form (a shell running a script given with "-c"), in which case the
+	// program is the first command within the script (which is resolved instead of the shell itself when possible)
+	Shell bool
+	// Program is the program name as given within the command (or script)
+	Program string
+	// Path is the path the program was found at (relative to the working directory or found within PATH), which may be
+	// a link
+	Path file.Path
+	// Reference is the regular file the program resolves to (following all links)
+	Reference *file.Reference
+}
+
+// ResolveEntrypoint resolves the effective entrypoint and cmd of the image config to the file that would be executed
+// within the image squash tree (see ResolveExecutable).
+func (i *Image) ResolveEntrypoint() (*Executable, error) {
+	if i.FileCatalog == nil {
+		return nil, fmt.Errorf("image has not been read")
+	}
+	return ResolveExecutable(i.SquashedTree(), i.FileCatalog, i.Metadata.Config.Config)
+}
+
+// ResolveExecutable resolves the effective command of the given config (the entrypoint followed by the cmd) to the file
+// that would be executed within the given tree. Program names without a slash are looked up within the PATH set by the
+// config environment (or the default runtime PATH), otherwise they are relative to the config working directory. As
+// with exec(3), only executable regular files are found when searching PATH. For commands in shell form the first
+// command of the script is resolved, falling back to the shell when that command is not a file (e.g. a builtin).
+func ResolveExecutable(tree filetree.Reader, catalog FileCatalogReader, cfg v1.Config) (*Executable, error) {
+	command := append(append([]string{}, cfg.Entrypoint...), cfg.Cmd...)
+	if len(command) == 0 || command[0] == "" {
+		return nil, fmt.Errorf("image config has no entrypoint or cmd")
+	}
+
+	exe := Executable{
+		Command: command,
+		Program: command[0],
+	}
+
+	resolver := executableResolver{
+		tree:       tree,
+		catalog:    catalog,
+		searchPath: configEnvValue(cfg.Env, "PATH", defaultExecPath),
+		workingDir: cfg.WorkingDir,
+	}
+
+	if script, ok := shellScript(command); ok {
+		exe.Shell = true
+		if program := scriptProgram(script); program != "" {
+			if p, ref, err := resolver.resolve(program); err == nil {
+				exe.Program, exe.Path, exe.Reference = program, p, ref
+				return &exe, nil
+			}
+		}
+	}
+
+	p, ref, err := resolver.resolve(exe.Program)
+	if err != nil {
+		return nil, err
+	}
+	exe.Path, exe.Reference = p, ref
+	return &exe, nil
+}
+
+type executableResolver struct {
+	tree       filetree.Reader
+	catalog    FileCatalogReader
+	searchPath string
+	workingDir string
+}
+
+// resolve finds the file for the given program name (as exec(3) would).
+func (r executableResolver) resolve(program string) (file.Path, *file.Reference, error) {
+	if strings.Contains(program, "/") {
+		p := r.absolute(program)
+		ref, err := r.file(p, false)
+		if err != nil {
+			return "", nil, err
+		}
+		return p, ref, nil
+	}
+
+	for _, dir := range strings.Split(r.searchPath, ":") {
+		if dir == "" {
+			// an empty PATH entry is the working directory
+			dir = "."
+		}
+		p := r.absolute(path.Join(dir, program))
+		if ref, err := r.file(p, true); err == nil {
+			return p, ref, nil
+		}
+	}
+	return "", nil, fmt.Errorf("unable to find executable %q in PATH %q", program, r.searchPath)
+}
+
+func (r executableResolver) absolute(p string) file.Path {
+	if path.IsAbs(p) {
+		return file.Path(path.Clean(p))
+	}
+	return file.Path(path.Join("/", r.workingDir, p))
+}
+
+// file returns the regular file at the given path (following links), optionally requiring any execute permission bit.
+func (r executableResolver) file(p file.Path, executable bool) (*file.Reference, error) {
+	exists, res, err := r.tree.File(p, filetree.FollowBasenameLinks)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve %q: %w", p, err)
+	}
+	if !exists || res == nil || !res.HasReference() {
+		return nil, fmt.Errorf("executable %q does not exist", p)
+	}
+
+	entry, err := r.catalog.Get(*res.Reference)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get metadata for %q: %w", p, err)
+	}
+	if entry.Metadata.Type != file.TypeRegular {
+		return nil, fmt.Errorf("executable %q is not a regular file", p)
+	}
+	if executable && entry.Metadata.Mode()&0111 == 0 {
+		return nil, fmt.Errorf("file %q is not executable", p)
+	}
+	return res.Reference, nil
+}
+
+// shellScript returns the script of a shell form command (e.g. ["/bin/sh", "-c", "exec app"]).
+func shellScript(command []string) (string, bool) {
+	if len(command) < 3 || command[1] != "-c" {
+		return "", false
+	}
+	if _, ok := shells[path.Base(command[0])]; !ok {
+		return "", false
+	}
+	return command[2], true
+}
+
+// scriptProgram returns the first program run by the given shell script, skipping any "exec" prefix and variable
+// assignments (e.g. "FOO=bar exec /app --flag" is "/app"). Empty is returned when the script starts with anything that
+// is not a simple command.
+func scriptProgram(script string) string {
+	for _, word := range strings.Fields(script) {
+		switch {
+		case word == "exec":
+			continue
+		case strings.Contains(word, "=") && !strings.HasPrefix(word, "="):
+			continue
+		case strings.ContainsAny(word, "'\"`$;&|<>(){}"):
+			return ""
+		default:
+			return word
+		}
+	}
+	return ""
+}
+
+// configEnvValue returns the value of the given variable within the config environment (or the fallback when unset).
+func configEnvValue(env []string, name, fallback string) string {
+	if value, ok := lookupConfigEnv(env, name); ok {
+		return value
+	}
+	return fallback
+}
+
+// lookupConfigEnv returns the value of the given variable within the config environment and whether it is set.
+func lookupConfigEnv(env []string, name string) (value string, found bool) {
+	for _, kv := range env {
+		// the last definition wins
+		if k, v, ok := strings.Cut(kv, "="); ok && k == name {
+			value, found = v, true
+		}
+	}
+	return value, found
+}
@@ -0,0 +1,114 @@
+package image
+
+import (
+	"archive/tar"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func TestImage_ResolveEntrypoint(t *testing.T) {
+	img := newTestImage(t, []tar.Header{
+		{Name: "bin/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "bin/busybox", Typeflag: tar.TypeReg, Mode: 0755},
+		{Name: "bin/sh", Typeflag: tar.TypeSymlink, Linkname: "busybox"},
+		{Name: "usr/local/bin/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "usr/local/bin/app", Typeflag: tar.TypeReg, Mode: 0755},
+		{Name: "usr/local/bin/current", Typeflag: tar.TypeSymlink, Linkname: "/usr/local/bin/app"},
+		{Name: "usr/bin/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "usr/bin/app", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "srv/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "srv/run.sh", Typeflag: tar.TypeReg, Mode: 0644},
+	}, "#!/bin/sh\n")
+
+	tests := []struct {
+		name        string
+		cfg         v1.Config
+		wantProgram string
+		wantPath    file.Path
+		wantReal    file.Path
+		wantShell   bool
+		wantErr     require.ErrorAssertionFunc
+	}{
+		{
+			name:        "entrypoint found in default PATH",
+			cfg:         v1.Config{Entrypoint: []string{"app"}, Cmd: []string{"--help"}},
+			wantProgram: "app",
+			wantPath:    "/usr/local/bin/app",
+			wantReal:    "/usr/local/bin/app",
+		},
+		{
+			name:        "non-executable files are skipped in PATH",
+			cfg:         v1.Config{Cmd: []string{"app"}, Env: []string{"PATH=/usr/bin:/usr/local/bin"}},
+			wantProgram: "app",
+			wantPath:    "/usr/local/bin/app",
+			wantReal:    "/usr/local/bin/app",
+		},
+		{
+			name:        "symlinks are followed",
+			cfg:         v1.Config{Cmd: []string{"current"}},
+			wantProgram: "current",
+			wantPath:    "/usr/local/bin/current",
+			wantReal:    "/usr/local/bin/app",
+		},
+		{
+			name:        "relative to working directory",
+			cfg:         v1.Config{Entrypoint: []string{"./run.sh"}, WorkingDir: "/srv"},
+			wantProgram: "./run.sh",
+			wantPath:    "/srv/run.sh",
+			wantReal:    "/srv/run.sh",
+		},
+		{
+			name:        "shell form resolves the script program",
+			cfg:         v1.Config{Cmd: []string{"/bin/sh", "-c", "FOO=bar exec app --serve"}},
+			wantProgram: "app",
+			wantPath:    "/usr/local/bin/app",
+			wantReal:    "/usr/local/bin/app",
+			wantShell:   true,
+		},
+		{
+			name:        "shell form falls back to the shell",
+			cfg:         v1.Config{Cmd: []string{"/bin/sh", "-c", "echo $HOME"}},
+			wantProgram: "/bin/sh",
+			wantPath:    "/bin/sh",
+			wantReal:    "/bin/busybox",
+			wantShell:   true,
+		},
+		{
+			name:    "not found",
+			cfg:     v1.Config{Cmd: []string{"missing"}},
+			wantErr: require.Error,
+		},
+		{
+			name:    "no command",
+			cfg:     v1.Config{},
+			wantErr: require.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+			img.Metadata.Config.Config = tt.cfg
+
+			exe, err := img.ResolveEntrypoint()
+			tt.wantErr(t, err)
+			if err != nil {
+				return
+			}
+
+			assert.Equal(t, append(append([]string{}, tt.cfg.Entrypoint...), tt.cfg.Cmd...), exe.Command)
+			assert.Equal(t, tt.wantShell, exe.Shell)
+			assert.Equal(t, tt.wantProgram, exe.Program)
+			assert.Equal(t, tt.wantPath, exe.Path)
+			require.NotNil(t, exe.Reference)
+			assert.Equal(t, tt.wantReal, exe.Reference.RealPath)
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package image
+
+import (
+	"context"
+
+	"github.com/anchore/stereoscope/internal/environ"
+)
+
+// EnvOverrides is an explicit view of the environment that image providers read from: environment variables such as
+// DOCKER_HOST, DOCKER_CONFIG, CONTAINERD_ADDRESS, CONTAINERD_NAMESPACE, and CONTAINER_HOST, as well as the home and XDG
+// runtime directories. Values given here take precedence over the process environment, and when Hermetic is set the
+// process environment is not consulted at all.
+type EnvOverrides = environ.Overrides
+
+// ContextWithEnvOverrides returns a context where all daemon providers (docker, podman, and containerd) discover their
+// runtimes using the given environment instead of the process environment.
+func ContextWithEnvOverrides(ctx context.Context, env *EnvOverrides) context.Context {
+	return environ.WithOverrides(ctx, env)
+}
@@ -0,0 +1,124 @@
+package image
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"syscall"
+
+	remoteerrors "github.com/containerd/containerd/remotes/errors"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// Kinds of provider failures, which are matched with errors.Is against the error of a provider (or the joined errors
+// of all providers attempted when detecting an image source), such that callers can tell apart failures without
+// parsing error strings (e.g. an image that was not found from a registry denying access).
+var (
+	// ErrNotAFile indicates the input path is not the kind of path the source reads (e.g. a directory given to an
+	// archive source, or a file given to a directory source)
+	ErrNotAFile = errors.New("input is not a file of the expected kind")
+	// ErrWrongArchiveFormat indicates the input is not in the format the source reads (e.g. a tar archive without a
+	// docker manifest, or a directory without an OCI layout)
+	ErrWrongArchiveFormat = errors.New("input is not in the expected archive format")
+	// ErrDaemonUnavailable indicates the daemon (or service) of the source is not running or not reachable
+	ErrDaemonUnavailable = errors.New("daemon is unavailable")
+	// ErrPlatformMismatch indicates the image does not match (or does not have a variant for) the requested platform
+	ErrPlatformMismatch = errors.New("image does not match the requested platform")
+	// ErrAuthFailure indicates the registry denied access with the given (or missing) credentials
+	ErrAuthFailure = errors.New("registry authentication failed")
+	// ErrImageNotFound indicates the source does not hold the image
+	ErrImageNotFound = errors.New("image not found")
+)
+
+// errorKinds are all kinds of provider failures.
+var errorKinds = []error{ErrNotAFile, ErrWrongArchiveFormat, ErrDaemonUnavailable, ErrPlatformMismatch, ErrAuthFailure, ErrImageNotFound}
+
+// MarkError returns the given error marked as the given kind of failure (e.g. ErrAuthFailure), such that
+// errors.Is(err, kind) holds (nil when the error is nil). The error string is not changed.
+func MarkError(err, kind error) error {
+	if err == nil {
+		return nil
+	}
+	return &markedError{err: err, kind: kind}
+}
+
+// classifyError marks the given provider error with the kind of failure of common causes (when the provider did not
+// mark the error already).
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind) {
+			return err
+		}
+	}
+
+	switch {
+	case errors.Is(err, syscall.EISDIR), errors.Is(err, syscall.ENOTDIR):
+		return MarkError(err, ErrNotAFile)
+	case errors.Is(err, tar.ErrHeader), errors.Is(err, gzip.ErrHeader):
+		return MarkError(err, ErrWrongArchiveFormat)
+	}
+
+	if kind := statusErrorKind(err); kind != nil {
+		return MarkError(err, kind)
+	}
+	return err
+}
+
+// statusErrorKind returns the kind of failure of the given registry or daemon response error (nil when unknown).
+func statusErrorKind(err error) error {
+	var statusCode int
+	var transportErr *transport.Error
+	var statusErr remoteerrors.ErrUnexpectedStatus
+	switch {
+	case errors.As(err, &transportErr):
+		statusCode = transportErr.StatusCode
+		for _, diagnostic := range transportErr.Errors {
+			switch diagnostic.Code {
+			case transport.ManifestUnknownErrorCode, transport.NameUnknownErrorCode:
+				return ErrImageNotFound
+			}
+		}
+	case errors.As(err, &statusErr):
+		statusCode = statusErr.StatusCode
+	}
+
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrAuthFailure
+	case http.StatusNotFound:
+		return ErrImageNotFound
+	}
+
+	// docker daemon errors (see github.com/docker/docker/errdefs)
+	var unauthorized interface{ Unauthorized() }
+	var forbidden interface{ Forbidden() }
+	var notFound interface{ NotFound() }
+	switch {
+	case errors.As(err, &unauthorized), errors.As(err, &forbidden):
+		return ErrAuthFailure
+	case errors.As(err, &notFound):
+		return ErrImageNotFound
+	}
+	return nil
+}
+
+type markedError struct {
+	err  error
+	kind error
+}
+
+func (e *markedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *markedError) Unwrap() error {
+	return e.err
+}
+
+func (e *markedError) Is(target error) bool {
+	return target == e.kind
+}
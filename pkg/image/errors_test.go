@@ -0,0 +1,86 @@
+package image
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+
+	remoteerrors "github.com/containerd/containerd/remotes/errors"
+	"github.com/docker/docker/errdefs"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkError(t *testing.T) {
+	assert.NoError(t, MarkError(nil, ErrAuthFailure))
+
+	cause := errors.New("denied")
+	err := fmt.Errorf("wrapped: %w", MarkError(cause, ErrAuthFailure))
+
+	assert.EqualError(t, err, "wrapped: denied")
+	assert.ErrorIs(t, err, ErrAuthFailure)
+	assert.ErrorIs(t, err, cause)
+	assert.NotErrorIs(t, err, ErrImageNotFound)
+
+	// kinds are matched within the joined errors of all providers
+	joined := errors.Join(errors.New("docker"), err)
+	assert.ErrorIs(t, joined, ErrAuthFailure)
+}
+
+func Test_classifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "nil"},
+		{name: "unknown", err: errors.New("failed")},
+		{name: "directory", err: &os.PathError{Op: "read", Path: "/dir", Err: syscall.EISDIR}, want: ErrNotAFile},
+		{name: "not a directory", err: &os.PathError{Op: "stat", Path: "/file/index.json", Err: syscall.ENOTDIR}, want: ErrNotAFile},
+		{name: "not a tar", err: fmt.Errorf("unable to read: %w", tar.ErrHeader), want: ErrWrongArchiveFormat},
+		{name: "registry unauthorized", err: &transport.Error{StatusCode: http.StatusUnauthorized}, want: ErrAuthFailure},
+		{name: "registry manifest unknown", err: &transport.Error{StatusCode: http.StatusBadRequest, Errors: []transport.Diagnostic{{Code: transport.ManifestUnknownErrorCode}}}, want: ErrImageNotFound},
+		{name: "registry not found", err: &transport.Error{StatusCode: http.StatusNotFound}, want: ErrImageNotFound},
+		{name: "containerd forbidden", err: fmt.Errorf("pull failed: %w", remoteerrors.ErrUnexpectedStatus{StatusCode: http.StatusForbidden}), want: ErrAuthFailure},
+		{name: "docker unauthorized", err: fmt.Errorf("pull failed: %w", errdefs.Unauthorized(errors.New("denied"))), want: ErrAuthFailure},
+		{name: "docker not found", err: errdefs.NotFound(errors.New("no such image")), want: ErrImageNotFound},
+		{name: "marked by the provider", err: MarkError(errdefs.NotFound(errors.New("no such platform")), ErrPlatformMismatch), want: ErrPlatformMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyError(tt.err)
+			if tt.err == nil {
+				require.NoError(t, got)
+				return
+			}
+			assert.ErrorIs(t, got, tt.err)
+			for _, kind := range errorKinds {
+				assert.Equal(t, kind == tt.want, errors.Is(got, kind), "kind %q", kind)
+			}
+		})
+	}
+}
+
+type failingProvider struct {
+	err error
+}
+
+func (p failingProvider) Name() string {
+	return "failing"
+}
+
+func (p failingProvider) Provide(context.Context) (*Image, error) {
+	return nil, p.err
+}
+
+func TestProvide_classifiesErrors(t *testing.T) {
+	_, err := Provide(context.Background(), failingProvider{err: fmt.Errorf("unable to read: %w", tar.ErrHeader)})
+	assert.ErrorIs(t, err, ErrWrongArchiveFormat)
+	assert.EqualError(t, err, "unable to read: archive/tar: invalid tar header")
+}
@@ -0,0 +1,268 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/wagoodman/go-partybus"
+	"github.com/wagoodman/go-progress"
+
+	"github.com/anchore/stereoscope/internal/bus"
+	"github.com/anchore/stereoscope/pkg/event"
+)
+
+const (
+	// DefaultExportChunkSize is the size of each chunk buffered between writing an image and the consumer.
+	DefaultExportChunkSize = 1 << 20
+	// DefaultExportBufferChunks is the number of chunks buffered ahead of a consumer before writing the image blocks.
+	DefaultExportBufferChunks = 8
+)
+
+// ExportOptions configures how an image is written (see Image.Export).
+type ExportOptions struct {
+	// ChunkSize is the size of each buffered chunk (DefaultExportChunkSize when zero)
+	ChunkSize int
+	// BufferChunks is the maximum number of chunks buffered ahead of the consumer (DefaultExportBufferChunks when
+	// zero), which bounds the memory used by the export regardless of the image size
+	BufferChunks int
+	// Tags are the tags written to the archive manifest (the tags of the image when empty, where images without tags
+	// are written untagged)
+	Tags []name.Tag
+}
+
+func (o ExportOptions) chunkSize() int {
+	if o.ChunkSize > 0 {
+		return o.ChunkSize
+	}
+	return DefaultExportChunkSize
+}
+
+func (o ExportOptions) bufferChunks() int {
+	if o.BufferChunks > 0 {
+		return o.BufferChunks
+	}
+	return DefaultExportBufferChunks
+}
+
+// Export writes the image as a docker archive (as written by "docker save", which is also usable as a
+// "docker-archive:" input) to the given writer. The archive is streamed while it is written: at most
+// ExportOptions.BufferChunks chunks are buffered ahead of the writer, such that a slow writer (e.g. a network upload)
+// applies backpressure instead of the image being buffered in memory or temp space. Progress is published as an
+// event.ExportImage event.
+func (i *Image) Export(ctx context.Context, w io.Writer, opts ExportOptions) error {
+	reader := i.ExportReader(ctx, opts)
+	_, err := io.Copy(w, reader)
+	if closeErr := reader.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ExportReader returns a reader of the docker archive of the image (see Export), which is written in the background
+// as the reader is consumed. The reader must be closed, which stops writing the archive when it has not been fully
+// read.
+func (i *Image) ExportReader(ctx context.Context, opts ExportOptions) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	pipe := newChunkPipe(ctx, opts.chunkSize(), opts.bufferChunks())
+
+	prog := i.trackExportProgress()
+	go func() {
+		defer cancel()
+		err := i.writeArchive(&progressWriter{Writer: pipe, progress: prog}, opts)
+		if err != nil {
+			prog.SetError(err)
+		} else {
+			prog.SetCompleted()
+		}
+		pipe.closeWithError(err)
+	}()
+
+	return &exportReader{pipe: pipe, cancel: cancel}
+}
+
+// writeArchive writes the docker archive of the image to the given writer.
+func (i *Image) writeArchive(w io.Writer, opts ExportOptions) error {
+	if i.image == nil {
+		return fmt.Errorf("image has no content to export")
+	}
+
+	tags := opts.Tags
+	if len(tags) == 0 {
+		tags = i.Metadata.Tags
+	}
+
+	refs := make(map[name.Reference]v1.Image)
+	for _, tag := range tags {
+		refs[tag] = i.image
+	}
+	if len(refs) == 0 {
+		// note: digest references are written to the archive manifest without any repo tags
+		digest, err := i.image.Digest()
+		if err != nil {
+			return fmt.Errorf("unable to determine image digest: %w", err)
+		}
+		ref, err := name.NewDigest("image@" + digest.String())
+		if err != nil {
+			return err
+		}
+		refs[ref] = i.image
+	}
+
+	if err := tarball.MultiRefWrite(refs, w); err != nil {
+		return fmt.Errorf("unable to write image archive: %w", err)
+	}
+	return nil
+}
+
+// trackExportProgress publishes the progress of exporting the image, measured in bytes of layer content.
+func (i *Image) trackExportProgress() *progress.Manual {
+	var total int64
+	if i.image != nil {
+		if layers, err := i.image.Layers(); err == nil {
+			for _, layer := range layers {
+				if size, err := layer.Size(); err == nil {
+					total += size
+				}
+			}
+		}
+	}
+	prog := progress.NewManual(total)
+
+	bus.Publish(partybus.Event{
+		Type:   event.ExportImage,
+		Source: i.Metadata,
+		Value:  progress.Progressable(prog),
+	})
+
+	return prog
+}
+
+type progressWriter struct {
+	io.Writer
+	progress *progress.Manual
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.progress.Add(int64(n))
+	return n, err
+}
+
+type exportReader struct {
+	pipe   *chunkPipe
+	cancel context.CancelFunc
+}
+
+func (r *exportReader) Read(p []byte) (int, error) {
+	return r.pipe.Read(p)
+}
+
+// Close stops writing the archive (when not fully read), returning any error from writing the archive.
+func (r *exportReader) Close() error {
+	r.cancel()
+	err := r.pipe.wait()
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// chunkPipe is an in-memory pipe that buffers a bounded number of chunks between a writer and a reader: writes block
+// once all chunks are buffered until the reader consumes a chunk (or the context is done).
+type chunkPipe struct {
+	ctx       context.Context
+	chunkSize int
+	chunks    chan []byte
+	// current is the chunk being filled by writes
+	current []byte
+	// pending is the remainder of the chunk being consumed by reads
+	pending []byte
+
+	once sync.Once
+	err  error
+	done chan struct{}
+}
+
+func newChunkPipe(ctx context.Context, chunkSize, bufferChunks int) *chunkPipe {
+	return &chunkPipe{
+		ctx:       ctx,
+		chunkSize: chunkSize,
+		chunks:    make(chan []byte, bufferChunks),
+		done:      make(chan struct{}),
+	}
+}
+
+func (p *chunkPipe) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		if p.current == nil {
+			p.current = make([]byte, 0, p.chunkSize)
+		}
+		n := min(len(b), p.chunkSize-len(p.current))
+		p.current = append(p.current, b[:n]...)
+		b = b[n:]
+		written += n
+
+		if len(p.current) == p.chunkSize {
+			if err := p.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// flush sends the current chunk to the reader, blocking while all chunks are buffered.
+func (p *chunkPipe) flush() error {
+	if len(p.current) == 0 {
+		return nil
+	}
+	select {
+	case p.chunks <- p.current:
+		p.current = nil
+		return nil
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
+// closeWithError flushes any remaining content and closes the writing side of the pipe, where reads return the given
+// error (or io.EOF when nil) once all buffered chunks are consumed.
+func (p *chunkPipe) closeWithError(err error) {
+	if err == nil {
+		err = p.flush()
+	}
+	p.once.Do(func() {
+		p.err = err
+		close(p.chunks)
+		close(p.done)
+	})
+}
+
+func (p *chunkPipe) Read(b []byte) (int, error) {
+	if len(p.pending) == 0 {
+		chunk, ok := <-p.chunks
+		if !ok {
+			if p.err != nil {
+				return 0, p.err
+			}
+			return 0, io.EOF
+		}
+		p.pending = chunk
+	}
+	n := copy(b, p.pending)
+	p.pending = p.pending[n:]
+	return n, nil
+}
+
+// wait blocks until the writing side is closed, returning the error it was closed with.
+func (p *chunkPipe) wait() error {
+	<-p.done
+	return p.err
+}
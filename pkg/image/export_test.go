@@ -0,0 +1,118 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_Export(t *testing.T) {
+	img := newTestImage(t, []tar.Header{
+		{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "etc/os-release", Typeflag: tar.TypeReg, Mode: 0644},
+	}, "ID=test\n")
+
+	tag, err := name.NewTag("example.com/app:v1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		tag  *name.Tag
+	}{
+		{name: "untagged"},
+		{name: "tagged", tag: &tag},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := ExportOptions{ChunkSize: 64, BufferChunks: 2}
+			if tt.tag != nil {
+				opts.Tags = []name.Tag{*tt.tag}
+			}
+
+			buf := &bytes.Buffer{}
+			require.NoError(t, img.Export(context.Background(), buf, opts))
+
+			exported, err := tarball.Image(func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+			}, tt.tag)
+			require.NoError(t, err)
+
+			exportedID, err := exported.ConfigName()
+			require.NoError(t, err)
+			assert.Equal(t, img.Metadata.ID, exportedID.String())
+
+			layers, err := exported.Layers()
+			require.NoError(t, err)
+			require.Len(t, layers, 1)
+			diffID, err := layers[0].DiffID()
+			require.NoError(t, err)
+			assert.Equal(t, img.Layers[0].Metadata.Digest, diffID.String())
+		})
+	}
+}
+
+func TestImage_ExportReader_closeEarly(t *testing.T) {
+	img := newTestImage(t, []tar.Header{
+		{Name: "etc/os-release", Typeflag: tar.TypeReg, Mode: 0644},
+	}, "ID=test\n")
+
+	reader := img.ExportReader(context.Background(), ExportOptions{ChunkSize: 16, BufferChunks: 1})
+	first := make([]byte, 8)
+	_, err := io.ReadFull(reader, first)
+	require.NoError(t, err)
+
+	// the archive is no longer written once the consumer stops reading
+	require.NoError(t, reader.Close())
+}
+
+func TestImage_Export_noContent(t *testing.T) {
+	err := (&Image{}).Export(context.Background(), io.Discard, ExportOptions{})
+	require.ErrorContains(t, err, "no content to export")
+}
+
+func Test_chunkPipe_backpressure(t *testing.T) {
+	pipe := newChunkPipe(context.Background(), 4, 2)
+
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	var written atomic.Int32
+	go func() {
+		for _, b := range content {
+			_, err := pipe.Write([]byte{b})
+			if err != nil {
+				pipe.closeWithError(err)
+				return
+			}
+			written.Add(1)
+		}
+		pipe.closeWithError(nil)
+	}()
+
+	// at most the buffered chunks (and a partial chunk) are written before the reader consumes any content
+	assert.Never(t, func() bool { return written.Load() > 12 }, 50*time.Millisecond, 5*time.Millisecond)
+
+	got, err := io.ReadAll(pipe)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+	require.NoError(t, pipe.wait())
+}
+
+func Test_chunkPipe_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pipe := newChunkPipe(ctx, 4, 1)
+
+	_, err := pipe.Write([]byte("0123"))
+	require.NoError(t, err)
+
+	cancel()
+	_, err = pipe.Write([]byte("4567"))
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,154 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+// DefaultFetchConcurrency is the number of files read at a time by FetchContents when no concurrency is configured.
+const DefaultFetchConcurrency = 8
+
+// FetchConfig configures batched file content fetching (see FetchContents).
+type FetchConfig struct {
+	// Concurrency is the maximum number of files read at a time (DefaultFetchConcurrency when zero)
+	Concurrency int
+	// MaxFileSize fails fetching any file with contents larger than the given number of bytes (no limit when zero)
+	MaxFileSize int64
+}
+
+// FileContents is the result of fetching the contents of a single file.
+type FileContents struct {
+	Reference file.Reference
+	Contents  []byte
+	// Err is the reason the contents could not be fetched (nil on success)
+	Err error
+}
+
+// FetchContents reads the contents of all given file references with the default configuration, returning the results
+// in the same order as the given references (see the FetchContents function).
+func (i *Image) FetchContents(ctx context.Context, refs ...file.Reference) []FileContents {
+	var catalog FileCatalogReader
+	if i.FileCatalog != nil {
+		catalog = i.FileCatalog
+	}
+	return FetchContents(ctx, catalog, FetchConfig{}, refs...)
+}
+
+// FetchContents reads the contents of all given file references from the catalog with bounded concurrency, returning
+// the results in the same order as the given references. Files are read in layer order (and in the order they appear
+// within each layer tar) regardless of the requested order, so large batches result in mostly sequential access of
+// the cached layer tars. Each file is read once, even if it is requested multiple times. Failures are reported for
+// each file individually (including context cancellation for files that were not read).
+func FetchContents(ctx context.Context, catalog FileCatalogReader, cfg FetchConfig, refs ...file.Reference) []FileContents {
+	results := make([]FileContents, len(refs))
+	if len(refs) == 0 {
+		return results
+	}
+
+	// group requests by file such that each file is read once
+	requested := make(map[file.ID][]int)
+	var unique []file.Reference
+	for idx, ref := range refs {
+		results[idx].Reference = ref
+		if _, ok := requested[ref.ID()]; !ok {
+			unique = append(unique, ref)
+		}
+		requested[ref.ID()] = append(requested[ref.ID()], idx)
+	}
+
+	if catalog == nil {
+		for idx := range results {
+			results[idx].Err = fmt.Errorf("image has not been read")
+		}
+		return results
+	}
+
+	forEachInReadOrder(catalog, cfg.Concurrency, unique, func(ref file.Reference) {
+		contents, err := fetchContents(ctx, catalog, ref, cfg.MaxFileSize)
+		// note: each index is only ever written by a single worker
+		for _, idx := range requested[ref.ID()] {
+			results[idx].Contents = contents
+			results[idx].Err = err
+		}
+	})
+
+	return results
+}
+
+// forEachInReadOrder calls the given function for all references (in read order, see sortByReadOrder) from a bounded
+// number of workers (DefaultFetchConcurrency when not positive), returning once all calls have completed.
+func forEachInReadOrder(catalog FileCatalogReader, concurrency int, refs []file.Reference, fn func(file.Reference)) {
+	sortByReadOrder(catalog, refs)
+
+	if concurrency <= 0 {
+		concurrency = DefaultFetchConcurrency
+	}
+
+	work := make(chan file.Reference)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ref := range work {
+				fn(ref)
+			}
+		}()
+	}
+
+	for _, ref := range refs {
+		work <- ref
+	}
+	close(work)
+	wg.Wait()
+}
+
+func fetchContents(ctx context.Context, catalog FileCatalogReader, ref file.Reference, maxSize int64) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	reader, err := catalog.Open(ref)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	if maxSize <= 0 {
+		return io.ReadAll(reader)
+	}
+
+	contents, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(contents)) > maxSize {
+		return nil, fmt.Errorf("file %q exceeds the max fetch size of %d bytes", ref.RealPath, maxSize)
+	}
+	return contents, nil
+}
+
+// sortByReadOrder sorts the given references by the layer they are from, then by the order they were cataloged within
+// that layer (file IDs are assigned sequentially while each layer tar is indexed).
+func sortByReadOrder(catalog FileCatalogReader, refs []file.Reference) {
+	layerIndex := make(map[file.ID]int, len(refs))
+	for _, ref := range refs {
+		layerIndex[ref.ID()] = -1
+		if l := catalog.Layer(ref); l != nil {
+			layerIndex[ref.ID()] = int(l.Metadata.Index)
+		}
+	}
+
+	sort.SliceStable(refs, func(a, b int) bool {
+		la, lb := layerIndex[refs[a].ID()], layerIndex[refs[b].ID()]
+		if la != lb {
+			return la < lb
+		}
+		return refs[a].ID() < refs[b].ID()
+	})
+}
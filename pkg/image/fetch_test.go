@@ -0,0 +1,112 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func TestFetchContents(t *testing.T) {
+	lower := map[string]string{}
+	for i := 0; i < 50; i++ {
+		lower[fmt.Sprintf("lower/file-%02d", i)] = fmt.Sprintf("lower contents %d", i)
+	}
+
+	v1Img, err := mutate.AppendLayers(empty.Image,
+		tarLayer(t, lower),
+		tarLayer(t, map[string]string{"upper/big": "this file is larger than the others"}),
+	)
+	require.NoError(t, err)
+
+	img := New(v1Img, nil, t.TempDir())
+	require.NoError(t, img.Read())
+
+	resolve := func(p string) file.Reference {
+		t.Helper()
+		_, ref, err := img.SquashedTree().File(file.Path(p))
+		require.NoError(t, err)
+		require.NotNil(t, ref)
+		return *ref.Reference
+	}
+
+	// request in reverse order (with a duplicate) to ensure results are ordered by the request
+	var refs []file.Reference
+	refs = append(refs, resolve("/upper/big"))
+	for i := 49; i >= 0; i-- {
+		refs = append(refs, resolve(fmt.Sprintf("/lower/file-%02d", i)))
+	}
+	refs = append(refs, resolve("/lower/file-07"))
+
+	t.Run("ordered results", func(t *testing.T) {
+		results := FetchContents(context.Background(), img.FileCatalog, FetchConfig{Concurrency: 3}, refs...)
+		require.Len(t, results, len(refs))
+
+		assert.Equal(t, "this file is larger than the others", string(results[0].Contents))
+		for i := 1; i <= 50; i++ {
+			require.NoError(t, results[i].Err)
+			assert.Equal(t, refs[i], results[i].Reference)
+			assert.Equal(t, fmt.Sprintf("lower contents %d", 50-i), string(results[i].Contents))
+		}
+		assert.Equal(t, "lower contents 7", string(results[51].Contents))
+	})
+
+	t.Run("image defaults", func(t *testing.T) {
+		results := img.FetchContents(context.Background(), refs[:2]...)
+		require.Len(t, results, 2)
+		assert.Equal(t, "lower contents 49", string(results[1].Contents))
+	})
+
+	t.Run("max file size", func(t *testing.T) {
+		results := FetchContents(context.Background(), img.FileCatalog, FetchConfig{MaxFileSize: 20}, refs[:2]...)
+		require.Error(t, results[0].Err)
+		assert.Nil(t, results[0].Contents)
+		require.NoError(t, results[1].Err)
+	})
+
+	t.Run("unknown reference", func(t *testing.T) {
+		results := FetchContents(context.Background(), img.FileCatalog, FetchConfig{}, *file.NewFileReference("/nope"), refs[1])
+		require.Error(t, results[0].Err)
+		require.NoError(t, results[1].Err)
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		for _, result := range FetchContents(ctx, img.FileCatalog, FetchConfig{}, refs...) {
+			require.ErrorIs(t, result.Err, context.Canceled)
+		}
+	})
+
+	t.Run("unread image", func(t *testing.T) {
+		unread := New(v1Img, nil, t.TempDir())
+		results := unread.FetchContents(context.Background(), refs[0])
+		require.Len(t, results, 1)
+		require.Error(t, results[0].Err)
+	})
+}
+
+func Test_sortByReadOrder(t *testing.T) {
+	catalog := NewFileCatalog()
+	lower := &Layer{Metadata: LayerMetadata{Index: 0}}
+	upper := &Layer{Metadata: LayerMetadata{Index: 1}}
+
+	// the upper layer file is cataloged first (and has a lower ID)
+	a := *file.NewFileReference("/a")
+	b := *file.NewFileReference("/b")
+	c := *file.NewFileReference("/c")
+	catalog.Add(a, file.Metadata{}, upper, nil)
+	catalog.Add(b, file.Metadata{}, lower, nil)
+	catalog.Add(c, file.Metadata{}, lower, nil)
+
+	refs := []file.Reference{c, a, b}
+	sortByReadOrder(catalog, refs)
+	assert.Equal(t, []file.Reference{b, c, a}, refs)
+}
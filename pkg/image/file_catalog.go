@@ -5,6 +5,7 @@ import (
 	"io"
 	"sync"
 
+	"github.com/anchore/stereoscope/internal/log"
 	"github.com/anchore/stereoscope/pkg/file"
 	"github.com/anchore/stereoscope/pkg/filetree"
 )
@@ -22,6 +23,9 @@ type FileCatalog struct {
 	filetree.Index
 	layerByID  map[file.ID]*Layer
 	openerByID map[file.ID]file.Opener
+	// digests are the digests of the contents of all regular files, when recorded while reading layers (see
+	// WithFileDigests)
+	digests *fileDigestIndex
 }
 
 // NewFileCatalog returns an empty FileCatalog.
@@ -48,6 +52,32 @@ func (c *FileCatalog) addImageReferences(id file.ID, l *Layer, opener file.Opene
 	c.openerByID[id] = opener
 }
 
+// remove deletes the entries of the given file references (e.g. the files of a layer that failed to be read).
+func (c *FileCatalog) remove(refs ...file.Reference) {
+	remover, ok := c.Index.(filetree.IndexRemover)
+	if !ok {
+		log.WithFields("entries", len(refs)).Debug("file catalog index does not support removal, keeping entries")
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	for _, ref := range refs {
+		if remover != nil {
+			remover.Remove(ref)
+		}
+		delete(c.layerByID, ref.ID())
+		delete(c.openerByID, ref.ID())
+	}
+}
+
+// removeTree deletes the entries of all files within the given layer tree (if any).
+func (c *FileCatalog) removeTree(tree filetree.Reader) {
+	if tree == nil {
+		return
+	}
+	c.remove(tree.AllFiles(file.AllTypes()...)...)
+}
+
 func (c *FileCatalog) Layer(f file.Reference) *Layer {
 	c.RLock()
 	defer c.RUnlock()
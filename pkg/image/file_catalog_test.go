@@ -4,6 +4,7 @@
 package image
 
 import (
+	"context"
 	"crypto/sha256"
 	"fmt"
 	"io"
@@ -222,7 +223,7 @@ func TestFileCatalog_GetByExtension(t *testing.T) {
 	// we don't need the index itself, just the side effect on the file catalog after indexing
 	_, err := file.NewTarIndex(
 		fixtureTarFile.Name(),
-		layerTarIndexer(ft, fileCatalog, &size, nil, nil),
+		layerTarIndexer(context.Background(), ft, fileCatalog, &size, nil, nil, nil),
 	)
 	require.NoError(t, err)
 
@@ -389,7 +390,7 @@ func TestFileCatalog_GetByBasename(t *testing.T) {
 	// we don't need the index itself, just the side effect on the file catalog after indexing
 	_, err := file.NewTarIndex(
 		fixtureTarFile.Name(),
-		layerTarIndexer(ft, fileCatalog, &size, nil, nil),
+		layerTarIndexer(context.Background(), ft, fileCatalog, &size, nil, nil, nil),
 	)
 	require.NoError(t, err)
 
@@ -493,7 +494,7 @@ func TestFileCatalog_GetByBasenameGlob(t *testing.T) {
 	// we don't need the index itself, just the side effect on the file catalog after indexing
 	_, err := file.NewTarIndex(
 		fixtureTarFile.Name(),
-		layerTarIndexer(ft, fileCatalog, &size, nil, nil),
+		layerTarIndexer(context.Background(), ft, fileCatalog, &size, nil, nil, nil),
 	)
 	require.NoError(t, err)
 
@@ -605,7 +606,7 @@ func TestFileCatalog_GetByMimeType(t *testing.T) {
 	// we don't need the index itself, just the side effect on the file catalog after indexing
 	_, err := file.NewTarIndex(
 		fixtureTarFile.Name(),
-		layerTarIndexer(ft, fileCatalog, &size, nil, nil),
+		layerTarIndexer(context.Background(), ft, fileCatalog, &size, nil, nil, nil),
 	)
 	require.NoError(t, err)
 
@@ -706,7 +707,7 @@ func TestFileCatalog_GetBasenames(t *testing.T) {
 	// we don't need the index itself, just the side effect on the file catalog after indexing
 	_, err := file.NewTarIndex(
 		fixtureTarFile.Name(),
-		layerTarIndexer(ft, fileCatalog, &size, nil, nil),
+		layerTarIndexer(context.Background(), ft, fileCatalog, &size, nil, nil, nil),
 	)
 	require.NoError(t, err)
 
@@ -0,0 +1,192 @@
+package image
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+// FileDigestAlgorithm is a hash algorithm used to digest the contents of all regular files (see WithFileDigests).
+type FileDigestAlgorithm string
+
+const (
+	FileDigestSHA256 FileDigestAlgorithm = "sha256"
+	// FileDigestXXH64 is a fast non-cryptographic hash, suitable for deduplication but not for integrity checks.
+	FileDigestXXH64 FileDigestAlgorithm = "xxh64"
+)
+
+func (a FileDigestAlgorithm) hash() (hash.Hash, error) {
+	switch a {
+	case FileDigestSHA256:
+		return sha256.New(), nil
+	case FileDigestXXH64:
+		return xxhash.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported file digest algorithm: %q", a)
+}
+
+// WithFileDigests digests the contents of every regular file within all layers while layers are read, such that files
+// can be found by digest without reading their contents again (see Image.FilesByDigest and Image.FileDigests). Files
+// are always digested with sha256, along with any of the given additional algorithms (e.g. FileDigestXXH64). Note: this
+// reads the contents of all files, thus the contents of lazily fetched layers are fetched in full.
+func WithFileDigests(algorithms ...FileDigestAlgorithm) AdditionalMetadata {
+	return func(image *Image) error {
+		all := []FileDigestAlgorithm{FileDigestSHA256}
+		for _, algorithm := range algorithms {
+			if _, err := algorithm.hash(); err != nil {
+				return err
+			}
+			if !containsAlgorithm(all, algorithm) {
+				all = append(all, algorithm)
+			}
+		}
+		image.fileDigestAlgorithms = all
+		return nil
+	}
+}
+
+func containsAlgorithm(algorithms []FileDigestAlgorithm, algorithm FileDigestAlgorithm) bool {
+	for _, a := range algorithms {
+		if a == algorithm {
+			return true
+		}
+	}
+	return false
+}
+
+// fileDigestIndex holds the digests of all regular files of the layers read so far.
+type fileDigestIndex struct {
+	algorithms []FileDigestAlgorithm
+	lock       sync.RWMutex
+	byDigest   map[string][]file.Reference
+	byID       map[file.ID][]string
+}
+
+func newFileDigestIndex(algorithms []FileDigestAlgorithm) *fileDigestIndex {
+	if len(algorithms) == 0 {
+		return nil
+	}
+	return &fileDigestIndex{
+		algorithms: algorithms,
+		byDigest:   make(map[string][]file.Reference),
+		byID:       make(map[file.ID][]string),
+	}
+}
+
+// addLayer digests all regular files within the tree of the given (read) layer, in path order.
+func (x *fileDigestIndex) addLayer(layer *Layer, catalog FileCatalogReader) error {
+	if x == nil || layer.Tree == nil {
+		return nil
+	}
+
+	refs := layer.Tree.AllFiles(file.TypeRegular)
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].RealPath < refs[j].RealPath
+	})
+
+	for _, ref := range refs {
+		digests, err := x.digest(catalog, ref)
+		if err != nil {
+			return fmt.Errorf("unable to digest %q in layer=%q: %w", ref.RealPath, layer.Metadata.Digest, err)
+		}
+		x.add(ref, digests)
+	}
+	return nil
+}
+
+func (x *fileDigestIndex) digest(catalog FileCatalogReader, ref file.Reference) ([]string, error) {
+	hashes := make([]hash.Hash, len(x.algorithms))
+	writers := make([]io.Writer, len(x.algorithms))
+	for idx, algorithm := range x.algorithms {
+		h, err := algorithm.hash()
+		if err != nil {
+			return nil, err
+		}
+		hashes[idx], writers[idx] = h, h
+	}
+
+	reader, err := catalog.Open(ref)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	if _, err := io.Copy(io.MultiWriter(writers...), reader); err != nil {
+		return nil, err
+	}
+
+	digests := make([]string, len(hashes))
+	for idx, h := range hashes {
+		digests[idx] = fmt.Sprintf("%s:%x", x.algorithms[idx], h.Sum(nil))
+	}
+	return digests, nil
+}
+
+// sha256 returns the sha256 digest recorded for the given file (empty when the file was not digested).
+func (x *fileDigestIndex) sha256(ref file.Reference) string {
+	if x == nil {
+		return ""
+	}
+
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+
+	// note: sha256 is always the first algorithm
+	if digests := x.byID[ref.ID()]; len(digests) > 0 {
+		return digests[0]
+	}
+	return ""
+}
+
+func (x *fileDigestIndex) add(ref file.Reference, digests []string) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	x.byID[ref.ID()] = digests
+	for _, d := range digests {
+		x.byDigest[d] = append(x.byDigest[d], ref)
+	}
+}
+
+// FilesByDigest returns all regular files within any layer of the image with contents matching the given digest (in
+// "<algorithm>:<hex>" form, where digests without an algorithm are sha256 digests), in layer order. Files are only
+// digested when requested before the image is read (see WithFileDigests).
+func (i *Image) FilesByDigest(digest string) ([]file.Reference, error) {
+	if i.fileDigests == nil {
+		return nil, fmt.Errorf("file digests were not computed (see WithFileDigests)")
+	}
+
+	algorithm, hexDigest, found := strings.Cut(strings.TrimSpace(digest), ":")
+	if !found {
+		algorithm, hexDigest = string(FileDigestSHA256), algorithm
+	}
+	if !containsAlgorithm(i.fileDigests.algorithms, FileDigestAlgorithm(algorithm)) {
+		return nil, fmt.Errorf("file digests were not computed with algorithm %q", algorithm)
+	}
+
+	i.fileDigests.lock.RLock()
+	defer i.fileDigests.lock.RUnlock()
+
+	refs := i.fileDigests.byDigest[algorithm+":"+strings.ToLower(hexDigest)]
+	return append([]file.Reference(nil), refs...), nil
+}
+
+// FileDigests returns the digests of the contents of the given regular file (in "<algorithm>:<hex>" form), one for
+// each algorithm requested with WithFileDigests (sha256 first). Nil is returned for files that were not digested.
+func (i *Image) FileDigests(ref file.Reference) []string {
+	if i.fileDigests == nil {
+		return nil
+	}
+
+	i.fileDigests.lock.RLock()
+	defer i.fileDigests.lock.RUnlock()
+	return append([]string(nil), i.fileDigests.byID[ref.ID()]...)
+}
@@ -0,0 +1,151 @@
+package image
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func TestImage_FilesByDigest(t *testing.T) {
+	v1Img, err := mutate.AppendLayers(empty.Image,
+		tarLayer(t, map[string]string{"bin/tool": "payload", "etc/os-release": "ID=test\n"}),
+		tarLayer(t, map[string]string{"opt/copy/tool": "payload", "etc/os-release": "ID=other\n"}),
+	)
+	require.NoError(t, err)
+
+	img := New(v1Img, nil, t.TempDir(), WithFileDigests(FileDigestXXH64))
+	require.NoError(t, img.Read())
+
+	payload := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("payload")))
+
+	tests := []struct {
+		name    string
+		digest  string
+		want    []file.Path
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:   "across layers",
+			digest: payload,
+			want:   []file.Path{"/bin/tool", "/opt/copy/tool"},
+		},
+		{
+			name:   "without algorithm",
+			digest: fmt.Sprintf("%x", sha256.Sum256([]byte("ID=test\n"))),
+			want:   []file.Path{"/etc/os-release"},
+		},
+		{
+			name:    "algorithm not computed",
+			digest:  "sha512:abc",
+			wantErr: require.Error,
+		},
+		{
+			name:   "no match",
+			digest: fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("missing"))),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+			refs, err := img.FilesByDigest(tt.digest)
+			tt.wantErr(t, err)
+
+			var got []file.Path
+			for _, ref := range refs {
+				got = append(got, ref.RealPath)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("digests of a file", func(t *testing.T) {
+		refs, err := img.FilesByDigest(payload)
+		require.NoError(t, err)
+		require.NotEmpty(t, refs)
+
+		digests := img.FileDigests(refs[0])
+		require.Len(t, digests, 2)
+		assert.Equal(t, payload, digests[0])
+
+		// the xxh64 digest finds the same files
+		byXXH64, err := img.FilesByDigest(digests[1])
+		require.NoError(t, err)
+		assert.Equal(t, refs, byXXH64)
+	})
+}
+
+func TestImage_FilesByDigest_notComputed(t *testing.T) {
+	v1Img, err := mutate.AppendLayers(empty.Image, tarLayer(t, map[string]string{"etc/os-release": "ID=test\n"}))
+	require.NoError(t, err)
+
+	img := New(v1Img, nil, t.TempDir())
+	require.NoError(t, img.Read())
+
+	_, err = img.FilesByDigest(fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("ID=test\n"))))
+	require.Error(t, err)
+	assert.Nil(t, img.FileDigests(file.Reference{}))
+}
+
+func TestWithFileDigests(t *testing.T) {
+	img := &Image{}
+	require.NoError(t, WithFileDigests(FileDigestSHA256, FileDigestXXH64, FileDigestXXH64)(img))
+	assert.Equal(t, []FileDigestAlgorithm{FileDigestSHA256, FileDigestXXH64}, img.fileDigestAlgorithms)
+
+	require.Error(t, WithFileDigests("md5")(img))
+}
+
+func TestContentDigest(t *testing.T) {
+	v1Img, err := mutate.AppendLayers(empty.Image, tarLayer(t, map[string]string{"bin/tool": "payload"}))
+	require.NoError(t, err)
+
+	payload := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("payload")))
+
+	tests := []struct {
+		name     string
+		options  []AdditionalMetadata
+		wantRead bool
+	}{
+		{
+			name:     "recorded digest",
+			options:  []AdditionalMetadata{WithFileDigests()},
+			wantRead: false,
+		},
+		{
+			name:     "digest not recorded",
+			wantRead: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := New(v1Img, nil, t.TempDir(), tt.options...)
+			require.NoError(t, img.Read())
+
+			_, ref, err := img.SquashedTree().File("/bin/tool")
+			require.NoError(t, err)
+			require.NotNil(t, ref.Reference)
+
+			catalog := img.FileCatalog.(*FileCatalog)
+			opener := catalog.openerByID[ref.Reference.ID()]
+			var read bool
+			catalog.openerByID[ref.Reference.ID()] = func() io.ReadCloser {
+				read = true
+				return opener()
+			}
+
+			got, err := ContentDigest(catalog, *ref.Reference)
+			require.NoError(t, err)
+			assert.Equal(t, payload, got)
+			assert.Equal(t, tt.wantRead, read)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// fipsApprovedDigestAlgorithms are the FIPS 180-4 and FIPS 202 approved hash algorithms, named as they appear within
+// OCI and docker digests.
+var fipsApprovedDigestAlgorithms = map[string]struct{}{
+	"sha224":   {},
+	"sha256":   {},
+	"sha384":   {},
+	"sha512":   {},
+	"sha3-224": {},
+	"sha3-256": {},
+	"sha3-384": {},
+	"sha3-512": {},
+}
+
+// ErrUnapprovedDigestAlgorithm is returned when an image references content by a digest algorithm that is not FIPS
+// approved.
+type ErrUnapprovedDigestAlgorithm struct {
+	// Subject describes what the digest refers to (e.g. "manifest", "layer 2")
+	Subject string
+	Digest  string
+}
+
+func (e *ErrUnapprovedDigestAlgorithm) Error() string {
+	return fmt.Sprintf("%s digest %q uses a digest algorithm that is not FIPS approved", e.Subject, e.Digest)
+}
+
+// IsFIPSApprovedDigest indicates if the given digest (in "<algorithm>:<encoded>" form) uses a FIPS approved algorithm.
+func IsFIPSApprovedDigest(digest string) bool {
+	algorithm, _, found := strings.Cut(digest, ":")
+	if !found {
+		return false
+	}
+	return IsFIPSApprovedDigestAlgorithm(algorithm)
+}
+
+// IsFIPSApprovedDigestAlgorithm indicates if the given digest algorithm (e.g. "sha256") is FIPS approved.
+func IsFIPSApprovedDigestAlgorithm(algorithm string) bool {
+	_, ok := fipsApprovedDigestAlgorithms[strings.ToLower(algorithm)]
+	return ok
+}
+
+// WithFIPSMode restricts all digest algorithms to FIPS approved algorithms: before any layer is read, every digest the
+// image is described by (see VerifyFIPSDigests) and every algorithm files are digested with (see WithFileDigests) must
+// be FIPS approved.
+func WithFIPSMode() AdditionalMetadata {
+	return func(image *Image) error {
+		image.fips = true
+		return nil
+	}
+}
+
+// verifyFIPS ensures the file digest algorithms and the digests the image is described by are FIPS approved.
+func (i *Image) verifyFIPS() error {
+	for _, algorithm := range i.fileDigestAlgorithms {
+		if !IsFIPSApprovedDigestAlgorithm(string(algorithm)) {
+			return fmt.Errorf("file digest algorithm %q is not FIPS approved", algorithm)
+		}
+	}
+	return i.VerifyFIPSDigests()
+}
+
+// VerifyFIPSDigests ensures that every digest the image is described by (the config, manifest, repo digests, layer
+// blobs, and layer diff IDs) uses a FIPS approved algorithm.
+func (i *Image) VerifyFIPSDigests() error {
+	check := func(subject, digest string) error {
+		if digest == "" || IsFIPSApprovedDigest(digest) {
+			return nil
+		}
+		return &ErrUnapprovedDigestAlgorithm{Subject: subject, Digest: digest}
+	}
+	checkHash := func(subject string, h v1.Hash) error {
+		if h == (v1.Hash{}) {
+			return nil
+		}
+		return check(subject, h.String())
+	}
+
+	if err := check("config", i.Metadata.ID); err != nil {
+		return err
+	}
+
+	if err := check("manifest", i.Metadata.ManifestDigest); err != nil {
+		return err
+	}
+
+	for _, repoDigest := range i.Metadata.RepoDigests {
+		_, digest, _ := strings.Cut(repoDigest, "@")
+		if err := check("repo", digest); err != nil {
+			return err
+		}
+	}
+
+	for idx, diffID := range i.Metadata.Config.RootFS.DiffIDs {
+		if err := checkHash(fmt.Sprintf("layer %d diff ID", idx), diffID); err != nil {
+			return err
+		}
+	}
+
+	if len(i.Metadata.RawManifest) == 0 {
+		return nil
+	}
+
+	manifest, err := v1.ParseManifest(bytes.NewReader(i.Metadata.RawManifest))
+	if err != nil {
+		return fmt.Errorf("unable to parse image manifest: %w", err)
+	}
+
+	if err := checkHash("manifest config", manifest.Config.Digest); err != nil {
+		return err
+	}
+
+	for idx, layer := range manifest.Layers {
+		if err := checkHash(fmt.Sprintf("layer %d", idx), layer.Digest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package image
+
+import (
+	"encoding/json"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsFIPSApprovedDigest(t *testing.T) {
+	tests := []struct {
+		digest string
+		want   bool
+	}{
+		{digest: "sha256:abc", want: true},
+		{digest: "SHA512:abc", want: true},
+		{digest: "sha3-256:abc", want: true},
+		{digest: "md5:abc", want: false},
+		{digest: "sha1:abc", want: false},
+		{digest: "blake3:abc", want: false},
+		{digest: "abc", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.digest, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsFIPSApprovedDigest(tt.digest))
+		})
+	}
+}
+
+func TestImage_VerifyFIPSDigests(t *testing.T) {
+	sha := v1.Hash{Algorithm: "sha256", Hex: "8b5b9db0c13db24256c829aa364aa90c6d2eba318b9232a6ab9313b954d3555f"}
+	manifest, err := json.Marshal(v1.Manifest{
+		SchemaVersion: 2,
+		Config:        v1.Descriptor{Digest: sha},
+		Layers:        []v1.Descriptor{{Digest: sha}},
+	})
+	require.NoError(t, err)
+
+	approved := func() Metadata {
+		m := Metadata{
+			ID:             sha.String(),
+			ManifestDigest: sha.String(),
+			RepoDigests:    []string{"docker.io/library/alpine@" + sha.String()},
+			RawManifest:    manifest,
+		}
+		m.Config.RootFS.DiffIDs = []v1.Hash{sha}
+		return m
+	}
+
+	tests := []struct {
+		name        string
+		metadata    func() Metadata
+		wantSubject string
+	}{
+		{
+			name:     "all approved",
+			metadata: approved,
+		},
+		{
+			name: "no optional metadata",
+			metadata: func() Metadata {
+				return Metadata{ID: sha.String()}
+			},
+		},
+		{
+			name: "unapproved manifest digest",
+			metadata: func() Metadata {
+				m := approved()
+				m.ManifestDigest = "md5:d41d8cd98f00b204e9800998ecf8427e"
+				return m
+			},
+			wantSubject: "manifest",
+		},
+		{
+			name: "unapproved repo digest",
+			metadata: func() Metadata {
+				m := approved()
+				m.RepoDigests = []string{"docker.io/library/alpine@sha1:da39a3ee5e6b4b0d3255bfef95601890afd80709"}
+				return m
+			},
+			wantSubject: "repo",
+		},
+		{
+			name: "unapproved diff ID",
+			metadata: func() Metadata {
+				m := approved()
+				m.Config.RootFS.DiffIDs = []v1.Hash{{Algorithm: "blake3", Hex: "af1349b9"}}
+				return m
+			},
+			wantSubject: "layer 0 diff ID",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := &Image{Metadata: tt.metadata()}
+			err := img.VerifyFIPSDigests()
+			if tt.wantSubject == "" {
+				require.NoError(t, err)
+				return
+			}
+			var target *ErrUnapprovedDigestAlgorithm
+			require.ErrorAs(t, err, &target)
+			assert.Equal(t, tt.wantSubject, target.Subject)
+		})
+	}
+}
+
+func TestImage_Read_FIPSMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []AdditionalMetadata
+		wantErr string
+	}{
+		{
+			name:    "approved",
+			options: []AdditionalMetadata{WithFIPSMode(), WithFileDigests(FileDigestSHA256)},
+		},
+		{
+			name:    "unapproved repo digest",
+			options: []AdditionalMetadata{WithFIPSMode(), WithRepoDigests("docker.io/library/alpine@md5:d41d8cd98f00b204e9800998ecf8427e")},
+			wantErr: "not FIPS approved",
+		},
+		{
+			name:    "unapproved file digest algorithm",
+			options: []AdditionalMetadata{WithFIPSMode(), WithFileDigests(FileDigestXXH64)},
+			wantErr: "not FIPS approved",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layer := &countingLayer{Layer: tarLayer(t, map[string]string{"etc/os-release": "ID=test\n"})}
+			v1Img, err := mutate.AppendLayers(empty.Image, layer)
+			require.NoError(t, err)
+
+			img := New(v1Img, nil, t.TempDir(), tt.options...)
+			err = img.Read()
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				assert.NotZero(t, layer.reads)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+			// nothing is read before the digest algorithms are verified
+			assert.Zero(t, layer.reads)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package image
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/wagoodman/go-progress"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+)
+
+// FSLayer is a layer whose contents are a filesystem read in place (e.g. a root filesystem directory on disk), such that
+// the layer tree is built by walking the filesystem and file contents are opened on demand, without a layer tar being
+// written. Symlinks are only reported as such when the filesystem implements ReadLinkFS.
+type FSLayer interface {
+	v1.Layer
+	// FS returns the filesystem holding the layer contents (walked from ".")
+	FS() fs.FS
+}
+
+// ReadLinkFS is a filesystem that is able to read symlink targets (without following the symlink).
+type ReadLinkFS interface {
+	fs.FS
+	ReadLink(name string) (string, error)
+}
+
+// fsLayer returns the filesystem of the underlying layer, or nil when the layer is not an FSLayer.
+func (l *Layer) fsLayer() fs.FS {
+	if layer, ok := l.layer.(FSLayer); ok {
+		return layer.FS()
+	}
+	return nil
+}
+
+// indexFS adds all entries within the given filesystem to the layer tree and the file catalog (the same as
+// squashfsVisitor does for squashfs layers). Entries that cannot be read (e.g. due to permissions) or that cannot be
+// represented within a layer (sockets) are skipped.
+func (l *Layer) indexFS(ctx context.Context, fsys fs.FS, ft filetree.Writer, monitor *progress.Manual, auditor *pathAuditor) error {
+	builder := filetree.NewBuilder(ft, l.fileCatalog.Index)
+	limiter := l.newTreeLimiter()
+
+	return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == "." {
+				return err
+			}
+			log.WithFields("path", p, "error", err).Warn("unable to read layer filesystem entry, skipping")
+			return nil
+		}
+		if p == "." {
+			return nil
+		}
+
+		if err := context.Cause(ctx); err != nil {
+			return err
+		}
+
+		if l.pathFilter.excluded(p) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if admitted, err := limiter.admit(p); !admitted {
+			if err == nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return err
+		}
+
+		if admitted, err := auditor.admit(p); !admitted {
+			if err == nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return err
+		}
+
+		metadata, ok := fsEntryMetadata(fsys, p, d)
+		if !ok {
+			return nil
+		}
+		l.timestampPolicy.apply(&metadata)
+
+		ref, err := builder.Add(metadata)
+		if err != nil {
+			return err
+		}
+
+		l.Metadata.Size += metadata.Size()
+		l.fileCatalog.addImageReferences(ref.ID(), l, fsOpener(fsys, p, metadata.Type))
+
+		if monitor != nil {
+			monitor.Increment()
+		}
+		return nil
+	})
+}
+
+// fsEntryMetadata returns the metadata of the given filesystem entry (without following symlinks), or false when the
+// entry should be skipped.
+func fsEntryMetadata(fsys fs.FS, p string, d fs.DirEntry) (file.Metadata, bool) {
+	info, err := d.Info()
+	if err != nil {
+		log.WithFields("path", p, "error", err).Warn("unable to stat layer filesystem entry, skipping")
+		return file.Metadata{}, false
+	}
+
+	var link string
+	if info.Mode()&fs.ModeSymlink != 0 {
+		rl, ok := fsys.(ReadLinkFS)
+		if !ok {
+			log.WithFields("path", p).Trace("unable to read symlink from layer filesystem, skipping")
+			return file.Metadata{}, false
+		}
+		if link, err = rl.ReadLink(p); err != nil {
+			log.WithFields("path", p, "error", err).Warn("unable to read layer filesystem symlink, skipping")
+			return file.Metadata{}, false
+		}
+	}
+
+	// note: the tar header carries the owner and device details of the entry (when available from the platform)
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		log.WithFields("path", p, "error", err).Trace("unsupported layer filesystem entry, skipping")
+		return file.Metadata{}, false
+	}
+	header.Name = p
+
+	var content io.Reader
+	if info.Mode().IsRegular() {
+		f, err := fsys.Open(p)
+		if err != nil {
+			log.WithFields("path", p, "error", err).Warn("unable to open layer filesystem file, skipping")
+			return file.Metadata{}, false
+		}
+		defer f.Close()
+		content = f
+	}
+
+	return file.NewMetadata(*header, content), true
+}
+
+// fsOpener opens the contents of the given regular file from the filesystem on demand.
+func fsOpener(fsys fs.FS, p string, ty file.Type) file.Opener {
+	if ty != file.TypeRegular {
+		return nil
+	}
+	return func() io.ReadCloser {
+		f, err := fsys.Open(p)
+		if err != nil {
+			return errReadCloser{err: fmt.Errorf("unable to open %q from layer filesystem: %w", p, err)}
+		}
+		return f
+	}
+}
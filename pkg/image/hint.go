@@ -0,0 +1,84 @@
+package image
+
+// Hint IDs of common failures, which are stable such that UIs may render their own instructions.
+const (
+	// HintDockerPermission indicates the user is not allowed to access the docker daemon socket
+	HintDockerPermission = "docker-permission"
+	// HintDaemonUnavailable indicates the daemon is not running (or not reachable at the configured address)
+	HintDaemonUnavailable = "daemon-unavailable"
+	// HintContainerdNamespace indicates the image may exist within another containerd namespace
+	HintContainerdNamespace = "containerd-namespace"
+	// HintRegistryLogin indicates the registry requires (other) credentials
+	HintRegistryLogin = "registry-login"
+)
+
+// Hint is a machine-readable remediation for a failure, describing the next steps a user can take to resolve it.
+type Hint struct {
+	// ID identifies the remediation (see the Hint* constants)
+	ID string
+	// Message describes the remediation for the user
+	Message string
+}
+
+// HintedError is an error that carries a remediation hint (see Hints).
+type HintedError interface {
+	error
+	Hint() Hint
+}
+
+// WithHint returns the given error with the remediation hint attached (nil when the error is nil). The error string
+// is not changed, where the hint is available through Hints.
+func WithHint(err error, hint Hint) error {
+	if err == nil {
+		return nil
+	}
+	return &hintedError{err: err, hint: hint}
+}
+
+// Hints returns the remediation hints of all errors within the given error tree (e.g. the errors of each provider
+// attempted when detecting an image source), without duplicates.
+func Hints(err error) []Hint {
+	var hints []Hint
+	seen := make(map[Hint]bool)
+
+	var walk func(error)
+	walk = func(err error) {
+		if err == nil {
+			return
+		}
+		if hinted, ok := err.(HintedError); ok {
+			if hint := hinted.Hint(); !seen[hint] {
+				seen[hint] = true
+				hints = append(hints, hint)
+			}
+		}
+		switch e := err.(type) {
+		case interface{ Unwrap() error }:
+			walk(e.Unwrap())
+		case interface{ Unwrap() []error }:
+			for _, err := range e.Unwrap() {
+				walk(err)
+			}
+		}
+	}
+	walk(err)
+
+	return hints
+}
+
+type hintedError struct {
+	err  error
+	hint Hint
+}
+
+func (e *hintedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *hintedError) Unwrap() error {
+	return e.err
+}
+
+func (e *hintedError) Hint() Hint {
+	return e.hint
+}
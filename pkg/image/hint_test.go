@@ -0,0 +1,63 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithHint(t *testing.T) {
+	assert.NoError(t, WithHint(nil, Hint{ID: HintRegistryLogin}))
+
+	cause := errors.New("unauthorized")
+	hint := Hint{ID: HintRegistryLogin, Message: "log in"}
+	err := WithHint(cause, hint)
+
+	assert.EqualError(t, err, "unauthorized")
+	assert.ErrorIs(t, err, cause)
+
+	var hinted HintedError
+	assert.ErrorAs(t, fmt.Errorf("wrapped: %w", err), &hinted)
+	assert.Equal(t, hint, hinted.Hint())
+}
+
+func TestHints(t *testing.T) {
+	login := Hint{ID: HintRegistryLogin, Message: "log in"}
+	daemon := Hint{ID: HintDaemonUnavailable, Message: "start the daemon"}
+
+	tests := []struct {
+		name string
+		err  error
+		want []Hint
+	}{
+		{name: "nil"},
+		{name: "no hints", err: errors.New("failed")},
+		{
+			name: "wrapped",
+			err:  fmt.Errorf("unable to provide: %w", WithHint(errors.New("unauthorized"), login)),
+			want: []Hint{login},
+		},
+		{
+			name: "nested hints",
+			err:  WithHint(fmt.Errorf("outer: %w", WithHint(errors.New("inner"), login)), daemon),
+			want: []Hint{daemon, login},
+		},
+		{
+			name: "joined provider errors without duplicates",
+			err: fmt.Errorf("unable to detect input: %w", errors.Join(
+				WithHint(errors.New("docker"), daemon),
+				errors.New("podman"),
+				WithHint(errors.New("registry"), login),
+				WithHint(errors.New("other registry"), login),
+			)),
+			want: []Hint{daemon, login},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Hints(tt.err))
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package image
+
+import (
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// BuildTimes describes when an image was built, derived from the timestamps within the image config history.
+// Timestamps that are zeroed or set to a fixed epoch by reproducible builders (see Metadata.ReproducibleTimestamps) do
+// not reflect when the image was built, thus are ignored.
+type BuildTimes struct {
+	// Started is the earliest history timestamp (zero when no history entry has a meaningful timestamp)
+	Started time.Time
+	// Finished is the latest history timestamp or the image creation time, whichever is later (zero when neither is
+	// meaningful)
+	Finished time.Time
+	// Duration is the time between Started and Finished. Note: history includes the entries of all base images, thus
+	// this spans the builds of all base images as well as the image itself.
+	Duration time.Duration
+	// ReproducibleEntries is the number of history entries with zeroed or fixed epoch timestamps (which are ignored)
+	ReproducibleEntries int
+}
+
+// readBuildTimes derives the build times from the config history (nil when the config has no history).
+func readBuildTimes(config v1.ConfigFile) *BuildTimes {
+	if len(config.History) == 0 {
+		return nil
+	}
+
+	var times BuildTimes
+	observe := func(t time.Time) {
+		if times.Started.IsZero() || t.Before(times.Started) {
+			times.Started = t
+		}
+		if t.After(times.Finished) {
+			times.Finished = t
+		}
+	}
+
+	for _, h := range config.History {
+		created := historyTime(h.Created)
+		if created.IsZero() {
+			times.ReproducibleEntries++
+			continue
+		}
+		observe(created)
+	}
+
+	if created := historyTime(config.Created); !created.IsZero() && created.After(times.Finished) {
+		// the creation time is not a build step of its own, thus only extends the build
+		if times.Started.IsZero() {
+			times.Started = created
+		}
+		times.Finished = created
+	}
+
+	times.Duration = times.Finished.Sub(times.Started)
+	return &times
+}
+
+// HistoryEntry is an entry of the image config history, aligned with the filesystem layers of the image.
+type HistoryEntry struct {
+	v1.History
+	// Index is the position of the entry within the config history
+	Index int
+	// LayerIndex is the index of the layer created by the entry, -1 for metadata-only entries (empty layers, such as ENV
+	// or LABEL instructions) and for all entries when the history cannot be aligned with the layers
+	LayerIndex int
+}
+
+// CreatesLayer indicates the entry is aligned with a filesystem layer of the image.
+func (h HistoryEntry) CreatesLayer() bool {
+	return h.LayerIndex >= 0
+}
+
+// readHistory returns all entries of the config history aligned with the layers of the image (nil when the config has
+// no history). Entries that create a layer are those not marked as empty layers, unless that does not account for
+// every layer, in which case metadata-only instructions recorded by the legacy docker builder (which did not always mark
+// empty layers) are considered empty as well. History that still does not account for every layer cannot be reliably
+// aligned (e.g. images assembled by tools that do not record history), thus no entry is aligned with a layer.
+func readHistory(config v1.ConfigFile) []HistoryEntry {
+	if len(config.History) == 0 {
+		return nil
+	}
+
+	layers := len(config.RootFS.DiffIDs)
+	createsLayer := func(h v1.History) bool {
+		return !h.EmptyLayer
+	}
+	if countHistory(config.History, createsLayer) != layers {
+		createsLayer = func(h v1.History) bool {
+			return !h.EmptyLayer && !isLegacyMetadataInstruction(h.CreatedBy)
+		}
+	}
+	aligned := countHistory(config.History, createsLayer) == layers
+
+	entries := make([]HistoryEntry, len(config.History))
+	layerIdx := 0
+	for idx, h := range config.History {
+		entries[idx] = HistoryEntry{History: h, Index: idx, LayerIndex: -1}
+		if aligned && createsLayer(h) {
+			entries[idx].LayerIndex = layerIdx
+			layerIdx++
+		}
+	}
+	return entries
+}
+
+func countHistory(history []v1.History, fn func(v1.History) bool) int {
+	var count int
+	for _, h := range history {
+		if fn(h) {
+			count++
+		}
+	}
+	return count
+}
+
+// isLegacyMetadataInstruction indicates the given history command is a metadata-only instruction as recorded by the
+// legacy docker builder (e.g. "/bin/sh -c #(nop)  ENV PATH=/bin"), where only ADD and COPY instructions create layers.
+func isLegacyMetadataInstruction(createdBy string) bool {
+	_, instruction, found := strings.Cut(createdBy, "#(nop)")
+	if !found {
+		return false
+	}
+	fields := strings.Fields(instruction)
+	if len(fields) == 0 {
+		return true
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "ADD", "COPY":
+		return false
+	}
+	return true
+}
+
+// layerHistory returns the history entry that created the layer at the given index. Layers are matched by their
+// position within the aligned history (never by digest, since images may repeat the same layer, e.g. empty metadata
+// layers of ko and buildpacks images). False is returned when the history cannot be aligned with the layers (see
+// readHistory).
+func layerHistory(history []HistoryEntry, idx int) (HistoryEntry, bool) {
+	for _, h := range history {
+		if h.CreatesLayer() && h.LayerIndex == idx {
+			return h, true
+		}
+	}
+	return HistoryEntry{}, false
+}
+
+// historyTime returns the given timestamp in UTC, or zero when the timestamp is zeroed or a fixed epoch used by
+// reproducible builders.
+func historyTime(t v1.Time) time.Time {
+	if isReproducibleEpoch(t.Time) {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// isReproducibleEpoch indicates the given time is zero or one of the fixed epochs used by reproducible builders.
+func isReproducibleEpoch(t time.Time) bool {
+	if t.IsZero() {
+		return true
+	}
+	for _, epoch := range reproducibleEpochs {
+		if t.UTC().Equal(epoch) {
+			return true
+		}
+	}
+	return false
+}
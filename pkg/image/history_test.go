@@ -0,0 +1,234 @@
+package image
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_readBuildTimes(t *testing.T) {
+	base := time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) v1.Time {
+		return v1.Time{Time: base.Add(d)}
+	}
+	epoch := v1.Time{Time: time.Unix(0, 0)}
+
+	tests := []struct {
+		name   string
+		config v1.ConfigFile
+		want   *BuildTimes
+	}{
+		{
+			name:   "no history",
+			config: v1.ConfigFile{Created: at(0)},
+		},
+		{
+			name: "history timestamps",
+			config: v1.ConfigFile{
+				Created: at(10 * time.Minute),
+				History: []v1.History{
+					{Created: at(0)},
+					{Created: at(2 * time.Minute), EmptyLayer: true},
+					{Created: at(5 * time.Minute)},
+				},
+			},
+			want: &BuildTimes{
+				Started:  base,
+				Finished: base.Add(10 * time.Minute),
+				Duration: 10 * time.Minute,
+			},
+		},
+		{
+			name: "out of order timestamps",
+			config: v1.ConfigFile{
+				History: []v1.History{
+					{Created: at(5 * time.Minute)},
+					{Created: at(0)},
+				},
+			},
+			want: &BuildTimes{
+				Started:  base,
+				Finished: base.Add(5 * time.Minute),
+				Duration: 5 * time.Minute,
+			},
+		},
+		{
+			name: "reproducible timestamps are ignored",
+			config: v1.ConfigFile{
+				Created: epoch,
+				History: []v1.History{
+					{Created: at(0)},
+					{Created: at(time.Minute)},
+					{Created: epoch},
+					{},
+				},
+			},
+			want: &BuildTimes{
+				Started:             base,
+				Finished:            base.Add(time.Minute),
+				Duration:            time.Minute,
+				ReproducibleEntries: 2,
+			},
+		},
+		{
+			name: "only reproducible timestamps",
+			config: v1.ConfigFile{
+				Created: epoch,
+				History: []v1.History{
+					{Created: epoch},
+					{Created: v1.Time{Time: time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)}},
+				},
+			},
+			want: &BuildTimes{
+				ReproducibleEntries: 2,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, readBuildTimes(tt.config))
+		})
+	}
+}
+
+func Test_layerHistory(t *testing.T) {
+	created := time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)
+	history := []v1.History{
+		{Created: v1.Time{Time: created}, CreatedBy: "ADD rootfs.tar /"},
+		{CreatedBy: "ENV PATH=/bin", EmptyLayer: true},
+		{Created: v1.Time{Time: time.Unix(0, 0)}, CreatedBy: "COPY app /app"},
+	}
+
+	config := v1.ConfigFile{
+		History: history,
+		RootFS:  v1.RootFS{DiffIDs: []v1.Hash{{Algorithm: "sha256", Hex: "a"}, {Algorithm: "sha256", Hex: "b"}}},
+	}
+
+	got, ok := layerHistory(readHistory(config), 0)
+	assert.True(t, ok)
+	assert.Equal(t, "ADD rootfs.tar /", got.CreatedBy)
+	assert.Equal(t, created, historyTime(got.Created))
+
+	got, ok = layerHistory(readHistory(config), 1)
+	assert.True(t, ok)
+	assert.Equal(t, "COPY app /app", got.CreatedBy)
+	assert.True(t, historyTime(got.Created).IsZero())
+
+	_, ok = layerHistory(readHistory(config), 2)
+	assert.False(t, ok)
+
+	// history that does not describe every layer is not matched to layers
+	config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, v1.Hash{Algorithm: "sha256", Hex: "c"})
+	_, ok = layerHistory(readHistory(config), 0)
+	assert.False(t, ok)
+}
+
+func Test_readHistory(t *testing.T) {
+	diffIDs := func(n int) []v1.Hash {
+		var hashes []v1.Hash
+		for i := 0; i < n; i++ {
+			hashes = append(hashes, v1.Hash{Algorithm: "sha256", Hex: fmt.Sprintf("%d", i)})
+		}
+		return hashes
+	}
+
+	tests := []struct {
+		name    string
+		config  v1.ConfigFile
+		want    []int
+		wantNil bool
+	}{
+		{
+			name:    "no history",
+			config:  v1.ConfigFile{RootFS: v1.RootFS{DiffIDs: diffIDs(1)}},
+			wantNil: true,
+		},
+		{
+			name: "empty layers between filesystem layers",
+			config: v1.ConfigFile{
+				History: []v1.History{
+					{CreatedBy: "ARG VERSION", EmptyLayer: true},
+					{CreatedBy: "ADD rootfs.tar /"},
+					{CreatedBy: "ENV PATH=/bin", EmptyLayer: true},
+					{CreatedBy: "LABEL a=b", EmptyLayer: true},
+					{CreatedBy: "RUN make"},
+					{CreatedBy: "WORKDIR /app", EmptyLayer: true},
+					{CreatedBy: "COPY app /app"},
+					{CreatedBy: "CMD [\"/app\"]", EmptyLayer: true},
+				},
+				RootFS: v1.RootFS{DiffIDs: diffIDs(3)},
+			},
+			want: []int{-1, 0, -1, -1, 1, -1, 2, -1},
+		},
+		{
+			name: "legacy builder metadata instructions not marked as empty layers",
+			config: v1.ConfigFile{
+				History: []v1.History{
+					{CreatedBy: "/bin/sh -c #(nop) ADD file:abc in / "},
+					{CreatedBy: "/bin/sh -c #(nop)  ENV PATH=/bin"},
+					{CreatedBy: "/bin/sh -c apk add curl"},
+					{CreatedBy: "/bin/sh -c #(nop) COPY dir:def in /app "},
+					{CreatedBy: "/bin/sh -c #(nop)  CMD [\"/app\"]"},
+				},
+				RootFS: v1.RootFS{DiffIDs: diffIDs(3)},
+			},
+			want: []int{0, -1, 1, 2, -1},
+		},
+		{
+			name: "history that does not account for every layer",
+			config: v1.ConfigFile{
+				History: []v1.History{
+					{CreatedBy: "ADD rootfs.tar /"},
+					{CreatedBy: "ENV PATH=/bin", EmptyLayer: true},
+				},
+				RootFS: v1.RootFS{DiffIDs: diffIDs(2)},
+			},
+			want: []int{-1, -1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readHistory(tt.config)
+			if tt.wantNil {
+				assert.Nil(t, got)
+				return
+			}
+			require.Len(t, got, len(tt.want))
+			for idx, entry := range got {
+				assert.Equal(t, idx, entry.Index)
+				assert.Equal(t, tt.config.History[idx], entry.History)
+				assert.Equal(t, tt.want[idx], entry.LayerIndex, "entry %d (%s)", idx, entry.CreatedBy)
+				assert.Equal(t, tt.want[idx] >= 0, entry.CreatesLayer())
+			}
+		})
+	}
+}
+
+func Test_newLayerMetadata_history(t *testing.T) {
+	config := v1.ConfigFile{
+		History: []v1.History{
+			{CreatedBy: "ADD rootfs.tar /"},
+			{CreatedBy: "ENV PATH=/bin", EmptyLayer: true},
+			{CreatedBy: "USER app", EmptyLayer: true},
+			{CreatedBy: "COPY app /app"},
+		},
+		RootFS: v1.RootFS{DiffIDs: []v1.Hash{{Algorithm: "sha256", Hex: "a"}, {Algorithm: "sha256", Hex: "b"}}},
+	}
+	layer := tarLayer(t, map[string]string{"app": "payload"})
+
+	metadata, err := newLayerMetadata(Metadata{Config: config}, layer, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, metadata.HistoryIndex)
+	assert.Equal(t, "COPY app /app", metadata.CreatedBy)
+
+	config.History = config.History[:3]
+	metadata, err = newLayerMetadata(Metadata{Config: config}, layer, 1)
+	require.NoError(t, err)
+	assert.Equal(t, -1, metadata.HistoryIndex)
+	assert.Empty(t, metadata.CreatedBy)
+}
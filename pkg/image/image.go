@@ -1,7 +1,10 @@
 package image
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -15,6 +18,7 @@ import (
 	"github.com/wagoodman/go-progress"
 
 	"github.com/anchore/stereoscope/internal/bus"
+	"github.com/anchore/stereoscope/internal/inflight"
 	"github.com/anchore/stereoscope/internal/log"
 	"github.com/anchore/stereoscope/pkg/event"
 	"github.com/anchore/stereoscope/pkg/file"
@@ -38,12 +42,125 @@ type Image struct {
 	FileCatalog FileCatalogReader
 
 	SquashedSearchContext filetree.Searcher
+	// Partial indicates that not all layers could be read: either the read deadline expired before all layers could
+	// be read (only the layers that were fully read are present in Layers, see LayerMetadata.Completed), or one or
+	// more layers failed to be read in best-effort mode (see WithBestEffortLayers and FailedLayers).
+	Partial bool
 
 	overrideMetadata []AdditionalMetadata
+	// maxLayers is the maximum number of layers that may be read (no limit when zero)
+	maxLayers int
+	// bestEffortLayers allows for reading to continue past layers that fail to be read
+	bestEffortLayers bool
+	// squashChunkSize is the number of layers squashed together at a time for deep images (disabled when zero)
+	squashChunkSize int
+	// maxParallelism is the number of layers fetched and uncompressed at a time before indexing (serial when <= 1)
+	maxParallelism int
+	// pathFilter excludes entries from all layers while they are read (see WithPathExcludes)
+	pathFilter *pathFilter
+	// treeLimits bounds the shape of all layer trees while they are read (see WithTreeLimits)
+	treeLimits *TreeLimits
+	// pathPolicy controls how anomalous entry paths are handled while layers are read (see WithPathPolicy)
+	pathPolicy *PathPolicy
+	// timestampPolicy controls how implausible entry timestamps are handled while layers are read (see
+	// WithTimestampPolicy)
+	timestampPolicy *TimestampPolicy
+	// referrers resolves artifacts that reference this image (when supported by the image source)
+	referrers ReferrersResolver
+	// fileDigestAlgorithms are the algorithms regular files are digested with while layers are read (see
+	// WithFileDigests)
+	fileDigestAlgorithms []FileDigestAlgorithm
+	// fileDigests holds the digests of all regular files of the layers read (nil when files are not digested)
+	fileDigests *fileDigestIndex
+	// privilegedFiles are the setuid, setgid, and capability-bearing regular files of the layers read
+	privilegedFiles []PrivilegedFile
+	// fips restricts all digest algorithms to FIPS approved algorithms, verified before any layer is read (see
+	// WithFIPSMode)
+	fips bool
 }
 
 type AdditionalMetadata func(*Image) error
 
+// WithDaemonUsage sets the image size information reported by the daemon the image was provided from.
+func WithDaemonUsage(usage DaemonUsage) AdditionalMetadata {
+	return func(image *Image) error {
+		image.Metadata.DaemonUsage = &usage
+		return nil
+	}
+}
+
+// DeepImageLayerThreshold is the number of layers beyond which an image is considered "deep" (most storage drivers
+// historically limit images to 127 layers). Deep images may be squashed in chunks (see WithChunkedSquash).
+const DeepImageLayerThreshold = 127
+
+// ErrTooManyLayers is returned when an image has more layers than allowed (see WithMaxLayers).
+type ErrTooManyLayers struct {
+	Layers int
+	Max    int
+}
+
+func (e *ErrTooManyLayers) Error() string {
+	return fmt.Sprintf("image has too many layers: %d (max %d)", e.Layers, e.Max)
+}
+
+type readMetadataKey struct{}
+
+// ContextWithReadMetadata returns a context that applies the given metadata (e.g. WithMaxLayers) to every image read
+// with the context (see Image.ReadContext), regardless of the provider that creates the image. Metadata given directly
+// to New is applied afterwards and takes precedence.
+func ContextWithReadMetadata(ctx context.Context, metadata ...AdditionalMetadata) context.Context {
+	existing, _ := ctx.Value(readMetadataKey{}).([]AdditionalMetadata)
+	return context.WithValue(ctx, readMetadataKey{}, append(append([]AdditionalMetadata{}, existing...), metadata...))
+}
+
+// WithMaxLayers fails reading any image with more than the given number of layers (with ErrTooManyLayers). This is
+// checked before any layer contents are read.
+func WithMaxLayers(max int) AdditionalMetadata {
+	return func(image *Image) error {
+		if max < 0 {
+			return fmt.Errorf("max layers must not be negative: %d", max)
+		}
+		image.maxLayers = max
+		return nil
+	}
+}
+
+// WithBestEffortLayers allows for an image to be read even when some layers fail to be read (e.g. a truncated blob or
+// corrupt compression). Failed layers remain in Image.Layers with an empty tree and the read error recorded in the
+// layer metadata (thus none of the files from failed layers are present), and the image is marked as Partial.
+func WithBestEffortLayers() AdditionalMetadata {
+	return func(image *Image) error {
+		image.bestEffortLayers = true
+		return nil
+	}
+}
+
+// WithChunkedSquash bounds squash times for deep images (with more than DeepImageLayerThreshold layers) by squashing
+// the given number of layers at a time instead of one layer at a time. Note: the squash tree of each layer within a
+// chunk is the squash tree of the entire chunk (only the squash trees of the last layer in each chunk are exact).
+func WithChunkedSquash(chunkSize int) AdditionalMetadata {
+	return func(image *Image) error {
+		if chunkSize < 0 {
+			return fmt.Errorf("squash chunk size must not be negative: %d", chunkSize)
+		}
+		image.squashChunkSize = chunkSize
+		return nil
+	}
+}
+
+// WithMaxParallelism fetches and uncompresses up to the given number of layers at a time before the layers are indexed
+// (which remains serial, in layer order). This is most useful for images with lazily fetched layers (e.g. from the
+// registry provider). Zero or one fetches each layer as it is indexed.
+func WithMaxParallelism(n int) AdditionalMetadata {
+	return func(image *Image) error {
+		if n < 0 {
+			return fmt.Errorf("max parallelism must not be negative: %d", n)
+		}
+		image.maxParallelism = n
+		return nil
+	}
+}
+
 func WithTags(tags ...string) AdditionalMetadata {
 	return func(image *Image) error {
 		existingTags := strset.New()
@@ -80,6 +197,15 @@ func WithManifest(manifest []byte) AdditionalMetadata {
 	}
 }
 
+// WithIndex records the raw multi-platform index the image manifest was selected from.
+func WithIndex(index []byte) AdditionalMetadata {
+	return func(image *Image) error {
+		image.Metadata.RawIndex = index
+		image.Metadata.IndexDigest = fmt.Sprintf("sha256:%x", sha256.Sum256(index))
+		return nil
+	}
+}
+
 func WithManifestDigest(digest string) AdditionalMetadata {
 	return func(image *Image) error {
 		image.Metadata.ManifestDigest = digest
@@ -159,6 +285,12 @@ func New(image v1.Image, tmpDirGen *file.TempDirGenerator, contentCacheDir strin
 	return imgObj
 }
 
+// V1Image returns the image content as provided by the image source (nil for images constructed without content),
+// from which the manifest, config, and layer blobs of the image can be read as-is.
+func (i *Image) V1Image() v1.Image {
+	return i.image
+}
+
 func (i *Image) IDs() []string {
 	var ids = make([]string, len(i.Metadata.Tags))
 	for idx, t := range i.Metadata.Tags {
@@ -183,8 +315,10 @@ func (i *Image) trackReadProgress(metadata Metadata) *progress.Manual {
 	return prog
 }
 
-func (i *Image) applyOverrideMetadata() error {
-	for _, optionFn := range i.overrideMetadata {
+func (i *Image) applyOverrideMetadata(ctx context.Context) error {
+	contextMetadata, _ := ctx.Value(readMetadataKey{}).([]AdditionalMetadata)
+	metadata := append(append([]AdditionalMetadata{}, contextMetadata...), i.overrideMetadata...)
+	for _, optionFn := range metadata {
 		if err := optionFn(i); err != nil {
 			return fmt.Errorf("unable to override metadata option: %w", err)
 		}
@@ -195,18 +329,48 @@ func (i *Image) applyOverrideMetadata() error {
 // Read parses information from the underlying image tar into this struct. This includes image metadata, layer
 // metadata, layer file trees, and layer squash trees (which implies the image squash tree).
 func (i *Image) Read() error {
+	return i.ReadContext(context.Background())
+}
+
+// ReadContext is the same as Read, however, if the deadline of the given context expires while layers are being read
+// then the layer being read is discarded, the remaining layers are skipped, and the image is marked as Partial
+// (instead of failing the read entirely).
+// This allows for time-boxed callers to still make use of the image metadata and any layers read so far.
+//
+// Errors fetching or reading layers (e.g. transient network errors) are returned as a ReadError (wrapping the original
+// error), allowing for the read to be retried without acquiring the image again. Layer contents that were already
+// fetched are reused (see CachedLayers).
+func (i *Image) ReadContext(ctx context.Context) error {
+	return i.read(ctx)
+}
+
+func (i *Image) read(ctx context.Context) error {
 	var layers = make([]*Layer, 0)
 	var err error
+
+	// reset any state from a previous (failed) read
+	i.Partial = false
+	i.Layers = nil
 	i.Metadata, err = readImageMetadata(i.image)
 	if err != nil {
 		return err
 	}
 
 	// override any metadata with what the user has provided manually
-	if err = i.applyOverrideMetadata(); err != nil {
+	if err = i.applyOverrideMetadata(ctx); err != nil {
 		return err
 	}
 
+	if i.fips {
+		// fail before any layer is fetched, read, or digested
+		if err := i.verifyFIPS(); err != nil {
+			return err
+		}
+	}
+
+	i.fileDigests = newFileDigestIndex(i.fileDigestAlgorithms)
+	i.privilegedFiles = nil
+
 	log.Debugf("image metadata: digest=%+v mediaType=%+v tags=%+v",
 		i.Metadata.ID,
 		i.Metadata.MediaType,
@@ -214,21 +378,67 @@ func (i *Image) Read() error {
 
 	v1Layers, err := i.image.Layers()
 	if err != nil {
-		return err
+		return &ReadError{Image: i, Err: err}
+	}
+
+	if i.maxLayers > 0 && len(v1Layers) > i.maxLayers {
+		return &ErrTooManyLayers{Layers: len(v1Layers), Max: i.maxLayers}
+	}
+
+	// the manifest is only used to describe the layer blobs (see Layer.Descriptor)
+	manifest, err := i.image.Manifest()
+	if err != nil {
+		log.WithFields("error", err).Trace("unable to read image manifest, layer descriptors are unknown")
+		manifest = nil
 	}
 
 	// let consumers know of a monitorable event (image save + copy stages)
 	readProg := i.trackReadProgress(i.Metadata)
+	indexProgressFromContext(ctx).track(readProg)
+
+	op := inflight.FromContext(ctx)
+	op.SetStage(inflight.StageReading)
 
 	fileCatalog := NewFileCatalog()
+	fileCatalog.digests = i.fileDigests
+
+	blobCache := BlobCacheFromContext(ctx)
+	i.cacheConfig(blobCache)
+	i.recordCachedImage(ctx, blobCache)
+
+	i.fetchLayers(ctx, v1Layers, blobCache)
 
 	for idx, v1Layer := range v1Layers {
-		layer := NewLayer(v1Layer)
-		err := layer.Read(fileCatalog, i.Metadata, idx, i.contentCacheDir)
+		if deadlineExceeded(ctx) {
+			i.markPartial(idx, len(v1Layers))
+			break
+		}
+
+		layer := i.newLayer(v1Layer, blobCache)
+		layer.descriptor = manifestLayerDescriptor(manifest, idx, len(v1Layers))
+		err := layer.ReadContext(ctx, fileCatalog, i.Metadata, idx, i.contentCacheDir)
+		if err == nil {
+			err = i.fileDigests.addLayer(layer, fileCatalog)
+		}
+		if err == nil {
+			i.privilegedFiles = append(i.privilegedFiles, layerPrivilegedFiles(layer, fileCatalog)...)
+		}
 		if err != nil {
-			return err
+			if deadlineExceeded(ctx) {
+				// the layer was interrupted, nothing read from it is kept
+				fileCatalog.removeTree(layer.Tree)
+				i.markPartial(idx, len(v1Layers))
+				break
+			}
+			if !i.bestEffortLayers {
+				return &ReadError{Image: i, Err: err}
+			}
+			i.markLayerFailed(layer, fileCatalog, idx, err)
+		} else {
+			layer.Metadata.Completed = true
 		}
 		i.Metadata.Size += layer.Metadata.Size
+		op.AddBytes(layer.Metadata.Size)
 		layers = append(layers, layer)
 
 		readProg.Increment()
@@ -242,12 +452,116 @@ func (i *Image) Read() error {
 	i.FileCatalog = fileCatalog
 	i.SquashedSearchContext = filetree.NewSearchContext(i.SquashedTree(), i.FileCatalog)
 
+	if err == nil {
+		i.Metadata.Distro = IdentifyDistro(i.SquashedTree(), i.FileCatalog)
+	}
+
 	return err
 }
 
+// ReadError is returned when the layers of an image cannot be fetched or read. The image remains usable for retrying
+// the read with Image.ReadContext (without acquiring the image again from the provider).
+type ReadError struct {
+	Image *Image
+	Err   error
+}
+
+func (e *ReadError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// cacheConfig adds the image config to the given blob cache (if any), such that daemon providers can compose the image
+// entirely from cached blobs on subsequent invocations (see BlobCache.Image).
+func (i *Image) cacheConfig(cache *BlobCache) {
+	if cache == nil || cache.Has(i.Metadata.ID) {
+		return
+	}
+
+	rawConfig, err := i.image.RawConfigFile()
+	if err != nil {
+		log.WithFields("error", err).Debug("unable to read image config for blob cache")
+		return
+	}
+
+	if err := cache.Put(i.Metadata.ID, bytes.NewReader(rawConfig)); err != nil {
+		log.WithFields("error", err).Debug("unable to add image config to blob cache")
+	}
+}
+
+// CachedLayers returns the diff IDs of all layers whose uncompressed contents have already been fetched into the local
+// content cache. These layers are not fetched again when the image is read.
+func (i *Image) CachedLayers() ([]string, error) {
+	cfg, err := i.image.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	var cached []string
+	for _, diffID := range cfg.RootFS.DiffIDs {
+		if _, err := os.Stat(i.pathFilter.cachePath(i.contentCacheDir, diffID.String())); err == nil {
+			cached = append(cached, diffID.String())
+		}
+	}
+	return cached, nil
+}
+
+func (i *Image) markPartial(readLayers, totalLayers int) {
+	i.Partial = true
+	log.WithFields("image", i.Metadata.ID, "layers-read", readLayers, "layers-total", totalLayers).
+		Warn("read deadline exceeded, providing partial image")
+}
+
+// markLayerFailed discards anything read from the given layer (leaving an empty tree and no file catalog entries) and
+// records the read error.
+func (i *Image) markLayerFailed(layer *Layer, fileCatalog *FileCatalog, idx int, err error) {
+	i.Partial = true
+
+	fileCatalog.removeTree(layer.Tree)
+
+	tree := filetree.New()
+	layer.Tree = tree
+	layer.fileCatalog = fileCatalog
+	layer.SearchContext = filetree.NewSearchContext(tree, fileCatalog.Index)
+	layer.indexedContent = nil
+	layer.Metadata.Index = uint(idx)
+	layer.Metadata.Size = 0
+	layer.Metadata.TruncatedEntries = 0
+	layer.Metadata.TreeLimitViolations = nil
+	layer.Metadata.PathAnomalyCount = 0
+	layer.Metadata.PathAnomalies = nil
+	layer.Metadata.ReadError = err
+
+	log.WithFields("image", i.Metadata.ID, "layer", idx, "error", err).Warn("unable to read layer, continuing without its contents")
+}
+
+// FailedLayers returns all layers that could not be read (see WithBestEffortLayers).
+func (i *Image) FailedLayers() []*Layer {
+	var failed []*Layer
+	for _, l := range i.Layers {
+		if l.Metadata.ReadError != nil {
+			failed = append(failed, l)
+		}
+	}
+	return failed
+}
+
+func deadlineExceeded(ctx context.Context) bool {
+	// note: the cause is used since deadlines measured by a clock other than the system clock are causes of
+	// cancellation (see ContextWithTimeout)
+	return errors.Is(context.Cause(ctx), context.DeadlineExceeded)
+}
+
 // squash generates a squash tree for each layer in the image. For instance, layer 2 squash =
 // squash(layer 0, layer 1, layer 2), layer 3 squash = squash(layer 0, layer 1, layer 2, layer 3), and so on.
 func (i *Image) squash(prog *progress.Manual) error {
+	if i.squashChunkSize > 1 && len(i.Layers) > DeepImageLayerThreshold {
+		return i.squashChunked(prog)
+	}
+
 	var lastSquashTree filetree.ReadWriter
 
 	for idx, layer := range i.Layers {
@@ -279,6 +593,48 @@ func (i *Image) squash(prog *progress.Manual) error {
 	return nil
 }
 
+// squashChunked generates a squash tree for each chunk of layers in the image (shared by all layers in the chunk). For
+// instance, with a chunk size of 10, layers 0-9 squash = squash(layer 0, ..., layer 9), layers 10-19 squash =
+// squash(layer 0, ..., layer 19), and so on.
+func (i *Image) squashChunked(prog *progress.Manual) error {
+	log.WithFields("layers", len(i.Layers), "chunk-size", i.squashChunkSize).Debug("squashing deep image in chunks")
+
+	var lastSquashTree filetree.ReadWriter
+	for start := 0; start < len(i.Layers); start += i.squashChunkSize {
+		end := start + i.squashChunkSize
+		if end > len(i.Layers) {
+			end = len(i.Layers)
+		}
+		chunk := i.Layers[start:end]
+
+		var unionTree = filetree.NewUnionFileTree()
+		if lastSquashTree != nil {
+			unionTree.PushTree(lastSquashTree)
+		}
+		for _, layer := range chunk {
+			unionTree.PushTree(layer.Tree.(filetree.ReadWriter))
+		}
+
+		squashedTree, err := unionTree.Squash()
+		if err != nil {
+			return fmt.Errorf("failed to squash trees %d-%d: %w", start, end-1, err)
+		}
+
+		searchContext := filetree.NewSearchContext(squashedTree, chunk[len(chunk)-1].fileCatalog.Index)
+		for _, layer := range chunk {
+			layer.SquashedTree = squashedTree
+			layer.SquashedSearchContext = searchContext
+		}
+		lastSquashTree = squashedTree
+
+		prog.Add(int64(len(chunk)))
+	}
+
+	prog.SetCompleted()
+
+	return nil
+}
+
 // SquashedTree returns the pre-computed image squash file tree.
 func (i *Image) SquashedTree() filetree.Reader {
 	layerCount := len(i.Layers)
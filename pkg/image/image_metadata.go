@@ -23,6 +23,43 @@ type Metadata struct {
 	Architecture   string
 	Variant        string
 	OS             string
+	// RawIndex is the multi-platform index the image manifest was selected from (when known)
+	RawIndex []byte
+	// IndexDigest is the digest of RawIndex (when known)
+	IndexDigest string
+	// Buildpacks is populated for images built by Cloud Native Buildpacks
+	Buildpacks *BuildpacksMetadata
+	// Ko is populated for images built by ko
+	Ko *KoMetadata
+	// BuildTimes is derived from the config history (nil when the config has no history)
+	BuildTimes *BuildTimes
+	// History is the config history aligned with the layers of the image, including metadata-only entries (nil when
+	// the config has no history)
+	History []HistoryEntry
+	// Distro is the operating system distribution identified from the squashed tree (populated once the image is read,
+	// nil when it cannot be identified)
+	Distro *Distro
+	// DaemonUsage is populated for images provided from a daemon (docker, podman, or containerd)
+	DaemonUsage *DaemonUsage
+	// ProviderInput is the normalized input the image was provided from (populated when provided via the stereoscope
+	// client, see stereoscope.SaveState)
+	ProviderInput *ProviderInput
+}
+
+// DaemonUsage describes the size of an image as reported by the daemon it was provided from, matching what is shown
+// by "docker images" and "ctr image ls". Sizes that a daemon does not report are -1.
+type DaemonUsage struct {
+	// Size is the size of the image as reported by "docker images" (all layers) or "ctr image ls" (the manifest,
+	// config, and compressed layers of the image)
+	Size int64
+	// VirtualSize is the docker virtual size of the image (equivalent to Size in newer docker API versions)
+	VirtualSize int64
+	// SharedSize is the size of docker layers shared with other images
+	SharedSize int64
+	// ContentSize is the size of all content within the containerd content store for the image (all platforms)
+	ContentSize int64
+	// SnapshotSize is the size of all content and unpacked snapshots within containerd for the image
+	SnapshotSize int64
 }
 
 // readImageMetadata extracts the most pertinent information from the underlying image tar.
@@ -47,10 +84,20 @@ func readImageMetadata(img v1.Image) (Metadata, error) {
 		return Metadata{}, err
 	}
 
+	// note: the manifest is optional for the purposes of builder metadata (only annotations are used)
+	manifest, err := img.Manifest()
+	if err != nil {
+		manifest = nil
+	}
+
 	return Metadata{
-		ID:        id.String(),
-		Config:    *config,
-		MediaType: mediaType,
-		RawConfig: rawConfig,
+		ID:         id.String(),
+		Config:     *config,
+		MediaType:  mediaType,
+		RawConfig:  rawConfig,
+		Buildpacks: readBuildpacksMetadata(*config),
+		Ko:         readKoMetadata(*config, manifest),
+		BuildTimes: readBuildTimes(*config),
+		History:    readHistory(*config),
 	}, nil
 }
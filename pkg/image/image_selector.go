@@ -0,0 +1,20 @@
+package image
+
+import "context"
+
+type imageSelectorKey struct{}
+
+// ContextWithImageSelector returns a context where providers of layouts that may hold more than one image (OCI
+// directories and archives) select the image by the given reference name (the "org.opencontainers.image.ref.name"
+// annotation of the index entry) or manifest digest. A selector given with the path (e.g. "/path#latest") takes
+// precedence.
+func ContextWithImageSelector(ctx context.Context, selector string) context.Context {
+	return context.WithValue(ctx, imageSelectorKey{}, selector)
+}
+
+// ImageSelectorFromContext returns the image selector for multi-image layouts (see ContextWithImageSelector), which is
+// empty when none was given.
+func ImageSelectorFromContext(ctx context.Context) string {
+	selector, _ := ctx.Value(imageSelectorKey{}).(string)
+	return selector
+}
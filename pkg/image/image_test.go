@@ -1,14 +1,31 @@
 package image
 
 import (
+	"archive/tar"
+	"bytes"
+	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
 )
 
 func TestImageAdditionalMetadata(t *testing.T) {
@@ -100,7 +117,7 @@ func TestImageAdditionalMetadata(t *testing.T) {
 
 			img := New(nil, nil, tempFile.Name(), test.options...)
 
-			err = img.applyOverrideMetadata()
+			err = img.applyOverrideMetadata(context.Background())
 			if err != nil {
 				t.Fatalf("could not create image: %+v", err)
 			}
@@ -135,3 +152,294 @@ func TestImage_SquashedTree(t *testing.T) {
 		}
 	})
 }
+
+func TestImage_ReadContext_DeadlineExceeded(t *testing.T) {
+	img, err := random.Image(64, 3)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	out := New(img, nil, t.TempDir())
+	require.NoError(t, out.ReadContext(ctx))
+
+	assert.True(t, out.Partial)
+	assert.Empty(t, out.Layers)
+	assert.NotEmpty(t, out.Metadata.ID)
+	assert.NotNil(t, out.SquashedTree())
+}
+
+func TestImage_ReadContext(t *testing.T) {
+	img, err := random.Image(64, 3)
+	require.NoError(t, err)
+
+	out := New(img, nil, t.TempDir())
+	require.NoError(t, out.ReadContext(context.Background()))
+
+	assert.False(t, out.Partial)
+	require.Len(t, out.Layers, 3)
+	for _, l := range out.Layers {
+		assert.True(t, l.Metadata.Completed)
+	}
+}
+
+// expiringLayer is a layer that expires the read deadline while its uncompressed contents are read: after the first
+// read, or once all contents were read.
+type expiringLayer struct {
+	v1.Layer
+	expire  func()
+	atFirst bool
+}
+
+func (l expiringLayer) Uncompressed() (io.ReadCloser, error) {
+	rc, err := l.Layer.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: readerFunc(func(p []byte) (int, error) {
+			n, err := rc.Read(p)
+			if l.atFirst || err == io.EOF {
+				l.expire()
+			}
+			return n, err
+		}),
+		Closer: rc,
+	}, nil
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
+
+func TestImage_ReadContext_DeadlineExceededWithinLayer(t *testing.T) {
+	tests := []struct {
+		name    string
+		atFirst bool
+	}{
+		{
+			name:    "while fetching the layer",
+			atFirst: true,
+		},
+		{
+			name: "while indexing the layer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancelCause(context.Background())
+			defer cancel(nil)
+
+			interrupted := expiringLayer{
+				Layer:   tarLayer(t, map[string]string{"interrupted/a.txt": "a", "interrupted/b.txt": "b"}),
+				expire:  func() { cancel(context.DeadlineExceeded) },
+				atFirst: tt.atFirst,
+			}
+			img, err := mutate.AppendLayers(empty.Image,
+				tarLayer(t, map[string]string{"etc/os-release": "ID=test\n"}),
+				interrupted,
+				tarLayer(t, map[string]string{"skipped.txt": "skipped"}),
+			)
+			require.NoError(t, err)
+
+			out := New(img, nil, t.TempDir())
+			require.NoError(t, out.ReadContext(ctx))
+
+			assert.True(t, out.Partial)
+			require.Len(t, out.Layers, 1)
+			assert.True(t, out.Layers[0].Metadata.Completed)
+
+			// nothing read from the interrupted (or skipped) layers is present
+			for _, basename := range []string{"interrupted", "a.txt", "b.txt", "skipped.txt"} {
+				entries, err := out.FileCatalog.GetByBasename(basename)
+				require.NoError(t, err)
+				assert.Empty(t, entries, basename)
+			}
+			entries, err := out.FileCatalog.GetByBasename("os-release")
+			require.NoError(t, err)
+			assert.Len(t, entries, 1)
+		})
+	}
+}
+
+// corruptLayer is a layer whose uncompressed contents are truncated with an error.
+type corruptLayer struct {
+	v1.Layer
+}
+
+func (l corruptLayer) Uncompressed() (io.ReadCloser, error) {
+	return io.NopCloser(iotest.ErrReader(fmt.Errorf("unexpected EOF"))), nil
+}
+
+func TestImage_ReadContext_BestEffortLayers(t *testing.T) {
+	var layers []v1.Layer
+	for i := 0; i < 3; i++ {
+		l, err := random.Layer(64, types.DockerLayer)
+		require.NoError(t, err)
+		layers = append(layers, l)
+	}
+	layers[1] = corruptLayer{Layer: layers[1]}
+
+	img, err := mutate.AppendLayers(empty.Image, layers...)
+	require.NoError(t, err)
+
+	// without best-effort mode, the corrupt layer fails the entire read
+	out := New(img, nil, t.TempDir())
+	require.Error(t, out.ReadContext(context.Background()))
+
+	out = New(img, nil, t.TempDir(), WithBestEffortLayers())
+	require.NoError(t, out.ReadContext(context.Background()))
+
+	assert.True(t, out.Partial)
+	require.Len(t, out.Layers, 3)
+
+	failed := out.FailedLayers()
+	require.Len(t, failed, 1)
+	assert.Equal(t, uint(1), failed[0].Metadata.Index)
+	assert.ErrorContains(t, failed[0].Metadata.ReadError, "unexpected EOF")
+	assert.Equal(t, []file.Path{"/"}, failed[0].Tree.AllRealPaths())
+
+	// only files from the layers that were read are present
+	expected := map[file.Path]struct{}{}
+	for _, l := range []*Layer{out.Layers[0], out.Layers[2]} {
+		for _, p := range l.Tree.AllRealPaths() {
+			expected[p] = struct{}{}
+		}
+	}
+	actual := map[file.Path]struct{}{}
+	for _, p := range out.SquashedTree().AllRealPaths() {
+		actual[p] = struct{}{}
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestImage_ReadContext_BestEffortLayers_noCatalogEntries(t *testing.T) {
+	good := tarLayer(t, map[string]string{"etc/os-release": "ID=test\n"})
+
+	// a layer holding valid entries followed by a corrupt header, failing only after some entries were cataloged
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, name := range []string{"failed/a.txt", "failed/b.txt"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: 4}))
+		_, err := tw.Write([]byte("data"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Flush())
+	buf.Write(bytes.Repeat([]byte("x"), 512))
+	bad, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	})
+	require.NoError(t, err)
+
+	img, err := mutate.AppendLayers(empty.Image, good, bad)
+	require.NoError(t, err)
+
+	out := New(img, nil, t.TempDir(), WithBestEffortLayers())
+	require.NoError(t, out.ReadContext(context.Background()))
+
+	failed := out.FailedLayers()
+	require.Len(t, failed, 1)
+	require.Error(t, failed[0].Metadata.ReadError)
+
+	// nothing read from the failed layer remains within the file catalog
+	for _, basename := range []string{"failed", "a.txt", "b.txt"} {
+		entries, err := out.FileCatalog.GetByBasename(basename)
+		require.NoError(t, err)
+		assert.Empty(t, entries, basename)
+	}
+	assert.NotContains(t, out.FileCatalog.Basenames(), "a.txt")
+	entries, err := out.FileCatalog.GetByFileType(file.AllTypes()...)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotSame(t, failed[0], out.FileCatalog.Layer(entry.Reference), entry.RealPath)
+	}
+
+	entries, err = out.FileCatalog.GetByBasename("os-release")
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+// flakyLayer is a layer that fails to provide uncompressed contents for a given number of attempts.
+type flakyLayer struct {
+	v1.Layer
+	failures int
+	attempts *int
+}
+
+func (l flakyLayer) Uncompressed() (io.ReadCloser, error) {
+	*l.attempts++
+	if *l.attempts <= l.failures {
+		return nil, fmt.Errorf("transient error")
+	}
+	return l.Layer.Uncompressed()
+}
+
+func TestImage_ReadContext_Retry(t *testing.T) {
+	var stableAttempts, flakyAttempts int
+	stable, err := random.Layer(64, types.DockerLayer)
+	require.NoError(t, err)
+	flaky, err := random.Layer(64, types.DockerLayer)
+	require.NoError(t, err)
+
+	img, err := mutate.AppendLayers(empty.Image,
+		flakyLayer{Layer: stable, attempts: &stableAttempts},
+		flakyLayer{Layer: flaky, failures: 1, attempts: &flakyAttempts},
+	)
+	require.NoError(t, err)
+
+	out := New(img, nil, t.TempDir())
+	err = out.ReadContext(context.Background())
+
+	var readErr *ReadError
+	require.ErrorAs(t, err, &readErr)
+	assert.Same(t, out, readErr.Image)
+	assert.ErrorContains(t, err, "transient error")
+
+	stableDiffID, err := stable.DiffID()
+	require.NoError(t, err)
+	cached, err := out.CachedLayers()
+	require.NoError(t, err)
+	assert.Equal(t, []string{stableDiffID.String()}, cached)
+
+	// retrying does not fetch the already cached layer again
+	require.NoError(t, readErr.Image.ReadContext(context.Background()))
+	assert.Len(t, out.Layers, 2)
+	assert.Equal(t, 1, stableAttempts)
+	assert.Equal(t, 2, flakyAttempts)
+
+	cached, err = out.CachedLayers()
+	require.NoError(t, err)
+	assert.Len(t, cached, 2)
+}
+
+func TestImage_ReadContext_ErrorTypes(t *testing.T) {
+	img, err := random.Image(64, 2)
+	require.NoError(t, err)
+
+	// errors unrelated to fetching or reading layers are returned as-is
+	err = New(img, nil, t.TempDir(), WithMaxLayers(1)).ReadContext(context.Background())
+	var tooMany *ErrTooManyLayers
+	require.ErrorAs(t, err, &tooMany)
+	var readErr *ReadError
+	assert.False(t, errors.As(err, &readErr))
+
+	// layer errors are wrapped, keeping the original error reachable
+	var attempts int
+	flaky, err := mutate.AppendLayers(empty.Image, flakyLayer{Layer: mustLayer(t), failures: 1, attempts: &attempts})
+	require.NoError(t, err)
+	err = New(flaky, nil, t.TempDir()).ReadContext(context.Background())
+	require.ErrorAs(t, err, &readErr)
+	assert.Equal(t, readErr.Err.Error(), err.Error())
+}
+
+func mustLayer(t *testing.T) v1.Layer {
+	t.Helper()
+	l, err := random.Layer(64, types.DockerLayer)
+	require.NoError(t, err)
+	return l
+}
@@ -0,0 +1,136 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+// ErrNotIndexProvider is returned when multi-platform images are requested from a source that only provides single
+// images (e.g. a daemon).
+var ErrNotIndexProvider = errors.New("source does not provide multi-platform images")
+
+// IndexProvider is a Provider that is also able to provide all platform images of a multi-platform image (see Index).
+type IndexProvider interface {
+	Provider
+	ProvideIndex(context.Context) (*Index, error)
+}
+
+// ProvideIndex calls the given index provider, returning a ProviderPanicError (instead of panicking) when the provider
+// panics.
+func ProvideIndex(ctx context.Context, provider IndexProvider) (index *Index, err error) {
+	defer recoverProviderPanic(provider.Name(), &err)
+	return provider.ProvideIndex(ctx)
+}
+
+// Index is a multi-platform image (an OCI image index or docker manifest list), where the image for each platform is
+// only read when provided (see IndexEntry.Provide and ForEach). Attestation and other artifact manifests are not
+// included. A single-platform image is represented as an index with a single entry (and no digest or manifest).
+type Index struct {
+	// Digest is the digest of the index manifest
+	Digest string
+	// RawManifest is the index manifest as found at the source
+	RawManifest []byte
+	// Entries are the platform images within the index (in index order)
+	Entries []IndexEntry
+}
+
+// IndexEntry is a single platform image within an Index.
+type IndexEntry struct {
+	// Descriptor is the descriptor of the image manifest within the index
+	Descriptor v1.Descriptor
+	// Platform is the platform of the image (from the index entry, otherwise the image config)
+	Platform Platform
+	provider Provider
+}
+
+// NewIndexEntry creates an index entry for the image manifest with the given descriptor, which is read with the given
+// provider.
+func NewIndexEntry(descriptor v1.Descriptor, platform Platform, provider Provider) IndexEntry {
+	return IndexEntry{
+		Descriptor: descriptor,
+		Platform:   platform,
+		provider:   provider,
+	}
+}
+
+// Provide reads the image of the entry, where the caller is responsible for cleaning up the image (see Image.Cleanup).
+func (e IndexEntry) Provide(ctx context.Context) (*Image, error) {
+	if e.provider == nil {
+		return nil, fmt.Errorf("no provider for index entry %s (platform %s)", e.Descriptor.Digest, e.Platform.String())
+	}
+	return Provide(ctx, e.provider)
+}
+
+// Platforms returns the platforms of all images within the index (in index order).
+func (i *Index) Platforms() []Platform {
+	var platforms []Platform
+	for _, e := range i.Entries {
+		platforms = append(platforms, e.Platform)
+	}
+	return platforms
+}
+
+// Image reads the first image within the index that matches the given platform (see Platform.Matches), where the
+// caller is responsible for cleaning up the image.
+func (i *Index) Image(ctx context.Context, platform Platform) (*Image, error) {
+	var available []string
+	for _, e := range i.Entries {
+		if platform.Matches(e.Platform.OS, e.Platform.Architecture, e.Platform.Variant) {
+			return e.Provide(ctx)
+		}
+		available = append(available, e.Platform.String())
+	}
+	return nil, MarkError(fmt.Errorf("no image found in index for platform %q (available: %s)", platform.String(), strings.Join(available, ", ")), ErrPlatformMismatch)
+}
+
+// ForEach reads each image within the index in turn, calling the given function with it and cleaning it up
+// afterwards, such that only one platform image is held at a time. Iteration stops at the first error. The combined
+// progress of reading all images is published on the bus (see IndexProgress and event.ReadImageIndex).
+func (i *Index) ForEach(ctx context.Context, fn func(IndexEntry, *Image) error) (err error) {
+	prog := i.trackIndexProgress()
+	defer func() {
+		prog.finish(err)
+	}()
+	ctx = contextWithIndexProgress(ctx, prog)
+
+	for idx, e := range i.Entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		prog.start(idx, e.Platform)
+		img, err := e.Provide(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to provide image for platform %q: %w", e.Platform.String(), err)
+		}
+
+		err = fn(e, img)
+		if cleanupErr := img.Cleanup(); cleanupErr != nil {
+			log.WithFields("platform", e.Platform.String(), "error", cleanupErr).Warn("unable to cleanup index image")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PlatformFromV1 returns the given index entry or image config platform as a (normalized) Platform, which is empty
+// when not given.
+func PlatformFromV1(p *v1.Platform) Platform {
+	if p == nil {
+		return Platform{}
+	}
+	arch, variant := normalizeArch(p.Architecture, p.Variant)
+	return Platform{
+		OS:           normalizeOS(p.OS),
+		Architecture: arch,
+		Variant:      variant,
+	}
+}
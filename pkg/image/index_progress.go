@@ -0,0 +1,132 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/wagoodman/go-partybus"
+	"github.com/wagoodman/go-progress"
+
+	"github.com/anchore/stereoscope/internal/bus"
+	"github.com/anchore/stereoscope/pkg/event"
+)
+
+// indexProgressUnits is the share of the overall progress of each image within an index.
+const indexProgressUnits = 1000
+
+// IndexProgress is the combined progress of reading all images within an index (see Index.ForEach), such that a single
+// overall completion can be shown for all images. Each image accounts for an equal share of the overall progress, where
+// the share of the image being read reflects the read progress of its layers (see event.ReadImage). The stage describes
+// the image being read (e.g. "linux/arm64 (2 of 3)").
+type IndexProgress struct {
+	lock      sync.RWMutex
+	images    int
+	completed int
+	stage     string
+	current   progress.Progressable
+	err       error
+}
+
+var _ progress.StagedProgressable = (*IndexProgress)(nil)
+
+func newIndexProgress(images int) *IndexProgress {
+	return &IndexProgress{images: images}
+}
+
+// Stage describes the image currently being read.
+func (p *IndexProgress) Stage() string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.stage
+}
+
+// Current is the overall progress of all images read so far, including the progress of the image being read.
+func (p *IndexProgress) Current() int64 {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	current := int64(p.completed) * indexProgressUnits
+	if p.current != nil && p.completed < p.images {
+		switch size := p.current.Size(); {
+		case progress.IsCompleted(p.current):
+			// note: the read progress is completed without necessarily reaching the size
+			current += indexProgressUnits
+		case size > 0:
+			current += min(p.current.Current(), size) * indexProgressUnits / size
+		}
+	}
+	return current
+}
+
+// Size is the overall progress once all images are read.
+func (p *IndexProgress) Size() int64 {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return int64(p.images) * indexProgressUnits
+}
+
+// Error is the error that stopped reading the images, or progress.ErrCompleted once all images are read.
+func (p *IndexProgress) Error() error {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.err
+}
+
+// start records the image at the given index as the image being read.
+func (p *IndexProgress) start(idx int, platform Platform) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.completed = idx
+	p.current = nil
+	p.stage = fmt.Sprintf("%s (%d of %d)", platform.String(), idx+1, p.images)
+}
+
+// track records the read progress of the image being read (see Image.ReadContext).
+func (p *IndexProgress) track(prog progress.Progressable) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.current = prog
+}
+
+// finish records the end of reading all images, where a nil error completes the progress.
+func (p *IndexProgress) finish(err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.current = nil
+	if err != nil {
+		p.err = err
+		return
+	}
+	p.completed = p.images
+	p.err = progress.ErrCompleted
+}
+
+type indexProgressKey struct{}
+
+func contextWithIndexProgress(ctx context.Context, p *IndexProgress) context.Context {
+	return context.WithValue(ctx, indexProgressKey{}, p)
+}
+
+// indexProgressFromContext returns the progress of the index the image being read belongs to (nil when not read as
+// part of an index).
+func indexProgressFromContext(ctx context.Context) *IndexProgress {
+	p, _ := ctx.Value(indexProgressKey{}).(*IndexProgress)
+	return p
+}
+
+// trackIndexProgress publishes the combined progress of reading all images within the index on the bus.
+func (i *Index) trackIndexProgress() *IndexProgress {
+	prog := newIndexProgress(len(i.Entries))
+
+	bus.Publish(partybus.Event{
+		Type:   event.ReadImageIndex,
+		Source: i,
+		Value:  progress.StagedProgressable(prog),
+	})
+
+	return prog
+}
@@ -0,0 +1,155 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wagoodman/go-partybus"
+	"github.com/wagoodman/go-progress"
+
+	"github.com/anchore/stereoscope/internal/bus"
+	"github.com/anchore/stereoscope/pkg/event"
+)
+
+// stubProvider provides an empty image with the given ID, recording the number of images provided.
+type stubProvider struct {
+	id       string
+	provided int
+}
+
+func (p *stubProvider) Name() string {
+	return "stub"
+}
+
+func (p *stubProvider) Provide(context.Context) (*Image, error) {
+	p.provided++
+	return &Image{Metadata: Metadata{ID: p.id}}, nil
+}
+
+func TestIndex(t *testing.T) {
+	amd64 := &stubProvider{id: "amd64"}
+	arm64 := &stubProvider{id: "arm64"}
+
+	idx := &Index{
+		Entries: []IndexEntry{
+			NewIndexEntry(v1.Descriptor{}, Platform{OS: "linux", Architecture: "amd64"}, amd64),
+			NewIndexEntry(v1.Descriptor{}, Platform{OS: "linux", Architecture: "arm64"}, arm64),
+		},
+	}
+
+	assert.Equal(t, []Platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "arm64"}}, idx.Platforms())
+
+	img, err := idx.Image(context.Background(), Platform{OS: "linux", Architecture: "arm64"})
+	require.NoError(t, err)
+	assert.Equal(t, "arm64", img.Metadata.ID)
+
+	_, err = idx.Image(context.Background(), Platform{OS: "linux", Architecture: "s390x"})
+	require.ErrorContains(t, err, "available: linux/amd64, linux/arm64")
+
+	var ids []string
+	require.NoError(t, idx.ForEach(context.Background(), func(e IndexEntry, img *Image) error {
+		ids = append(ids, img.Metadata.ID)
+		return nil
+	}))
+	assert.Equal(t, []string{"amd64", "arm64"}, ids)
+
+	// iteration stops at the first error
+	stop := errors.New("stop")
+	err = idx.ForEach(context.Background(), func(IndexEntry, *Image) error {
+		return stop
+	})
+	require.ErrorIs(t, err, stop)
+	assert.Equal(t, 2, amd64.provided)
+	assert.Equal(t, 2, arm64.provided)
+}
+
+func TestPlatformFromV1(t *testing.T) {
+	assert.Equal(t, Platform{}, PlatformFromV1(nil))
+	assert.Equal(t, Platform{OS: "linux", Architecture: "arm64"}, PlatformFromV1(&v1.Platform{OS: "linux", Architecture: "aarch64"}))
+	assert.Equal(t, Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, PlatformFromV1(&v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}))
+}
+
+// readingProvider provides the given image, read with the provide context.
+type readingProvider struct {
+	img *Image
+}
+
+func (p *readingProvider) Name() string {
+	return "reading"
+}
+
+func (p *readingProvider) Provide(ctx context.Context) (*Image, error) {
+	return p.img, p.img.ReadContext(ctx)
+}
+
+// eventRecorder is a bus publisher that records all published events.
+type eventRecorder struct {
+	lock   sync.Mutex
+	events []partybus.Event
+}
+
+func (r *eventRecorder) Publish(e partybus.Event) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = append(r.events, e)
+}
+
+// indexProgress returns the progress of the last published event.ReadImageIndex event.
+func (r *eventRecorder) indexProgress(t *testing.T) *IndexProgress {
+	t.Helper()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for idx := len(r.events) - 1; idx >= 0; idx-- {
+		if r.events[idx].Type == event.ReadImageIndex {
+			prog, ok := r.events[idx].Value.(*IndexProgress)
+			require.True(t, ok)
+			return prog
+		}
+	}
+	t.Fatal("no index progress published")
+	return nil
+}
+
+func TestIndex_ForEach_progress(t *testing.T) {
+	recorder := &eventRecorder{}
+	bus.SetPublisher(recorder)
+	t.Cleanup(func() { bus.SetPublisher(&eventRecorder{}) })
+
+	newEntry := func(arch string) IndexEntry {
+		v1Img, err := mutate.AppendLayers(empty.Image, tarLayer(t, map[string]string{"etc/arch": arch}))
+		require.NoError(t, err)
+		return NewIndexEntry(v1.Descriptor{}, Platform{OS: "linux", Architecture: arch}, &readingProvider{img: New(v1Img, nil, t.TempDir())})
+	}
+
+	idx := &Index{Entries: []IndexEntry{newEntry("amd64"), newEntry("arm64"), newEntry("s390x")}}
+
+	var stages []string
+	var currents []int64
+	require.NoError(t, idx.ForEach(context.Background(), func(e IndexEntry, img *Image) error {
+		prog := recorder.indexProgress(t)
+		stages = append(stages, prog.Stage())
+		currents = append(currents, prog.Current())
+		return nil
+	}))
+
+	assert.Equal(t, []string{"linux/amd64 (1 of 3)", "linux/arm64 (2 of 3)", "linux/s390x (3 of 3)"}, stages)
+	// each read image completes its share of the overall progress
+	assert.Equal(t, []int64{indexProgressUnits, 2 * indexProgressUnits, 3 * indexProgressUnits}, currents)
+
+	prog := recorder.indexProgress(t)
+	assert.Equal(t, int64(3*indexProgressUnits), prog.Size())
+	assert.True(t, progress.IsCompleted(prog))
+
+	stop := errors.New("stop")
+	require.ErrorIs(t, idx.ForEach(context.Background(), func(IndexEntry, *Image) error { return stop }), stop)
+	prog = recorder.indexProgress(t)
+	assert.ErrorIs(t, prog.Error(), stop)
+	assert.Equal(t, int64(0), prog.Current())
+}
@@ -0,0 +1,88 @@
+package initramfs
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+const Archive image.Source = image.InitramfsSource
+
+// NewArchiveProvider creates a new provider for initramfs images: (possibly compressed and concatenated) "newc" cpio
+// archives, represented as a single layer image.
+func NewArchiveProvider(tmpDirGen *file.TempDirGenerator, path string) image.Provider {
+	return &archiveImageProvider{
+		tmpDirGen: tmpDirGen,
+		path:      path,
+	}
+}
+
+// archiveImageProvider is an image.Provider for initramfs images.
+type archiveImageProvider struct {
+	tmpDirGen *file.TempDirGenerator
+	path      string
+}
+
+func (p *archiveImageProvider) Name() string {
+	return Archive
+}
+
+// Provide an image object that represents the filesystem within the initramfs at the configured path.
+func (p *archiveImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	tempDir, err := p.tmpDirGen.NewDirectory("initramfs-archive")
+	if err != nil {
+		return nil, err
+	}
+
+	layerPath := filepath.Join(tempDir, "rootfs.tar")
+	if err := writeLayer(p.path, layerPath); err != nil {
+		return nil, err
+	}
+
+	img, err := image.NewSingleLayerImage(layerPath, v1.ConfigFile{OS: "linux"})
+	if err != nil {
+		return nil, err
+	}
+
+	contentTempDir, err := p.tmpDirGen.NewDirectory("initramfs-archive-image")
+	if err != nil {
+		return nil, err
+	}
+
+	out := image.New(img, p.tmpDirGen, contentTempDir, image.WithOS("linux"))
+	if err := out.ReadContext(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func writeLayer(archivePath, layerPath string) error {
+	fh, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("unable to open initramfs: %w", err)
+	}
+	defer fh.Close()
+
+	out, err := os.Create(layerPath)
+	if err != nil {
+		return fmt.Errorf("unable to create initramfs layer: %w", err)
+	}
+	defer out.Close()
+
+	tw := tar.NewWriter(out)
+	if err := newArchiveWriter(tw).writeStream(fh, 0); err != nil {
+		return fmt.Errorf("unable to read initramfs: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("unable to write initramfs layer: %w", err)
+	}
+	return nil
+}
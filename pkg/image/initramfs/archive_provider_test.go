@@ -0,0 +1,115 @@
+package initramfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+type cpioEntry struct {
+	name     string
+	mode     int64
+	ino      int64
+	nlink    int64
+	contents string
+}
+
+func TestArchiveProvider_Provide(t *testing.T) {
+	// an uncompressed early microcode archive followed by the compressed main archive
+	early := buildCPIO([]cpioEntry{
+		{name: "kernel", mode: modeDir | 0o755, ino: 1, nlink: 2},
+		{name: "kernel/x86/microcode/GenuineIntel.bin", mode: modeRegular | 0o644, ino: 2, nlink: 1, contents: "microcode"},
+	})
+
+	main := buildCPIO([]cpioEntry{
+		{name: ".", mode: modeDir | 0o755, ino: 1, nlink: 2},
+		{name: "bin", mode: modeDir | 0o755, ino: 2, nlink: 2},
+		// the data for a hardlinked inode is carried by the last entry
+		{name: "bin/sh", mode: modeRegular | 0o755, ino: 3, nlink: 2},
+		{name: "bin/busybox", mode: modeRegular | 0o755, ino: 3, nlink: 2, contents: "busybox"},
+		{name: "init", mode: modeSymlink | 0o777, ino: 4, nlink: 1, contents: "bin/busybox"},
+		{name: "etc/os-release", mode: modeRegular | 0o644, ino: 5, nlink: 1, contents: "ID=test\n"},
+	})
+
+	var compressed bytes.Buffer
+	gw := gzip.NewWriter(&compressed)
+	_, err := gw.Write(main)
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	// archives are padded to a 512 byte boundary when concatenated (e.g. by dracut)
+	contents := append(early, make([]byte, 512-len(early)%512)...)
+	contents = append(contents, compressed.Bytes()...)
+
+	path := filepath.Join(t.TempDir(), "initrd.img")
+	require.NoError(t, os.WriteFile(path, contents, 0o644))
+
+	tmpDirGen := file.NewTempDirGenerator("tempDir")
+	t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+	img, err := NewArchiveProvider(tmpDirGen, path).Provide(context.Background())
+	require.NoError(t, err)
+	require.Len(t, img.Layers, 1)
+	assert.Equal(t, "linux", img.Metadata.OS)
+
+	for p, expected := range map[string]string{
+		"/kernel/x86/microcode/GenuineIntel.bin": "microcode",
+		"/bin/busybox":                           "busybox",
+		"/bin/sh":                                "busybox",
+		"/init":                                  "busybox",
+		"/etc/os-release":                        "ID=test\n",
+	} {
+		reader, err := img.OpenPathFromSquash(file.Path(p))
+		require.NoError(t, err, p)
+		actual, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(actual), p)
+	}
+
+	_, ref, err := img.SquashedTree().File("/bin/sh")
+	require.NoError(t, err)
+	entry, err := img.FileCatalog.Get(*ref.Reference)
+	require.NoError(t, err)
+	assert.Equal(t, file.TypeHardLink, entry.Metadata.Type)
+	assert.Equal(t, "bin/busybox", entry.Metadata.LinkDestination)
+}
+
+func TestArchiveProvider_NotCPIO(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "initrd.img")
+	require.NoError(t, os.WriteFile(path, []byte("not a cpio archive"), 0o644))
+
+	tmpDirGen := file.NewTempDirGenerator("tempDir")
+	t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+	_, err := NewArchiveProvider(tmpDirGen, path).Provide(context.Background())
+	require.ErrorContains(t, err, "unsupported archive format")
+}
+
+func buildCPIO(entries []cpioEntry) []byte {
+	var buf bytes.Buffer
+	write := func(e cpioEntry) {
+		name := e.name + "\x00"
+		fmt.Fprintf(&buf, "%s%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x",
+			newcMagic, e.ino, e.mode, 0, 0, e.nlink, 1700000000, len(e.contents), 0, 0, 0, 0, len(name), 0)
+		buf.WriteString(name)
+		buf.Write(make([]byte, pad(int64(headerSize+len(name)))))
+		buf.WriteString(e.contents)
+		buf.Write(make([]byte, pad(int64(len(e.contents)))))
+	}
+
+	for _, e := range entries {
+		write(e)
+	}
+	write(cpioEntry{name: trailerName, nlink: 1})
+	return buf.Bytes()
+}
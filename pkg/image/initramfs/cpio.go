@@ -0,0 +1,354 @@
+package initramfs
+
+import (
+	"archive/tar"
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+const (
+	newcMagic    = "070701"
+	newcCRCMagic = "070702"
+	headerSize   = 110
+	trailerName  = "TRAILER!!!"
+
+	// maxNameSize protects against reading unbounded names from corrupt headers
+	maxNameSize = 4096
+
+	modeTypeMask = 0o170000
+	modeSocket   = 0o140000
+	modeSymlink  = 0o120000
+	modeRegular  = 0o100000
+	modeBlock    = 0o060000
+	modeDir      = 0o040000
+	modeChar     = 0o020000
+	modeFIFO     = 0o010000
+)
+
+// header is a single parsed "newc" cpio header.
+type header struct {
+	ino      int64
+	mode     int64
+	uid      int
+	gid      int
+	nlink    int64
+	mtime    int64
+	size     int64
+	devMajor int64
+	devMinor int64
+	rdevMaj  int64
+	rdevMin  int64
+	nameSize int64
+	name     string
+}
+
+// inode identifies a file across hardlinked entries.
+type inode struct {
+	devMajor, devMinor, ino int64
+}
+
+// archiveWriter converts (possibly concatenated and compressed) cpio archives to a single tar stream.
+type archiveWriter struct {
+	tw *tar.Writer
+	// linkTargets maps an inode to the path of the entry that carries its data
+	linkTargets map[inode]string
+	// pendingLinks are hardlinked paths seen before the entry that carries the data for the inode
+	pendingLinks map[inode][]header
+}
+
+func newArchiveWriter(tw *tar.Writer) *archiveWriter {
+	return &archiveWriter{
+		tw:           tw,
+		linkTargets:  make(map[inode]string),
+		pendingLinks: make(map[inode][]header),
+	}
+}
+
+// writeStream writes all cpio archives within the given stream. Multiple archives may be concatenated (separated by
+// zero padding), and the remainder of the stream may be compressed (e.g. an uncompressed early microcode archive
+// followed by the compressed main archive), as is supported by the kernel.
+func (w *archiveWriter) writeStream(reader io.Reader, depth int) error {
+	if depth > 4 {
+		return fmt.Errorf("too many nested compressed archives")
+	}
+
+	r := bufio.NewReader(reader)
+	var archives int
+	for {
+		if err := skipPadding(r); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+
+		magic, err := r.Peek(len(newcMagic))
+		if err != nil {
+			return fmt.Errorf("unable to read archive header: %w", err)
+		}
+
+		if string(magic) != newcMagic && string(magic) != newcCRCMagic {
+			compression, _, err := file.DetectCompression(r)
+			if err != nil {
+				return err
+			}
+			if compression == file.Uncompressed {
+				return fmt.Errorf("unsupported archive format (only newc cpio archives are supported)")
+			}
+
+			// the compressed archive is read to the end of the stream
+			log.WithFields("compression", compression).Trace("reading compressed initramfs archive")
+			decompressed, err := file.NewDecompressingReader(r)
+			if err != nil {
+				return err
+			}
+			defer decompressed.Close()
+			return w.writeStream(decompressed, depth+1)
+		}
+
+		if err := w.writeArchive(r); err != nil {
+			return err
+		}
+		archives++
+	}
+
+	if archives == 0 && depth == 0 {
+		return fmt.Errorf("no cpio archives found")
+	}
+	return nil
+}
+
+// skipPadding consumes zero bytes between concatenated archives, returning io.EOF when the stream is exhausted.
+func skipPadding(r *bufio.Reader) error {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != 0 {
+			return r.UnreadByte()
+		}
+	}
+}
+
+// writeArchive writes all entries of a single cpio archive (up to and including the trailer).
+func (w *archiveWriter) writeArchive(r *bufio.Reader) error {
+	for {
+		hdr, err := readHeader(r)
+		if err != nil {
+			return err
+		}
+
+		if hdr.name == trailerName {
+			// per archive: any hardlinks without data are regular (empty) files
+			return w.flushPendingLinks()
+		}
+
+		if err := w.writeEntry(r, hdr); err != nil {
+			return fmt.Errorf("unable to read %q: %w", hdr.name, err)
+		}
+	}
+}
+
+func readHeader(r io.Reader) (*header, error) {
+	raw := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, fmt.Errorf("unable to read cpio header: %w", err)
+	}
+
+	magic := string(raw[:6])
+	if magic != newcMagic && magic != newcCRCMagic {
+		return nil, fmt.Errorf("invalid cpio header magic: %q", magic)
+	}
+
+	var fields [13]int64
+	for i := range fields {
+		start := 6 + i*8
+		v, err := strconv.ParseInt(string(raw[start:start+8]), 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpio header field: %w", err)
+		}
+		fields[i] = v
+	}
+
+	hdr := &header{
+		ino:      fields[0],
+		mode:     fields[1],
+		uid:      int(fields[2]),
+		gid:      int(fields[3]),
+		nlink:    fields[4],
+		mtime:    fields[5],
+		size:     fields[6],
+		devMajor: fields[7],
+		devMinor: fields[8],
+		rdevMaj:  fields[9],
+		rdevMin:  fields[10],
+		nameSize: fields[11],
+	}
+
+	if hdr.nameSize < 1 || hdr.nameSize > maxNameSize {
+		return nil, fmt.Errorf("invalid cpio name size: %d", hdr.nameSize)
+	}
+
+	// the name (including NUL terminator) is padded so that the header plus name is a multiple of 4 bytes
+	name := make([]byte, hdr.nameSize+pad(headerSize+hdr.nameSize))
+	if _, err := io.ReadFull(r, name); err != nil {
+		return nil, fmt.Errorf("unable to read cpio entry name: %w", err)
+	}
+	hdr.name = strings.TrimRight(string(name[:hdr.nameSize]), "\x00")
+
+	return hdr, nil
+}
+
+func pad(n int64) int64 {
+	return (4 - n%4) % 4
+}
+
+func (h header) key() inode {
+	return inode{devMajor: h.devMajor, devMinor: h.devMinor, ino: h.ino}
+}
+
+func (h header) tarHeader(name string) tar.Header {
+	return tar.Header{
+		Name:     name,
+		Mode:     h.mode &^ modeTypeMask,
+		Uid:      h.uid,
+		Gid:      h.gid,
+		ModTime:  time.Unix(h.mtime, 0).UTC(),
+		Devmajor: h.rdevMaj,
+		Devminor: h.rdevMin,
+		Format:   tar.FormatPAX,
+	}
+}
+
+func cleanName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// writeEntry writes a single cpio entry (consuming its data and padding).
+func (w *archiveWriter) writeEntry(r io.Reader, h *header) error {
+	data := io.LimitReader(r, h.size)
+	defer func() {
+		// ensure all (unused) data and padding are consumed
+		_, _ = io.Copy(io.Discard, data)
+		_, _ = io.CopyN(io.Discard, r, pad(h.size))
+	}()
+
+	name := cleanName(h.name)
+	if name == "" {
+		// the root directory
+		return nil
+	}
+
+	hdr := h.tarHeader(name)
+
+	switch h.mode & modeTypeMask {
+	case modeDir:
+		hdr.Typeflag = tar.TypeDir
+		hdr.Name += "/"
+	case modeSymlink:
+		target, err := io.ReadAll(data)
+		if err != nil {
+			return err
+		}
+		hdr.Typeflag = tar.TypeSymlink
+		hdr.Linkname = strings.TrimRight(string(target), "\x00")
+	case modeChar:
+		hdr.Typeflag = tar.TypeChar
+	case modeBlock:
+		hdr.Typeflag = tar.TypeBlock
+	case modeFIFO:
+		hdr.Typeflag = tar.TypeFifo
+	case modeSocket:
+		log.WithFields("path", name).Trace("skipping socket within initramfs")
+		return nil
+	case modeRegular:
+		return w.writeRegular(h, hdr, data)
+	default:
+		return fmt.Errorf("unsupported file mode: %o", h.mode)
+	}
+
+	return w.tw.WriteHeader(&hdr)
+}
+
+// writeRegular writes a regular file entry, accounting for hardlinks. In newc archives all entries for a hardlinked
+// inode share the same inode number, however only one (typically the last) carries the file data.
+func (w *archiveWriter) writeRegular(h *header, hdr tar.Header, data io.Reader) error {
+	if h.nlink <= 1 {
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = h.size
+		if err := w.tw.WriteHeader(&hdr); err != nil {
+			return err
+		}
+		_, err := io.Copy(w.tw, data)
+		return err
+	}
+
+	key := h.key()
+	if target, ok := w.linkTargets[key]; ok {
+		hdr.Typeflag = tar.TypeLink
+		hdr.Linkname = target
+		return w.tw.WriteHeader(&hdr)
+	}
+
+	if h.size == 0 {
+		// the data may be carried by a later entry for the same inode
+		w.pendingLinks[key] = append(w.pendingLinks[key], *h)
+		return nil
+	}
+
+	hdr.Typeflag = tar.TypeReg
+	hdr.Size = h.size
+	if err := w.tw.WriteHeader(&hdr); err != nil {
+		return err
+	}
+	if _, err := io.Copy(w.tw, data); err != nil {
+		return err
+	}
+	w.linkTargets[key] = hdr.Name
+
+	for _, pending := range w.pendingLinks[key] {
+		link := pending.tarHeader(cleanName(pending.name))
+		link.Typeflag = tar.TypeLink
+		link.Linkname = hdr.Name
+		if err := w.tw.WriteHeader(&link); err != nil {
+			return err
+		}
+	}
+	delete(w.pendingLinks, key)
+	return nil
+}
+
+// flushPendingLinks writes hardlinked entries for which no data was found as empty regular files (the first) and
+// hardlinks to that file (the rest).
+func (w *archiveWriter) flushPendingLinks() error {
+	for key, pending := range w.pendingLinks {
+		var target string
+		for _, h := range pending {
+			hdr := h.tarHeader(cleanName(h.name))
+			if target == "" {
+				hdr.Typeflag = tar.TypeReg
+				target = hdr.Name
+			} else {
+				hdr.Typeflag = tar.TypeLink
+				hdr.Linkname = target
+			}
+			if err := w.tw.WriteHeader(&hdr); err != nil {
+				return err
+			}
+		}
+		delete(w.pendingLinks, key)
+	}
+
+	// inode numbers are only unique within a single archive
+	w.linkTargets = make(map[inode]string)
+	return nil
+}
@@ -0,0 +1,97 @@
+package iso
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+const Archive image.Source = image.ISOSource
+
+// NewArchiveProvider creates a new provider for ISO9660 images (e.g. installer or appliance ISOs), representing the
+// filesystem within the ISO as a single layer image. Rock Ridge extensions are used when present.
+func NewArchiveProvider(tmpDirGen *file.TempDirGenerator, path string) image.Provider {
+	return &archiveImageProvider{
+		tmpDirGen: tmpDirGen,
+		path:      path,
+	}
+}
+
+// archiveImageProvider is an image.Provider for ISO9660 images.
+type archiveImageProvider struct {
+	tmpDirGen *file.TempDirGenerator
+	path      string
+}
+
+func (p *archiveImageProvider) Name() string {
+	return Archive
+}
+
+// Provide an image object that represents the filesystem within the ISO at the configured path.
+func (p *archiveImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	fh, err := os.Open(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open ISO: %w", err)
+	}
+	defer fh.Close()
+
+	if !isISO9660(fh) {
+		return nil, fmt.Errorf("not an ISO9660 image: %q", p.path)
+	}
+
+	info, err := fh.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("unable to stat ISO: %w", err)
+	}
+
+	tempDir, err := p.tmpDirGen.NewDirectory("iso-archive")
+	if err != nil {
+		return nil, err
+	}
+
+	layerPath := filepath.Join(tempDir, "rootfs.tar")
+	if err := writeLayer(&reader{r: fh, size: info.Size()}, layerPath); err != nil {
+		return nil, err
+	}
+
+	img, err := image.NewSingleLayerImage(layerPath, v1.ConfigFile{})
+	if err != nil {
+		return nil, err
+	}
+
+	contentTempDir, err := p.tmpDirGen.NewDirectory("iso-archive-image")
+	if err != nil {
+		return nil, err
+	}
+
+	out := image.New(img, p.tmpDirGen, contentTempDir)
+	if err := out.ReadContext(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func writeLayer(r *reader, layerPath string) error {
+	out, err := os.Create(layerPath)
+	if err != nil {
+		return fmt.Errorf("unable to create ISO layer: %w", err)
+	}
+	defer out.Close()
+
+	tw := tar.NewWriter(out)
+	if err := r.writeTar(tw); err != nil {
+		return fmt.Errorf("unable to read ISO: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("unable to write ISO layer: %w", err)
+	}
+	return nil
+}
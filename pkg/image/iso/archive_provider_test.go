@@ -0,0 +1,309 @@
+package iso
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func TestArchiveProvider_Provide(t *testing.T) {
+	tests := []struct {
+		name      string
+		rockRidge bool
+		readme    string
+		osRelease string
+	}{
+		{
+			name:      "rock ridge",
+			rockRidge: true,
+			readme:    "/readme.txt",
+			osRelease: "/etc/os-release",
+		},
+		{
+			// without rock ridge names are the (lower-cased) ISO9660 identifiers
+			name:      "plain ISO9660",
+			readme:    "/readme.txt",
+			osRelease: "/etc/os_release",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.iso")
+			require.NoError(t, os.WriteFile(path, buildISO(test.rockRidge), 0o644))
+
+			tmpDirGen := file.NewTempDirGenerator("tempDir")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			img, err := NewArchiveProvider(tmpDirGen, path).Provide(context.Background())
+			require.NoError(t, err)
+			require.Len(t, img.Layers, 1)
+
+			reader, err := img.OpenPathFromSquash(file.Path(test.readme))
+			require.NoError(t, err)
+			contents, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, "hello\n", string(contents))
+
+			reader, err = img.OpenPathFromSquash(file.Path(test.osRelease))
+			require.NoError(t, err)
+			contents, err = io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, "ID=test\n", string(contents))
+
+			_, ref, err := img.SquashedTree().File(file.Path(test.readme))
+			require.NoError(t, err)
+			entry, err := img.FileCatalog.Get(*ref.Reference)
+			require.NoError(t, err)
+
+			if !test.rockRidge {
+				assert.Equal(t, os.FileMode(0o444), entry.Metadata.Mode().Perm())
+				assert.False(t, img.SquashedTree().HasPath("/etc/link"))
+				return
+			}
+
+			assert.Equal(t, os.FileMode(0o640), entry.Metadata.Mode().Perm())
+			assert.Equal(t, 1000, entry.Metadata.UserID)
+
+			_, ref, err = img.SquashedTree().File("/etc/link")
+			require.NoError(t, err)
+			entry, err = img.FileCatalog.Get(*ref.Reference)
+			require.NoError(t, err)
+			assert.Equal(t, file.TypeSymLink, entry.Metadata.Type)
+			assert.Equal(t, "../readme.txt", entry.Metadata.LinkDestination)
+		})
+	}
+}
+
+func TestArchiveProvider_NotISO(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.iso")
+	require.NoError(t, os.WriteFile(path, make([]byte, 20*sectorSize), 0o644))
+
+	tmpDirGen := file.NewTempDirGenerator("tempDir")
+	t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+	_, err := NewArchiveProvider(tmpDirGen, path).Provide(context.Background())
+	require.ErrorContains(t, err, "not an ISO9660 image")
+}
+
+func TestArchiveProvider_Malformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(img []byte)
+		wantErr string
+	}{
+		{
+			name: "root record name exceeds record length",
+			corrupt: func(img []byte) {
+				img[18*sectorSize+32] = 200
+			},
+			wantErr: "invalid ISO9660 directory record name length",
+		},
+		{
+			name: "root directory size exceeds image size",
+			corrupt: func(img []byte) {
+				binary.LittleEndian.PutUint32(img[16*sectorSize+156+10:], 0xfffffff0)
+			},
+			wantErr: "exceeds the image size",
+		},
+		{
+			name: "directory cycle",
+			corrupt: func(img []byte) {
+				// point the /etc record at the root directory extent
+				etc := 18*sectorSize + bytes.Index(img[18*sectorSize:19*sectorSize], []byte("ETC")) - 33
+				binary.LittleEndian.PutUint32(img[etc+2:], 18)
+			},
+			wantErr: "directory cycle found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img := buildISO(true)
+			test.corrupt(img)
+
+			path := filepath.Join(t.TempDir(), "test.iso")
+			require.NoError(t, os.WriteFile(path, img, 0o644))
+
+			tmpDirGen := file.NewTempDirGenerator("tempDir")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			_, err := NewArchiveProvider(tmpDirGen, path).Provide(context.Background())
+			require.ErrorContains(t, err, test.wantErr)
+		})
+	}
+}
+
+func TestReader_continuationAreaBounds(t *testing.T) {
+	img := buildISO(true)
+	r := &reader{r: bytes.NewReader(img), size: int64(len(img)), rockRidge: true}
+
+	tests := []struct {
+		name    string
+		area    []byte
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:    "within a block",
+			area:    ce(20, 0, 6),
+			wantErr: require.NoError,
+		},
+		{
+			name:    "larger than a block",
+			area:    ce(20, 0, 0xffffffff),
+			wantErr: require.Error,
+		},
+		{
+			name:    "beyond the image",
+			area:    ce(0xffffff, 0, 16),
+			wantErr: require.Error,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.wantErr(t, r.applyRockRidge(&directoryRecord{}, test.area, 0))
+		})
+	}
+}
+
+func TestReader_truncatedSymlinkEntry(t *testing.T) {
+	r := &reader{rockRidge: true}
+	record := &directoryRecord{}
+
+	require.NoError(t, r.applyRockRidge(record, []byte{'S', 'L', 4, 1}, 0))
+	assert.False(t, record.isSymlink())
+}
+
+// buildISO creates a minimal ISO9660 image with the layout:
+//
+//	/readme.txt
+//	/etc/os-release
+//	/etc/link -> ../readme.txt (rock ridge only)
+func buildISO(rockRidge bool) []byte {
+	const (
+		rootLBA   = 18
+		etcLBA    = 19
+		readmeLBA = 20
+		osRelLBA  = 21
+	)
+	readme := []byte("hello\n")
+	osRelease := []byte("ID=test\n")
+
+	img := make([]byte, 22*sectorSize)
+
+	var sp, readmeSU, etcSU, osRelSU, linkSU []byte
+	if rockRidge {
+		sp = []byte{'S', 'P', 7, 1, 0xbe, 0xef, 0}
+		readmeSU = append(px(0o100640, 1000, 1000), nm("readme.txt")...)
+		etcSU = append(px(0o040755, 0, 0), nm("etc")...)
+		osRelSU = append(px(0o100644, 0, 0), nm("os-release")...)
+		linkSU = append(append(px(0o120777, 0, 0), nm("link")...), sl("readme.txt")...)
+	}
+
+	root := concat(
+		record([]byte{0}, rootLBA, sectorSize, flagDirectory, sp),
+		record([]byte{1}, rootLBA, sectorSize, flagDirectory, nil),
+		record([]byte("ETC"), etcLBA, sectorSize, flagDirectory, etcSU),
+		record([]byte("README.TXT;1"), readmeLBA, len(readme), 0, readmeSU),
+	)
+
+	etcRecords := [][]byte{
+		record([]byte{0}, etcLBA, sectorSize, flagDirectory, nil),
+		record([]byte{1}, rootLBA, sectorSize, flagDirectory, nil),
+	}
+	if rockRidge {
+		etcRecords = append(etcRecords, record([]byte("LINK.;1"), 0, 0, 0, linkSU))
+	}
+	etcRecords = append(etcRecords, record([]byte("OS_RELEASE.;1"), osRelLBA, len(osRelease), 0, osRelSU))
+	etc := concat(etcRecords...)
+
+	pvd := img[16*sectorSize:]
+	pvd[0] = 1
+	copy(pvd[1:], standardIdentifier)
+	pvd[6] = 1
+	copy(pvd[156:], record([]byte{0}, rootLBA, sectorSize, flagDirectory, nil))
+
+	terminator := img[17*sectorSize:]
+	terminator[0] = 255
+	copy(terminator[1:], standardIdentifier)
+	terminator[6] = 1
+
+	copy(img[rootLBA*sectorSize:], root)
+	copy(img[etcLBA*sectorSize:], etc)
+	copy(img[readmeLBA*sectorSize:], readme)
+	copy(img[osRelLBA*sectorSize:], osRelease)
+	return img
+}
+
+func record(name []byte, extent, size int, flags byte, systemUse []byte) []byte {
+	length := systemUseOffset(len(name)) + len(systemUse)
+	length += length % 2
+	r := make([]byte, length)
+	r[0] = byte(length)
+	binary.LittleEndian.PutUint32(r[2:], uint32(extent))
+	binary.BigEndian.PutUint32(r[6:], uint32(extent))
+	binary.LittleEndian.PutUint32(r[10:], uint32(size))
+	binary.BigEndian.PutUint32(r[14:], uint32(size))
+	copy(r[18:], []byte{124, 1, 2, 3, 4, 5, 0})
+	r[25] = flags
+	r[28] = 1
+	r[32] = byte(len(name))
+	copy(r[33:], name)
+	copy(r[systemUseOffset(len(name)):], systemUse)
+	return r
+}
+
+func px(mode, uid, gid uint32) []byte {
+	e := make([]byte, 36)
+	copy(e, []byte{'P', 'X', 36, 1})
+	both := func(offset int, v uint32) {
+		binary.LittleEndian.PutUint32(e[offset:], v)
+		binary.BigEndian.PutUint32(e[offset+4:], v)
+	}
+	both(4, mode)
+	both(12, 1)
+	both(20, uid)
+	both(28, gid)
+	return e
+}
+
+func nm(name string) []byte {
+	return append([]byte{'N', 'M', byte(5 + len(name)), 1, 0}, name...)
+}
+
+// sl creates a symlink entry to "../<name>"
+func sl(name string) []byte {
+	components := append([]byte{0x4, 0}, append([]byte{0, byte(len(name))}, name...)...)
+	return append([]byte{'S', 'L', byte(5 + len(components)), 1, 0}, components...)
+}
+
+// ce creates a continuation area entry pointing at the given block, offset, and size
+func ce(block, offset, size uint32) []byte {
+	e := make([]byte, 28)
+	copy(e, []byte{'C', 'E', 28, 1})
+	both := func(at int, v uint32) {
+		binary.LittleEndian.PutUint32(e[at:], v)
+		binary.BigEndian.PutUint32(e[at+4:], v)
+	}
+	both(4, block)
+	both(12, offset)
+	both(20, size)
+	return e
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
@@ -0,0 +1,406 @@
+package iso
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	sectorSize           = 2048
+	volumeDescriptorsLBA = 16
+	maxDirectoryDepth    = 256
+
+	flagDirectory   = 0x2
+	flagMultiExtent = 0x80
+)
+
+var standardIdentifier = []byte("CD001")
+
+// directoryRecord is a single ISO9660 directory record, with Rock Ridge extensions applied when present.
+type directoryRecord struct {
+	name     string
+	extent   int64
+	size     int64
+	isDir    bool
+	modTime  time.Time
+	mode     int64
+	uid      int
+	gid      int
+	symlink  string
+	hasPOSIX bool
+}
+
+// isSymlink indicates that the record describes a (Rock Ridge) symlink.
+func (r directoryRecord) isSymlink() bool {
+	return r.symlink != ""
+}
+
+// reader reads the contents of an ISO9660 filesystem (with Rock Ridge extensions when present).
+type reader struct {
+	r io.ReaderAt
+	// size is the size of the image, bounding every extent read from it
+	size int64
+	// suspSkip is the number of bytes to skip at the start of each system use area (from the root "SP" entry)
+	suspSkip  int
+	rockRidge bool
+	// dirs are the extents of all directories visited so far (directories are never shared, thus a directory extent
+	// found again is a cycle within a corrupt or hostile image)
+	dirs map[int64]struct{}
+}
+
+func isISO9660(r io.ReaderAt) bool {
+	id := make([]byte, len(standardIdentifier))
+	if _, err := r.ReadAt(id, volumeDescriptorsLBA*sectorSize+1); err != nil {
+		return false
+	}
+	return bytes.Equal(id, standardIdentifier)
+}
+
+// rootRecord finds the root directory record within the primary volume descriptor.
+func (i *reader) rootRecord() (*directoryRecord, error) {
+	for lba := int64(volumeDescriptorsLBA); ; lba++ {
+		descriptor := make([]byte, sectorSize)
+		if _, err := i.r.ReadAt(descriptor, lba*sectorSize); err != nil {
+			return nil, fmt.Errorf("unable to read volume descriptor: %w", err)
+		}
+
+		if !bytes.Equal(descriptor[1:6], standardIdentifier) {
+			return nil, fmt.Errorf("invalid ISO9660 volume descriptor")
+		}
+
+		switch descriptor[0] {
+		case 1:
+			// primary volume descriptor
+			root, _ := i.parseRecord(descriptor[156:190])
+			if root == nil {
+				return nil, fmt.Errorf("invalid ISO9660 root directory record")
+			}
+			return root, i.detectRockRidge(root)
+		case 255:
+			return nil, fmt.Errorf("no primary volume descriptor found")
+		}
+	}
+}
+
+// detectRockRidge checks the "." entry of the root directory for the SUSP "SP" indicator.
+func (i *reader) detectRockRidge(root *directoryRecord) error {
+	sector := make([]byte, sectorSize)
+	if _, err := i.r.ReadAt(sector, root.extent*sectorSize); err != nil {
+		return fmt.Errorf("unable to read root directory: %w", err)
+	}
+
+	length := int(sector[0])
+	nameLength := int(sector[32])
+	if length == 0 || length > len(sector) || systemUseOffset(nameLength) > length {
+		return nil
+	}
+
+	systemUse := sector[systemUseOffset(nameLength):length]
+	if len(systemUse) >= 7 && string(systemUse[0:2]) == "SP" && systemUse[4] == 0xbe && systemUse[5] == 0xef {
+		i.rockRidge = true
+		i.suspSkip = int(systemUse[6])
+	}
+	return nil
+}
+
+func systemUseOffset(nameLength int) int {
+	offset := 33 + nameLength
+	if nameLength%2 == 0 {
+		// padding field
+		offset++
+	}
+	return offset
+}
+
+// parseRecord parses a single directory record, returning nil for records that should be skipped.
+func (i *reader) parseRecord(raw []byte) (*directoryRecord, error) {
+	length := int(raw[0])
+	if length < 34 || length > len(raw) {
+		return nil, nil
+	}
+
+	le := binary.LittleEndian
+	nameLength := int(raw[32])
+	if 33+nameLength > length {
+		return nil, fmt.Errorf("invalid ISO9660 directory record name length")
+	}
+
+	record := &directoryRecord{
+		extent:  int64(le.Uint32(raw[2:6])),
+		size:    int64(le.Uint32(raw[10:14])),
+		isDir:   raw[25]&flagDirectory != 0,
+		modTime: recordingTime(raw[18:25]),
+		name:    isoName(raw[33 : 33+nameLength]),
+		mode:    0o444,
+	}
+	if record.isDir {
+		record.mode = 0o555
+	}
+
+	if raw[25]&flagMultiExtent != 0 {
+		return nil, fmt.Errorf("multi-extent files are not supported (%q)", record.name)
+	}
+
+	if i.rockRidge {
+		if offset := systemUseOffset(nameLength) + i.suspSkip; offset < length {
+			if err := i.applyRockRidge(record, raw[offset:length], 0); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return record, nil
+}
+
+// isoName converts a raw ISO9660 file identifier to a file name (removing the version suffix and trailing dot).
+func isoName(raw []byte) string {
+	if len(raw) == 1 && (raw[0] == 0 || raw[0] == 1) {
+		// "." and ".." entries
+		return string([]rune{rune(raw[0])})
+	}
+	name := string(raw)
+	if idx := strings.LastIndex(name, ";"); idx >= 0 {
+		name = name[:idx]
+	}
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+func recordingTime(raw []byte) time.Time {
+	if raw[0] == 0 {
+		return time.Time{}
+	}
+	offset := time.Duration(int8(raw[6])) * 15 * time.Minute
+	t := time.Date(1900+int(raw[0]), time.Month(raw[1]), int(raw[2]), int(raw[3]), int(raw[4]), int(raw[5]), 0, time.UTC)
+	return t.Add(-offset)
+}
+
+// applyRockRidge applies the SUSP / Rock Ridge entries within the given system use area to the record.
+func (i *reader) applyRockRidge(record *directoryRecord, area []byte, depth int) error {
+	if depth > 8 {
+		return fmt.Errorf("too many Rock Ridge continuation areas")
+	}
+
+	le := binary.LittleEndian
+	var name strings.Builder
+	var hasName bool
+	var link []string
+	var pending string
+
+	for offset := 0; offset+4 <= len(area); {
+		signature := string(area[offset : offset+2])
+		length := int(area[offset+2])
+		if length < 4 || offset+length > len(area) {
+			break
+		}
+		entry := area[offset : offset+length]
+
+		switch signature {
+		case "PX":
+			if len(entry) >= 36 {
+				record.mode = int64(le.Uint32(entry[4:8]) & 0o7777)
+				record.uid = int(le.Uint32(entry[20:24]))
+				record.gid = int(le.Uint32(entry[28:32]))
+				record.hasPOSIX = true
+			}
+		case "NM":
+			if len(entry) >= 5 && entry[4]&0x6 == 0 {
+				name.Write(entry[5:])
+				hasName = true
+			}
+		case "SL":
+			if len(entry) >= 5 {
+				components, rest := parseSymlinkComponents(entry[5:], pending)
+				link = append(link, components...)
+				pending = rest
+			}
+		case "CE":
+			if len(entry) >= 28 {
+				block := int64(le.Uint32(entry[4:8]))
+				within := int64(le.Uint32(entry[12:16]))
+				size := int64(le.Uint32(entry[20:24]))
+				if within+size > sectorSize {
+					return fmt.Errorf("invalid Rock Ridge continuation area (offset %d, size %d)", within, size)
+				}
+				continuation, err := i.readExtent(block*sectorSize+within, size)
+				if err != nil {
+					return fmt.Errorf("unable to read Rock Ridge continuation area: %w", err)
+				}
+				if err := i.applyRockRidge(record, continuation, depth+1); err != nil {
+					return err
+				}
+			}
+		case "ST":
+			return nil
+		}
+		offset += length
+	}
+
+	if hasName {
+		record.name = name.String()
+	}
+
+	if len(link) > 0 {
+		target := strings.Join(link, "/")
+		if strings.HasPrefix(target, "//") {
+			target = target[1:]
+		}
+		record.symlink = target
+	}
+	return nil
+}
+
+// parseSymlinkComponents parses the component records of a Rock Ridge "SL" entry. Components that continue into the
+// next entry are returned as the pending remainder.
+func parseSymlinkComponents(raw []byte, pending string) ([]string, string) {
+	var components []string
+	for offset := 0; offset+2 <= len(raw); {
+		flags := raw[offset]
+		length := int(raw[offset+1])
+		if offset+2+length > len(raw) {
+			break
+		}
+		content := string(raw[offset+2 : offset+2+length])
+		offset += 2 + length
+
+		switch {
+		case flags&0x2 != 0:
+			content = "."
+		case flags&0x4 != 0:
+			content = ".."
+		case flags&0x8 != 0:
+			// root: results in a leading "/" once joined
+			content = ""
+		}
+
+		if flags&0x1 != 0 {
+			// continues in the next component
+			pending += content
+			continue
+		}
+		components = append(components, pending+content)
+		pending = ""
+	}
+	return components, pending
+}
+
+// readDir returns all records within the given directory (excluding "." and "..").
+func (i *reader) readDir(dir *directoryRecord) ([]*directoryRecord, error) {
+	data, err := i.readExtent(dir.extent*sectorSize, dir.size)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read directory: %w", err)
+	}
+
+	var records []*directoryRecord
+	for offset := 0; offset < len(data); {
+		length := int(data[offset])
+		if length == 0 {
+			// records do not span sectors, the remainder of the sector is padding
+			offset = (offset/sectorSize + 1) * sectorSize
+			continue
+		}
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("invalid ISO9660 directory record length")
+		}
+
+		record, err := i.parseRecord(data[offset : offset+length])
+		if err != nil {
+			return nil, err
+		}
+		offset += length
+
+		if record == nil || record.name == "\x00" || record.name == "\x01" {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// readExtent reads size bytes at the given offset, refusing extents that lie beyond the end of the image (before
+// allocating anything).
+func (i *reader) readExtent(offset, size int64) ([]byte, error) {
+	if offset < 0 || size < 0 || offset+size > i.size {
+		return nil, fmt.Errorf("extent at offset %d (size %d) exceeds the image size", offset, size)
+	}
+	data := make([]byte, size)
+	if _, err := i.r.ReadAt(data, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
+
+// writeTar writes all paths within the filesystem to the given tar writer.
+func (i *reader) writeTar(tw *tar.Writer) error {
+	root, err := i.rootRecord()
+	if err != nil {
+		return err
+	}
+	return i.writeDir(tw, root, "", 0)
+}
+
+func (i *reader) writeDir(tw *tar.Writer, dir *directoryRecord, dirPath string, depth int) error {
+	if depth > maxDirectoryDepth {
+		return fmt.Errorf("maximum directory depth exceeded at %q", dirPath)
+	}
+
+	if _, ok := i.dirs[dir.extent]; ok {
+		return fmt.Errorf("directory cycle found at %q", "/"+dirPath)
+	}
+	if i.dirs == nil {
+		i.dirs = make(map[int64]struct{})
+	}
+	i.dirs[dir.extent] = struct{}{}
+
+	records, err := i.readDir(dir)
+	if err != nil {
+		return fmt.Errorf("unable to read directory %q: %w", "/"+dirPath, err)
+	}
+
+	for _, record := range records {
+		name := path.Join(dirPath, record.name)
+		hdr := tar.Header{
+			Name:    name,
+			Mode:    record.mode,
+			Uid:     record.uid,
+			Gid:     record.gid,
+			ModTime: record.modTime,
+			Format:  tar.FormatPAX,
+		}
+
+		switch {
+		case record.isSymlink():
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = record.symlink
+			if err := tw.WriteHeader(&hdr); err != nil {
+				return err
+			}
+
+		case record.isDir:
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+			if err := tw.WriteHeader(&hdr); err != nil {
+				return err
+			}
+			if err := i.writeDir(tw, record, name, depth+1); err != nil {
+				return err
+			}
+
+		default:
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = record.size
+			if err := tw.WriteHeader(&hdr); err != nil {
+				return err
+			}
+			if _, err := io.Copy(tw, io.NewSectionReader(i.r, record.extent*sectorSize, record.size)); err != nil {
+				return fmt.Errorf("unable to read %q: %w", "/"+name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package kaniko
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/anchore/stereoscope/pkg/image/docker"
+)
+
+const Cache image.Source = image.KanikoCacheSource
+
+// cacheEntryPattern matches the names of image tarballs written by the kaniko warmer (named by manifest digest).
+var cacheEntryPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// NewCacheProvider creates a new provider for images within a kaniko cache directory (as populated by the kaniko
+// warmer via --cache-dir) or for tarballs written by kaniko (via --tar-path). A specific image within a cache directory
+// containing multiple images can be selected with a digest suffix (e.g. "/cache@sha256:...").
+func NewCacheProvider(tmpDirGen *file.TempDirGenerator, path string) image.Provider {
+	return &cacheImageProvider{
+		tmpDirGen: tmpDirGen,
+		path:      path,
+	}
+}
+
+// cacheImageProvider is an image.Provider for images written by kaniko to a cache volume.
+type cacheImageProvider struct {
+	tmpDirGen *file.TempDirGenerator
+	path      string
+}
+
+func (p *cacheImageProvider) Name() string {
+	return Cache
+}
+
+// Provide an image object that represents the kaniko cached image (or kaniko tarball output) at the configured path.
+func (p *cacheImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	dir, digest := splitDigest(p.path)
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to stat kaniko path %q: %w", dir, err)
+	}
+
+	tarPath := dir
+	if info.IsDir() {
+		tarPath, err = selectCacheEntry(dir, digest)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the kaniko warmer names cache entries by manifest digest and writes the raw manifest alongside the tarball
+	var metadata []image.AdditionalMetadata
+	if name := filepath.Base(tarPath); cacheEntryPattern.MatchString(name) {
+		metadata = append(metadata, image.WithManifestDigest(name))
+	}
+	if rawManifest, err := os.ReadFile(tarPath + ".json"); err == nil {
+		metadata = append(metadata, image.WithManifest(rawManifest))
+	}
+
+	log.WithFields("path", tarPath).Debug("providing image from kaniko cache")
+
+	// kaniko writes cache entries and tarball outputs in the docker archive format
+	return docker.NewArchiveProvider(p.tmpDirGen, tarPath, metadata...).Provide(ctx)
+}
+
+// splitDigest separates an optional trailing "@sha256:..." selector from the given path.
+func splitDigest(path string) (string, string) {
+	idx := strings.LastIndex(path, "@")
+	if idx < 0 {
+		return path, ""
+	}
+	if digest := path[idx+1:]; cacheEntryPattern.MatchString(digest) {
+		return path[:idx], digest
+	}
+	return path, ""
+}
+
+// selectCacheEntry finds the image tarball within the kaniko cache directory, optionally by digest.
+func selectCacheEntry(dir, digest string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("unable to read kaniko cache directory %q: %w", dir, err)
+	}
+
+	var candidates []string
+	for _, entry := range entries {
+		if entry.IsDir() || !cacheEntryPattern.MatchString(entry.Name()) {
+			continue
+		}
+		if digest != "" && entry.Name() != digest {
+			continue
+		}
+		candidates = append(candidates, entry.Name())
+	}
+
+	sort.Strings(candidates)
+
+	switch len(candidates) {
+	case 0:
+		if digest != "" {
+			return "", fmt.Errorf("no kaniko cached image found with digest %q in %q", digest, dir)
+		}
+		return "", fmt.Errorf("no kaniko cached images found in %q", dir)
+	case 1:
+		return filepath.Join(dir, candidates[0]), nil
+	default:
+		return "", fmt.Errorf("multiple kaniko cached images found in %q, please select one by digest (e.g. %s@%s): %s", dir, dir, candidates[0], strings.Join(candidates, ", "))
+	}
+}
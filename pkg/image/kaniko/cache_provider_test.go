@@ -0,0 +1,84 @@
+package kaniko
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func Test_splitDigest(t *testing.T) {
+	digest := "sha256:" + fakeHex
+	tests := []struct {
+		input      string
+		wantPath   string
+		wantDigest string
+	}{
+		{input: "/cache", wantPath: "/cache"},
+		{input: "/cache@" + digest, wantPath: "/cache", wantDigest: digest},
+		{input: "/cache@notadigest", wantPath: "/cache@notadigest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			gotPath, gotDigest := splitDigest(tt.input)
+			assert.Equal(t, tt.wantPath, gotPath)
+			assert.Equal(t, tt.wantDigest, gotDigest)
+		})
+	}
+}
+
+const fakeHex = "0000000000000000000000000000000000000000000000000000000000000000"
+
+func TestCacheProvider_Provide(t *testing.T) {
+	cacheDir := t.TempDir()
+	first := writeCacheEntry(t, cacheDir)
+
+	generator := file.NewTempDirGenerator("tempDir")
+	t.Cleanup(func() { _ = generator.Cleanup() })
+
+	img, err := NewCacheProvider(generator, cacheDir).Provide(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, first.String(), img.Metadata.ManifestDigest)
+	assert.Len(t, img.Layers, 2)
+
+	// once there are multiple cached images a digest selector is required
+	second := writeCacheEntry(t, cacheDir)
+
+	_, err = NewCacheProvider(generator, cacheDir).Provide(context.Background())
+	require.ErrorContains(t, err, "multiple kaniko cached images")
+
+	img, err = NewCacheProvider(generator, cacheDir+"@"+second.String()).Provide(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, second.String(), img.Metadata.ManifestDigest)
+}
+
+func writeCacheEntry(t *testing.T, dir string) v1.Hash {
+	t.Helper()
+
+	img, err := random.Image(64, 2)
+	require.NoError(t, err)
+
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	ref, err := name.NewTag("kaniko/cached:latest")
+	require.NoError(t, err)
+
+	cachePath := filepath.Join(dir, digest.String())
+	require.NoError(t, tarball.WriteToFile(cachePath, ref, img))
+
+	rawManifest, err := img.RawManifest()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(cachePath+".json", rawManifest, 0600))
+
+	return digest
+}
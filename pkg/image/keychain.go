@@ -0,0 +1,103 @@
+package image
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/configfile"
+	"github.com/docker/cli/cli/config/types"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/anchore/stereoscope/internal/environ"
+)
+
+// ResolveKeychain returns the keychain for registries without explicit credentials: the configured Keychain, otherwise
+// the docker config (and credential helpers) of the environment within the given context (see
+// ContextWithEnvOverrides), which is authn.DefaultKeychain for the process environment.
+func (r RegistryOptions) ResolveKeychain(ctx context.Context) authn.Keychain {
+	if r.Keychain != nil {
+		return r.Keychain
+	}
+	env := environ.FromContext(ctx)
+	if env == nil {
+		return authn.DefaultKeychain
+	}
+	return &envKeychain{env: env}
+}
+
+// envKeychain is authn.DefaultKeychain within the given environment instead of the process environment: the docker
+// config within DOCKER_CONFIG (or the home directory) is used, otherwise the podman auth file within the XDG runtime
+// directory. Note: credential helpers are run with the process environment.
+type envKeychain struct {
+	env  *environ.Overrides
+	lock sync.Mutex
+}
+
+func (k *envKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	cf, err := k.configFile()
+	if err != nil || cf == nil {
+		return authn.Anonymous, err
+	}
+
+	// note: the same keys are tried as with authn.DefaultKeychain (see https://github.com/google/ko/issues/90)
+	var cfg, empty types.AuthConfig
+	for _, key := range []string{target.String(), target.RegistryStr()} {
+		if key == name.DefaultRegistry {
+			key = authn.DefaultAuthKey
+		}
+
+		if cfg, err = cf.GetAuthConfig(key); err != nil {
+			return nil, err
+		}
+		cfg.ServerAddress = ""
+		if cfg != empty {
+			break
+		}
+	}
+	if cfg == empty {
+		return authn.Anonymous, nil
+	}
+
+	return authn.FromConfig(authn.AuthConfig{
+		Username:      cfg.Username,
+		Password:      cfg.Password,
+		Auth:          cfg.Auth,
+		IdentityToken: cfg.IdentityToken,
+		RegistryToken: cfg.RegistryToken,
+	}), nil
+}
+
+// configFile loads the docker config of the environment, falling back to the podman auth file (nil when neither
+// exists).
+func (k *envKeychain) configFile() (*configfile.ConfigFile, error) {
+	dir := k.env.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		if home := k.env.Home(); home != "" {
+			dir = filepath.Join(home, ".docker")
+		}
+	}
+	if dir != "" {
+		if _, err := os.Stat(filepath.Join(dir, config.ConfigFileName)); err == nil {
+			return config.Load(dir)
+		}
+	}
+
+	runtimeDir := k.env.XDGRuntimeDir()
+	if runtimeDir == "" {
+		return nil, nil
+	}
+	f, err := os.Open(filepath.Join(runtimeDir, "containers", "auth.json"))
+	if err != nil {
+		// neither a docker config nor a podman auth file exists (anonymous access)
+		return nil, nil
+	}
+	defer f.Close()
+	return config.LoadFromReader(f)
+}
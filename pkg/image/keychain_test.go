@@ -0,0 +1,90 @@
+package image
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryOptions_ResolveKeychain(t *testing.T) {
+	// the process environment holds other credentials, which must never be used with overrides
+	t.Setenv("DOCKER_CONFIG", writeDockerConfig(t, t.TempDir(), "process"))
+
+	home := t.TempDir()
+	writeDockerConfig(t, filepath.Join(home, ".docker"), "home")
+	runtimeDir := t.TempDir()
+	writeDockerConfig(t, filepath.Join(runtimeDir, "containers"), "podman")
+	require.NoError(t, os.Rename(filepath.Join(runtimeDir, "containers", "config.json"), filepath.Join(runtimeDir, "containers", "auth.json")))
+
+	tests := []struct {
+		name     string
+		options  RegistryOptions
+		env      *EnvOverrides
+		wantUser string
+	}{
+		{
+			name:     "process environment",
+			wantUser: "process",
+		},
+		{
+			name:     "DOCKER_CONFIG override",
+			env:      &EnvOverrides{Hermetic: true, Vars: map[string]string{"DOCKER_CONFIG": writeDockerConfig(t, t.TempDir(), "override")}},
+			wantUser: "override",
+		},
+		{
+			name:     "home directory override",
+			env:      &EnvOverrides{Hermetic: true, HomeDir: home},
+			wantUser: "home",
+		},
+		{
+			name:     "podman auth file",
+			env:      &EnvOverrides{Hermetic: true, HomeDir: t.TempDir(), RuntimeDir: runtimeDir},
+			wantUser: "podman",
+		},
+		{
+			name: "no config",
+			env:  &EnvOverrides{Hermetic: true},
+		},
+		{
+			name:    "explicit keychain",
+			options: RegistryOptions{Keychain: authn.NewMultiKeychain()},
+			env:     &EnvOverrides{Hermetic: true, HomeDir: home},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.env != nil {
+				ctx = ContextWithEnvOverrides(ctx, tt.env)
+			}
+
+			repo, err := name.NewRepository("registry.example.com/app")
+			require.NoError(t, err)
+
+			auth, err := tt.options.ResolveKeychain(ctx).Resolve(repo)
+			require.NoError(t, err)
+			cfg, err := auth.Authorization()
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantUser, cfg.Username)
+		})
+	}
+}
+
+// writeDockerConfig writes a docker config within the given directory holding credentials for registry.example.com
+// with the given username, returning the directory.
+func writeDockerConfig(t *testing.T, dir, user string) string {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	auth := base64.StdEncoding.EncodeToString([]byte(user + ":password"))
+	contents := fmt.Sprintf(`{"auths":{"registry.example.com":{"auth":%q}}}`, auth)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(contents), 0o600))
+	return dir
+}
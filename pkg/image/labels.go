@@ -0,0 +1,196 @@
+package image
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// setRequirementPattern matches set-based selector requirements (e.g. "tier in (frontend, backend)").
+var setRequirementPattern = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+
+// Labels returns the labels of the image config (never nil).
+func (m Metadata) Labels() map[string]string {
+	labels := make(map[string]string, len(m.Config.Config.Labels))
+	for k, v := range m.Config.Config.Labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// GetLabel returns the value of the given label within the image config and whether the label is set.
+func (m Metadata) GetLabel(key string) (string, bool) {
+	v, ok := m.Config.Config.Labels[key]
+	return v, ok
+}
+
+// MatchLabels indicates the image config labels match the given Kubernetes-style label selector (see
+// ParseLabelSelector).
+func (m Metadata) MatchLabels(selector string) (bool, error) {
+	s, err := ParseLabelSelector(selector)
+	if err != nil {
+		return false, err
+	}
+	return s.Matches(m.Config.Config.Labels), nil
+}
+
+// Env returns the environment variables of the image config by name (never nil). Variables defined more than once
+// have the last value defined, as with container runtimes.
+func (m Metadata) Env() map[string]string {
+	env := make(map[string]string, len(m.Config.Config.Env))
+	for _, kv := range m.Config.Config.Env {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+// GetEnv returns the value of the given environment variable within the image config and whether the variable is set.
+func (m Metadata) GetEnv(name string) (string, bool) {
+	return lookupConfigEnv(m.Config.Config.Env, name)
+}
+
+// MatchEnv indicates the image config environment variables match the given Kubernetes-style selector (see
+// ParseLabelSelector), where variable names are matched as label keys (e.g. "JAVA_VERSION in (17, 21)").
+func (m Metadata) MatchEnv(selector string) (bool, error) {
+	s, err := ParseLabelSelector(selector)
+	if err != nil {
+		return false, err
+	}
+	return s.Matches(m.Env()), nil
+}
+
+// LabelSelector is a parsed Kubernetes-style label selector, where all requirements must match.
+type LabelSelector struct {
+	requirements []labelRequirement
+}
+
+type labelOperator string
+
+const (
+	labelExists       labelOperator = "exists"
+	labelDoesNotExist labelOperator = "!"
+	labelEquals       labelOperator = "="
+	labelNotEquals    labelOperator = "!="
+	labelIn           labelOperator = "in"
+	labelNotIn        labelOperator = "notin"
+)
+
+type labelRequirement struct {
+	key      string
+	operator labelOperator
+	values   []string
+}
+
+// ParseLabelSelector parses a Kubernetes-style label selector: a comma-separated list of requirements, each being one
+// of "key", "!key", "key=value", "key==value", "key!=value", "key in (v1,v2)", or "key notin (v1,v2)". As with
+// Kubernetes, "!=" and "notin" requirements match when the key is not set. An empty selector matches everything.
+func ParseLabelSelector(selector string) (LabelSelector, error) {
+	var s LabelSelector
+	for _, raw := range splitSelector(selector) {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			if strings.TrimSpace(selector) == "" {
+				continue
+			}
+			return LabelSelector{}, fmt.Errorf("invalid label selector %q: empty requirement", selector)
+		}
+
+		r, err := parseLabelRequirement(raw)
+		if err != nil {
+			return LabelSelector{}, fmt.Errorf("invalid label selector %q: %w", selector, err)
+		}
+		s.requirements = append(s.requirements, r)
+	}
+	return s, nil
+}
+
+// Matches indicates the given labels satisfy all requirements of the selector.
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for _, r := range s.requirements {
+		if !r.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r labelRequirement) matches(labels map[string]string) bool {
+	value, ok := labels[r.key]
+	switch r.operator {
+	case labelExists:
+		return ok
+	case labelDoesNotExist:
+		return !ok
+	case labelEquals, labelIn:
+		return ok && slices.Contains(r.values, value)
+	case labelNotEquals, labelNotIn:
+		return !ok || !slices.Contains(r.values, value)
+	}
+	return false
+}
+
+// splitSelector splits the selector on commas that are not within the parentheses of a set-based requirement.
+func splitSelector(selector string) []string {
+	var parts []string
+	var depth, start int
+	for idx, c := range selector {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, selector[start:idx])
+				start = idx + 1
+			}
+		}
+	}
+	return append(parts, selector[start:])
+}
+
+func parseLabelRequirement(raw string) (labelRequirement, error) {
+	if m := setRequirementPattern.FindStringSubmatch(raw); m != nil {
+		r := labelRequirement{key: m[1], operator: labelOperator(m[2])}
+		for _, v := range strings.Split(m[3], ",") {
+			r.values = append(r.values, strings.TrimSpace(v))
+		}
+		return r, r.validate()
+	}
+
+	var r labelRequirement
+	switch {
+	case strings.HasPrefix(raw, "!"):
+		r = labelRequirement{key: strings.TrimSpace(raw[1:]), operator: labelDoesNotExist}
+	case strings.Contains(raw, "!="):
+		k, v, _ := strings.Cut(raw, "!=")
+		r = labelRequirement{key: strings.TrimSpace(k), operator: labelNotEquals, values: []string{strings.TrimSpace(v)}}
+	case strings.Contains(raw, "=="):
+		k, v, _ := strings.Cut(raw, "==")
+		r = labelRequirement{key: strings.TrimSpace(k), operator: labelEquals, values: []string{strings.TrimSpace(v)}}
+	case strings.Contains(raw, "="):
+		k, v, _ := strings.Cut(raw, "=")
+		r = labelRequirement{key: strings.TrimSpace(k), operator: labelEquals, values: []string{strings.TrimSpace(v)}}
+	default:
+		r = labelRequirement{key: raw, operator: labelExists}
+	}
+	return r, r.validate()
+}
+
+func (r labelRequirement) validate() error {
+	if r.key == "" {
+		return fmt.Errorf("missing key")
+	}
+	if strings.ContainsAny(r.key, " \t!=(),") {
+		return fmt.Errorf("invalid key %q", r.key)
+	}
+	for _, v := range r.values {
+		if strings.ContainsAny(v, " \t!=(),") {
+			return fmt.Errorf("invalid value %q for key %q", v, r.key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package image
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadata_MatchLabels(t *testing.T) {
+	m := Metadata{
+		Config: v1.ConfigFile{
+			Config: v1.Config{
+				Labels: map[string]string{
+					"app":                               "web",
+					"tier":                              "frontend",
+					"org.opencontainers.image.vendor":   "anchore",
+					"org.opencontainers.image.licenses": "Apache-2.0",
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		selector string
+		want     bool
+		wantErr  require.ErrorAssertionFunc
+	}{
+		{selector: "", want: true},
+		{selector: "app", want: true},
+		{selector: "missing", want: false},
+		{selector: "!missing", want: true},
+		{selector: "!app", want: false},
+		{selector: "app=web", want: true},
+		{selector: "app==web", want: true},
+		{selector: "app = web", want: true},
+		{selector: "app=api", want: false},
+		{selector: "app!=api", want: true},
+		{selector: "missing!=api", want: true},
+		{selector: "tier in (frontend, backend)", want: true},
+		{selector: "tier in (backend)", want: false},
+		{selector: "tier notin (backend)", want: true},
+		{selector: "missing notin (backend)", want: true},
+		{selector: "missing in (backend)", want: false},
+		{selector: "app=web,tier in (frontend,backend),org.opencontainers.image.vendor=anchore", want: true},
+		{selector: "app=web,tier=backend", want: false},
+		{selector: "app=web,", wantErr: require.Error},
+		{selector: "=web", wantErr: require.Error},
+		{selector: "app=web api", wantErr: require.Error},
+		{selector: "tier in (a b)", wantErr: require.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+
+			got, err := m.MatchLabels(tt.selector)
+			tt.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMetadata_Labels(t *testing.T) {
+	m := Metadata{
+		Config: v1.ConfigFile{
+			Config: v1.Config{
+				Labels: map[string]string{"app": "web"},
+			},
+		},
+	}
+
+	v, ok := m.GetLabel("app")
+	assert.True(t, ok)
+	assert.Equal(t, "web", v)
+
+	_, ok = m.GetLabel("missing")
+	assert.False(t, ok)
+
+	labels := m.Labels()
+	labels["app"] = "changed"
+	assert.Equal(t, "web", m.Config.Config.Labels["app"], "labels should be a copy")
+
+	assert.NotNil(t, Metadata{}.Labels())
+}
+
+func TestMetadata_Env(t *testing.T) {
+	m := Metadata{
+		Config: v1.ConfigFile{
+			Config: v1.Config{
+				Env: []string{"PATH=/usr/bin:/bin", "JAVA_VERSION=17", "EMPTY=", "JAVA_VERSION=21", "INVALID"},
+			},
+		},
+	}
+
+	assert.Equal(t, map[string]string{
+		"PATH":         "/usr/bin:/bin",
+		"JAVA_VERSION": "21",
+		"EMPTY":        "",
+	}, m.Env())
+
+	v, ok := m.GetEnv("JAVA_VERSION")
+	assert.True(t, ok)
+	assert.Equal(t, "21", v)
+
+	v, ok = m.GetEnv("EMPTY")
+	assert.True(t, ok)
+	assert.Empty(t, v)
+
+	_, ok = m.GetEnv("INVALID")
+	assert.False(t, ok)
+
+	matched, err := m.MatchEnv("JAVA_VERSION in (17, 21),PATH")
+	require.NoError(t, err)
+	assert.True(t, matched)
+
+	matched, err = m.MatchEnv("JAVA_VERSION=17")
+	require.NoError(t, err)
+	assert.False(t, matched)
+}
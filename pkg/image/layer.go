@@ -2,6 +2,7 @@ package image
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -24,6 +25,10 @@ import (
 
 const SingularitySquashFSLayer = "application/vnd.sylabs.sif.layer.v1.squashfs"
 
+// OCIRestrictedLayerZStd is the (deprecated) non-distributable variant of types.OCILayerZStd, which is not defined by
+// the GCR lib.
+const OCIRestrictedLayerZStd types.MediaType = "application/vnd.oci.image.layer.nondistributable.v1.tar+zstd"
+
 // Layer represents a single layer within a container image.
 type Layer struct {
 	// layer is the raw layer metadata and content provider from the GCR lib
@@ -41,6 +46,22 @@ type Layer struct {
 	fileCatalog           *FileCatalog
 	SquashedSearchContext filetree.Searcher
 	SearchContext         filetree.Searcher
+	// pathFilter excludes entries from the layer while it is read (see WithPathExcludes)
+	pathFilter *pathFilter
+	// treeLimits bounds the shape of the layer tree while it is read (see WithTreeLimits)
+	treeLimits *TreeLimits
+	// pathPolicy controls how anomalous entry paths are handled while the layer is read (see WithPathPolicy)
+	pathPolicy *PathPolicy
+	// timestampPolicy controls how implausible entry timestamps are handled while the layer is read (see
+	// WithTimestampPolicy)
+	timestampPolicy *TimestampPolicy
+	// descriptor is the descriptor of the layer within the image manifest (nil when unknown)
+	descriptor *v1.Descriptor
+	// blobCache is the persistent cache the uncompressed layer tar is read from and written to (see WithCacheDir)
+	blobCache *BlobCache
+	// lazy is the layer file contents are fetched from on demand (nil unless the layer was read from its table of
+	// contents, see LazyLayer)
+	lazy LazyLayer
 }
 
 // NewLayer provides a new, unread layer object.
@@ -50,37 +71,122 @@ func NewLayer(layer v1.Layer) *Layer {
 	}
 }
 
-func (l *Layer) uncompressedTarCache(uncompressedLayersCacheDir string) (string, error) {
+func (l *Layer) uncompressedTarCache(ctx context.Context, uncompressedLayersCacheDir string) (string, error) {
 	if uncompressedLayersCacheDir == "" {
 		return "", fmt.Errorf("no cache directory given")
 	}
 
-	tarPath := path.Join(uncompressedLayersCacheDir, l.Metadata.Digest+".tar")
+	tarPath := l.pathFilter.cachePath(uncompressedLayersCacheDir, l.Metadata.Digest)
 
 	if _, err := os.Stat(tarPath); !os.IsNotExist(err) {
 		return tarPath, nil
 	}
 
-	rawReader, err := l.layer.Uncompressed()
+	if l.blobCache != nil && l.populateBlobCache(ctx, tarPath) {
+		return tarPath, nil
+	}
+
+	rawReadCloser, err := l.uncompressedReader()
 	if err != nil {
 		return "", err
 	}
+	defer rawReadCloser.Close()
+	rawReader := contextReader{ctx: ctx, r: rawReadCloser}
 
-	fh, err := os.Create(tarPath)
+	// write to an intermediate file such that a failed (e.g. interrupted) fetch is never mistaken for a cached layer
+	partialPath := tarPath + ".partial"
+	fh, err := os.Create(partialPath)
 	if err != nil {
 		return "", fmt.Errorf("unable to create layer cache dir=%q : %w", tarPath, err)
 	}
 
-	if _, err := io.Copy(fh, rawReader); err != nil {
+	if l.pathFilter != nil {
+		err = l.pathFilter.copyTar(fh, rawReader)
+	} else {
+		_, err = io.Copy(fh, rawReader)
+	}
+	if err != nil {
+		_ = fh.Close()
+		_ = os.Remove(partialPath)
 		return "", fmt.Errorf("unable to populate layer cache dir=%q : %w", tarPath, err)
 	}
 
+	if err := fh.Close(); err != nil {
+		return "", fmt.Errorf("unable to close layer cache file=%q : %w", partialPath, err)
+	}
+
+	if err := os.Rename(partialPath, tarPath); err != nil {
+		return "", fmt.Errorf("unable to finalize layer cache file=%q : %w", tarPath, err)
+	}
+
 	return tarPath, nil
 }
 
+// populateBlobCache adds the uncompressed layer tar to the persistent blob cache (if missing) and, when no filtering is
+// needed, links it from the blob cache to the given path (returning true when linked).
+func (l *Layer) populateBlobCache(ctx context.Context, tarPath string) bool {
+	digest := l.Metadata.Digest
+	if l.blobCache.Has(digest) {
+		log.WithFields("layer", digest).Trace("using layer from blob cache")
+	} else {
+		rawReader, err := l.layer.Uncompressed()
+		if err != nil {
+			log.WithFields("layer", digest, "error", err).Debug("unable to read layer for blob cache")
+			return false
+		}
+		err = l.blobCache.Put(digest, contextReader{ctx: ctx, r: rawReader})
+		_ = rawReader.Close()
+		if err != nil {
+			log.WithFields("layer", digest, "error", err).Warn("unable to add layer to blob cache")
+			return false
+		}
+	}
+
+	if l.pathFilter != nil {
+		return false
+	}
+
+	if err := l.blobCache.Link(digest, tarPath); err != nil {
+		log.WithFields("layer", digest, "error", err).Debug("unable to link layer from blob cache")
+		return false
+	}
+	return true
+}
+
+// uncompressedReader returns a reader for the uncompressed layer tar, preferring the persistent blob cache.
+func (l *Layer) uncompressedReader() (io.ReadCloser, error) {
+	if l.blobCache != nil && l.blobCache.Has(l.Metadata.Digest) {
+		reader, err := l.blobCache.Open(l.Metadata.Digest)
+		if err == nil {
+			return reader, nil
+		}
+		// the blob may have been evicted concurrently
+		log.WithFields("layer", l.Metadata.Digest, "error", err).Debug("unable to read layer from blob cache")
+	}
+	return l.layer.Uncompressed()
+}
+
+// layerCachePath is the path of the uncompressed layer tar with the given digest within the layer cache directory.
+func layerCachePath(uncompressedLayersCacheDir, digest string) string {
+	return path.Join(uncompressedLayersCacheDir, digest+".tar")
+}
+
+// LayerCachePath is the path within the given content cache directory where the uncompressed layer tar with the given
+// diff ID is cached. Providers that already have the uncompressed layer tar on disk may place it here (before the
+// image is read) to avoid the layer being written a second time.
+func LayerCachePath(contentCacheDir, diffID string) string {
+	return layerCachePath(contentCacheDir, diffID)
+}
+
 // Read parses information from the underlying layer tar into this struct. This includes layer metadata, the layer
 // file tree, and the layer squash tree.
 func (l *Layer) Read(catalog *FileCatalog, imgMetadata Metadata, idx int, uncompressedLayersCacheDir string) error {
+	return l.ReadContext(context.Background(), catalog, imgMetadata, idx, uncompressedLayersCacheDir)
+}
+
+// ReadContext is the same as Read, where reading stops as soon as the given context is done (while the layer contents
+// are fetched, or between layer entries), failing with the cause of the cancellation.
+func (l *Layer) ReadContext(ctx context.Context, catalog *FileCatalog, imgMetadata Metadata, idx int, uncompressedLayersCacheDir string) error {
 	var err error
 	tree := filetree.New()
 	l.Tree = tree
@@ -89,6 +195,7 @@ func (l *Layer) Read(catalog *FileCatalog, imgMetadata Metadata, idx int, uncomp
 	if err != nil {
 		return err
 	}
+	l.Metadata.Descriptor = l.descriptor
 
 	log.Debugf("layer metadata: index=%+v digest=%+v mediaType=%+v",
 		l.Metadata.Index,
@@ -96,31 +203,36 @@ func (l *Layer) Read(catalog *FileCatalog, imgMetadata Metadata, idx int, uncomp
 		l.Metadata.MediaType)
 
 	monitor := trackReadProgress(l.Metadata)
+	auditor := l.newPathAuditor(tree)
 
-	switch l.Metadata.MediaType {
-	case types.OCILayer,
-		types.OCIUncompressedLayer,
-		types.OCIRestrictedLayer,
-		types.OCIUncompressedRestrictedLayer,
-		types.OCILayerZStd,
-		types.DockerLayer,
-		types.DockerForeignLayer,
-		types.DockerUncompressedLayer:
+	lazyEntries := l.lazyEntries()
+
+	switch fsys := l.fsLayer(); {
+	case fsys != nil:
+		if err := l.indexFS(ctx, fsys, tree, monitor, auditor); err != nil {
+			return fmt.Errorf("failed to walk layer=%q: %w", l.Metadata.Digest, err)
+		}
 
-		tarFilePath, err := l.uncompressedTarCache(uncompressedLayersCacheDir)
+	case lazyEntries != nil:
+		if err := l.indexLazyEntries(ctx, lazyEntries, tree, monitor, auditor); err != nil {
+			return fmt.Errorf("failed to read layer=%q table of contents : %w", l.Metadata.Digest, err)
+		}
+
+	case isTarLayer(l.Metadata.MediaType):
+		tarFilePath, err := l.uncompressedTarCache(ctx, uncompressedLayersCacheDir)
 		if err != nil {
 			return err
 		}
 
 		l.indexedContent, err = file.NewTarIndex(
 			tarFilePath,
-			layerTarIndexer(tree, l.fileCatalog, &l.Metadata.Size, l, monitor),
+			layerTarIndexer(ctx, tree, l.fileCatalog, &l.Metadata.Size, l, monitor, auditor),
 		)
 		if err != nil {
 			return fmt.Errorf("failed to read layer=%q tar : %w", l.Metadata.Digest, err)
 		}
 
-	case SingularitySquashFSLayer:
+	case l.Metadata.MediaType == SingularitySquashFSLayer:
 		r, err := l.layer.Uncompressed()
 		if err != nil {
 			return fmt.Errorf("failed to read layer=%q: %w", l.Metadata.Digest, err)
@@ -129,9 +241,9 @@ func (l *Layer) Read(catalog *FileCatalog, imgMetadata Metadata, idx int, uncomp
 
 		// Walk the more efficient walk if we're blessed with an io.ReaderAt.
 		if ra, ok := r.(io.ReaderAt); ok {
-			err = file.WalkSquashFS(ra, squashfsVisitor(tree, l.fileCatalog, &l.Metadata.Size, l, monitor))
+			err = file.WalkSquashFS(ra, squashfsVisitor(ctx, tree, l.fileCatalog, &l.Metadata.Size, l, monitor, l.pathFilter, auditor))
 		} else {
-			err = file.WalkSquashFSFromReader(r, squashfsVisitor(tree, l.fileCatalog, &l.Metadata.Size, l, monitor))
+			err = file.WalkSquashFSFromReader(r, squashfsVisitor(ctx, tree, l.fileCatalog, &l.Metadata.Size, l, monitor, l.pathFilter, auditor))
 		}
 		if err != nil {
 			return fmt.Errorf("failed to walk layer=%q: %w", l.Metadata.Digest, err)
@@ -141,6 +253,10 @@ func (l *Layer) Read(catalog *FileCatalog, imgMetadata Metadata, idx int, uncomp
 		return fmt.Errorf("unknown layer media type: %+v", l.Metadata.MediaType)
 	}
 
+	if err := auditor.addAliases(filetree.NewBuilder(tree, l.fileCatalog.Index), l.fileCatalog, l); err != nil {
+		return fmt.Errorf("failed to read layer=%q: %w", l.Metadata.Digest, err)
+	}
+
 	l.SearchContext = filetree.NewSearchContext(l.Tree, l.fileCatalog.Index)
 
 	monitor.SetCompleted()
@@ -148,6 +264,35 @@ func (l *Layer) Read(catalog *FileCatalog, imgMetadata Metadata, idx int, uncomp
 	return nil
 }
 
+// isTarLayer indicates that layers with the given media type are (possibly gzip or zstd compressed) tars. Note: the
+// layer contents are decompressed based on the contents themselves (not the media type).
+func isTarLayer(mediaType types.MediaType) bool {
+	switch mediaType {
+	case types.OCILayer,
+		types.OCIUncompressedLayer,
+		types.OCIRestrictedLayer,
+		types.OCIUncompressedRestrictedLayer,
+		types.OCILayerZStd,
+		OCIRestrictedLayerZStd,
+		types.DockerLayer,
+		types.DockerForeignLayer,
+		types.DockerUncompressedLayer:
+		return true
+	}
+	return false
+}
+
+// Descriptor returns the descriptor of the layer blob within the image manifest as provided by the source (media type,
+// compressed size and digest, and annotations, such as the estargz table of contents digest), such that findings
+// within the layer can be correlated with registry blobs. Nil is returned when the manifest is unknown.
+func (l *Layer) Descriptor() *v1.Descriptor {
+	if l.descriptor == nil {
+		return nil
+	}
+	desc := *l.descriptor
+	return &desc
+}
+
 // OpenPath reads the file contents for the given path from the underlying layer blob, relative to the layers "diff tree".
 // An error is returned if there is no file at the given path and layer or the read operation cannot continue.
 func (l *Layer) OpenPath(path file.Path) (io.ReadCloser, error) {
@@ -206,13 +351,39 @@ func (l *Layer) FilesByMIMETypeFromSquash(mimeTypes ...string) ([]file.Reference
 	return refs, nil
 }
 
-func layerTarIndexer(ft filetree.Writer, fileCatalog *FileCatalog, size *int64, layerRef *Layer, monitor *progress.Manual) file.TarIndexVisitor {
+// newTreeLimiter creates a limiter for a single read of the layer tree (nil when there are no limits).
+func (l *Layer) newTreeLimiter() *treeLimiter {
+	if l == nil {
+		return nil
+	}
+	return newTreeLimiter(l.treeLimits, &l.Metadata)
+}
+
+// newPathAuditor creates an auditor for a single read of the layer tree.
+func (l *Layer) newPathAuditor(tree filetree.PathReader) *pathAuditor {
+	return newPathAuditor(l.pathPolicy, &l.Metadata, tree)
+}
+
+func layerTarIndexer(ctx context.Context, ft filetree.Writer, fileCatalog *FileCatalog, size *int64, layerRef *Layer, monitor *progress.Manual, auditor *pathAuditor) file.TarIndexVisitor {
 	builder := filetree.NewBuilder(ft, fileCatalog.Index)
+	limiter := layerRef.newTreeLimiter()
 
 	return func(index file.TarIndexEntry) error {
+		if err := context.Cause(ctx); err != nil {
+			return err
+		}
+
 		var err error
 		var entry = index.ToTarFileEntry()
 
+		if admitted, err := limiter.admit(entry.Header.Name); !admitted {
+			return err
+		}
+
+		if admitted, err := auditor.admit(entry.Header.Name); !admitted {
+			return err
+		}
+
 		var contents = index.Open()
 		defer func() {
 			if err := contents.Close(); err != nil {
@@ -220,6 +391,7 @@ func layerTarIndexer(ft filetree.Writer, fileCatalog *FileCatalog, size *int64,
 			}
 		}()
 		metadata := file.NewMetadata(entry.Header, contents)
+		layerRef.timestampPolicy.apply(&metadata)
 
 		// note: the tar header name is independent of surrounding structure, for example, there may be a tar header entry
 		// for /some/path/to/file.txt without any entries to constituent paths (/some, /some/path, /some/path/to ).
@@ -248,10 +420,36 @@ func layerTarIndexer(ft filetree.Writer, fileCatalog *FileCatalog, size *int64,
 	}
 }
 
-func squashfsVisitor(ft filetree.Writer, fileCatalog *FileCatalog, size *int64, layerRef *Layer, monitor *progress.Manual) file.SquashFSVisitor {
+func squashfsVisitor(ctx context.Context, ft filetree.Writer, fileCatalog *FileCatalog, size *int64, layerRef *Layer, monitor *progress.Manual, filter *pathFilter, auditor *pathAuditor) file.SquashFSVisitor {
 	builder := filetree.NewBuilder(ft, fileCatalog.Index)
+	limiter := layerRef.newTreeLimiter()
 
 	return func(fsys fs.FS, path string, d fs.DirEntry) error {
+		if err := context.Cause(ctx); err != nil {
+			return err
+		}
+
+		if filter.excluded(path) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if admitted, err := limiter.admit(path); !admitted {
+			if err == nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return err
+		}
+
+		if admitted, err := auditor.admit(path); !admitted {
+			if err == nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return err
+		}
+
 		ff, err := fsys.Open(path)
 		if err != nil {
 			return err
@@ -267,6 +465,7 @@ func squashfsVisitor(ft filetree.Writer, fileCatalog *FileCatalog, size *int64,
 		if err != nil {
 			return err
 		}
+		layerRef.timestampPolicy.apply(&metadata)
 
 		fileReference, err := builder.Add(metadata)
 		if err != nil {
@@ -292,6 +491,19 @@ func squashfsVisitor(ft filetree.Writer, fileCatalog *FileCatalog, size *int64,
 	}
 }
 
+// contextReader fails all reads with the cause of the cancellation once the context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := context.Cause(r.ctx); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
 func trackReadProgress(metadata LayerMetadata) *progress.Manual {
 	p := &progress.Manual{}
 
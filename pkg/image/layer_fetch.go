@@ -0,0 +1,79 @@
+package image
+
+import (
+	"context"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+// newLayer creates a layer for the given v1 layer with all read settings of the image applied.
+func (i *Image) newLayer(v1Layer v1.Layer, blobCache *BlobCache) *Layer {
+	layer := NewLayer(v1Layer)
+	layer.pathFilter = i.pathFilter
+	layer.treeLimits = i.treeLimits
+	layer.pathPolicy = i.pathPolicy
+	layer.timestampPolicy = i.timestampPolicy
+	layer.blobCache = blobCache
+	return layer
+}
+
+// fetchLayers populates the uncompressed layer tar cache for all tar-based layers using up to maxParallelism workers
+// (see WithMaxParallelism), such that layer downloads and decompression overlap. Layers are still indexed serially
+// afterwards, which finds each layer tar already cached. Fetching is best-effort: any layer that fails to be fetched is
+// fetched again while it is read, where the failure is reported (or tolerated, see WithBestEffortLayers).
+func (i *Image) fetchLayers(ctx context.Context, v1Layers []v1.Layer, blobCache *BlobCache) {
+	if i.maxParallelism <= 1 || len(v1Layers) <= 1 {
+		return
+	}
+
+	// layers with the same content (diff ID) share a cache path, thus are only fetched once
+	seen := make(map[string]struct{})
+	var layers []*Layer
+	for idx, v1Layer := range v1Layers {
+		if _, ok := v1Layer.(LazyLayer); ok {
+			// lazy layers are never fetched in full unless the table of contents cannot be read
+			continue
+		}
+		if _, ok := v1Layer.(FSLayer); ok {
+			// filesystem layers are read in place
+			continue
+		}
+		layer := i.newLayer(v1Layer, blobCache)
+		metadata, err := newLayerMetadata(i.Metadata, v1Layer, idx)
+		if err != nil || !isTarLayer(metadata.MediaType) {
+			continue
+		}
+		if _, ok := seen[metadata.Digest]; ok {
+			continue
+		}
+		seen[metadata.Digest] = struct{}{}
+		layer.Metadata = metadata
+		layers = append(layers, layer)
+	}
+
+	work := make(chan *Layer)
+	var wg sync.WaitGroup
+	for w := 0; w < i.maxParallelism && w < len(layers); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for layer := range work {
+				if ctx.Err() != nil {
+					continue
+				}
+				if _, err := layer.uncompressedTarCache(ctx, i.contentCacheDir); err != nil {
+					log.WithFields("layer", layer.Metadata.Digest, "error", err).Trace("unable to fetch layer ahead of reading")
+				}
+			}
+		}()
+	}
+
+	for _, layer := range layers {
+		work <- layer
+	}
+	close(work)
+	wg.Wait()
+}
@@ -0,0 +1,104 @@
+package image
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+// concurrentLayer tracks the number of layers being uncompressed at the same time.
+type concurrentLayer struct {
+	v1.Layer
+	tracker *concurrencyTracker
+}
+
+type concurrencyTracker struct {
+	lock    sync.Mutex
+	current int
+	max     int
+	calls   int
+}
+
+func (l *concurrentLayer) Uncompressed() (io.ReadCloser, error) {
+	l.tracker.lock.Lock()
+	l.tracker.current++
+	l.tracker.calls++
+	if l.tracker.current > l.tracker.max {
+		l.tracker.max = l.tracker.current
+	}
+	l.tracker.lock.Unlock()
+
+	// give other workers a chance to start fetching
+	time.Sleep(50 * time.Millisecond)
+
+	l.tracker.lock.Lock()
+	l.tracker.current--
+	l.tracker.lock.Unlock()
+
+	return l.Layer.Uncompressed()
+}
+
+func TestImage_Read_MaxParallelism(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxParallelism int
+		wantConcurrent bool
+	}{
+		{
+			name: "serial by default",
+		},
+		{
+			name:           "parallel",
+			maxParallelism: 3,
+			wantConcurrent: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &concurrencyTracker{}
+			var layers []v1.Layer
+			for _, files := range []map[string]string{
+				{"a.txt": "first", "etc/config": "v1"},
+				{"b.txt": "second"},
+				{"etc/config": "v2"},
+				{"b.txt": "second"},
+			} {
+				layers = append(layers, &concurrentLayer{Layer: tarLayer(t, files), tracker: tracker})
+			}
+
+			v1Img, err := mutate.AppendLayers(empty.Image, layers...)
+			require.NoError(t, err)
+
+			img := New(v1Img, nil, t.TempDir(), WithMaxParallelism(tt.maxParallelism))
+			require.NoError(t, img.Read())
+
+			require.Len(t, img.Layers, 4)
+			assert.Equal(t, tt.wantConcurrent, tracker.max > 1)
+			// the duplicate layer shares the cached tar of the second layer
+			assert.Equal(t, 3, tracker.calls)
+
+			for p, want := range map[string]string{"/a.txt": "first", "/b.txt": "second", "/etc/config": "v2"} {
+				contents, err := img.OpenPathFromSquash(file.Path(p))
+				require.NoError(t, err, p)
+				got, err := io.ReadAll(contents)
+				require.NoError(t, err)
+				assert.Equal(t, want, string(got), p)
+			}
+		})
+	}
+
+	t.Run("negative", func(t *testing.T) {
+		img := New(empty.Image, nil, t.TempDir(), WithMaxParallelism(-1))
+		require.Error(t, img.Read())
+	})
+}
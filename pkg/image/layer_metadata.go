@@ -1,8 +1,13 @@
 package image
 
 import (
+	"strings"
+	"time"
+
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	v1Types "github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/anchore/stereoscope/pkg/file"
 )
 
 // Metadata represents container layer metadata.
@@ -13,6 +18,32 @@ type LayerMetadata struct {
 	MediaType v1Types.MediaType
 	// Size in bytes of the layer content size
 	Size int64
+	// Descriptor is the descriptor of the layer blob within the image manifest as provided by the source (e.g. the
+	// compressed digest, size, and annotations of the registry blob), nil when the manifest is unknown
+	Descriptor *v1.Descriptor
+	// Compression is the compression of the layer blob according to the media type
+	Compression file.Compression
+	// Created is when the layer was created according to the config history (zero when the history does not describe
+	// every layer, or when the timestamp is zeroed or a fixed epoch used by reproducible builders)
+	Created time.Time
+	// CreatedBy is the command that created the layer according to the config history (e.g. a Dockerfile instruction)
+	CreatedBy string
+	// HistoryIndex is the position of the entry within the config history that created the layer (see
+	// Metadata.History), -1 when the history cannot be aligned with the layers
+	HistoryIndex int
+	// Completed indicates that every entry of the layer was read (false for layers that failed to be read, see
+	// ReadError)
+	Completed bool
+	// ReadError is set when the layer could not be read (see WithBestEffortLayers)
+	ReadError error
+	// TruncatedEntries is the number of layer entries skipped for exceeding the tree limits (see WithTreeLimits)
+	TruncatedEntries int
+	// TreeLimitViolations describes the first entries skipped for exceeding the tree limits (see WithTreeLimits)
+	TreeLimitViolations []ErrTreeLimitExceeded
+	// PathAnomalyCount is the number of anomalous entry paths within the layer (see PathPolicy)
+	PathAnomalyCount int
+	// PathAnomalies describes the first anomalous entry paths within the layer (see PathPolicy)
+	PathAnomalies []PathAnomaly
 }
 
 // newLayerMetadata aggregates pertinent layer metadata information.
@@ -24,9 +55,43 @@ func newLayerMetadata(imgMetadata Metadata, layer v1.Layer, idx int) (LayerMetad
 
 	// digest = diff-id = a digest of the uncompressed layer content
 	diffIDHash := imgMetadata.Config.RootFS.DiffIDs[idx]
-	return LayerMetadata{
-		Index:     uint(idx),
-		Digest:    diffIDHash.String(),
-		MediaType: mediaType,
-	}, nil
+	metadata := LayerMetadata{
+		Index:        uint(idx),
+		Digest:       diffIDHash.String(),
+		MediaType:    mediaType,
+		Compression:  mediaTypeCompression(mediaType),
+		HistoryIndex: -1,
+	}
+
+	history := imgMetadata.History
+	if history == nil {
+		history = readHistory(imgMetadata.Config)
+	}
+	if h, ok := layerHistory(history, idx); ok {
+		metadata.Created = historyTime(h.Created)
+		metadata.CreatedBy = h.CreatedBy
+		metadata.HistoryIndex = h.Index
+	}
+	return metadata, nil
+}
+
+// mediaTypeCompression returns the compression of layer blobs with the given media type.
+func mediaTypeCompression(mediaType v1Types.MediaType) file.Compression {
+	switch {
+	case mediaType == v1Types.DockerLayer, mediaType == v1Types.DockerForeignLayer, strings.HasSuffix(string(mediaType), "+gzip"):
+		return file.Gzip
+	case strings.HasSuffix(string(mediaType), "+zstd"):
+		return file.Zstd
+	}
+	return file.Uncompressed
+}
+
+// manifestLayerDescriptor returns the descriptor of the layer at the given index within the image manifest (nil when
+// the manifest is unknown or does not describe the given layers).
+func manifestLayerDescriptor(manifest *v1.Manifest, idx, layers int) *v1.Descriptor {
+	if manifest == nil || len(manifest.Layers) != layers || idx >= layers {
+		return nil
+	}
+	desc := manifest.Layers[idx]
+	return &desc
 }
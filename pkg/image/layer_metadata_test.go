@@ -0,0 +1,62 @@
+package image
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func TestLayer_Descriptor(t *testing.T) {
+	first := tarLayer(t, map[string]string{"etc/os-release": "ID=test\n"})
+	second := tarLayer(t, map[string]string{"app/main": "payload"})
+
+	v1Img, err := mutate.Append(empty.Image,
+		mutate.Addendum{Layer: first},
+		mutate.Addendum{Layer: second, Annotations: map[string]string{"containerd.io/snapshot/stargz/toc.digest": "sha256:abc"}},
+	)
+	require.NoError(t, err)
+
+	img := New(v1Img, nil, t.TempDir())
+	require.NoError(t, img.Read())
+	require.Len(t, img.Layers, 2)
+
+	for idx, v1Layer := range []v1.Layer{first, second} {
+		digest, err := v1Layer.Digest()
+		require.NoError(t, err)
+
+		desc := img.Layers[idx].Descriptor()
+		require.NotNil(t, desc)
+		assert.Equal(t, digest, desc.Digest)
+		assert.Equal(t, types.DockerLayer, desc.MediaType)
+		assert.Equal(t, desc, img.Layers[idx].Metadata.Descriptor)
+		assert.Equal(t, file.Gzip, img.Layers[idx].Metadata.Compression)
+	}
+	assert.Empty(t, img.Layers[0].Descriptor().Annotations)
+	assert.Equal(t, "sha256:abc", img.Layers[1].Descriptor().Annotations["containerd.io/snapshot/stargz/toc.digest"])
+}
+
+func Test_mediaTypeCompression(t *testing.T) {
+	tests := []struct {
+		mediaType types.MediaType
+		want      file.Compression
+	}{
+		{mediaType: types.DockerLayer, want: file.Gzip},
+		{mediaType: types.OCILayer, want: file.Gzip},
+		{mediaType: types.OCILayerZStd, want: file.Zstd},
+		{mediaType: types.OCIUncompressedLayer, want: file.Uncompressed},
+		{mediaType: types.DockerUncompressedLayer, want: file.Uncompressed},
+		{mediaType: SingularitySquashFSLayer, want: file.Uncompressed},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mediaType), func(t *testing.T) {
+			assert.Equal(t, tt.want, mediaTypeCompression(tt.mediaType))
+		})
+	}
+}
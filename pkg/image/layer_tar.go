@@ -0,0 +1,102 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+// LayerTarEntry is a single raw tar entry within an image layer.
+type LayerTarEntry struct {
+	file.TarFileEntry
+	// LayerIndex is the index of the layer within the image (in build order)
+	LayerIndex int
+	// LayerDigest is the digest of the uncompressed layer tar (the layer diff ID)
+	LayerDigest string
+}
+
+// LayerTarVisitor is a visitor function meant to be used in conjunction with Image.IterateLayerTars. Returning
+// file.ErrTarStopIteration stops the iteration without error.
+type LayerTarVisitor func(LayerTarEntry) error
+
+// IterateTar streams all raw tar entries (header and contents) of the layer to the given visitor in a single pass,
+// without building any file trees or caching layer contents to disk. The entry reader is only valid until the visitor
+// returns. The layer does not need to have been read. Returning file.ErrTarStopIteration from the visitor stops the
+// iteration without error.
+func (l *Layer) IterateTar(visitor file.TarFileVisitor) error {
+	return iterateLayerTar(l.layer, visitor)
+}
+
+// IterateLayerTars streams all raw tar entries of every layer in the image (in build order) to the given visitor in a
+// single pass, without building any file trees or caching layer contents to disk. This is intended for custom
+// single-pass processing (e.g. secret scanning) where maximal throughput is desired; note that whiteouts are not
+// applied, so entries from all layers are visited even if they are not present in the squashed filesystem. The image
+// does not need to have been read. The iteration stops early when the context is canceled or when the visitor returns
+// file.ErrTarStopIteration (which is not considered an error).
+func (i *Image) IterateLayerTars(ctx context.Context, visitor LayerTarVisitor) error {
+	if i.image == nil {
+		return fmt.Errorf("no image to iterate")
+	}
+
+	layers, err := i.image.Layers()
+	if err != nil {
+		return fmt.Errorf("unable to get image layers: %w", err)
+	}
+
+	for idx, layer := range layers {
+		diffID, err := layer.DiffID()
+		if err != nil {
+			return fmt.Errorf("unable to get layer=%d digest: %w", idx, err)
+		}
+
+		var stopped bool
+		err = iterateLayerTar(layer, func(entry file.TarFileEntry) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			err := visitor(LayerTarEntry{
+				TarFileEntry: entry,
+				LayerIndex:   idx,
+				LayerDigest:  diffID.String(),
+			})
+			if errors.Is(err, file.ErrTarStopIteration) {
+				stopped = true
+			}
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("unable to iterate layer=%q: %w", diffID.String(), err)
+		}
+		if stopped {
+			return nil
+		}
+	}
+	return nil
+}
+
+func iterateLayerTar(layer v1.Layer, visitor file.TarFileVisitor) error {
+	if layer == nil {
+		return fmt.Errorf("no layer to iterate")
+	}
+
+	mediaType, err := layer.MediaType()
+	if err != nil {
+		return fmt.Errorf("unable to get layer media type: %w", err)
+	}
+
+	if mediaType == SingularitySquashFSLayer {
+		return fmt.Errorf("layer media type is not a tar: %s", mediaType)
+	}
+
+	reader, err := layer.Uncompressed()
+	if err != nil {
+		return fmt.Errorf("unable to read layer: %w", err)
+	}
+	defer reader.Close()
+
+	return file.IterateTar(reader, visitor)
+}
@@ -0,0 +1,130 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func TestImage_IterateLayerTars(t *testing.T) {
+	first := tarLayer(t, map[string]string{"etc/os-release": "ID=test\n", "etc/secret": "token=abc\n"})
+	second := tarLayer(t, map[string]string{"etc/.wh.secret": ""})
+
+	v1Img, err := mutate.AppendLayers(empty.Image, first, second)
+	require.NoError(t, err)
+
+	// note: the image is never read
+	img := New(v1Img, nil, t.TempDir())
+
+	type visited struct {
+		layer    int
+		name     string
+		contents string
+	}
+
+	t.Run("all entries", func(t *testing.T) {
+		var got []visited
+		err := img.IterateLayerTars(context.Background(), func(entry LayerTarEntry) error {
+			contents, err := io.ReadAll(entry.Reader)
+			require.NoError(t, err)
+			got = append(got, visited{layer: entry.LayerIndex, name: entry.Header.Name, contents: string(contents)})
+			return nil
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []visited{
+			{layer: 0, name: "etc/os-release", contents: "ID=test\n"},
+			{layer: 0, name: "etc/secret", contents: "token=abc\n"},
+			{layer: 1, name: "etc/.wh.secret"},
+		}, got)
+		assert.Nil(t, img.FileCatalog)
+	})
+
+	t.Run("layer digests", func(t *testing.T) {
+		digests := map[int]string{}
+		err := img.IterateLayerTars(context.Background(), func(entry LayerTarEntry) error {
+			digests[entry.LayerIndex] = entry.LayerDigest
+			return nil
+		})
+		require.NoError(t, err)
+
+		for idx, layer := range []v1.Layer{first, second} {
+			diffID, err := layer.DiffID()
+			require.NoError(t, err)
+			assert.Equal(t, diffID.String(), digests[idx])
+		}
+	})
+
+	t.Run("stop iteration", func(t *testing.T) {
+		var count int
+		err := img.IterateLayerTars(context.Background(), func(LayerTarEntry) error {
+			count++
+			return file.ErrTarStopIteration
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := img.IterateLayerTars(ctx, func(LayerTarEntry) error {
+			t.Fatal("unexpected visit")
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestLayer_IterateTar(t *testing.T) {
+	layer := NewLayer(tarLayer(t, map[string]string{"etc/os-release": "ID=test\n"}))
+
+	var names []string
+	err := layer.IterateTar(func(entry file.TarFileEntry) error {
+		names = append(names, entry.Header.Name)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"etc/os-release"}, names)
+
+	require.Error(t, (&Layer{}).IterateTar(func(file.TarFileEntry) error { return nil }))
+}
+
+// tarLayer creates a layer with the given regular files (in lexical order of the paths).
+func tarLayer(t *testing.T, files map[string]string) v1.Layer {
+	t.Helper()
+
+	var paths []string
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, p := range paths {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: p, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(files[p]))}))
+		_, err := tw.Write([]byte(files[p]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	})
+	require.NoError(t, err)
+	return layer
+}
@@ -0,0 +1,144 @@
+package image
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/wagoodman/go-progress"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+)
+
+// LazyLayer is a layer with a table of contents, such that the layer can be read without fetching the entire layer
+// blob and file contents are fetched on demand (e.g. eStargz layers from a registry, see RegistryOptions.LazyPull).
+// Note: since file contents are not read while the layer is read, MIME types are not detected for files within lazy
+// layers.
+type LazyLayer interface {
+	v1.Layer
+	// Entries returns the tar headers of all entries within the layer (without fetching the layer contents)
+	Entries() ([]tar.Header, error)
+	// Open returns the contents of the regular file entry with the given name (fetched on demand)
+	Open(name string) (io.ReadCloser, error)
+}
+
+// LazyPrefetcher is a LazyLayer that is able to fetch the contents of entries ahead of demand (e.g. issuing range
+// requests for the compressed regions of those entries), such that opening the entries afterwards does not fetch the
+// contents again (see Image.Prefetch).
+type LazyPrefetcher interface {
+	LazyLayer
+	// Prefetch fetches and caches the contents of the regular file entries with the given names
+	Prefetch(ctx context.Context, names []string) error
+}
+
+// lazyPrefetcher returns the lazy layer the layer was read from when it supports prefetching (nil otherwise).
+func (l *Layer) lazyPrefetcher() LazyPrefetcher {
+	if lazy, ok := l.lazy.(LazyPrefetcher); ok {
+		return lazy
+	}
+	return nil
+}
+
+// lazyEntries returns the entries of the underlying lazy layer, or nil when the layer is not lazy or when the table of
+// contents cannot be read (in which case the layer is read in full instead).
+func (l *Layer) lazyEntries() []tar.Header {
+	lazy, ok := l.layer.(LazyLayer)
+	if !ok || !isTarLayer(l.Metadata.MediaType) {
+		return nil
+	}
+
+	entries, err := lazy.Entries()
+	if err != nil {
+		log.WithFields("layer", l.Metadata.Digest, "error", err).Debug("unable to read layer table of contents, reading the full layer instead")
+		return nil
+	}
+	if entries == nil {
+		entries = []tar.Header{}
+	}
+	return entries
+}
+
+// indexLazyEntries adds the given lazy layer entries to the layer tree and the file catalog (the same as
+// layerTarIndexer does for layer tars).
+func (l *Layer) indexLazyEntries(ctx context.Context, entries []tar.Header, ft filetree.Writer, monitor *progress.Manual, auditor *pathAuditor) error {
+	lazy := l.layer.(LazyLayer)
+	builder := filetree.NewBuilder(ft, l.fileCatalog.Index)
+	limiter := l.newTreeLimiter()
+
+	for _, header := range entries {
+		if err := context.Cause(ctx); err != nil {
+			return err
+		}
+
+		if l.pathFilter.excluded(header.Name) {
+			continue
+		}
+
+		if admitted, err := limiter.admit(header.Name); !admitted {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if admitted, err := auditor.admit(header.Name); !admitted {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		metadata := file.NewMetadata(header, nil)
+		l.timestampPolicy.apply(&metadata)
+		ref, err := builder.Add(metadata)
+		if err != nil {
+			return err
+		}
+
+		l.Metadata.Size += metadata.Size()
+		l.fileCatalog.addImageReferences(ref.ID(), l, lazyOpener(lazy, header))
+
+		if monitor != nil {
+			monitor.Increment()
+		}
+	}
+	l.lazy = lazy
+	return nil
+}
+
+// lazyOpener opens the contents of the given entry (or the entry it is a hardlink to) from the lazy layer.
+func lazyOpener(lazy LazyLayer, header tar.Header) file.Opener {
+	name := header.Name
+	switch header.Typeflag {
+	case tar.TypeReg:
+	case tar.TypeLink:
+		name = header.Linkname
+	default:
+		return nil
+	}
+
+	return func() io.ReadCloser {
+		reader, err := lazy.Open(name)
+		if err != nil {
+			return errReadCloser{err: fmt.Errorf("unable to open %q from lazy layer: %w", name, err)}
+		}
+		return reader
+	}
+}
+
+// errReadCloser fails all reads with the given error (for openers, which cannot return errors directly).
+type errReadCloser struct {
+	err error
+}
+
+func (r errReadCloser) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func (r errReadCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,206 @@
+package lxd
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"gopkg.in/yaml.v3"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+const Tarball image.Source = image.LxdTarballSource
+
+const (
+	metadataFile = "metadata.yaml"
+	rootfsDir    = "rootfs/"
+
+	// PropertyLabelPrefix is the prefix of the image config labels containing the properties from metadata.yaml
+	PropertyLabelPrefix = "org.linuxcontainers.image."
+)
+
+// metadata is the contents of the metadata.yaml file within an LXD image.
+type metadata struct {
+	Architecture string            `yaml:"architecture"`
+	CreationDate int64             `yaml:"creation_date"`
+	Properties   map[string]string `yaml:"properties"`
+}
+
+// NewTarballProvider creates a new provider for LXD unified image tarballs (a possibly compressed tar containing a
+// metadata.yaml file and a rootfs/ directory).
+func NewTarballProvider(tmpDirGen *file.TempDirGenerator, path string) image.Provider {
+	return &tarballImageProvider{
+		tmpDirGen: tmpDirGen,
+		path:      path,
+	}
+}
+
+// tarballImageProvider is an image.Provider for LXD unified image tarballs, represented as a single layer image.
+type tarballImageProvider struct {
+	tmpDirGen *file.TempDirGenerator
+	path      string
+}
+
+func (p *tarballImageProvider) Name() string {
+	return Tarball
+}
+
+// Provide an image object that represents the rootfs within the LXD tarball at the configured path.
+func (p *tarballImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	tempDir, err := p.tmpDirGen.NewDirectory("lxd-tarball")
+	if err != nil {
+		return nil, err
+	}
+
+	layerPath := filepath.Join(tempDir, "rootfs.tar")
+	meta, err := extractRootfs(p.path, layerPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := meta.configFile()
+	img, err := image.NewSingleLayerImage(layerPath, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	contentTempDir, err := p.tmpDirGen.NewDirectory("lxd-tarball-image")
+	if err != nil {
+		return nil, err
+	}
+
+	out := image.New(img, p.tmpDirGen, contentTempDir, image.WithOS(cfg.OS), image.WithArchitecture(cfg.Architecture, cfg.Variant))
+	if err := out.ReadContext(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// extractRootfs writes all rootfs/ entries (relative to the rootfs) from the LXD tarball to a new tar at the given
+// path, returning the parsed metadata.yaml.
+func extractRootfs(tarballPath, layerPath string) (*metadata, error) {
+	fh, err := os.Open(tarballPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open LXD tarball: %w", err)
+	}
+	defer fh.Close()
+
+	reader, err := file.NewDecompressingReader(fh)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	out, err := os.Create(layerPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create rootfs layer: %w", err)
+	}
+	defer out.Close()
+
+	tw := tar.NewWriter(out)
+
+	var meta *metadata
+	var foundRootfs bool
+	err = file.IterateTar(reader, func(entry file.TarFileEntry) error {
+		name := strings.TrimPrefix(entry.Header.Name, "./")
+
+		switch {
+		case name == metadataFile:
+			meta = &metadata{}
+			if err := yaml.NewDecoder(entry.Reader).Decode(meta); err != nil {
+				return fmt.Errorf("unable to parse %s: %w", metadataFile, err)
+			}
+			return nil
+
+		case !strings.HasPrefix(name, rootfsDir):
+			// e.g. templates/
+			return nil
+		}
+
+		foundRootfs = true
+		relative := strings.TrimPrefix(name, rootfsDir)
+		if relative == "" {
+			return nil
+		}
+
+		hdr := entry.Header
+		hdr.Name = relative
+		if hdr.Typeflag == tar.TypeLink {
+			hdr.Linkname = strings.TrimPrefix(strings.TrimPrefix(hdr.Linkname, "./"), rootfsDir)
+		}
+
+		if err := tw.WriteHeader(&hdr); err != nil {
+			return err
+		}
+		_, err := io.Copy(tw, entry.Reader)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read LXD tarball: %w", err)
+	}
+
+	if meta == nil || !foundRootfs {
+		return nil, fmt.Errorf("not an LXD unified tarball (missing %s or %s)", metadataFile, rootfsDir)
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("unable to write rootfs layer: %w", err)
+	}
+
+	return meta, nil
+}
+
+// configFile creates an image config that reflects the LXD image metadata.
+func (m metadata) configFile() v1.ConfigFile {
+	cfg := v1.ConfigFile{
+		OS: "linux",
+		Config: v1.Config{
+			Labels: make(map[string]string),
+		},
+	}
+
+	cfg.Architecture, cfg.Variant = goArchitecture(m.Architecture)
+
+	if m.CreationDate > 0 {
+		cfg.Created = v1.Time{Time: time.Unix(m.CreationDate, 0).UTC()}
+	}
+
+	for k, v := range m.Properties {
+		cfg.Config.Labels[PropertyLabelPrefix+k] = v
+	}
+
+	return cfg
+}
+
+// goArchitecture maps kernel architecture names (as used by LXD) to GOARCH-style architecture and variant names.
+func goArchitecture(arch string) (string, string) {
+	switch arch {
+	case "x86_64", "amd64":
+		return "amd64", ""
+	case "i686", "i386":
+		return "386", ""
+	case "aarch64", "arm64":
+		return "arm64", ""
+	case "armv7l", "armhf":
+		return "arm", "v7"
+	case "armv6l", "armel":
+		return "arm", "v6"
+	case "ppc64le", "ppc64el":
+		return "ppc64le", ""
+	case "s390x", "riscv64", "mips64", "mips64le":
+		return arch, ""
+	case "":
+		return "", ""
+	}
+	log.WithFields("architecture", arch).Debug("unknown LXD image architecture")
+	return "", ""
+}
@@ -0,0 +1,91 @@
+package lxd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+const testMetadata = `architecture: aarch64
+creation_date: 1700000000
+properties:
+  os: Ubuntu
+  release: jammy
+  description: Ubuntu jammy arm64
+`
+
+func TestTarballProvider_Provide(t *testing.T) {
+	path := writeTarball(t, map[string]string{
+		"metadata.yaml":         testMetadata,
+		"templates/hostname":    "{{ container.name }}",
+		"rootfs/etc/os-release": "ID=ubuntu\n",
+	})
+
+	tmpDirGen := file.NewTempDirGenerator("tempDir")
+	t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+	img, err := NewTarballProvider(tmpDirGen, path).Provide(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, "linux", img.Metadata.OS)
+	assert.Equal(t, "arm64", img.Metadata.Architecture)
+	assert.Equal(t, "Ubuntu", img.Metadata.Config.Config.Labels[PropertyLabelPrefix+"os"])
+	assert.Equal(t, "jammy", img.Metadata.Config.Config.Labels[PropertyLabelPrefix+"release"])
+	require.Len(t, img.Layers, 1)
+
+	reader, err := img.OpenPathFromSquash("/etc/os-release")
+	require.NoError(t, err)
+	contents, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "ID=ubuntu\n", string(contents))
+
+	assert.False(t, img.SquashedTree().HasPath("/hostname"))
+	assert.False(t, img.SquashedTree().HasPath("/metadata.yaml"))
+}
+
+func TestTarballProvider_NotLXD(t *testing.T) {
+	path := writeTarball(t, map[string]string{
+		"etc/os-release": "ID=ubuntu\n",
+	})
+
+	tmpDirGen := file.NewTempDirGenerator("tempDir")
+	t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+	_, err := NewTarballProvider(tmpDirGen, path).Provide(context.Background())
+	require.ErrorContains(t, err, "not an LXD unified tarball")
+}
+
+func writeTarball(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "image.tar.gz")
+	fh, err := os.Create(path)
+	require.NoError(t, err)
+	defer fh.Close()
+
+	gw := gzip.NewWriter(fh)
+	tw := tar.NewWriter(gw)
+	for name, contents := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(contents)),
+		}))
+		_, err := tw.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+
+	return path
+}
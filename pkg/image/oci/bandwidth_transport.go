@@ -0,0 +1,33 @@
+package oci
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// bandwidthTransport limits the rate at which responses are downloaded from a registry according to the bandwidth
+// limiter of the request context (see image.BandwidthLimiter). All responses are limited, since blobs (e.g. layers)
+// are commonly served through redirects to other hosts (e.g. a CDN).
+type bandwidthTransport struct {
+	inner http.RoundTripper
+}
+
+func (t *bandwidthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet {
+		return resp, err
+	}
+
+	limiter := image.BandwidthLimiterFromContext(req.Context())
+	if limiter != nil {
+		resp.Body = &limitedBody{Reader: limiter.Reader(req.Context(), resp.Body), Closer: resp.Body}
+	}
+	return resp, nil
+}
+
+type limitedBody struct {
+	io.Reader
+	io.Closer
+}
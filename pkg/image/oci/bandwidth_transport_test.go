@@ -0,0 +1,57 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func Test_bandwidthTransport(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 300)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(ts.Close)
+
+	start := time.Now()
+	clock := image.NewManualClock(start)
+	ctx := image.ContextWithClock(context.Background(), clock)
+	ctx = image.ContextWithBandwidthLimiter(ctx, image.NewBandwidthLimiter(100))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/v2/test/blobs/sha256:abc", nil)
+	require.NoError(t, err)
+	resp, err := (&http.Client{Transport: &bandwidthTransport{inner: http.DefaultTransport}}).Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	done := make(chan []byte)
+	go func() {
+		got, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		done <- got
+	}()
+
+	var got []byte
+	require.Eventually(t, func() bool {
+		select {
+		case got = <-done:
+			return true
+		default:
+			clock.Advance(10 * time.Millisecond)
+			return false
+		}
+	}, 10*time.Second, time.Millisecond)
+
+	assert.Equal(t, data, got)
+	// the first 100 bytes are a burst, the remaining 200 bytes take 2 seconds
+	assert.GreaterOrEqual(t, clock.Now().Sub(start), 2*time.Second)
+}
@@ -0,0 +1,134 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+const Bazel image.Source = image.BazelSource
+
+// NewBazelProvider creates a new provider for OCI layout directories produced by Bazel rules (e.g. the output of a
+// rules_oci oci_image target under bazel-bin or bazel-out). These layouts are typically reached through convenience
+// symlinks and are composed of blob farms where each blob is a symlink into the Bazel output tree.
+func NewBazelProvider(tmpDirGen *file.TempDirGenerator, path string, platform *image.Platform) image.Provider {
+	return &bazelImageProvider{
+		tmpDirGen: tmpDirGen,
+		path:      path,
+		platform:  platform,
+	}
+}
+
+// bazelImageProvider is an image.Provider for an OCI layout directory produced by a Bazel build.
+type bazelImageProvider struct {
+	tmpDirGen *file.TempDirGenerator
+	path      string
+	platform  *image.Platform
+}
+
+func (p *bazelImageProvider) Name() string {
+	return Bazel
+}
+
+// Provide an image object that represents the Bazel-built OCI layout at the configured path.
+func (p *bazelImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	// resolve any convenience symlinks (e.g. bazel-bin) to the real output directory
+	resolved, err := filepath.EvalSymlinks(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve Bazel output path %q: %w", p.path, err)
+	}
+
+	if err := validateBlobFarm(resolved); err != nil {
+		return nil, err
+	}
+
+	log.WithFields("path", resolved).Debug("providing image from Bazel OCI layout")
+
+	return (&directoryImageProvider{
+		tmpDirGen: p.tmpDirGen,
+		path:      resolved,
+		platform:  p.platform,
+	}).Provide(ctx)
+}
+
+// validateBlobFarm ensures that every blob within the OCI layout resolves to a regular file within the Bazel output
+// tree (see blobRoot). Blobs may be symlinks (possibly chained) into the Bazel output tree; dangling, cyclic, non-file,
+// or escaping links are reported up front instead of failing mid-read (or reading arbitrary files from the host).
+func validateBlobFarm(layoutDir string) error {
+	if _, err := os.Stat(filepath.Join(layoutDir, "index.json")); err != nil {
+		return fmt.Errorf("not an OCI layout (no index.json found in %q): %w", layoutDir, err)
+	}
+
+	root := blobRoot(layoutDir)
+
+	blobsDir := filepath.Join(layoutDir, "blobs")
+	algorithms, err := os.ReadDir(blobsDir)
+	if err != nil {
+		return fmt.Errorf("unable to read OCI layout blobs in %q: %w", layoutDir, err)
+	}
+
+	for _, algorithm := range algorithms {
+		algorithmDir := filepath.Join(blobsDir, algorithm.Name())
+		blobs, err := os.ReadDir(algorithmDir)
+		if err != nil {
+			return fmt.Errorf("unable to read OCI layout blobs in %q: %w", algorithmDir, err)
+		}
+
+		for _, blob := range blobs {
+			// note: blobs that are not symlinks are resolved as well, since the blob directories may be symlinks
+			if err := validateBlobLink(root, filepath.Join(algorithmDir, blob.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// blobRoot returns the directory that all blobs of the given (resolved) layout directory must resolve within: the
+// Bazel output base when the layout is within an execroot, the workspace holding the bazel-out tree when the layout is
+// within one, otherwise the layout directory itself.
+func blobRoot(layoutDir string) string {
+	root := layoutDir
+	for dir := layoutDir; ; dir = filepath.Dir(dir) {
+		switch filepath.Base(dir) {
+		case "execroot":
+			// the output base holds the execroot and all external repositories
+			return filepath.Dir(dir)
+		case "bazel-out":
+			if root == layoutDir {
+				root = filepath.Dir(dir)
+			}
+		}
+		if filepath.Dir(dir) == dir {
+			return root
+		}
+	}
+}
+
+func validateBlobLink(root, path string) error {
+	// note: EvalSymlinks detects link cycles (returning an error after too many links)
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("unable to resolve blob symlink %q: %w", path, err)
+	}
+
+	if target != root && !strings.HasPrefix(target, root+string(os.PathSeparator)) {
+		return fmt.Errorf("blob %q resolves to %q outside of the Bazel output tree %q", path, target, root)
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return fmt.Errorf("unable to stat blob %q (resolved to %q): %w", path, target, err)
+	}
+
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("blob %q resolves to a non-regular file %q", path, target)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package oci
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func TestBazelProvider_Provide(t *testing.T) {
+	tests := []struct {
+		name    string
+		dangle  bool
+		escape  bool
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:    "symlinked blob farm",
+			wantErr: require.NoError,
+		},
+		{
+			name:    "dangling blob symlink",
+			dangle:  true,
+			wantErr: require.Error,
+		},
+		{
+			name:    "blob symlink outside of the output tree",
+			escape:  true,
+			wantErr: require.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			casDir := filepath.Join(root, "cas")
+			if tt.escape {
+				casDir = t.TempDir()
+			}
+			outputDir := filepath.Join(root, "bazel-out", "k8-fastbuild", "bin", "image")
+			writeBazelLayout(t, outputDir, casDir)
+
+			// bazel-bin is a convenience symlink into bazel-out
+			bazelBin := filepath.Join(root, "bazel-bin")
+			require.NoError(t, os.Symlink(filepath.Join(root, "bazel-out", "k8-fastbuild", "bin"), bazelBin))
+
+			if tt.dangle {
+				require.NoError(t, os.RemoveAll(casDir))
+			}
+
+			tmpDirGen := file.NewTempDirGenerator("tempDir")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			img, err := NewBazelProvider(tmpDirGen, filepath.Join(bazelBin, "image"), nil).Provide(context.Background())
+			tt.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			assert.Len(t, img.Layers, 1)
+		})
+	}
+}
+
+// writeBazelLayout writes an OCI layout where every blob is a symlink into the given content-addressable store dir.
+func writeBazelLayout(t *testing.T, layoutDir, casDir string) {
+	t.Helper()
+
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+
+	p, err := layout.Write(layoutDir, empty.Index)
+	require.NoError(t, err)
+	require.NoError(t, p.AppendImage(img))
+
+	blobsDir := filepath.Join(layoutDir, "blobs", "sha256")
+	blobs, err := os.ReadDir(blobsDir)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(casDir, 0755))
+
+	for _, blob := range blobs {
+		blobPath := filepath.Join(blobsDir, blob.Name())
+		casPath := filepath.Join(casDir, blob.Name())
+		require.NoError(t, os.Rename(blobPath, casPath))
+		require.NoError(t, os.Symlink(casPath, blobPath))
+	}
+}
+
+func Test_blobRoot(t *testing.T) {
+	tests := []struct {
+		name      string
+		layoutDir string
+		want      string
+	}{
+		{
+			name:      "within an execroot",
+			layoutDir: filepath.FromSlash("/cache/output-base/execroot/_main/bazel-out/k8-fastbuild/bin/image"),
+			want:      filepath.FromSlash("/cache/output-base"),
+		},
+		{
+			name:      "within a bazel-out tree",
+			layoutDir: filepath.FromSlash("/workspace/bazel-out/k8-fastbuild/bin/image"),
+			want:      filepath.FromSlash("/workspace"),
+		},
+		{
+			name:      "outside of an output tree",
+			layoutDir: filepath.FromSlash("/layouts/image"),
+			want:      filepath.FromSlash("/layouts/image"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, blobRoot(tt.layoutDir))
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+const BuildKit image.Source = image.BuildKitSource
+
+// buildkitCacheConfigMediaType is the config media type of the cache manifests written by BuildKit cache exports, which
+// describe build cache layers rather than images.
+const buildkitCacheConfigMediaType = "application/vnd.buildkit.cacheconfig.v0"
+
+// maxManifestSize is the largest blob considered when searching a content store for manifests and indexes.
+const maxManifestSize = 4 << 20
+
+// NewBuildKitProvider creates a new provider for images within a BuildKit content store (e.g.
+// /var/lib/buildkit/runc-overlayfs/content) or a BuildKit local cache export (buildctl --export-cache type=local),
+// which hold image manifests without an OCI layout index referencing them. The content store is searched for all image
+// manifests and indexes (skipping build cache manifests), where attestation manifests (e.g. SLSA provenance) are
+// available as referrers of the image (see image.Image.Referrers). When more than one image is found, the image for the
+// platform is selected from the most recently written image (or index), and an image can be selected by manifest
+// digest with a "#" suffix (e.g. "/path#sha256:...").
+func NewBuildKitProvider(tmpDirGen *file.TempDirGenerator, path string, platform *image.Platform) image.Provider {
+	return &buildkitImageProvider{
+		tmpDirGen: tmpDirGen,
+		path:      path,
+		platform:  platform,
+	}
+}
+
+// buildkitImageProvider is an image.Provider for images within a BuildKit content store.
+type buildkitImageProvider struct {
+	tmpDirGen *file.TempDirGenerator
+	path      string
+	platform  *image.Platform
+}
+
+func (p *buildkitImageProvider) Name() string {
+	return BuildKit
+}
+
+// Provide an image object that represents an image within the BuildKit content store at the configured path.
+func (p *buildkitImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	provider, err := p.layoutProvider()
+	if err != nil {
+		return nil, err
+	}
+	return provider.Provide(ctx)
+}
+
+// ProvideIndex provides all images within the BuildKit content store that match the image selector (see
+// image.Index).
+func (p *buildkitImageProvider) ProvideIndex(ctx context.Context) (*image.Index, error) {
+	provider, err := p.layoutProvider()
+	if err != nil {
+		return nil, err
+	}
+	return provider.ProvideIndex(ctx)
+}
+
+// layoutProvider returns a provider for an OCI layout indexing all images within the content store, where the blobs of
+// the layout are the blobs of the content store.
+func (p *buildkitImageProvider) layoutProvider() (*directoryImageProvider, error) {
+	path, selector := splitImageSelector(p.path)
+
+	store, err := readContentStore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	layoutDir, err := p.tmpDirGen.NewDirectory("buildkit-layout")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.writeLayout(layoutDir); err != nil {
+		return nil, fmt.Errorf("unable to index BuildKit content store %q: %w", path, err)
+	}
+
+	log.WithFields("path", path, "images", len(store.roots)).Debug("providing image from BuildKit content store")
+
+	return &directoryImageProvider{
+		tmpDirGen: p.tmpDirGen,
+		path:      layoutDir,
+		platform:  p.platform,
+		selector:  selector,
+	}, nil
+}
+
+// contentStore is the set of image manifests and indexes found within a BuildKit content store.
+type contentStore struct {
+	blobsDir string
+	// roots are the manifests and indexes not referenced by any other index (most recently written first)
+	roots []v1.Descriptor
+}
+
+// contentBlob is a manifest or index found within the content store.
+type contentBlob struct {
+	descriptor v1.Descriptor
+	modTime    time.Time
+	references []v1.Hash
+	cache      bool
+}
+
+// readContentStore searches the sha256 blobs of the content store at the given path for image manifests and indexes.
+func readContentStore(path string) (*contentStore, error) {
+	blobsDir := filepath.Join(path, "blobs")
+	entries, err := os.ReadDir(filepath.Join(blobsDir, "sha256"))
+	if err != nil {
+		return nil, fmt.Errorf("not a BuildKit content store (unable to read blobs in %q): %w", path, err)
+	}
+
+	blobs := make(map[v1.Hash]contentBlob)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.Size() > maxManifestSize {
+			continue
+		}
+		digest := v1.Hash{Algorithm: "sha256", Hex: entry.Name()}
+		blob, ok := readContentBlob(filepath.Join(blobsDir, "sha256", entry.Name()), digest, info)
+		if ok {
+			blobs[digest] = blob
+		}
+	}
+
+	referenced := make(map[v1.Hash]struct{})
+	for _, blob := range blobs {
+		for _, ref := range blob.references {
+			referenced[ref] = struct{}{}
+		}
+	}
+
+	annotations := indexAnnotations(path)
+
+	var roots []contentBlob
+	for digest, blob := range blobs {
+		if _, ok := referenced[digest]; ok || blob.cache {
+			continue
+		}
+		if a, ok := annotations[digest]; ok {
+			blob.descriptor.Annotations = a
+		}
+		roots = append(roots, blob)
+	}
+
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("no images found in BuildKit content store %q (cache exports only hold build cache layers, export images with --output type=oci instead)", path)
+	}
+
+	sort.Slice(roots, func(i, j int) bool {
+		if !roots[i].modTime.Equal(roots[j].modTime) {
+			return roots[i].modTime.After(roots[j].modTime)
+		}
+		return roots[i].descriptor.Digest.String() < roots[j].descriptor.Digest.String()
+	})
+
+	store := &contentStore{blobsDir: blobsDir}
+	for _, root := range roots {
+		store.roots = append(store.roots, root.descriptor)
+	}
+	return store, nil
+}
+
+// readContentBlob reads the blob at the given path, when it is an image manifest or index.
+func readContentBlob(path string, digest v1.Hash, info os.FileInfo) (contentBlob, bool) {
+	content, err := os.ReadFile(path)
+	if err != nil || !bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		return contentBlob{}, false
+	}
+
+	var doc struct {
+		SchemaVersion int64           `json:"schemaVersion"`
+		MediaType     types.MediaType `json:"mediaType"`
+		Config        *v1.Descriptor  `json:"config"`
+		Manifests     []v1.Descriptor `json:"manifests"`
+	}
+	// note: image configs (and other JSON blobs) are never schema version 2
+	if err := json.Unmarshal(content, &doc); err != nil || doc.SchemaVersion != 2 {
+		return contentBlob{}, false
+	}
+
+	blob := contentBlob{
+		descriptor: v1.Descriptor{MediaType: doc.MediaType, Digest: digest, Size: info.Size()},
+		modTime:    info.ModTime(),
+	}
+
+	switch {
+	case doc.Manifests != nil && (doc.MediaType == "" || doc.MediaType.IsIndex()):
+		if blob.descriptor.MediaType == "" {
+			blob.descriptor.MediaType = types.OCIImageIndex
+		}
+		for _, m := range doc.Manifests {
+			blob.references = append(blob.references, m.Digest)
+			blob.cache = blob.cache || m.MediaType == buildkitCacheConfigMediaType
+		}
+	case doc.Config != nil && doc.Config.Digest.Hex != "" && (doc.MediaType == "" || doc.MediaType.IsImage()):
+		if blob.descriptor.MediaType == "" {
+			blob.descriptor.MediaType = types.OCIManifestSchema1
+		}
+		blob.cache = doc.Config.MediaType == buildkitCacheConfigMediaType
+	default:
+		return contentBlob{}, false
+	}
+	return blob, true
+}
+
+// indexAnnotations returns the annotations of the entries of the OCI layout index at the given path by digest (e.g.
+// the reference names of a local cache export), if there is an index.
+func indexAnnotations(path string) map[v1.Hash]map[string]string {
+	index, err := layout.ImageIndexFromPath(path)
+	if err != nil {
+		return nil
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil
+	}
+
+	annotations := make(map[v1.Hash]map[string]string)
+	for _, desc := range indexManifest.Manifests {
+		if len(desc.Annotations) > 0 {
+			annotations[desc.Digest] = desc.Annotations
+		}
+	}
+	return annotations
+}
+
+// writeLayout writes an OCI layout to the given directory that indexes all root manifests of the content store, where
+// the blobs of the layout link to the blobs of the content store.
+func (s *contentStore) writeLayout(dir string) error {
+	blobsDir, err := filepath.Abs(s.blobsDir)
+	if err != nil {
+		return err
+	}
+	if err := os.Symlink(blobsDir, filepath.Join(dir, "blobs")); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0o600); err != nil {
+		return err
+	}
+
+	index, err := json.Marshal(v1.IndexManifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIImageIndex,
+		Manifests:     s.roots,
+	})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "index.json"), index, 0o600)
+}
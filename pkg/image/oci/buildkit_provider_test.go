@@ -0,0 +1,185 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func TestBuildKitProvider_Provide(t *testing.T) {
+	older, err := random.Image(64, 1)
+	require.NoError(t, err)
+	olderDigest, err := older.Digest()
+	require.NoError(t, err)
+
+	img, err := random.Image(64, 2)
+	require.NoError(t, err)
+	imgDigest, err := img.Digest()
+	require.NoError(t, err)
+
+	// emulates the provenance attestation manifest attached by buildkit to the image index
+	provenance := static.NewLayer([]byte(`{"_type":"https://in-toto.io/Statement/v0.1"}`), "application/vnd.in-toto+json")
+	attestation, err := mutate.AppendLayers(empty.Image, provenance)
+	require.NoError(t, err)
+	attestationDigest, err := attestation.Digest()
+	require.NoError(t, err)
+
+	amd64 := v1.Platform{OS: "linux", Architecture: "amd64"}
+	unknown := v1.Platform{OS: "unknown", Architecture: "unknown"}
+	index := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: &amd64}},
+		mutate.IndexAddendum{Add: attestation, Descriptor: v1.Descriptor{
+			Platform: &unknown,
+			Annotations: map[string]string{
+				image.DockerReferenceTypeAnnotation:   image.DockerAttestationManifestType,
+				image.DockerReferenceDigestAnnotation: imgDigest.String(),
+			},
+		}},
+	)
+
+	tests := []struct {
+		name     string
+		setup    func(t *testing.T, p layout.Path)
+		selector string
+		want     v1.Hash
+		wantErr  require.ErrorAssertionFunc
+	}{
+		{
+			name: "image index with attestations",
+			setup: func(t *testing.T, p layout.Path) {
+				require.NoError(t, p.AppendIndex(index))
+			},
+			want: imgDigest,
+		},
+		{
+			name: "most recently written image",
+			setup: func(t *testing.T, p layout.Path) {
+				require.NoError(t, p.AppendImage(older))
+				require.NoError(t, p.AppendIndex(index))
+				past := time.Now().Add(-time.Hour)
+				require.NoError(t, os.Chtimes(blobPath(p, olderDigest), past, past))
+			},
+			want: imgDigest,
+		},
+		{
+			name: "select image by digest",
+			setup: func(t *testing.T, p layout.Path) {
+				require.NoError(t, p.AppendImage(older))
+				require.NoError(t, p.AppendIndex(index))
+				past := time.Now().Add(-time.Hour)
+				require.NoError(t, os.Chtimes(blobPath(p, olderDigest), past, past))
+			},
+			selector: olderDigest.String(),
+			want:     olderDigest,
+		},
+		{
+			name: "ignore build cache manifests",
+			setup: func(t *testing.T, p layout.Path) {
+				require.NoError(t, p.AppendImage(older))
+				writeCacheManifest(t, p)
+			},
+			want: olderDigest,
+		},
+		{
+			name: "only build cache manifests",
+			setup: func(t *testing.T, p layout.Path) {
+				writeCacheManifest(t, p)
+			},
+			wantErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.ErrorContains(t, err, "no images found in BuildKit content store")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+
+			// content stores hold blobs only, nothing references the images
+			store := t.TempDir()
+			p, err := layout.Write(store, empty.Index)
+			require.NoError(t, err)
+			tt.setup(t, p)
+			require.NoError(t, os.Remove(filepath.Join(store, "index.json")))
+			require.NoError(t, os.Remove(filepath.Join(store, "oci-layout")))
+
+			tmpDirGen := file.NewTempDirGenerator("tempDir")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			path := store
+			if tt.selector != "" {
+				path += "#" + tt.selector
+			}
+
+			img, err := NewBuildKitProvider(tmpDirGen, path, nil).Provide(context.Background())
+			tt.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { _ = img.Cleanup() })
+
+			assert.Equal(t, tt.want.String(), img.Metadata.ManifestDigest)
+
+			if tt.want != imgDigest {
+				return
+			}
+			referrers, err := img.Referrers(context.Background())
+			require.NoError(t, err)
+			require.Len(t, referrers, 1)
+			assert.Equal(t, attestationDigest, referrers[0].Descriptor.Digest)
+		})
+	}
+}
+
+func blobPath(p layout.Path, digest v1.Hash) string {
+	return filepath.Join(string(p), "blobs", digest.Algorithm, digest.Hex)
+}
+
+// writeCacheManifest writes a build cache manifest (as written by "buildctl build --export-cache type=local") and its
+// cache config as loose blobs.
+func writeCacheManifest(t *testing.T, p layout.Path) {
+	t.Helper()
+
+	config := []byte(`{"layers":[],"records":[]}`)
+	configDigest := writeContentBlob(t, p, config)
+
+	manifest, err := json.Marshal(v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIManifestSchema1,
+		Config: v1.Descriptor{
+			MediaType: buildkitCacheConfigMediaType,
+			Digest:    configDigest,
+			Size:      int64(len(config)),
+		},
+	})
+	require.NoError(t, err)
+	writeContentBlob(t, p, manifest)
+}
+
+func writeContentBlob(t *testing.T, p layout.Path, content []byte) v1.Hash {
+	t.Helper()
+
+	digest, _, err := v1.SHA256(bytes.NewReader(content))
+	require.NoError(t, err)
+	require.NoError(t, p.WriteBlob(digest, io.NopCloser(bytes.NewReader(content))))
+	return digest
+}
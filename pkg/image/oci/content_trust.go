@@ -0,0 +1,619 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/scylladb/go-set/strset"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// maxTrustMetadataSize limits the size of TUF metadata read from the notary server.
+const maxTrustMetadataSize = 5 << 20
+
+// releasesRole is the delegation role that 'docker trust sign' signs tags with, preferred over the targets role.
+const releasesRole = "targets/releases"
+
+// tufSigned is a TUF metadata document as served by a notary server.
+type tufSigned struct {
+	Signed     json.RawMessage `json:"signed"`
+	Signatures []tufSignature  `json:"signatures"`
+}
+
+type tufSignature struct {
+	KeyID  string `json:"keyid"`
+	Method string `json:"method"`
+	Sig    []byte `json:"sig"`
+}
+
+type tufKey struct {
+	Type  string `json:"keytype"`
+	Value struct {
+		Public []byte `json:"public"`
+	} `json:"keyval"`
+}
+
+type tufRole struct {
+	KeyIDs    []string `json:"keyids"`
+	Threshold int      `json:"threshold"`
+}
+
+type tufFileMeta struct {
+	Length int64             `json:"length"`
+	Hashes map[string][]byte `json:"hashes"`
+}
+
+// tufCommon holds the fields common to the signed portion of all TUF metadata.
+type tufCommon struct {
+	Type    string    `json:"_type"`
+	Version int       `json:"version"`
+	Expires time.Time `json:"expires"`
+}
+
+func (c tufCommon) common() tufCommon {
+	return c
+}
+
+type tufMetadata interface {
+	common() tufCommon
+}
+
+type tufRoot struct {
+	tufCommon
+	Keys  map[string]tufKey  `json:"keys"`
+	Roles map[string]tufRole `json:"roles"`
+}
+
+// tufSnapshot is the signed portion of both the timestamp and snapshot metadata.
+type tufSnapshot struct {
+	tufCommon
+	Meta map[string]tufFileMeta `json:"meta"`
+}
+
+type tufTargets struct {
+	tufCommon
+	Targets     map[string]tufFileMeta `json:"targets"`
+	Delegations struct {
+		Keys  map[string]tufKey `json:"keys"`
+		Roles []tufDelegation   `json:"roles"`
+	} `json:"delegations"`
+}
+
+type tufDelegation struct {
+	tufRole
+	Name  string   `json:"name"`
+	Paths []string `json:"paths"`
+}
+
+// resolveTrustedDigest resolves the tag to the manifest digest signed within the Docker Content Trust (Notary v1) data
+// of the repository, failing with image.ErrContentTrust when the tag is not signed. The verified root metadata is
+// persisted within the trust directory (when given), and trusted for later verifications of the repository.
+func resolveTrustedDigest(ctx context.Context, tag name.Tag, trust image.ContentTrustRegistry, trustDir string, registryOptions image.RegistryOptions) (name.Digest, error) {
+	client, err := newNotaryClient(ctx, tag.Context(), trust.Server, registryOptions)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("unable to connect to notary server %q: %w", trust.Server, err)
+	}
+
+	var rootPath string
+	var trustedRoot []byte
+	if trustDir != "" {
+		rootPath = trustedRootPath(trustDir, client.gun)
+		if trustedRoot, err = os.ReadFile(rootPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return name.Digest{}, fmt.Errorf("unable to read trusted root metadata: %w", err)
+		}
+	}
+
+	digest, root, err := client.trustedDigest(tag.TagStr(), trust.RootKeyIDs, trustedRoot, image.ClockFromContext(ctx).Now())
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("%w: %s: %w", image.ErrContentTrust, tag, err)
+	}
+	log.WithFields("tag", tag.String(), "digest", digest).Debug("verified content trust of tag")
+
+	if rootPath != "" && !bytes.Equal(root, trustedRoot) {
+		if err := writeTrustedRoot(rootPath, root); err != nil {
+			return name.Digest{}, fmt.Errorf("unable to persist trusted root metadata: %w", err)
+		}
+	}
+	return tag.Context().Digest(digest), nil
+}
+
+// trustedRootPath returns the path of the trusted root metadata of the given repository (GUN) within the trust
+// directory (the same layout as the docker trust directory).
+func trustedRootPath(trustDir, gun string) string {
+	return filepath.Join(trustDir, "tuf", filepath.FromSlash(gun), "metadata", "root.json")
+}
+
+func writeTrustedRoot(path string, raw []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	// note: the metadata is written to a temporary file first, such that a partially written root is never trusted
+	tmp, err := os.CreateTemp(filepath.Dir(path), "root-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// notaryClient fetches the TUF metadata of a single repository (GUN) from a notary server.
+type notaryClient struct {
+	ctx    context.Context
+	client *http.Client
+	gun    string
+	base   string
+}
+
+func newNotaryClient(ctx context.Context, repo name.Repository, server string, registryOptions image.RegistryOptions) (*notaryClient, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("notary server URL must use https")
+	}
+
+	registry, err := name.NewRegistry(u.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	// the trust data of Docker Hub repositories is named after the canonical registry name, not the index host
+	gun := repo.Name()
+	if repo.RegistryStr() == name.DefaultRegistry {
+		gun = "docker.io/" + repo.RepositoryStr()
+	}
+
+	var base http.RoundTripper = remote.DefaultTransport
+	if tlsConfig, err := registryOptions.TLSConfig(u.Host); err != nil {
+		log.Warnf("unable to configure TLS transport for notary server: %+v", err)
+	} else if tlsConfig != nil {
+		base = getTransport(tlsConfig)
+	}
+
+	rt, err := transport.NewWithContext(ctx, registry, notaryAuthenticator(ctx, repo, u.Host, registryOptions), base, []string{fmt.Sprintf("repository:%s:pull", gun)})
+	if err != nil {
+		return nil, err
+	}
+
+	return &notaryClient{
+		ctx:    ctx,
+		client: &http.Client{Transport: rt},
+		gun:    gun,
+		base:   fmt.Sprintf("%s/v2/%s/_trust/tuf/", strings.TrimSuffix(server, "/"), gun),
+	}, nil
+}
+
+// notaryAuthenticator returns the credentials for the notary server, which are the credentials of the registry unless
+// the notary server has credentials of its own.
+func notaryAuthenticator(ctx context.Context, repo name.Repository, server string, registryOptions image.RegistryOptions) authn.Authenticator {
+	if auth := registryOptions.Authenticator(server); auth != nil {
+		return auth
+	}
+	if auth := registryOptions.Authenticator(repo.RegistryStr()); auth != nil {
+		return auth
+	}
+
+	auth, err := registryOptions.ResolveKeychain(ctx).Resolve(repo)
+	if err != nil {
+		log.Debugf("unable to resolve credentials for notary server %q: %+v", server, err)
+		return authn.Anonymous
+	}
+	return auth
+}
+
+func (c *notaryClient) fetch(role string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, c.base+role+".json", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusOK); err != nil {
+		return nil, fmt.Errorf("unable to fetch %s metadata: %w", role, err)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxTrustMetadataSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > maxTrustMetadataSize {
+		return nil, fmt.Errorf("%s metadata exceeds %d bytes", role, maxTrustMetadataSize)
+	}
+	return raw, nil
+}
+
+// trustedDigest verifies the chain of TUF metadata (root, timestamp, snapshot, targets, and the releases delegation)
+// and returns the digest the tag is signed with, along with the verified root metadata.
+func (c *notaryClient) trustedDigest(tag string, rootKeyIDs []string, trustedRoot []byte, now time.Time) (string, []byte, error) {
+	rawRoot, err := c.fetch("root")
+	if err != nil {
+		return "", nil, err
+	}
+
+	root, err := verifyRoot(rawRoot, rootKeyIDs, trustedRoot, now)
+	if err != nil {
+		return "", nil, err
+	}
+
+	digest, err := c.signedDigest(tag, root, now)
+	if err != nil {
+		return "", nil, err
+	}
+	return digest, rawRoot, nil
+}
+
+// verifyRoot verifies the root metadata, which is signed by the root keys within itself, and must additionally be
+// signed by any of the pinned root keys (when given), and by the root keys of the trusted root metadata (when given,
+// such that the root keys may only be rotated by the holders of the trusted keys).
+func verifyRoot(rawRoot []byte, rootKeyIDs []string, trustedRoot []byte, now time.Time) (*tufRoot, error) {
+	var unverified tufRoot
+	if err := parseSigned(rawRoot, &unverified); err != nil {
+		return nil, fmt.Errorf("unable to parse root metadata: %w", err)
+	}
+	var root tufRoot
+	signers, err := verifyMetadata(rawRoot, "root", unverified.Roles["root"], unverified.Keys, now, &root)
+	if err != nil {
+		return nil, err
+	}
+	if len(rootKeyIDs) > 0 && !signedByPinnedKey(signers, root.Keys, rootKeyIDs) {
+		return nil, fmt.Errorf("root metadata is not signed by any of the pinned root keys")
+	}
+
+	if trustedRoot == nil {
+		return &root, nil
+	}
+
+	// note: the trusted root metadata was verified when it was first trusted (and may have expired since)
+	var trusted tufRoot
+	if err := parseSigned(trustedRoot, &trusted); err != nil {
+		return nil, fmt.Errorf("unable to parse trusted root metadata: %w", err)
+	}
+	switch {
+	case root.Version < trusted.Version:
+		return nil, fmt.Errorf("root metadata version %d is older than the trusted version %d", root.Version, trusted.Version)
+	case root.Version == trusted.Version:
+		same, err := sameSigned(rawRoot, trustedRoot)
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			return nil, fmt.Errorf("root metadata differs from the trusted root metadata of the same version %d", root.Version)
+		}
+	default:
+		if _, err := verifyMetadata(rawRoot, "root", trusted.Roles["root"], trusted.Keys, now, &tufRoot{}); err != nil {
+			return nil, fmt.Errorf("root metadata rotation is not signed by the trusted root keys: %w", err)
+		}
+	}
+	return &root, nil
+}
+
+// sameSigned reports whether the signed portions of both metadata documents are the same.
+func sameSigned(a, b []byte) (bool, error) {
+	var docA, docB tufSigned
+	if err := json.Unmarshal(a, &docA); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(b, &docB); err != nil {
+		return false, err
+	}
+	canonicalA, err := canonicalJSON(docA.Signed)
+	if err != nil {
+		return false, err
+	}
+	canonicalB, err := canonicalJSON(docB.Signed)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(canonicalA, canonicalB), nil
+}
+
+// signedDigest verifies the metadata signed by the roles of the (verified) root metadata, and returns the digest the
+// tag is signed with.
+func (c *notaryClient) signedDigest(tag string, root *tufRoot, now time.Time) (string, error) {
+	var timestamp tufSnapshot
+	if err := c.fetchVerified("timestamp", nil, root.Roles["timestamp"], root.Keys, now, &timestamp); err != nil {
+		return "", err
+	}
+
+	var snapshot tufSnapshot
+	if err := c.fetchVerified("snapshot", timestamp.Meta, root.Roles["snapshot"], root.Keys, now, &snapshot); err != nil {
+		return "", err
+	}
+
+	var targets tufTargets
+	if err := c.fetchVerified("targets", snapshot.Meta, root.Roles["targets"], root.Keys, now, &targets); err != nil {
+		return "", err
+	}
+
+	if _, ok := snapshot.Meta[releasesRole]; ok {
+		for _, delegation := range targets.Delegations.Roles {
+			if delegation.Name != releasesRole || !delegation.allows(tag) {
+				continue
+			}
+			var releases tufTargets
+			if err := c.fetchVerified(releasesRole, snapshot.Meta, delegation.tufRole, targets.Delegations.Keys, now, &releases); err != nil {
+				return "", err
+			}
+			if target, ok := releases.Targets[tag]; ok {
+				return targetDigest(target)
+			}
+		}
+	}
+
+	if target, ok := targets.Targets[tag]; ok {
+		return targetDigest(target)
+	}
+	return "", fmt.Errorf("no trust data for tag %q", tag)
+}
+
+// fetchVerified fetches the metadata of the given role, ensuring it matches the file metadata of the role within the
+// referring metadata (unless nil) and is signed by the keys of the role.
+func (c *notaryClient) fetchVerified(role string, referring map[string]tufFileMeta, r tufRole, keys map[string]tufKey, now time.Time, into tufMetadata) error {
+	raw, err := c.fetch(role)
+	if err != nil {
+		return err
+	}
+	if referring != nil {
+		meta, ok := referring[role]
+		if !ok {
+			return fmt.Errorf("%s metadata is not referenced by the repository", role)
+		}
+		if err := verifyFileMeta(role, raw, meta); err != nil {
+			return err
+		}
+	}
+	_, err = verifyMetadata(raw, role, r, keys, now, into)
+	return err
+}
+
+func (d tufDelegation) allows(tag string) bool {
+	for _, p := range d.Paths {
+		if strings.HasPrefix(tag, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseSigned(raw []byte, into any) error {
+	var doc tufSigned
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+	return json.Unmarshal(doc.Signed, into)
+}
+
+// verifyMetadata ensures the metadata of the given role is signed by at least the threshold of the keys of the role,
+// is of the expected type, and is not expired, returning the IDs of the keys with valid signatures.
+func verifyMetadata(raw []byte, role string, r tufRole, keys map[string]tufKey, now time.Time, into tufMetadata) (*strset.Set, error) {
+	var doc tufSigned
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("unable to parse %s metadata: %w", role, err)
+	}
+	payload, err := canonicalJSON(doc.Signed)
+	if err != nil {
+		return nil, fmt.Errorf("unable to canonicalize %s metadata: %w", role, err)
+	}
+
+	roleKeys := strset.New(r.KeyIDs...)
+	signers := strset.New()
+	for _, sig := range doc.Signatures {
+		key, ok := keys[sig.KeyID]
+		if !roleKeys.Has(sig.KeyID) || !ok || signers.Has(sig.KeyID) {
+			continue
+		}
+		if err := key.verify(sig.KeyID, sig.Method, payload, sig.Sig); err != nil {
+			log.WithFields("role", role, "key", sig.KeyID).Tracef("invalid trust metadata signature: %+v", err)
+			continue
+		}
+		signers.Add(sig.KeyID)
+	}
+
+	threshold := max(r.Threshold, 1)
+	if signers.Size() < threshold {
+		return nil, fmt.Errorf("%s metadata has %d valid signatures (threshold %d)", role, signers.Size(), threshold)
+	}
+
+	if err := json.Unmarshal(doc.Signed, into); err != nil {
+		return nil, fmt.Errorf("unable to parse %s metadata: %w", role, err)
+	}
+	common := into.common()
+	if expected := metadataType(role); !strings.EqualFold(common.Type, expected) {
+		return nil, fmt.Errorf("%s metadata has unexpected type %q", role, common.Type)
+	}
+	if !common.Expires.After(now) {
+		return nil, fmt.Errorf("%s metadata expired at %s", role, common.Expires.Format(time.RFC3339))
+	}
+	return signers, nil
+}
+
+// metadataType returns the type of the metadata of the given role (delegations are targets metadata).
+func metadataType(role string) string {
+	switch role {
+	case "root", "timestamp", "snapshot":
+		return role
+	}
+	return "targets"
+}
+
+func verifyFileMeta(role string, raw []byte, meta tufFileMeta) error {
+	expected, ok := meta.Hashes["sha256"]
+	if !ok {
+		return fmt.Errorf("%s metadata has no sha256 hash", role)
+	}
+	if meta.Length > 0 && int64(len(raw)) != meta.Length {
+		return fmt.Errorf("%s metadata length mismatch: expected %d, got %d", role, meta.Length, len(raw))
+	}
+	if actual := sha256.Sum256(raw); !bytes.Equal(actual[:], expected) {
+		return fmt.Errorf("%s metadata hash mismatch: expected %x, got %x", role, expected, actual)
+	}
+	return nil
+}
+
+func targetDigest(target tufFileMeta) (string, error) {
+	hash, ok := target.Hashes["sha256"]
+	if !ok || len(hash) != sha256.Size {
+		return "", fmt.Errorf("target has no sha256 digest")
+	}
+	return "sha256:" + hex.EncodeToString(hash), nil
+}
+
+// signedByPinnedKey reports whether any of the signing root keys is pinned, by either its ID within the root metadata
+// or the canonical ID of its public key (as shown for certificate keys by 'docker trust inspect').
+func signedByPinnedKey(signers *strset.Set, keys map[string]tufKey, pinned []string) bool {
+	pinnedIDs := strset.New(pinned...)
+	for _, id := range signers.List() {
+		if pinnedIDs.Has(id) {
+			return true
+		}
+		if canonical, err := keys[id].canonicalID(); err == nil && pinnedIDs.Has(canonical) {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalJSON encodes the JSON value with sorted keys and without insignificant whitespace or HTML escaping, which
+// is the form TUF metadata is signed in.
+func canonicalJSON(raw []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// tufKeyID returns the ID of the key of the given type, which is the hash of the canonical JSON of the public key.
+func tufKeyID(keyType string, public []byte) string {
+	// note: maps are encoded with sorted keys, and base64 never needs escaping, thus this is already canonical
+	raw, _ := json.Marshal(map[string]any{
+		"keytype": keyType,
+		"keyval":  map[string]any{"private": nil, "public": public},
+	})
+	digest := sha256.Sum256(raw)
+	return hex.EncodeToString(digest[:])
+}
+
+// canonicalID returns the ID of the public key of the key, which differs from the key ID for certificate keys.
+func (k tufKey) canonicalID() (string, error) {
+	keyType, isCert := strings.CutSuffix(k.Type, "-x509")
+	if !isCert {
+		return tufKeyID(k.Type, k.Value.Public), nil
+	}
+	pub, err := k.publicKey()
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	return tufKeyID(keyType, der), nil
+}
+
+func (k tufKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Type {
+	case "ecdsa", "rsa":
+		return x509.ParsePKIXPublicKey(k.Value.Public)
+	case "ecdsa-x509", "rsa-x509":
+		certs, err := parseCertificates(k.Value.Public)
+		if err != nil {
+			return nil, err
+		}
+		if len(certs) == 0 {
+			return nil, fmt.Errorf("no certificate found")
+		}
+		return certs[0].PublicKey, nil
+	case "ed25519":
+		if len(k.Value.Public) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 key size %d", len(k.Value.Public))
+		}
+		return ed25519.PublicKey(k.Value.Public), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Type)
+}
+
+// verify checks the signature of the key with the given ID (which must be the ID of the key) over the payload.
+func (k tufKey) verify(id, method string, payload, sig []byte) error {
+	if actual := tufKeyID(k.Type, k.Value.Public); actual != id {
+		return fmt.Errorf("key ID mismatch: expected %s, got %s", id, actual)
+	}
+	pub, err := k.publicKey()
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(payload)
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		// notary ECDSA signatures are the concatenation of r and s rather than ASN.1 encoded
+		if method == "ecdsa" && len(sig)%2 == 0 {
+			r, s := new(big.Int).SetBytes(sig[:len(sig)/2]), new(big.Int).SetBytes(sig[len(sig)/2:])
+			if ecdsa.Verify(key, digest[:], r, s) {
+				return nil
+			}
+		}
+	case *rsa.PublicKey:
+		switch method {
+		case "rsapss":
+			if rsa.VerifyPSS(key, crypto.SHA256, digest[:], sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil {
+				return nil
+			}
+		case "rsapkcs1v15":
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil {
+				return nil
+			}
+		}
+	case ed25519.PublicKey:
+		if method == "ed25519" && ed25519.Verify(key, payload, sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid %s signature", method)
+}
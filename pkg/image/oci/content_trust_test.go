@@ -0,0 +1,379 @@
+package oci
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func Test_RegistryProvider_ContentTrust(t *testing.T) {
+	registryHost := makeRegistry(t)
+	registryOptions := image.RegistryOptions{InsecureUseHTTP: true, InsecureSkipTLSVerify: true}
+
+	// the tag was moved after the signed image was pushed
+	pushRandomRegistryImage(t, registryHost, "app", "v1")
+	signed := registryDigest(t, registryHost+"/app:v1").DigestStr()
+	pushRandomRegistryImage(t, registryHost, "app", "latest")
+	unsigned := registryDigest(t, registryHost+"/app:latest").DigestStr()
+
+	gun := registryHost + "/app"
+
+	tests := []struct {
+		name       string
+		data       testTrustData
+		tamper     func(n *testNotary)
+		policy     func(n *testNotary) image.ContentTrustPolicy
+		wantDigest string
+		wantErr    require.ErrorAssertionFunc
+	}{
+		{
+			name:       "tag signed within the releases delegation",
+			data:       testTrustData{releases: map[string]string{"latest": signed}},
+			wantDigest: signed,
+		},
+		{
+			name:       "tag signed within the targets role",
+			data:       testTrustData{targets: map[string]string{"latest": signed}},
+			wantDigest: signed,
+		},
+		{
+			name:       "releases delegation preferred over the targets role",
+			data:       testTrustData{targets: map[string]string{"latest": unsigned}, releases: map[string]string{"latest": signed}},
+			wantDigest: signed,
+		},
+		{
+			name:    "unsigned tag",
+			data:    testTrustData{releases: map[string]string{"v1": signed}},
+			wantErr: requireContentTrustError,
+		},
+		{
+			name: "tampered targets",
+			data: testTrustData{releases: map[string]string{"latest": signed}},
+			tamper: func(n *testNotary) {
+				n.files["targets"] = append(n.files["targets"], ' ')
+			},
+			wantErr: requireContentTrustError,
+		},
+		{
+			name: "targets signed by the wrong key",
+			data: testTrustData{releases: map[string]string{"latest": signed}},
+			tamper: func(n *testNotary) {
+				n.files["targets"] = n.sign(t, "targets", newTestKey(t))
+				n.resign(t, "snapshot", "timestamp")
+			},
+			wantErr: requireContentTrustError,
+		},
+		{
+			name:    "expired trust data",
+			data:    testTrustData{releases: map[string]string{"latest": signed}, expires: time.Now().Add(-time.Minute)},
+			wantErr: requireContentTrustError,
+		},
+		{
+			name: "pinned root key",
+			data: testTrustData{releases: map[string]string{"latest": signed}},
+			policy: func(n *testNotary) image.ContentTrustPolicy {
+				return image.ContentTrustPolicy{Registries: []image.ContentTrustRegistry{{Authority: registryHost, Server: n.url, RootKeyIDs: []string{n.rootKeyID}}}}
+			},
+			wantDigest: signed,
+		},
+		{
+			name: "other pinned root key",
+			data: testTrustData{releases: map[string]string{"latest": signed}},
+			policy: func(n *testNotary) image.ContentTrustPolicy {
+				return image.ContentTrustPolicy{Registries: []image.ContentTrustRegistry{{Authority: registryHost, Server: n.url, RootKeyIDs: []string{strings.Repeat("a", 64)}}}}
+			},
+			wantErr: requireContentTrustError,
+		},
+		{
+			name: "registry not within the policy",
+			data: testTrustData{},
+			policy: func(n *testNotary) image.ContentTrustPolicy {
+				return image.ContentTrustPolicy{TrustDir: t.TempDir(), Registries: []image.ContentTrustRegistry{{Authority: "registry.example.com", Server: n.url}}}
+			},
+			wantDigest: unsigned,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNotary(t, gun, tt.data)
+			if tt.tamper != nil {
+				tt.tamper(n)
+			}
+			policy := image.ContentTrustPolicy{TrustDir: t.TempDir(), Registries: []image.ContentTrustRegistry{{Authority: registryHost, Server: n.url}}}
+			if tt.policy != nil {
+				policy = tt.policy(n)
+			}
+			require.NoError(t, policy.Validate())
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+
+			generator := file.TempDirGenerator{}
+			defer generator.Cleanup()
+
+			ctx := image.ContextWithContentTrustPolicy(context.Background(), &policy)
+			provider := NewRegistryProvider(&generator, registryOptions, registryHost+"/app:latest", nil)
+			img, err := provider.Provide(ctx)
+			tt.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			defer img.Cleanup()
+			assert.Equal(t, []string{fmt.Sprintf("%s/app@%s", registryHost, tt.wantDigest)}, img.Metadata.RepoDigests)
+		})
+	}
+}
+
+func Test_RegistryProvider_ContentTrust_rootRotation(t *testing.T) {
+	registryHost := makeRegistry(t)
+	registryOptions := image.RegistryOptions{InsecureUseHTTP: true, InsecureSkipTLSVerify: true}
+
+	pushRandomRegistryImage(t, registryHost, "app", "latest")
+	signed := registryDigest(t, registryHost+"/app:latest").DigestStr()
+
+	n := newTestNotary(t, registryHost+"/app", testTrustData{releases: map[string]string{"latest": signed}})
+	policy := image.ContentTrustPolicy{TrustDir: t.TempDir(), Registries: []image.ContentTrustRegistry{{Authority: registryHost, Server: n.url}}}
+	require.NoError(t, policy.Validate())
+
+	provide := func(t *testing.T) error {
+		generator := file.TempDirGenerator{}
+		defer generator.Cleanup()
+
+		ctx := image.ContextWithContentTrustPolicy(context.Background(), &policy)
+		img, err := NewRegistryProvider(&generator, registryOptions, registryHost+"/app:latest", nil).Provide(ctx)
+		if err != nil {
+			return err
+		}
+		return img.Cleanup()
+	}
+	trustedRoot := func(t *testing.T) []byte {
+		raw, err := os.ReadFile(trustedRootPath(policy.TrustDir, registryHost+"/app"))
+		require.NoError(t, err)
+		return raw
+	}
+
+	// the first root seen is trusted from then on
+	require.NoError(t, provide(t))
+	first, firstKey := n.files["root"], n.keys["root"]
+	assert.Equal(t, first, trustedRoot(t))
+
+	// a different root of the same version is not trusted
+	n.rotateRoot(t, 1)
+	requireContentTrustError(t, provide(t))
+
+	// the root keys may be rotated by the holders of the trusted root keys
+	n.rotateRoot(t, 2, firstKey)
+	require.NoError(t, provide(t))
+	rotated := n.files["root"]
+	assert.Equal(t, rotated, trustedRoot(t))
+
+	// but not by anyone else (including the holders of keys rotated away)
+	n.rotateRoot(t, 3, firstKey)
+	requireContentTrustError(t, provide(t))
+	assert.Equal(t, rotated, trustedRoot(t))
+
+	// and older roots are never trusted again
+	n.files["root"] = first
+	n.resign(t, "snapshot", "timestamp")
+	requireContentTrustError(t, provide(t))
+	assert.Equal(t, rotated, trustedRoot(t))
+}
+
+func Test_canonicalJSON(t *testing.T) {
+	actual, err := canonicalJSON([]byte(`{ "b": [1.50, 2e3], "a": {"y": "<&>", "x": null} }`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":{"x":null,"y":"<&>"},"b":[1.50,2e3]}`, string(actual))
+}
+
+func requireContentTrustError(t require.TestingT, err error, _ ...interface{}) {
+	require.ErrorIs(t, err, image.ErrContentTrust)
+}
+
+// testTrustData describes the tags signed within the trust data of a repository.
+type testTrustData struct {
+	// targets are the tags signed by the targets role
+	targets map[string]string
+	// releases are the tags signed by the releases delegation (no delegation when nil)
+	releases map[string]string
+	// expires is when all metadata expires (an hour from now when zero)
+	expires time.Time
+}
+
+// testNotary is a notary server serving the signed trust data of a single repository.
+type testNotary struct {
+	url       string
+	rootKeyID string
+	keys      map[string]testKey
+	signed    map[string]any
+	files     map[string][]byte
+}
+
+func newTestNotary(t *testing.T, gun string, data testTrustData) *testNotary {
+	t.Helper()
+
+	n := &testNotary{
+		keys:   map[string]testKey{},
+		signed: map[string]any{},
+		files:  map[string][]byte{},
+	}
+	roleKeys := map[string]any{}
+	roles := map[string]any{}
+	for _, role := range []string{"root", "targets", "snapshot", "timestamp", releasesRole} {
+		n.keys[role] = newTestKey(t)
+		if role == releasesRole {
+			continue
+		}
+		id, key := tufTestKey(t, n.keys[role])
+		roleKeys[id] = key
+		roles[role] = map[string]any{"keyids": []string{id}, "threshold": 1}
+	}
+	n.rootKeyID, _ = tufTestKey(t, n.keys["root"])
+
+	expires := data.expires
+	if expires.IsZero() {
+		expires = time.Now().Add(time.Hour)
+	}
+
+	n.signed["root"] = map[string]any{"_type": "Root", "version": 1, "expires": expires, "keys": roleKeys, "roles": roles}
+
+	targets := map[string]any{"_type": "Targets", "expires": expires, "targets": testTargets(t, data.targets)}
+	if data.releases != nil {
+		id, key := tufTestKey(t, n.keys[releasesRole])
+		targets["delegations"] = map[string]any{
+			"keys":  map[string]any{id: key},
+			"roles": []any{map[string]any{"name": releasesRole, "keyids": []string{id}, "threshold": 1, "paths": []string{""}}},
+		}
+		n.signed[releasesRole] = map[string]any{"_type": "Targets", "expires": expires, "targets": testTargets(t, data.releases)}
+		n.files[releasesRole] = n.sign(t, releasesRole, n.keys[releasesRole])
+	}
+	n.signed["targets"] = targets
+	n.signed["snapshot"] = map[string]any{"_type": "Snapshot", "expires": expires}
+	n.signed["timestamp"] = map[string]any{"_type": "Timestamp", "expires": expires}
+
+	n.files["root"] = n.sign(t, "root", n.keys["root"])
+	n.files["targets"] = n.sign(t, "targets", n.keys["targets"])
+	n.resign(t, "snapshot", "timestamp")
+
+	prefix := fmt.Sprintf("/v2/%s/_trust/tuf/", gun)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		raw, ok := n.files[strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, prefix), ".json")]
+		if !ok || !strings.HasPrefix(r.URL.Path, prefix) {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(raw)
+	}))
+	t.Cleanup(server.Close)
+	n.url = server.URL
+	return n
+}
+
+// resign updates the snapshot and timestamp metadata (in order) to refer to the current metadata files.
+func (n *testNotary) resign(t *testing.T, roles ...string) {
+	t.Helper()
+	for _, role := range roles {
+		referenced := []string{"snapshot"}
+		if role == "snapshot" {
+			referenced = []string{"root", "targets", releasesRole}
+		}
+		meta := map[string]any{}
+		for _, r := range referenced {
+			if raw, ok := n.files[r]; ok {
+				meta[r] = testFileMeta(raw)
+			}
+		}
+		n.signed[role].(map[string]any)["meta"] = meta
+		n.files[role] = n.sign(t, role, n.keys[role])
+	}
+}
+
+// rotateRoot replaces the root key with a new key within root metadata of the given version, which is signed by the new
+// key and the given (previous) keys.
+func (n *testNotary) rotateRoot(t *testing.T, version int, signers ...testKey) {
+	t.Helper()
+
+	root := n.signed["root"].(map[string]any)
+	keys := root["keys"].(map[string]any)
+	delete(keys, n.rootKeyID)
+
+	n.keys["root"] = newTestKey(t)
+	id, key := tufTestKey(t, n.keys["root"])
+	keys[id] = key
+	n.rootKeyID = id
+
+	root["version"] = version
+	root["roles"].(map[string]any)["root"] = map[string]any{"keyids": []string{id}, "threshold": 1}
+	n.files["root"] = n.sign(t, "root", append([]testKey{n.keys["root"]}, signers...)...)
+	n.resign(t, "snapshot", "timestamp")
+}
+
+// sign returns the metadata document of the role signed by the given keys (formatted differently than the signed form).
+func (n *testNotary) sign(t *testing.T, role string, keys ...testKey) []byte {
+	t.Helper()
+
+	signed, err := json.Marshal(n.signed[role])
+	require.NoError(t, err)
+
+	digest := sha256.Sum256(signed)
+	var signatures []any
+	for _, key := range keys {
+		r, s, err := ecdsa.Sign(rand.Reader, key.PrivateKey, digest[:])
+		require.NoError(t, err)
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+
+		id, _ := tufTestKey(t, key)
+		signatures = append(signatures, map[string]any{"keyid": id, "method": "ecdsa", "sig": sig})
+	}
+
+	raw, err := json.MarshalIndent(map[string]any{
+		"signed":     json.RawMessage(signed),
+		"signatures": signatures,
+	}, "", "  ")
+	require.NoError(t, err)
+	return raw
+}
+
+func tufTestKey(t *testing.T, key testKey) (string, map[string]any) {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	return tufKeyID("ecdsa", der), map[string]any{"keytype": "ecdsa", "keyval": map[string]any{"private": nil, "public": der}}
+}
+
+func testTargets(t *testing.T, tags map[string]string) map[string]any {
+	t.Helper()
+	targets := map[string]any{}
+	for tag, digest := range tags {
+		hash, err := hex.DecodeString(strings.TrimPrefix(digest, "sha256:"))
+		require.NoError(t, err)
+		targets[tag] = map[string]any{"length": 1024, "hashes": map[string][]byte{"sha256": hash}}
+	}
+	return targets
+}
+
+func testFileMeta(raw []byte) map[string]any {
+	hash := sha256.Sum256(raw)
+	return map[string]any{"length": len(raw), "hashes": map[string][]byte{"sha256": hash[:]}}
+}
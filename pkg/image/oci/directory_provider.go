@@ -2,22 +2,40 @@ package oci
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"syscall"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/layout"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 
+	"github.com/anchore/stereoscope/internal/log"
 	"github.com/anchore/stereoscope/pkg/file"
 	"github.com/anchore/stereoscope/pkg/image"
 )
 
 const Directory image.Source = image.OciDirectorySource
 
-// NewDirectoryProvider creates a new provider instance for the specific image already at the given path.
+// containerdImageNameAnnotation is the full image name annotated on index entries by "ctr image export".
+const containerdImageNameAnnotation = "io.containerd.image.name"
+
+// NewDirectoryProvider creates a new provider instance for the specific image already at the given path. When the
+// layout holds more than one image, an image can be selected by reference name or manifest digest with a "#" suffix
+// (e.g. "/path#latest" or "/path#sha256:...") or with image.ContextWithImageSelector.
 func NewDirectoryProvider(tmpDirGen *file.TempDirGenerator, path string) image.Provider {
+	return NewPlatformDirectoryProvider(tmpDirGen, path, nil)
+}
+
+// NewPlatformDirectoryProvider creates a new provider for the OCI layout at the given path (see NewDirectoryProvider),
+// selecting the image for the given platform when the layout holds a multi-platform index.
+func NewPlatformDirectoryProvider(tmpDirGen *file.TempDirGenerator, path string, platform *image.Platform) image.Provider {
 	return &directoryImageProvider{
 		tmpDirGen: tmpDirGen,
 		path:      path,
+		platform:  platform,
 	}
 }
 
@@ -25,6 +43,8 @@ func NewDirectoryProvider(tmpDirGen *file.TempDirGenerator, path string) image.P
 type directoryImageProvider struct {
 	tmpDirGen *file.TempDirGenerator
 	path      string
+	platform  *image.Platform
+	selector  string
 }
 
 func (p *directoryImageProvider) Name() string {
@@ -32,15 +52,27 @@ func (p *directoryImageProvider) Name() string {
 }
 
 // Provide an image object that represents the OCI image as a directory.
-func (p *directoryImageProvider) Provide(_ context.Context) (*image.Image, error) {
-	pathObj, err := layout.FromPath(p.path)
+func (p *directoryImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	index, selector, err := p.readIndex(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("unable to read image from OCI directory path %q: %w", p.path, err)
+		return nil, err
 	}
 
-	index, err := layout.ImageIndexFromPath(p.path)
+	manifest, img, err := selectManifest(index, p.platform, selector)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse OCI directory index: %w", err)
+		return nil, err
+	}
+
+	return newLayoutImageProvider(p.tmpDirGen, index, *manifest, img).Provide(ctx)
+}
+
+// ProvideIndex provides all images within the OCI layout that match the image selector (see image.Index), including
+// the platform images of nested indexes. The digest and manifest of the index are only set when a single nested index
+// (e.g. a multi-platform image exported by buildx) holds all images.
+func (p *directoryImageProvider) ProvideIndex(ctx context.Context) (*image.Index, error) {
+	index, selector, err := p.readIndex(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	indexManifest, err := index.IndexManifest()
@@ -48,29 +80,89 @@ func (p *directoryImageProvider) Provide(_ context.Context) (*image.Image, error
 		return nil, fmt.Errorf("unable to parse OCI directory indexManifest: %w", err)
 	}
 
-	// for now, lets only support one image indexManifest (it is not clear how to handle multiple manifests)
-	if len(indexManifest.Manifests) != 1 {
-		if len(indexManifest.Manifests) == 0 {
-			return nil, fmt.Errorf("unexpected number of OCI directory manifests (found %d)", len(indexManifest.Manifests))
-		}
-		// if all the manifests have the same digest, then we can treat this as a single image
-		if !checkManifestDigestsEqual(indexManifest.Manifests) {
-			return nil, fmt.Errorf("unexpected number of OCI directory manifests (found %d)", len(indexManifest.Manifests))
+	candidates, err := selectCandidates(index, indexManifest, selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no readable image manifests found in OCI directory (found %d index entries)", len(indexManifest.Manifests))
+	}
+
+	out := &image.Index{}
+	if digest, raw, ok := nestedIndex(index, indexManifest, selector); ok {
+		out.Digest = digest.String()
+		out.RawManifest = raw
+	}
+
+	for _, c := range candidates {
+		c := c
+		out.Entries = append(out.Entries, image.NewIndexEntry(c.descriptor, image.PlatformFromV1(c.platform), &indexEntryProvider{
+			name:      Directory,
+			tmpDirGen: p.tmpDirGen,
+			newProvider: func(tmpDirGen *file.TempDirGenerator) image.Provider {
+				return newLayoutImageProvider(tmpDirGen, index, c.descriptor, c.image)
+			},
+		}))
+	}
+	return out, nil
+}
+
+// readIndex reads the index of the OCI layout, returning it along with the image selector to use (from the path, the
+// provider, or the context, in order of precedence).
+func (p *directoryImageProvider) readIndex(ctx context.Context) (v1.ImageIndex, string, error) {
+	path, selector := splitImageSelector(p.path)
+	if selector == "" {
+		selector = p.selector
+	}
+	if selector == "" {
+		selector = image.ImageSelectorFromContext(ctx)
+	}
+
+	if _, err := layout.FromPath(path); err != nil {
+		err = fmt.Errorf("unable to read image from OCI directory path %q: %w", path, err)
+		if errors.Is(err, os.ErrNotExist) && !errors.Is(err, syscall.ENOTDIR) {
+			// the directory exists (see image.Capabilities), but has no index
+			err = image.MarkError(err, image.ErrWrongArchiveFormat)
 		}
+		return nil, "", err
 	}
 
-	manifest := indexManifest.Manifests[0]
-	img, err := pathObj.Image(manifest.Digest)
+	index, err := layout.ImageIndexFromPath(path)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse OCI directory as an image: %w", err)
+		return nil, "", fmt.Errorf("unable to parse OCI directory index: %w", err)
 	}
+	return index, selector, nil
+}
+
+func newLayoutImageProvider(tmpDirGen *file.TempDirGenerator, index v1.ImageIndex, manifest v1.Descriptor, img v1.Image) *layoutImageProvider {
+	return &layoutImageProvider{
+		tmpDirGen: tmpDirGen,
+		index:     index,
+		manifest:  manifest,
+		image:     img,
+	}
+}
+
+// layoutImageProvider is an image.Provider for a single image manifest already selected within an OCI layout.
+type layoutImageProvider struct {
+	tmpDirGen *file.TempDirGenerator
+	index     v1.ImageIndex
+	manifest  v1.Descriptor
+	image     v1.Image
+}
+
+func (p *layoutImageProvider) Name() string {
+	return Directory
+}
 
+func (p *layoutImageProvider) Provide(ctx context.Context) (*image.Image, error) {
 	var metadata = []image.AdditionalMetadata{
-		image.WithManifestDigest(manifest.Digest.String()),
+		image.WithManifestDigest(p.manifest.Digest.String()),
+		image.WithReferrers(layoutReferrers(p.index)),
 	}
 
 	// make a best-effort attempt at getting the raw indexManifest
-	rawManifest, err := img.RawManifest()
+	rawManifest, err := p.image.RawManifest()
 	if err == nil {
 		metadata = append(metadata, image.WithManifest(rawManifest))
 	}
@@ -80,22 +172,225 @@ func (p *directoryImageProvider) Provide(_ context.Context) (*image.Image, error
 		return nil, err
 	}
 
-	out := image.New(img, p.tmpDirGen, contentTempDir, metadata...)
-	err = out.Read()
+	out := image.New(p.image, p.tmpDirGen, contentTempDir, metadata...)
+	err = out.ReadContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return out, err
 }
 
-func checkManifestDigestsEqual(manifests []v1.Descriptor) bool {
-	if len(manifests) < 1 {
-		return false
+// nestedIndex returns the digest and raw manifest of the single nested index that the (selected) entries of the given
+// layout index refer to, if any.
+func nestedIndex(index v1.ImageIndex, indexManifest *v1.IndexManifest, selector string) (v1.Hash, []byte, bool) {
+	var entries []v1.Descriptor
+	for _, desc := range indexManifest.Manifests {
+		if selector == "" || matchesSelector(desc, selector) {
+			entries = append(entries, desc)
+		}
+	}
+	if len(entries) != 1 || !entries[0].MediaType.IsIndex() {
+		return v1.Hash{}, nil, false
+	}
+
+	child, err := index.ImageIndex(entries[0].Digest)
+	if err != nil {
+		return v1.Hash{}, nil, false
+	}
+	raw, err := child.RawManifest()
+	if err != nil {
+		return v1.Hash{}, nil, false
+	}
+	return entries[0].Digest, raw, true
+}
+
+// manifestCandidate is a single image manifest found within an OCI index (possibly within a nested index).
+type manifestCandidate struct {
+	descriptor v1.Descriptor
+	image      v1.Image
+	platform   *v1.Platform
+}
+
+// splitImageSelector splits an image selector suffix (e.g. "#latest") from the given path, unless the path exists as-is.
+func splitImageSelector(path string) (string, string) {
+	idx := strings.LastIndex(path, "#")
+	if idx < 0 {
+		return path, ""
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path, ""
+	}
+	return path[:idx], path[idx+1:]
+}
+
+// selectManifest finds the single image within the given index that should be provided. Archives produced by tools
+// such as "ctr image export" or "nerdctl save" may reference a (nested) multi-platform index where only some of the
+// platform manifests are present on disk, thus any manifests with missing blobs are ignored. When a selector is given,
+// only the index entries with a matching reference name or digest are considered (see selectCandidates). When more
+// than one distinct image remains, the image matching the given platform (or the host platform when none is given) is
+// selected.
+func selectManifest(index v1.ImageIndex, platform *image.Platform, selector string) (*v1.Descriptor, v1.Image, error) {
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse OCI directory indexManifest: %w", err)
+	}
+
+	if len(indexManifest.Manifests) == 0 {
+		return nil, nil, fmt.Errorf("unexpected number of OCI directory manifests (found %d)", len(indexManifest.Manifests))
 	}
-	for _, m := range manifests {
-		if m.Digest != manifests[0].Digest {
-			return false
+
+	candidates, err := selectCandidates(index, indexManifest, selector)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, nil, fmt.Errorf("no readable image manifests found in OCI directory (found %d index entries)", len(indexManifest.Manifests))
+	case 1:
+		return &candidates[0].descriptor, candidates[0].image, nil
+	}
+
+	want := defaultPlatformIfNil(platform)
+	if want == nil {
+		return nil, nil, fmt.Errorf("unexpected number of OCI directory manifests (found %d) and no platform to select by", len(candidates))
+	}
+
+	var available []string
+	for i := range candidates {
+		c := &candidates[i]
+		if c.platform == nil {
+			continue
+		}
+		if want.Matches(c.platform.OS, c.platform.Architecture, c.platform.Variant) {
+			log.WithFields("platform", want.String(), "digest", c.descriptor.Digest.String()).Debug("selected image manifest from OCI index")
+			return &c.descriptor, c.image, nil
+		}
+		available = append(available, c.platform.String())
+	}
+
+	return nil, nil, image.MarkError(fmt.Errorf("no image found in OCI index for platform %q (available: %s)", want.String(), strings.Join(available, ", ")), image.ErrPlatformMismatch)
+}
+
+// selectCandidates returns the readable image manifests within the given index that match the selector. The selector
+// is matched against the reference name ("org.opencontainers.image.ref.name", or the full image name annotated by
+// containerd) and the digest of the top-level index entries, where entries referencing an index select all images
+// within it. A digest may also select an image manifest within a nested index. All images are returned when no
+// selector is given.
+func selectCandidates(index v1.ImageIndex, indexManifest *v1.IndexManifest, selector string) ([]manifestCandidate, error) {
+	if selector == "" {
+		return findManifestCandidates(index, indexManifest, nil), nil
+	}
+
+	var entries []v1.Descriptor
+	for _, desc := range indexManifest.Manifests {
+		if matchesSelector(desc, selector) {
+			entries = append(entries, desc)
 		}
 	}
-	return true
+
+	if len(entries) > 0 {
+		selected := *indexManifest
+		selected.Manifests = entries
+		return findManifestCandidates(index, &selected, nil), nil
+	}
+
+	var candidates []manifestCandidate
+	for _, c := range findManifestCandidates(index, indexManifest, nil) {
+		if c.descriptor.Digest.String() == selector {
+			candidates = append(candidates, c)
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates, nil
+	}
+
+	var available []string
+	for _, desc := range indexManifest.Manifests {
+		if name := desc.Annotations[ocispec.AnnotationRefName]; name != "" {
+			available = append(available, name)
+			continue
+		}
+		available = append(available, desc.Digest.String())
+	}
+	return nil, fmt.Errorf("no image found in OCI index for %q (available: %s)", selector, strings.Join(available, ", "))
+}
+
+// matchesSelector indicates the given index entry has the selector as its digest or reference name.
+func matchesSelector(desc v1.Descriptor, selector string) bool {
+	return desc.Digest.String() == selector || desc.Annotations[ocispec.AnnotationRefName] == selector || desc.Annotations[containerdImageNameAnnotation] == selector
+}
+
+// findManifestCandidates recursively collects all image manifests (deduplicated by digest) that are readable within the
+// given index.
+func findManifestCandidates(index v1.ImageIndex, indexManifest *v1.IndexManifest, seen map[v1.Hash]struct{}) []manifestCandidate {
+	if seen == nil {
+		seen = make(map[v1.Hash]struct{})
+	}
+
+	var candidates []manifestCandidate
+	for _, desc := range indexManifest.Manifests {
+		if _, ok := seen[desc.Digest]; ok {
+			continue
+		}
+		seen[desc.Digest] = struct{}{}
+
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := index.ImageIndex(desc.Digest)
+			if err != nil {
+				log.WithFields("digest", desc.Digest.String(), "error", err).Trace("skipping unreadable nested OCI index")
+				continue
+			}
+			childManifest, err := child.IndexManifest()
+			if err != nil {
+				log.WithFields("digest", desc.Digest.String(), "error", err).Trace("skipping unreadable nested OCI index")
+				continue
+			}
+			candidates = append(candidates, findManifestCandidates(child, childManifest, seen)...)
+
+		case desc.ArtifactType != "", image.IsAttestationManifest(desc, nil):
+			// referrer artifacts (signatures, attestations, etc.) may be co-located with the image they describe
+			log.WithFields("digest", desc.Digest.String(), "artifactType", desc.ArtifactType).Trace("skipping OCI artifact manifest")
+
+		case desc.MediaType.IsImage():
+			img, err := index.Image(desc.Digest)
+			if err != nil {
+				log.WithFields("digest", desc.Digest.String(), "error", err).Trace("skipping unreadable OCI image manifest")
+				continue
+			}
+			// the manifest blob may not have been exported (e.g. "ctr image export" without --all-platforms)
+			manifest, err := img.Manifest()
+			if err != nil {
+				log.WithFields("digest", desc.Digest.String(), "error", err).Trace("skipping OCI image manifest with missing blob")
+				continue
+			}
+			if image.IsAttestationManifest(desc, manifest) {
+				log.WithFields("digest", desc.Digest.String()).Trace("skipping OCI attestation manifest")
+				continue
+			}
+			candidates = append(candidates, manifestCandidate{
+				descriptor: desc,
+				image:      img,
+				platform:   descriptorPlatform(desc, img),
+			})
+
+		default:
+			log.WithFields("digest", desc.Digest.String(), "mediaType", desc.MediaType).Trace("skipping unsupported OCI index entry")
+		}
+	}
+	return candidates
+}
+
+// descriptorPlatform returns the platform described on the index entry, falling back to the platform described within
+// the image config.
+func descriptorPlatform(desc v1.Descriptor, img v1.Image) *v1.Platform {
+	if desc.Platform != nil {
+		return desc.Platform
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil || cfg == nil {
+		return nil
+	}
+	return cfg.Platform()
 }
@@ -2,11 +2,22 @@ package oci
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
 )
 
 func Test_NewProviderFromPath(t *testing.T) {
@@ -58,3 +69,264 @@ func Test_Directory_Provider(t *testing.T) {
 		})
 	}
 }
+
+func Test_selectManifest(t *testing.T) {
+	amd64 := v1.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 := v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}
+
+	amd64Img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	arm64Img, err := random.Image(64, 1)
+	require.NoError(t, err)
+
+	amd64Digest, err := amd64Img.Digest()
+	require.NoError(t, err)
+	arm64Digest, err := arm64Img.Digest()
+	require.NoError(t, err)
+
+	unknown := v1.Platform{OS: "unknown", Architecture: "unknown"}
+	attestationLayer := static.NewLayer([]byte(`{"_type":"https://in-toto.io/Statement/v0.1"}`), "application/vnd.in-toto+json")
+	attestationImg, err := mutate.AppendLayers(empty.Image, attestationLayer)
+	require.NoError(t, err)
+
+	// emulates a "ctr image export" archive: the top-level index references a multi-platform index
+	nested := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: amd64Img, Descriptor: v1.Descriptor{Platform: &amd64}},
+		mutate.IndexAddendum{Add: arm64Img, Descriptor: v1.Descriptor{Platform: &arm64}},
+	)
+
+	tests := []struct {
+		name     string
+		setup    func(t *testing.T, p layout.Path)
+		platform string
+		selector string
+		want     v1.Hash
+		wantErr  require.ErrorAssertionFunc
+	}{
+		{
+			name: "select platform from top-level index",
+			setup: func(t *testing.T, p layout.Path) {
+				require.NoError(t, p.AppendImage(amd64Img, layout.WithPlatform(amd64)))
+				require.NoError(t, p.AppendImage(arm64Img, layout.WithPlatform(arm64)))
+			},
+			platform: "linux/arm64",
+			want:     arm64Digest,
+		},
+		{
+			name: "select platform from nested index",
+			setup: func(t *testing.T, p layout.Path) {
+				require.NoError(t, p.AppendIndex(nested))
+			},
+			platform: "linux/amd64",
+			want:     amd64Digest,
+		},
+		{
+			name: "ignore manifests with missing blobs",
+			setup: func(t *testing.T, p layout.Path) {
+				require.NoError(t, p.AppendIndex(nested))
+				require.NoError(t, p.RemoveBlob(arm64Digest))
+			},
+			want: amd64Digest,
+		},
+		{
+			name: "skip buildkit attestation manifest",
+			setup: func(t *testing.T, p layout.Path) {
+				require.NoError(t, p.AppendImage(attestationImg, layout.WithPlatform(unknown)))
+				require.NoError(t, p.AppendImage(amd64Img, layout.WithPlatform(amd64)))
+			},
+			want: amd64Digest,
+		},
+		{
+			name: "skip in-toto manifest without platform",
+			setup: func(t *testing.T, p layout.Path) {
+				require.NoError(t, p.AppendImage(attestationImg))
+				require.NoError(t, p.AppendImage(arm64Img, layout.WithPlatform(arm64)))
+			},
+			want: arm64Digest,
+		},
+		{
+			name: "only attestation manifests",
+			setup: func(t *testing.T, p layout.Path) {
+				require.NoError(t, p.AppendImage(attestationImg, layout.WithPlatform(unknown)))
+			},
+			wantErr: require.Error,
+		},
+		{
+			name: "no matching platform",
+			setup: func(t *testing.T, p layout.Path) {
+				require.NoError(t, p.AppendIndex(nested))
+			},
+			platform: "linux/s390x",
+			wantErr:  require.Error,
+		},
+		{
+			name: "select image by reference name",
+			setup: func(t *testing.T, p layout.Path) {
+				require.NoError(t, p.AppendImage(amd64Img, layout.WithAnnotations(map[string]string{ocispec.AnnotationRefName: "app"})))
+				require.NoError(t, p.AppendImage(arm64Img, layout.WithAnnotations(map[string]string{ocispec.AnnotationRefName: "sidecar"})))
+			},
+			selector: "sidecar",
+			want:     arm64Digest,
+		},
+		{
+			name: "select image by containerd image name",
+			setup: func(t *testing.T, p layout.Path) {
+				require.NoError(t, p.AppendImage(amd64Img, layout.WithAnnotations(map[string]string{containerdImageNameAnnotation: "docker.io/library/app:latest"})))
+				require.NoError(t, p.AppendImage(arm64Img, layout.WithAnnotations(map[string]string{containerdImageNameAnnotation: "docker.io/library/sidecar:latest"})))
+			},
+			selector: "docker.io/library/app:latest",
+			want:     amd64Digest,
+		},
+		{
+			name: "select nested index by reference name then platform",
+			setup: func(t *testing.T, p layout.Path) {
+				require.NoError(t, p.AppendImage(attestationImg))
+				require.NoError(t, p.AppendImage(amd64Img, layout.WithAnnotations(map[string]string{ocispec.AnnotationRefName: "other"})))
+				require.NoError(t, p.AppendIndex(nested, layout.WithAnnotations(map[string]string{ocispec.AnnotationRefName: "multi"})))
+			},
+			platform: "linux/arm64",
+			selector: "multi",
+			want:     arm64Digest,
+		},
+		{
+			name: "select image by digest within nested index",
+			setup: func(t *testing.T, p layout.Path) {
+				require.NoError(t, p.AppendIndex(nested))
+			},
+			platform: "linux/amd64",
+			selector: arm64Digest.String(),
+			want:     arm64Digest,
+		},
+		{
+			name: "no image matching selector",
+			setup: func(t *testing.T, p layout.Path) {
+				require.NoError(t, p.AppendImage(amd64Img, layout.WithAnnotations(map[string]string{ocispec.AnnotationRefName: "app"})))
+			},
+			selector: "missing",
+			wantErr:  require.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+			p, err := layout.Write(t.TempDir(), empty.Index)
+			require.NoError(t, err)
+			tt.setup(t, p)
+
+			var platform *image.Platform
+			if tt.platform != "" {
+				platform, err = image.NewPlatform(tt.platform)
+				require.NoError(t, err)
+			}
+
+			index, err := p.ImageIndex()
+			require.NoError(t, err)
+
+			desc, img, err := selectManifest(index, platform, tt.selector)
+			tt.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tt.want, desc.Digest)
+			assert.NotNil(t, img)
+		})
+	}
+}
+
+func Test_splitImageSelector(t *testing.T) {
+	existing := filepath.Join(t.TempDir(), "dir#name")
+	require.NoError(t, os.Mkdir(existing, 0o755))
+
+	tests := []struct {
+		path         string
+		wantPath     string
+		wantSelector string
+	}{
+		{path: "/path/to/layout", wantPath: "/path/to/layout"},
+		{path: "/path/to/layout#latest", wantPath: "/path/to/layout", wantSelector: "latest"},
+		{path: "/path/to/layout#sha256:abc", wantPath: "/path/to/layout", wantSelector: "sha256:abc"},
+		{path: existing, wantPath: existing},
+		{path: existing + "#latest", wantPath: existing, wantSelector: "latest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			path, selector := splitImageSelector(tt.path)
+			assert.Equal(t, tt.wantPath, path)
+			assert.Equal(t, tt.wantSelector, selector)
+		})
+	}
+}
+
+func Test_Directory_ProvideIndex(t *testing.T) {
+	amd64 := v1.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 := v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}
+
+	amd64Img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	arm64Img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	otherImg, err := random.Image(64, 1)
+	require.NoError(t, err)
+
+	nested := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: amd64Img, Descriptor: v1.Descriptor{Platform: &amd64}},
+		mutate.IndexAddendum{Add: arm64Img, Descriptor: v1.Descriptor{Platform: &arm64}},
+	)
+	nestedDigest, err := nested.Digest()
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	p, err := layout.Write(dir, empty.Index)
+	require.NoError(t, err)
+	require.NoError(t, p.AppendIndex(nested, layout.WithAnnotations(map[string]string{ocispec.AnnotationRefName: "multi"})))
+	require.NoError(t, p.AppendImage(otherImg, layout.WithPlatform(amd64), layout.WithAnnotations(map[string]string{ocispec.AnnotationRefName: "other"})))
+
+	tests := []struct {
+		name          string
+		path          string
+		wantDigest    string
+		wantPlatforms []image.Platform
+	}{
+		{
+			name:       "selected nested index",
+			path:       dir + "#multi",
+			wantDigest: nestedDigest.String(),
+			wantPlatforms: []image.Platform{
+				{OS: "linux", Architecture: "amd64"},
+				{OS: "linux", Architecture: "arm64"},
+			},
+		},
+		{
+			name: "all images",
+			path: dir,
+			wantPlatforms: []image.Platform{
+				{OS: "linux", Architecture: "amd64"},
+				{OS: "linux", Architecture: "arm64"},
+				{OS: "linux", Architecture: "amd64"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDirGen := file.NewTempDirGenerator("test")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			provider := NewDirectoryProvider(tmpDirGen, tt.path).(image.IndexProvider)
+			idx, err := provider.ProvideIndex(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantDigest, idx.Digest)
+			assert.Equal(t, tt.wantPlatforms, idx.Platforms())
+
+			arm, err := idx.Image(context.Background(), image.Platform{OS: "linux", Architecture: "arm64"})
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = arm.Cleanup() })
+
+			wantID, err := arm64Img.ConfigName()
+			require.NoError(t, err)
+			assert.Equal(t, wantID.String(), arm.Metadata.ID)
+		})
+	}
+}
@@ -0,0 +1,614 @@
+package oci
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	"github.com/google/go-containerregistry/pkg/name"
+	containerregistryV1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/hashicorp/go-multierror"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// estargzPrefetchConcurrency is the number of range requests made at once while prefetching eStargz layer contents.
+const estargzPrefetchConcurrency = 4
+
+// lazyRegistryImage wraps the given registry image such that all eStargz layers (layers annotated with a TOC digest)
+// are read lazily (see image.LazyLayer). Layers without a table of contents are unchanged. Prefetched layer contents
+// are cached within the given directory.
+func lazyRegistryImage(ctx context.Context, img containerregistryV1.Image, ref name.Reference, registryOptions image.RegistryOptions, cacheDir string) (containerregistryV1.Image, error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(layers) != len(manifest.Layers) {
+		return nil, fmt.Errorf("image has %d layers but the manifest describes %d", len(layers), len(manifest.Layers))
+	}
+
+	var client *http.Client
+	var lazyLayers int
+	for idx, desc := range manifest.Layers {
+		tocDigest, ok := desc.Annotations[estargz.TOCJSONDigestAnnotation]
+		if !ok {
+			continue
+		}
+
+		if client == nil {
+			if client, err = registryClient(ctx, ref.Context(), registryOptions); err != nil {
+				return nil, err
+			}
+		}
+
+		layers[idx] = &estargzLayer{
+			Layer:     layers[idx],
+			tocDigest: tocDigest,
+			blob: &blobReaderAt{
+				ctx:      image.ContentContext(ctx),
+				client:   client,
+				url:      blobURL(ref.Context(), desc.Digest),
+				size:     desc.Size,
+				cacheDir: cacheDir,
+			},
+		}
+		lazyLayers++
+	}
+
+	if lazyLayers == 0 {
+		log.WithFields("image", ref.String()).Debug("no eStargz layers found, reading all layers in full")
+		return img, nil
+	}
+
+	log.WithFields("image", ref.String(), "layers", lazyLayers).Debug("reading eStargz layers lazily")
+	return &lazyImage{Image: img, layers: layers}, nil
+}
+
+// lazyImage is a registry image where some layers are replaced with lazy layers.
+type lazyImage struct {
+	containerregistryV1.Image
+	layers []containerregistryV1.Layer
+}
+
+func (i *lazyImage) Layers() ([]containerregistryV1.Layer, error) {
+	return i.layers, nil
+}
+
+// estargzLayer is an image.LazyLayer for an eStargz layer blob within a registry, where only the table of contents
+// and the requested file contents are fetched (with range requests). The table of contents is verified against the
+// TOC digest annotation of the layer, and file contents are verified against the chunk digests within the table of
+// contents. Note: the full layer blob is still available (e.g. for unpacking) through the underlying layer.
+type estargzLayer struct {
+	containerregistryV1.Layer
+	tocDigest string
+	blob      *blobReaderAt
+
+	once     sync.Once
+	reader   *estargz.Reader
+	verifier estargz.TOCEntryVerifier
+	err      error
+}
+
+var _ image.LazyPrefetcher = (*estargzLayer)(nil)
+
+func (l *estargzLayer) open() (*estargz.Reader, error) {
+	l.once.Do(func() {
+		tocDigest, err := digest.Parse(l.tocDigest)
+		if err != nil {
+			l.err = fmt.Errorf("invalid TOC digest annotation %q: %w", l.tocDigest, err)
+			return
+		}
+
+		reader, err := estargz.Open(io.NewSectionReader(l.blob, 0, l.blob.size))
+		if err != nil {
+			l.err = fmt.Errorf("unable to read eStargz table of contents: %w", err)
+			return
+		}
+
+		verifier, err := reader.VerifyTOC(tocDigest)
+		if err != nil {
+			l.err = fmt.Errorf("unable to verify eStargz table of contents: %w", err)
+			return
+		}
+		l.reader, l.verifier = reader, verifier
+	})
+	return l.reader, l.err
+}
+
+// Entries returns the tar headers of all entries within the layer table of contents (in lexical path order).
+func (l *estargzLayer) Entries() ([]tar.Header, error) {
+	reader, err := l.open()
+	if err != nil {
+		return nil, err
+	}
+
+	root, ok := reader.Lookup("")
+	if !ok {
+		return nil, fmt.Errorf("eStargz table of contents has no root directory")
+	}
+
+	var headers []tar.Header
+	var walk func(dir string, ent *estargz.TOCEntry)
+	walk = func(dir string, ent *estargz.TOCEntry) {
+		var names []string
+		children := make(map[string]*estargz.TOCEntry)
+		ent.ForeachChild(func(baseName string, child *estargz.TOCEntry) bool {
+			names = append(names, baseName)
+			children[baseName] = child
+			return true
+		})
+		sort.Strings(names)
+
+		for _, baseName := range names {
+			child := children[baseName]
+			p := path.Join(dir, baseName)
+			if p == estargz.PrefetchLandmark || p == estargz.NoPrefetchLandmark {
+				// landmarks are markers for runtime prefetching (not part of the layer filesystem)
+				continue
+			}
+			if header, ok := tocEntryHeader(p, child); ok {
+				headers = append(headers, header)
+			}
+			// note: hardlinks to directories are not possible, thus directories are only visited once
+			if child.Type == "dir" && child.Name == p {
+				walk(p, child)
+			}
+		}
+	}
+	walk("", root)
+
+	return headers, nil
+}
+
+// Open returns the contents of the regular file with the given name, which are fetched when first read (a chunk at a
+// time, each verified against its digest within the table of contents before any of it is returned).
+func (l *estargzLayer) Open(name string) (io.ReadCloser, error) {
+	reader, err := l.open()
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimPrefix(name, "/")
+	ent, ok := reader.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("no entry %q within the eStargz table of contents", name)
+	}
+
+	sr, err := reader.OpenFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(&verifiedChunkReader{
+		toc:      reader,
+		verifier: l.verifier,
+		// note: hardlinks resolve to the entry they link to, which holds the chunks of the file
+		name: ent.Name,
+		file: sr,
+	}), nil
+}
+
+// verifiedChunkReader reads the contents of a file within an eStargz layer a chunk at a time, verifying each chunk
+// against its digest within the (verified) table of contents.
+type verifiedChunkReader struct {
+	toc      *estargz.Reader
+	verifier estargz.TOCEntryVerifier
+	name     string
+	file     *io.SectionReader
+	// offset is the offset of the next chunk to read within the file
+	offset int64
+	chunk  []byte
+}
+
+func (r *verifiedChunkReader) Read(p []byte) (int, error) {
+	if len(r.chunk) == 0 {
+		if r.offset >= r.file.Size() {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+func (r *verifiedChunkReader) next() error {
+	ce, ok := r.toc.ChunkEntryForOffset(r.name, r.offset)
+	if !ok || ce.ChunkOffset != r.offset || ce.ChunkSize <= 0 || ce.ChunkOffset+ce.ChunkSize > r.file.Size() {
+		return fmt.Errorf("no valid chunk at offset %d of %q within the eStargz table of contents", r.offset, r.name)
+	}
+
+	chunk := make([]byte, ce.ChunkSize)
+	if n, err := r.file.ReadAt(chunk, ce.ChunkOffset); n != len(chunk) {
+		return fmt.Errorf("unable to read chunk at offset %d of %q: %w", ce.ChunkOffset, r.name, err)
+	}
+
+	v, err := r.verifier.Verifier(ce)
+	if err != nil {
+		return fmt.Errorf("unable to verify chunk at offset %d of %q: %w", ce.ChunkOffset, r.name, err)
+	}
+	if _, err := v.Write(chunk); err != nil {
+		return err
+	}
+	if !v.Verified() {
+		return fmt.Errorf("chunk at offset %d of %q does not match its digest within the eStargz table of contents", ce.ChunkOffset, r.name)
+	}
+
+	r.offset = ce.ChunkOffset + ce.ChunkSize
+	r.chunk = chunk
+	return nil
+}
+
+// Prefetch fetches the compressed regions of the layer blob holding the contents of the regular files with the given
+// names (with concurrent range requests, merging adjacent regions), which are cached on disk for the lifetime of the
+// layer such that reading those files afterwards does not fetch them again.
+func (l *estargzLayer) Prefetch(ctx context.Context, names []string) error {
+	reader, err := l.open()
+	if err != nil {
+		return err
+	}
+
+	var ranges []blobRange
+	for _, n := range names {
+		n = strings.TrimPrefix(n, "/")
+		ent, ok := reader.Lookup(n)
+		if !ok {
+			return fmt.Errorf("no entry %q within the eStargz table of contents", n)
+		}
+		if ent.Type != "reg" || ent.Size == 0 {
+			continue
+		}
+		// note: hardlinks resolve to the entry they link to, which holds the chunks of the file
+		last, ok := reader.ChunkEntryForOffset(ent.Name, ent.Size-1)
+		if !ok {
+			return fmt.Errorf("no chunk for the end of %q within the eStargz table of contents", n)
+		}
+		ranges = append(ranges, blobRange{offset: ent.Offset, size: last.NextOffset() - ent.Offset})
+	}
+
+	return l.blob.prefetch(ctx, mergeBlobRanges(ranges))
+}
+
+// tocEntryHeader converts the given TOC entry found at the given path into a tar header. Entries found at a path other
+// than their own name are hardlinks (the TOC reader resolves hardlinks to the entry they link to).
+func tocEntryHeader(p string, ent *estargz.TOCEntry) (tar.Header, bool) {
+	header := tar.Header{
+		Name:     p,
+		Mode:     ent.Mode,
+		Uid:      ent.UID,
+		Gid:      ent.GID,
+		Uname:    ent.Uname,
+		Gname:    ent.Gname,
+		ModTime:  ent.ModTime(),
+		Linkname: ent.LinkName,
+		Devmajor: int64(ent.DevMajor),
+		Devminor: int64(ent.DevMinor),
+	}
+
+	if ent.Name != p {
+		header.Typeflag = tar.TypeLink
+		header.Linkname = ent.Name
+		return header, true
+	}
+
+	switch ent.Type {
+	case "dir":
+		header.Typeflag = tar.TypeDir
+	case "reg":
+		header.Typeflag = tar.TypeReg
+		header.Size = ent.Size
+	case "symlink":
+		header.Typeflag = tar.TypeSymlink
+	case "char":
+		header.Typeflag = tar.TypeChar
+	case "block":
+		header.Typeflag = tar.TypeBlock
+	case "fifo":
+		header.Typeflag = tar.TypeFifo
+	default:
+		return tar.Header{}, false
+	}
+
+	if len(ent.Xattrs) > 0 {
+		header.PAXRecords = make(map[string]string, len(ent.Xattrs))
+		for k, v := range ent.Xattrs {
+			header.PAXRecords["SCHILY.xattr."+k] = string(v)
+		}
+	}
+	return header, true
+}
+
+// blobRange is a region of a blob.
+type blobRange struct {
+	offset int64
+	size   int64
+}
+
+// mergeBlobRanges returns the given ranges sorted by offset, with overlapping and adjacent ranges merged.
+func mergeBlobRanges(ranges []blobRange) []blobRange {
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].offset < ranges[j].offset
+	})
+
+	var merged []blobRange
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r.offset <= merged[n-1].offset+merged[n-1].size {
+			if end := r.offset + r.size; end > merged[n-1].offset+merged[n-1].size {
+				merged[n-1].size = end - merged[n-1].offset
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// blobReaderAt reads ranges of a blob within a registry, serving reads from prefetched ranges when possible.
+type blobReaderAt struct {
+	// ctx bounds all reads (the context the image was provided with, see image.ContentContext), since reads through
+	// io.ReaderAt cannot be given a context of their own
+	ctx      context.Context
+	client   *http.Client
+	url      string
+	size     int64
+	cacheDir string
+
+	lock sync.RWMutex
+	// cachePath is a (sparse) file holding the prefetched ranges at their offset within the blob, such that prefetched
+	// contents are not held in memory (created within the cache directory on the first prefetch)
+	cachePath string
+	cached    []blobRange
+}
+
+func (b *blobReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= b.size {
+		return 0, io.EOF
+	}
+
+	want := p
+	if remaining := b.size - off; int64(len(want)) > remaining {
+		want = want[:remaining]
+	}
+	if len(want) == 0 {
+		return 0, nil
+	}
+
+	if !b.readCached(want, off) {
+		if n, err := b.fetch(b.ctx, want, off); err != nil {
+			return n, err
+		}
+	}
+
+	if len(want) < len(p) {
+		return len(want), io.EOF
+	}
+	return len(want), nil
+}
+
+// readCached fills p with the blob contents at the given offset when they are held by a prefetched range.
+func (b *blobReaderAt) readCached(p []byte, off int64) bool {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	if !b.isCached(off, int64(len(p))) {
+		return false
+	}
+
+	f, err := os.Open(b.cachePath)
+	if err != nil {
+		log.WithFields("path", b.cachePath, "error", err).Debug("unable to open prefetched blob ranges")
+		return false
+	}
+	defer f.Close()
+
+	if _, err := f.ReadAt(p, off); err != nil {
+		log.WithFields("path", b.cachePath, "error", err).Debug("unable to read prefetched blob range")
+		return false
+	}
+	return true
+}
+
+// isCached reports whether the given region of the blob is held by a prefetched range. The caller must hold the lock.
+func (b *blobReaderAt) isCached(off, size int64) bool {
+	for _, c := range b.cached {
+		if off >= c.offset && off+size <= c.offset+c.size {
+			return true
+		}
+	}
+	return false
+}
+
+// prefetch fetches the given ranges of the blob (a bounded number at once) into the cache file.
+func (b *blobReaderAt) prefetch(ctx context.Context, ranges []blobRange) error {
+	cache, err := b.openCache()
+	if err != nil {
+		return fmt.Errorf("unable to open cache for prefetched blob ranges: %w", err)
+	}
+	defer cache.Close()
+
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+		errs error
+		work = make(chan blobRange)
+	)
+
+	for w := 0; w < estargzPrefetchConcurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range work {
+				if err := b.fetchTo(ctx, io.NewOffsetWriter(cache, r.offset), r.offset, r.size); err != nil {
+					lock.Lock()
+					errs = multierror.Append(errs, err)
+					lock.Unlock()
+					continue
+				}
+
+				b.lock.Lock()
+				b.cached = append(b.cached, r)
+				b.lock.Unlock()
+			}
+		}()
+	}
+
+	for _, r := range ranges {
+		if r.offset < 0 || r.size <= 0 || r.offset+r.size > b.size {
+			lock.Lock()
+			errs = multierror.Append(errs, fmt.Errorf("range at offset %d (size %d) exceeds the blob size", r.offset, r.size))
+			lock.Unlock()
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		b.lock.RLock()
+		cached := b.isCached(r.offset, r.size)
+		b.lock.RUnlock()
+		if cached {
+			continue
+		}
+		work <- r
+	}
+	close(work)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errs
+}
+
+// openCache opens the cache file for writing prefetched ranges, creating it on first use.
+func (b *blobReaderAt) openCache() (*os.File, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.cachePath != "" {
+		return os.OpenFile(b.cachePath, os.O_WRONLY, 0)
+	}
+
+	f, err := os.CreateTemp(b.cacheDir, "estargz-prefetch-*")
+	if err != nil {
+		return nil, err
+	}
+	b.cachePath = f.Name()
+	return f, nil
+}
+
+// fetch reads len(p) bytes of the blob at the given offset with a range request (the range must be within the blob).
+func (b *blobReaderAt) fetch(ctx context.Context, p []byte, off int64) (int, error) {
+	body, err := b.openRange(ctx, off, int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	n, err := io.ReadFull(body, p)
+	if err != nil {
+		return n, fmt.Errorf("unable to read blob range: %w", err)
+	}
+	return n, nil
+}
+
+// fetchTo copies size bytes of the blob at the given offset to w with a range request (the range must be within the
+// blob).
+func (b *blobReaderAt) fetchTo(ctx context.Context, w io.Writer, off, size int64) error {
+	body, err := b.openRange(ctx, off, size)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if _, err := io.CopyN(w, body, size); err != nil {
+		return fmt.Errorf("unable to read blob range: %w", err)
+	}
+	return nil
+}
+
+// openRange requests the given range of the blob, returning the response body positioned at the start of the range.
+func (b *blobReaderAt) openRange(ctx context.Context, off, size int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+size-1))
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// the registry does not support range requests, thus the blob is read from the start
+		if _, err := io.CopyN(io.Discard, resp.Body, off); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unable to read blob range: %w", err)
+		}
+	default:
+		defer resp.Body.Close()
+		return nil, transport.CheckError(resp, http.StatusPartialContent, http.StatusOK)
+	}
+	return resp.Body, nil
+}
+
+// registryClient creates an HTTP client authorized to pull from the given repository (using the same credentials and
+// TLS configuration as the registry provider).
+func registryClient(ctx context.Context, repo name.Repository, registryOptions image.RegistryOptions) (*http.Client, error) {
+	registryName := repo.RegistryStr()
+
+	auth := registryOptions.Authenticator(registryName)
+	if auth == nil {
+		var err error
+		if auth, err = registryOptions.ResolveKeychain(ctx).Resolve(repo); err != nil {
+			return nil, fmt.Errorf("unable to resolve registry credentials: %w", err)
+		}
+	}
+
+	var base http.RoundTripper = http.DefaultTransport
+	tlsConfig, err := registryOptions.TLSConfig(registryName)
+	if err != nil {
+		log.Warnf("unable to configure TLS transport: %+v", err)
+	} else if tlsConfig != nil {
+		base = getTransport(tlsConfig)
+	}
+
+	rt, err := transport.NewWithContext(ctx, repo.Registry, auth, base, []string{repo.Scope(transport.PullScope)})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create registry transport: %w", err)
+	}
+	return &http.Client{Transport: rt}, nil
+}
+
+func blobURL(repo name.Repository, d containerregistryV1.Hash) string {
+	u := url.URL{
+		Scheme: repo.Registry.Scheme(),
+		Host:   repo.RegistryStr(),
+		Path:   fmt.Sprintf("/v2/%s/blobs/%s", repo.RepositoryStr(), d.String()),
+	}
+	return u.String()
+}
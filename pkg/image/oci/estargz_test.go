@@ -0,0 +1,455 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func Test_RegistryProvider_LazyPull(t *testing.T) {
+	large := make([]byte, 512*1024)
+	_, err := rand.Read(large)
+	require.NoError(t, err)
+
+	base, baseSize := newEstargzLayer(t, []tar.Header{
+		{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "etc/os-release", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "etc/removed", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "usr/lib/large.bin", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(large))},
+		{Name: "usr/lib/large-link.bin", Typeflag: tar.TypeLink, Linkname: "usr/lib/large.bin"},
+	}, map[string][]byte{
+		"etc/os-release":    []byte("ID=lazy\n"),
+		"etc/removed":       []byte("gone"),
+		"usr/lib/large.bin": large,
+	})
+	top, topSize := newEstargzLayer(t, []tar.Header{
+		{Name: "etc/.wh.removed", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "etc/os-release", Typeflag: tar.TypeReg, Mode: 0644, Uid: 1000},
+	}, map[string][]byte{
+		"etc/os-release": []byte("ID=lazy\nVERSION_ID=2\n"),
+	})
+
+	img, err := mutate.Append(empty.Image, base, top)
+	require.NoError(t, err)
+
+	registryHost, served := makeRangeRegistry(t)
+	ref, err := name.ParseReference(registryHost+"/lazy:latest", name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+
+	t.Run("lazy", func(t *testing.T) {
+		served.reset()
+		provided := provideRegistryImage(t, ref, image.RegistryOptions{InsecureUseHTTP: true, LazyPull: true})
+
+		// only the table of contents of each layer has been fetched
+		assert.Less(t, served.total(), (baseSize+topSize)/2)
+		assert.Zero(t, served.full(base.Layer, top.Layer))
+
+		assertSquashContents(t, provided, map[string]string{
+			"/etc/os-release": "ID=lazy\nVERSION_ID=2\n",
+		})
+		assert.False(t, provided.SquashedTree().HasPath("/etc/removed"))
+		assert.False(t, provided.SquashedTree().HasPath("/"+estargz.PrefetchLandmark))
+		assert.False(t, provided.SquashedTree().HasPath("/"+estargz.NoPrefetchLandmark))
+
+		_, res, err := provided.SquashedTree().File("/etc/os-release")
+		require.NoError(t, err)
+		entry, err := provided.FileCatalog.Get(*res.Reference)
+		require.NoError(t, err)
+		assert.Equal(t, 1000, entry.Metadata.UserID)
+
+		assertSquashContents(t, provided, map[string]string{
+			"/usr/lib/large.bin":      string(large),
+			"/usr/lib/large-link.bin": string(large),
+		})
+		assert.Zero(t, served.full(base.Layer, top.Layer))
+	})
+
+	t.Run("prefetch", func(t *testing.T) {
+		provided := provideRegistryImage(t, ref, image.RegistryOptions{InsecureUseHTTP: true, LazyPull: true})
+
+		served.reset()
+		summary, err := provided.Prefetch(context.Background(), "/usr/lib/**", "/etc/os-release")
+		require.NoError(t, err)
+		assert.Equal(t, image.PrefetchSummary{Files: 2, Bytes: int64(len(large) + len("ID=lazy\nVERSION_ID=2\n"))}, summary)
+		assert.NotZero(t, served.total())
+		assert.Zero(t, served.full(base.Layer, top.Layer))
+
+		// prefetched contents are read from the cache of each layer
+		served.reset()
+		assertSquashContents(t, provided, map[string]string{
+			"/etc/os-release":         "ID=lazy\nVERSION_ID=2\n",
+			"/usr/lib/large.bin":      string(large),
+			"/usr/lib/large-link.bin": string(large),
+		})
+		assert.Zero(t, served.total())
+
+		// prefetching again does not fetch the cached contents again
+		_, err = provided.Prefetch(context.Background(), "/usr/lib/**")
+		require.NoError(t, err)
+		assert.Zero(t, served.total())
+	})
+
+	t.Run("not lazy", func(t *testing.T) {
+		served.reset()
+		provided := provideRegistryImage(t, ref, image.RegistryOptions{InsecureUseHTTP: true})
+
+		assert.Equal(t, 2, served.full(base.Layer, top.Layer))
+		assertSquashContents(t, provided, map[string]string{
+			"/etc/os-release":    "ID=lazy\nVERSION_ID=2\n",
+			"/usr/lib/large.bin": string(large),
+		})
+	})
+
+	t.Run("unverifiable contents", func(t *testing.T) {
+		tampered, _ := newClaimedEstargzLayer(t, []tar.Header{
+			{Name: "etc/os-release", Typeflag: tar.TypeReg, Mode: 0644},
+		}, map[string][]byte{
+			"etc/os-release": []byte("ID=evil\n"),
+		}, map[string][]byte{
+			"etc/os-release": []byte("ID=good\n"),
+		})
+		img, err := mutate.Append(empty.Image, tampered)
+		require.NoError(t, err)
+
+		tamperedRef, err := name.ParseReference(registryHost+"/tampered-contents:latest", name.Insecure)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(tamperedRef, img))
+
+		provided := provideRegistryImage(t, tamperedRef, image.RegistryOptions{InsecureUseHTTP: true, LazyPull: true})
+
+		_, res, err := provided.SquashedTree().File("/etc/os-release")
+		require.NoError(t, err)
+		reader, err := provided.OpenReference(*res.Reference)
+		require.NoError(t, err)
+		defer reader.Close()
+
+		contents, err := io.ReadAll(reader)
+		require.ErrorContains(t, err, "does not match its digest")
+		assert.Empty(t, contents)
+	})
+
+	t.Run("unverifiable table of contents", func(t *testing.T) {
+		tampered, err := mutate.Append(empty.Image, mutate.Addendum{
+			Layer:       base.Layer,
+			Annotations: map[string]string{estargz.TOCJSONDigestAnnotation: "sha256:" + strings.Repeat("0", 64)},
+		})
+		require.NoError(t, err)
+
+		tamperedRef, err := name.ParseReference(registryHost+"/tampered:latest", name.Insecure)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(tamperedRef, tampered))
+
+		served.reset()
+		provided := provideRegistryImage(t, tamperedRef, image.RegistryOptions{InsecureUseHTTP: true, LazyPull: true})
+
+		// the layer is read in full instead
+		assert.Equal(t, 1, served.full(base.Layer))
+		assertSquashContents(t, provided, map[string]string{
+			"/etc/os-release": "ID=lazy\n",
+		})
+	})
+}
+
+func Test_blobReaderAt_prefetch(t *testing.T) {
+	blob := make([]byte, 64*1024)
+	_, err := rand.Read(blob)
+	require.NoError(t, err)
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.ServeContent(w, r, "blob", time.Time{}, bytes.NewReader(blob))
+	}))
+	t.Cleanup(server.Close)
+
+	cacheDir := t.TempDir()
+	b := &blobReaderAt{
+		ctx:      context.Background(),
+		client:   server.Client(),
+		url:      server.URL,
+		size:     int64(len(blob)),
+		cacheDir: cacheDir,
+	}
+
+	ranges := []blobRange{{offset: 100, size: 1000}, {offset: 32 * 1024, size: 32 * 1024}}
+	require.NoError(t, b.prefetch(context.Background(), ranges))
+	assert.EqualValues(t, 2, requests.Load())
+
+	// prefetched ranges are written to the cache directory instead of being held in memory
+	entries, err := os.ReadDir(cacheDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.ElementsMatch(t, ranges, b.cached)
+
+	requests.Store(0)
+	for _, r := range []blobRange{{offset: 100, size: 1000}, {offset: 500, size: 10}, {offset: 40 * 1024, size: 24 * 1024}} {
+		p := make([]byte, r.size)
+		n, err := b.ReadAt(p, r.offset)
+		require.NoError(t, err)
+		assert.Equal(t, int(r.size), n)
+		assert.Equal(t, blob[r.offset:r.offset+r.size], p)
+	}
+	assert.Zero(t, requests.Load())
+
+	// regions not (entirely) prefetched are fetched
+	p := make([]byte, 200)
+	_, err = b.ReadAt(p, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, blob[1000:1200], p)
+	assert.EqualValues(t, 1, requests.Load())
+
+	// prefetching again only fetches the ranges not cached yet, into the same cache file
+	requests.Store(0)
+	require.NoError(t, b.prefetch(context.Background(), append(ranges, blobRange{offset: 2000, size: 100})))
+	assert.EqualValues(t, 1, requests.Load())
+	entries, err = os.ReadDir(cacheDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func provideRegistryImage(t *testing.T, ref name.Reference, options image.RegistryOptions) *image.Image {
+	t.Helper()
+
+	tmpDirGen := file.NewTempDirGenerator("test")
+	t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+	provided, err := NewRegistryProvider(tmpDirGen, options, ref.String(), nil).Provide(context.Background())
+	require.NoError(t, err)
+	return provided
+}
+
+func assertSquashContents(t *testing.T, img *image.Image, want map[string]string) {
+	t.Helper()
+
+	for p, contents := range want {
+		reader, err := img.OpenPathFromSquash(file.Path(p))
+		require.NoError(t, err, p)
+		got, err := io.ReadAll(reader)
+		require.NoError(t, err, p)
+		assert.Equal(t, contents, string(got), p)
+	}
+}
+
+// newEstargzLayer creates an eStargz layer (annotated with the TOC digest) from the given tar entries, returning the
+// layer and the size of the layer blob. Note: the blob is assembled by hand (each regular file payload in its own gzip
+// member, followed by the TOC and footer) since the footer written by estargz.Build depends on the gzip implementation
+// of the Go version used.
+func newEstargzLayer(t *testing.T, headers []tar.Header, contents map[string][]byte) (mutate.Addendum, int64) {
+	t.Helper()
+	return newClaimedEstargzLayer(t, headers, contents, contents)
+}
+
+// newClaimedEstargzLayer creates an eStargz layer with the given contents, where the table of contents describes the
+// digests of the claimed contents instead (such that the table of contents is valid, but the contents are not).
+func newClaimedEstargzLayer(t *testing.T, headers []tar.Header, contents, claimed map[string][]byte) (mutate.Addendum, int64) {
+	t.Helper()
+
+	var blob bytes.Buffer
+	var pending bytes.Buffer
+	flush := func() {
+		if pending.Len() == 0 {
+			return
+		}
+		zw := gzip.NewWriter(&blob)
+		_, err := zw.Write(pending.Bytes())
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		pending.Reset()
+	}
+
+	toc := estargz.JTOC{Version: 1}
+	tw := tar.NewWriter(&pending)
+	for _, h := range headers {
+		h := h
+		payload := contents[h.Name]
+		if h.Typeflag == tar.TypeReg {
+			h.Size = int64(len(payload))
+		}
+		require.NoError(t, tw.WriteHeader(&h))
+
+		entry := &estargz.TOCEntry{
+			Name:     h.Name,
+			Mode:     h.Mode,
+			UID:      h.Uid,
+			GID:      h.Gid,
+			LinkName: h.Linkname,
+		}
+
+		switch h.Typeflag {
+		case tar.TypeDir:
+			entry.Type = "dir"
+		case tar.TypeSymlink:
+			entry.Type = "symlink"
+		case tar.TypeLink:
+			entry.Type = "hardlink"
+		case tar.TypeReg:
+			entry.Type = "reg"
+			entry.Size = h.Size
+			if h.Size > 0 {
+				// each payload starts a new gzip member
+				flush()
+				entry.Offset = int64(blob.Len())
+				entry.Digest = digest.FromBytes(claimed[h.Name]).String()
+				entry.ChunkDigest = entry.Digest
+				entry.ChunkSize = h.Size
+				_, err := tw.Write(payload)
+				require.NoError(t, err)
+				require.NoError(t, tw.Flush())
+				flush()
+			}
+		}
+		toc.Entries = append(toc.Entries, entry)
+	}
+	flush()
+
+	// the TOC is a tar entry in its own gzip member (which also ends the tar stream)
+	tocJSON, err := json.Marshal(toc)
+	require.NoError(t, err)
+	tocOffset := int64(blob.Len())
+	tocTar := tar.NewWriter(&pending)
+	require.NoError(t, tocTar.WriteHeader(&tar.Header{Name: estargz.TOCTarName, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(tocJSON))}))
+	_, err = tocTar.Write(tocJSON)
+	require.NoError(t, err)
+	require.NoError(t, tocTar.Close())
+	flush()
+
+	blob.Write(estargzFooter(tocOffset))
+
+	compressed := blob.Bytes()
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(compressed)), nil
+	})
+	require.NoError(t, err)
+
+	return mutate.Addendum{
+		Layer:       layer,
+		Annotations: map[string]string{estargz.TOCJSONDigestAnnotation: digest.FromBytes(tocJSON).String()},
+	}, int64(len(compressed))
+}
+
+// estargzFooter returns the 51 byte eStargz footer: an empty gzip member with the TOC offset in the extra field.
+func estargzFooter(tocOffset int64) []byte {
+	subfield := fmt.Sprintf("%016xSTARGZ", tocOffset)
+
+	var footer bytes.Buffer
+	// gzip header with the FEXTRA flag set, zero mtime, no extra flags, and an unknown OS
+	footer.Write([]byte{0x1f, 0x8b, 0x08, 0x04, 0, 0, 0, 0, 0, 0xff})
+	_ = binary.Write(&footer, binary.LittleEndian, uint16(4+len(subfield)))
+	footer.Write([]byte{'S', 'G'})
+	_ = binary.Write(&footer, binary.LittleEndian, uint16(len(subfield)))
+	footer.WriteString(subfield)
+	// an empty final stored deflate block, followed by the CRC-32 and size (both zero)
+	footer.Write([]byte{0x01, 0x00, 0x00, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0})
+	return footer.Bytes()
+}
+
+// blobStats tracks the blob contents served by a test registry.
+type blobStats struct {
+	lock      sync.Mutex
+	bytes     int64
+	fullReads map[string]int
+}
+
+func (s *blobStats) reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.bytes, s.fullReads = 0, make(map[string]int)
+}
+
+func (s *blobStats) total() int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.bytes
+}
+
+// full returns the number of times any of the given layers were fetched without a range request.
+func (s *blobStats) full(layers ...v1.Layer) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var count int
+	for _, l := range layers {
+		d, err := l.Digest()
+		if err != nil {
+			panic(err)
+		}
+		count += s.fullReads[d.String()]
+	}
+	return count
+}
+
+// makeRangeRegistry creates an in-memory registry that supports range requests for blobs (which the test registry
+// otherwise does not), tracking the amount of blob content served.
+func makeRangeRegistry(t *testing.T) (string, *blobStats) {
+	stats := &blobStats{}
+	stats.reset()
+	handler := registry.New(registry.WithBlobHandler(registry.NewInMemoryBlobHandler()))
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || !strings.Contains(r.URL.Path, "/blobs/sha256:") {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if rec.Code != http.StatusOK {
+			w.WriteHeader(rec.Code)
+			_, _ = w.Write(rec.Body.Bytes())
+			return
+		}
+
+		counter := &countingWriter{ResponseWriter: w}
+		http.ServeContent(counter, r, "", time.Time{}, bytes.NewReader(rec.Body.Bytes()))
+
+		stats.lock.Lock()
+		defer stats.lock.Unlock()
+		stats.bytes += counter.n
+		if r.Header.Get("Range") == "" {
+			stats.fullReads[r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]]++
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return strings.TrimPrefix(ts.URL, "http://"), stats
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
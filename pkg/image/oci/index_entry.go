@@ -0,0 +1,25 @@
+package oci
+
+import (
+	"context"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// indexEntryProvider provides a platform image of an image.Index with its own temp dir generator (a child of the
+// given one), such that cleaning up one platform image does not remove the content shared by the index (e.g. an
+// extracted OCI archive) or the content of other platform images.
+type indexEntryProvider struct {
+	name        string
+	tmpDirGen   *file.TempDirGenerator
+	newProvider func(*file.TempDirGenerator) image.Provider
+}
+
+func (p *indexEntryProvider) Name() string {
+	return p.name
+}
+
+func (p *indexEntryProvider) Provide(ctx context.Context) (*image.Image, error) {
+	return p.newProvider(p.tmpDirGen.NewGenerator()).Provide(ctx)
+}
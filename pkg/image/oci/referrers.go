@@ -0,0 +1,193 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// emptyJSON is the OCI "empty descriptor" content used as the config blob for artifact manifests.
+var emptyJSON = []byte("{}")
+
+// Referrer is a caller-provided artifact (e.g. a signature, SBOM, or attestation) that should be written alongside an
+// image within an OCI layout, linked to the image via the OCI subject descriptor.
+type Referrer struct {
+	// ArtifactType describes the kind of artifact (e.g. "application/vnd.in-toto+json")
+	ArtifactType string
+	// MediaType is the media type of Content, defaults to ArtifactType when not provided
+	MediaType types.MediaType
+	// Content is the raw artifact payload
+	Content []byte
+	// Annotations are added to the artifact manifest as well as the layout index entry
+	Annotations map[string]string
+}
+
+// SubjectDescriptor returns the descriptor of the manifest for the given image, suitable for use as the subject of
+// referrer artifacts.
+func SubjectDescriptor(img *image.Image) (v1.Descriptor, error) {
+	if img == nil || len(img.Metadata.RawManifest) == 0 {
+		return v1.Descriptor{}, fmt.Errorf("image does not have a raw manifest available")
+	}
+
+	digest, size, err := v1.SHA256(bytes.NewReader(img.Metadata.RawManifest))
+	if err != nil {
+		return v1.Descriptor{}, fmt.Errorf("unable to digest image manifest: %w", err)
+	}
+
+	mediaType := img.Metadata.MediaType
+	if mediaType == "" {
+		mediaType = types.OCIManifestSchema1
+	}
+
+	return v1.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest,
+		Size:      size,
+	}, nil
+}
+
+// AppendReferrers writes each referrer artifact into the OCI layout at the given path as an image manifest that
+// references the given subject, and adds the artifact manifest to the layout index.
+func AppendReferrers(path layout.Path, subject v1.Descriptor, referrers ...Referrer) error {
+	for idx, r := range referrers {
+		if err := appendReferrer(path, subject, r); err != nil {
+			return fmt.Errorf("unable to write referrer %d (artifactType=%q): %w", idx, r.ArtifactType, err)
+		}
+	}
+	return nil
+}
+
+func appendReferrer(path layout.Path, subject v1.Descriptor, r Referrer) error {
+	if r.ArtifactType == "" {
+		return fmt.Errorf("no artifact type provided")
+	}
+
+	configDesc, err := writeBlob(path, types.MediaType(r.ArtifactType), emptyJSON)
+	if err != nil {
+		return fmt.Errorf("unable to write artifact config: %w", err)
+	}
+
+	layerDesc, err := writeBlob(path, r.mediaType(), r.Content)
+	if err != nil {
+		return fmt.Errorf("unable to write artifact content: %w", err)
+	}
+
+	raw, err := r.manifest(subject, configDesc, layerDesc)
+	if err != nil {
+		return err
+	}
+
+	manifestDesc, err := writeBlob(path, types.OCIManifestSchema1, raw)
+	if err != nil {
+		return fmt.Errorf("unable to write artifact manifest: %w", err)
+	}
+	manifestDesc.ArtifactType = r.ArtifactType
+	manifestDesc.Annotations = r.Annotations
+
+	return path.AppendDescriptor(manifestDesc)
+}
+
+func (r Referrer) mediaType() types.MediaType {
+	if r.MediaType == "" {
+		return types.MediaType(r.ArtifactType)
+	}
+	return r.MediaType
+}
+
+// manifest returns the raw artifact manifest, with the given config and content blobs, that references the subject.
+func (r Referrer) manifest(subject, configDesc, layerDesc v1.Descriptor) ([]byte, error) {
+	subjectCopy := subject
+	manifest := v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIManifestSchema1,
+		Config:        configDesc,
+		Layers:        []v1.Descriptor{layerDesc},
+		Annotations:   r.Annotations,
+		Subject:       &subjectCopy,
+	}
+
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode artifact manifest: %w", err)
+	}
+	return raw, nil
+}
+
+func writeBlob(path layout.Path, mediaType types.MediaType, content []byte) (v1.Descriptor, error) {
+	digest, size, err := v1.SHA256(bytes.NewReader(content))
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+
+	if err := path.WriteBlob(digest, io.NopCloser(bytes.NewReader(content))); err != nil {
+		return v1.Descriptor{}, err
+	}
+
+	return v1.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest,
+		Size:      size,
+	}, nil
+}
+
+// layoutReferrers returns a resolver for all artifact manifests within the given OCI layout index (or nested indexes)
+// that reference a subject image, allowing for offline verification of co-located signatures and attestations.
+func layoutReferrers(index v1.ImageIndex) image.ReferrersResolver {
+	return func(_ context.Context, subject v1.Hash) ([]image.Referrer, error) {
+		return findLayoutReferrers(index, subject, make(map[v1.Hash]struct{}))
+	}
+}
+
+func findLayoutReferrers(index v1.ImageIndex, subject v1.Hash, seen map[v1.Hash]struct{}) ([]image.Referrer, error) {
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	var referrers []image.Referrer
+	for _, desc := range indexManifest.Manifests {
+		if _, ok := seen[desc.Digest]; ok || desc.Digest == subject {
+			continue
+		}
+		seen[desc.Digest] = struct{}{}
+
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := index.ImageIndex(desc.Digest)
+			if err != nil {
+				log.WithFields("digest", desc.Digest.String(), "error", err).Trace("skipping unreadable nested OCI index")
+				continue
+			}
+			found, err := findLayoutReferrers(child, subject, seen)
+			if err != nil {
+				log.WithFields("digest", desc.Digest.String(), "error", err).Trace("skipping unreadable nested OCI index")
+				continue
+			}
+			referrers = append(referrers, found...)
+
+		case desc.MediaType.IsImage():
+			artifact, err := index.Image(desc.Digest)
+			if err != nil {
+				continue
+			}
+			manifest, err := artifact.Manifest()
+			if err != nil {
+				// the manifest blob may not be present (e.g. other platforms of a partially exported index)
+				continue
+			}
+			if image.ReferencesSubject(desc, manifest, subject) {
+				referrers = append(referrers, image.NewReferrer(desc, artifact))
+			}
+		}
+	}
+	return referrers, nil
+}
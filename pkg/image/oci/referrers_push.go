@@ -0,0 +1,127 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// PushReferrers pushes each referrer artifact (e.g. an SBOM produced by analyzing the image) to the repository of the
+// given image reference as an artifact manifest that references the image manifest as its subject, such that the
+// artifact is listed by the OCI referrers API (or the referrers tag schema fallback for registries without support).
+// Tags are resolved to the manifest digest they currently refer to (which may be an image index). Credentials and TLS
+// configuration are taken from the registry options, as with the registry provider. The descriptors of the pushed
+// artifact manifests are returned.
+func PushReferrers(ctx context.Context, subjectRef string, registryOptions image.RegistryOptions, referrers ...Referrer) ([]v1.Descriptor, error) {
+	ref, err := name.ParseReference(subjectRef, prepareReferenceOptions(registryOptions)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse registry reference=%q: %w", subjectRef, err)
+	}
+
+	options := prepareRemoteOptions(ctx, ref, registryOptions, nil, "")
+
+	subject, err := remote.Head(ref, options...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve subject %q: %w", subjectRef, err)
+	}
+
+	var pushed []v1.Descriptor
+	for idx, r := range referrers {
+		desc, err := pushReferrer(ref.Context(), *subject, r, options)
+		if err != nil {
+			return pushed, fmt.Errorf("unable to push referrer %d (artifactType=%q): %w", idx, r.ArtifactType, err)
+		}
+		log.WithFields("subject", ref.Context().Digest(subject.Digest.String()).String(), "artifact", desc.Digest, "artifactType", r.ArtifactType).Debug("pushed referrer")
+		pushed = append(pushed, desc)
+	}
+	return pushed, nil
+}
+
+func pushReferrer(repo name.Repository, subject v1.Descriptor, r Referrer, options []remote.Option) (v1.Descriptor, error) {
+	if r.ArtifactType == "" {
+		return v1.Descriptor{}, fmt.Errorf("no artifact type provided")
+	}
+
+	configDesc, err := pushBlob(repo, types.MediaType(r.ArtifactType), emptyJSON, options)
+	if err != nil {
+		return v1.Descriptor{}, fmt.Errorf("unable to push artifact config: %w", err)
+	}
+
+	layerDesc, err := pushBlob(repo, r.mediaType(), r.Content, options)
+	if err != nil {
+		return v1.Descriptor{}, fmt.Errorf("unable to push artifact content: %w", err)
+	}
+
+	// note: the subject descriptor only identifies the subject manifest (platform and annotations are not carried over)
+	raw, err := r.manifest(v1.Descriptor{MediaType: subject.MediaType, Digest: subject.Digest, Size: subject.Size}, configDesc, layerDesc)
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+
+	manifest := rawManifest{raw: raw, mediaType: types.OCIManifestSchema1}
+	desc, err := manifest.descriptor()
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+
+	if err := remote.Put(repo.Digest(desc.Digest.String()), manifest, options...); err != nil {
+		return v1.Descriptor{}, fmt.Errorf("unable to push artifact manifest: %w", err)
+	}
+
+	desc.ArtifactType = r.ArtifactType
+	desc.Annotations = r.Annotations
+	return desc, nil
+}
+
+func pushBlob(repo name.Repository, mediaType types.MediaType, content []byte, options []remote.Option) (v1.Descriptor, error) {
+	layer := static.NewLayer(content, mediaType)
+	if err := remote.WriteLayer(repo, layer, options...); err != nil {
+		return v1.Descriptor{}, err
+	}
+
+	digest, err := layer.Digest()
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+
+	return v1.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest,
+		Size:      int64(len(content)),
+	}, nil
+}
+
+// rawManifest is a remote.Taggable for an already encoded manifest.
+type rawManifest struct {
+	raw       []byte
+	mediaType types.MediaType
+}
+
+func (m rawManifest) RawManifest() ([]byte, error) {
+	return m.raw, nil
+}
+
+func (m rawManifest) MediaType() (types.MediaType, error) {
+	return m.mediaType, nil
+}
+
+func (m rawManifest) descriptor() (v1.Descriptor, error) {
+	digest, size, err := v1.SHA256(bytes.NewReader(m.raw))
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	return v1.Descriptor{
+		MediaType: m.mediaType,
+		Digest:    digest,
+		Size:      size,
+	}, nil
+}
@@ -0,0 +1,235 @@
+package oci
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func Test_AppendReferrers(t *testing.T) {
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+
+	p, err := layout.Write(t.TempDir(), empty.Index)
+	require.NoError(t, err)
+	require.NoError(t, p.AppendImage(img))
+
+	rawManifest, err := img.RawManifest()
+	require.NoError(t, err)
+	mediaType, err := img.MediaType()
+	require.NoError(t, err)
+
+	subject, err := SubjectDescriptor(&image.Image{
+		Metadata: image.Metadata{
+			MediaType:   mediaType,
+			RawManifest: rawManifest,
+		},
+	})
+	require.NoError(t, err)
+
+	imgDigest, err := img.Digest()
+	require.NoError(t, err)
+	assert.Equal(t, imgDigest, subject.Digest)
+
+	err = AppendReferrers(p, subject, Referrer{
+		ArtifactType: "application/vnd.in-toto+json",
+		Content:      []byte(`{"_type":"https://in-toto.io/Statement/v1"}`),
+		Annotations:  map[string]string{"org.opencontainers.image.title": "attestation"},
+	})
+	require.NoError(t, err)
+
+	index, err := p.ImageIndex()
+	require.NoError(t, err)
+	indexManifest, err := index.IndexManifest()
+	require.NoError(t, err)
+	require.Len(t, indexManifest.Manifests, 2)
+
+	artifactDesc := indexManifest.Manifests[1]
+	assert.Equal(t, "application/vnd.in-toto+json", artifactDesc.ArtifactType)
+	assert.Equal(t, "attestation", artifactDesc.Annotations["org.opencontainers.image.title"])
+
+	artifact, err := index.Image(artifactDesc.Digest)
+	require.NoError(t, err)
+	artifactManifest, err := artifact.Manifest()
+	require.NoError(t, err)
+	require.NotNil(t, artifactManifest.Subject)
+	assert.Equal(t, subject.Digest, artifactManifest.Subject.Digest)
+
+	// the image is still selectable even though referrers are co-located within the layout
+	desc, _, err := selectManifest(index, nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, imgDigest, desc.Digest)
+}
+
+func Test_AppendReferrers_missingArtifactType(t *testing.T) {
+	p, err := layout.Write(t.TempDir(), empty.Index)
+	require.NoError(t, err)
+
+	err = AppendReferrers(p, v1.Descriptor{}, Referrer{Content: []byte("data")})
+	require.Error(t, err)
+}
+
+func Test_DirectoryProvider_Referrers(t *testing.T) {
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+
+	p, err := layout.Write(t.TempDir(), empty.Index)
+	require.NoError(t, err)
+	require.NoError(t, p.AppendImage(img))
+
+	subject, err := partial.Descriptor(img)
+	require.NoError(t, err)
+
+	// an artifact referencing the image via the OCI subject field
+	require.NoError(t, AppendReferrers(p, *subject, Referrer{
+		ArtifactType: inTotoMediaType,
+		Content:      []byte(`{"_type":"https://in-toto.io/Statement/v1"}`),
+	}))
+
+	// a buildkit attestation manifest referencing the image via annotations
+	require.NoError(t, p.AppendImage(attestationManifest(t, nil), layout.WithAnnotations(map[string]string{
+		image.DockerReferenceTypeAnnotation:   image.DockerAttestationManifestType,
+		image.DockerReferenceDigestAnnotation: subject.Digest.String(),
+	})))
+
+	// an unrelated artifact
+	require.NoError(t, AppendReferrers(p, v1.Descriptor{MediaType: types.OCIManifestSchema1, Digest: v1.Hash{Algorithm: "sha256", Hex: strings.Repeat("0", 64)}}, Referrer{
+		ArtifactType: inTotoMediaType,
+		Content:      []byte(`{}`),
+	}))
+
+	tmpDirGen := file.NewTempDirGenerator("tempDir")
+	t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+	provided, err := NewDirectoryProvider(tmpDirGen, string(p)).Provide(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, subject.Digest.String(), provided.Metadata.ManifestDigest)
+
+	referrers, err := provided.Referrers(context.Background())
+	require.NoError(t, err)
+	require.Len(t, referrers, 2)
+
+	for _, r := range referrers {
+		assert.Equal(t, inTotoMediaType, r.ArtifactType())
+		blobs, err := r.Blobs()
+		require.NoError(t, err)
+		require.Len(t, blobs, 1)
+	}
+
+	referrers, err = provided.Referrers(context.Background(), "application/vnd.dev.sigstore.bundle+json")
+	require.NoError(t, err)
+	assert.Empty(t, referrers)
+}
+
+func Test_RegistryProvider_Referrers(t *testing.T) {
+	registryHost := makeRegistry(t)
+	pushRandomRegistryImage(t, registryHost, "my-image", "the-tag")
+
+	ref, err := name.ParseReference(registryHost+"/my-image:the-tag", name.Insecure)
+	require.NoError(t, err)
+
+	desc, err := remote.Get(ref)
+	require.NoError(t, err)
+
+	artifact := attestationManifest(t, &desc.Descriptor)
+	artifactDigest, err := artifact.Digest()
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref.Context().Digest(artifactDigest.String()), artifact))
+
+	generator := file.TempDirGenerator{}
+	defer generator.Cleanup()
+
+	provided, err := NewRegistryProvider(&generator, image.RegistryOptions{InsecureUseHTTP: true}, ref.String(), nil).Provide(context.Background())
+	require.NoError(t, err)
+
+	referrers, err := provided.Referrers(context.Background(), inTotoMediaType)
+	require.NoError(t, err)
+	require.Len(t, referrers, 1)
+	assert.Equal(t, artifactDigest, referrers[0].Descriptor.Digest)
+}
+
+const inTotoMediaType = "application/vnd.in-toto+json"
+
+// attestationManifest creates an in-toto attestation artifact image, optionally referencing the given subject.
+func attestationManifest(t *testing.T, subject *v1.Descriptor) v1.Image {
+	t.Helper()
+
+	artifact := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+	artifact = mutate.ConfigMediaType(artifact, types.OCIConfigJSON)
+
+	artifact, err := mutate.Append(artifact, mutate.Addendum{
+		Layer:     static.NewLayer([]byte(`{"_type":"https://in-toto.io/Statement/v1"}`), inTotoMediaType),
+		MediaType: inTotoMediaType,
+	})
+	require.NoError(t, err)
+
+	if subject != nil {
+		artifact = mutate.ConfigMediaType(artifact, inTotoMediaType)
+		artifact = mutate.Subject(artifact, *subject).(v1.Image)
+	}
+	return artifact
+}
+
+func Test_PushReferrers(t *testing.T) {
+	registryHost := makeRegistry(t)
+	pushRandomRegistryImage(t, registryHost, "my-image", "the-tag")
+
+	const sbomType = "application/spdx+json"
+	sbom := []byte(`{"spdxVersion":"SPDX-2.3"}`)
+	registryOptions := image.RegistryOptions{InsecureUseHTTP: true}
+
+	pushed, err := PushReferrers(context.Background(), registryHost+"/my-image:the-tag", registryOptions, Referrer{
+		ArtifactType: sbomType,
+		Content:      sbom,
+		Annotations:  map[string]string{"org.opencontainers.image.title": "sbom"},
+	})
+	require.NoError(t, err)
+	require.Len(t, pushed, 1)
+	assert.Equal(t, sbomType, pushed[0].ArtifactType)
+
+	generator := file.TempDirGenerator{}
+	defer generator.Cleanup()
+
+	provided, err := NewRegistryProvider(&generator, registryOptions, registryHost+"/my-image:the-tag", nil).Provide(context.Background())
+	require.NoError(t, err)
+
+	referrers, err := provided.Referrers(context.Background(), sbomType)
+	require.NoError(t, err)
+	require.Len(t, referrers, 1)
+	assert.Equal(t, pushed[0].Digest, referrers[0].Descriptor.Digest)
+
+	blobs, err := referrers[0].Blobs()
+	require.NoError(t, err)
+	require.Len(t, blobs, 1)
+	reader, err := blobs[0].Compressed()
+	require.NoError(t, err)
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, sbom, content)
+
+	// referrers without an artifact type are rejected
+	_, err = PushReferrers(context.Background(), registryHost+"/my-image:the-tag", registryOptions, Referrer{Content: sbom})
+	require.Error(t, err)
+
+	// the subject must exist
+	_, err = PushReferrers(context.Background(), registryHost+"/my-image:missing", registryOptions, Referrer{ArtifactType: sbomType, Content: sbom})
+	require.Error(t, err)
+}
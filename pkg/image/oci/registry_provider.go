@@ -3,14 +3,16 @@ package oci
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
 	"runtime"
+	"strings"
 
-	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	containerregistryV1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 
 	"github.com/anchore/stereoscope/internal/log"
 	"github.com/anchore/stereoscope/pkg/file"
@@ -35,6 +37,9 @@ type registryImageProvider struct {
 	imageStr        string
 	platform        *image.Platform
 	registryOptions image.RegistryOptions
+	// indexDigest is the digest of the index the image manifest was selected from (for index entries, see
+	// ProvideIndex), nil otherwise
+	indexDigest *containerregistryV1.Hash
 }
 
 func (p *registryImageProvider) Name() string {
@@ -43,6 +48,10 @@ func (p *registryImageProvider) Name() string {
 
 // Provide an image object that represents the cached docker image tar fetched a registry.
 func (p *registryImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	if err := image.CheckNetworkAccess(ctx, fmt.Sprintf("pull registry image=%q", p.imageStr)); err != nil {
+		return nil, err
+	}
+
 	log.Debugf("pulling image info directly from registry image=%q", p.imageStr)
 
 	imageTempDir, err := p.tmpDirGen.NewDirectory("oci-registry-image")
@@ -55,26 +64,56 @@ func (p *registryImageProvider) Provide(ctx context.Context) (*image.Image, erro
 		return nil, fmt.Errorf("unable to parse registry reference=%q: %+v", p.imageStr, err)
 	}
 
+	if ref, err = trustedReference(ctx, ref, p.registryOptions); err != nil {
+		return nil, err
+	}
+
 	platform := defaultPlatformIfNil(p.platform)
 
-	options := prepareRemoteOptions(ctx, ref, p.registryOptions, platform)
+	var partialDir string
+	if image.RetryPolicyFromContext(ctx) != nil {
+		// interrupted blob downloads are resumed from the partial blobs persisted here
+		if partialDir, err = p.tmpDirGen.NewDirectory("oci-registry-partial-blobs"); err != nil {
+			return nil, err
+		}
+	}
+
+	options := prepareRemoteOptions(ctx, ref, p.registryOptions, platform, partialDir)
 
 	descriptor, err := remote.Get(ref, options...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get image descriptor from registry: %+v", err)
+		return nil, registryHint(fmt.Errorf("failed to get image descriptor from registry: %w", err), err, ref.Context().RegistryStr())
 	}
 
-	img, err := descriptor.Image()
+	img, err := registryImage(descriptor, platform)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get image from registry: %+v", err)
 	}
 
+	if policy := image.SignaturePolicyFromContext(ctx); policy != nil {
+		if err := verifyImageSignatures(ref.Context(), descriptor, img, p.indexDigest, *policy, options); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.registryOptions.LazyPull {
+		if img, err = lazyRegistryImage(ctx, img, ref, p.registryOptions, imageTempDir); err != nil {
+			return nil, fmt.Errorf("failed to prepare lazy image layers: %w", err)
+		}
+	}
+
 	// craft a repo digest from the registry reference and the known digest
 	// note: the descriptor is fetched from the registry, and the descriptor digest is the same as the repo digest
 	repoDigest := fmt.Sprintf("%s/%s@%s", ref.Context().RegistryStr(), ref.Context().RepositoryStr(), descriptor.Digest.String())
 
 	metadata := []image.AdditionalMetadata{
 		image.WithRepoDigests(repoDigest),
+		image.WithReferrers(registryReferrers(ref.Context(), descriptorIndex(descriptor), options)),
+	}
+
+	if descriptor.MediaType.IsIndex() {
+		// the image manifest was selected from the index for the platform
+		metadata = append(metadata, image.WithIndex(descriptor.Manifest))
 	}
 
 	// make a best effort to get the manifest, should not block getting an image though if it fails
@@ -89,14 +128,158 @@ func (p *registryImageProvider) Provide(ctx context.Context) (*image.Image, erro
 		)
 	}
 
+	if p.registryOptions.MaxParallelism > 1 {
+		metadata = append(metadata, image.WithMaxParallelism(p.registryOptions.MaxParallelism))
+	}
+
 	out := image.New(img, p.tmpDirGen, imageTempDir, metadata...)
-	err = out.Read()
+	err = out.ReadContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return out, err
 }
 
+// ProvideIndex provides all platform images of the image (see image.Index), where each platform image is only pulled
+// when provided. Images that are not an index are provided as an index with a single entry, and nested indexes and
+// attestation manifests are not included.
+func (p *registryImageProvider) ProvideIndex(ctx context.Context) (*image.Index, error) {
+	if err := image.CheckNetworkAccess(ctx, fmt.Sprintf("pull registry image index=%q", p.imageStr)); err != nil {
+		return nil, err
+	}
+
+	ref, err := name.ParseReference(p.imageStr, prepareReferenceOptions(p.registryOptions)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse registry reference=%q: %+v", p.imageStr, err)
+	}
+
+	if ref, err = trustedReference(ctx, ref, p.registryOptions); err != nil {
+		return nil, err
+	}
+
+	descriptor, err := remote.Get(ref, prepareRemoteOptions(ctx, ref, p.registryOptions, nil, "")...)
+	if err != nil {
+		return nil, registryHint(fmt.Errorf("failed to get image descriptor from registry: %w", err), err, ref.Context().RegistryStr())
+	}
+
+	if !descriptor.MediaType.IsIndex() {
+		img, err := descriptor.Image()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get image from registry: %+v", err)
+		}
+		cfg, err := img.ConfigFile()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get image config from registry: %+v", err)
+		}
+		platform := image.PlatformFromV1(cfg.Platform())
+		return &image.Index{
+			Entries: []image.IndexEntry{image.NewIndexEntry(descriptor.Descriptor, platform, p.entryProvider(ref, nil, descriptor.Digest, platform))},
+		}, nil
+	}
+
+	index, err := descriptor.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image index from registry: %+v", err)
+	}
+
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse image index from registry: %+v", err)
+	}
+
+	out := &image.Index{
+		Digest:      descriptor.Digest.String(),
+		RawManifest: descriptor.Manifest,
+	}
+	for _, desc := range indexManifest.Manifests {
+		if !desc.MediaType.IsImage() || image.IsAttestationManifest(desc, nil) {
+			continue
+		}
+		platform := image.PlatformFromV1(desc.Platform)
+		out.Entries = append(out.Entries, image.NewIndexEntry(desc, platform, p.entryProvider(ref, &descriptor.Digest, desc.Digest, platform)))
+	}
+	return out, nil
+}
+
+// trustedReference returns the digest reference signed within the Docker Content Trust data for tags of registries
+// within the content trust policy (see image.ContextWithContentTrustPolicy), otherwise the given reference.
+func trustedReference(ctx context.Context, ref name.Reference, registryOptions image.RegistryOptions) (name.Reference, error) {
+	policy := image.ContentTrustPolicyFromContext(ctx)
+	tag, isTag := ref.(name.Tag)
+	if policy == nil || !isTag {
+		// digest references are immutable, thus there is no tag to digest mapping to verify
+		return ref, nil
+	}
+
+	trust, ok := policy.ForRegistry(tag.RegistryStr())
+	if !ok {
+		return ref, nil
+	}
+	return resolveTrustedDigest(ctx, tag, trust, policy.TrustDir, registryOptions)
+}
+
+// entryProvider returns a provider for the platform image with the given manifest digest within the repository of the
+// given reference, selected from the index with the given digest (nil for single-platform images).
+func (p *registryImageProvider) entryProvider(ref name.Reference, indexDigest *containerregistryV1.Hash, digest containerregistryV1.Hash, platform image.Platform) image.Provider {
+	var want *image.Platform
+	if platform != (image.Platform{}) {
+		want = &platform
+	}
+	return &indexEntryProvider{
+		name:      Registry,
+		tmpDirGen: p.tmpDirGen,
+		newProvider: func(tmpDirGen *file.TempDirGenerator) image.Provider {
+			return &registryImageProvider{
+				tmpDirGen:       tmpDirGen,
+				imageStr:        ref.Context().Digest(digest.String()).String(),
+				platform:        want,
+				registryOptions: p.registryOptions,
+				indexDigest:     indexDigest,
+			}
+		},
+	}
+}
+
+// registryImage returns the image for the given descriptor, selecting the image matching the given platform when the
+// descriptor is an index. Attestation manifests (with the "unknown/unknown" platform) are never selected.
+func registryImage(descriptor *remote.Descriptor, platform *image.Platform) (containerregistryV1.Image, error) {
+	if !descriptor.MediaType.IsIndex() || platform == nil {
+		return descriptor.Image()
+	}
+
+	index, err := descriptor.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	var available []string
+	var attestations int
+	for _, desc := range indexManifest.Manifests {
+		if image.IsAttestationManifest(desc, nil) {
+			attestations++
+			continue
+		}
+		if desc.Platform == nil || !desc.MediaType.IsImage() {
+			continue
+		}
+		if platform.Matches(desc.Platform.OS, desc.Platform.Architecture, desc.Platform.Variant) {
+			return index.Image(desc.Digest)
+		}
+		available = append(available, desc.Platform.String())
+	}
+
+	if attestations > 0 {
+		log.WithFields("index", descriptor.Digest.String(), "count", attestations).Warn("skipped attestation manifests while selecting platform")
+	}
+
+	return nil, image.MarkError(fmt.Errorf("no image found in index for platform %q (available: %s)", platform.String(), strings.Join(available, ", ")), image.ErrPlatformMismatch)
+}
+
 func prepareReferenceOptions(registryOptions image.RegistryOptions) []name.Option {
 	var options []name.Option
 	if registryOptions.InsecureUseHTTP {
@@ -105,7 +288,9 @@ func prepareReferenceOptions(registryOptions image.RegistryOptions) []name.Optio
 	return options
 }
 
-func prepareRemoteOptions(ctx context.Context, ref name.Reference, registryOptions image.RegistryOptions, p *image.Platform) (options []remote.Option) {
+// prepareRemoteOptions returns the options for registry requests for the given reference, where interrupted blob
+// downloads are resumed from partial blobs persisted in the given directory (when set, and with a retry policy).
+func prepareRemoteOptions(ctx context.Context, ref name.Reference, registryOptions image.RegistryOptions, p *image.Platform, partialDir string) (options []remote.Option) {
 	options = append(options, remote.WithContext(ctx))
 
 	if p != nil {
@@ -129,21 +314,63 @@ func prepareRemoteOptions(ctx context.Context, ref name.Reference, registryOptio
 	case registryOptions.Keychain != nil:
 		options = append(options, remote.WithAuthFromKeychain(registryOptions.Keychain))
 	default:
-		// use the Keychain specified from a docker config file.
+		// use the Keychain specified from a docker config file (within the environment overrides, when given).
 		log.Debugf("no registry credentials configured for %q, using the default keychain", registryName)
-		options = append(options, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+		options = append(options, remote.WithAuthFromKeychain(registryOptions.ResolveKeychain(ctx)))
 	}
 
+	var httpTransport http.RoundTripper
 	tlsConfig, err := registryOptions.TLSConfig(registryName)
 	if err != nil {
 		log.Warn("unable to configure TLS transport: %w", err)
 	} else if tlsConfig != nil {
-		options = append(options, remote.WithTransport(getTransport(tlsConfig)))
+		httpTransport = getTransport(tlsConfig)
+	}
+
+	if image.BandwidthLimiterFromContext(ctx) != nil {
+		if httpTransport == nil {
+			httpTransport = remote.DefaultTransport
+		}
+		httpTransport = &bandwidthTransport{inner: httpTransport}
+	}
+
+	// note: with a retry policy, requests responding with a transient status code are retried by the policy instead
+	// of the (fixed) retries of the registry client
+	if image.RetryPolicyFromContext(ctx) != nil {
+		if httpTransport == nil {
+			httpTransport = remote.DefaultTransport
+		}
+		if partialDir != "" {
+			httpTransport = &resumeTransport{inner: httpTransport, dir: partialDir}
+		}
+		httpTransport = &retryTransport{inner: httpTransport}
+		options = append(options, remote.WithRetryStatusCodes())
+	}
+
+	if httpTransport != nil {
+		options = append(options, remote.WithTransport(httpTransport))
 	}
 
 	return options
 }
 
+// registryHint attaches a remediation hint to the given error when the registry denied access (according to the
+// cause of the error).
+func registryHint(err, cause error, registryName string) error {
+	var transportErr *transport.Error
+	if !errors.As(cause, &transportErr) {
+		return err
+	}
+	switch transportErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return image.WithHint(err, image.Hint{
+			ID:      image.HintRegistryLogin,
+			Message: fmt.Sprintf("log in to the registry (e.g. 'docker login %s') or provide registry credentials; note that some registries deny access to images that do not exist", registryName),
+		})
+	}
+	return err
+}
+
 func getTransport(tlsConfig *tls.Config) *http.Transport {
 	// use the default transport to inherit existing default options (including proxy options)
 	transport := http.DefaultTransport.(*http.Transport).Clone()
@@ -13,6 +13,9 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/stretchr/testify/assert"
@@ -205,3 +208,170 @@ func makeRegistry(t *testing.T) (registryHost string) {
 	t.Cleanup(ts.Close)
 	return strings.TrimPrefix(ts.URL, "http://")
 }
+
+func Test_RegistryProvider_SkipsAttestationManifests(t *testing.T) {
+	registryHost := makeRegistry(t)
+
+	amd64Img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	arm64Img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	attestationImg, err := random.Image(64, 1)
+	require.NoError(t, err)
+
+	index := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: attestationImg, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "unknown", Architecture: "unknown"}}},
+		mutate.IndexAddendum{Add: amd64Img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: arm64Img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}}},
+	)
+
+	ref, err := name.ParseReference(registryHost+"/multi:latest", name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(ref, index))
+
+	tests := []struct {
+		platform string
+		want     v1.Image
+		wantErr  require.ErrorAssertionFunc
+	}{
+		{platform: "linux/amd64", want: amd64Img},
+		{platform: "linux/arm64", want: arm64Img},
+		{platform: "linux/s390x", wantErr: require.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.platform, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+			platform, err := image.NewPlatform(tt.platform)
+			require.NoError(t, err)
+
+			tmpDirGen := file.NewTempDirGenerator("test")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			img, err := NewRegistryProvider(tmpDirGen, image.RegistryOptions{InsecureUseHTTP: true}, ref.String(), platform).Provide(context.Background())
+			tt.wantErr(t, err)
+			if err != nil {
+				return
+			}
+
+			wantID, err := tt.want.ConfigName()
+			require.NoError(t, err)
+			assert.Equal(t, wantID.String(), img.Metadata.ID)
+		})
+	}
+}
+
+func Test_RegistryProvider_ProvideIndex(t *testing.T) {
+	registryHost := makeRegistry(t)
+
+	amd64Img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	arm64Img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	attestationImg, err := random.Image(64, 1)
+	require.NoError(t, err)
+
+	index := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: amd64Img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: arm64Img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}}},
+		mutate.IndexAddendum{Add: attestationImg, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "unknown", Architecture: "unknown"}}},
+	)
+
+	indexRef, err := name.ParseReference(registryHost+"/multi:latest", name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(indexRef, index))
+
+	singleRef, err := name.ParseReference(registryHost+"/single:latest", name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(singleRef, amd64Img))
+
+	indexDigest, err := index.Digest()
+	require.NoError(t, err)
+
+	tmpDirGen := file.NewTempDirGenerator("test")
+	t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+	options := image.RegistryOptions{InsecureUseHTTP: true}
+
+	t.Run("index", func(t *testing.T) {
+		provider := NewRegistryProvider(tmpDirGen, options, indexRef.String(), nil).(image.IndexProvider)
+		idx, err := provider.ProvideIndex(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, indexDigest.String(), idx.Digest)
+		assert.NotEmpty(t, idx.RawManifest)
+		assert.Equal(t, []image.Platform{
+			{OS: "linux", Architecture: "amd64"},
+			{OS: "linux", Architecture: "arm64"}, // normalized from arm64/v8
+		}, idx.Platforms())
+
+		var ids []string
+		require.NoError(t, idx.ForEach(context.Background(), func(_ image.IndexEntry, img *image.Image) error {
+			ids = append(ids, img.Metadata.ID)
+			return nil
+		}))
+
+		amd64ID, err := amd64Img.ConfigName()
+		require.NoError(t, err)
+		arm64ID, err := arm64Img.ConfigName()
+		require.NoError(t, err)
+		assert.Equal(t, []string{amd64ID.String(), arm64ID.String()}, ids)
+	})
+
+	t.Run("single image", func(t *testing.T) {
+		provider := NewRegistryProvider(tmpDirGen, options, singleRef.String(), nil).(image.IndexProvider)
+		idx, err := provider.ProvideIndex(context.Background())
+		require.NoError(t, err)
+
+		assert.Empty(t, idx.Digest)
+		require.Len(t, idx.Entries, 1)
+
+		img, err := idx.Entries[0].Provide(context.Background())
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = img.Cleanup() })
+
+		wantID, err := amd64Img.ConfigName()
+		require.NoError(t, err)
+		assert.Equal(t, wantID.String(), img.Metadata.ID)
+	})
+}
+
+func Test_Registry_Provide_HintsLogin(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		wantHint bool
+	}{
+		{name: "unauthorized", status: http.StatusUnauthorized, wantHint: true},
+		{name: "forbidden", status: http.StatusForbidden, wantHint: true},
+		{name: "not found", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			t.Cleanup(ts.Close)
+			registryHost := strings.TrimPrefix(ts.URL, "http://")
+
+			tmpDirGen := file.NewTempDirGenerator("test")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			provider := NewRegistryProvider(tmpDirGen, image.RegistryOptions{InsecureUseHTTP: true}, registryHost+"/private:latest", nil)
+			_, err := provider.Provide(context.Background())
+			require.Error(t, err)
+
+			var ids []string
+			for _, hint := range image.Hints(err) {
+				ids = append(ids, hint.ID)
+				assert.Contains(t, hint.Message, "docker login "+registryHost)
+			}
+			if tt.wantHint {
+				assert.Equal(t, []string{image.HintRegistryLogin}, ids)
+			} else {
+				assert.Empty(t, ids)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	containerregistryV1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// Referrers discovers the artifacts (e.g. SBOMs, signatures, and attestations) attached to the image with the given
+// reference within a registry, optionally filtered to the given artifact types, without pulling the image itself. When
+// the reference is an index the image for the given platform (or the host platform when none is given) is the subject,
+// where artifacts attached to the index are included as well. Credentials and TLS configuration are taken from the
+// registry options, as with the registry provider. The artifact content is fetched on demand (see
+// image.Referrer.Blobs).
+func Referrers(ctx context.Context, imageRef string, registryOptions image.RegistryOptions, platform *image.Platform, artifactTypes ...string) ([]image.Referrer, error) {
+	ref, err := name.ParseReference(imageRef, prepareReferenceOptions(registryOptions)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse registry reference=%q: %w", imageRef, err)
+	}
+
+	platform = defaultPlatformIfNil(platform)
+	options := prepareRemoteOptions(ctx, ref, registryOptions, platform, "")
+
+	descriptor, err := remote.Get(ref, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image descriptor from registry: %w", err)
+	}
+
+	img, err := registryImage(descriptor, platform)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image from registry: %w", err)
+	}
+
+	subject, err := img.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get image manifest digest: %w", err)
+	}
+
+	referrers, err := registryReferrers(ref.Context(), descriptorIndex(descriptor), options)(ctx, subject)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list referrers: %w", err)
+	}
+	return image.FilterReferrers(referrers, artifactTypes...), nil
+}
+
+// descriptorIndex returns the index for the given descriptor (nil when the descriptor is not an index).
+func descriptorIndex(descriptor *remote.Descriptor) containerregistryV1.ImageIndex {
+	if !descriptor.MediaType.IsIndex() {
+		return nil
+	}
+	index, err := descriptor.ImageIndex()
+	if err != nil {
+		log.WithFields("digest", descriptor.Digest.String(), "error", err).Debug("unable to read image index for referrers")
+		return nil
+	}
+	return index
+}
+
+// registryReferrers returns a resolver for all artifacts referencing a subject within the given repository (using the
+// OCI referrers API, or the referrers tag schema fallback for registries without support). When the subject was
+// selected from an index, the artifacts referencing the index and the buildkit attestation manifests within the index
+// that reference the subject are included as well.
+func registryReferrers(repo name.Repository, index containerregistryV1.ImageIndex, options []remote.Option) image.ReferrersResolver {
+	return func(ctx context.Context, subject containerregistryV1.Hash) ([]image.Referrer, error) {
+		options := append(append([]remote.Option{}, options...), remote.WithContext(ctx))
+		seen := make(map[containerregistryV1.Hash]struct{})
+
+		referrers, err := fetchRegistryReferrers(repo, subject, seen, options)
+		if err != nil || index == nil {
+			return referrers, err
+		}
+
+		indexDigest, err := index.Digest()
+		if err != nil {
+			return nil, err
+		}
+		if indexDigest != subject {
+			indexReferrers, err := fetchRegistryReferrers(repo, indexDigest, seen, options)
+			if err != nil {
+				return nil, err
+			}
+			referrers = append(referrers, indexReferrers...)
+		}
+
+		indexManifest, err := index.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+		for _, desc := range indexManifest.Manifests {
+			if _, ok := seen[desc.Digest]; ok || !image.ReferencesSubject(desc, nil, subject) {
+				continue
+			}
+			seen[desc.Digest] = struct{}{}
+
+			artifact, err := index.Image(desc.Digest)
+			if err != nil {
+				return nil, fmt.Errorf("unable to fetch attestation manifest %q: %w", desc.Digest, err)
+			}
+			referrers = append(referrers, image.NewReferrer(desc, artifact))
+		}
+		return referrers, nil
+	}
+}
+
+// fetchRegistryReferrers lists the artifacts referencing the given subject within the repository (skipping artifacts
+// that were already seen).
+func fetchRegistryReferrers(repo name.Repository, subject containerregistryV1.Hash, seen map[containerregistryV1.Hash]struct{}, options []remote.Option) ([]image.Referrer, error) {
+	index, err := remote.Referrers(repo.Digest(subject.String()), options...)
+	if err != nil {
+		return nil, err
+	}
+
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	var referrers []image.Referrer
+	for _, desc := range indexManifest.Manifests {
+		if _, ok := seen[desc.Digest]; ok {
+			continue
+		}
+		seen[desc.Digest] = struct{}{}
+
+		artifact, err := remote.Image(repo.Digest(desc.Digest.String()), options...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch referrer %q: %w", desc.Digest, err)
+		}
+		referrers = append(referrers, image.NewReferrer(desc, artifact))
+	}
+	return referrers, nil
+}
@@ -0,0 +1,100 @@
+package oci
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func Test_Referrers(t *testing.T) {
+	registryHost := makeRegistry(t)
+	registryOptions := image.RegistryOptions{InsecureUseHTTP: true}
+
+	amd64Img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	arm64Img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	amd64Digest, err := amd64Img.Digest()
+	require.NoError(t, err)
+
+	// buildkit stores attestations within the index, referencing the image by annotation
+	buildkitAttestation := attestationManifest(t, nil)
+	buildkitDigest, err := buildkitAttestation.Digest()
+	require.NoError(t, err)
+
+	index := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: amd64Img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: arm64Img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}}},
+		mutate.IndexAddendum{Add: buildkitAttestation, Descriptor: v1.Descriptor{
+			Platform: &v1.Platform{OS: "unknown", Architecture: "unknown"},
+			Annotations: map[string]string{
+				image.DockerReferenceTypeAnnotation:   image.DockerAttestationManifestType,
+				image.DockerReferenceDigestAnnotation: amd64Digest.String(),
+			},
+		}},
+	)
+
+	ref, err := name.ParseReference(registryHost+"/multi:latest", name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(ref, index))
+
+	const sbomType = "application/spdx+json"
+
+	// an SBOM attached to the index (covering all platforms) and a signature attached to the amd64 image
+	indexSBOM, err := PushReferrers(context.Background(), ref.String(), registryOptions, Referrer{ArtifactType: sbomType, Content: []byte(`{}`)})
+	require.NoError(t, err)
+	imageSignature, err := PushReferrers(context.Background(), ref.Context().Digest(amd64Digest.String()).String(), registryOptions, Referrer{ArtifactType: "application/vnd.dev.sigstore.bundle+json", Content: []byte(`{}`)})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		platform      string
+		artifactTypes []string
+		want          []v1.Hash
+	}{
+		{
+			name:     "all referrers of the platform image",
+			platform: "linux/amd64",
+			want:     []v1.Hash{imageSignature[0].Digest, indexSBOM[0].Digest, buildkitDigest},
+		},
+		{
+			name:          "filtered by artifact type",
+			platform:      "linux/amd64",
+			artifactTypes: []string{sbomType, inTotoMediaType},
+			want:          []v1.Hash{indexSBOM[0].Digest, buildkitDigest},
+		},
+		{
+			name:     "only index referrers for other platforms",
+			platform: "linux/arm64",
+			want:     []v1.Hash{indexSBOM[0].Digest},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			platform, err := image.NewPlatform(tt.platform)
+			require.NoError(t, err)
+
+			referrers, err := Referrers(context.Background(), ref.String(), registryOptions, platform, tt.artifactTypes...)
+			require.NoError(t, err)
+
+			var got []v1.Hash
+			for _, r := range referrers {
+				got = append(got, r.Descriptor.Digest)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err = Referrers(context.Background(), registryHost+"/multi:missing", registryOptions, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,315 @@
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	containerregistryV1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// resumeTransport resumes interrupted registry blob downloads with HTTP range requests instead of downloading the
+// blob again from the start. The received content of each blob is persisted as a partial blob in the given directory,
+// such that a blob download interrupted with a transient error is resumed (according to the retry policy of the request
+// context, see image.RetryPolicy) from the end of the partial blob, as is any subsequent download of the same blob.
+// Partial blobs are removed once the download completes. Requests that already request a range (e.g. lazy pulls) are
+// not affected.
+type resumeTransport struct {
+	inner http.RoundTripper
+	dir   string
+
+	lock     sync.Mutex
+	inFlight map[containerregistryV1.Hash]struct{}
+}
+
+func (t *resumeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	digest, ok := blobRequestDigest(req)
+	if !ok || req.Method != http.MethodGet || req.Header.Get("Range") != "" || !t.acquire(digest) {
+		return t.inner.RoundTrip(req)
+	}
+
+	resp, body, err := t.download(req, digest)
+	if err != nil || body == nil {
+		t.release(digest)
+		return resp, err
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+// download requests the blob starting at the end of any partial blob, returning a body that resumes the download when
+// interrupted (nil when the response is not the blob content, e.g. a redirect or an error response).
+func (t *resumeTransport) download(req *http.Request, digest containerregistryV1.Hash) (*http.Response, *resumableBody, error) {
+	path := filepath.Join(t.dir, digest.Algorithm+"-"+digest.Hex+".partial")
+	partial, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		log.WithFields("digest", digest.String(), "error", err).Debug("unable to persist partial blob")
+		resp, err := t.inner.RoundTrip(req)
+		return resp, nil, err
+	}
+
+	info, err := partial.Stat()
+	if err != nil {
+		_ = partial.Close()
+		return nil, nil, err
+	}
+
+	offset := info.Size()
+	resp, err := t.inner.RoundTrip(rangeRequest(req, offset))
+	if err == nil && offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// the partial blob is not a prefix of the blob (e.g. it is complete, but was never verified), start over
+		_ = resp.Body.Close()
+		offset = 0
+		resp, err = t.inner.RoundTrip(req)
+	}
+	if err != nil {
+		_ = partial.Close()
+		return nil, nil, err
+	}
+
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent && contentRangeStart(resp) == offset:
+		log.WithFields("digest", digest.String(), "offset", offset).Debug("resuming blob download from partial blob")
+		_, size := parseContentRange(resp.Header.Get("Content-Range"))
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+		resp.Header.Del("Content-Range")
+		resp.ContentLength = size
+	case resp.StatusCode == http.StatusOK:
+		// the registry ignored the range request (or there is no partial blob), start over
+		offset = 0
+	default:
+		_ = partial.Close()
+		return resp, nil, nil
+	}
+
+	if err := partial.Truncate(offset); err != nil {
+		_ = partial.Close()
+		_ = resp.Body.Close()
+		return nil, nil, err
+	}
+
+	return resp, &resumableBody{
+		transport: t,
+		req:       resp.Request,
+		digest:    digest,
+		body:      resp.Body,
+		partial:   partial,
+		offset:    offset,
+		size:      resp.ContentLength,
+	}, nil
+}
+
+func (t *resumeTransport) acquire(digest containerregistryV1.Hash) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.inFlight == nil {
+		t.inFlight = make(map[containerregistryV1.Hash]struct{})
+	}
+	if _, ok := t.inFlight[digest]; ok {
+		// the same blob is concurrently downloaded, which cannot share the partial blob
+		return false
+	}
+	t.inFlight[digest] = struct{}{}
+	return true
+}
+
+func (t *resumeTransport) release(digest containerregistryV1.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.inFlight, digest)
+}
+
+// resumableBody is the content of a blob download (starting with the partial blob), which is persisted to the partial
+// blob as it is read, and is resumed with range requests when interrupted.
+type resumableBody struct {
+	transport *resumeTransport
+	req       *http.Request
+	digest    containerregistryV1.Hash
+	body      io.ReadCloser
+	partial   *os.File
+	// replayed is the amount of the partial blob read so far
+	replayed int64
+	// offset is the size of the partial blob (where the body continues)
+	offset int64
+	// size is the size of the blob (-1 when unknown)
+	size int64
+	// interrupted is the error interrupting the body (to resume with the next read)
+	interrupted error
+	// failed is the error of resuming the body
+	failed error
+	closed bool
+}
+
+func (b *resumableBody) Read(p []byte) (int, error) {
+	if b.replayed < b.offset {
+		n, err := b.partial.ReadAt(p[:min(int64(len(p)), b.offset-b.replayed)], b.replayed)
+		b.replayed += int64(n)
+		if errors.Is(err, io.EOF) && n > 0 {
+			err = nil
+		}
+		return n, err
+	}
+
+	for {
+		if b.failed != nil {
+			return 0, b.failed
+		}
+		if b.interrupted != nil {
+			b.failed = b.resume(b.interrupted)
+			b.interrupted = nil
+			continue
+		}
+
+		n, err := b.body.Read(p)
+		if n > 0 {
+			if _, werr := b.partial.WriteAt(p[:n], b.offset); werr != nil {
+				return n, fmt.Errorf("unable to persist partial blob %s: %w", b.digest, werr)
+			}
+			b.offset += int64(n)
+			b.replayed = b.offset
+		}
+
+		if errors.Is(err, io.EOF) && b.size >= 0 && b.offset < b.size {
+			// the connection was closed before the full blob was received
+			err = io.ErrUnexpectedEOF
+		}
+
+		switch {
+		case err == nil:
+			return n, nil
+		case errors.Is(err, io.EOF):
+			b.complete()
+			return n, io.EOF
+		}
+
+		b.interrupted = err
+		if n > 0 {
+			// resume with the next read (the content read so far is still returned)
+			return n, nil
+		}
+	}
+}
+
+// resume requests the rest of the blob after the download was interrupted with the given error, which is attempted
+// again according to the retry policy of the request context.
+func (b *resumableBody) resume(cause error) error {
+	_ = b.body.Close()
+	b.body = http.NoBody
+
+	interrupted := true
+	return image.Retry(b.req.Context(), fmt.Sprintf("resume blob download %s", b.digest), func() error {
+		if interrupted {
+			// the interrupted read counts as the first attempt
+			interrupted = false
+			return cause
+		}
+
+		log.WithFields("digest", b.digest.String(), "offset", b.offset).Debug("resuming interrupted blob download")
+		req := rangeRequest(b.req, b.offset)
+		resp, err := b.transport.inner.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusPartialContent || contentRangeStart(resp) != b.offset {
+			_ = resp.Body.Close()
+			if slices.Contains(image.TransientStatusCodes, resp.StatusCode) {
+				return &transport.Error{StatusCode: resp.StatusCode, Request: req}
+			}
+			return fmt.Errorf("unable to resume blob download %s at offset %d (status %d): %v", b.digest, b.offset, resp.StatusCode, cause)
+		}
+		b.body = resp.Body
+		return nil
+	})
+}
+
+// complete removes the partial blob once the full blob has been read.
+func (b *resumableBody) complete() {
+	if b.closed {
+		return
+	}
+	b.closed = true
+	_ = b.partial.Close()
+	if err := os.Remove(b.partial.Name()); err != nil {
+		log.WithFields("path", b.partial.Name(), "error", err).Trace("unable to remove partial blob")
+	}
+	b.transport.release(b.digest)
+}
+
+// Close the body, keeping the partial blob when the download is incomplete.
+func (b *resumableBody) Close() error {
+	err := b.body.Close()
+	if !b.closed {
+		b.closed = true
+		_ = b.partial.Close()
+		b.transport.release(b.digest)
+	}
+	return err
+}
+
+// blobRequestDigest returns the digest of the blob requested by the given request, which may be a redirect of a blob
+// request (e.g. to a CDN).
+func blobRequestDigest(req *http.Request) (containerregistryV1.Hash, bool) {
+	for r := req; r != nil; {
+		if _, after, ok := strings.Cut(r.URL.Path, "/blobs/"); ok && !strings.Contains(after, "/") {
+			if digest, err := containerregistryV1.NewHash(after); err == nil {
+				return digest, true
+			}
+		}
+		if r.Response == nil {
+			break
+		}
+		r = r.Response.Request
+	}
+	return containerregistryV1.Hash{}, false
+}
+
+// rangeRequest returns a copy of the given request for the content starting at the given offset.
+func rangeRequest(req *http.Request, offset int64) *http.Request {
+	if offset <= 0 {
+		return req
+	}
+	r := req.Clone(req.Context())
+	r.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	return r
+}
+
+// contentRangeStart returns the start of the range of the given partial content response (-1 when unknown).
+func contentRangeStart(resp *http.Response) int64 {
+	start, _ := parseContentRange(resp.Header.Get("Content-Range"))
+	return start
+}
+
+// parseContentRange parses the start and complete size of a "bytes <start>-<end>/<size>" content range, where either
+// is -1 when unknown (e.g. a size of "*").
+func parseContentRange(value string) (start, size int64) {
+	spec, ok := strings.CutPrefix(value, "bytes ")
+	if !ok {
+		return -1, -1
+	}
+	rng, total, _ := strings.Cut(spec, "/")
+	first, _, ok := strings.Cut(rng, "-")
+	if !ok {
+		return -1, -1
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return -1, -1
+	}
+	size, err = strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		size = -1
+	}
+	return start, size
+}
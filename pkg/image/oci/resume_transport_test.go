@@ -0,0 +1,179 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func Test_RegistryProvider_ResumeDownload(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     *image.RetryPolicy
+		wantErr    require.ErrorAssertionFunc
+		wantRanges []string
+	}{
+		{
+			name:       "resume interrupted layer download",
+			policy:     &image.RetryPolicy{Attempts: 3},
+			wantRanges: []string{"", "bytes=%d-"},
+		},
+		{
+			name:       "without a retry policy",
+			wantErr:    require.Error,
+			wantRanges: []string{""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+
+			img, err := random.Image(64*1024, 1)
+			require.NoError(t, err)
+			layers, err := img.Layers()
+			require.NoError(t, err)
+			layerDigest, err := layers[0].Digest()
+			require.NoError(t, err)
+			rc, err := layers[0].Compressed()
+			require.NoError(t, err)
+			content, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			half := len(content) / 2
+
+			var lock sync.Mutex
+			var ranges []string
+			registryInstance := registry.New()
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet || !strings.HasSuffix(r.URL.Path, "/blobs/"+layerDigest.String()) {
+					registryInstance.ServeHTTP(w, r)
+					return
+				}
+
+				lock.Lock()
+				ranges = append(ranges, r.Header.Get("Range"))
+				first := len(ranges) == 1
+				lock.Unlock()
+
+				if !first {
+					http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+					return
+				}
+
+				// drop the connection halfway through the layer
+				conn, buf, err := w.(http.Hijacker).Hijack()
+				require.NoError(t, err)
+				_, _ = fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n", len(content))
+				_, _ = buf.Write(content[:half])
+				_ = buf.Flush()
+				_ = conn.Close()
+			}))
+			t.Cleanup(ts.Close)
+
+			ref, err := name.ParseReference(strings.TrimPrefix(ts.URL, "http://")+"/resume:latest", name.Insecure)
+			require.NoError(t, err)
+			require.NoError(t, remote.Write(ref, img))
+
+			tmpDirGen := file.NewTempDirGenerator("test")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			ctx := context.Background()
+			if tt.policy != nil {
+				ctx = image.ContextWithRetryPolicy(ctx, *tt.policy)
+			}
+
+			out, err := NewRegistryProvider(tmpDirGen, image.RegistryOptions{InsecureUseHTTP: true}, ref.String(), nil).Provide(ctx)
+			tt.wantErr(t, err)
+
+			var wantRanges []string
+			for _, r := range tt.wantRanges {
+				if strings.Contains(r, "%d") {
+					r = fmt.Sprintf(r, half)
+				}
+				wantRanges = append(wantRanges, r)
+			}
+			assert.Equal(t, wantRanges, ranges)
+
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { _ = out.Cleanup() })
+			require.Len(t, out.Layers, 1)
+			diffID, err := layers[0].DiffID()
+			require.NoError(t, err)
+			assert.Equal(t, diffID.String(), out.Layers[0].Metadata.Digest)
+		})
+	}
+}
+
+func Test_resumeTransport_partialBlob(t *testing.T) {
+	content := []byte("the content of a blob that was partially downloaded before")
+	digest := "sha256:" + strings.Repeat("a", 64)
+
+	var ranges []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(ts.Close)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sha256-"+strings.Repeat("a", 64)+".partial"), content[:10], 0o600))
+
+	client := &http.Client{Transport: &resumeTransport{inner: http.DefaultTransport, dir: dir}}
+	resp, err := client.Get(ts.URL + "/v2/repo/blobs/" + digest)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int64(len(content)), resp.ContentLength)
+
+	got, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	assert.Equal(t, content, got)
+	assert.Equal(t, []string{"bytes=10-"}, ranges)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the partial blob should be removed once complete")
+}
+
+func Test_parseContentRange(t *testing.T) {
+	tests := []struct {
+		value     string
+		wantStart int64
+		wantSize  int64
+	}{
+		{value: "bytes 10-99/100", wantStart: 10, wantSize: 100},
+		{value: "bytes 10-99/*", wantStart: 10, wantSize: -1},
+		{value: "bytes */100", wantStart: -1, wantSize: -1},
+		{value: "", wantStart: -1, wantSize: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			start, size := parseContentRange(tt.value)
+			assert.Equal(t, tt.wantStart, start)
+			assert.Equal(t, tt.wantSize, size)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package oci
+
+import (
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// retryTransport attempts registry requests again according to the retry policy of the request context (see
+// image.RetryPolicy), for both transient errors and responses with a transient status code. When all attempts respond
+// with a transient status code, the last response is returned as-is (so the registry error is reported as usual).
+type retryTransport struct {
+	inner http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.GetBody == nil {
+		// the request body cannot be replayed
+		return t.inner.RoundTrip(req)
+	}
+
+	var resp *http.Response
+	err := image.Retry(req.Context(), "registry request "+req.Method+" "+req.URL.Path, func() error {
+		if resp != nil {
+			// discard the response of the previous attempt
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			_ = resp.Body.Close()
+			resp = nil
+		}
+
+		attempt := req
+		if req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+			attempt = req.Clone(req.Context())
+			attempt.Body = body
+		}
+
+		r, err := t.inner.RoundTrip(attempt)
+		if err != nil {
+			return err
+		}
+		resp = r
+		if slices.Contains(image.TransientStatusCodes, r.StatusCode) {
+			return &transport.Error{StatusCode: r.StatusCode, Request: attempt}
+		}
+		return nil
+	})
+	if resp != nil {
+		return resp, nil
+	}
+	return nil, err
+}
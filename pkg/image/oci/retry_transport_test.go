@@ -0,0 +1,97 @@
+package oci
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func Test_RegistryProvider_Retry(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     image.RetryPolicy
+		status     int
+		failures   int32
+		wantErr    require.ErrorAssertionFunc
+		wantFailed int32
+	}{
+		{
+			name:       "transient failures beyond the default retries",
+			policy:     image.RetryPolicy{Attempts: 6},
+			status:     http.StatusServiceUnavailable,
+			failures:   5,
+			wantFailed: 5,
+		},
+		{
+			name:       "rate limited",
+			policy:     image.RetryPolicy{Attempts: 3},
+			status:     http.StatusTooManyRequests,
+			failures:   2,
+			wantFailed: 2,
+		},
+		{
+			name:       "attempts exhausted",
+			policy:     image.RetryPolicy{Attempts: 2},
+			status:     http.StatusBadGateway,
+			failures:   5,
+			wantErr:    require.Error,
+			wantFailed: 2,
+		},
+		{
+			name:       "permanent failure",
+			policy:     image.RetryPolicy{Attempts: 5},
+			status:     http.StatusForbidden,
+			failures:   5,
+			wantErr:    require.Error,
+			wantFailed: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+
+			var failed atomic.Int32
+			registryInstance := registry.New()
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// fail requests for the image manifest (writes of the fixture are not affected)
+				if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/manifests/") && failed.Load() < tt.failures {
+					failed.Add(1)
+					w.WriteHeader(tt.status)
+					return
+				}
+				registryInstance.ServeHTTP(w, r)
+			}))
+			t.Cleanup(ts.Close)
+
+			ref, err := name.ParseReference(strings.TrimPrefix(ts.URL, "http://")+"/flaky:latest", name.Insecure)
+			require.NoError(t, err)
+			img, err := random.Image(64, 1)
+			require.NoError(t, err)
+			require.NoError(t, remote.Write(ref, img))
+
+			tmpDirGen := file.NewTempDirGenerator("test")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			ctx := image.ContextWithRetryPolicy(context.Background(), tt.policy)
+
+			_, err = NewRegistryProvider(tmpDirGen, image.RegistryOptions{InsecureUseHTTP: true}, ref.String(), nil).Provide(ctx)
+			tt.wantErr(t, err)
+			assert.Equal(t, tt.wantFailed, failed.Load())
+		})
+	}
+}
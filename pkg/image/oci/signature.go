@@ -0,0 +1,396 @@
+package oci
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	containerregistryV1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// annotations of cosign signature layers (see https://github.com/sigstore/cosign/blob/main/specs/SIGNATURE_SPEC.md)
+const (
+	cosignSignatureAnnotation   = "dev.cosignproject.cosign/signature"
+	cosignCertificateAnnotation = "dev.sigstore.cosign/certificate"
+	cosignChainAnnotation       = "dev.sigstore.cosign/chain"
+	cosignBundleAnnotation      = "dev.sigstore.cosign/bundle"
+)
+
+// maxSignaturePayloadSize limits the size of signature payloads read from the registry.
+const maxSignaturePayloadSize = 1 << 20
+
+// OIDC issuer extensions of keyless signing certificates (the deprecated raw string form and the DER encoded form).
+var (
+	oidIssuer       = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidIssuerString = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// cosignPayload is the simple signing payload signed by cosign.
+type cosignPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// rekorBundle is the transparency log entry attached to a cosign signature.
+type rekorBundle struct {
+	SignedEntryTimestamp []byte `json:"SignedEntryTimestamp"`
+	Payload              struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogIndex       int64  `json:"logIndex"`
+		LogID          string `json:"logID"`
+	} `json:"Payload"`
+}
+
+// hashedRekord is the transparency log entry body of a signature over a payload digest.
+type hashedRekord struct {
+	Spec struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+		Signature struct {
+			Content []byte `json:"content"`
+		} `json:"signature"`
+	} `json:"spec"`
+}
+
+// signatureVerifier verifies cosign signatures against a signature policy.
+type signatureVerifier struct {
+	keys       []crypto.PublicKey
+	roots      *x509.CertPool
+	logKeys    map[string]crypto.PublicKey
+	identities map[image.SignatureIdentity]struct{}
+}
+
+func newSignatureVerifier(policy image.SignaturePolicy) (*signatureVerifier, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	v := &signatureVerifier{
+		logKeys:    make(map[string]crypto.PublicKey),
+		identities: make(map[image.SignatureIdentity]struct{}),
+	}
+
+	for _, raw := range policy.PublicKeys {
+		key, err := parsePublicKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature policy public key: %w", err)
+		}
+		v.keys = append(v.keys, key)
+	}
+
+	for _, raw := range policy.TransparencyLogKeys {
+		key, err := parsePublicKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature policy transparency log key: %w", err)
+		}
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		logID := sha256.Sum256(der)
+		v.logKeys[hex.EncodeToString(logID[:])] = key
+	}
+
+	if len(policy.Roots) > 0 {
+		v.roots = x509.NewCertPool()
+		if !v.roots.AppendCertsFromPEM(policy.Roots) {
+			return nil, fmt.Errorf("invalid signature policy root certificates")
+		}
+	}
+
+	for _, id := range policy.Identities {
+		v.identities[id] = struct{}{}
+	}
+	return v, nil
+}
+
+// verifyImageSignatures verifies that the given image (or the index it was selected from) has a cosign signature
+// within the repository that satisfies the policy, failing with image.ErrSignatureVerification otherwise. The digest of
+// the index is given when the image was fetched by its manifest digest from an index read before (see
+// registryImageProvider.ProvideIndex), since the descriptor then only describes the platform manifest.
+func verifyImageSignatures(repo name.Repository, descriptor *remote.Descriptor, img containerregistryV1.Image, indexDigest *containerregistryV1.Hash, policy image.SignaturePolicy, options []remote.Option) error {
+	v, err := newSignatureVerifier(policy)
+	if err != nil {
+		return err
+	}
+
+	subjects := []containerregistryV1.Hash{descriptor.Digest}
+	if digest, err := img.Digest(); err == nil && digest != descriptor.Digest {
+		subjects = append(subjects, digest)
+	}
+	if indexDigest != nil && *indexDigest != descriptor.Digest {
+		subjects = append(subjects, *indexDigest)
+	}
+
+	var errs []error
+	for _, subject := range subjects {
+		err := v.verify(repo, subject, options)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", subject, err))
+	}
+	return fmt.Errorf("%w: %w", image.ErrSignatureVerification, errors.Join(errs...))
+}
+
+// verify checks the signatures stored with the cosign tag scheme ("<repo>:sha256-<hex>.sig") for the given subject.
+func (v *signatureVerifier) verify(repo name.Repository, subject containerregistryV1.Hash, options []remote.Option) error {
+	sigs, err := remote.Image(repo.Tag(fmt.Sprintf("%s-%s.sig", subject.Algorithm, subject.Hex)), options...)
+	if err != nil {
+		return fmt.Errorf("no signatures found: %w", err)
+	}
+
+	manifest, err := sigs.Manifest()
+	if err != nil {
+		return fmt.Errorf("unable to read signatures: %w", err)
+	}
+
+	var errs []error
+	for _, desc := range manifest.Layers {
+		payload, err := readSignaturePayload(sigs, desc.Digest)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := v.verifySignature(subject, payload, desc.Annotations); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		log.WithFields("subject", subject.String(), "signature", desc.Digest.String()).Debug("verified image signature")
+		return nil
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("no signatures found")
+	}
+	return errors.Join(errs...)
+}
+
+func readSignaturePayload(sigs containerregistryV1.Image, digest containerregistryV1.Hash) ([]byte, error) {
+	layer, err := sigs.LayerByDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(io.LimitReader(reader, maxSignaturePayloadSize))
+}
+
+// verifySignature verifies a single signature (the payload and its annotations) for the given subject.
+func (v *signatureVerifier) verifySignature(subject containerregistryV1.Hash, payload []byte, annotations map[string]string) error {
+	sig, err := base64.StdEncoding.DecodeString(annotations[cosignSignatureAnnotation])
+	if err != nil || len(sig) == 0 {
+		return fmt.Errorf("missing or invalid signature annotation")
+	}
+
+	var p cosignPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("invalid signature payload: %w", err)
+	}
+	if p.Critical.Image.DockerManifestDigest != subject.String() {
+		return fmt.Errorf("signature payload is for digest %q", p.Critical.Image.DockerManifestDigest)
+	}
+
+	var signedAt time.Time
+	if len(v.logKeys) > 0 {
+		signedAt, err = v.verifyBundle(payload, sig, annotations[cosignBundleAnnotation])
+		if err != nil {
+			return fmt.Errorf("invalid transparency log entry: %w", err)
+		}
+	}
+
+	if raw := annotations[cosignCertificateAnnotation]; raw != "" && len(v.identities) > 0 {
+		return v.verifyCertificate(payload, sig, raw, annotations[cosignChainAnnotation], signedAt)
+	}
+
+	for _, key := range v.keys {
+		if verifyWithKey(key, payload, sig) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature not made by any accepted key or identity")
+}
+
+// verifyBundle verifies that the signature was recorded in the transparency log, returning the time of the entry.
+func (v *signatureVerifier) verifyBundle(payload, sig []byte, raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, fmt.Errorf("no transparency log bundle")
+	}
+
+	var bundle rekorBundle
+	if err := json.Unmarshal([]byte(raw), &bundle); err != nil {
+		return time.Time{}, err
+	}
+
+	key, ok := v.logKeys[bundle.Payload.LogID]
+	if !ok {
+		return time.Time{}, fmt.Errorf("unknown transparency log %q", bundle.Payload.LogID)
+	}
+
+	// the signed entry timestamp is a signature over the canonical JSON of the payload (sorted keys)
+	canonical, err := json.Marshal(map[string]interface{}{
+		"body":           bundle.Payload.Body,
+		"integratedTime": bundle.Payload.IntegratedTime,
+		"logIndex":       bundle.Payload.LogIndex,
+		"logID":          bundle.Payload.LogID,
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := verifyWithKey(key, canonical, bundle.SignedEntryTimestamp); err != nil {
+		return time.Time{}, fmt.Errorf("invalid signed entry timestamp: %w", err)
+	}
+
+	body, err := base64.StdEncoding.DecodeString(bundle.Payload.Body)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var entry hashedRekord
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return time.Time{}, err
+	}
+
+	digest := sha256.Sum256(payload)
+	if entry.Spec.Data.Hash.Algorithm != "sha256" || entry.Spec.Data.Hash.Value != hex.EncodeToString(digest[:]) {
+		return time.Time{}, fmt.Errorf("log entry is for another payload")
+	}
+	if !bytes.Equal(entry.Spec.Signature.Content, sig) {
+		return time.Time{}, fmt.Errorf("log entry is for another signature")
+	}
+	return time.Unix(bundle.Payload.IntegratedTime, 0), nil
+}
+
+// verifyCertificate verifies a keyless signature: the signing certificate must chain to the policy roots (at the time
+// the signature was recorded in the transparency log) and be issued to any of the policy identities.
+func (v *signatureVerifier) verifyCertificate(payload, sig []byte, rawCert, rawChain string, signedAt time.Time) error {
+	certs, err := parseCertificates([]byte(rawCert))
+	if err != nil || len(certs) == 0 {
+		return fmt.Errorf("invalid signing certificate: %w", err)
+	}
+	cert := certs[0]
+
+	intermediates := x509.NewCertPool()
+	if rawChain != "" {
+		chain, err := parseCertificates([]byte(rawChain))
+		if err != nil {
+			return fmt.Errorf("invalid signing certificate chain: %w", err)
+		}
+		for _, c := range chain {
+			intermediates.AddCert(c)
+		}
+	}
+
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		CurrentTime:   signedAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return fmt.Errorf("untrusted signing certificate: %w", err)
+	}
+
+	id := certificateIdentity(cert)
+	if _, ok := v.identities[id]; !ok {
+		return fmt.Errorf("signing certificate identity (subject=%q issuer=%q) not accepted", id.Subject, id.Issuer)
+	}
+
+	return verifyWithKey(cert.PublicKey, payload, sig)
+}
+
+// certificateIdentity returns the subject (the email or URI subject alternative name) and OIDC issuer of a keyless
+// signing certificate.
+func certificateIdentity(cert *x509.Certificate) image.SignatureIdentity {
+	var id image.SignatureIdentity
+	switch {
+	case len(cert.EmailAddresses) > 0:
+		id.Subject = cert.EmailAddresses[0]
+	case len(cert.URIs) > 0:
+		id.Subject = cert.URIs[0].String()
+	}
+
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidIssuerString):
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				id.Issuer = issuer
+			}
+		case ext.Id.Equal(oidIssuer) && id.Issuer == "":
+			id.Issuer = string(ext.Value)
+		}
+	}
+	return id
+}
+
+func verifyWithKey(key crypto.PublicKey, payload, sig []byte) error {
+	digest := sha256.Sum256(payload)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if ecdsa.VerifyASN1(k, digest[:], sig) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil || rsa.VerifyPSS(k, crypto.SHA256, digest[:], sig, nil) == nil {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if ed25519.Verify(k, payload, sig) {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	return fmt.Errorf("invalid signature")
+}
+
+func parsePublicKey(raw []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM encoded key found")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+func parseCertificates(raw []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, raw = pem.Decode(raw)
+		if block == nil {
+			return certs, nil
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+}
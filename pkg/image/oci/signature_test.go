@@ -0,0 +1,359 @@
+package oci
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func Test_RegistryProvider_SignatureVerification(t *testing.T) {
+	registryHost := makeRegistry(t)
+	registryOptions := image.RegistryOptions{InsecureUseHTTP: true}
+
+	pushRandomRegistryImage(t, registryHost, "signed", "latest")
+	pushRandomRegistryImage(t, registryHost, "unsigned", "latest")
+	pushRandomRegistryImage(t, registryHost, "wrong-digest", "latest")
+	pushRandomRegistryImage(t, registryHost, "keyless", "latest")
+
+	signingKey := newTestKey(t)
+	otherKey := newTestKey(t)
+	logKey := newTestKey(t)
+
+	signed := registryDigest(t, registryHost+"/signed:latest")
+	signedPayload := cosignTestPayload(signed.DigestStr())
+	pushSignature(t, signed, signedPayload, signingKey.sign(t, signedPayload), nil)
+
+	// a valid signature, but for another image
+	wrongDigest := registryDigest(t, registryHost+"/wrong-digest:latest")
+	pushSignature(t, wrongDigest, signedPayload, signingKey.sign(t, signedPayload), nil)
+
+	// keyless signatures are made with a short-lived certificate (expired by now) recorded in the transparency log
+	root, rootKey := newTestCA(t)
+	signedAt := time.Now().Add(-time.Hour)
+	keyless := registryDigest(t, registryHost+"/keyless:latest")
+	leafKey := newTestKey(t)
+	leaf := newTestLeafCertificate(t, root, rootKey, leafKey, "someone@example.com", "https://accounts.example.com", signedAt)
+	payload := cosignTestPayload(keyless.DigestStr())
+	sig := leafKey.sign(t, payload)
+	pushSignature(t, keyless, payload, sig, map[string]string{
+		cosignCertificateAnnotation: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})),
+		cosignBundleAnnotation:      testBundle(t, logKey, payload, sig, signedAt),
+	})
+
+	rootPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw})
+
+	tests := []struct {
+		name    string
+		ref     string
+		policy  image.SignaturePolicy
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:   "signed with accepted key",
+			ref:    "signed:latest",
+			policy: image.SignaturePolicy{PublicKeys: [][]byte{otherKey.publicPEM(t), signingKey.publicPEM(t)}},
+		},
+		{
+			name:    "signed with other key",
+			ref:     "signed:latest",
+			policy:  image.SignaturePolicy{PublicKeys: [][]byte{otherKey.publicPEM(t)}},
+			wantErr: requireSignatureError,
+		},
+		{
+			name:    "unsigned",
+			ref:     "unsigned:latest",
+			policy:  image.SignaturePolicy{PublicKeys: [][]byte{signingKey.publicPEM(t)}},
+			wantErr: requireSignatureError,
+		},
+		{
+			name:    "signature for another image",
+			ref:     "wrong-digest:latest",
+			policy:  image.SignaturePolicy{PublicKeys: [][]byte{signingKey.publicPEM(t)}},
+			wantErr: requireSignatureError,
+		},
+		{
+			name: "keyless identity",
+			ref:  "keyless:latest",
+			policy: image.SignaturePolicy{
+				Identities:          []image.SignatureIdentity{{Subject: "someone@example.com", Issuer: "https://accounts.example.com"}},
+				Roots:               rootPEM,
+				TransparencyLogKeys: [][]byte{logKey.publicPEM(t)},
+			},
+		},
+		{
+			name: "keyless identity not accepted",
+			ref:  "keyless:latest",
+			policy: image.SignaturePolicy{
+				Identities:          []image.SignatureIdentity{{Subject: "someone-else@example.com", Issuer: "https://accounts.example.com"}},
+				Roots:               rootPEM,
+				TransparencyLogKeys: [][]byte{logKey.publicPEM(t)},
+			},
+			wantErr: requireSignatureError,
+		},
+		{
+			name: "keyless signature from unknown transparency log",
+			ref:  "keyless:latest",
+			policy: image.SignaturePolicy{
+				Identities:          []image.SignatureIdentity{{Subject: "someone@example.com", Issuer: "https://accounts.example.com"}},
+				Roots:               rootPEM,
+				TransparencyLogKeys: [][]byte{otherKey.publicPEM(t)},
+			},
+			wantErr: requireSignatureError,
+		},
+		{
+			name:    "invalid policy",
+			ref:     "signed:latest",
+			wantErr: require.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+			tmpDirGen := file.NewTempDirGenerator("test")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			ctx := image.ContextWithSignaturePolicy(context.Background(), &tt.policy)
+			img, err := NewRegistryProvider(tmpDirGen, registryOptions, registryHost+"/"+tt.ref, nil).Provide(ctx)
+			tt.wantErr(t, err)
+			if err != nil {
+				assert.Nil(t, img)
+				return
+			}
+			assert.NotNil(t, img)
+		})
+	}
+}
+
+func Test_RegistryProvider_ProvideIndex_SignatureVerification(t *testing.T) {
+	registryHost := makeRegistry(t)
+	registryOptions := image.RegistryOptions{InsecureUseHTTP: true}
+
+	pushIndex := func(t *testing.T, repo string) name.Digest {
+		amd64Img, err := random.Image(64, 1)
+		require.NoError(t, err)
+		arm64Img, err := random.Image(64, 1)
+		require.NoError(t, err)
+		index := mutate.AppendManifests(empty.Index,
+			mutate.IndexAddendum{Add: amd64Img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+			mutate.IndexAddendum{Add: arm64Img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+		)
+		ref, err := name.ParseReference(registryHost+"/"+repo+":latest", name.Insecure)
+		require.NoError(t, err)
+		require.NoError(t, remote.WriteIndex(ref, index))
+		return registryDigest(t, ref.String())
+	}
+
+	signingKey := newTestKey(t)
+
+	// only the index is signed (as done by "cosign sign" for multi-platform images)
+	signed := pushIndex(t, "signed")
+	payload := cosignTestPayload(signed.DigestStr())
+	pushSignature(t, signed, payload, signingKey.sign(t, payload), nil)
+
+	pushIndex(t, "unsigned")
+
+	tests := []struct {
+		name    string
+		repo    string
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:    "signed index",
+			repo:    "signed",
+			wantErr: require.NoError,
+		},
+		{
+			name:    "unsigned index",
+			repo:    "unsigned",
+			wantErr: requireSignatureError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDirGen := file.NewTempDirGenerator("test")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			ctx := image.ContextWithSignaturePolicy(context.Background(), &image.SignaturePolicy{PublicKeys: [][]byte{signingKey.publicPEM(t)}})
+			provider := NewRegistryProvider(tmpDirGen, registryOptions, registryHost+"/"+tt.repo+":latest", nil).(image.IndexProvider)
+			idx, err := provider.ProvideIndex(ctx)
+			require.NoError(t, err)
+			require.Len(t, idx.Entries, 2)
+
+			for _, entry := range idx.Entries {
+				img, err := entry.Provide(ctx)
+				tt.wantErr(t, err)
+				if img != nil {
+					t.Cleanup(func() { _ = img.Cleanup() })
+				}
+			}
+		})
+	}
+}
+
+func requireSignatureError(t require.TestingT, err error, _ ...interface{}) {
+	require.ErrorIs(t, err, image.ErrSignatureVerification)
+}
+
+func registryDigest(t *testing.T, ref string) name.Digest {
+	t.Helper()
+	r, err := name.ParseReference(ref, name.Insecure)
+	require.NoError(t, err)
+	desc, err := remote.Head(r)
+	require.NoError(t, err)
+	return r.Context().Digest(desc.Digest.String())
+}
+
+func cosignTestPayload(digest string) []byte {
+	return []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"test"},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, digest))
+}
+
+// pushSignature pushes a cosign signature (using the tag scheme) for the given image.
+func pushSignature(t *testing.T, subject name.Digest, payload, sig []byte, annotations map[string]string) {
+	t.Helper()
+
+	digest, err := v1.NewHash(subject.DigestStr())
+	require.NoError(t, err)
+
+	all := map[string]string{cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(sig)}
+	for k, v := range annotations {
+		all[k] = v
+	}
+
+	sigs, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:       static.NewLayer(payload, "application/vnd.dev.cosign.simplesigning.v1+json"),
+		Annotations: all,
+	})
+	require.NoError(t, err)
+
+	tag := subject.Context().Tag(fmt.Sprintf("%s-%s.sig", digest.Algorithm, digest.Hex))
+	require.NoError(t, remote.Write(tag, sigs))
+}
+
+type testKey struct {
+	*ecdsa.PrivateKey
+}
+
+func newTestKey(t *testing.T) testKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return testKey{key}
+}
+
+func (k testKey) sign(t *testing.T, payload []byte) []byte {
+	t.Helper()
+	digest := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, k.PrivateKey, digest[:])
+	require.NoError(t, err)
+	return sig
+}
+
+func (k testKey) publicPEM(t *testing.T) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&k.PublicKey)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func newTestCA(t *testing.T) (*x509.Certificate, testKey) {
+	t.Helper()
+	key := newTestKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test root"},
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key.PrivateKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+// newTestLeafCertificate creates a keyless signing certificate valid for ten minutes from the given time.
+func newTestLeafCertificate(t *testing.T, root *x509.Certificate, rootKey, key testKey, email, issuer string, at time.Time) *x509.Certificate {
+	t.Helper()
+	issuerValue, err := asn1.Marshal(issuer)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       at.Add(-time.Minute),
+		NotAfter:        at.Add(10 * time.Minute),
+		EmailAddresses:  []string{email},
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		ExtraExtensions: []pkix.Extension{{Id: oidIssuerString, Value: issuerValue}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, root, &key.PublicKey, rootKey.PrivateKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+// testBundle creates a transparency log bundle for the signature, signed by the given log key.
+func testBundle(t *testing.T, logKey testKey, payload, sig []byte, at time.Time) string {
+	t.Helper()
+	digest := sha256.Sum256(payload)
+	body, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]interface{}{
+			"data":      map[string]interface{}{"hash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(digest[:])}},
+			"signature": map[string]interface{}{"content": base64.StdEncoding.EncodeToString(sig)},
+		},
+	})
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(&logKey.PublicKey)
+	require.NoError(t, err)
+	logID := sha256.Sum256(der)
+
+	entry := map[string]interface{}{
+		"body":           base64.StdEncoding.EncodeToString(body),
+		"integratedTime": at.Unix(),
+		"logIndex":       42,
+		"logID":          hex.EncodeToString(logID[:]),
+	}
+	canonical, err := json.Marshal(entry)
+	require.NoError(t, err)
+
+	bundle, err := json.Marshal(map[string]interface{}{
+		"SignedEntryTimestamp": logKey.sign(t, canonical),
+		"Payload":              entry,
+	})
+	require.NoError(t, err)
+	return string(bundle)
+}
@@ -11,11 +11,19 @@ import (
 
 const Archive image.Source = image.OciTarballSource
 
-// NewArchiveProvider creates a new provider instance for the specific image tarball already at the given path.
+// NewArchiveProvider creates a new provider instance for the specific image tarball already at the given path. As with
+// NewDirectoryProvider, an image can be selected from archives holding more than one image with a "#" suffix.
 func NewArchiveProvider(tmpDirGen *file.TempDirGenerator, path string) image.Provider {
+	return NewPlatformArchiveProvider(tmpDirGen, path, nil)
+}
+
+// NewPlatformArchiveProvider creates a new provider for the OCI archive at the given path (see NewArchiveProvider),
+// selecting the image for the given platform when the archive holds a multi-platform index.
+func NewPlatformArchiveProvider(tmpDirGen *file.TempDirGenerator, path string, platform *image.Platform) image.Provider {
 	return &tarballImageProvider{
 		tmpDirGen: tmpDirGen,
 		path:      path,
+		platform:  platform,
 	}
 }
 
@@ -23,6 +31,7 @@ func NewArchiveProvider(tmpDirGen *file.TempDirGenerator, path string) image.Pro
 type tarballImageProvider struct {
 	tmpDirGen *file.TempDirGenerator
 	path      string
+	platform  *image.Platform
 }
 
 func (p *tarballImageProvider) Name() string {
@@ -31,12 +40,32 @@ func (p *tarballImageProvider) Name() string {
 
 // Provide an image object that represents the OCI image from a tarball.
 func (p *tarballImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	provider, err := p.directoryProvider()
+	if err != nil {
+		return nil, err
+	}
+	return provider.Provide(ctx)
+}
+
+// ProvideIndex provides all images within the OCI tarball (see directoryImageProvider.ProvideIndex).
+func (p *tarballImageProvider) ProvideIndex(ctx context.Context) (*image.Index, error) {
+	provider, err := p.directoryProvider()
+	if err != nil {
+		return nil, err
+	}
+	return provider.ProvideIndex(ctx)
+}
+
+// directoryProvider extracts the tarball to a temp dir, returning a provider for the extracted OCI layout.
+func (p *tarballImageProvider) directoryProvider() (*directoryImageProvider, error) {
 	// note: we are untaring the image and using the existing directory provider, we could probably enhance the google
 	// container registry lib to do this without needing to untar to a temp dir (https://github.com/google/go-containerregistry/issues/726)
-	f, err := os.Open(p.path)
+	path, selector := splitImageSelector(p.path)
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("unable to open OCI tarball: %w", err)
 	}
+	defer f.Close()
 
 	tempDir, err := p.tmpDirGen.NewDirectory("oci-tarball-image")
 	if err != nil {
@@ -47,5 +76,10 @@ func (p *tarballImageProvider) Provide(ctx context.Context) (*image.Image, error
 		return nil, err
 	}
 
-	return NewDirectoryProvider(p.tmpDirGen, tempDir).Provide(ctx)
+	return &directoryImageProvider{
+		tmpDirGen: p.tmpDirGen,
+		path:      tempDir,
+		platform:  p.platform,
+		selector:  selector,
+	}, nil
 }
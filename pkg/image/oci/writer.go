@@ -0,0 +1,119 @@
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// Writer writes images provided from any image source as OCI image layouts, preserving the manifest, config, and layer
+// blobs of the image (e.g. to write an image provided from a daemon to an OCI layout directory).
+type Writer struct {
+	// Tag names the image within the layout, annotated as the reference name ("org.opencontainers.image.ref.name") and
+	// the containerd image name. Defaults to the first tag of the image, where images without tags are written unnamed.
+	Tag string
+}
+
+// WriteDirectory writes the image to the OCI layout directory at the given path, which is created when it does not
+// exist. Within an existing layout the image replaces any image of the same name (or the same digest when unnamed).
+func (w Writer) WriteDirectory(path string, img *image.Image) error {
+	content, annotations, err := w.prepare(img)
+	if err != nil {
+		return err
+	}
+
+	p, err := layoutPath(path)
+	if err != nil {
+		return err
+	}
+
+	var matcher match.Matcher
+	if annotations != nil {
+		matcher = match.Annotation(containerdImageNameAnnotation, annotations[containerdImageNameAnnotation])
+	} else {
+		digest, err := content.Digest()
+		if err != nil {
+			return fmt.Errorf("unable to determine image digest: %w", err)
+		}
+		matcher = match.Digests(digest)
+	}
+
+	var options []layout.Option
+	if annotations != nil {
+		options = append(options, layout.WithAnnotations(annotations))
+	}
+	if cfg, err := content.ConfigFile(); err == nil && cfg.Platform() != nil {
+		options = append(options, layout.WithPlatform(*cfg.Platform()))
+	}
+
+	if err := p.ReplaceImage(content, matcher, options...); err != nil {
+		return fmt.Errorf("unable to write image to OCI layout %q: %w", path, err)
+	}
+	return nil
+}
+
+// WriteArchive writes the image as a tar of an OCI image layout (an "oci-archive") to the given writer.
+func (w Writer) WriteArchive(out io.Writer, img *image.Image) error {
+	content, annotations, err := w.prepare(img)
+	if err != nil {
+		return err
+	}
+
+	if err := image.WriteOCIArchive(out, content, annotations); err != nil {
+		return fmt.Errorf("unable to write OCI archive: %w", err)
+	}
+	return nil
+}
+
+// prepare returns the content of the image along with the annotations naming the image within the layout (nil when
+// the image is unnamed).
+func (w Writer) prepare(img *image.Image) (v1.Image, map[string]string, error) {
+	if img == nil || img.V1Image() == nil {
+		return nil, nil, fmt.Errorf("image has no content to write")
+	}
+
+	tagStr := w.Tag
+	if tagStr == "" && len(img.Metadata.Tags) > 0 {
+		tagStr = img.Metadata.Tags[0].String()
+	}
+	if tagStr == "" {
+		return img.V1Image(), nil, nil
+	}
+
+	tag, err := name.NewTag(tagStr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid image tag %q: %w", tagStr, err)
+	}
+	return img.V1Image(), map[string]string{
+		ocispec.AnnotationRefName:     tag.TagStr(),
+		containerdImageNameAnnotation: tag.Name(),
+	}, nil
+}
+
+// layoutPath returns the OCI layout at the given path, creating an empty layout when there is none.
+func layoutPath(path string) (layout.Path, error) {
+	if _, err := os.Stat(filepath.Join(path, "index.json")); errors.Is(err, os.ErrNotExist) {
+		p, err := layout.Write(path, empty.Index)
+		if err != nil {
+			return "", fmt.Errorf("unable to create OCI layout %q: %w", path, err)
+		}
+		return p, nil
+	}
+
+	p, err := layout.FromPath(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to open OCI layout %q: %w", path, err)
+	}
+	return p, nil
+}
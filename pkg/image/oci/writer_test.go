@@ -0,0 +1,81 @@
+package oci
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func TestWriter(t *testing.T) {
+	v1Img, err := random.Image(1024, 2)
+	require.NoError(t, err)
+	tag, err := name.NewTag("example.com/app:v1")
+	require.NoError(t, err)
+
+	img := image.New(v1Img, nil, t.TempDir(), image.WithTags(tag.String()))
+	require.NoError(t, img.Read())
+
+	manifestDigest, err := v1Img.Digest()
+	require.NoError(t, err)
+
+	assertSameImage := func(t *testing.T, newProvider func(*file.TempDirGenerator) image.Provider) {
+		t.Helper()
+		generator := file.NewTempDirGenerator("oci-writer")
+		defer generator.Cleanup()
+
+		written, err := newProvider(generator).Provide(context.Background())
+		require.NoError(t, err)
+		defer written.Cleanup()
+		assert.Equal(t, img.Metadata.ID, written.Metadata.ID)
+		assert.Equal(t, manifestDigest.String(), written.Metadata.ManifestDigest)
+		assert.Len(t, written.Layers, 2)
+	}
+
+	t.Run("directory", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "layout")
+		w := Writer{}
+
+		// writing the same image again replaces the entry of the image
+		require.NoError(t, w.WriteDirectory(dir, img))
+		require.NoError(t, w.WriteDirectory(dir, img))
+
+		index, err := layout.ImageIndexFromPath(dir)
+		require.NoError(t, err)
+		manifest, err := index.IndexManifest()
+		require.NoError(t, err)
+		require.Len(t, manifest.Manifests, 1)
+		assert.Equal(t, "v1", manifest.Manifests[0].Annotations[ocispec.AnnotationRefName])
+		assert.Equal(t, tag.Name(), manifest.Manifests[0].Annotations[containerdImageNameAnnotation])
+
+		assertSameImage(t, func(generator *file.TempDirGenerator) image.Provider {
+			return NewDirectoryProvider(generator, dir)
+		})
+	})
+
+	t.Run("archive", func(t *testing.T) {
+		archive := filepath.Join(t.TempDir(), "image.tar")
+		fh, err := os.Create(archive)
+		require.NoError(t, err)
+		require.NoError(t, Writer{Tag: "example.com/app:other"}.WriteArchive(fh, img))
+		require.NoError(t, fh.Close())
+
+		assertSameImage(t, func(generator *file.TempDirGenerator) image.Provider {
+			return NewArchiveProvider(generator, archive)
+		})
+	})
+
+	t.Run("no content", func(t *testing.T) {
+		require.Error(t, Writer{}.WriteDirectory(t.TempDir(), &image.Image{}))
+	})
+}
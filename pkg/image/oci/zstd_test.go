@@ -0,0 +1,179 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/compression"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func Test_Providers_ZstdLayers(t *testing.T) {
+	img := zstdImage(t, types.OCILayerZStd)
+
+	tests := []struct {
+		name          string
+		provider      func(t *testing.T, tmpDirGen *file.TempDirGenerator) image.Provider
+		wantMediaType types.MediaType
+	}{
+		{
+			name: "registry",
+			provider: func(t *testing.T, tmpDirGen *file.TempDirGenerator) image.Provider {
+				ref, err := name.ParseReference(makeRegistry(t)+"/zstd:latest", name.Insecure)
+				require.NoError(t, err)
+				require.NoError(t, remote.Write(ref, img))
+				return NewRegistryProvider(tmpDirGen, image.RegistryOptions{InsecureUseHTTP: true}, ref.String(), nil)
+			},
+		},
+		{
+			name: "directory",
+			provider: func(t *testing.T, tmpDirGen *file.TempDirGenerator) image.Provider {
+				return NewDirectoryProvider(tmpDirGen, zstdLayout(t, img))
+			},
+		},
+		{
+			name: "archive",
+			provider: func(t *testing.T, tmpDirGen *file.TempDirGenerator) image.Provider {
+				return NewArchiveProvider(tmpDirGen, tarDirectory(t, zstdLayout(t, img)))
+			},
+		},
+		{
+			name: "non-distributable layers",
+			provider: func(t *testing.T, tmpDirGen *file.TempDirGenerator) image.Provider {
+				return NewDirectoryProvider(tmpDirGen, zstdLayout(t, zstdImage(t, image.OCIRestrictedLayerZStd)))
+			},
+			wantMediaType: image.OCIRestrictedLayerZStd,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantMediaType == "" {
+				tt.wantMediaType = types.OCILayerZStd
+			}
+			tmpDirGen := file.NewTempDirGenerator("test")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			provided, err := tt.provider(t, tmpDirGen).Provide(context.Background())
+			require.NoError(t, err)
+
+			require.Len(t, provided.Layers, 2)
+			for _, l := range provided.Layers {
+				assert.Equal(t, tt.wantMediaType, l.Metadata.MediaType)
+			}
+
+			for p, want := range map[string]string{"/etc/os-release": "ID=zstd\n", "/bin/app": "v2"} {
+				reader, err := provided.OpenPathFromSquash(file.Path(p))
+				require.NoError(t, err, p)
+				got, err := io.ReadAll(reader)
+				require.NoError(t, err)
+				assert.Equal(t, want, string(got), p)
+			}
+		})
+	}
+}
+
+// zstdImage creates an OCI image with zstd compressed layers of the given media type.
+func zstdImage(t *testing.T, mediaType types.MediaType) v1.Image {
+	t.Helper()
+
+	var layers []v1.Layer
+	for _, files := range []map[string]string{
+		{"etc/os-release": "ID=zstd\n", "bin/app": "v1"},
+		{"bin/app": "v2"},
+	} {
+		contents := layerTar(t, files)
+		layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(contents)), nil
+		}, tarball.WithCompression(compression.ZStd), tarball.WithMediaType(mediaType))
+		require.NoError(t, err)
+		layers = append(layers, layer)
+	}
+
+	img := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+	img = mutate.ConfigMediaType(img, types.OCIConfigJSON)
+	img, err := mutate.AppendLayers(img, layers...)
+	require.NoError(t, err)
+	return img
+}
+
+func layerTar(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, p := range []string{"etc/os-release", "bin/app"} {
+		contents, ok := files[p]
+		if !ok {
+			continue
+		}
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: p, Mode: 0644, Size: int64(len(contents)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func zstdLayout(t *testing.T, img v1.Image) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	p, err := layout.Write(dir, empty.Index)
+	require.NoError(t, err)
+	require.NoError(t, p.AppendImage(img))
+	return dir
+}
+
+// tarDirectory writes all files within the given directory to a tar (as "oci:archive" shaped archives are).
+func tarDirectory(t *testing.T, dir string) string {
+	t.Helper()
+
+	tarPath := filepath.Join(t.TempDir(), "image.tar")
+	fh, err := os.Create(tarPath)
+	require.NoError(t, err)
+	defer fh.Close()
+
+	tw := tar.NewWriter(fh)
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return tw.WriteHeader(&tar.Header{Name: rel + "/", Mode: 0755, Typeflag: tar.TypeDir})
+		}
+		contents, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: rel, Mode: 0644, Size: int64(len(contents)), Typeflag: tar.TypeReg}); err != nil {
+			return err
+		}
+		_, err = tw.Write(contents)
+		return err
+	})
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	return tarPath
+}
@@ -0,0 +1,163 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/scylladb/go-set/strset"
+)
+
+// WriteOCIArchive writes the image as a tar of an OCI image layout (an "oci-archive", as read by the OCI tarball
+// provider) with the manifest, config, and layer blobs of the image as-is. The image is the only entry of the layout
+// index, annotated with the given annotations (e.g. the reference name).
+func WriteOCIArchive(w io.Writer, img v1.Image, annotations map[string]string) error {
+	rawManifest, err := img.RawManifest()
+	if err != nil {
+		return err
+	}
+	manifestDigest, err := img.Digest()
+	if err != nil {
+		return err
+	}
+	rawConfig, err := img.RawConfigFile()
+	if err != nil {
+		return err
+	}
+	configDigest, err := img.ConfigName()
+	if err != nil {
+		return err
+	}
+	mediaType, err := img.MediaType()
+	if err != nil {
+		return err
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return err
+	}
+
+	desc := v1.Descriptor{
+		MediaType:   mediaType,
+		Size:        int64(len(rawManifest)),
+		Digest:      manifestDigest,
+		Annotations: annotations,
+	}
+	if cfg, err := img.ConfigFile(); err == nil {
+		desc.Platform = cfg.Platform()
+	}
+
+	rawIndex, err := json.Marshal(v1.IndexManifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIImageIndex,
+		Manifests:     []v1.Descriptor{desc},
+	})
+	if err != nil {
+		return err
+	}
+
+	a := ociArchiveWriter{tw: tar.NewWriter(w), written: strset.New()}
+	if err := a.writeFile("oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`)); err != nil {
+		return err
+	}
+	if err := a.writeFile("index.json", rawIndex); err != nil {
+		return err
+	}
+	if err := a.writeBlob(manifestDigest, rawManifest); err != nil {
+		return err
+	}
+	if err := a.writeBlob(configDigest, rawConfig); err != nil {
+		return err
+	}
+	for _, layer := range layers {
+		if err := a.writeLayer(layer); err != nil {
+			return err
+		}
+	}
+	return a.tw.Close()
+}
+
+// ociArchiveWriter writes the entries of an OCI layout tar, where blob directories are written as needed and each
+// blob is written once (e.g. for images holding the same layer more than once).
+type ociArchiveWriter struct {
+	tw      *tar.Writer
+	written *strset.Set
+}
+
+func (a ociArchiveWriter) writeFile(name string, content []byte) error {
+	return a.writeEntry(name, int64(len(content)), bytes.NewReader(content))
+}
+
+func (a ociArchiveWriter) writeBlob(digest v1.Hash, content []byte) error {
+	if !a.prepareBlob(digest) {
+		return nil
+	}
+	if err := a.writeBlobDirs(digest); err != nil {
+		return err
+	}
+	return a.writeFile(ociBlobPath(digest), content)
+}
+
+func (a ociArchiveWriter) writeLayer(layer v1.Layer) error {
+	digest, err := layer.Digest()
+	if err != nil {
+		return err
+	}
+	if !a.prepareBlob(digest) {
+		return nil
+	}
+	if err := a.writeBlobDirs(digest); err != nil {
+		return err
+	}
+
+	size, err := layer.Size()
+	if err != nil {
+		return err
+	}
+	reader, err := layer.Compressed()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return a.writeEntry(ociBlobPath(digest), size, reader)
+}
+
+// prepareBlob reports whether the blob with the given digest still needs to be written (marking it as written).
+func (a ociArchiveWriter) prepareBlob(digest v1.Hash) bool {
+	path := ociBlobPath(digest)
+	if a.written.Has(path) {
+		return false
+	}
+	a.written.Add(path)
+	return true
+}
+
+func (a ociArchiveWriter) writeBlobDirs(digest v1.Hash) error {
+	for _, dir := range []string{"blobs/", fmt.Sprintf("blobs/%s/", digest.Algorithm)} {
+		if a.written.Has(dir) {
+			continue
+		}
+		if err := a.tw.WriteHeader(&tar.Header{Name: dir, Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+			return err
+		}
+		a.written.Add(dir)
+	}
+	return nil
+}
+
+func (a ociArchiveWriter) writeEntry(name string, size int64, r io.Reader) error {
+	if err := a.tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: size}); err != nil {
+		return err
+	}
+	_, err := io.Copy(a.tw, r)
+	return err
+}
+
+func ociBlobPath(digest v1.Hash) string {
+	return fmt.Sprintf("blobs/%s/%s", digest.Algorithm, digest.Hex)
+}
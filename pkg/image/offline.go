@@ -0,0 +1,34 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrOffline is returned when providing an image would require network access (e.g. pulling an image from a registry,
+// directly or through a daemon) while in offline mode (see ContextWithOffline).
+var ErrOffline = errors.New("network access is not allowed in offline mode")
+
+type offlineKey struct{}
+
+// ContextWithOffline returns a context where providers must never access the network: images are never pulled from
+// registries (neither directly nor into the docker, podman, or containerd image stores). Providers fail with ErrOffline
+// instead.
+func ContextWithOffline(ctx context.Context) context.Context {
+	return context.WithValue(ctx, offlineKey{}, true)
+}
+
+// IsOffline indicates that the network must not be accessed (see ContextWithOffline).
+func IsOffline(ctx context.Context) bool {
+	offline, _ := ctx.Value(offlineKey{}).(bool)
+	return offline
+}
+
+// CheckNetworkAccess returns ErrOffline (describing the attempted action) when the given context is offline.
+func CheckNetworkAccess(ctx context.Context, action string) error {
+	if IsOffline(ctx) {
+		return fmt.Errorf("unable to %s: %w", action, ErrOffline)
+	}
+	return nil
+}
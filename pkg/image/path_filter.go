@@ -0,0 +1,118 @@
+package image
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+// WithPathExcludes excludes all layer entries matching any of the given glob patterns (e.g. "proc/**", "sys/**",
+// "**/*.mp4") while layers are read, such that the excluded contents are never written to the layer cache or indexed.
+// Patterns are matched against paths relative to the root of the layer (a leading "/" is ignored). Note: excluded
+// paths are absent from all trees, thus links to excluded paths are dead links.
+func WithPathExcludes(patterns ...string) AdditionalMetadata {
+	return func(image *Image) error {
+		filter, err := image.pathFilter.with(patterns...)
+		if err != nil {
+			return err
+		}
+		image.pathFilter = filter
+		return nil
+	}
+}
+
+// pathFilter excludes layer entries by path while layers are read (a nil filter excludes nothing).
+type pathFilter struct {
+	excludes []string
+}
+
+// with returns a new filter that additionally excludes the given patterns.
+func (f *pathFilter) with(patterns ...string) (*pathFilter, error) {
+	out := pathFilter{}
+	if f != nil {
+		out.excludes = append(out.excludes, f.excludes...)
+	}
+
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(pattern, "/")
+		if pattern == "" || !doublestar.ValidatePattern(pattern) {
+			return nil, fmt.Errorf("invalid path exclude pattern: %q", pattern)
+		}
+		out.excludes = append(out.excludes, pattern)
+	}
+
+	if len(out.excludes) == 0 {
+		return nil, nil
+	}
+	return &out, nil
+}
+
+// excluded indicates if the given layer entry path (e.g. "./usr/bin/" or "/usr/bin") should be excluded.
+func (f *pathFilter) excluded(p string) bool {
+	if f == nil {
+		return false
+	}
+
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if p == "" {
+		return false
+	}
+
+	for _, pattern := range f.excludes {
+		// note: patterns are validated when the filter is created
+		if matched, _ := doublestar.Match(pattern, p); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// cachePath is the path of the (filtered) uncompressed layer tar with the given digest within the layer cache directory,
+// which is distinct from the unfiltered layer cache (and from the caches of other filters).
+func (f *pathFilter) cachePath(uncompressedLayersCacheDir, digest string) string {
+	if f == nil {
+		return layerCachePath(uncompressedLayersCacheDir, digest)
+	}
+	key := sha256.Sum256([]byte(strings.Join(f.excludes, "\x00")))
+	return layerCachePath(uncompressedLayersCacheDir, fmt.Sprintf("%s-%x", digest, key[:6]))
+}
+
+// copyTar copies the layer tar from the reader to the writer without any excluded entries.
+func (f *pathFilter) copyTar(w io.Writer, r io.Reader) error {
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+
+	var excluded int
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if f.excluded(hdr.Name) {
+			excluded++
+			continue
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+
+	log.WithFields("excluded", excluded).Trace("filtered layer tar")
+	return tw.Close()
+}
@@ -0,0 +1,102 @@
+package image
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func TestWithPathExcludes(t *testing.T) {
+	v1Img, err := mutate.AppendLayers(empty.Image,
+		tarLayer(t, map[string]string{
+			"etc/os-release":  "ID=test\n",
+			"proc/cpuinfo":    "cpu",
+			"sys/kernel/mm":   "mm",
+			"media/intro.mp4": "a very large video",
+		}),
+		tarLayer(t, map[string]string{
+			"srv/videos/outro.mp4": "another very large video",
+			"srv/index.html":       "<html/>",
+		}),
+	)
+	require.NoError(t, err)
+
+	cacheDir := t.TempDir()
+	img := New(v1Img, nil, cacheDir, WithPathExcludes("/proc/**", "sys/**"), WithPathExcludes("**/*.mp4"))
+	require.NoError(t, img.Read())
+
+	tree := img.SquashedTree()
+	for _, p := range []string{"/etc/os-release", "/srv/index.html"} {
+		assert.True(t, tree.HasPath(file.Path(p)), p)
+	}
+	for _, p := range []string{"/proc", "/proc/cpuinfo", "/sys/kernel/mm", "/media/intro.mp4", "/srv/videos/outro.mp4"} {
+		assert.False(t, tree.HasPath(file.Path(p)), p)
+	}
+
+	assert.Equal(t, int64(len("ID=test\n")+len("<html/>")), img.Metadata.Size)
+
+	cached, err := img.CachedLayers()
+	require.NoError(t, err)
+	assert.Len(t, cached, 2)
+
+	// excluded contents are never written to the layer cache
+	entries, err := os.ReadDir(cacheDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	for _, entry := range entries {
+		contents, err := os.ReadFile(cacheDir + "/" + entry.Name())
+		require.NoError(t, err)
+		assert.NotContains(t, string(contents), "very large video")
+		assert.NotContains(t, string(contents), "cpuinfo")
+	}
+}
+
+func Test_pathFilter_excluded(t *testing.T) {
+	filter, err := (*pathFilter)(nil).with("proc/**", "/sys/**", "**/*.mp4")
+	require.NoError(t, err)
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "proc", want: true},
+		{path: "./proc/1/status", want: true},
+		{path: "/sys/kernel", want: true},
+		{path: "media/a.mp4", want: true},
+		{path: "a.mp4", want: true},
+		{path: "etc/os-release"},
+		{path: "process/thing"},
+		{path: "./"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, filter.excluded(tt.path))
+		})
+	}
+
+	assert.False(t, (*pathFilter)(nil).excluded("proc"))
+}
+
+func Test_pathFilter_with(t *testing.T) {
+	_, err := (*pathFilter)(nil).with("[invalid")
+	require.Error(t, err)
+
+	filter, err := (*pathFilter)(nil).with()
+	require.NoError(t, err)
+	assert.Nil(t, filter)
+
+	a, err := (*pathFilter)(nil).with("proc/**")
+	require.NoError(t, err)
+	b, err := a.with("sys/**")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"proc/**"}, a.excludes)
+	assert.Equal(t, []string{"proc/**", "sys/**"}, b.excludes)
+	assert.NotEqual(t, a.cachePath("dir", "sha256:abc"), b.cachePath("dir", "sha256:abc"))
+	assert.Equal(t, layerCachePath("dir", "sha256:abc"), (*pathFilter)(nil).cachePath("dir", "sha256:abc"))
+}
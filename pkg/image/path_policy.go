@@ -0,0 +1,212 @@
+package image
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+)
+
+// PathAnomalyKind describes why a layer entry path may be interpreted differently between providers and filesystems.
+type PathAnomalyKind string
+
+const (
+	// PathAnomalyUnnormalized is a path that is not in Unicode normalization form C (NFC), as is typical of content
+	// written on macOS filesystems (NFD)
+	PathAnomalyUnnormalized PathAnomalyKind = "unnormalized"
+	// PathAnomalyControlCharacters is a path that contains newlines or other control characters
+	PathAnomalyControlCharacters PathAnomalyKind = "control-characters"
+	// PathAnomalyCollision is a path that differs from another path within the same layer only by Unicode normalization
+	PathAnomalyCollision PathAnomalyKind = "collision"
+)
+
+// maxRecordedPathAnomalies bounds the number of anomalies recorded within the metadata of each layer (all anomalies
+// are still counted, see LayerMetadata.PathAnomalyCount).
+const maxRecordedPathAnomalies = 100
+
+// PathAnomaly is a single layer entry path that may be interpreted differently between providers and filesystems. A
+// PathAnomaly of kind PathAnomalyCollision is returned as an error when failing on collisions (see PathPolicy).
+type PathAnomaly struct {
+	Kind PathAnomalyKind
+	// Path is the path of the layer entry as it exists within the layer
+	Path string
+	// NormalizedPath is the NFC normalized form of the path (for unnormalized paths and collisions)
+	NormalizedPath string
+	// CollidesWith is the path of the other layer entry with the same normalized path (for collisions only)
+	CollidesWith string
+}
+
+func (a *PathAnomaly) Error() string {
+	switch a.Kind {
+	case PathAnomalyCollision:
+		return fmt.Sprintf("layer entry %q collides with %q (both normalize to %q)", a.Path, a.CollidesWith, a.NormalizedPath)
+	case PathAnomalyUnnormalized:
+		return fmt.Sprintf("layer entry %q is not NFC normalized (normalized: %q)", a.Path, a.NormalizedPath)
+	default:
+		return fmt.Sprintf("layer entry %q contains %s", a.Path, strings.ReplaceAll(string(a.Kind), "-", " "))
+	}
+}
+
+// PathPolicy controls how layer entries with anomalous paths are handled while layers are read. Regardless of policy,
+// all anomalies are recorded within the layer metadata (see LayerMetadata.PathAnomalies). Note: collisions are only
+// detected between entries within the same layer.
+type PathPolicy struct {
+	// NormalizedAliases adds a symlink at the NFC normalized path of every unnormalized entry (when there is no
+	// colliding entry), such that lookups by the normalized path resolve regardless of the normalization form used
+	// within the layer. Entries within an unnormalized directory are reachable through the alias of the directory.
+	NormalizedAliases bool
+	// SkipControlCharacters skips all entries with paths containing newlines or other control characters
+	SkipControlCharacters bool
+	// FailOnCollision fails the read with a PathAnomaly error when entries within a layer differ only by normalization
+	FailOnCollision bool
+}
+
+// WithPathPolicy sets how layer entries with anomalous paths are handled while layers are read (see PathPolicy).
+func WithPathPolicy(policy PathPolicy) AdditionalMetadata {
+	return func(image *Image) error {
+		image.pathPolicy = &policy
+		return nil
+	}
+}
+
+// pathAuditor records anomalous paths for a single read of a layer tree (a nil auditor admits all entries).
+type pathAuditor struct {
+	policy PathPolicy
+	// metadata is where anomalies are recorded
+	metadata *LayerMetadata
+	// tree is the layer tree being read, used to find entries that collide with unnormalized entries added later
+	tree filetree.PathReader
+	// unnormalized maps the normalized form of all unnormalized entries seen so far to the original entry path
+	unnormalized map[string]string
+	// aliases maps the normalized form of unnormalized entries to the original entry path (see NormalizedAliases)
+	aliases map[string]string
+}
+
+func newPathAuditor(policy *PathPolicy, metadata *LayerMetadata, tree filetree.PathReader) *pathAuditor {
+	a := pathAuditor{
+		metadata:     metadata,
+		tree:         tree,
+		unnormalized: make(map[string]string),
+		aliases:      make(map[string]string),
+	}
+	if policy != nil {
+		a.policy = *policy
+	}
+	return &a
+}
+
+// admit records any anomalies of the given layer entry path, returning false when the entry should be skipped and an
+// error when the read should fail.
+func (a *pathAuditor) admit(p string) (bool, error) {
+	if a == nil {
+		return true, nil
+	}
+
+	p = path.Clean(file.DirSeparator + p)
+
+	if hasControlCharacters(p) {
+		a.record(PathAnomaly{Kind: PathAnomalyControlCharacters, Path: p})
+		if a.policy.SkipControlCharacters {
+			return false, nil
+		}
+	}
+
+	if !utf8.ValidString(p) {
+		// normalization is only meaningful for valid UTF-8
+		return true, nil
+	}
+
+	if norm.NFC.IsNormalString(p) {
+		if other, ok := a.unnormalized[p]; ok {
+			return a.collision(PathAnomaly{Kind: PathAnomalyCollision, Path: p, NormalizedPath: p, CollidesWith: other})
+		}
+		return true, nil
+	}
+
+	normalized := norm.NFC.String(p)
+	a.record(PathAnomaly{Kind: PathAnomalyUnnormalized, Path: p, NormalizedPath: normalized})
+
+	other, ok := a.unnormalized[normalized]
+	switch {
+	case ok && other != p:
+		return a.collision(PathAnomaly{Kind: PathAnomalyCollision, Path: p, NormalizedPath: normalized, CollidesWith: other})
+	case !ok && a.tree != nil && a.tree.HasPath(file.Path(normalized)):
+		a.unnormalized[normalized] = p
+		return a.collision(PathAnomaly{Kind: PathAnomalyCollision, Path: p, NormalizedPath: normalized, CollidesWith: normalized})
+	}
+
+	a.unnormalized[normalized] = p
+	if a.policy.NormalizedAliases && norm.NFC.IsNormalString(path.Dir(p)) {
+		a.aliases[normalized] = p
+	}
+	return true, nil
+}
+
+func (a *pathAuditor) collision(anomaly PathAnomaly) (bool, error) {
+	if a.policy.FailOnCollision {
+		return false, &anomaly
+	}
+	// colliding entries are never aliased
+	delete(a.aliases, anomaly.NormalizedPath)
+	a.record(anomaly)
+	return true, nil
+}
+
+func (a *pathAuditor) record(anomaly PathAnomaly) {
+	metadata := a.metadata
+	if metadata.PathAnomalyCount == 0 {
+		log.WithFields("layer", metadata.Digest, "path", anomaly.Path, "anomaly", anomaly.Kind).Debug("layer contains anomalous paths")
+	}
+	metadata.PathAnomalyCount++
+	if len(metadata.PathAnomalies) < maxRecordedPathAnomalies {
+		metadata.PathAnomalies = append(metadata.PathAnomalies, anomaly)
+	}
+}
+
+// addAliases adds a symlink at the normalized path of each unnormalized entry (see PathPolicy.NormalizedAliases). This
+// must be called after all layer entries have been added, such that no alias is added for colliding entries.
+func (a *pathAuditor) addAliases(builder *filetree.Builder, fileCatalog *FileCatalog, layerRef *Layer) error {
+	if a == nil || len(a.aliases) == 0 {
+		return nil
+	}
+
+	aliases := make([]string, 0, len(a.aliases))
+	for alias := range a.aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	for _, alias := range aliases {
+		ref, err := builder.Add(file.Metadata{
+			FileInfo: file.ManualInfo{
+				NameValue: path.Base(alias),
+				ModeValue: fs.ModeSymlink | 0o777,
+			},
+			Path:            alias,
+			LinkDestination: a.aliases[alias],
+			Type:            file.TypeSymLink,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to add normalized alias %q: %w", alias, err)
+		}
+
+		fileCatalog.addImageReferences(ref.ID(), layerRef, func() io.ReadCloser {
+			return io.NopCloser(strings.NewReader(""))
+		})
+	}
+	return nil
+}
+
+func hasControlCharacters(p string) bool {
+	return strings.IndexFunc(p, unicode.IsControl) >= 0
+}
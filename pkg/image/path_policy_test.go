@@ -0,0 +1,138 @@
+package image
+
+import (
+	"io"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+)
+
+const (
+	// "café" in Unicode normalization form C (composed) and form D (decomposed)
+	cafeNFC = "caf\u00e9"
+	cafeNFD = "cafe\u0301"
+)
+
+func TestWithPathPolicy(t *testing.T) {
+	v1Img, err := mutate.AppendLayers(empty.Image, tarLayer(t, map[string]string{
+		"etc/" + cafeNFD:   "decomposed",
+		"etc/line\nbreak":  "control",
+		"etc/os-release":   "ID=test\n",
+		"other/" + cafeNFD: "decomposed",
+		"other/" + cafeNFC: "composed",
+	}))
+	require.NoError(t, err)
+
+	t.Run("anomalies are always recorded", func(t *testing.T) {
+		img := New(v1Img, nil, t.TempDir())
+		require.NoError(t, img.Read())
+
+		tree := img.SquashedTree()
+		assert.True(t, tree.HasPath("/etc/"+cafeNFD))
+		assert.False(t, tree.HasPath("/etc/"+cafeNFC))
+		assert.True(t, tree.HasPath("/etc/line\nbreak"))
+
+		metadata := img.Layers[0].Metadata
+		assert.Equal(t, 4, metadata.PathAnomalyCount)
+		assert.Equal(t, []PathAnomaly{
+			{Kind: PathAnomalyUnnormalized, Path: "/etc/" + cafeNFD, NormalizedPath: "/etc/" + cafeNFC},
+			{Kind: PathAnomalyControlCharacters, Path: "/etc/line\nbreak"},
+			{Kind: PathAnomalyUnnormalized, Path: "/other/" + cafeNFD, NormalizedPath: "/other/" + cafeNFC},
+			{Kind: PathAnomalyCollision, Path: "/other/" + cafeNFC, NormalizedPath: "/other/" + cafeNFC, CollidesWith: "/other/" + cafeNFD},
+		}, metadata.PathAnomalies)
+	})
+
+	t.Run("normalized aliases", func(t *testing.T) {
+		img := New(v1Img, nil, t.TempDir(), WithPathPolicy(PathPolicy{NormalizedAliases: true}))
+		require.NoError(t, img.Read())
+
+		reader, err := img.OpenPathFromSquash(file.Path("/etc/" + cafeNFC))
+		require.NoError(t, err)
+		contents, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, "decomposed", string(contents))
+
+		// colliding entries are never aliased
+		reader, err = img.OpenPathFromSquash(file.Path("/other/" + cafeNFC))
+		require.NoError(t, err)
+		contents, err = io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, "composed", string(contents))
+	})
+
+	t.Run("skip control characters", func(t *testing.T) {
+		img := New(v1Img, nil, t.TempDir(), WithPathPolicy(PathPolicy{SkipControlCharacters: true}))
+		require.NoError(t, img.Read())
+
+		assert.False(t, img.SquashedTree().HasPath("/etc/line\nbreak"))
+		assert.True(t, img.SquashedTree().HasPath("/etc/os-release"))
+	})
+
+	t.Run("fail on collision", func(t *testing.T) {
+		img := New(v1Img, nil, t.TempDir(), WithPathPolicy(PathPolicy{FailOnCollision: true}))
+		err := img.Read()
+
+		var anomaly *PathAnomaly
+		require.ErrorAs(t, err, &anomaly)
+		assert.Equal(t, PathAnomalyCollision, anomaly.Kind)
+		assert.Equal(t, "/other/"+cafeNFD, anomaly.CollidesWith)
+	})
+}
+
+func Test_pathAuditor_admit(t *testing.T) {
+	tree := filetree.New()
+	_, err := tree.AddFile("/K")
+	require.NoError(t, err)
+
+	metadata := LayerMetadata{}
+	auditor := newPathAuditor(&PathPolicy{NormalizedAliases: true}, &metadata, tree)
+
+	tests := []struct {
+		path        string
+		wantAlias   string
+		wantAnomaly []PathAnomalyKind
+	}{
+		{path: "usr/bin/env"},
+		{path: "./etc/" + cafeNFD, wantAlias: "/etc/" + cafeNFC, wantAnomaly: []PathAnomalyKind{PathAnomalyUnnormalized}},
+		// the same entry may appear more than once within a layer
+		{path: "etc/" + cafeNFD, wantAlias: "/etc/" + cafeNFC, wantAnomaly: []PathAnomalyKind{PathAnomalyUnnormalized}},
+		// entries within unnormalized directories are not aliased
+		{path: "etc/" + cafeNFD + "/file", wantAnomaly: []PathAnomalyKind{PathAnomalyUnnormalized}},
+		// the kelvin sign normalizes to "K", which is already in the tree
+		{path: "K", wantAnomaly: []PathAnomalyKind{PathAnomalyUnnormalized, PathAnomalyCollision}},
+		{path: "tab\there", wantAnomaly: []PathAnomalyKind{PathAnomalyControlCharacters}},
+		// invalid UTF-8 is never normalized
+		{path: "bad\xffname"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			before := len(metadata.PathAnomalies)
+
+			admitted, err := auditor.admit(tt.path)
+			require.NoError(t, err)
+			assert.True(t, admitted)
+			if tt.wantAlias != "" {
+				assert.Contains(t, auditor.aliases, tt.wantAlias)
+			}
+
+			var got []PathAnomalyKind
+			for _, a := range metadata.PathAnomalies[before:] {
+				got = append(got, a.Kind)
+			}
+			assert.Equal(t, tt.wantAnomaly, got)
+		})
+	}
+
+	assert.Equal(t, map[string]string{"/etc/" + cafeNFC: "/etc/" + cafeNFD}, auditor.aliases)
+
+	var nilAuditor *pathAuditor
+	admitted, err := nilAuditor.admit("etc/" + cafeNFD)
+	assert.True(t, admitted)
+	assert.NoError(t, err)
+}
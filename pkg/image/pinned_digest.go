@@ -0,0 +1,163 @@
+package image
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ErrDigestMismatch is returned when an image does not match an expected digest, or when its content does not match
+// the digests it is described by (see Image.VerifyDigest).
+type ErrDigestMismatch struct {
+	// Subject is what was verified (e.g. "image", "config", or "layer 2 diff ID")
+	Subject  string
+	Expected string
+	Actual   string
+}
+
+func (e *ErrDigestMismatch) Error() string {
+	return fmt.Sprintf("%s digest mismatch: expected %s, got %s", e.Subject, e.Expected, e.Actual)
+}
+
+// VerifyDigest ensures the image is the image pinned by the given digest, which is either the manifest digest, the
+// digest of the multi-platform index the image was selected from, or the image ID (the config digest, which is the only
+// stable digest of images exported from a daemon or archive). Only digests computed from the content that was read are
+// considered: repo digests reported by the source (e.g. a daemon) are claims that nothing ties to the content, thus
+// never verify an image. The chain of digests is verified against the content that was read: the raw index against
+// the index digest and the manifest within it, the raw manifest against the manifest digest, the config digest within
+// the manifest and the raw config against the image ID, and the uncompressed content of every layer against its diff ID
+// within the config. An ErrDigestMismatch is returned when any digest does not match.
+func (i *Image) VerifyDigest(expected string) error {
+	if err := i.verifyPinnedDigest(expected); err != nil {
+		return err
+	}
+
+	if err := i.verifyManifestDigests(); err != nil {
+		return err
+	}
+
+	if len(i.Metadata.RawConfig) > 0 {
+		if actual := sha256Digest(i.Metadata.RawConfig); strings.HasPrefix(i.Metadata.ID, "sha256:") && actual != i.Metadata.ID {
+			return &ErrDigestMismatch{Subject: "config", Expected: i.Metadata.ID, Actual: actual}
+		}
+	}
+
+	return i.verifyDiffIDs()
+}
+
+// verifyPinnedDigest ensures the given digest is one of the digests computed from the content the image was read from.
+func (i *Image) verifyPinnedDigest(expected string) error {
+	if expected == i.Metadata.ID && len(i.Metadata.RawConfig) > 0 {
+		// note: the raw config is verified against the image ID afterwards
+		return nil
+	}
+
+	if len(i.Metadata.RawManifest) > 0 {
+		manifestDigest := sha256Digest(i.Metadata.RawManifest)
+		if expected == manifestDigest {
+			return nil
+		}
+		if len(i.Metadata.RawIndex) > 0 && expected == sha256Digest(i.Metadata.RawIndex) {
+			return verifyIndexManifest(i.Metadata.RawIndex, manifestDigest)
+		}
+	}
+
+	actual := i.Metadata.ManifestDigest
+	if actual == "" {
+		actual = i.Metadata.ID
+	}
+	return &ErrDigestMismatch{Subject: "image", Expected: expected, Actual: actual}
+}
+
+// verifyIndexManifest ensures the given raw index lists the manifest with the given digest.
+func verifyIndexManifest(rawIndex []byte, manifestDigest string) error {
+	index, err := v1.ParseIndexManifest(bytes.NewReader(rawIndex))
+	if err != nil {
+		return fmt.Errorf("unable to parse image index: %w", err)
+	}
+	for _, m := range index.Manifests {
+		if m.Digest.String() == manifestDigest {
+			return nil
+		}
+	}
+	return &ErrDigestMismatch{Subject: "index manifest", Expected: manifestDigest, Actual: "(not listed)"}
+}
+
+// verifyManifestDigests ensures the raw manifest (when known) matches the manifest digest and refers to the config.
+func (i *Image) verifyManifestDigests() error {
+	if len(i.Metadata.RawManifest) == 0 {
+		return nil
+	}
+
+	if actual := sha256Digest(i.Metadata.RawManifest); strings.HasPrefix(i.Metadata.ManifestDigest, "sha256:") && actual != i.Metadata.ManifestDigest {
+		return &ErrDigestMismatch{Subject: "manifest", Expected: i.Metadata.ManifestDigest, Actual: actual}
+	}
+
+	manifest, err := v1.ParseManifest(bytes.NewReader(i.Metadata.RawManifest))
+	if err != nil {
+		return fmt.Errorf("unable to parse image manifest: %w", err)
+	}
+	if actual := manifest.Config.Digest.String(); actual != i.Metadata.ID {
+		return &ErrDigestMismatch{Subject: "manifest config", Expected: i.Metadata.ID, Actual: actual}
+	}
+	return nil
+}
+
+// verifyDiffIDs ensures the uncompressed content of every layer matches the diff ID of the layer within the config.
+func (i *Image) verifyDiffIDs() error {
+	diffIDs := i.Metadata.Config.RootFS.DiffIDs
+	if len(i.Layers) != len(diffIDs) || len(i.FailedLayers()) > 0 {
+		return fmt.Errorf("unable to verify the layers of a partially read image (%d of %d layers read, %d failed)", len(i.Layers), len(diffIDs), len(i.FailedLayers()))
+	}
+
+	for idx, layer := range i.Layers {
+		if expected := diffIDs[idx].String(); layer.Metadata.Digest != expected {
+			return &ErrDigestMismatch{Subject: fmt.Sprintf("layer %d diff ID", idx), Expected: expected, Actual: layer.Metadata.Digest}
+		}
+		if err := layer.verifyDiffID(i.contentCacheDir); err != nil {
+			return fmt.Errorf("unable to verify layer %d: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+// verifyDiffID ensures the uncompressed content of the layer matches its diff ID, preferring the (unfiltered) layer
+// tar within the content cache over reading the layer again.
+func (l *Layer) verifyDiffID(contentCacheDir string) error {
+	expected := l.Metadata.Digest
+	if !strings.HasPrefix(expected, "sha256:") {
+		return fmt.Errorf("unsupported diff ID: %q", expected)
+	}
+
+	var reader io.ReadCloser
+	if l.pathFilter == nil && contentCacheDir != "" {
+		if fh, err := os.Open(layerCachePath(contentCacheDir, expected)); err == nil {
+			reader = fh
+		}
+	}
+	if reader == nil {
+		var err error
+		if reader, err = l.uncompressedReader(); err != nil {
+			return err
+		}
+	}
+	defer reader.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, reader); err != nil {
+		return err
+	}
+	if actual := fmt.Sprintf("sha256:%x", hasher.Sum(nil)); actual != expected {
+		return &ErrDigestMismatch{Subject: fmt.Sprintf("layer %d diff ID", l.Metadata.Index), Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+func sha256Digest(b []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(b))
+}
@@ -0,0 +1,130 @@
+package image
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_VerifyDigest(t *testing.T) {
+	repoDigest := "sha256:" + strings.Repeat("a", 64)
+
+	newImage := func(t *testing.T) *Image {
+		layer := tarLayer(t, map[string]string{"etc/os-release": "ID=test\n"})
+		v1Img, err := mutate.AppendLayers(empty.Image, layer)
+		require.NoError(t, err)
+		rawManifest, err := v1Img.RawManifest()
+		require.NoError(t, err)
+		rawIndex, err := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: v1Img}).RawManifest()
+		require.NoError(t, err)
+
+		img := New(v1Img, nil, t.TempDir(), WithManifest(rawManifest), WithIndex(rawIndex), WithRepoDigests("example.com/app@"+repoDigest))
+		require.NoError(t, img.Read())
+		return img
+	}
+
+	tests := []struct {
+		name        string
+		expected    func(img *Image) string
+		tamper      func(t *testing.T, img *Image)
+		wantSubject string
+	}{
+		{
+			name:     "manifest digest",
+			expected: func(img *Image) string { return img.Metadata.ManifestDigest },
+		},
+		{
+			name:     "index digest",
+			expected: func(img *Image) string { return img.Metadata.IndexDigest },
+		},
+		{
+			name:     "index not listing the manifest",
+			expected: func(img *Image) string { return img.Metadata.IndexDigest },
+			tamper: func(t *testing.T, img *Image) {
+				rawIndex, err := empty.Index.RawManifest()
+				require.NoError(t, err)
+				require.NoError(t, WithIndex(rawIndex)(img))
+			},
+			wantSubject: "index manifest",
+		},
+		{
+			// repo digests are claims of the source (e.g. a daemon), not computed from the content
+			name:        "repo digest",
+			expected:    func(*Image) string { return repoDigest },
+			wantSubject: "image",
+		},
+		{
+			name:     "claimed manifest digest",
+			expected: func(*Image) string { return repoDigest },
+			tamper: func(_ *testing.T, img *Image) {
+				img.Metadata.RawManifest = nil
+				img.Metadata.ManifestDigest = repoDigest
+			},
+			wantSubject: "image",
+		},
+		{
+			name:     "image ID",
+			expected: func(img *Image) string { return img.Metadata.ID },
+		},
+		{
+			name:        "other image",
+			expected:    func(*Image) string { return "sha256:" + strings.Repeat("b", 64) },
+			wantSubject: "image",
+		},
+		{
+			name:     "tampered manifest",
+			expected: func(img *Image) string { return img.Metadata.ID },
+			tamper: func(_ *testing.T, img *Image) {
+				img.Metadata.RawManifest = append(img.Metadata.RawManifest, ' ')
+			},
+			wantSubject: "manifest",
+		},
+		{
+			name:     "tampered config",
+			expected: func(img *Image) string { return img.Metadata.ID },
+			tamper: func(_ *testing.T, img *Image) {
+				img.Metadata.RawConfig = append(img.Metadata.RawConfig, ' ')
+			},
+			wantSubject: "config",
+		},
+		{
+			name:     "tampered layer",
+			expected: func(img *Image) string { return img.Metadata.ManifestDigest },
+			tamper: func(t *testing.T, img *Image) {
+				tarPath := layerCachePath(img.contentCacheDir, img.Layers[0].Metadata.Digest)
+				require.NoError(t, os.WriteFile(tarPath, []byte("tampered"), 0o600))
+			},
+			wantSubject: "layer 0 diff ID",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := newImage(t)
+			if tt.tamper != nil {
+				tt.tamper(t, img)
+			}
+
+			err := img.VerifyDigest(tt.expected(img))
+			if tt.wantSubject == "" {
+				require.NoError(t, err)
+				return
+			}
+			var mismatch *ErrDigestMismatch
+			require.ErrorAs(t, err, &mismatch)
+			assert.Equal(t, tt.wantSubject, mismatch.Subject)
+		})
+	}
+}
+
+func TestImage_VerifyDigest_partial(t *testing.T) {
+	img := newTestImage(t, nil, "")
+	img.Layers = nil
+
+	err := img.VerifyDigest(img.Metadata.ID)
+	require.ErrorContains(t, err, "partially read")
+}
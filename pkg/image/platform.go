@@ -62,6 +62,26 @@ func (p *Platform) String() string {
 	return strings.Join(fields, "/")
 }
 
+// Matches indicates that the given OS, architecture, and variant (e.g. from an image config or index descriptor)
+// satisfy this platform. The OS and variant are only considered when specified on this platform, and the OS is only
+// compared when the given OS is known.
+func (p *Platform) Matches(os, architecture, variant string) bool {
+	if p == nil {
+		return true
+	}
+	if p.OS != "" && os != "" && p.OS != normalizeOS(os) {
+		return false
+	}
+	arch, variant := normalizeArch(architecture, variant)
+	if p.Architecture != arch {
+		return false
+	}
+	if p.Variant != "" && p.Variant != variant {
+		return false
+	}
+	return true
+}
+
 // parse has been extracted out from containerd (platforms/platforms.go). The behavior in containerd is to use the
 // runtime package to assume default values. This might be OK for a container engine, however, syft and other consumers
 // of stereoscope are at the client side, where we cannot fill default OS/arch values based on the client we
@@ -103,3 +103,29 @@ func TestNewPlatform(t *testing.T) {
 		})
 	}
 }
+
+func TestPlatform_Matches(t *testing.T) {
+	tests := []struct {
+		name      string
+		specifier string
+		os        string
+		arch      string
+		variant   string
+		want      bool
+	}{
+		{name: "exact", specifier: "linux/amd64", os: "linux", arch: "amd64", want: true},
+		{name: "normalized arch", specifier: "linux/amd64", os: "linux", arch: "x86_64", want: true},
+		{name: "normalized variant", specifier: "linux/arm64", os: "linux", arch: "arm64", variant: "v8", want: true},
+		{name: "unknown os", specifier: "linux/amd64", arch: "amd64", want: true},
+		{name: "different arch", specifier: "linux/amd64", os: "linux", arch: "arm64", want: false},
+		{name: "different os", specifier: "windows/amd64", os: "linux", arch: "amd64", want: false},
+		{name: "different variant", specifier: "linux/arm/v7", os: "linux", arch: "arm", variant: "v6", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPlatform(tt.specifier)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, p.Matches(tt.os, tt.arch, tt.variant))
+		})
+	}
+}
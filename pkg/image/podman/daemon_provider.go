@@ -1,8 +1,13 @@
 package podman
 
 import (
+	"context"
+	"errors"
+
 	"github.com/docker/docker/client"
 
+	"github.com/anchore/stereoscope/internal/environ"
+	"github.com/anchore/stereoscope/internal/log"
 	"github.com/anchore/stereoscope/internal/podman"
 	"github.com/anchore/stereoscope/pkg/file"
 	"github.com/anchore/stereoscope/pkg/image"
@@ -11,8 +16,64 @@ import (
 
 const Daemon image.Source = image.PodmanDaemonSource
 
+// NewDaemonProvider creates a new provider for an image within the podman service. When the service speaks the native
+// libpod API, the image is inspected and exported through libpod endpoints (which report more accurate metadata, e.g.
+// the variant and digests of images from manifest lists), otherwise the docker-compatible API is used.
 func NewDaemonProvider(tmpDirGen *file.TempDirGenerator, imageStr string, platform *image.Platform) image.Provider {
-	return docker.NewAPIClientProvider(Daemon, tmpDirGen, imageStr, platform, func() (client.APIClient, error) {
-		return podman.GetClient()
-	})
+	return &daemonImageProvider{
+		compat: docker.NewEnvAPIClientProvider(Daemon, tmpDirGen, imageStr, platform, func(env *image.EnvOverrides) (client.APIClient, error) {
+			return podman.GetClientWithOverrides(env)
+		}),
+		libpod: &libpodImageProvider{
+			tmpDirGen: tmpDirGen,
+			imageStr:  imageStr,
+			platform:  platform,
+		},
+	}
+}
+
+// daemonImageProvider is an image.Provider for images within the podman service, through the libpod API when
+// supported by the service and the docker-compatible API otherwise.
+type daemonImageProvider struct {
+	compat image.Provider
+	libpod *libpodImageProvider
+}
+
+func (p *daemonImageProvider) Name() string {
+	return Daemon
+}
+
+func (p *daemonImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	c := detectLibpod(ctx, environ.FromContext(ctx))
+	if c == nil {
+		return p.compat.Provide(ctx)
+	}
+
+	log.WithFields("connection", c.connection.URI, "version", c.apiVersion).Trace("using the podman libpod API")
+	return p.libpod.provideFrom(ctx, []*libpodClient{c})
+}
+
+// detectLibpod returns a client for the first podman connection that responds, when it speaks the libpod API (nil
+// otherwise, in which case the docker-compatible API is to be used).
+func detectLibpod(ctx context.Context, env *environ.Overrides) *libpodClient {
+	for _, connection := range podman.Connections(env) {
+		httpClient, err := connection.HTTPClient(env)
+		if err != nil {
+			log.WithFields("connection", connection.URI, "error", err).Trace("unable to connect to podman")
+			continue
+		}
+
+		c := &libpodClient{connection: connection, http: httpClient}
+		err = c.ping(ctx)
+		switch {
+		case err == nil:
+			return c
+		case errors.Is(err, errNotLibpod):
+			log.WithFields("connection", connection.URI, "error", err).Trace("using the podman docker-compatible API")
+			return nil
+		default:
+			log.WithFields("connection", connection.URI, "error", err).Trace("unable to ping podman")
+		}
+	}
+	return nil
 }
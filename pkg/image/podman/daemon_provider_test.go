@@ -0,0 +1,98 @@
+package podman
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/internal/environ"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func Test_detectLibpod(t *testing.T) {
+	tests := []struct {
+		name        string
+		compatOnly  bool
+		unreachable bool
+		wantLibpod  bool
+	}{
+		{
+			name:       "service speaking libpod",
+			wantLibpod: true,
+		},
+		{
+			name:       "service speaking only the docker-compatible API",
+			compatOnly: true,
+		},
+		{
+			name:        "unreachable service is skipped",
+			unreachable: true,
+			wantLibpod:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// note: unix socket paths are limited in length, thus the (typically long) test temp dir is not used
+			dir, err := os.MkdirTemp("", "libpod")
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+			f := newFakeLibpod(t, filepath.Join(dir, "run", "podman", "podman.sock"), true)
+			f.compatOnly = test.compatOnly
+
+			vars := map[string]string{}
+			if test.unreachable {
+				vars["CONTAINER_HOST"] = "unix://" + filepath.Join(dir, "missing.sock")
+			}
+
+			c := detectLibpod(context.Background(), &environ.Overrides{
+				Vars:       vars,
+				Hermetic:   true,
+				HomeDir:    dir,
+				RuntimeDir: filepath.Join(dir, "run"),
+			})
+			if !test.wantLibpod {
+				assert.Nil(t, c)
+				return
+			}
+			require.NotNil(t, c)
+			assert.Equal(t, "unix://"+f.socket, c.connection.URI)
+			assert.Equal(t, "4.9.3", c.apiVersion)
+		})
+	}
+}
+
+func TestDaemonProvider_Provide_libpod(t *testing.T) {
+	app := newFakeLibpodImage(t, "localhost/app:v1", "arm64")
+	app.inspect.Variant = "v8"
+
+	dir, err := os.MkdirTemp("", "libpod")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	newFakeLibpod(t, filepath.Join(dir, "run", "podman", "podman.sock"), true, app)
+
+	ctx := image.ContextWithEnvOverrides(context.Background(), &image.EnvOverrides{
+		Hermetic:   true,
+		HomeDir:    dir,
+		RuntimeDir: filepath.Join(dir, "run"),
+	})
+
+	tmpDirGen := file.NewTempDirGenerator("podman")
+	t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+	provider := NewDaemonProvider(tmpDirGen, "localhost/app:v1", nil)
+	assert.Equal(t, Daemon, provider.Name())
+
+	img, err := provider.Provide(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:"+app.inspect.ID, img.Metadata.ID)
+	assert.Equal(t, "v8", img.Metadata.Variant)
+	assert.Equal(t, app.inspect.RepoDigests, img.Metadata.RepoDigests)
+}
@@ -0,0 +1,443 @@
+package podman
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/anchore/stereoscope/internal/environ"
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/internal/podman"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/anchore/stereoscope/pkg/image/docker"
+)
+
+const Libpod image.Source = image.PodmanLibpodSource
+
+// libpodAPI is the base URL of the libpod REST API (podman serves any API version up to its own, and the host is
+// ignored since requests are dialed to the connection socket).
+const libpodAPI = "http://d/v4.0.0/libpod"
+
+// libpodVersionHeader is the response header podman sets with the version of the native libpod API it serves.
+const libpodVersionHeader = "Libpod-API-Version"
+
+var (
+	// errNotFound is returned for 404 responses of the libpod API (e.g. an image that is not within the store).
+	errNotFound = errors.New("not found")
+	// errNotLibpod is returned when a service responds without speaking the libpod API (e.g. a docker daemon or a
+	// service offering only the docker-compatible API).
+	errNotLibpod = errors.New("libpod API not supported")
+)
+
+// NewLibpodProvider creates a new provider for an image within podman using the native podman (libpod) REST API
+// instead of the docker-compatible API. All podman connections are considered (see Stores), where the first store
+// holding the image is used, thus images within both rootless and rootful stores are found. Images may be given by
+// name, ID, or digest (e.g. "alpine@sha256:..."), and are pulled into the first available store when not found.
+func NewLibpodProvider(tmpDirGen *file.TempDirGenerator, imageStr string, platform *image.Platform) image.Provider {
+	return &libpodImageProvider{
+		tmpDirGen: tmpDirGen,
+		imageStr:  imageStr,
+		platform:  platform,
+	}
+}
+
+// libpodImageProvider is an image.Provider for images within podman stores, exported through the libpod REST API.
+type libpodImageProvider struct {
+	tmpDirGen *file.TempDirGenerator
+	imageStr  string
+	platform  *image.Platform
+}
+
+func (p *libpodImageProvider) Name() string {
+	return Libpod
+}
+
+// Store is an image store of a podman service that is reachable through one of the configured podman connections.
+type Store struct {
+	// Connection is the name of the connection within containers.conf (empty when not configured there)
+	Connection string
+	// URI is the address of the podman service
+	URI string
+	// GraphRoot is the root directory of the image store on the podman host
+	GraphRoot string
+	// Rootless indicates the podman service runs without root privileges
+	Rootless bool
+	// Images is the number of images within the store
+	Images int
+}
+
+// Stores lists the image stores of all reachable podman services (see podman connections within containers.conf,
+// CONTAINER_HOST, and CONTAINER_CONNECTION), read from the environment of the given context (see
+// image.ContextWithEnvOverrides).
+func Stores(ctx context.Context) ([]Store, error) {
+	clients := connect(ctx, environ.FromContext(ctx))
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("no podman service available")
+	}
+
+	var stores []Store
+	for _, c := range clients {
+		info, err := c.info(ctx)
+		if err != nil {
+			log.WithFields("connection", c.connection.URI, "error", err).Debug("unable to get podman service info")
+			continue
+		}
+		stores = append(stores, Store{
+			Connection: c.connection.Name,
+			URI:        c.connection.URI,
+			GraphRoot:  info.Store.GraphRoot,
+			Rootless:   info.Host.Security.Rootless,
+			Images:     info.Store.ImageStore.Number,
+		})
+	}
+	return stores, nil
+}
+
+// Provide an image object that represents the image exported from the podman store that holds it.
+func (p *libpodImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	if p.imageStr == "" {
+		return nil, fmt.Errorf("no image name, ID, or digest provided")
+	}
+
+	clients := connect(ctx, environ.FromContext(ctx))
+	if len(clients) == 0 {
+		return nil, image.MarkError(fmt.Errorf("%s not available: no podman service found", Libpod), image.ErrDaemonUnavailable)
+	}
+
+	return p.provideFrom(ctx, clients)
+}
+
+// provideFrom provides the image from the first store holding it among the given clients (pulling into the first
+// store when not found).
+func (p *libpodImageProvider) provideFrom(ctx context.Context, clients []*libpodClient) (*image.Image, error) {
+	c, inspect, err := p.findImage(ctx, clients)
+	if errors.Is(err, errNotFound) || (err == nil && !p.matchesPlatform(inspect)) {
+		// pull into the first store when the image is not found, otherwise the image within the store it was found in is
+		// replaced with the image for the requested platform
+		if c == nil {
+			c = clients[0]
+		}
+		if err = p.pull(ctx, c); err != nil {
+			return nil, err
+		}
+		inspect, err = c.inspect(ctx, p.imageStr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to inspect image %q: %w", p.imageStr, err)
+	}
+
+	if !p.matchesPlatform(inspect) {
+		return nil, image.MarkError(fmt.Errorf("image %q does not match the requested platform %q (found %s/%s)", p.imageStr, p.platform.String(), inspect.Os, inspect.Architecture), image.ErrPlatformMismatch)
+	}
+
+	log.WithFields("image", p.imageStr, "id", inspect.ID, "connection", c.connection.URI).Debug("providing image from podman")
+
+	metadata := []image.AdditionalMetadata{
+		image.WithTags(inspect.RepoTags...),
+		image.WithRepoDigests(inspect.RepoDigests...),
+		image.WithArchitecture(inspect.Architecture, inspect.Variant),
+		image.WithOS(inspect.Os),
+		image.WithDaemonUsage(image.DaemonUsage{
+			Size:        inspect.Size,
+			VirtualSize: inspect.VirtualSize,
+			SharedSize:  -1,
+		}),
+	}
+
+	// export by ID, such that the exported image is the inspected image (even when resolved by digest)
+	reader, err := c.export(ctx, inspect.ID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to export image %q: %w", p.imageStr, err)
+	}
+	defer reader.Close()
+
+	if image.IsStreamingExport(ctx) {
+		return docker.NewStreamArchiveProvider(p.tmpDirGen, reader, nil, metadata...).Provide(ctx)
+	}
+
+	tarFileName, err := p.saveImage(reader)
+	if err != nil {
+		return nil, err
+	}
+	return docker.NewArchiveProvider(p.tmpDirGen, tarFileName, metadata...).Provide(ctx)
+}
+
+// findImage returns the first client with a store holding the image.
+func (p *libpodImageProvider) findImage(ctx context.Context, clients []*libpodClient) (*libpodClient, *libpodImage, error) {
+	for _, c := range clients {
+		inspect, err := c.inspect(ctx, p.imageStr)
+		switch {
+		case err == nil:
+			return c, inspect, nil
+		case errors.Is(err, errNotFound):
+			continue
+		default:
+			log.WithFields("connection", c.connection.URI, "error", err).Debug("unable to inspect podman image")
+		}
+	}
+	return nil, nil, errNotFound
+}
+
+func (p *libpodImageProvider) matchesPlatform(inspect *libpodImage) bool {
+	return p.platform == nil || p.platform.Matches(inspect.Os, inspect.Architecture, inspect.Variant)
+}
+
+func (p *libpodImageProvider) pull(ctx context.Context, c *libpodClient) error {
+	action := fmt.Sprintf("pull %s image=%q", Libpod, p.imageStr)
+	if err := image.CheckNetworkAccess(ctx, action); err != nil {
+		return err
+	}
+	if err := image.CheckDaemonMutation(ctx, action); err != nil {
+		return err
+	}
+
+	log.WithFields("image", p.imageStr, "connection", c.connection.URI).Debug("pulling image into podman")
+	if err := c.pull(ctx, p.imageStr, p.platform); err != nil {
+		return fmt.Errorf("pull failed: %w", err)
+	}
+	return nil
+}
+
+func (p *libpodImageProvider) saveImage(reader io.Reader) (string, error) {
+	imageTempDir, err := p.tmpDirGen.NewDirectory("podman-libpod-image")
+	if err != nil {
+		return "", err
+	}
+
+	tarFile, err := os.Create(filepath.Join(imageTempDir, "image.tar"))
+	if err != nil {
+		return "", fmt.Errorf("unable to create temp file for image: %w", err)
+	}
+	defer tarFile.Close()
+
+	n, err := io.Copy(tarFile, reader)
+	if err != nil {
+		return "", fmt.Errorf("unable to save image to tar: %w", err)
+	}
+	if n == 0 {
+		return "", errors.New("cannot provide an empty image")
+	}
+	return tarFile.Name(), nil
+}
+
+// connect returns clients for all podman connections that respond to a ping (in order of precedence).
+func connect(ctx context.Context, env *environ.Overrides) []*libpodClient {
+	var clients []*libpodClient
+	for _, connection := range podman.Connections(env) {
+		httpClient, err := connection.HTTPClient(env)
+		if err != nil {
+			log.WithFields("connection", connection.URI, "error", err).Trace("unable to connect to podman")
+			continue
+		}
+
+		c := &libpodClient{connection: connection, http: httpClient}
+		if err := c.ping(ctx); err != nil {
+			log.WithFields("connection", connection.URI, "error", err).Trace("unable to ping podman")
+			continue
+		}
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+// libpodClient is a minimal client for the libpod REST API over a single podman connection.
+type libpodClient struct {
+	connection podman.Connection
+	http       *http.Client
+	// apiVersion is the libpod API version reported by the service (set once pinged)
+	apiVersion string
+}
+
+// libpodImage is the subset of the libpod image inspect response that is used.
+type libpodImage struct {
+	ID           string   `json:"Id"`
+	Digest       string   `json:"Digest"`
+	RepoTags     []string `json:"RepoTags"`
+	RepoDigests  []string `json:"RepoDigests"`
+	Architecture string   `json:"Architecture"`
+	Os           string   `json:"Os"`
+	Variant      string   `json:"Variant"`
+	Size         int64    `json:"Size"`
+	VirtualSize  int64    `json:"VirtualSize"`
+}
+
+// libpodInfo is the subset of the libpod system info response that is used.
+type libpodInfo struct {
+	Host struct {
+		Security struct {
+			Rootless bool `json:"rootless"`
+		} `json:"security"`
+	} `json:"host"`
+	Store struct {
+		GraphRoot  string `json:"graphRoot"`
+		ImageStore struct {
+			Number int `json:"number"`
+		} `json:"imageStore"`
+	} `json:"store"`
+}
+
+// libpodError is the error response body of the libpod REST API.
+type libpodError struct {
+	Cause    string `json:"cause"`
+	Message  string `json:"message"`
+	Response int    `json:"response"`
+}
+
+// ping checks the service responds and speaks the libpod API, where errNotLibpod is returned for services that respond
+// without it (e.g. only offering the docker-compatible API).
+func (c *libpodClient) ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	resp, err := c.do(ctx, http.MethodGet, "/_ping", nil)
+	var statusErr *libpodStatusError
+	if errors.As(err, &statusErr) {
+		return fmt.Errorf("%w: %w", errNotLibpod, err)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	c.apiVersion = resp.Header.Get(libpodVersionHeader)
+	if c.apiVersion == "" {
+		return fmt.Errorf("%w: no %s header", errNotLibpod, libpodVersionHeader)
+	}
+	return nil
+}
+
+func (c *libpodClient) info(ctx context.Context) (*libpodInfo, error) {
+	var info libpodInfo
+	if err := c.getJSON(ctx, "/info", &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// inspect returns the image with the given name, ID, or digest (errNotFound when not within the store).
+func (c *libpodClient) inspect(ctx context.Context, name string) (*libpodImage, error) {
+	var img libpodImage
+	if err := c.getJSON(ctx, "/images/"+name+"/json", &img); err != nil {
+		return nil, err
+	}
+	return &img, nil
+}
+
+// pull pulls the given image (for the given platform) into the store when missing.
+func (c *libpodClient) pull(ctx context.Context, ref string, platform *image.Platform) error {
+	query := url.Values{
+		"reference": {ref},
+		"policy":    {"missing"},
+		"quiet":     {"true"},
+	}
+	if platform != nil {
+		query.Set("OS", platform.OS)
+		query.Set("Arch", platform.Architecture)
+		if platform.Variant != "" {
+			query.Set("Variant", platform.Variant)
+		}
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/images/pull", query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// the pull report is streamed as a sequence of JSON objects, where failures are reported within the stream
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var report struct {
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&report); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("unable to read pull report: %w", err)
+		}
+		if report.Error != "" {
+			return errors.New(report.Error)
+		}
+	}
+}
+
+// export returns the docker archive of the image with the given ID.
+func (c *libpodClient) export(ctx context.Context, id string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/images/"+id+"/get", url.Values{"format": {"docker-archive"}})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (c *libpodClient) getJSON(ctx context.Context, p string, v interface{}) error {
+	resp, err := c.do(ctx, http.MethodGet, p, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("unable to decode podman response: %w", err)
+	}
+	return nil
+}
+
+// do makes a request to the libpod API, where responses other than 2xx are returned as errors (errNotFound for
+// a 404 response).
+func (c *libpodClient) do(ctx context.Context, method, p string, query url.Values) (*http.Response, error) {
+	u, err := url.Parse(libpodAPI)
+	if err != nil {
+		return nil, err
+	}
+	u.Path += p
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var apiErr libpodError
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&apiErr); err != nil || apiErr.Message == "" {
+		apiErr.Message = resp.Status
+	}
+
+	return nil, &libpodStatusError{status: resp.StatusCode, message: apiErr.Message}
+}
+
+// libpodStatusError is a response from the service other than 2xx (which is errNotFound for a 404 response).
+type libpodStatusError struct {
+	status  int
+	message string
+}
+
+func (e *libpodStatusError) Error() string {
+	if e.status == http.StatusNotFound {
+		return fmt.Sprintf("%s: %s", errNotFound, e.message)
+	}
+	return fmt.Sprintf("podman API error (status %d): %s", e.status, e.message)
+}
+
+func (e *libpodStatusError) Is(target error) bool {
+	return target == errNotFound && e.status == http.StatusNotFound
+}
@@ -0,0 +1,333 @@
+package podman
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// fakeLibpod serves a minimal libpod REST API over a unix socket for a store holding the given images.
+type fakeLibpod struct {
+	socket   string
+	rootless bool
+	// pullable are images that are added to the store when pulled
+	pullable map[string]fakeLibpodImage
+	// compatOnly serves only the docker-compatible API (all libpod endpoints are not found)
+	compatOnly bool
+
+	lock   sync.Mutex
+	images map[string]fakeLibpodImage
+	pulls  []string
+}
+
+type fakeLibpodImage struct {
+	inspect libpodImage
+	img     v1.Image
+	tag     string
+}
+
+func newFakeLibpod(t *testing.T, socket string, rootless bool, images ...fakeLibpodImage) *fakeLibpod {
+	t.Helper()
+
+	f := &fakeLibpod{
+		socket:   socket,
+		rootless: rootless,
+		pullable: make(map[string]fakeLibpodImage),
+		images:   make(map[string]fakeLibpodImage),
+	}
+	for _, img := range images {
+		f.add(img)
+	}
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(socket), 0700))
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	server := &http.Server{Handler: f} //nolint:gosec
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	return f
+}
+
+func (f *fakeLibpod) add(img fakeLibpodImage) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.images[img.inspect.ID] = img
+	f.images[img.tag] = img
+	for _, d := range img.inspect.RepoDigests {
+		f.images[d] = img
+	}
+}
+
+func (f *fakeLibpod) lookup(n string) (fakeLibpodImage, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	img, ok := f.images[n]
+	return img, ok
+}
+
+func (f *fakeLibpod) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p, ok := strings.CutPrefix(r.URL.Path, "/v4.0.0/libpod")
+	switch {
+	case !ok || f.compatOnly:
+		w.WriteHeader(http.StatusNotFound)
+	case p == "/_ping":
+		w.Header().Set(libpodVersionHeader, "4.9.3")
+		_, _ = w.Write([]byte("OK"))
+	case p == "/info":
+		var info libpodInfo
+		info.Host.Security.Rootless = f.rootless
+		info.Store.GraphRoot = filepath.Dir(f.socket)
+		ids := make(map[string]struct{})
+		f.lock.Lock()
+		for _, img := range f.images {
+			ids[img.inspect.ID] = struct{}{}
+		}
+		f.lock.Unlock()
+		info.Store.ImageStore.Number = len(ids)
+		_ = json.NewEncoder(w).Encode(info)
+	case p == "/images/pull" && r.Method == http.MethodPost:
+		ref := r.URL.Query().Get("reference")
+		f.lock.Lock()
+		f.pulls = append(f.pulls, ref)
+		img, ok := f.pullable[ref]
+		f.lock.Unlock()
+		if !ok {
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "manifest unknown"})
+			return
+		}
+		f.add(img)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"images": []string{img.inspect.ID}, "id": img.inspect.ID})
+	case strings.HasPrefix(p, "/images/") && strings.HasSuffix(p, "/json"):
+		img, ok := f.lookup(strings.TrimSuffix(strings.TrimPrefix(p, "/images/"), "/json"))
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(libpodError{Message: "image not known", Response: http.StatusNotFound})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(img.inspect)
+	case strings.HasPrefix(p, "/images/") && strings.HasSuffix(p, "/get"):
+		img, ok := f.lookup(strings.TrimSuffix(strings.TrimPrefix(p, "/images/"), "/get"))
+		if !ok || r.URL.Query().Get("format") != "docker-archive" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := tarball.Write(nil, img.img, w); err != nil {
+			panic(err)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newFakeLibpodImage(t *testing.T, tag, arch string) fakeLibpodImage {
+	t.Helper()
+
+	img, err := random.Image(64, 2)
+	require.NoError(t, err)
+
+	id, err := img.ConfigName()
+	require.NoError(t, err)
+
+	ref, err := name.NewTag(tag)
+	require.NoError(t, err)
+
+	repoDigest := ref.Context().Name() + "@sha256:" + strings.Repeat("d", 64)
+
+	return fakeLibpodImage{
+		inspect: libpodImage{
+			ID:           id.Hex,
+			RepoTags:     []string{ref.Name()},
+			RepoDigests:  []string{repoDigest},
+			Architecture: arch,
+			Os:           "linux",
+		},
+		img: img,
+		tag: tag,
+	}
+}
+
+func TestLibpodProvider_Provide(t *testing.T) {
+	app := newFakeLibpodImage(t, "localhost/app:v1", "amd64")
+	pulled := newFakeLibpodImage(t, "docker.io/library/alpine:latest", "amd64")
+
+	tests := []struct {
+		name      string
+		input     string
+		platform  string
+		readOnly  bool
+		offline   bool
+		streaming bool
+		wantErr   require.ErrorAssertionFunc
+		wantID    string
+		// wantPulls are the images pulled into the rootful store (the first store)
+		wantPulls []string
+		// wantRootlessPulls are the images pulled into the rootless store
+		wantRootlessPulls []string
+	}{
+		{
+			name:   "image within the rootless store",
+			input:  "localhost/app:v1",
+			wantID: app.inspect.ID,
+		},
+		{
+			name:   "image by digest",
+			input:  app.inspect.RepoDigests[0],
+			wantID: app.inspect.ID,
+		},
+		{
+			name:   "image by ID",
+			input:  app.inspect.ID,
+			wantID: app.inspect.ID,
+		},
+		{
+			name:      "streaming export",
+			input:     "localhost/app:v1",
+			streaming: true,
+			wantID:    app.inspect.ID,
+		},
+		{
+			name:      "missing image is pulled into the first store",
+			input:     "docker.io/library/alpine:latest",
+			wantID:    pulled.inspect.ID,
+			wantPulls: []string{"docker.io/library/alpine:latest"},
+		},
+		{
+			name:     "missing image is not pulled with a read-only daemon",
+			input:    "docker.io/library/alpine:latest",
+			readOnly: true,
+			wantErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.ErrorIs(t, err, image.ErrDaemonMutation)
+			},
+		},
+		{
+			name:    "missing image is not pulled in offline mode",
+			input:   "docker.io/library/alpine:latest",
+			offline: true,
+			wantErr: func(t require.TestingT, err error, _ ...interface{}) {
+				require.ErrorIs(t, err, image.ErrOffline)
+			},
+		},
+		{
+			name:      "missing image that cannot be pulled",
+			input:     "docker.io/library/missing:latest",
+			wantPulls: []string{"docker.io/library/missing:latest"},
+			wantErr:   require.Error,
+		},
+		{
+			name:              "platform mismatch",
+			input:             "localhost/app:v1",
+			platform:          "linux/arm64",
+			wantRootlessPulls: []string{"localhost/app:v1"},
+			wantErr:           require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.wantErr == nil {
+				test.wantErr = require.NoError
+			}
+
+			// note: unix socket paths are limited in length, thus the (typically long) test temp dir is not used
+			dir, err := os.MkdirTemp("", "libpod")
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+			rootful := newFakeLibpod(t, filepath.Join(dir, "rootful.sock"), false)
+			rootful.pullable[pulled.tag] = pulled
+			rootless := newFakeLibpod(t, filepath.Join(dir, "run", "podman", "podman.sock"), true, app)
+
+			ctx := image.ContextWithEnvOverrides(context.Background(), &image.EnvOverrides{
+				Vars:       map[string]string{"CONTAINER_HOST": "unix://" + rootful.socket},
+				Hermetic:   true,
+				HomeDir:    dir,
+				RuntimeDir: filepath.Join(dir, "run"),
+			})
+			if test.readOnly {
+				ctx = image.ContextWithReadOnlyDaemon(ctx)
+			}
+			if test.offline {
+				ctx = image.ContextWithOffline(ctx)
+			}
+			if test.streaming {
+				ctx = image.ContextWithStreamingExport(ctx)
+			}
+
+			var platform *image.Platform
+			if test.platform != "" {
+				platform, err = image.NewPlatform(test.platform)
+				require.NoError(t, err)
+			}
+
+			tmpDirGen := file.NewTempDirGenerator("libpod")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			img, err := NewLibpodProvider(tmpDirGen, test.input, platform).Provide(ctx)
+			assert.Equal(t, test.wantPulls, rootful.pulls)
+			assert.Equal(t, test.wantRootlessPulls, rootless.pulls)
+			test.wantErr(t, err)
+			if err != nil {
+				return
+			}
+
+			assert.Equal(t, "sha256:"+test.wantID, img.Metadata.ID)
+			assert.Len(t, img.Layers, 2)
+			assert.NotEmpty(t, img.Metadata.Tags)
+		})
+	}
+}
+
+func TestStores(t *testing.T) {
+	dir, err := os.MkdirTemp("", "libpod")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	rootless := newFakeLibpod(t, filepath.Join(dir, "run", "podman", "podman.sock"), true, newFakeLibpodImage(t, "localhost/app:v1", "amd64"))
+
+	containersConf := filepath.Join(dir, ".config", "containers", "containers.conf")
+	require.NoError(t, os.MkdirAll(filepath.Dir(containersConf), 0700))
+	require.NoError(t, os.WriteFile(containersConf, []byte(`
+[engine]
+active_service = "unreachable"
+
+[engine.service_destinations.unreachable]
+uri = "unix://`+filepath.Join(dir, "missing.sock")+`"
+`), 0600))
+
+	ctx := image.ContextWithEnvOverrides(context.Background(), &image.EnvOverrides{
+		Hermetic:   true,
+		HomeDir:    dir,
+		RuntimeDir: filepath.Join(dir, "run"),
+	})
+
+	stores, err := Stores(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Store{
+		{
+			URI:       "unix://" + rootless.socket,
+			GraphRoot: filepath.Dir(rootless.socket),
+			Rootless:  true,
+			Images:    1,
+		},
+	}, stores)
+}
@@ -0,0 +1,178 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+)
+
+// PrefetchSummary describes the files fetched while prefetching.
+type PrefetchSummary struct {
+	// Files is the number of regular files that were fetched
+	Files int
+	// Bytes is the total size of the files that were fetched
+	Bytes int64
+}
+
+// Prefetch is a hint that the files matching the given glob patterns within the squashed tree (e.g.
+// "/usr/lib/python3*/**") will be accessed soon, such that their contents are fetched ahead of demand:
+//   - files within lazy layers (see LazyPrefetcher, e.g. eStargz layers) are fetched with range requests for the
+//     regions of the layer blob holding them, and cached on disk by the layer (within the image temp directory, not in
+//     memory) such that opening them later does not fetch them again (all files of a layer are requested together, and
+//     layers are prefetched concurrently).
+//   - all other files are read (with the given concurrency, in the same order used by FetchContents) such that the
+//     underlying cached layer tars are in the OS page cache and any compressed layer contents (e.g. squashfs layers)
+//     have been decompressed once. Note: the file contents themselves are not retained.
+//
+// Prefetching is best-effort: every file that can be fetched is fetched, and all failures are returned together.
+// Callers that do not want to wait may call this from a separate goroutine and cancel the context once the files are no
+// longer needed.
+func (i *Image) Prefetch(ctx context.Context, patterns ...string) (PrefetchSummary, error) {
+	if i.FileCatalog == nil {
+		return PrefetchSummary{}, fmt.Errorf("image has not been read")
+	}
+	return Prefetch(ctx, i.SquashedTree(), i.FileCatalog, 0, patterns...)
+}
+
+// Prefetch fetches all regular files within the tree that match any of the given glob patterns (see Image.Prefetch),
+// reading files outside of lazy layers with the given concurrency (DefaultFetchConcurrency when not positive).
+func Prefetch(ctx context.Context, tree filetree.Reader, catalog FileCatalogReader, concurrency int, patterns ...string) (PrefetchSummary, error) {
+	refs, err := prefetchReferences(tree, catalog, patterns...)
+	if err != nil {
+		return PrefetchSummary{}, err
+	}
+
+	var (
+		lock    sync.Mutex
+		summary PrefetchSummary
+		errs    error
+	)
+
+	lazyRefs, refs := partitionLazyReferences(catalog, refs)
+
+	var wg sync.WaitGroup
+	for lazy, lrefs := range lazyRefs {
+		wg.Add(1)
+		go func(lazy LazyPrefetcher, lrefs []file.Reference) {
+			defer wg.Done()
+			n, err := prefetchLazy(ctx, catalog, lazy, lrefs)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				errs = multierror.Append(errs, err)
+				return
+			}
+			summary.Files += len(lrefs)
+			summary.Bytes += n
+		}(lazy, lrefs)
+	}
+
+	forEachInReadOrder(catalog, concurrency, refs, func(ref file.Reference) {
+		n, err := prefetch(ctx, catalog, ref)
+
+		lock.Lock()
+		defer lock.Unlock()
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("unable to prefetch %q: %w", ref.RealPath, err))
+			return
+		}
+		summary.Files++
+		summary.Bytes += n
+	})
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return summary, err
+	}
+	return summary, errs
+}
+
+// prefetchReferences returns all unique regular files matching any of the given glob patterns (following links).
+func prefetchReferences(tree filetree.Reader, catalog FileCatalogReader, patterns ...string) ([]file.Reference, error) {
+	seen := make(map[file.ID]struct{})
+	var refs []file.Reference
+	for _, pattern := range patterns {
+		resolutions, err := tree.FilesByGlob(pattern, filetree.FollowBasenameLinks)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve prefetch pattern %q: %w", pattern, err)
+		}
+
+		for _, res := range resolutions {
+			if !res.HasReference() {
+				continue
+			}
+			ref := *res.Reference
+			if _, ok := seen[ref.ID()]; ok {
+				continue
+			}
+			seen[ref.ID()] = struct{}{}
+
+			entry, err := catalog.Get(ref)
+			if err != nil || entry.Metadata.Type != file.TypeRegular {
+				continue
+			}
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
+
+// partitionLazyReferences groups the references within lazy layers that support prefetching by layer, returning the
+// remaining references separately.
+func partitionLazyReferences(catalog FileCatalogReader, refs []file.Reference) (map[LazyPrefetcher][]file.Reference, []file.Reference) {
+	lazyRefs := make(map[LazyPrefetcher][]file.Reference)
+	var rest []file.Reference
+	for _, ref := range refs {
+		if layer := catalog.Layer(ref); layer != nil {
+			if lazy := layer.lazyPrefetcher(); lazy != nil {
+				lazyRefs[lazy] = append(lazyRefs[lazy], ref)
+				continue
+			}
+		}
+		rest = append(rest, ref)
+	}
+	return lazyRefs, rest
+}
+
+// prefetchLazy fetches the given files from the lazy layer holding them, returning their total size.
+func prefetchLazy(ctx context.Context, catalog FileCatalogReader, lazy LazyPrefetcher, refs []file.Reference) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	var size int64
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		names = append(names, strings.TrimPrefix(string(ref.RealPath), "/"))
+		if entry, err := catalog.Get(ref); err == nil {
+			size += entry.Metadata.Size()
+		}
+	}
+
+	if err := lazy.Prefetch(ctx, names); err != nil {
+		return 0, fmt.Errorf("unable to prefetch %d files from lazy layer: %w", len(names), err)
+	}
+	return size, nil
+}
+
+func prefetch(ctx context.Context, catalog FileCatalogReader, ref file.Reference) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	reader, err := catalog.Open(ref)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	return io.Copy(io.Discard, reader)
+}
@@ -0,0 +1,72 @@
+package image
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_Prefetch(t *testing.T) {
+	v1Img, err := mutate.AppendLayers(empty.Image,
+		tarLayer(t, map[string]string{
+			"usr/lib/python3/os.py":      "import sys\n",
+			"usr/lib/python3/json.py":    "import re\n",
+			"usr/lib/ruby/gems.rb":       "require 'x'\n",
+			"usr/lib/python3/collect.py": "old",
+		}),
+		tarLayer(t, map[string]string{
+			"usr/lib/python3/collect.py": "new",
+		}),
+	)
+	require.NoError(t, err)
+
+	img := New(v1Img, nil, t.TempDir())
+
+	t.Run("image not read", func(t *testing.T) {
+		_, err := img.Prefetch(context.Background(), "/usr/lib/python3/**")
+		require.Error(t, err)
+	})
+
+	require.NoError(t, img.Read())
+
+	tests := []struct {
+		name     string
+		patterns []string
+		want     PrefetchSummary
+	}{
+		{
+			name:     "directory",
+			patterns: []string{"/usr/lib/python3/**"},
+			want:     PrefetchSummary{Files: 3, Bytes: int64(len("import sys\n") + len("import re\n") + len("new"))},
+		},
+		{
+			name:     "overlapping patterns",
+			patterns: []string{"/usr/lib/python3/**", "**/*.py", "/usr/lib/ruby/gems.rb"},
+			want:     PrefetchSummary{Files: 4, Bytes: int64(len("import sys\n") + len("import re\n") + len("new") + len("require 'x'\n"))},
+		},
+		{
+			name:     "no matches",
+			patterns: []string{"/opt/**"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := img.Prefetch(context.Background(), tt.patterns...)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		got, err := img.Prefetch(ctx, "/usr/lib/python3/**")
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Zero(t, got.Files)
+	})
+}
@@ -0,0 +1,81 @@
+package image
+
+import (
+	"io/fs"
+	"sort"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+// PrivilegedFile is a regular file that grants elevated privileges to processes executing it: a setuid or setgid file,
+// or a file with file capabilities.
+type PrivilegedFile struct {
+	file.Reference
+	// Layer is the layer containing the file
+	Layer *Layer
+	// Setuid indicates the file runs as the owning user
+	Setuid bool
+	// Setgid indicates the file runs as the owning group
+	Setgid bool
+	// Capabilities are the file capabilities of the file (nil when none are recorded)
+	Capabilities *file.Capabilities
+}
+
+// PrivilegedFiles returns all setuid, setgid, and capability-bearing regular files within all layers of the image (in
+// layer and path order), as found while layers were read. Note: this includes files that are overwritten or deleted by
+// higher layers (see PrivilegedFilesFromSquash).
+func (i *Image) PrivilegedFiles() []PrivilegedFile {
+	return append([]PrivilegedFile(nil), i.privilegedFiles...)
+}
+
+// PrivilegedFilesFromSquash returns the setuid, setgid, and capability-bearing regular files within the squashed tree
+// of the image (in layer and path order).
+func (i *Image) PrivilegedFilesFromSquash() []PrivilegedFile {
+	tree := i.SquashedTree()
+	if tree == nil {
+		return nil
+	}
+
+	var files []PrivilegedFile
+	for _, f := range i.privilegedFiles {
+		_, res, err := tree.File(f.RealPath)
+		if err != nil || res == nil || res.Reference == nil || res.Reference.ID() != f.ID() {
+			continue
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// layerPrivilegedFiles returns the setuid, setgid, and capability-bearing regular files within the tree of the given
+// (read) layer, in path order.
+func layerPrivilegedFiles(layer *Layer, catalog FileCatalogReader) []PrivilegedFile {
+	if layer.Tree == nil {
+		return nil
+	}
+
+	refs := layer.Tree.AllFiles(file.TypeRegular)
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].RealPath < refs[j].RealPath
+	})
+
+	var files []PrivilegedFile
+	for _, ref := range refs {
+		entry, err := catalog.Get(ref)
+		if err != nil || entry.FileInfo == nil {
+			continue
+		}
+		mode := entry.Mode()
+		f := PrivilegedFile{
+			Reference:    ref,
+			Layer:        layer,
+			Setuid:       mode&fs.ModeSetuid != 0,
+			Setgid:       mode&fs.ModeSetgid != 0,
+			Capabilities: entry.Capabilities,
+		}
+		if f.Setuid || f.Setgid || f.Capabilities != nil {
+			files = append(files, f)
+		}
+	}
+	return files
+}
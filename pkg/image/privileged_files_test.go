@@ -0,0 +1,102 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func TestImage_PrivilegedFiles(t *testing.T) {
+	// revision 2 capabilities with cap_net_raw permitted and effective
+	netRaw := binary.LittleEndian.AppendUint32(nil, 0x02000001)
+	netRaw = append(netRaw, 0x00, 0x20, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+
+	first := privilegedTestLayer(t, []*tar.Header{
+		{Name: "bin/su", Mode: 0755 | 04000},
+		{Name: "usr/bin/wall", Mode: 0755 | 02000},
+		{Name: "usr/bin/ping", Mode: 0755, PAXRecords: map[string]string{"SCHILY.xattr.security.capability": string(netRaw)}},
+		{Name: "usr/bin/env", Mode: 0755},
+	})
+	second := privilegedTestLayer(t, []*tar.Header{
+		// the setuid bit is removed in the upper layer
+		{Name: "bin/su", Mode: 0755},
+		{Name: "usr/bin/sudo", Mode: 0755 | 04000 | 02000},
+	})
+
+	v1Img, err := mutate.AppendLayers(empty.Image, first, second)
+	require.NoError(t, err)
+
+	img := New(v1Img, nil, t.TempDir())
+	require.NoError(t, img.Read())
+
+	type summary struct {
+		Path         string
+		Layer        uint
+		Setuid       bool
+		Setgid       bool
+		Capabilities []string
+	}
+	summarize := func(files []PrivilegedFile) []summary {
+		var out []summary
+		for _, f := range files {
+			s := summary{Path: string(f.RealPath), Layer: f.Layer.Metadata.Index, Setuid: f.Setuid, Setgid: f.Setgid}
+			if f.Capabilities != nil {
+				s.Capabilities = f.Capabilities.PermittedNames()
+			}
+			out = append(out, s)
+		}
+		return out
+	}
+
+	assert.Equal(t, []summary{
+		{Path: "/bin/su", Layer: 0, Setuid: true},
+		{Path: "/usr/bin/ping", Layer: 0, Capabilities: []string{"cap_net_raw"}},
+		{Path: "/usr/bin/wall", Layer: 0, Setgid: true},
+		{Path: "/usr/bin/sudo", Layer: 1, Setuid: true, Setgid: true},
+	}, summarize(img.PrivilegedFiles()))
+
+	assert.Equal(t, []summary{
+		{Path: "/usr/bin/ping", Layer: 0, Capabilities: []string{"cap_net_raw"}},
+		{Path: "/usr/bin/wall", Layer: 0, Setgid: true},
+		{Path: "/usr/bin/sudo", Layer: 1, Setuid: true, Setgid: true},
+	}, summarize(img.PrivilegedFilesFromSquash()))
+
+	_, res, err := img.SquashedTree().File(file.Path("/usr/bin/ping"))
+	require.NoError(t, err)
+	require.NotNil(t, res)
+	entry, err := img.FileCatalog.Get(*res.Reference)
+	require.NoError(t, err)
+	require.NotNil(t, entry.Capabilities)
+	assert.True(t, entry.Capabilities.Effective)
+}
+
+// privilegedTestLayer creates a layer with an empty regular file for each of the given headers.
+func privilegedTestLayer(t *testing.T, headers []*tar.Header) v1.Layer {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, hdr := range headers {
+		hdr.Typeflag = tar.TypeReg
+		hdr.Format = tar.FormatPAX
+		require.NoError(t, tw.WriteHeader(hdr))
+	}
+	require.NoError(t, tw.Close())
+
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	})
+	require.NoError(t, err)
+	return layer
+}
@@ -2,6 +2,10 @@ package image
 
 import (
 	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/anchore/stereoscope/internal/log"
 )
 
 // Provider is an abstraction for any object that provides image objects (e.g. the docker daemon API, a tar file of
@@ -10,3 +14,47 @@ type Provider interface {
 	Name() string
 	Provide(context.Context) (*Image, error)
 }
+
+// ProviderPanicError is returned when a provider panics (e.g. on malformed input), such that a single misbehaving
+// provider does not crash the embedding process.
+type ProviderPanicError struct {
+	// Provider is the name of the provider that panicked
+	Provider string
+	// Value is the value passed to panic
+	Value any
+	// Stack is the stack trace of the goroutine at the time of the panic
+	Stack []byte
+}
+
+func (e *ProviderPanicError) Error() string {
+	return fmt.Sprintf("%s provider panicked: %v", e.Provider, e.Value)
+}
+
+// Unwrap returns the value passed to panic when it is an error.
+func (e *ProviderPanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// Provide calls the given provider, returning a ProviderPanicError (instead of panicking) when the provider panics.
+// Errors of common causes are marked with the kind of failure (e.g. ErrWrongArchiveFormat) when the provider did not
+// mark the error itself (see MarkError).
+func Provide(ctx context.Context, provider Provider) (img *Image, err error) {
+	defer recoverProviderPanic(provider.Name(), &err)
+	img, err = provider.Provide(ctx)
+	return img, classifyError(err)
+}
+
+// recoverProviderPanic recovers from a panic of the named provider, setting the given error to a ProviderPanicError.
+// This must be deferred directly by the function calling the provider.
+func recoverProviderPanic(name string, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	panicErr := &ProviderPanicError{Provider: name, Value: r, Stack: debug.Stack()}
+	log.WithFields("provider", name, "panic", r, "stack", string(panicErr.Stack)).Error("recovered from provider panic")
+	*err = panicErr
+}
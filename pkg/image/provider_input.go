@@ -0,0 +1,31 @@
+package image
+
+// ProviderInput is the normalized input that an image was provided from, which is sufficient to provide the exact same
+// image again at a later time (e.g. to reproduce analysis results).
+type ProviderInput struct {
+	// Source is the name of the provider that provided the image (e.g. "docker-archive")
+	Source Source `json:"source"`
+	// UserInput is the input as originally given by the user
+	UserInput string `json:"userInput"`
+	// Reference is the resolved input to provide the image from again: an absolute path for file and directory based
+	// sources, otherwise the most specific known reference (a repo digest when available)
+	Reference string `json:"reference"`
+	// Digest is the manifest digest of the provided image (when known)
+	Digest string `json:"digest,omitempty"`
+	// ID is the image ID (the digest of the image config), used to verify that the same image is provided again
+	ID string `json:"id"`
+	// Platform is the platform of the provided image (e.g. "linux/arm64/v8") when known, otherwise the platform
+	// requested when providing the image (if any)
+	Platform string `json:"platform,omitempty"`
+	// ImageSelector is the image selector the image was provided with (see stereoscope.WithImageSelector), needed to
+	// select the same image again from sources holding more than one image
+	ImageSelector string `json:"imageSelector,omitempty"`
+}
+
+// WithProviderInput records the normalized input that the image was provided from.
+func WithProviderInput(input ProviderInput) AdditionalMetadata {
+	return func(image *Image) error {
+		image.Metadata.ProviderInput = &input
+		return nil
+	}
+}
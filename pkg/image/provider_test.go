@@ -0,0 +1,67 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type panickingProvider struct {
+	value any
+}
+
+func (p panickingProvider) Name() string {
+	return "panicking"
+}
+
+func (p panickingProvider) Provide(context.Context) (*Image, error) {
+	panic(p.value)
+}
+
+func (p panickingProvider) ProvideIndex(context.Context) (*Index, error) {
+	panic(p.value)
+}
+
+func TestProvide_recoversPanics(t *testing.T) {
+	cause := errors.New("index out of range")
+
+	tests := []struct {
+		name    string
+		value   any
+		wantErr string
+	}{
+		{name: "string", value: "bad input", wantErr: "panicking provider panicked: bad input"},
+		{name: "error", value: cause, wantErr: "panicking provider panicked: index out of range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := panickingProvider{value: tt.value}
+
+			img, err := Provide(context.Background(), provider)
+			assert.Nil(t, img)
+			require.EqualError(t, err, tt.wantErr)
+
+			var panicErr *ProviderPanicError
+			require.ErrorAs(t, err, &panicErr)
+			assert.Equal(t, "panicking", panicErr.Provider)
+			assert.Equal(t, tt.value, panicErr.Value)
+			assert.Contains(t, string(panicErr.Stack), "panickingProvider.Provide")
+			if cause, ok := tt.value.(error); ok {
+				assert.ErrorIs(t, err, cause)
+			}
+
+			index, err := ProvideIndex(context.Background(), provider)
+			assert.Nil(t, index)
+			require.ErrorAs(t, err, &panicErr)
+			assert.Contains(t, string(panicErr.Stack), "panickingProvider.ProvideIndex")
+
+			// panics of index entries are recovered as well
+			_, err = NewIndexEntry(v1.Descriptor{}, Platform{}, provider).Provide(context.Background())
+			require.ErrorAs(t, err, &panicErr)
+		})
+	}
+}
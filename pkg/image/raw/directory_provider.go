@@ -0,0 +1,337 @@
+package raw
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+const Directory image.Source = image.RootfsDirectorySource
+
+// ociLayoutFile marks OCI layout directories, which are provided by the oci-dir source instead
+const ociLayoutFile = "oci-layout"
+
+// NewDirectoryProvider creates a new provider for directories holding a root filesystem (e.g. an unpacked chroot, a
+// mounted volume, or the merged overlay directory of a container).
+func NewDirectoryProvider(tmpDirGen *file.TempDirGenerator, path string) image.Provider {
+	return &directoryImageProvider{
+		tmpDirGen: tmpDirGen,
+		path:      path,
+	}
+}
+
+// directoryImageProvider is an image.Provider for root filesystem directories, represented as a single layer image with
+// minimal metadata.
+type directoryImageProvider struct {
+	tmpDirGen *file.TempDirGenerator
+	path      string
+}
+
+func (p *directoryImageProvider) Name() string {
+	return Directory
+}
+
+// Provide an image object that represents the filesystem within the directory at the configured path.
+func (p *directoryImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	// the directory itself may be a symlink (e.g. /var/lib/docker on a relocated volume), but no link within it is followed
+	root, err := filepath.EvalSymlinks(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve rootfs directory: %w", err)
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("unable to stat rootfs directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("not a rootfs directory: %q", p.path)
+	}
+	if _, err := os.Stat(filepath.Join(root, ociLayoutFile)); err == nil {
+		return nil, fmt.Errorf("not a rootfs directory (found %s of an OCI layout)", ociLayoutFile)
+	}
+
+	fsys := rootfsFS{root: root}
+	fsys.dev, fsys.hasDev = device(info)
+
+	img, err := mutate.ConfigFile(empty.Image, &v1.ConfigFile{OS: "linux"})
+	if err != nil {
+		return nil, fmt.Errorf("unable to set image config: %w", err)
+	}
+	img, err = mutate.AppendLayers(img, &directoryLayer{fsys: fsys})
+	if err != nil {
+		return nil, fmt.Errorf("unable to append layer: %w", err)
+	}
+
+	contentTempDir, err := p.tmpDirGen.NewDirectory("rootfs-dir-image")
+	if err != nil {
+		return nil, err
+	}
+
+	out := image.New(img, p.tmpDirGen, contentTempDir, image.WithOS("linux"))
+	if err := out.ReadContext(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// rootfsFS is the filesystem within a root filesystem directory, where symlinks are reported (never followed) and the
+// contents of pseudo filesystems (/proc and /sys) and of other filesystems mounted within the directory are omitted.
+type rootfsFS struct {
+	root string
+	// dev is the device holding the root directory (when known), used to detect mount points
+	dev    uint64
+	hasDev bool
+}
+
+var _ image.ReadLinkFS = rootfsFS{}
+
+func (f rootfsFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(f.root, filepath.FromSlash(name)), nil
+}
+
+func (f rootfsFS) Open(name string) (fs.File, error) {
+	p, err := f.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (f rootfsFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := f.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if f.omitted(name, p) {
+		return nil, nil
+	}
+	return os.ReadDir(p)
+}
+
+func (f rootfsFS) ReadLink(name string) (string, error) {
+	p, err := f.path("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	return os.Readlink(p)
+}
+
+// omitted indicates the contents of the given directory are not part of the root filesystem (the directory itself is
+// kept, as it would be within an image).
+func (f rootfsFS) omitted(name, p string) bool {
+	if name == "proc" || name == "sys" {
+		return true
+	}
+	if name == "." || !f.hasDev {
+		return false
+	}
+	info, err := os.Lstat(p)
+	if err != nil {
+		return false
+	}
+	if dev, ok := device(info); ok && dev != f.dev {
+		log.WithFields("path", "/"+name).Debug("skipping mount point within rootfs directory")
+		return true
+	}
+	return false
+}
+
+// directoryLayer is an image.FSLayer for a root filesystem directory, which is read in place. Since file contents are
+// not read to build the layer, the digest of the layer identifies the listing of the directory (the path, mode, size,
+// modification time, and link target of every entry) rather than the layer tar, which is only written when the layer
+// contents are requested (e.g. when the image is saved).
+type directoryLayer struct {
+	fsys rootfsFS
+
+	once   sync.Once
+	digest v1.Hash
+	size   int64
+	err    error
+}
+
+var _ image.FSLayer = (*directoryLayer)(nil)
+
+func (l *directoryLayer) FS() fs.FS {
+	return l.fsys
+}
+
+func (l *directoryLayer) Digest() (v1.Hash, error) {
+	l.once.Do(l.identify)
+	return l.digest, l.err
+}
+
+func (l *directoryLayer) DiffID() (v1.Hash, error) {
+	return l.Digest()
+}
+
+// Size returns the total size of all regular files within the directory (the size of the layer tar is not known until
+// it is written).
+func (l *directoryLayer) Size() (int64, error) {
+	l.once.Do(l.identify)
+	return l.size, l.err
+}
+
+func (l *directoryLayer) MediaType() (types.MediaType, error) {
+	return types.DockerUncompressedLayer, nil
+}
+
+func (l *directoryLayer) Compressed() (io.ReadCloser, error) {
+	return l.Uncompressed()
+}
+
+func (l *directoryLayer) Uncompressed() (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeDirectoryTar(l.fsys, pw))
+	}()
+	return pr, nil
+}
+
+// identify computes the digest and size of the directory listing.
+func (l *directoryLayer) identify() {
+	h := sha256.New()
+	l.err = fs.WalkDir(l.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == "." {
+				return err
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			link, _ = l.fsys.ReadLink(p)
+		}
+		if info.Mode().IsRegular() {
+			l.size += info.Size()
+		}
+		_, err = fmt.Fprintf(h, "%s\x00%o\x00%d\x00%d\x00%s\n", p, info.Mode(), info.Size(), info.ModTime().UnixNano(), link)
+		return err
+	})
+	if l.err != nil {
+		l.err = fmt.Errorf("unable to read rootfs directory: %w", l.err)
+		return
+	}
+	l.digest = v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(h.Sum(nil))}
+}
+
+// writeDirectoryTar writes all entries within the given root filesystem to a tar. Symlinks are written as-is (never
+// followed), files linked more than once within the directory are written as hardlinks to the first path found, and
+// entries that cannot be read (e.g. due to permissions) or that cannot be represented within a tar (sockets) are
+// skipped.
+func writeDirectoryTar(fsys rootfsFS, out io.Writer) error {
+	tw := tar.NewWriter(out)
+	w := directoryWriter{fsys: fsys, tw: tw, links: make(map[inode]string)}
+
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == "." {
+				return err
+			}
+			log.WithFields("path", p, "error", err).Warn("unable to read rootfs directory entry, skipping")
+			return nil
+		}
+		if p == "." {
+			return nil
+		}
+		return w.write(p, d)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to read rootfs directory: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("unable to write rootfs layer: %w", err)
+	}
+	return nil
+}
+
+// directoryWriter writes the entries of a root filesystem directory to a tar.
+type directoryWriter struct {
+	fsys rootfsFS
+	tw   *tar.Writer
+	// links are the tar paths of files found so far that are linked more than once, by inode
+	links map[inode]string
+}
+
+func (w directoryWriter) write(name string, d fs.DirEntry) error {
+	info, err := d.Info()
+	if err != nil {
+		log.WithFields("path", name, "error", err).Warn("unable to stat rootfs directory entry, skipping")
+		return nil
+	}
+
+	var link string
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if link, err = w.fsys.ReadLink(name); err != nil {
+			log.WithFields("path", name, "error", err).Warn("unable to read rootfs symlink, skipping")
+			return nil
+		}
+	}
+
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		// e.g. sockets, which cannot be represented within a tar
+		log.WithFields("path", name, "error", err).Trace("unsupported rootfs directory entry, skipping")
+		return nil
+	}
+	hdr.Name = name
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+
+	if !info.Mode().IsRegular() {
+		return w.tw.WriteHeader(hdr)
+	}
+
+	if key, ok := hardlinkInode(info); ok {
+		if target, seen := w.links[key]; seen {
+			hdr.Typeflag = tar.TypeLink
+			hdr.Linkname = target
+			hdr.Size = 0
+			return w.tw.WriteHeader(hdr)
+		}
+		w.links[key] = name
+	}
+
+	fh, err := w.fsys.Open(name)
+	if err != nil {
+		log.WithFields("path", name, "error", err).Warn("unable to open rootfs file, skipping")
+		return nil
+	}
+	defer fh.Close()
+
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(w.tw, fh, hdr.Size); err != nil {
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("file %q changed while being read", name)
+		}
+		return err
+	}
+	return nil
+}
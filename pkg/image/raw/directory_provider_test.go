@@ -0,0 +1,146 @@
+package raw
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+)
+
+func TestDirectoryProvider_Provide(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "etc"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "usr", "bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "etc", "os-release"), []byte("ID=alpine\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "usr", "bin", "busybox"), []byte("binary"), 0755))
+	require.NoError(t, os.Link(filepath.Join(root, "usr", "bin", "busybox"), filepath.Join(root, "usr", "bin", "sh")))
+	require.NoError(t, os.Symlink("/usr/bin", filepath.Join(root, "bin")))
+	require.NoError(t, os.Symlink("../etc/os-release", filepath.Join(root, "usr", "os-release")))
+	// absolute links resolve within the image, never to the host
+	require.NoError(t, os.Symlink("/etc/shadow", filepath.Join(root, "etc", "shadow")))
+
+	// the directory may be given as a symlink
+	link := filepath.Join(t.TempDir(), "rootfs")
+	require.NoError(t, os.Symlink(root, link))
+
+	tmpDirGen := file.NewTempDirGenerator("tempDir")
+	t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+	img, err := NewDirectoryProvider(tmpDirGen, link).Provide(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, "linux", img.Metadata.OS)
+	require.Len(t, img.Layers, 1)
+
+	readFile := func(p string) string {
+		reader, err := img.OpenPathFromSquash(file.Path(p))
+		require.NoError(t, err)
+		defer reader.Close()
+		contents, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		return string(contents)
+	}
+	assert.Equal(t, "ID=alpine\n", readFile("/etc/os-release"))
+	assert.Equal(t, "ID=alpine\n", readFile("/usr/os-release"))
+	assert.Equal(t, "binary", readFile("/bin/busybox"))
+	assert.Equal(t, "binary", readFile("/usr/bin/sh"))
+
+	entryType := func(p string) file.Type {
+		_, res, err := img.SquashedTree().File(file.Path(p), filetree.DoNotFollowDeadBasenameLinks)
+		require.NoError(t, err)
+		require.NotNil(t, res, p)
+		entry, err := img.FileCatalog.Get(*res.Reference)
+		require.NoError(t, err)
+		return entry.Type
+	}
+	assert.Equal(t, file.TypeDirectory, entryType("/etc"))
+	assert.Equal(t, file.TypeSymLink, entryType("/bin"))
+	assert.Equal(t, file.TypeSymLink, entryType("/etc/shadow"))
+	// multiply linked files are read in place, thus every path is the file itself
+	assert.Equal(t, file.TypeRegular, entryType("/usr/bin/busybox"))
+	assert.Equal(t, file.TypeRegular, entryType("/usr/bin/sh"))
+
+	// the layer tar is only written when the layer contents are requested, where the first path of a multiply linked
+	// file is the file itself and all others are hardlinks to it
+	types := make(map[string]byte)
+	require.NoError(t, img.Layers[0].IterateTar(func(entry file.TarFileEntry) error {
+		types[entry.Header.Name] = entry.Header.Typeflag
+		return nil
+	}))
+	assert.Equal(t, byte(tar.TypeReg), types["usr/bin/busybox"])
+	assert.Equal(t, byte(tar.TypeLink), types["usr/bin/sh"])
+	assert.Equal(t, byte(tar.TypeSymlink), types["bin"])
+}
+
+func TestDirectoryProvider_PseudoFilesystems(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"proc/1", "sys/kernel", "etc"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, "proc", "1", "environ"), []byte("SECRET=1"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "sys", "kernel", "version"), []byte("6.1"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "etc", "os-release"), []byte("ID=alpine\n"), 0644))
+
+	tmpDirGen := file.NewTempDirGenerator("tempDir")
+	t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+	img, err := NewDirectoryProvider(tmpDirGen, root).Provide(context.Background())
+	require.NoError(t, err)
+
+	tree := img.SquashedTree()
+	assert.True(t, tree.HasPath("/etc/os-release"))
+	// the mount point directories are kept, but never their contents
+	assert.True(t, tree.HasPath("/proc"))
+	assert.True(t, tree.HasPath("/sys"))
+	assert.False(t, tree.HasPath("/proc/1"))
+	assert.False(t, tree.HasPath("/sys/kernel"))
+}
+
+func TestDirectoryProvider_NotRootfs(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    func(t *testing.T) string
+		wantErr string
+	}{
+		{
+			name: "OCI layout",
+			path: func(t *testing.T) string {
+				root := t.TempDir()
+				require.NoError(t, os.WriteFile(filepath.Join(root, "oci-layout"), []byte("{}"), 0644))
+				return root
+			},
+			wantErr: "not a rootfs directory",
+		},
+		{
+			name: "file",
+			path: func(t *testing.T) string {
+				return writeTarball(t, map[string]string{"etc/os-release": "ID=alpine\n"})
+			},
+			wantErr: "not a rootfs directory",
+		},
+		{
+			name: "missing",
+			path: func(t *testing.T) string {
+				return filepath.Join(t.TempDir(), "missing")
+			},
+			wantErr: "unable to resolve rootfs directory",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDirGen := file.NewTempDirGenerator("tempDir")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			_, err := NewDirectoryProvider(tmpDirGen, tt.path(t)).Provide(context.Background())
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+
+package raw
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// inode identifies a file within the filesystems of a directory.
+type inode struct {
+	dev uint64
+	ino uint64
+}
+
+// hardlinkInode returns the inode of the given file when the file has more than one link.
+func hardlinkInode(info fs.FileInfo) (inode, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Nlink <= 1 {
+		return inode{}, false
+	}
+	return inode{dev: uint64(stat.Dev), ino: stat.Ino}, true //nolint:unconvert // the type of Dev varies by platform
+}
+
+// device returns the device holding the given file.
+func device(info fs.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Dev), true //nolint:unconvert // the type of Dev varies by platform
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package raw
+
+import (
+	"io/fs"
+)
+
+// inode identifies a file within the filesystems of a directory.
+type inode struct {
+	dev uint64
+	ino uint64
+}
+
+// hardlinkInode is a placeholder for windows, where hardlinks are written as regular files.
+func hardlinkInode(fs.FileInfo) (inode, bool) {
+	return inode{}, false
+}
+
+// device is a placeholder for windows, where mount points within the directory are not detected.
+func device(fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,171 @@
+package raw
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+const Tarball image.Source = image.RawTarballSource
+
+// files at the root of docker and OCI archives, which are provided by the docker-archive and oci-archive sources
+// instead (as a raw tarball these would be a filesystem of image blobs rather than the image filesystem)
+var archiveMarkers = []string{"manifest.json", "oci-layout"}
+
+// NewTarballProvider creates a new provider for raw rootfs tarballs: a plain (possibly compressed) tar of a filesystem
+// that is not in the docker or OCI image format (e.g. from "docker export" or a distribution rootfs download).
+func NewTarballProvider(tmpDirGen *file.TempDirGenerator, path string) image.Provider {
+	return &tarballImageProvider{
+		tmpDirGen: tmpDirGen,
+		path:      path,
+	}
+}
+
+// tarballImageProvider is an image.Provider for raw rootfs tarballs, represented as a single layer image with minimal
+// metadata.
+type tarballImageProvider struct {
+	tmpDirGen *file.TempDirGenerator
+	path      string
+}
+
+func (p *tarballImageProvider) Name() string {
+	return Tarball
+}
+
+// Provide an image object that represents the filesystem within the tarball at the configured path.
+func (p *tarballImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	// note: "docker save" writes the archive markers after all image blobs, thus these are checked before copying
+	if err := sniffArchiveMarkers(p.path); err != nil {
+		return nil, err
+	}
+
+	tempDir, err := p.tmpDirGen.NewDirectory("raw-tarball")
+	if err != nil {
+		return nil, err
+	}
+
+	layerPath := filepath.Join(tempDir, "rootfs.tar")
+	if err := writeLayer(p.path, layerPath); err != nil {
+		return nil, err
+	}
+
+	img, err := image.NewSingleLayerImage(layerPath, v1.ConfigFile{OS: "linux"})
+	if err != nil {
+		return nil, err
+	}
+
+	contentTempDir, err := p.tmpDirGen.NewDirectory("raw-tarball-image")
+	if err != nil {
+		return nil, err
+	}
+
+	out := image.New(img, p.tmpDirGen, contentTempDir, image.WithOS("linux"))
+	if err := out.ReadContext(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// sniffArchiveMarkers fails when the tarball at the given path holds the root files of a docker or OCI archive. Only
+// the entry headers are read: the contents of uncompressed tarballs are seeked over, and the contents of compressed
+// tarballs are decompressed without being copied anywhere.
+func sniffArchiveMarkers(tarballPath string) error {
+	fh, err := os.Open(tarballPath)
+	if err != nil {
+		return fmt.Errorf("unable to open raw tarball: %w", err)
+	}
+	defer fh.Close()
+
+	compression, _, err := file.DetectCompression(fh)
+	if err != nil {
+		return fmt.Errorf("unable to read raw tarball: %w", err)
+	}
+	if _, err := fh.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("unable to read raw tarball: %w", err)
+	}
+
+	var reader io.Reader = fh
+	if compression != file.Uncompressed {
+		decompressed, err := file.NewDecompressingReader(fh)
+		if err != nil {
+			return err
+		}
+		defer decompressed.Close()
+		reader = decompressed
+	}
+
+	// note: the tar reader skips entry contents by seeking when the reader is seekable
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read raw tarball: %w", err)
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(hdr.Name, "./"), "/"), "/")
+		for _, marker := range archiveMarkers {
+			if name == marker {
+				return fmt.Errorf("not a raw tarball (found %s of a docker or OCI archive)", marker)
+			}
+		}
+	}
+}
+
+// writeLayer writes all entries of the (possibly compressed) tarball to a new uncompressed tar at the given path.
+func writeLayer(tarballPath, layerPath string) error {
+	fh, err := os.Open(tarballPath)
+	if err != nil {
+		return fmt.Errorf("unable to open raw tarball: %w", err)
+	}
+	defer fh.Close()
+
+	reader, err := file.NewDecompressingReader(fh)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	out, err := os.Create(layerPath)
+	if err != nil {
+		return fmt.Errorf("unable to create rootfs layer: %w", err)
+	}
+	defer out.Close()
+
+	tw := tar.NewWriter(out)
+
+	var entries int
+	err = file.IterateTar(reader, func(entry file.TarFileEntry) error {
+		entries++
+		hdr := entry.Header
+		if err := tw.WriteHeader(&hdr); err != nil {
+			return err
+		}
+		_, err := io.Copy(tw, entry.Reader)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to read raw tarball: %w", err)
+	}
+
+	if entries == 0 {
+		return fmt.Errorf("not a raw tarball (no entries)")
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("unable to write rootfs layer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package raw
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func TestTarballProvider_Provide(t *testing.T) {
+	path := writeTarball(t, map[string]string{
+		"./etc/os-release": "ID=alpine\n",
+		"./bin/busybox":    "binary",
+	})
+
+	tmpDirGen := file.NewTempDirGenerator("tempDir")
+	t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+	img, err := NewTarballProvider(tmpDirGen, path).Provide(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, "linux", img.Metadata.OS)
+	require.Len(t, img.Layers, 1)
+
+	reader, err := img.OpenPathFromSquash("/etc/os-release")
+	require.NoError(t, err)
+	contents, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "ID=alpine\n", string(contents))
+	assert.True(t, img.SquashedTree().HasPath("/bin/busybox"))
+}
+
+func TestTarballProvider_NotRaw(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    func(t *testing.T) string
+		wantErr string
+	}{
+		{
+			name: "docker archive",
+			path: func(t *testing.T) string {
+				return writeTarball(t, map[string]string{"manifest.json": "[]", "abc/layer.tar": ""})
+			},
+			wantErr: "not a raw tarball",
+		},
+		{
+			name: "OCI archive",
+			path: func(t *testing.T) string {
+				return writeTarball(t, map[string]string{"./oci-layout": "{}", "./index.json": "{}"})
+			},
+			wantErr: "not a raw tarball",
+		},
+		{
+			name: "empty tarball",
+			path: func(t *testing.T) string {
+				return writeTarball(t, nil)
+			},
+			wantErr: "no entries",
+		},
+		{
+			name: "not a tar",
+			path: func(t *testing.T) string {
+				path := filepath.Join(t.TempDir(), "file.txt")
+				require.NoError(t, os.WriteFile(path, []byte("not a tar, but long enough to fill the start of a tar header block"), 0600))
+				return path
+			},
+			wantErr: "unable to read raw tarball",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDirGen := file.NewTempDirGenerator("tempDir")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			_, err := NewTarballProvider(tmpDirGen, tt.path(t)).Provide(context.Background())
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func Test_sniffArchiveMarkers(t *testing.T) {
+	// "docker save" writes the image blobs first and the archive markers last
+	dockerSave := []tarEntry{
+		{name: "blobs/sha256/" + strings.Repeat("a", 64), contents: strings.Repeat("layer", 4096)},
+		{name: "index.json", contents: "{}"},
+		{name: "manifest.json", contents: "[]"},
+		{name: "oci-layout", contents: "{}"},
+	}
+	rootfs := []tarEntry{
+		{name: "./etc/os-release", contents: "ID=alpine\n"},
+		{name: "./bin/busybox", contents: strings.Repeat("binary", 4096)},
+	}
+
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		gzip    bool
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:    "docker save archive",
+			entries: dockerSave,
+			wantErr: require.Error,
+		},
+		{
+			name:    "compressed docker save archive",
+			entries: dockerSave,
+			gzip:    true,
+			wantErr: require.Error,
+		},
+		{
+			name:    "rootfs",
+			entries: rootfs,
+			wantErr: require.NoError,
+		},
+		{
+			name:    "compressed rootfs",
+			entries: rootfs,
+			gzip:    true,
+			wantErr: require.NoError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.wantErr(t, sniffArchiveMarkers(writeOrderedTarball(t, tt.entries, tt.gzip)))
+		})
+	}
+}
+
+type tarEntry struct {
+	name     string
+	contents string
+}
+
+// writeOrderedTarball writes a tarball holding the given entries in order.
+func writeOrderedTarball(t *testing.T, entries []tarEntry, compress bool) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "archive.tar")
+	fh, err := os.Create(path)
+	require.NoError(t, err)
+	defer fh.Close()
+
+	var w io.Writer = fh
+	if compress {
+		gw := gzip.NewWriter(fh)
+		defer func() { require.NoError(t, gw.Close()) }()
+		w = gw
+	}
+
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: e.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(e.contents))}))
+		_, err := tw.Write([]byte(e.contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return path
+}
+
+func writeTarball(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "rootfs.tar.gz")
+	fh, err := os.Create(path)
+	require.NoError(t, err)
+	defer fh.Close()
+
+	gw := gzip.NewWriter(fh)
+	tw := tar.NewWriter(gw)
+	for name, contents := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(contents)),
+		}))
+		_, err := tw.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+
+	return path
+}
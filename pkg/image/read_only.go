@@ -0,0 +1,34 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrDaemonMutation is returned when providing an image would require mutating a container runtime (e.g. pulling an
+// image into the docker or containerd image store) while in read-only mode (see ContextWithReadOnlyDaemon).
+var ErrDaemonMutation = errors.New("daemon mutation is not allowed in read-only mode")
+
+type readOnlyDaemonKey struct{}
+
+// ContextWithReadOnlyDaemon returns a context where providers must never mutate a container runtime (no pulls into the
+// docker, podman, or containerd image stores, no tag creation, no snapshot or content creation). Providers fail with
+// ErrDaemonMutation instead.
+func ContextWithReadOnlyDaemon(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyDaemonKey{}, true)
+}
+
+// IsReadOnlyDaemon indicates that container runtimes must not be mutated (see ContextWithReadOnlyDaemon).
+func IsReadOnlyDaemon(ctx context.Context) bool {
+	readOnly, _ := ctx.Value(readOnlyDaemonKey{}).(bool)
+	return readOnly
+}
+
+// CheckDaemonMutation returns ErrDaemonMutation (describing the attempted action) when the given context is read-only.
+func CheckDaemonMutation(ctx context.Context, action string) error {
+	if IsReadOnlyDaemon(ctx) {
+		return fmt.Errorf("unable to %s: %w", action, ErrDaemonMutation)
+	}
+	return nil
+}
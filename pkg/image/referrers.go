@@ -0,0 +1,147 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+const (
+	// DockerReferenceTypeAnnotation and DockerReferenceDigestAnnotation are used by buildkit to associate attestation
+	// manifests with an image within an index (instead of the OCI subject field).
+	DockerReferenceTypeAnnotation   = "vnd.docker.reference.type"
+	DockerReferenceDigestAnnotation = "vnd.docker.reference.digest"
+	DockerAttestationManifestType   = "attestation-manifest"
+
+	// attestationPlatform is the placeholder OS and architecture buildkit uses for attestation manifests within an index
+	attestationPlatform   = "unknown"
+	inTotoMediaTypePrefix = "application/vnd.in-toto"
+)
+
+// Referrer is an artifact (e.g. a signature, SBOM, or in-toto attestation) whose manifest references an image
+// manifest, either as the OCI subject or via buildkit attestation annotations.
+type Referrer struct {
+	// Descriptor describes the artifact manifest
+	Descriptor v1.Descriptor
+	artifact   v1.Image
+}
+
+// ReferrersResolver returns all artifacts that reference the image manifest with the given digest.
+type ReferrersResolver func(ctx context.Context, subject v1.Hash) ([]Referrer, error)
+
+// NewReferrer creates a Referrer for the artifact manifest described by the given descriptor.
+func NewReferrer(desc v1.Descriptor, artifact v1.Image) Referrer {
+	return Referrer{
+		Descriptor: desc,
+		artifact:   artifact,
+	}
+}
+
+// WithReferrers allows for artifacts referencing the image to be listed with Image.Referrers.
+func WithReferrers(resolver ReferrersResolver) AdditionalMetadata {
+	return func(image *Image) error {
+		image.referrers = resolver
+		return nil
+	}
+}
+
+// ArtifactType returns the artifact type of the referrer, falling back to the artifact config media type (or the
+// in-toto media type for buildkit attestation manifests).
+func (r Referrer) ArtifactType() string {
+	if r.Descriptor.ArtifactType != "" {
+		return r.Descriptor.ArtifactType
+	}
+
+	manifest, err := r.Manifest()
+	if err != nil {
+		return ""
+	}
+
+	if r.Descriptor.Annotations[DockerReferenceTypeAnnotation] == DockerAttestationManifestType && len(manifest.Layers) > 0 {
+		return string(manifest.Layers[0].MediaType)
+	}
+	return string(manifest.Config.MediaType)
+}
+
+// Manifest returns the artifact manifest.
+func (r Referrer) Manifest() (*v1.Manifest, error) {
+	return r.artifact.Manifest()
+}
+
+// Blobs returns the artifact content blobs (the layers of the artifact manifest).
+func (r Referrer) Blobs() ([]v1.Layer, error) {
+	return r.artifact.Layers()
+}
+
+// ReferencesSubject indicates that the given artifact manifest descriptor and manifest reference the given subject.
+func ReferencesSubject(desc v1.Descriptor, manifest *v1.Manifest, subject v1.Hash) bool {
+	if manifest != nil && manifest.Subject != nil && manifest.Subject.Digest == subject {
+		return true
+	}
+	return desc.Annotations[DockerReferenceTypeAnnotation] == DockerAttestationManifestType &&
+		desc.Annotations[DockerReferenceDigestAnnotation] == subject.String()
+}
+
+// IsAttestationManifest indicates that the given index entry (and optionally its manifest) describes an attestation
+// (e.g. an SBOM or provenance attached by buildkit as a "unknown/unknown" platform image) rather than a runnable image.
+// Such manifests should never be selected as the image for a platform, instead they are available via Image.Referrers.
+func IsAttestationManifest(desc v1.Descriptor, manifest *v1.Manifest) bool {
+	if desc.Annotations[DockerReferenceTypeAnnotation] == DockerAttestationManifestType {
+		return true
+	}
+
+	if desc.Platform != nil && desc.Platform.OS == attestationPlatform && desc.Platform.Architecture == attestationPlatform {
+		return true
+	}
+
+	if manifest == nil || len(manifest.Layers) == 0 {
+		return false
+	}
+
+	for _, l := range manifest.Layers {
+		if !strings.HasPrefix(string(l.MediaType), inTotoMediaTypePrefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// Referrers returns all artifacts that reference this image manifest, optionally filtered to the given artifact
+// types. Nil is returned when the image source does not support referrers.
+func (i *Image) Referrers(ctx context.Context, artifactTypes ...string) ([]Referrer, error) {
+	if i.referrers == nil || i.Metadata.ManifestDigest == "" {
+		return nil, nil
+	}
+
+	subject, err := v1.NewHash(i.Metadata.ManifestDigest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image manifest digest: %w", err)
+	}
+
+	referrers, err := i.referrers(ctx, subject)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list referrers: %w", err)
+	}
+
+	return FilterReferrers(referrers, artifactTypes...), nil
+}
+
+// FilterReferrers returns the referrers with any of the given artifact types (all referrers when no types are given).
+func FilterReferrers(referrers []Referrer, artifactTypes ...string) []Referrer {
+	if len(artifactTypes) == 0 {
+		return referrers
+	}
+
+	var filtered []Referrer
+	for _, r := range referrers {
+		for _, t := range artifactTypes {
+			if r.ArtifactType() == t {
+				filtered = append(filtered, r)
+				break
+			}
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,69 @@
+package image
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsAttestationManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		desc     v1.Descriptor
+		manifest *v1.Manifest
+		want     bool
+	}{
+		{
+			name: "image manifest",
+			desc: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}},
+			manifest: &v1.Manifest{
+				Layers: []v1.Descriptor{{MediaType: types.OCILayer}},
+			},
+			want: false,
+		},
+		{
+			name: "buildkit reference annotation",
+			desc: v1.Descriptor{Annotations: map[string]string{
+				DockerReferenceTypeAnnotation: DockerAttestationManifestType,
+			}},
+			want: true,
+		},
+		{
+			name: "unknown platform",
+			desc: v1.Descriptor{Platform: &v1.Platform{OS: "unknown", Architecture: "unknown"}},
+			want: true,
+		},
+		{
+			name: "in-toto layers",
+			manifest: &v1.Manifest{
+				Layers: []v1.Descriptor{
+					{MediaType: "application/vnd.in-toto+json"},
+					{MediaType: "application/vnd.in-toto.spdx+dsse"},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "mixed layers",
+			manifest: &v1.Manifest{
+				Layers: []v1.Descriptor{
+					{MediaType: "application/vnd.in-toto+json"},
+					{MediaType: types.OCILayer},
+				},
+			},
+			want: false,
+		},
+		{
+			name:     "no layers",
+			manifest: &v1.Manifest{},
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsAttestationManifest(tt.desc, tt.manifest))
+		})
+	}
+}
@@ -9,7 +9,7 @@ import (
 
 // RegistryCredentials contains any information necessary to authenticate against an OCI-distribution-compliant
 // registry (either with basic auth, or bearer token, or ggcr authenticator implementation).
-// Note: only valid for the OCI registry provider.
+// Note: only valid for the OCI registry provider and the containerd daemon provider (when pulling images).
 type RegistryCredentials struct {
 	Authority string
 	Username  string
@@ -24,6 +24,14 @@ type RegistryOptions struct {
 	Credentials           []RegistryCredentials
 	Keychain              authn.Keychain
 	CAFileOrDir           string
+	// MaxParallelism is the number of layers fetched from the registry at a time (serial when <= 1, see
+	// WithMaxParallelism)
+	MaxParallelism int
+	// LazyPull reads eStargz layers from the registry provider lazily: only the table of contents of each layer is
+	// fetched while reading the image, and file contents are fetched on demand (see LazyLayer). Other layers are
+	// fetched in full. Note: SOCI indexes (tables of contents stored separately from the layers, as referrers of the
+	// image) are not supported yet, thus layers indexed by SOCI alone are fetched in full.
+	LazyPull bool
 }
 
 type credentialSelection struct {
@@ -0,0 +1,140 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/containerd/containerd/errdefs"
+	remoteerrors "github.com/containerd/containerd/remotes/errors"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+type retryPolicyKey struct{}
+
+// TransientStatusCodes are the HTTP response status codes considered transient (see IsTransientError).
+var TransientStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy configures how network operations (registry pulls, daemon exports, and containerd pulls) are attempted
+// again when failing with a transient error (see IsTransientError), such that flaky networks do not abort long pulls.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts (including the first), where values below 2 disable retries
+	Attempts int
+	// Backoff is the delay before the first retry, which doubles for each subsequent retry
+	Backoff time.Duration
+	// MaxBackoff caps the delay between retries (no cap when zero)
+	MaxBackoff time.Duration
+}
+
+// Delay returns the delay before the given retry (starting at 1).
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < retry && d > 0; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+// ContextWithRetryPolicy returns a context that carries the retry policy for network operations.
+func ContextWithRetryPolicy(ctx context.Context, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, policy)
+}
+
+// RetryPolicyFromContext returns the retry policy carried by the context (nil when none, in which case each source
+// applies its own defaults).
+func RetryPolicyFromContext(ctx context.Context) *RetryPolicy {
+	if policy, ok := ctx.Value(retryPolicyKey{}).(RetryPolicy); ok {
+		return &policy
+	}
+	return nil
+}
+
+// Retry calls the given function until it succeeds, fails with an error that is not transient, or all attempts of the
+// retry policy of the context are used (the function is called once when there is no policy). Delays between attempts
+// are measured by the clock of the context (see ClockFromContext).
+func Retry(ctx context.Context, operation string, fn func() error) error {
+	policy := RetryPolicyFromContext(ctx)
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || policy == nil || attempt >= policy.Attempts || !IsTransientError(err) {
+			return err
+		}
+
+		delay := policy.Delay(attempt)
+		log.WithFields("operation", operation, "attempt", attempt, "delay", delay, "error", err).Warn("transient error, retrying")
+		if sleepErr := sleep(ctx, delay); sleepErr != nil {
+			return fmt.Errorf("%s: %w (while waiting to retry after: %v)", operation, sleepErr, err)
+		}
+	}
+}
+
+// sleep waits for the given duration on the clock of the context, returning early when the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	done := make(chan struct{})
+	timer := ClockFromContext(ctx).AfterFunc(d, func() { close(done) })
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// IsTransientError indicates the given error is likely to succeed when attempted again: responses with a transient
+// status code (see TransientStatusCodes) from registries, unavailable services, connection resets, timeouts, and
+// connections closed unexpectedly. Cancellation of the operation is never transient.
+func IsTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) {
+		return slices.Contains(TransientStatusCodes, transportErr.StatusCode)
+	}
+
+	var statusErr remoteerrors.ErrUnexpectedStatus
+	if errors.As(err, &statusErr) {
+		return slices.Contains(TransientStatusCodes, statusErr.StatusCode)
+	}
+
+	// docker daemon errors (see github.com/docker/docker/errdefs)
+	var unavailable interface{ Unavailable() }
+	if errors.As(err, &unavailable) || errdefs.IsUnavailable(err) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed)
+}
@@ -0,0 +1,122 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	remoteerrors "github.com/containerd/containerd/remotes/errors"
+	"github.com/docker/docker/errdefs"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "registry too many requests", err: fmt.Errorf("wrapped: %w", &transport.Error{StatusCode: http.StatusTooManyRequests}), want: true},
+		{name: "registry unavailable", err: &transport.Error{StatusCode: http.StatusServiceUnavailable}, want: true},
+		{name: "registry unauthorized", err: &transport.Error{StatusCode: http.StatusUnauthorized}, want: false},
+		{name: "registry not found", err: &transport.Error{StatusCode: http.StatusNotFound}, want: false},
+		{name: "containerd bad gateway", err: fmt.Errorf("pull failed: %w", remoteerrors.ErrUnexpectedStatus{StatusCode: http.StatusBadGateway}), want: true},
+		{name: "containerd forbidden", err: remoteerrors.ErrUnexpectedStatus{StatusCode: http.StatusForbidden}, want: false},
+		{name: "docker daemon unavailable", err: errdefs.Unavailable(errors.New("busy")), want: true},
+		{name: "docker daemon not found", err: errdefs.NotFound(errors.New("no such image")), want: false},
+		{name: "connection reset", err: fmt.Errorf("read: %w", syscall.ECONNRESET), want: true},
+		{name: "unexpected EOF", err: io.ErrUnexpectedEOF, want: true},
+		{name: "canceled", err: fmt.Errorf("%w: %w", context.Canceled, syscall.ECONNRESET), want: false},
+		{name: "other", err: errors.New("invalid manifest"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsTransientError(tt.err))
+		})
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	assert.Equal(t, time.Second, p.Delay(1))
+	assert.Equal(t, 2*time.Second, p.Delay(2))
+	assert.Equal(t, 4*time.Second, p.Delay(3))
+	assert.Equal(t, 5*time.Second, p.Delay(4))
+	assert.Equal(t, 5*time.Second, p.Delay(40))
+}
+
+func TestRetry(t *testing.T) {
+	transient := &transport.Error{StatusCode: http.StatusServiceUnavailable}
+	permanent := &transport.Error{StatusCode: http.StatusNotFound}
+
+	tests := []struct {
+		name      string
+		policy    *RetryPolicy
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "no policy", errs: []error{transient, nil}, wantCalls: 1, wantErr: transient},
+		{name: "succeeds after transient errors", policy: &RetryPolicy{Attempts: 3}, errs: []error{transient, transient, nil}, wantCalls: 3},
+		{name: "attempts exhausted", policy: &RetryPolicy{Attempts: 2}, errs: []error{transient, transient, nil}, wantCalls: 2, wantErr: transient},
+		{name: "permanent error", policy: &RetryPolicy{Attempts: 3}, errs: []error{permanent, nil}, wantCalls: 1, wantErr: permanent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.policy != nil {
+				ctx = ContextWithRetryPolicy(ctx, *tt.policy)
+			}
+
+			var calls int
+			err := Retry(ctx, "test", func() error {
+				calls++
+				return tt.errs[calls-1]
+			})
+			assert.Equal(t, tt.wantCalls, calls)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetry_waitsOnContextClock(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	ctx := ContextWithClock(ContextWithRetryPolicy(context.Background(), RetryPolicy{Attempts: 3, Backoff: time.Minute}), clock)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	calls := make(chan struct{}, 3)
+	done := make(chan error)
+	go func() {
+		done <- Retry(ctx, "test", func() error {
+			calls <- struct{}{}
+			return syscall.ECONNRESET
+		})
+	}()
+
+	<-calls
+	// the second attempt is made once the backoff has elapsed on the clock
+	require.Eventually(t, func() bool {
+		clock.Advance(time.Second)
+		return len(calls) == 1
+	}, 5*time.Second, time.Millisecond)
+	<-calls
+
+	// the context is canceled while waiting for the third attempt
+	cancel()
+	err := <-done
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, calls)
+}
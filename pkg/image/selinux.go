@@ -0,0 +1,31 @@
+package image
+
+import (
+	"sort"
+
+	"github.com/scylladb/go-set/strset"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+// SELinuxContexts returns the distinct SELinux security contexts (sorted) of all files within all layers of the image, as
+// recorded within the security.selinux xattrs of layer entries. Files without a recorded context are not considered.
+func (i *Image) SELinuxContexts() []string {
+	contexts := strset.New()
+	for _, layer := range i.Layers {
+		if layer.Tree == nil {
+			continue
+		}
+		for _, ref := range layer.Tree.AllFiles(file.AllTypes()...) {
+			entry, err := i.FileCatalog.Get(ref)
+			if err != nil || entry.SELinuxContext == "" {
+				continue
+			}
+			contexts.Add(entry.SELinuxContext)
+		}
+	}
+
+	list := contexts.List()
+	sort.Strings(list)
+	return list
+}
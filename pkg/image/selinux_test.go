@@ -0,0 +1,78 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func TestImage_SELinuxContexts(t *testing.T) {
+	first := selinuxTestLayer(t, map[string]string{
+		"bin/sh":     "system_u:object_r:shell_exec_t:s0\x00",
+		"etc/passwd": "system_u:object_r:passwd_file_t:s0\x00",
+		"etc/motd":   "",
+	})
+	second := selinuxTestLayer(t, map[string]string{
+		"bin/sh":  "system_u:object_r:shell_exec_t:s0",
+		"app/run": "system_u:object_r:container_file_t:s0:c1,c2",
+	})
+
+	v1Img, err := mutate.AppendLayers(empty.Image, first, second)
+	require.NoError(t, err)
+
+	img := New(v1Img, nil, t.TempDir())
+	require.NoError(t, img.Read())
+
+	assert.Equal(t, []string{
+		"system_u:object_r:container_file_t:s0:c1,c2",
+		"system_u:object_r:passwd_file_t:s0",
+		"system_u:object_r:shell_exec_t:s0",
+	}, img.SELinuxContexts())
+
+	_, res, err := img.SquashedTree().File(file.Path("/etc/passwd"))
+	require.NoError(t, err)
+	require.NotNil(t, res)
+	entry, err := img.FileCatalog.Get(*res.Reference)
+	require.NoError(t, err)
+	assert.Equal(t, "system_u:object_r:passwd_file_t:s0", entry.SELinuxContext)
+
+	_, res, err = img.SquashedTree().File(file.Path("/etc/motd"))
+	require.NoError(t, err)
+	require.NotNil(t, res)
+	entry, err = img.FileCatalog.Get(*res.Reference)
+	require.NoError(t, err)
+	assert.Empty(t, entry.SELinuxContext)
+}
+
+// selinuxTestLayer creates a layer with a regular file for each of the given names, labeled with the given SELinux
+// context (unlabeled when empty).
+func selinuxTestLayer(t *testing.T, files map[string]string) v1.Layer {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for name, context := range files {
+		hdr := &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Format: tar.FormatPAX}
+		if context != "" {
+			hdr.PAXRecords = map[string]string{"SCHILY.xattr.security.selinux": context}
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+	}
+	require.NoError(t, tw.Close())
+
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	})
+	require.NoError(t, err)
+	return layer
+}
@@ -31,7 +31,7 @@ func (p *singularityImageProvider) Name() string {
 }
 
 // Provide returns an Image that represents a Singularity Image Format (SIF) image.
-func (p *singularityImageProvider) Provide(_ context.Context) (*image.Image, error) {
+func (p *singularityImageProvider) Provide(ctx context.Context) (*image.Image, error) {
 	// We need to map the SIF to a GGCR v1.Image. Start with an implementation of the GGCR
 	// partial.UncompressedImageCore interface.
 	si, err := newSIFImage(p.path)
@@ -58,7 +58,7 @@ func (p *singularityImageProvider) Provide(_ context.Context) (*image.Image, err
 	}
 
 	out := image.New(ui, p.tmpDirGen, contentCacheDir, metadata...)
-	err = out.Read()
+	err = out.ReadContext(ctx)
 	if err != nil {
 		return nil, err
 	}
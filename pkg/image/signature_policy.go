@@ -0,0 +1,72 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrSignatureVerification is returned when an image does not have a signature satisfying the signature policy.
+var ErrSignatureVerification = errors.New("image signature verification failed")
+
+// SignaturePolicy describes which cosign signatures are accepted for an image (see ContextWithSignaturePolicy). An
+// image is accepted when at least one of its signatures was made by any of the public keys, or by a keyless signing
+// certificate for any of the identities.
+type SignaturePolicy struct {
+	// PublicKeys are PEM encoded public keys (ECDSA, RSA, or Ed25519) accepted for signatures made with a key pair
+	PublicKeys [][]byte
+
+	// Identities are the accepted identities of keyless signing certificates (requires Roots and TransparencyLogKeys)
+	Identities []SignatureIdentity
+
+	// Roots are the PEM encoded certificates (e.g. the Fulcio root and intermediates) that keyless signing
+	// certificates must chain to
+	Roots []byte
+
+	// TransparencyLogKeys are the PEM encoded public keys of the transparency log (e.g. Rekor). When given, signatures
+	// must be accompanied by a transparency log entry signed by any of these keys, where the time of the entry is used
+	// to verify the (short-lived) keyless signing certificates.
+	TransparencyLogKeys [][]byte
+}
+
+// SignatureIdentity is the identity of a keyless signing certificate: the certificate subject (the email or URI
+// subject alternative name) and the OIDC issuer that authenticated it, both of which must match exactly.
+type SignatureIdentity struct {
+	Subject string
+	Issuer  string
+}
+
+// Validate returns an error when the policy cannot accept any signature.
+func (p SignaturePolicy) Validate() error {
+	if len(p.PublicKeys) == 0 && len(p.Identities) == 0 {
+		return fmt.Errorf("signature policy requires public keys or keyless identities")
+	}
+	if len(p.Identities) > 0 {
+		if len(p.Roots) == 0 {
+			return fmt.Errorf("signature policy requires root certificates to verify keyless identities")
+		}
+		if len(p.TransparencyLogKeys) == 0 {
+			return fmt.Errorf("signature policy requires transparency log keys to verify keyless identities")
+		}
+	}
+	for _, id := range p.Identities {
+		if id.Subject == "" || id.Issuer == "" {
+			return fmt.Errorf("signature identities require a subject and issuer")
+		}
+	}
+	return nil
+}
+
+type signaturePolicyKey struct{}
+
+// ContextWithSignaturePolicy returns a context where registry providers verify the cosign signatures of images
+// against the given policy before the image is provided, failing with ErrSignatureVerification otherwise.
+func ContextWithSignaturePolicy(ctx context.Context, policy *SignaturePolicy) context.Context {
+	return context.WithValue(ctx, signaturePolicyKey{}, policy)
+}
+
+// SignaturePolicyFromContext returns the signature policy images must satisfy (nil when signatures are not verified).
+func SignaturePolicyFromContext(ctx context.Context) *SignaturePolicy {
+	policy, _ := ctx.Value(signaturePolicyKey{}).(*SignaturePolicy)
+	return policy
+}
@@ -0,0 +1,33 @@
+package image
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// NewSingleLayerImage creates an image with a single layer from the given (uncompressed) tar file on disk. This is
+// useful for providers of sources that are not container images themselves (e.g. system container or VM images) but
+// that should be analyzed through the same image API.
+func NewSingleLayerImage(layerTarPath string, config v1.ConfigFile) (v1.Image, error) {
+	layer, err := tarball.LayerFromFile(layerTarPath, tarball.WithMediaType(types.DockerLayer))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read layer tar %q: %w", layerTarPath, err)
+	}
+
+	img, err := mutate.ConfigFile(empty.Image, &config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set image config: %w", err)
+	}
+
+	img, err = mutate.AppendLayers(img, layer)
+	if err != nil {
+		return nil, fmt.Errorf("unable to append layer: %w", err)
+	}
+
+	return img, nil
+}
@@ -1,15 +1,187 @@
 package image
 
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
 type Source = string
 
 const (
-	UnknownSource          Source = ""
-	ContainerdDaemonSource Source = "containerd"
-	DockerTarballSource    Source = "docker-archive"
-	DockerDaemonSource     Source = "docker"
-	OciDirectorySource     Source = "oci-dir"
-	OciTarballSource       Source = "oci-archive"
-	OciRegistrySource      Source = "oci-registry"
-	PodmanDaemonSource     Source = "podman"
-	SingularitySource      Source = "singularity"
+	UnknownSource            Source = ""
+	BazelSource              Source = "bazel"
+	BuildKitSource           Source = "buildkit"
+	ContainerdDaemonSource   Source = "containerd"
+	ContainerdSnapshotSource Source = "containerd-snapshot"
+	CRIDaemonSource          Source = "cri"
+	CrioDaemonSource         Source = "crio"
+	DockerTarballSource      Source = "docker-archive"
+	DockerDaemonSource       Source = "docker"
+	InitramfsSource          Source = "initramfs"
+	ISOSource                Source = "iso"
+	KanikoCacheSource        Source = "kaniko-cache"
+	LxdTarballSource         Source = "lxd"
+	OciDirectorySource       Source = "oci-dir"
+	OciTarballSource         Source = "oci-archive"
+	OciRegistrySource        Source = "oci-registry"
+	PodmanDaemonSource       Source = "podman"
+	PodmanLibpodSource       Source = "podman-libpod"
+	RawTarballSource         Source = "raw-tarball"
+	RootfsDirectorySource    Source = "rootfs-dir"
+	SingularitySource        Source = "singularity"
+	VMDiskSource             Source = "vm-disk"
+)
+
+// tags used to select groups of image providers by the kind of input they accept
+const (
+	FileTag     = "file"
+	DirTag      = "dir"
+	DaemonTag   = "daemon"
+	PullTag     = "pull"
+	RegistryTag = "registry"
+)
+
+// capabilities of image sources, used to select providers by the requirements of the caller
+const (
+	// PlatformCapability indicates the source provides the image for a requested platform (selecting the platform
+	// from multi-platform images, or failing when the image does not match)
+	PlatformCapability = "platform"
+	// OfflineCapability indicates the source is able to provide images without network access (see ContextWithOffline)
+	OfflineCapability = "offline"
+	// DigestCapability indicates the content digests of images are verified while the image is read
+	DigestCapability = "digest"
+	// PullOnMissingCapability indicates the source pulls images that are missing from the store (e.g. a daemon)
+	PullOnMissingCapability = "pull-on-missing"
 )
+
+// SourceInfo describes the identity of an image source.
+type SourceInfo struct {
+	// Source is the canonical name of the source, which is also usable as a scheme (e.g. "docker-archive:path.tar")
+	Source Source
+	// DisplayName is a human-readable name for the source
+	DisplayName string
+	// Aliases are additional scheme names that select the source
+	Aliases []string
+	// Tags are the provider selection tags the source is associated with (e.g. FileTag)
+	Tags []string
+	// Capabilities are the capabilities of the source (e.g. PlatformCapability)
+	Capabilities []string
+	// Input is the kind of user input the source accepts (e.g. PathInput)
+	Input InputKind
+	// Auth are the registry authentication methods used by the source when pulling images (e.g. BasicAuth)
+	Auth []string
+	// Examples are example user inputs for the source (e.g. for help text)
+	Examples []string
+	// ExplicitOnly indicates the source is only used when requested by name (e.g. "rootfs-dir:/mnt/rootfs"), never when
+	// detecting the source of the input or when selecting sources by tag, since it accepts inputs of other sources
+	ExplicitOnly bool
+}
+
+// HasCapabilities indicates the source has all of the given capabilities.
+func (s SourceInfo) HasCapabilities(capabilities ...string) bool {
+	for _, c := range capabilities {
+		if !slices.Contains(s.Capabilities, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Names returns the canonical source name followed by all aliases.
+func (s SourceInfo) Names() []string {
+	return append([]string{s.Source}, s.Aliases...)
+}
+
+var sources = struct {
+	sync.RWMutex
+	byName map[string]SourceInfo
+}{
+	byName: make(map[string]SourceInfo),
+}
+
+func init() {
+	for _, info := range []SourceInfo{
+		{Source: BazelSource, DisplayName: "Bazel OCI layout", Tags: []string{FileTag, DirTag}, Capabilities: []string{PlatformCapability, OfflineCapability}, Input: PathInput, Examples: []string{"bazel-bin/app/image"}},
+		{Source: BuildKitSource, DisplayName: "BuildKit content store", Tags: []string{FileTag, DirTag}, Capabilities: []string{PlatformCapability, OfflineCapability}, Input: PathInput, Examples: []string{"/var/lib/buildkit/runc-overlayfs/content", "cache-export-dir#sha256:<digest>"}},
+		{Source: ContainerdDaemonSource, DisplayName: "containerd daemon", Tags: []string{DaemonTag, PullTag}, Capabilities: []string{PlatformCapability, OfflineCapability, PullOnMissingCapability}, Input: ReferenceInput, Auth: registryAuthMethods, Examples: []string{"alpine:latest"}},
+		{Source: ContainerdSnapshotSource, DisplayName: "containerd snapshot", Tags: []string{DaemonTag}, Capabilities: []string{OfflineCapability}, Input: ReferenceInput, Examples: []string{"<container-id>"}},
+		{Source: CRIDaemonSource, DisplayName: "Kubernetes CRI API", Tags: []string{DaemonTag}, Capabilities: []string{PlatformCapability, OfflineCapability}, Input: ReferenceInput, Examples: []string{"alpine:latest", "<image-id>"}},
+		{Source: CrioDaemonSource, DisplayName: "CRI-O containers-storage", Aliases: []string{"cri-o"}, Tags: []string{DaemonTag}, Capabilities: []string{PlatformCapability, OfflineCapability}, Input: ReferenceInput, Examples: []string{"alpine:latest", "<image-id>"}},
+		{Source: DockerTarballSource, DisplayName: "Docker archive", Tags: []string{FileTag}, Capabilities: []string{PlatformCapability, OfflineCapability}, Input: PathInput, Examples: []string{"image.tar"}},
+		{Source: DockerDaemonSource, DisplayName: "Docker daemon", Tags: []string{DaemonTag, PullTag}, Capabilities: []string{PlatformCapability, OfflineCapability, PullOnMissingCapability}, Input: ReferenceInput, Auth: []string{KeychainAuth}, Examples: []string{"alpine:latest"}},
+		{Source: InitramfsSource, DisplayName: "initramfs archive", Tags: []string{FileTag}, Capabilities: []string{OfflineCapability}, Input: PathInput, Examples: []string{"initrd.img"}},
+		{Source: ISOSource, DisplayName: "ISO9660 image", Tags: []string{FileTag}, Capabilities: []string{OfflineCapability}, Input: PathInput, Examples: []string{"appliance.iso"}},
+		{Source: KanikoCacheSource, DisplayName: "kaniko cache", Tags: []string{FileTag, DirTag}, Capabilities: []string{OfflineCapability}, Input: PathInput, Examples: []string{"/cache", "/cache@sha256:<digest>"}},
+		{Source: LxdTarballSource, DisplayName: "LXD image tarball", Tags: []string{FileTag}, Capabilities: []string{OfflineCapability}, Input: PathInput, Examples: []string{"image.tar.xz"}},
+		{Source: OciDirectorySource, DisplayName: "OCI layout directory", Tags: []string{FileTag, DirTag}, Capabilities: []string{PlatformCapability, OfflineCapability}, Input: PathInput, Examples: []string{"path/to/layout"}},
+		{Source: OciTarballSource, DisplayName: "OCI archive", Tags: []string{FileTag}, Capabilities: []string{PlatformCapability, OfflineCapability}, Input: PathInput, Examples: []string{"image-oci.tar"}},
+		{Source: OciRegistrySource, DisplayName: "OCI registry", Tags: []string{RegistryTag, PullTag}, Capabilities: []string{PlatformCapability, DigestCapability}, Input: ReferenceInput, Auth: registryAuthMethods, Examples: []string{"docker.io/library/alpine:latest"}},
+		{Source: PodmanDaemonSource, DisplayName: "Podman daemon", Tags: []string{DaemonTag, PullTag}, Capabilities: []string{PlatformCapability, OfflineCapability, PullOnMissingCapability}, Input: ReferenceInput, Auth: []string{KeychainAuth}, Examples: []string{"alpine:latest"}},
+		{Source: PodmanLibpodSource, DisplayName: "Podman REST API", Aliases: []string{"libpod"}, Tags: []string{DaemonTag, PullTag}, Capabilities: []string{PlatformCapability, OfflineCapability, PullOnMissingCapability}, Input: ReferenceInput, Examples: []string{"alpine:latest", "alpine@sha256:<digest>", "<image-id>"}},
+		{Source: RawTarballSource, DisplayName: "raw rootfs tarball", Aliases: []string{"rootfs"}, Tags: []string{FileTag}, Capabilities: []string{OfflineCapability}, Input: PathInput, Examples: []string{"rootfs.tar.gz"}},
+		{Source: RootfsDirectorySource, DisplayName: "root filesystem directory", Tags: []string{DirTag}, Capabilities: []string{OfflineCapability}, Input: PathInput, Examples: []string{"/mnt/rootfs"}, ExplicitOnly: true},
+		{Source: SingularitySource, DisplayName: "Singularity image", Aliases: []string{"sif"}, Tags: []string{FileTag}, Capabilities: []string{OfflineCapability}, Input: PathInput, Examples: []string{"image.sif"}},
+		{Source: VMDiskSource, DisplayName: "VM disk image", Tags: []string{FileTag}, Capabilities: []string{OfflineCapability}, Input: PathInput, Examples: []string{"disk.qcow2", "disk.raw"}},
+	} {
+		if err := RegisterSource(info); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterSource adds the given source to the set of known sources, making the source name and all aliases usable as
+// schemes. An error is returned if the source name or any alias is already registered.
+func RegisterSource(info SourceInfo) error {
+	if strings.TrimSpace(info.Source) == "" {
+		return fmt.Errorf("no source name provided")
+	}
+
+	sources.Lock()
+	defer sources.Unlock()
+
+	for _, n := range info.Names() {
+		n = normalizeSourceName(n)
+		if existing, ok := sources.byName[n]; ok {
+			return fmt.Errorf("source name %q is already registered by source %q", n, existing.Source)
+		}
+	}
+
+	for _, n := range info.Names() {
+		sources.byName[normalizeSourceName(n)] = info
+	}
+	return nil
+}
+
+// LookupSource returns the source registered with the given name or alias.
+func LookupSource(name string) (SourceInfo, bool) {
+	sources.RLock()
+	defer sources.RUnlock()
+
+	info, ok := sources.byName[normalizeSourceName(name)]
+	return info, ok
+}
+
+// Sources returns all registered sources, sorted by name.
+func Sources() []SourceInfo {
+	sources.RLock()
+	defer sources.RUnlock()
+
+	var out []SourceInfo
+	for n, info := range sources.byName {
+		if n == info.Source {
+			out = append(out, info)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Source < out[j].Source
+	})
+	return out
+}
+
+func normalizeSourceName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,67 @@
+package image
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupSource(t *testing.T) {
+	info, ok := LookupSource("SIF")
+	require.True(t, ok)
+	assert.Equal(t, SingularitySource, info.Source)
+	assert.Contains(t, info.Tags, FileTag)
+
+	info, ok = LookupSource(DockerTarballSource)
+	require.True(t, ok)
+	assert.Equal(t, DockerTarballSource, info.Source)
+
+	_, ok = LookupSource("not-a-source")
+	assert.False(t, ok)
+}
+
+func TestRegisterSource(t *testing.T) {
+	require.Error(t, RegisterSource(SourceInfo{}))
+	require.ErrorContains(t, RegisterSource(SourceInfo{Source: DockerTarballSource}), "already registered")
+	require.ErrorContains(t, RegisterSource(SourceInfo{Source: "something-new", Aliases: []string{"sif"}}), "already registered")
+
+	// a failed registration does not partially register names
+	_, ok := LookupSource("something-new")
+	assert.False(t, ok)
+}
+
+func TestSources(t *testing.T) {
+	all := Sources()
+	require.NotEmpty(t, all)
+
+	var names []string
+	for _, info := range all {
+		names = append(names, info.Source)
+		assert.NotEmpty(t, info.DisplayName, info.Source)
+		assert.NotEmpty(t, info.Tags, info.Source)
+	}
+	assert.IsNonDecreasing(t, names)
+	assert.Contains(t, names, OciRegistrySource)
+	assert.NotContains(t, names, "sif")
+}
+
+func TestSourceInfo_HasCapabilities(t *testing.T) {
+	registry, ok := LookupSource(OciRegistrySource)
+	require.True(t, ok)
+	assert.True(t, registry.HasCapabilities())
+	assert.True(t, registry.HasCapabilities(PlatformCapability, DigestCapability))
+	assert.False(t, registry.HasCapabilities(PlatformCapability, OfflineCapability))
+
+	sif, ok := LookupSource(SingularitySource)
+	require.True(t, ok)
+	assert.True(t, sif.HasCapabilities(OfflineCapability))
+	assert.False(t, sif.HasCapabilities(PlatformCapability))
+
+	// only sources that pull from registries (and are not daemons) require network access
+	for _, info := range Sources() {
+		pullsOnly := slices.Contains(info.Tags, PullTag) && !slices.Contains(info.Tags, DaemonTag)
+		assert.Equal(t, !pullsOnly, info.HasCapabilities(OfflineCapability), info.Source)
+	}
+}
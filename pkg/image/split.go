@@ -0,0 +1,142 @@
+package image
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Split splits the image into a base image and an app image at the given layer index (see SplitImage).
+func (i *Image) Split(lastBaseLayer int) (base, app v1.Image, err error) {
+	if i.image == nil {
+		return nil, nil, fmt.Errorf("image has no content to split")
+	}
+	return SplitImage(i.image, lastBaseLayer)
+}
+
+// SplitImage derives two OCI images from the given image: a base image with the layers up to (and including) the
+// given layer index, and an app image with all remaining layers. Both images share the platform of the given image
+// and the config history of their layers. The app image keeps the runtime config of the given image (e.g. the
+// entrypoint and environment) and is annotated with the manifest digest of the base image (as the OCI base image
+// digest annotation), where the base image only keeps the environment (since the runtime config of the base is not
+// recorded within the image). Layers are shared with the given image, thus nothing is read or copied until the derived
+// images are written.
+func SplitImage(img v1.Image, lastBaseLayer int) (base, app v1.Image, err error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read image layers: %w", err)
+	}
+	if lastBaseLayer < 0 || lastBaseLayer >= len(layers)-1 {
+		return nil, nil, fmt.Errorf("invalid layer index %d to split an image with %d layers (must be within 0..%d)", lastBaseLayer, len(layers), len(layers)-2)
+	}
+
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read image config: %w", err)
+	}
+	baseHistory, appHistory := splitHistory(cfg.History, lastBaseLayer+1, len(layers))
+
+	baseCfg := derivedConfig(cfg, baseHistory)
+	baseCfg.Config = v1.Config{Env: cfg.Config.Env}
+	if n := len(baseHistory); n > 0 {
+		baseCfg.Created = baseHistory[n-1].Created
+	}
+
+	base, err = derivedImage(baseCfg, layers[:lastBaseLayer+1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create base image: %w", err)
+	}
+
+	app, err = derivedImage(derivedConfig(cfg, appHistory), layers[lastBaseLayer+1:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create app image: %w", err)
+	}
+
+	baseDigest, err := base.Digest()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to determine base image digest: %w", err)
+	}
+	app = mutate.Annotations(app, map[string]string{ociBaseDigestAnnotation: baseDigest.String()}).(v1.Image)
+
+	return base, app, nil
+}
+
+// derivedConfig returns a copy of the given config with the given history (where the root filesystem is set as layers
+// are appended).
+func derivedConfig(cfg *v1.ConfigFile, history []v1.History) *v1.ConfigFile {
+	derived := cfg.DeepCopy()
+	derived.History = history
+	derived.RootFS = v1.RootFS{Type: "layers"}
+	return derived
+}
+
+// derivedImage creates an OCI image with the given config and layers.
+func derivedImage(cfg *v1.ConfigFile, layers []v1.Layer) (v1.Image, error) {
+	img := mutate.ConfigMediaType(mutate.MediaType(empty.Image, types.OCIManifestSchema1), types.OCIConfigJSON)
+
+	history := cfg.History
+	img, err := mutate.ConfigFile(img, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var addenda []mutate.Addendum
+	for _, layer := range layers {
+		mediaType, err := layer.MediaType()
+		if err != nil {
+			return nil, err
+		}
+		addenda = append(addenda, mutate.Addendum{Layer: layer, MediaType: ociLayerMediaType(mediaType)})
+	}
+	img, err = mutate.Append(img, addenda...)
+	if err != nil {
+		return nil, err
+	}
+
+	// appending layers adds an (empty) history entry for each layer when the config has history
+	cfgFile, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	cfgFile = cfgFile.DeepCopy()
+	cfgFile.History = history
+	return mutate.ConfigFile(img, cfgFile)
+}
+
+// splitHistory splits the given config history after the entry of the given number of (non-empty) layers, where
+// empty entries (e.g. ENV directives) following the last base layer belong to the app image. No history is returned
+// when the history does not describe all layers.
+func splitHistory(history []v1.History, baseLayers, totalLayers int) (base, app []v1.History) {
+	nonEmpty := 0
+	split := -1
+	for idx, h := range history {
+		if h.EmptyLayer {
+			continue
+		}
+		nonEmpty++
+		if nonEmpty == baseLayers {
+			split = idx + 1
+		}
+	}
+	if split < 0 || nonEmpty != totalLayers {
+		return nil, nil
+	}
+	return history[:split], history[split:]
+}
+
+// ociLayerMediaType returns the OCI equivalent of the given docker layer media type.
+func ociLayerMediaType(mediaType types.MediaType) types.MediaType {
+	switch mediaType {
+	case types.DockerLayer:
+		return types.OCILayer
+	case types.DockerUncompressedLayer:
+		return types.OCIUncompressedLayer
+	case types.DockerForeignLayer:
+		return types.OCIRestrictedLayer
+	default:
+		return mediaType
+	}
+}
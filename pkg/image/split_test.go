@@ -0,0 +1,125 @@
+package image
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitImage(t *testing.T) {
+	created := func(day int) v1.Time {
+		return v1.Time{Time: time.Date(2024, time.January, day, 0, 0, 0, 0, time.UTC)}
+	}
+
+	var layers []v1.Layer
+	for i := 0; i < 3; i++ {
+		layer, err := random.Layer(64, types.DockerLayer)
+		require.NoError(t, err)
+		layers = append(layers, layer)
+	}
+
+	cfg := &v1.ConfigFile{
+		Architecture: "arm64",
+		OS:           "linux",
+		Created:      created(3),
+		Config: v1.Config{
+			Env:        []string{"PATH=/usr/bin"},
+			Entrypoint: []string{"/app"},
+			Labels:     map[string]string{"app": "true"},
+		},
+		History: []v1.History{
+			{CreatedBy: "ADD rootfs", Created: created(1)},
+			{CreatedBy: "RUN install", Created: created(2)},
+			{CreatedBy: "ENV MODE=prod", EmptyLayer: true},
+			{CreatedBy: "COPY app", Created: created(3)},
+		},
+	}
+	img, err := mutate.ConfigFile(empty.Image, cfg)
+	require.NoError(t, err)
+	img, err = mutate.AppendLayers(img, layers...)
+	require.NoError(t, err)
+	cfgFile, err := img.ConfigFile()
+	require.NoError(t, err)
+	cfgFile = cfgFile.DeepCopy()
+	cfgFile.History = cfg.History
+	img, err = mutate.ConfigFile(img, cfgFile)
+	require.NoError(t, err)
+
+	base, app, err := SplitImage(img, 1)
+	require.NoError(t, err)
+
+	diffIDs := func(layers []v1.Layer) (ids []v1.Hash) {
+		for _, layer := range layers {
+			id, err := layer.DiffID()
+			require.NoError(t, err)
+			ids = append(ids, id)
+		}
+		return ids
+	}
+
+	baseCfg, err := base.ConfigFile()
+	require.NoError(t, err)
+	assert.Equal(t, diffIDs(layers[:2]), baseCfg.RootFS.DiffIDs)
+	assert.Equal(t, cfg.History[:2], baseCfg.History)
+	assert.Equal(t, v1.Config{Env: []string{"PATH=/usr/bin"}}, baseCfg.Config)
+	assert.Equal(t, created(2), baseCfg.Created)
+	assert.Equal(t, "arm64", baseCfg.Architecture)
+
+	appCfg, err := app.ConfigFile()
+	require.NoError(t, err)
+	assert.Equal(t, diffIDs(layers[2:]), appCfg.RootFS.DiffIDs)
+	assert.Equal(t, cfg.History[2:], appCfg.History)
+	assert.Equal(t, cfg.Config, appCfg.Config)
+
+	baseDigest, err := base.Digest()
+	require.NoError(t, err)
+	appManifest, err := app.Manifest()
+	require.NoError(t, err)
+	assert.Equal(t, types.OCIManifestSchema1, appManifest.MediaType)
+	assert.Equal(t, types.OCIConfigJSON, appManifest.Config.MediaType)
+	assert.Equal(t, baseDigest.String(), appManifest.Annotations[ociBaseDigestAnnotation])
+	require.Len(t, appManifest.Layers, 1)
+	assert.Equal(t, types.OCILayer, appManifest.Layers[0].MediaType)
+
+	appLayers, err := app.Layers()
+	require.NoError(t, err)
+	require.Len(t, appLayers, 1)
+	assert.Equal(t, diffIDs(layers[2:]), diffIDs(appLayers))
+}
+
+func TestSplitImage_invalidIndex(t *testing.T) {
+	img, err := random.Image(64, 2)
+	require.NoError(t, err)
+
+	for _, idx := range []int{-1, 1, 2} {
+		_, _, err := SplitImage(img, idx)
+		assert.ErrorContains(t, err, "invalid layer index")
+	}
+
+	_, _, err = (&Image{}).Split(0)
+	assert.ErrorContains(t, err, "no content to split")
+}
+
+func Test_splitHistory(t *testing.T) {
+	history := []v1.History{
+		{CreatedBy: "a"},
+		{CreatedBy: "b", EmptyLayer: true},
+		{CreatedBy: "c"},
+	}
+
+	base, app := splitHistory(history, 1, 2)
+	assert.Equal(t, history[:1], base)
+	assert.Equal(t, history[1:], app)
+
+	// history which does not describe every layer is dropped
+	base, app = splitHistory(history, 1, 3)
+	assert.Nil(t, base)
+	assert.Nil(t, app)
+}
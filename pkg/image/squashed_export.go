@@ -0,0 +1,193 @@
+package image
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+)
+
+// SquashedExportFormat is the format the squashed filesystem of an image is written in (see Image.SquashedExport).
+type SquashedExportFormat string
+
+const (
+	// SquashedTarFormat writes the squashed filesystem as a single (uncompressed) tar.
+	SquashedTarFormat SquashedExportFormat = "tar"
+	// SquashedOCIFormat writes an OCI image with the squashed filesystem as its only layer, as a tar of an OCI image
+	// layout (usable as an "oci-archive:" input).
+	SquashedOCIFormat SquashedExportFormat = "oci"
+)
+
+// paxBasicKeys are the PAX records that are represented by tar header fields, which are set from the squashed entry
+// instead of being carried over from the layer the entry was read from.
+var paxBasicKeys = map[string]struct{}{
+	"path": {}, "linkpath": {}, "size": {}, "uid": {}, "gid": {}, "uname": {}, "gname": {}, "mtime": {}, "atime": {}, "ctime": {},
+}
+
+// SquashedExport writes the squashed filesystem of the image (the filesystem as seen from the top layer, with all
+// whiteouts applied) to the given writer in the given format, flattening a multi-layer image into a single artifact.
+// Sockets cannot be represented within a tar and are not written.
+func (i *Image) SquashedExport(w io.Writer, format SquashedExportFormat) error {
+	if i.FileCatalog == nil {
+		return fmt.Errorf("image has not been read")
+	}
+
+	switch format {
+	case SquashedTarFormat:
+		return writeSquashedTar(w, i.SquashedTree(), i.FileCatalog)
+	case SquashedOCIFormat:
+		return i.writeSquashedOCIArchive(w)
+	}
+	return fmt.Errorf("unsupported squashed export format: %q", format)
+}
+
+// writeSquashedTar writes all paths within the given tree as a tar, in path order (parents before their children).
+func writeSquashedTar(w io.Writer, tree filetree.Reader, catalog FileCatalogReader) error {
+	paths := tree.AllRealPaths()
+	sort.Sort(file.Paths(paths))
+
+	tw := tar.NewWriter(w)
+	for _, p := range paths {
+		if p == file.DirSeparator || p.IsWhiteout() {
+			continue
+		}
+
+		_, res, err := tree.File(p)
+		if err != nil {
+			return fmt.Errorf("unable to resolve path %q: %w", p, err)
+		}
+
+		if res == nil || res.Reference == nil {
+			// implied directories (parents of a path without an entry in the layer tar)
+			hdr := &tar.Header{Name: squashedEntryName(p, true), Typeflag: tar.TypeDir, Mode: 0755}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			continue
+		}
+
+		entry, err := catalog.Get(*res.Reference)
+		if err != nil {
+			return fmt.Errorf("unable to get metadata for %q: %w", p, err)
+		}
+
+		hdr, err := squashedHeader(p, entry.Metadata)
+		if err != nil {
+			log.WithFields("path", p, "type", entry.Metadata.Type).Tracef("skipping path while exporting squashed filesystem: %+v", err)
+			continue
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("unable to write %q: %w", p, err)
+		}
+
+		if hdr.Typeflag == tar.TypeReg && hdr.Size > 0 {
+			if err := copyContents(tw, catalog, *res.Reference); err != nil {
+				return fmt.Errorf("unable to write contents of %q: %w", p, err)
+			}
+		}
+	}
+	return tw.Close()
+}
+
+func copyContents(w io.Writer, catalog FileCatalogReader, ref file.Reference) error {
+	reader, err := catalog.Open(ref)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	_, err = io.Copy(w, reader)
+	return err
+}
+
+// squashedHeader returns the tar header of the entry at the given path, preserving the details of the header the
+// entry was read from (e.g. owner names, timestamps, and extended attributes) when known.
+func squashedHeader(p file.Path, m file.Metadata) (*tar.Header, error) {
+	hdr := &tar.Header{Mode: 0644}
+	if m.FileInfo != nil {
+		var err error
+		if hdr, err = tar.FileInfoHeader(m.FileInfo, m.LinkDestination); err != nil {
+			return nil, err
+		}
+	}
+
+	switch m.Type {
+	case file.TypeDirectory:
+		hdr.Typeflag = tar.TypeDir
+		hdr.Size = 0
+	case file.TypeRegular:
+		hdr.Typeflag = tar.TypeReg
+	case file.TypeSymLink:
+		hdr.Typeflag = tar.TypeSymlink
+		hdr.Size = 0
+	case file.TypeHardLink:
+		hdr.Typeflag = tar.TypeLink
+		hdr.Size = 0
+	case file.TypeSocket:
+		return nil, fmt.Errorf("sockets cannot be written to a tar")
+	default:
+		if m.FileInfo == nil {
+			return nil, fmt.Errorf("unknown file details")
+		}
+	}
+
+	hdr.Name = squashedEntryName(p, hdr.Typeflag == tar.TypeDir)
+	hdr.Linkname = m.LinkDestination
+	hdr.Uid = m.UserID
+	hdr.Gid = m.GroupID
+	for k := range hdr.PAXRecords {
+		if _, ok := paxBasicKeys[k]; ok {
+			delete(hdr.PAXRecords, k)
+		}
+	}
+	return hdr, nil
+}
+
+func squashedEntryName(p file.Path, dir bool) string {
+	name := strings.TrimPrefix(string(p), file.DirSeparator)
+	if dir {
+		name += "/"
+	}
+	return name
+}
+
+// writeSquashedOCIArchive writes an OCI image with the squashed filesystem as its only layer (see SquashedOCIFormat).
+func (i *Image) writeSquashedOCIArchive(w io.Writer) error {
+	fh, err := os.CreateTemp(i.contentCacheDir, "squashed-*.tar")
+	if err != nil {
+		return fmt.Errorf("unable to create squashed layer: %w", err)
+	}
+	defer os.Remove(fh.Name())
+
+	err = writeSquashedTar(fh, i.SquashedTree(), i.FileCatalog)
+	if closeErr := fh.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write squashed layer: %w", err)
+	}
+
+	layer, err := tarball.LayerFromFile(fh.Name())
+	if err != nil {
+		return fmt.Errorf("unable to read squashed layer: %w", err)
+	}
+
+	history := []v1.History{{
+		Created: i.Metadata.Config.Created,
+		Comment: fmt.Sprintf("squashed from %d layers", len(i.Layers)),
+	}}
+	img, err := derivedImage(derivedConfig(&i.Metadata.Config, history), []v1.Layer{layer})
+	if err != nil {
+		return fmt.Errorf("unable to create squashed image: %w", err)
+	}
+	return WriteOCIArchive(w, img, nil)
+}
@@ -0,0 +1,96 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_SquashedExport(t *testing.T) {
+	v1Img, err := mutate.AppendLayers(empty.Image,
+		tarLayer(t, map[string]string{"etc/app.conf": "v1", "etc/removed": "gone"}),
+		tarLayer(t, map[string]string{"etc/app.conf": "v2", "etc/.wh.removed": "", "bin/app": "binary"}),
+	)
+	require.NoError(t, err)
+	img := New(v1Img, nil, t.TempDir())
+	require.NoError(t, img.Read())
+
+	expected := map[string]string{
+		"bin/":         "",
+		"bin/app":      "binary",
+		"etc/":         "",
+		"etc/app.conf": "v2",
+	}
+
+	t.Run("tar", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		require.NoError(t, img.SquashedExport(buf, SquashedTarFormat))
+		assert.Equal(t, expected, readTarContents(t, buf))
+	})
+
+	t.Run("oci", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		require.NoError(t, img.SquashedExport(buf, SquashedOCIFormat))
+
+		dir := t.TempDir()
+		for name, contents := range readTarContents(t, buf) {
+			if strings.HasSuffix(name, "/") {
+				continue
+			}
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o600))
+		}
+		index, err := layout.ImageIndexFromPath(dir)
+		require.NoError(t, err)
+		manifest, err := index.IndexManifest()
+		require.NoError(t, err)
+		require.Len(t, manifest.Manifests, 1)
+
+		squashed, err := index.Image(manifest.Manifests[0].Digest)
+		require.NoError(t, err)
+		layers, err := squashed.Layers()
+		require.NoError(t, err)
+		require.Len(t, layers, 1)
+
+		cfg, err := squashed.ConfigFile()
+		require.NoError(t, err)
+		assert.Len(t, cfg.RootFS.DiffIDs, 1)
+		assert.Len(t, cfg.History, 1)
+
+		reader, err := layers[0].Uncompressed()
+		require.NoError(t, err)
+		defer reader.Close()
+		assert.Equal(t, expected, readTarContents(t, reader))
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		require.Error(t, img.SquashedExport(io.Discard, "zip"))
+	})
+}
+
+func readTarContents(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+	contents := map[string]string{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return contents
+		}
+		require.NoError(t, err)
+		b, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		contents[hdr.Name] = string(b)
+	}
+}
@@ -0,0 +1,121 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+var errOverlayNotSupported = errors.New("overlayfs is not supported on this platform")
+
+// SquashedViewOptions configures how a SquashedView is constructed.
+type SquashedViewOptions struct {
+	// PreferOverlay will attempt to build the view by mounting the extracted layer directories with overlayfs
+	// (Linux only, requires sufficient privileges), falling back to unpacking the logical squash when not possible.
+	PreferOverlay bool
+	// Unpack configures how files are written to disk.
+	Unpack UnpackOptions
+}
+
+// SquashedView is the squashed filesystem of an image materialized on the host.
+type SquashedView struct {
+	// Path is the root directory of the squashed filesystem.
+	Path string
+	// Overlay indicates that the view is backed by a read-only overlayfs mount (otherwise the squash tree was unpacked).
+	Overlay bool
+	cleanup func() error
+}
+
+// Close releases all resources for the view (unmounting the overlay if necessary).
+func (v *SquashedView) Close() error {
+	if v == nil || v.cleanup == nil {
+		return nil
+	}
+	return v.cleanup()
+}
+
+// SquashedView materializes the squashed filesystem of the image within the given (empty or non-existent) directory.
+// Callers must call Close() on the returned view when finished.
+func (i *Image) SquashedView(dir string, opts SquashedViewOptions) (*SquashedView, error) {
+	if i.FileCatalog == nil {
+		return nil, fmt.Errorf("image has not been read")
+	}
+
+	if opts.PreferOverlay {
+		view, err := i.overlayView(dir, opts.Unpack)
+		if err == nil {
+			return view, nil
+		}
+		log.WithFields("error", err).Debug("unable to create overlayfs squashed view, falling back to unpacking the squashed tree")
+	}
+
+	rootfs := filepath.Join(dir, "rootfs")
+	if err := i.UnpackRootfs(rootfs, opts.Unpack); err != nil {
+		return nil, err
+	}
+
+	return &SquashedView{
+		Path: rootfs,
+		cleanup: func() error {
+			return os.RemoveAll(rootfs)
+		},
+	}, nil
+}
+
+func (i *Image) overlayView(dir string, opts UnpackOptions) (*SquashedView, error) {
+	if !overlaySupported() {
+		return nil, errOverlayNotSupported
+	}
+
+	layersDir := filepath.Join(dir, "layers")
+	cleanupLayers := func() error {
+		return os.RemoveAll(layersDir)
+	}
+
+	// note: overlayfs expects the uppermost layer first in the set of lower directories
+	var lowerDirs []string
+	for idx := len(i.Layers) - 1; idx >= 0; idx-- {
+		layer := i.Layers[idx]
+		layerDir := filepath.Join(layersDir, fmt.Sprintf("%d", layer.Metadata.Index))
+		if err := unpackTree(layer.Tree, i.FileCatalog, layerDir, opts, overlayWhiteout); err != nil {
+			_ = cleanupLayers()
+			return nil, fmt.Errorf("unable to extract layer %d: %w", layer.Metadata.Index, err)
+		}
+		lowerDirs = append(lowerDirs, layerDir)
+	}
+
+	if len(lowerDirs) == 1 {
+		// a read-only overlay mount requires at least two lower directories, which may not be the same directory
+		emptyDir := filepath.Join(layersDir, "empty")
+		if err := os.MkdirAll(emptyDir, 0755); err != nil {
+			_ = cleanupLayers()
+			return nil, err
+		}
+		lowerDirs = append(lowerDirs, emptyDir)
+	}
+
+	rootfs := filepath.Join(dir, "rootfs")
+	if err := os.MkdirAll(rootfs, 0755); err != nil {
+		_ = cleanupLayers()
+		return nil, err
+	}
+
+	if err := mountOverlay(lowerDirs, rootfs); err != nil {
+		_ = cleanupLayers()
+		return nil, err
+	}
+
+	return &SquashedView{
+		Path:    rootfs,
+		Overlay: true,
+		cleanup: func() error {
+			if err := unmountOverlay(rootfs); err != nil {
+				return err
+			}
+			return errors.Join(cleanupLayers(), os.RemoveAll(rootfs))
+		},
+	}, nil
+}
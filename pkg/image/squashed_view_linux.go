@@ -0,0 +1,57 @@
+//go:build linux
+
+package image
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func overlaySupported() bool {
+	return os.Geteuid() == 0
+}
+
+// overlayWhiteout converts an OCI whiteout entry into the overlayfs equivalent: a 0/0 character device for removed
+// paths and the "trusted.overlay.opaque" xattr for opaque directories.
+func overlayWhiteout(target string, p file.Path) error {
+	if p.IsDirWhiteout() {
+		dir := filepath.Dir(target)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		return syscall.Setxattr(dir, "trusted.overlay.opaque", []byte("y"), 0)
+	}
+
+	removed := filepath.Join(filepath.Dir(target), strings.TrimPrefix(filepath.Base(target), file.WhiteoutPrefix))
+	return syscall.Mknod(removed, syscall.S_IFCHR, 0)
+}
+
+func mountOverlay(lowerDirs []string, target string) error {
+	if len(lowerDirs) == 0 {
+		return fmt.Errorf("no layers to mount")
+	}
+
+	// note: a read-only overlay mount requires at least two (distinct, non-overlapping) lower directories
+	if len(lowerDirs) < 2 {
+		return fmt.Errorf("at least two lower directories are required for a read-only overlay mount")
+	}
+
+	options := "lowerdir=" + strings.Join(lowerDirs, ":")
+	if len(options) >= os.Getpagesize() {
+		return fmt.Errorf("too many layers to mount with overlayfs (options length=%d)", len(options))
+	}
+
+	if err := syscall.Mount("overlay", target, "overlay", syscall.MS_RDONLY, options); err != nil {
+		return fmt.Errorf("unable to mount overlayfs: %w", err)
+	}
+	return nil
+}
+
+func unmountOverlay(target string) error {
+	return syscall.Unmount(target, 0)
+}
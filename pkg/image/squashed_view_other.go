@@ -0,0 +1,23 @@
+//go:build !linux
+
+package image
+
+import (
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func overlaySupported() bool {
+	return false
+}
+
+func overlayWhiteout(_ string, _ file.Path) error {
+	return errOverlayNotSupported
+}
+
+func mountOverlay(_ []string, _ string) error {
+	return errOverlayNotSupported
+}
+
+func unmountOverlay(_ string) error {
+	return errOverlayNotSupported
+}
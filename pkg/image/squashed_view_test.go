@@ -0,0 +1,48 @@
+package image
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage_SquashedView(t *testing.T) {
+	tests := []struct {
+		name          string
+		preferOverlay bool
+	}{
+		{
+			name: "logical squash",
+		},
+		{
+			// note: this may still fall back to the logical squash when the test is run without privileges
+			name:          "prefer overlay",
+			preferOverlay: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := newTestImage(t, []tar.Header{
+				{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "etc/os-release", Typeflag: tar.TypeReg, Mode: 0644},
+			}, "ID=test\n")
+
+			view, err := img.SquashedView(t.TempDir(), SquashedViewOptions{PreferOverlay: tt.preferOverlay})
+			require.NoError(t, err)
+			// note: a single layer image is mounted with an additional (empty) lower directory
+			assert.Equal(t, tt.preferOverlay && overlaySupported(), view.Overlay)
+
+			contents, err := os.ReadFile(filepath.Join(view.Path, "etc", "os-release"))
+			require.NoError(t, err)
+			assert.Equal(t, "ID=test\n", string(contents))
+
+			require.NoError(t, view.Close())
+			_, err = os.Stat(view.Path)
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
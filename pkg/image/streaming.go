@@ -0,0 +1,19 @@
+package image
+
+import "context"
+
+type streamingExportKey struct{}
+
+// ContextWithStreamingExport returns a context where daemon providers (docker, podman, and containerd) consume the
+// image export stream directly instead of first writing the entire image tarball to a temp file. Each layer blob is
+// written once (uncompressed layers are written directly into the layer cache), which roughly halves the disk usage
+// for large images.
+func ContextWithStreamingExport(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamingExportKey{}, true)
+}
+
+// IsStreamingExport indicates that daemon exports should be streamed (see ContextWithStreamingExport).
+func IsStreamingExport(ctx context.Context) bool {
+	streaming, _ := ctx.Value(streamingExportKey{}).(bool)
+	return streaming
+}
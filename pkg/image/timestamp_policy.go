@@ -0,0 +1,114 @@
+package image
+
+import (
+	"archive/tar"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+// TimestampMode describes how implausible timestamps of layer entries are handled (see TimestampPolicy).
+type TimestampMode string
+
+const (
+	// TimestampsPreserved keeps all timestamps as they are within the layer (the default)
+	TimestampsPreserved TimestampMode = ""
+	// TimestampsClamped moves implausible timestamps to the nearest plausible time (TimestampPolicy.Earliest or
+	// TimestampPolicy.Latest)
+	TimestampsClamped TimestampMode = "clamp"
+	// TimestampsZeroed replaces implausible timestamps with the zero time, the same as timestamps that are not recorded
+	TimestampsZeroed TimestampMode = "zero"
+)
+
+// LatestPlausibleTimestamp is the default latest plausible timestamp of layer entries: one second before the largest
+// unsigned 32-bit unix time (2106-02-07T06:28:15Z), which tools commonly write for unknown times.
+var LatestPlausibleTimestamp = time.Unix(math.MaxUint32-1, 0).UTC()
+
+// TimestampPolicy controls how the timestamps (modification, access, and change times) of layer entries outside the
+// plausible range are handled while layers are read, such as negative times (before the unix epoch) or times in the
+// year 2106. Timestamps that are not recorded (the zero time) are left as-is.
+type TimestampPolicy struct {
+	Mode TimestampMode
+	// Earliest is the earliest plausible timestamp (the unix epoch when zero)
+	Earliest time.Time
+	// Latest is the latest plausible timestamp (LatestPlausibleTimestamp when zero)
+	Latest time.Time
+}
+
+// WithTimestampPolicy sets how implausible timestamps of layer entries are handled while layers are read (see
+// TimestampPolicy).
+func WithTimestampPolicy(policy TimestampPolicy) AdditionalMetadata {
+	return func(image *Image) error {
+		switch policy.Mode {
+		case TimestampsPreserved, TimestampsClamped, TimestampsZeroed:
+		default:
+			return fmt.Errorf("unsupported timestamp mode: %q", policy.Mode)
+		}
+		if policy.Earliest.IsZero() {
+			policy.Earliest = time.Unix(0, 0).UTC()
+		}
+		if policy.Latest.IsZero() {
+			policy.Latest = LatestPlausibleTimestamp
+		}
+		if !policy.Earliest.Before(policy.Latest) {
+			return fmt.Errorf("earliest plausible timestamp %s must be before the latest %s", policy.Earliest, policy.Latest)
+		}
+		image.timestampPolicy = &policy
+		return nil
+	}
+}
+
+// apply handles the implausible timestamps of the given layer entry according to the policy (a nil policy preserves
+// all timestamps). The file info of the entry reflects the resulting modification time.
+func (p *TimestampPolicy) apply(m *file.Metadata) {
+	if p == nil || p.Mode == TimestampsPreserved {
+		return
+	}
+
+	ts := file.Timestamps{
+		Modified: p.normalize(m.Timestamps.Modified),
+		Accessed: p.normalize(m.Timestamps.Accessed),
+		Changed:  p.normalize(m.Timestamps.Changed),
+	}
+	if ts == m.Timestamps {
+		return
+	}
+	m.Timestamps = ts
+
+	if m.FileInfo == nil {
+		return
+	}
+	if hdr, ok := m.FileInfo.Sys().(*tar.Header); ok {
+		normalized := *hdr
+		normalized.ModTime, normalized.AccessTime, normalized.ChangeTime = ts.Modified, ts.Accessed, ts.Changed
+		m.FileInfo = normalized.FileInfo()
+		return
+	}
+	m.FileInfo = file.ManualInfo{
+		NameValue:    m.FileInfo.Name(),
+		SizeValue:    m.FileInfo.Size(),
+		ModeValue:    m.FileInfo.Mode(),
+		ModTimeValue: ts.Modified,
+		SysValue:     m.FileInfo.Sys(),
+	}
+}
+
+func (p *TimestampPolicy) normalize(t time.Time) time.Time {
+	switch {
+	case t.IsZero():
+		return t
+	case t.Before(p.Earliest):
+		if p.Mode == TimestampsClamped {
+			return p.Earliest
+		}
+		return time.Time{}
+	case t.After(p.Latest):
+		if p.Mode == TimestampsClamped {
+			return p.Latest
+		}
+		return time.Time{}
+	}
+	return t
+}
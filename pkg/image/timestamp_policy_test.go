@@ -0,0 +1,110 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func TestWithTimestampPolicy(t *testing.T) {
+	plausible := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	year2106 := time.Unix(math.MaxUint32, 0).UTC()
+	negative := time.Unix(-86400, 0).UTC()
+	epoch := time.Unix(0, 0).UTC()
+
+	tests := []struct {
+		name   string
+		policy *TimestampPolicy
+		want   map[string]time.Time
+	}{
+		{
+			name: "preserved by default",
+			want: map[string]time.Time{"/plausible": plausible, "/future": year2106, "/negative": negative},
+		},
+		{
+			name:   "clamped",
+			policy: &TimestampPolicy{Mode: TimestampsClamped},
+			want:   map[string]time.Time{"/plausible": plausible, "/future": LatestPlausibleTimestamp, "/negative": epoch},
+		},
+		{
+			name:   "clamped to a custom range",
+			policy: &TimestampPolicy{Mode: TimestampsClamped, Latest: plausible.Add(-time.Hour)},
+			want:   map[string]time.Time{"/plausible": plausible.Add(-time.Hour), "/future": plausible.Add(-time.Hour), "/negative": epoch},
+		},
+		{
+			name:   "zeroed",
+			policy: &TimestampPolicy{Mode: TimestampsZeroed},
+			want:   map[string]time.Time{"/plausible": plausible, "/future": {}, "/negative": {}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var metadata []AdditionalMetadata
+			if tt.policy != nil {
+				metadata = append(metadata, WithTimestampPolicy(*tt.policy))
+			}
+			img := newTimestampTestImage(t, map[string]time.Time{"plausible": plausible, "future": year2106, "negative": negative}, metadata...)
+
+			for p, want := range tt.want {
+				_, res, err := img.SquashedTree().File(file.Path(p))
+				require.NoError(t, err)
+				require.NotNil(t, res)
+				entry, err := img.FileCatalog.Get(*res.Reference)
+				require.NoError(t, err)
+
+				assert.True(t, want.Equal(entry.Metadata.Timestamps.Modified), "%s: want %s, got %s", p, want, entry.Metadata.Timestamps.Modified)
+				assert.True(t, want.Equal(entry.Metadata.Timestamps.Accessed), "%s: want %s, got %s", p, want, entry.Metadata.Timestamps.Accessed)
+				// the file info reflects the resulting modification time
+				assert.True(t, want.Equal(entry.Metadata.ModTime()), "%s: want %s, got %s", p, want, entry.Metadata.ModTime())
+			}
+		})
+	}
+}
+
+func TestWithTimestampPolicy_invalid(t *testing.T) {
+	require.Error(t, WithTimestampPolicy(TimestampPolicy{Mode: "round"})(&Image{}))
+	require.Error(t, WithTimestampPolicy(TimestampPolicy{Mode: TimestampsClamped, Earliest: LatestPlausibleTimestamp.Add(time.Hour)})(&Image{}))
+}
+
+// newTimestampTestImage creates an image with a single layer holding a regular file for each of the given names, with
+// modification and access times of the given times.
+func newTimestampTestImage(t *testing.T, files map[string]time.Time, metadata ...AdditionalMetadata) *Image {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for name, ts := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:       name,
+			Typeflag:   tar.TypeReg,
+			Mode:       0644,
+			ModTime:    ts,
+			AccessTime: ts,
+			Format:     tar.FormatPAX,
+		}))
+	}
+	require.NoError(t, tw.Close())
+
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	})
+	require.NoError(t, err)
+
+	v1Img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+
+	img := New(v1Img, nil, t.TempDir(), metadata...)
+	require.NoError(t, img.Read())
+	return img
+}
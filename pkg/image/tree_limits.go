@@ -0,0 +1,139 @@
+package image
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+// TreeLimit identifies a limit on the shape of layer trees (see TreeLimits).
+type TreeLimit string
+
+const (
+	TreeLimitDepth            TreeLimit = "depth"
+	TreeLimitDirectoryEntries TreeLimit = "directory-entries"
+)
+
+// maxRecordedTreeLimitViolations bounds the number of violations recorded within the metadata of each layer (all
+// violations are still counted, see LayerMetadata.TruncatedEntries).
+const maxRecordedTreeLimitViolations = 100
+
+// TreeLimits bounds the shape of each layer tree as it is constructed, protecting against crafted images designed to
+// exhaust resources (e.g. millions of entries within a single directory, or extremely deep paths).
+type TreeLimits struct {
+	// MaxDepth is the maximum number of path components of any entry, where "/usr/bin/env" has a depth of 3 (no limit
+	// when zero)
+	MaxDepth int
+	// MaxDirectoryEntries is the maximum number of entries within any single directory of a layer, including
+	// directories that are only implied by deeper paths (no limit when zero)
+	MaxDirectoryEntries int
+	// Truncate skips all entries that exceed a limit instead of failing the read with ErrTreeLimitExceeded. Skipped
+	// entries are recorded in the layer metadata (see LayerMetadata.TreeLimitViolations).
+	Truncate bool
+}
+
+// ErrTreeLimitExceeded is returned when a layer entry exceeds a tree limit (see WithTreeLimits).
+type ErrTreeLimitExceeded struct {
+	Limit TreeLimit
+	Max   int
+	// Path is the path of the layer entry that exceeded the limit
+	Path string
+}
+
+func (e *ErrTreeLimitExceeded) Error() string {
+	return fmt.Sprintf("layer entry %q exceeds the tree %s limit (max %d)", e.Path, e.Limit, e.Max)
+}
+
+// WithTreeLimits bounds the depth and directory sizes of all layer trees while layers are read (see TreeLimits).
+func WithTreeLimits(limits TreeLimits) AdditionalMetadata {
+	return func(image *Image) error {
+		if limits.MaxDepth < 0 || limits.MaxDirectoryEntries < 0 {
+			return fmt.Errorf("tree limits must not be negative: depth=%d directory-entries=%d", limits.MaxDepth, limits.MaxDirectoryEntries)
+		}
+		image.treeLimits = &limits
+		return nil
+	}
+}
+
+// treeLimiter tracks the shape of a single layer tree as entries are added (a nil limiter admits all entries).
+type treeLimiter struct {
+	limits TreeLimits
+	// metadata is where entries skipped while truncating are recorded
+	metadata *LayerMetadata
+	// children are the names of all entries within each directory seen so far
+	children map[string]map[string]struct{}
+}
+
+func newTreeLimiter(limits *TreeLimits, metadata *LayerMetadata) *treeLimiter {
+	if limits == nil || (limits.MaxDepth == 0 && limits.MaxDirectoryEntries == 0) {
+		return nil
+	}
+	return &treeLimiter{
+		limits:   *limits,
+		metadata: metadata,
+		children: make(map[string]map[string]struct{}),
+	}
+}
+
+// admit records the given layer entry path, returning false when the entry should be skipped (when truncating) and
+// an error when the read should fail.
+func (t *treeLimiter) admit(p string) (bool, error) {
+	if t == nil {
+		return true, nil
+	}
+
+	err := t.check(p)
+	if err == nil {
+		return true, nil
+	}
+
+	if !t.limits.Truncate {
+		return false, err
+	}
+
+	metadata := t.metadata
+	if metadata.TruncatedEntries == 0 {
+		log.WithFields("layer", metadata.Digest, "path", err.Path, "limit", err.Limit).Warn("layer exceeds tree limits, skipping entries")
+	}
+	metadata.TruncatedEntries++
+	if len(metadata.TreeLimitViolations) < maxRecordedTreeLimitViolations {
+		metadata.TreeLimitViolations = append(metadata.TreeLimitViolations, *err)
+	}
+	return false, nil
+}
+
+func (t *treeLimiter) check(p string) *ErrTreeLimitExceeded {
+	normalized := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if normalized == "" {
+		return nil
+	}
+	components := strings.Split(normalized, "/")
+
+	if t.limits.MaxDepth > 0 && len(components) > t.limits.MaxDepth {
+		return &ErrTreeLimitExceeded{Limit: TreeLimitDepth, Max: t.limits.MaxDepth, Path: "/" + normalized}
+	}
+
+	if t.limits.MaxDirectoryEntries == 0 {
+		return nil
+	}
+
+	// every ancestor directory gains an entry (which may only be implied by this path)
+	dir := "/"
+	for _, name := range components {
+		children, ok := t.children[dir]
+		if !ok {
+			children = make(map[string]struct{})
+			t.children[dir] = children
+		}
+		if _, exists := children[name]; !exists {
+			if len(children) >= t.limits.MaxDirectoryEntries {
+				return &ErrTreeLimitExceeded{Limit: TreeLimitDirectoryEntries, Max: t.limits.MaxDirectoryEntries, Path: "/" + normalized}
+			}
+			children[name] = struct{}{}
+		}
+		dir = path.Join(dir, name)
+	}
+	return nil
+}
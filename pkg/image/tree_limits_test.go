@@ -0,0 +1,110 @@
+package image
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func TestWithTreeLimits(t *testing.T) {
+	files := map[string]string{
+		"etc/os-release":                     "ID=test\n",
+		strings.Repeat("deep/", 10) + "file": "deep",
+	}
+	for i := 0; i < 10; i++ {
+		files[fmt.Sprintf("wide/file-%02d", i)] = "wide"
+	}
+
+	v1Img, err := mutate.AppendLayers(empty.Image, tarLayer(t, files))
+	require.NoError(t, err)
+
+	t.Run("no limits", func(t *testing.T) {
+		img := New(v1Img, nil, t.TempDir())
+		require.NoError(t, img.Read())
+		assert.True(t, img.SquashedTree().HasPath(file.Path("/"+strings.Repeat("deep/", 10)+"file")))
+		assert.Zero(t, img.Layers[0].Metadata.TruncatedEntries)
+	})
+
+	t.Run("depth exceeded", func(t *testing.T) {
+		img := New(v1Img, nil, t.TempDir(), WithTreeLimits(TreeLimits{MaxDepth: 5}))
+		err := img.Read()
+
+		var limitErr *ErrTreeLimitExceeded
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, TreeLimitDepth, limitErr.Limit)
+		assert.Equal(t, 5, limitErr.Max)
+	})
+
+	t.Run("directory entries exceeded", func(t *testing.T) {
+		img := New(v1Img, nil, t.TempDir(), WithTreeLimits(TreeLimits{MaxDirectoryEntries: 5}))
+		err := img.Read()
+
+		var limitErr *ErrTreeLimitExceeded
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, TreeLimitDirectoryEntries, limitErr.Limit)
+		assert.Equal(t, "/wide/file-05", limitErr.Path)
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		img := New(v1Img, nil, t.TempDir(), WithTreeLimits(TreeLimits{MaxDepth: 5, MaxDirectoryEntries: 5, Truncate: true}))
+		require.NoError(t, img.Read())
+
+		tree := img.SquashedTree()
+		assert.True(t, tree.HasPath("/etc/os-release"))
+		assert.True(t, tree.HasPath("/wide/file-04"))
+		assert.False(t, tree.HasPath("/wide/file-05"))
+		assert.False(t, tree.HasPath(file.Path("/"+strings.Repeat("deep/", 10)+"file")))
+
+		metadata := img.Layers[0].Metadata
+		assert.Equal(t, 6, metadata.TruncatedEntries)
+		require.Len(t, metadata.TreeLimitViolations, 6)
+		assert.Equal(t, TreeLimitDepth, metadata.TreeLimitViolations[0].Limit)
+		assert.Equal(t, TreeLimitDirectoryEntries, metadata.TreeLimitViolations[1].Limit)
+	})
+
+	t.Run("negative limits", func(t *testing.T) {
+		img := New(v1Img, nil, t.TempDir(), WithTreeLimits(TreeLimits{MaxDepth: -1}))
+		require.Error(t, img.Read())
+	})
+}
+
+func Test_treeLimiter_check(t *testing.T) {
+	limiter := newTreeLimiter(&TreeLimits{MaxDepth: 3, MaxDirectoryEntries: 2}, &LayerMetadata{})
+
+	tests := []struct {
+		path string
+		want TreeLimit
+	}{
+		{path: "a/b/c"},
+		{path: "./a/b/d"},
+		// the same entry may appear more than once within a layer
+		{path: "a/b/c"},
+		{path: "a/b/e", want: TreeLimitDirectoryEntries},
+		{path: "a/x"},
+		// implicit directories count as entries
+		{path: "a/y/z", want: TreeLimitDirectoryEntries},
+		{path: "b/c/d/e", want: TreeLimitDepth},
+		{path: "/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			err := limiter.check(tt.path)
+			if tt.want == "" {
+				assert.Nil(t, err)
+				return
+			}
+			require.NotNil(t, err)
+			assert.Equal(t, tt.want, err.Limit)
+		})
+	}
+
+	assert.Nil(t, newTreeLimiter(nil, nil))
+	assert.Nil(t, newTreeLimiter(&TreeLimits{Truncate: true}, nil))
+}
@@ -0,0 +1,301 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+)
+
+// IDMapping maps a contiguous range of IDs within the container to a range of IDs on the host (mirroring the
+// semantics of /proc/self/uid_map and /etc/subuid).
+type IDMapping struct {
+	ContainerID int
+	HostID      int
+	Size        int
+}
+
+// UnpackOptions configures how an image filesystem is materialized onto the host.
+type UnpackOptions struct {
+	// PreserveOwnership will chown all unpacked paths to the (possibly mapped) owner found in the image. When false
+	// all paths are owned by the current user.
+	PreserveOwnership bool
+	// UIDMappings translates image user IDs to host user IDs (e.g. for rootless use). IDs without a mapping are used as-is.
+	UIDMappings []IDMapping
+	// GIDMappings translates image group IDs to host group IDs (e.g. for rootless use). IDs without a mapping are used as-is.
+	GIDMappings []IDMapping
+}
+
+func mapID(id int, mappings []IDMapping) int {
+	for _, m := range mappings {
+		if id >= m.ContainerID && id < m.ContainerID+m.Size {
+			return m.HostID + (id - m.ContainerID)
+		}
+	}
+	return id
+}
+
+// UnpackRootfs materializes the squashed filesystem of the image to the given destination directory, resulting in a
+// directory that can be used as a chroot. Device files and sockets are not created.
+func (i *Image) UnpackRootfs(dest string, opts UnpackOptions) error {
+	if i.FileCatalog == nil {
+		return fmt.Errorf("image has not been read")
+	}
+	return unpackTree(i.SquashedTree(), i.FileCatalog, dest, opts, nil)
+}
+
+// whiteoutHandler is invoked for whiteout paths instead of writing the whiteout file itself.
+type whiteoutHandler func(target string, p file.Path) error
+
+// unpackTree writes all paths within the given tree to the destination directory. When no whiteout handler is given,
+// whiteout files are written as-is.
+func unpackTree(tree filetree.Reader, catalog FileCatalogReader, dest string, opts UnpackOptions, onWhiteout whiteoutHandler) error {
+	dest, err := filepath.Abs(dest)
+	if err != nil {
+		return fmt.Errorf("unable to resolve destination %q: %w", dest, err)
+	}
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return fmt.Errorf("unable to create destination %q: %w", dest, err)
+	}
+
+	paths := tree.AllRealPaths()
+	sort.Sort(file.Paths(paths))
+
+	u := unpacker{
+		catalog: catalog,
+		dest:    dest,
+		opts:    opts,
+	}
+
+	// hardlinks are created once all regular files are written (since a link may precede its destination in path
+	// order), and directory permissions are applied last so that restrictive modes do not prevent writing child paths
+	var links []file.Reference
+	var dirs []file.Metadata
+	for _, p := range paths {
+		if p == file.DirSeparator {
+			continue
+		}
+
+		if onWhiteout != nil && p.IsWhiteout() {
+			target, err := u.resolve(p)
+			if err != nil {
+				return err
+			}
+			if err := onWhiteout(target, p); err != nil {
+				return fmt.Errorf("unable to handle whiteout %q: %w", p, err)
+			}
+			continue
+		}
+
+		_, res, err := tree.File(p)
+		if err != nil {
+			return fmt.Errorf("unable to resolve path %q: %w", p, err)
+		}
+
+		if res == nil || res.Reference == nil {
+			// implied directories (parents of a path without an entry in the layer tar)
+			target, err := u.resolve(p)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		entry, err := catalog.Get(*res.Reference)
+		if err != nil {
+			return fmt.Errorf("unable to get metadata for %q: %w", p, err)
+		}
+
+		switch entry.Metadata.Type {
+		case file.TypeHardLink:
+			links = append(links, *res.Reference)
+			continue
+		case file.TypeDirectory:
+			dirs = append(dirs, entry.Metadata)
+		}
+
+		if err := u.unpack(*res.Reference, entry.Metadata); err != nil {
+			return fmt.Errorf("unable to unpack %q: %w", p, err)
+		}
+	}
+
+	for _, ref := range links {
+		entry, err := catalog.Get(ref)
+		if err != nil {
+			return fmt.Errorf("unable to get metadata for %q: %w", ref.RealPath, err)
+		}
+		if err := u.unpack(ref, entry.Metadata); err != nil {
+			return fmt.Errorf("unable to unpack %q: %w", ref.RealPath, err)
+		}
+	}
+
+	for idx := len(dirs) - 1; idx >= 0; idx-- {
+		if err := u.finalize(dirs[idx]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type unpacker struct {
+	catalog FileCatalogReader
+	dest    string
+	opts    UnpackOptions
+}
+
+func (u unpacker) target(p file.Path) string {
+	return filepath.Join(u.dest, filepath.FromSlash(string(p)))
+}
+
+func (u unpacker) withinDest(target string) bool {
+	return target == u.dest || strings.HasPrefix(target, u.dest+string(os.PathSeparator))
+}
+
+// resolve returns the host path of the given image path, refusing paths outside of the destination and paths that
+// traverse a symlink already written to the destination (since image symlinks may point anywhere on the host, writing
+// through them could modify files outside of the destination).
+func (u unpacker) resolve(p file.Path) (string, error) {
+	target := u.target(p)
+	if !u.withinDest(target) {
+		return "", fmt.Errorf("path is outside of the destination: %q", p)
+	}
+
+	rel, err := filepath.Rel(u.dest, target)
+	if err != nil || rel == "." {
+		return target, err
+	}
+
+	current := u.dest
+	for _, part := range strings.Split(rel, string(os.PathSeparator)) {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			// nothing below a missing path can exist either (it is created by the unpacker as a real directory)
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("path traverses a symlink within the destination: %q", p)
+		}
+	}
+	return target, nil
+}
+
+func (u unpacker) unpack(ref file.Reference, m file.Metadata) error {
+	target, err := u.resolve(ref.RealPath)
+	if err != nil {
+		return err
+	}
+
+	switch m.Type {
+	case file.TypeDirectory:
+		// note: final permissions are applied after all children are written
+		return os.MkdirAll(target, 0755)
+
+	case file.TypeRegular:
+		if err := u.writeFile(ref, target); err != nil {
+			return err
+		}
+
+	case file.TypeSymLink:
+		if err := os.Symlink(m.LinkDestination, target); err != nil {
+			return err
+		}
+		return u.chown(target, m, true)
+
+	case file.TypeHardLink:
+		// note: the destination may be a symlink itself (which is linked, not followed), but not any of its parents
+		linkPath := file.Path(path.Clean(file.DirSeparator + m.LinkDestination))
+		linkParentPath, err := linkPath.ParentPath()
+		if err != nil {
+			return fmt.Errorf("invalid hardlink destination %q: %w", m.LinkDestination, err)
+		}
+		linkParent, err := u.resolve(linkParentPath)
+		if err != nil {
+			return fmt.Errorf("invalid hardlink destination %q: %w", m.LinkDestination, err)
+		}
+		linkTarget := filepath.Join(linkParent, linkPath.Basename())
+		if err := os.Link(linkTarget, target); err != nil {
+			return err
+		}
+		return nil
+
+	default:
+		log.WithFields("path", ref.RealPath, "type", m.Type).Trace("skipping special file while unpacking rootfs")
+		return nil
+	}
+
+	return u.finalize(m)
+}
+
+func (u unpacker) writeFile(ref file.Reference, target string) error {
+	reader, err := u.catalog.Open(ref)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	fh, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(fh, reader); err != nil {
+		_ = fh.Close()
+		return err
+	}
+
+	return fh.Close()
+}
+
+// finalize applies the ownership, permissions, and timestamps to an already-created path.
+func (u unpacker) finalize(m file.Metadata) error {
+	target, err := u.resolve(file.Path(m.Path))
+	if err != nil {
+		return err
+	}
+	if err := u.chown(target, m, false); err != nil {
+		return err
+	}
+
+	if m.FileInfo == nil {
+		return nil
+	}
+
+	if err := os.Chmod(target, m.Mode().Perm()|(m.Mode()&(os.ModeSetuid|os.ModeSetgid|os.ModeSticky))); err != nil {
+		return err
+	}
+
+	modTime := m.ModTime()
+	return os.Chtimes(target, modTime, modTime)
+}
+
+func (u unpacker) chown(target string, m file.Metadata, isLink bool) error {
+	if !u.opts.PreserveOwnership || m.UserID < 0 || m.GroupID < 0 {
+		return nil
+	}
+
+	uid := mapID(m.UserID, u.opts.UIDMappings)
+	gid := mapID(m.GroupID, u.opts.GIDMappings)
+
+	if isLink {
+		return os.Lchown(target, uid, gid)
+	}
+	return os.Chown(target, uid, gid)
+}
@@ -0,0 +1,147 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_mapID(t *testing.T) {
+	mappings := []IDMapping{
+		{ContainerID: 0, HostID: 1000, Size: 1},
+		{ContainerID: 1, HostID: 100000, Size: 65536},
+	}
+
+	tests := []struct {
+		id   int
+		want int
+	}{
+		{id: 0, want: 1000},
+		{id: 1, want: 100000},
+		{id: 65536, want: 165535},
+		{id: 65537, want: 65537},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, mapID(tt.id, mappings))
+	}
+}
+
+func TestImage_UnpackRootfs(t *testing.T) {
+	img := newTestImage(t, []tar.Header{
+		{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "etc/os-release", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "etc/os-release.link", Typeflag: tar.TypeSymlink, Linkname: "os-release", Mode: 0777},
+		{Name: "etc/os-release.hard", Typeflag: tar.TypeLink, Linkname: "etc/os-release"},
+		{Name: "usr/bin/tool", Typeflag: tar.TypeReg, Mode: 0755},
+		// a hardlink that precedes its destination in path order
+		{Name: "bin/sh", Typeflag: tar.TypeLink, Linkname: "usr/bin/tool"},
+	}, "ID=test\n")
+
+	dest := t.TempDir()
+	require.NoError(t, img.UnpackRootfs(dest, UnpackOptions{}))
+
+	contents, err := os.ReadFile(filepath.Join(dest, "etc", "os-release"))
+	require.NoError(t, err)
+	assert.Equal(t, "ID=test\n", string(contents))
+
+	linkDest, err := os.Readlink(filepath.Join(dest, "etc", "os-release.link"))
+	require.NoError(t, err)
+	assert.Equal(t, "os-release", linkDest)
+
+	hard, err := os.ReadFile(filepath.Join(dest, "etc", "os-release.hard"))
+	require.NoError(t, err)
+	assert.Equal(t, "ID=test\n", string(hard))
+
+	shInfo, err := os.Stat(filepath.Join(dest, "bin", "sh"))
+	require.NoError(t, err)
+	toolInfo, err := os.Stat(filepath.Join(dest, "usr", "bin", "tool"))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(shInfo, toolInfo))
+
+	info, err := os.Stat(filepath.Join(dest, "usr", "bin", "tool"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+}
+
+// newTestImage creates a single layer image from the given tar headers, where all regular files have the given contents.
+func newTestImage(t *testing.T, headers []tar.Header, contents string) *Image {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, h := range headers {
+		h := h
+		if h.Typeflag == tar.TypeReg {
+			h.Size = int64(len(contents))
+		}
+		require.NoError(t, tw.WriteHeader(&h))
+		if h.Size > 0 {
+			_, err := tw.Write([]byte(contents))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	})
+	require.NoError(t, err)
+
+	v1Img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+
+	img := New(v1Img, nil, t.TempDir())
+	require.NoError(t, img.Read())
+	return img
+}
+
+func TestImage_UnpackRootfs_symlinkEscape(t *testing.T) {
+	outside := t.TempDir()
+
+	tests := []struct {
+		name    string
+		headers []tar.Header
+	}{
+		{
+			name: "file beneath a symlink to outside of the destination",
+			headers: []tar.Header{
+				{Name: "a", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0777},
+				{Name: "a/evil", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+		},
+		{
+			name: "file beneath a relative symlink to outside of the destination",
+			headers: []tar.Header{
+				{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "../../../../../../../../../../.." + outside, Mode: 0777},
+				{Name: "a/evil", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+		},
+		{
+			name: "directory beneath a symlink to outside of the destination",
+			headers: []tar.Header{
+				{Name: "a", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0777},
+				{Name: "a/evil/", Typeflag: tar.TypeDir, Mode: 0755},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := newTestImage(t, tt.headers, "owned\n")
+
+			require.Error(t, img.UnpackRootfs(t.TempDir(), UnpackOptions{}))
+
+			entries, err := os.ReadDir(outside)
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
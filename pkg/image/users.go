@@ -0,0 +1,267 @@
+package image
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/filetree"
+)
+
+const (
+	passwdPath = "/etc/passwd"
+	groupPath  = "/etc/group"
+
+	// maxUserDatabaseFileSize bounds how much of the passwd and group files are read
+	maxUserDatabaseFileSize = 4 * 1024 * 1024
+)
+
+// User is a single entry within an /etc/passwd file (see passwd(5)).
+type User struct {
+	Name string
+	UID  int
+	// GID is the primary group of the user
+	GID   int
+	GECOS string
+	Home  string
+	Shell string
+}
+
+// Group is a single entry within an /etc/group file (see group(5)).
+type Group struct {
+	Name string
+	GID  int
+	// Members are the names of users that are (supplementary) members of the group
+	Members []string
+}
+
+// UserDatabase is the set of users and groups defined within a (squashed) tree, from /etc/passwd and /etc/group.
+// Entries are kept in file order, and lookups return the first matching entry (as getpwnam(3) and friends do).
+type UserDatabase struct {
+	Users  []User
+	Groups []Group
+}
+
+// UserDatabase reads the users and groups defined within the image squash tree (see ReadUserDatabase).
+func (i *Image) UserDatabase() (*UserDatabase, error) {
+	if i.FileCatalog == nil {
+		return nil, fmt.Errorf("image has not been read")
+	}
+	return ReadUserDatabase(i.SquashedTree(), i.FileCatalog)
+}
+
+// ConfigUser resolves the user the image config is set to run as (the USER directive) to a uid and gid using the users
+// and groups defined within the image squash tree (see UserDatabase.Resolve).
+func (i *Image) ConfigUser() (uid, gid int, err error) {
+	db, err := i.UserDatabase()
+	if err != nil {
+		return 0, 0, err
+	}
+	return db.Resolve(i.Metadata.Config.Config.User)
+}
+
+// ReadUserDatabase parses /etc/passwd and /etc/group within the given tree (following links). Missing files result in
+// no users or groups (not an error), and malformed lines (as well as NIS "+" and "-" entries) are skipped.
+func ReadUserDatabase(tree filetree.Reader, catalog FileCatalogReader) (*UserDatabase, error) {
+	var db UserDatabase
+
+	err := readUserDatabaseFile(tree, catalog, passwdPath, func(fields []string) {
+		if u, ok := parsePasswdEntry(fields); ok {
+			db.Users = append(db.Users, u)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readUserDatabaseFile(tree, catalog, groupPath, func(fields []string) {
+		if g, ok := parseGroupEntry(fields); ok {
+			db.Groups = append(db.Groups, g)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &db, nil
+}
+
+// UserByName returns the first user with the given name (nil when not found).
+func (db *UserDatabase) UserByName(name string) *User {
+	for idx := range db.Users {
+		if db.Users[idx].Name == name {
+			return &db.Users[idx]
+		}
+	}
+	return nil
+}
+
+// UserByID returns the first user with the given uid (nil when not found).
+func (db *UserDatabase) UserByID(uid int) *User {
+	for idx := range db.Users {
+		if db.Users[idx].UID == uid {
+			return &db.Users[idx]
+		}
+	}
+	return nil
+}
+
+// GroupByName returns the first group with the given name (nil when not found).
+func (db *UserDatabase) GroupByName(name string) *Group {
+	for idx := range db.Groups {
+		if db.Groups[idx].Name == name {
+			return &db.Groups[idx]
+		}
+	}
+	return nil
+}
+
+// GroupByID returns the first group with the given gid (nil when not found).
+func (db *UserDatabase) GroupByID(gid int) *Group {
+	for idx := range db.Groups {
+		if db.Groups[idx].GID == gid {
+			return &db.Groups[idx]
+		}
+	}
+	return nil
+}
+
+// Owner returns the user and group names that own the file with the given metadata. Owners without an entry in the
+// database are reported as the numeric ID (as "ls -l" does).
+func (db *UserDatabase) Owner(metadata file.Metadata) (user, group string) {
+	user = strconv.Itoa(metadata.UserID)
+	if u := db.UserByID(metadata.UserID); u != nil {
+		user = u.Name
+	}
+
+	group = strconv.Itoa(metadata.GroupID)
+	if g := db.GroupByID(metadata.GroupID); g != nil {
+		group = g.Name
+	}
+	return user, group
+}
+
+// Resolve resolves a user specification as found in the USER directive of an image config ("user", "user:group",
+// "uid", or "uid:gid", where names and numeric IDs may be mixed) to a uid and gid. When no group is given the primary
+// group of the user is used (or gid 0 for numeric uids without an entry in the database). An empty specification is
+// root. Names that are not within the database result in an error.
+func (db *UserDatabase) Resolve(spec string) (uid, gid int, err error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return 0, 0, nil
+	}
+
+	userSpec, groupSpec, hasGroup := strings.Cut(spec, ":")
+
+	var user *User
+	if id, err := strconv.Atoi(userSpec); err == nil {
+		uid = id
+		user = db.UserByID(id)
+	} else {
+		if user = db.UserByName(userSpec); user == nil {
+			return 0, 0, fmt.Errorf("unable to find user %q", userSpec)
+		}
+		uid = user.UID
+	}
+
+	switch {
+	case hasGroup && groupSpec != "":
+		if id, err := strconv.Atoi(groupSpec); err == nil {
+			gid = id
+		} else {
+			group := db.GroupByName(groupSpec)
+			if group == nil {
+				return 0, 0, fmt.Errorf("unable to find group %q", groupSpec)
+			}
+			gid = group.GID
+		}
+	case user != nil:
+		gid = user.GID
+	}
+
+	if uid < 0 || gid < 0 {
+		return 0, 0, fmt.Errorf("invalid user specification %q", spec)
+	}
+	return uid, gid, nil
+}
+
+// readUserDatabaseFile calls the given function with the colon-separated fields of each non-empty, non-comment line
+// within the regular file at the given path (when it exists).
+func readUserDatabaseFile(tree filetree.Reader, catalog FileCatalogReader, p string, fn func(fields []string)) error {
+	ref, ok := regularFileReference(tree, catalog, p)
+	if !ok {
+		return nil
+	}
+
+	reader, err := catalog.Open(*ref)
+	if err != nil {
+		return fmt.Errorf("unable to read %q: %w", p, err)
+	}
+	defer reader.Close()
+
+	scanner := bufio.NewScanner(io.LimitReader(reader, maxUserDatabaseFileSize))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxUserDatabaseFileSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			continue
+		}
+		fn(strings.Split(line, ":"))
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("unable to read %q: %w", p, err)
+	}
+	return nil
+}
+
+// parsePasswdEntry parses the fields of a passwd line: name:password:uid:gid:gecos:home:shell
+func parsePasswdEntry(fields []string) (User, bool) {
+	if len(fields) != 7 || fields[0] == "" {
+		return User{}, false
+	}
+
+	uid, err := strconv.Atoi(fields[2])
+	if err != nil || uid < 0 {
+		return User{}, false
+	}
+
+	gid, err := strconv.Atoi(fields[3])
+	if err != nil || gid < 0 {
+		return User{}, false
+	}
+
+	return User{
+		Name:  fields[0],
+		UID:   uid,
+		GID:   gid,
+		GECOS: fields[4],
+		Home:  fields[5],
+		Shell: fields[6],
+	}, true
+}
+
+// parseGroupEntry parses the fields of a group line: name:password:gid:member,member,...
+func parseGroupEntry(fields []string) (Group, bool) {
+	if len(fields) != 4 || fields[0] == "" {
+		return Group{}, false
+	}
+
+	gid, err := strconv.Atoi(fields[2])
+	if err != nil || gid < 0 {
+		return Group{}, false
+	}
+
+	g := Group{
+		Name: fields[0],
+		GID:  gid,
+	}
+	for _, member := range strings.Split(fields[3], ",") {
+		if member = strings.TrimSpace(member); member != "" {
+			g.Members = append(g.Members, member)
+		}
+	}
+	return g, true
+}
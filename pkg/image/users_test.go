@@ -0,0 +1,132 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+const (
+	testPasswd = `root:x:0:0:root:/root:/bin/bash
+# comment
+daemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin
+
+app:x:1000:1001:App User,,,:/home/app:/bin/sh
+malformed:x:notanumber:1:::
++nis
+`
+	testGroup = `root:x:0:
+daemon:x:1:
+staff:x:50:app, daemon
+app:x:1001:
+`
+)
+
+func TestImage_UserDatabase(t *testing.T) {
+	v1Img, err := mutate.AppendLayers(empty.Image, tarLayer(t, map[string]string{
+		"etc/passwd": testPasswd,
+		"etc/group":  testGroup,
+	}))
+	require.NoError(t, err)
+
+	img := New(v1Img, nil, t.TempDir())
+
+	_, err = img.UserDatabase()
+	require.Error(t, err)
+
+	require.NoError(t, img.Read())
+
+	db, err := img.UserDatabase()
+	require.NoError(t, err)
+
+	assert.Equal(t, []User{
+		{Name: "root", UID: 0, GID: 0, GECOS: "root", Home: "/root", Shell: "/bin/bash"},
+		{Name: "daemon", UID: 1, GID: 1, GECOS: "daemon", Home: "/usr/sbin", Shell: "/usr/sbin/nologin"},
+		{Name: "app", UID: 1000, GID: 1001, GECOS: "App User,,,", Home: "/home/app", Shell: "/bin/sh"},
+	}, db.Users)
+
+	assert.Equal(t, []Group{
+		{Name: "root", GID: 0},
+		{Name: "daemon", GID: 1},
+		{Name: "staff", GID: 50, Members: []string{"app", "daemon"}},
+		{Name: "app", GID: 1001},
+	}, db.Groups)
+
+	user, group := db.Owner(file.Metadata{UserID: 1000, GroupID: 50})
+	assert.Equal(t, "app", user)
+	assert.Equal(t, "staff", group)
+
+	user, group = db.Owner(file.Metadata{UserID: 4242, GroupID: 4343})
+	assert.Equal(t, "4242", user)
+	assert.Equal(t, "4343", group)
+}
+
+func TestImage_UserDatabase_missingFiles(t *testing.T) {
+	v1Img, err := mutate.AppendLayers(empty.Image, tarLayer(t, map[string]string{"app/main": "binary"}))
+	require.NoError(t, err)
+
+	img := New(v1Img, nil, t.TempDir())
+	require.NoError(t, img.Read())
+
+	db, err := img.UserDatabase()
+	require.NoError(t, err)
+	assert.Empty(t, db.Users)
+	assert.Empty(t, db.Groups)
+
+	uid, gid, err := img.ConfigUser()
+	require.NoError(t, err)
+	assert.Equal(t, 0, uid)
+	assert.Equal(t, 0, gid)
+}
+
+func TestUserDatabase_Resolve(t *testing.T) {
+	db := &UserDatabase{
+		Users: []User{
+			{Name: "root", UID: 0, GID: 0},
+			{Name: "app", UID: 1000, GID: 1001},
+		},
+		Groups: []Group{
+			{Name: "root", GID: 0},
+			{Name: "staff", GID: 50},
+		},
+	}
+
+	tests := []struct {
+		spec    string
+		wantUID int
+		wantGID int
+		wantErr require.ErrorAssertionFunc
+	}{
+		{spec: "", wantUID: 0, wantGID: 0},
+		{spec: "app", wantUID: 1000, wantGID: 1001},
+		{spec: "1000", wantUID: 1000, wantGID: 1001},
+		{spec: "app:staff", wantUID: 1000, wantGID: 50},
+		{spec: "app:77", wantUID: 1000, wantGID: 77},
+		{spec: "app:", wantUID: 1000, wantGID: 1001},
+		{spec: "4242", wantUID: 4242, wantGID: 0},
+		{spec: "4242:4343", wantUID: 4242, wantGID: 4343},
+		{spec: "nobody", wantErr: require.Error},
+		{spec: "app:nogroup", wantErr: require.Error},
+		{spec: "-1", wantErr: require.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+			uid, gid, err := db.Resolve(tt.spec)
+			tt.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tt.wantUID, uid)
+			assert.Equal(t, tt.wantGID, gid)
+		})
+	}
+}
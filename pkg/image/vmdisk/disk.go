@@ -0,0 +1,81 @@
+//go:build vmdisk
+
+package vmdisk
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// disk is a random-access view of the (virtual) disk contents.
+type disk interface {
+	io.ReaderAt
+	Size() int64
+}
+
+type rawDisk struct {
+	*os.File
+	size int64
+}
+
+func (d rawDisk) Size() int64 {
+	return d.size
+}
+
+// openDisk opens the disk image at the given path, detecting the image format (qcow2 or raw).
+func openDisk(path string) (disk, io.Closer, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to open disk image: %w", err)
+	}
+
+	magic := make([]byte, len(qcow2Magic))
+	if _, err := fh.ReadAt(magic, 0); err != nil && err != io.EOF {
+		fh.Close()
+		return nil, nil, fmt.Errorf("unable to read disk image: %w", err)
+	}
+
+	info, err := fh.Stat()
+	if err != nil {
+		fh.Close()
+		return nil, nil, err
+	}
+
+	if bytes.Equal(magic, qcow2Magic) {
+		d, err := newQcow2Disk(fh, info.Size())
+		if err != nil {
+			fh.Close()
+			return nil, nil, err
+		}
+		return d, fh, nil
+	}
+
+	return rawDisk{File: fh, size: info.Size()}, fh, nil
+}
+
+// section is a disk region (e.g. a single partition).
+type section struct {
+	disk
+	offset int64
+	size   int64
+}
+
+func (s section) ReadAt(p []byte, off int64) (int, error) {
+	if off >= s.size {
+		return 0, io.EOF
+	}
+	if remaining := s.size - off; int64(len(p)) > remaining {
+		n, err := s.disk.ReadAt(p[:remaining], s.offset+off)
+		if err == nil {
+			err = io.EOF
+		}
+		return n, err
+	}
+	return s.disk.ReadAt(p, s.offset+off)
+}
+
+func (s section) Size() int64 {
+	return s.size
+}
@@ -0,0 +1,156 @@
+//go:build vmdisk
+
+package vmdisk
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+const Disk image.Source = image.VMDiskSource
+
+// rootMarkers are paths that indicate that a filesystem is the root filesystem (as opposed to /boot, /home, etc).
+var rootMarkers = []string{"etc", "usr"}
+
+// NewDiskProvider creates a new provider for VM disk images (qcow2 or raw). The partition table (MBR or GPT) is read
+// and the root filesystem (ext2/3/4 or xfs) is represented as a single layer image.
+func NewDiskProvider(tmpDirGen *file.TempDirGenerator, path string) image.Provider {
+	return &diskImageProvider{
+		tmpDirGen: tmpDirGen,
+		path:      path,
+	}
+}
+
+// diskImageProvider is an image.Provider for the root filesystem within a VM disk image.
+type diskImageProvider struct {
+	tmpDirGen *file.TempDirGenerator
+	path      string
+}
+
+func (p *diskImageProvider) Name() string {
+	return Disk
+}
+
+// Provide an image object that represents the root filesystem of the VM disk image at the configured path.
+func (p *diskImageProvider) Provide(ctx context.Context) (*image.Image, error) {
+	d, closer, err := openDisk(p.path)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	fs, err := findRootFilesystem(d)
+	if err != nil {
+		return nil, err
+	}
+
+	tempDir, err := p.tmpDirGen.NewDirectory("vm-disk")
+	if err != nil {
+		return nil, err
+	}
+
+	layerPath := filepath.Join(tempDir, "rootfs.tar")
+	if err := writeLayer(fs, layerPath); err != nil {
+		return nil, err
+	}
+
+	img, err := image.NewSingleLayerImage(layerPath, v1.ConfigFile{OS: "linux"})
+	if err != nil {
+		return nil, err
+	}
+
+	contentTempDir, err := p.tmpDirGen.NewDirectory("vm-disk-image")
+	if err != nil {
+		return nil, err
+	}
+
+	out := image.New(img, p.tmpDirGen, contentTempDir, image.WithOS("linux"))
+	if err := out.ReadContext(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// findRootFilesystem returns the filesystem that looks like a root filesystem, falling back to the first readable
+// filesystem found.
+func findRootFilesystem(d disk) (filesystem, error) {
+	partitions, err := readPartitions(d)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []filesystem
+	var errs error
+	for _, part := range partitions {
+		fs, err := openFilesystem(section{disk: d, offset: part.Offset, size: part.Size})
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("partition %d: %w", part.Index, err))
+			continue
+		}
+		if isRootFilesystem(fs) {
+			log.WithFields("partition", part.Index, "filesystem", fs.Type()).Debug("found root filesystem in VM disk")
+			return fs, nil
+		}
+		candidates = append(candidates, fs)
+	}
+
+	if len(candidates) > 0 {
+		return candidates[0], nil
+	}
+
+	return nil, fmt.Errorf("no supported filesystems found in disk image: %w", errs)
+}
+
+var errUnsupportedFilesystem = fmt.Errorf("unsupported filesystem (only ext2/3/4 and xfs are supported)")
+
+func openFilesystem(r io.ReaderAt) (filesystem, error) {
+	switch {
+	case isExt4(r):
+		return newExt4(r)
+	case isXFS(r):
+		return newXFS(r)
+	}
+	return nil, errUnsupportedFilesystem
+}
+
+func isRootFilesystem(fs filesystem) bool {
+	entries, err := fs.ReadDir(fs.Root())
+	if err != nil {
+		return false
+	}
+
+	found := 0
+	for _, e := range entries {
+		for _, marker := range rootMarkers {
+			if e.Name == marker {
+				found++
+			}
+		}
+	}
+	return found == len(rootMarkers)
+}
+
+func writeLayer(fs filesystem, layerPath string) error {
+	fh, err := os.Create(layerPath)
+	if err != nil {
+		return fmt.Errorf("unable to create rootfs layer: %w", err)
+	}
+	defer fh.Close()
+
+	tw := tar.NewWriter(fh)
+	if err := writeTar(fs, tw); err != nil {
+		return fmt.Errorf("unable to read %s filesystem: %w", fs.Type(), err)
+	}
+	return tw.Close()
+}
@@ -0,0 +1,186 @@
+//go:build vmdisk
+
+package vmdisk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+)
+
+func TestDiskProvider_Provide(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		disk    func(t *testing.T, fs []byte) []byte
+	}{
+		{
+			name:    "ext4 without partition table",
+			fixture: "ext4.img.gz",
+			disk: func(_ *testing.T, fs []byte) []byte {
+				return fs
+			},
+		},
+		{
+			name:    "ext4 in MBR partition",
+			fixture: "ext4.img.gz",
+			disk:    mbrDisk,
+		},
+		{
+			name:    "ext2 in GPT partition",
+			fixture: "ext2.img.gz",
+			disk:    gptDisk,
+		},
+		{
+			name:    "ext4 in MBR partition in qcow2",
+			fixture: "ext4.img.gz",
+			disk: func(t *testing.T, fs []byte) []byte {
+				return qcow2Image(t, mbrDisk(t, fs))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "disk.img")
+			require.NoError(t, os.WriteFile(path, tt.disk(t, readFixture(t, tt.fixture)), 0600))
+
+			tmpDirGen := file.NewTempDirGenerator("tempDir")
+			t.Cleanup(func() { _ = tmpDirGen.Cleanup() })
+
+			img, err := NewDiskProvider(tmpDirGen, path).Provide(context.Background())
+			require.NoError(t, err)
+			require.Len(t, img.Layers, 1)
+
+			reader, err := img.OpenPathFromSquash("/etc/os-release")
+			require.NoError(t, err)
+			contents, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, "ID=test\nVERSION_ID=1.0\n", string(contents))
+
+			// the linked file is read through the hardlink
+			reader, err = img.OpenPathFromSquash("/usr/bin/tool-hardlink")
+			require.NoError(t, err)
+			tool, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Greater(t, len(tool), 20000)
+
+			reader, err = img.OpenPathFromSquash("/usr/os-release")
+			require.NoError(t, err)
+			contents, err = io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, "ID=test\nVERSION_ID=1.0\n", string(contents))
+
+			_, res, err := img.SquashedTree().File("/var/long-link")
+			require.NoError(t, err)
+			require.True(t, res.HasReference())
+			entry, err := img.FileCatalog.Get(*res.Reference)
+			require.NoError(t, err)
+			assert.Equal(t, "/var/lib/deep/nested/this/is/a/very/long/symlink/target/that/exceeds/sixty/bytes", entry.LinkDestination)
+
+			assert.True(t, img.SquashedTree().HasPath("/var/lib/deep/nested/file.txt"))
+			for _, name := range []string{"f1", "f50", "f100"} {
+				assert.True(t, img.SquashedTree().HasPath(file.Path("/var/lib/deep/"+name)), name)
+			}
+		})
+	}
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	fh, err := os.Open(filepath.Join("test-fixtures", name))
+	require.NoError(t, err)
+	defer fh.Close()
+
+	gr, err := gzip.NewReader(fh)
+	require.NoError(t, err)
+	contents, err := io.ReadAll(gr)
+	require.NoError(t, err)
+	return contents
+}
+
+const partitionStartLBA = 2048
+
+// mbrDisk creates a disk image with a single MBR partition containing the given filesystem.
+func mbrDisk(_ *testing.T, fs []byte) []byte {
+	d := make([]byte, partitionStartLBA*sectorSize+len(fs))
+	copy(d[partitionStartLBA*sectorSize:], fs)
+
+	entry := d[446:462]
+	entry[4] = 0x83
+	binary.LittleEndian.PutUint32(entry[8:12], partitionStartLBA)
+	binary.LittleEndian.PutUint32(entry[12:16], uint32(len(fs)/sectorSize))
+	d[510], d[511] = 0x55, 0xaa
+	return d
+}
+
+// gptDisk creates a disk image with a protective MBR and a GPT with a single partition containing the given filesystem.
+func gptDisk(_ *testing.T, fs []byte) []byte {
+	d := make([]byte, partitionStartLBA*sectorSize+len(fs))
+	copy(d[partitionStartLBA*sectorSize:], fs)
+
+	le := binary.LittleEndian
+	d[446+4] = mbrProtectiveGPT
+	d[510], d[511] = 0x55, 0xaa
+
+	header := d[sectorSize : 2*sectorSize]
+	copy(header[0:8], gptSignature)
+	le.PutUint64(header[72:80], 2)
+	le.PutUint32(header[80:84], 128)
+	le.PutUint32(header[84:88], 128)
+
+	entry := d[2*sectorSize : 2*sectorSize+128]
+	copy(entry[0:16], bytes.Repeat([]byte{0xaf}, 16))
+	le.PutUint64(entry[32:40], partitionStartLBA)
+	le.PutUint64(entry[40:48], uint64(partitionStartLBA+len(fs)/sectorSize-1))
+	return d
+}
+
+// qcow2Image creates a (version 2, uncompressed) qcow2 image of the given raw disk contents.
+func qcow2Image(_ *testing.T, raw []byte) []byte {
+	const clusterBits = 16
+	const clusterSize = 1 << clusterBits
+
+	clusters := (len(raw) + clusterSize - 1) / clusterSize
+	l2Entries := clusterSize / 8
+	l2Tables := (clusters + l2Entries - 1) / l2Entries
+
+	// layout: header, L1 table, L2 tables, data clusters
+	l1Offset := clusterSize
+	l2Offset := 2 * clusterSize
+	dataOffset := l2Offset + l2Tables*clusterSize
+
+	out := make([]byte, dataOffset+clusters*clusterSize)
+	be := binary.BigEndian
+	copy(out[0:4], qcow2Magic)
+	be.PutUint32(out[4:8], 2)
+	be.PutUint32(out[20:24], clusterBits)
+	be.PutUint64(out[24:32], uint64(len(raw)))
+	be.PutUint32(out[36:40], uint32(l2Tables))
+	be.PutUint64(out[40:48], uint64(l1Offset))
+
+	for i := 0; i < l2Tables; i++ {
+		be.PutUint64(out[l1Offset+i*8:], uint64(l2Offset+i*clusterSize))
+	}
+
+	for i := 0; i < clusters; i++ {
+		chunk := raw[i*clusterSize : min((i+1)*clusterSize, len(raw))]
+		if isZero(chunk) {
+			// leave unallocated (reads as zeros)
+			continue
+		}
+		offset := dataOffset + i*clusterSize
+		copy(out[offset:], chunk)
+		be.PutUint64(out[l2Offset+i*8:], uint64(offset))
+	}
+	return out
+}
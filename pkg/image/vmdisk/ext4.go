@@ -0,0 +1,435 @@
+//go:build vmdisk
+
+package vmdisk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	ext4SuperblockOffset = 1024
+	ext4Magic            = 0xef53
+	ext4RootInode        = 2
+
+	ext4IncompatFileType = 0x2
+	ext4Incompat64Bit    = 0x80
+
+	ext4InodeFlagExtents    = 0x80000
+	ext4InodeFlagInlineData = 0x10000000
+
+	ext4ExtentMagic = 0xf30a
+	ext4BlockPtrs   = 15
+)
+
+// ext4 is a read-only ext2/3/4 filesystem reader.
+type ext4 struct {
+	r               io.ReaderAt
+	blockSize       int64
+	inodesPerGroup  uint32
+	inodeSize       int64
+	descSize        int64
+	firstDataBlock  uint32
+	incompat        uint32
+	groupDescCache  map[uint32]int64
+	groupDescOffset int64
+}
+
+func isExt4(r io.ReaderAt) bool {
+	magic := make([]byte, 2)
+	if _, err := r.ReadAt(magic, ext4SuperblockOffset+56); err != nil {
+		return false
+	}
+	return binary.LittleEndian.Uint16(magic) == ext4Magic
+}
+
+func newExt4(r io.ReaderAt) (*ext4, error) {
+	sb := make([]byte, 1024)
+	if _, err := r.ReadAt(sb, ext4SuperblockOffset); err != nil {
+		return nil, fmt.Errorf("unable to read ext4 superblock: %w", err)
+	}
+
+	le := binary.LittleEndian
+	if le.Uint16(sb[56:58]) != ext4Magic {
+		return nil, fmt.Errorf("invalid ext4 superblock magic")
+	}
+
+	logBlockSize := le.Uint32(sb[24:28])
+	if logBlockSize > 6 {
+		return nil, fmt.Errorf("invalid ext4 block size (log=%d)", logBlockSize)
+	}
+
+	fs := &ext4{
+		r:              r,
+		blockSize:      int64(1024) << logBlockSize,
+		firstDataBlock: le.Uint32(sb[20:24]),
+		inodesPerGroup: le.Uint32(sb[40:44]),
+		inodeSize:      128,
+		descSize:       32,
+		incompat:       le.Uint32(sb[96:100]),
+		groupDescCache: make(map[uint32]int64),
+	}
+
+	if revision := le.Uint32(sb[76:80]); revision >= 1 {
+		fs.inodeSize = int64(le.Uint16(sb[88:90]))
+	}
+
+	if fs.incompat&ext4Incompat64Bit != 0 {
+		if size := int64(le.Uint16(sb[254:256])); size >= 64 {
+			fs.descSize = size
+		}
+	}
+
+	if fs.inodesPerGroup == 0 || fs.inodeSize < 128 {
+		return nil, fmt.Errorf("invalid ext4 superblock (inodes per group=%d, inode size=%d)", fs.inodesPerGroup, fs.inodeSize)
+	}
+
+	fs.groupDescOffset = int64(fs.firstDataBlock+1) * fs.blockSize
+
+	return fs, nil
+}
+
+func (fs *ext4) Type() string {
+	return "ext4"
+}
+
+func (fs *ext4) Root() uint64 {
+	return ext4RootInode
+}
+
+// inodeTable returns the byte offset of the inode table for the given block group.
+func (fs *ext4) inodeTable(group uint32) (int64, error) {
+	if offset, ok := fs.groupDescCache[group]; ok {
+		return offset, nil
+	}
+
+	desc := make([]byte, fs.descSize)
+	if _, err := fs.r.ReadAt(desc, fs.groupDescOffset+int64(group)*fs.descSize); err != nil {
+		return 0, fmt.Errorf("unable to read group descriptor %d: %w", group, err)
+	}
+
+	le := binary.LittleEndian
+	block := uint64(le.Uint32(desc[8:12]))
+	if fs.descSize >= 64 {
+		block |= uint64(le.Uint32(desc[40:44])) << 32
+	}
+
+	offset := int64(block) * fs.blockSize
+	fs.groupDescCache[group] = offset
+	return offset, nil
+}
+
+type ext4Inode struct {
+	inode
+	flags  uint32
+	blocks []byte
+}
+
+func (fs *ext4) readInode(ino uint64) (*ext4Inode, error) {
+	if ino == 0 {
+		return nil, fmt.Errorf("invalid inode 0")
+	}
+
+	index := uint32(ino - 1)
+	table, err := fs.inodeTable(index / fs.inodesPerGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, 128)
+	if _, err := fs.r.ReadAt(raw, table+int64(index%fs.inodesPerGroup)*fs.inodeSize); err != nil {
+		return nil, fmt.Errorf("unable to read inode %d: %w", ino, err)
+	}
+
+	le := binary.LittleEndian
+	size := int64(uint64(le.Uint32(raw[4:8])) | uint64(le.Uint32(raw[108:112]))<<32)
+	if size < 0 {
+		return nil, fmt.Errorf("invalid inode %d size: %d", ino, size)
+	}
+
+	return &ext4Inode{
+		inode: inode{
+			Mode:  le.Uint16(raw[0:2]),
+			UID:   uint32(le.Uint16(raw[2:4])) | uint32(le.Uint16(raw[120:122]))<<16,
+			GID:   uint32(le.Uint16(raw[24:26])) | uint32(le.Uint16(raw[122:124]))<<16,
+			Size:  size,
+			Nlink: uint32(le.Uint16(raw[26:28])),
+			Mtime: time.Unix(int64(int32(le.Uint32(raw[16:20]))), 0).UTC(),
+		},
+		flags:  le.Uint32(raw[32:36]),
+		blocks: raw[40:100],
+	}, nil
+}
+
+func (fs *ext4) Stat(ino uint64) (*inode, error) {
+	in, err := fs.readInode(ino)
+	if err != nil {
+		return nil, err
+	}
+	return &in.inode, nil
+}
+
+func (fs *ext4) Open(ino uint64) (io.Reader, error) {
+	in, err := fs.readInode(ino)
+	if err != nil {
+		return nil, err
+	}
+	return fs.open(in)
+}
+
+// inlineData returns the inline data of the given inode.
+func (fs *ext4) inlineData(in *ext4Inode) ([]byte, error) {
+	// note: only the portion of inline data within the inode block area is supported (not the xattr continuation)
+	if in.Size > int64(len(in.blocks)) {
+		return nil, fmt.Errorf("inline data size %d exceeds the inode block area", in.Size)
+	}
+	return in.blocks[:in.Size], nil
+}
+
+// fileExtents returns the extents of the given (non-inline) inode.
+func (fs *ext4) fileExtents(in *ext4Inode) ([]extent, error) {
+	if in.flags&ext4InodeFlagExtents != 0 {
+		return fs.extents(in.blocks, 0)
+	}
+	return fs.blockMap(in.blocks, in.Size)
+}
+
+func (fs *ext4) open(in *ext4Inode) (io.Reader, error) {
+	if in.flags&ext4InodeFlagInlineData != 0 {
+		data, err := fs.inlineData(in)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(data), nil
+	}
+
+	extents, err := fs.fileExtents(in)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.extentReader(in, extents), nil
+}
+
+// readAll reads the full contents of the given directory or symlink inode, which may not extend beyond its extents
+// (unlike regular files, these are never sparse, and are read into memory).
+func (fs *ext4) readAll(in *ext4Inode) ([]byte, error) {
+	if in.flags&ext4InodeFlagInlineData != 0 {
+		return fs.inlineData(in)
+	}
+
+	extents, err := fs.fileExtents(in)
+	if err != nil {
+		return nil, err
+	}
+
+	if mapped := extentsEnd(extents) * fs.blockSize; in.Size > mapped {
+		return nil, fmt.Errorf("inode size %d exceeds the size of its extents (%d)", in.Size, mapped)
+	}
+	return io.ReadAll(fs.extentReader(in, extents))
+}
+
+func (fs *ext4) extentReader(in *ext4Inode, extents []extent) *extentReader {
+	return &extentReader{
+		r:         fs.r,
+		blockSize: fs.blockSize,
+		extents:   extents,
+		size:      in.Size,
+		physical: func(block uint64) int64 {
+			return int64(block) * fs.blockSize
+		},
+	}
+}
+
+// extents reads the extent tree rooted at the given node.
+func (fs *ext4) extents(node []byte, depth int) ([]extent, error) {
+	if depth > 5 {
+		return nil, fmt.Errorf("ext4 extent tree is too deep")
+	}
+
+	le := binary.LittleEndian
+	if len(node) < 12 || le.Uint16(node[0:2]) != ext4ExtentMagic {
+		return nil, fmt.Errorf("invalid ext4 extent header")
+	}
+
+	entries := int(le.Uint16(node[2:4]))
+	treeDepth := le.Uint16(node[6:8])
+	if 12+entries*12 > len(node) {
+		return nil, fmt.Errorf("invalid ext4 extent entry count: %d", entries)
+	}
+
+	var extents []extent
+	for i := 0; i < entries; i++ {
+		raw := node[12+i*12 : 24+i*12]
+		if treeDepth == 0 {
+			length := uint64(le.Uint16(raw[4:6]))
+			unwritten := false
+			if length > 32768 {
+				length -= 32768
+				unwritten = true
+			}
+			extents = append(extents, extent{
+				Logical:   uint64(le.Uint32(raw[0:4])),
+				Physical:  uint64(le.Uint16(raw[6:8]))<<32 | uint64(le.Uint32(raw[8:12])),
+				Length:    length,
+				Unwritten: unwritten,
+			})
+			continue
+		}
+
+		leaf := uint64(le.Uint16(raw[8:10]))<<32 | uint64(le.Uint32(raw[4:8]))
+		child := make([]byte, fs.blockSize)
+		if _, err := fs.r.ReadAt(child, int64(leaf)*fs.blockSize); err != nil {
+			return nil, fmt.Errorf("unable to read ext4 extent block: %w", err)
+		}
+		childExtents, err := fs.extents(child, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		extents = append(extents, childExtents...)
+	}
+	return extents, nil
+}
+
+// blockMap reads the (ext2/3 style) direct and indirect block pointers for a file of the given size.
+func (fs *ext4) blockMap(raw []byte, size int64) ([]extent, error) {
+	le := binary.LittleEndian
+	total := uint64((size + fs.blockSize - 1) / fs.blockSize)
+	perBlock := uint64(fs.blockSize / 4)
+
+	var extents []extent
+	var logical uint64
+	add := func(physical uint64) {
+		if physical != 0 {
+			if n := len(extents); n > 0 && extents[n-1].Logical+extents[n-1].Length == logical && extents[n-1].Physical+extents[n-1].Length == physical {
+				extents[n-1].Length++
+			} else {
+				extents = append(extents, extent{Logical: logical, Physical: physical, Length: 1})
+			}
+		}
+		logical++
+	}
+
+	var indirect func(block uint64, level int) error
+	indirect = func(block uint64, level int) error {
+		if block == 0 {
+			// a sparse indirect block covers perBlock^level logical blocks
+			span := uint64(1)
+			for i := 0; i < level; i++ {
+				span *= perBlock
+			}
+			logical += span
+			return nil
+		}
+		data := make([]byte, fs.blockSize)
+		if _, err := fs.r.ReadAt(data, int64(block)*fs.blockSize); err != nil {
+			return fmt.Errorf("unable to read ext indirect block: %w", err)
+		}
+		for i := uint64(0); i < perBlock && logical < total; i++ {
+			ptr := uint64(le.Uint32(data[i*4:]))
+			if level == 1 {
+				add(ptr)
+				continue
+			}
+			if err := indirect(ptr, level-1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i := 0; i < ext4BlockPtrs && logical < total; i++ {
+		ptr := uint64(le.Uint32(raw[i*4:]))
+		if i < 12 {
+			add(ptr)
+			continue
+		}
+		if err := indirect(ptr, i-11); err != nil {
+			return nil, err
+		}
+	}
+	return extents, nil
+}
+
+func (fs *ext4) ReadDir(ino uint64) ([]dirEntry, error) {
+	in, err := fs.readInode(ino)
+	if err != nil {
+		return nil, err
+	}
+
+	if in.Mode&modeTypeMask != modeDir {
+		return nil, fmt.Errorf("inode %d is not a directory", ino)
+	}
+
+	data, err := fs.readAll(in)
+	if err != nil {
+		return nil, err
+	}
+
+	if in.flags&ext4InodeFlagInlineData != 0 {
+		// inline directories start with the parent inode number followed by directory entries
+		if len(data) < 4 {
+			return nil, nil
+		}
+		return fs.parseDirEntries(data[4:]), nil
+	}
+
+	// note: hashed (htree) directories are still readable linearly, as the index is stored within entries that
+	// span the remainder of their block and are otherwise skipped.
+	var entries []dirEntry
+	for offset := int64(0); offset < int64(len(data)); offset += fs.blockSize {
+		end := offset + fs.blockSize
+		if end > int64(len(data)) {
+			end = int64(len(data))
+		}
+		entries = append(entries, fs.parseDirEntries(data[offset:end])...)
+	}
+	return entries, nil
+}
+
+func (fs *ext4) parseDirEntries(block []byte) []dirEntry {
+	le := binary.LittleEndian
+	var entries []dirEntry
+	for offset := 0; offset+8 <= len(block); {
+		ino := le.Uint32(block[offset : offset+4])
+		recLen := int(le.Uint16(block[offset+4 : offset+6]))
+		nameLen := int(block[offset+6])
+		if fs.incompat&ext4IncompatFileType == 0 {
+			nameLen = int(le.Uint16(block[offset+6 : offset+8]))
+		}
+
+		if recLen < 8 || offset+recLen > len(block) {
+			break
+		}
+
+		if ino != 0 && nameLen > 0 && offset+8+nameLen <= len(block) {
+			entries = append(entries, dirEntry{
+				Name:  string(block[offset+8 : offset+8+nameLen]),
+				Inode: uint64(ino),
+			})
+		}
+		offset += recLen
+	}
+	return entries
+}
+
+func (fs *ext4) ReadLink(ino uint64) (string, error) {
+	in, err := fs.readInode(ino)
+	if err != nil {
+		return "", err
+	}
+
+	// fast symlinks store the target within the block pointer area
+	if in.Size < int64(len(in.blocks)) && in.flags&(ext4InodeFlagExtents|ext4InodeFlagInlineData) == 0 {
+		return string(in.blocks[:in.Size]), nil
+	}
+
+	target, err := fs.readAll(in)
+	if err != nil {
+		return "", err
+	}
+	return string(target), nil
+}
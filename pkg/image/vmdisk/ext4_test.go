@@ -0,0 +1,64 @@
+//go:build vmdisk
+
+package vmdisk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ext4_readAll(t *testing.T) {
+	// an extent tree (depth 0) with a single extent mapping logical block 0 to physical block 1
+	extentRoot := make([]byte, 60)
+	binary.LittleEndian.PutUint16(extentRoot[0:2], ext4ExtentMagic)
+	binary.LittleEndian.PutUint16(extentRoot[2:4], 1)
+	binary.LittleEndian.PutUint16(extentRoot[16:18], 1)
+	binary.LittleEndian.PutUint32(extentRoot[20:24], 1)
+
+	disk := make([]byte, 2048)
+	copy(disk[1024:], "target")
+
+	tests := []struct {
+		name    string
+		inode   ext4Inode
+		want    string
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:  "inline data",
+			inode: ext4Inode{inode: inode{Size: 6}, flags: ext4InodeFlagInlineData, blocks: append([]byte("inline"), make([]byte, 54)...)},
+			want:  "inline",
+		},
+		{
+			name:    "inline data beyond the inode",
+			inode:   ext4Inode{inode: inode{Size: 61}, flags: ext4InodeFlagInlineData, blocks: make([]byte, 60)},
+			wantErr: require.Error,
+		},
+		{
+			name:  "extents",
+			inode: ext4Inode{inode: inode{Size: 6}, flags: ext4InodeFlagExtents, blocks: extentRoot},
+			want:  "target",
+		},
+		{
+			name:    "size beyond the extents",
+			inode:   ext4Inode{inode: inode{Size: 1 << 40}, flags: ext4InodeFlagExtents, blocks: extentRoot},
+			wantErr: require.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+
+			fs := &ext4{r: bytes.NewReader(disk), blockSize: 1024}
+			got, err := fs.readAll(&tt.inode)
+			tt.wantErr(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
@@ -0,0 +1,256 @@
+//go:build vmdisk
+
+package vmdisk
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/anchore/stereoscope/internal/log"
+)
+
+const (
+	modeTypeMask = 0xf000
+	modeFIFO     = 0x1000
+	modeChar     = 0x2000
+	modeDir      = 0x4000
+	modeBlock    = 0x6000
+	modeRegular  = 0x8000
+	modeSymlink  = 0xa000
+	modeSocket   = 0xc000
+)
+
+// filesystem is a read-only view of a filesystem within a disk partition.
+type filesystem interface {
+	Type() string
+	Root() uint64
+	Stat(ino uint64) (*inode, error)
+	ReadDir(ino uint64) ([]dirEntry, error)
+	ReadLink(ino uint64) (string, error)
+	Open(ino uint64) (io.Reader, error)
+}
+
+// inode is the filesystem-independent metadata for a single file.
+type inode struct {
+	Mode  uint16
+	UID   uint32
+	GID   uint32
+	Size  int64
+	Nlink uint32
+	Mtime time.Time
+}
+
+type dirEntry struct {
+	Name  string
+	Inode uint64
+}
+
+// maxWalkDepth bounds directory recursion for corrupt or hostile filesystems.
+const maxWalkDepth = 256
+
+// writeTar writes all paths within the filesystem (relative to the root) to the given tar writer. Multiple paths to the
+// same inode are written as hardlinks.
+func writeTar(fs filesystem, tw *tar.Writer) error {
+	w := tarWalker{
+		fs:    fs,
+		tw:    tw,
+		links: make(map[uint64]string),
+		dirs:  make(map[uint64]struct{}),
+	}
+	return w.walkDir(fs.Root(), "", 0)
+}
+
+type tarWalker struct {
+	fs    filesystem
+	tw    *tar.Writer
+	links map[uint64]string
+	// dirs are the inodes of all directories visited so far (directories cannot be hardlinked, thus a directory found
+	// again is a cycle within a corrupt or hostile filesystem)
+	dirs map[uint64]struct{}
+}
+
+func (w *tarWalker) walkDir(ino uint64, dir string, depth int) error {
+	if depth > maxWalkDepth {
+		return fmt.Errorf("maximum directory depth exceeded at %q", dir)
+	}
+
+	if _, ok := w.dirs[ino]; ok {
+		log.WithFields("path", "/"+dir, "inode", ino).Warn("directory cycle found in VM disk filesystem, skipping")
+		return nil
+	}
+	w.dirs[ino] = struct{}{}
+
+	entries, err := w.fs.ReadDir(ino)
+	if err != nil {
+		return fmt.Errorf("unable to read directory %q: %w", "/"+dir, err)
+	}
+
+	for _, e := range entries {
+		if e.Name == "." || e.Name == ".." || e.Name == "" {
+			continue
+		}
+		name := path.Join(dir, e.Name)
+		if err := w.visit(e.Inode, name, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *tarWalker) visit(ino uint64, name string, depth int) error {
+	node, err := w.fs.Stat(ino)
+	if err != nil {
+		return fmt.Errorf("unable to read inode for %q: %w", "/"+name, err)
+	}
+
+	hdr := tar.Header{
+		Name:    name,
+		Mode:    int64(node.Mode & 0o7777),
+		Uid:     int(node.UID),
+		Gid:     int(node.GID),
+		ModTime: node.Mtime,
+		Format:  tar.FormatPAX,
+	}
+
+	kind := node.Mode & modeTypeMask
+	if kind != modeDir && node.Nlink > 1 {
+		if target, ok := w.links[ino]; ok {
+			hdr.Typeflag = tar.TypeLink
+			hdr.Linkname = target
+			return w.tw.WriteHeader(&hdr)
+		}
+		w.links[ino] = name
+	}
+
+	switch kind {
+	case modeDir:
+		hdr.Typeflag = tar.TypeDir
+		hdr.Name += "/"
+		if err := w.tw.WriteHeader(&hdr); err != nil {
+			return err
+		}
+		return w.walkDir(ino, name, depth+1)
+
+	case modeSymlink:
+		target, err := w.fs.ReadLink(ino)
+		if err != nil {
+			return fmt.Errorf("unable to read symlink %q: %w", "/"+name, err)
+		}
+		hdr.Typeflag = tar.TypeSymlink
+		hdr.Linkname = target
+		return w.tw.WriteHeader(&hdr)
+
+	case modeRegular:
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = node.Size
+		if err := w.tw.WriteHeader(&hdr); err != nil {
+			return err
+		}
+		reader, err := w.fs.Open(ino)
+		if err != nil {
+			return fmt.Errorf("unable to open %q: %w", "/"+name, err)
+		}
+		if _, err := io.CopyN(w.tw, reader, node.Size); err != nil {
+			return fmt.Errorf("unable to read %q: %w", "/"+name, err)
+		}
+		return nil
+
+	case modeChar:
+		hdr.Typeflag = tar.TypeChar
+	case modeBlock:
+		hdr.Typeflag = tar.TypeBlock
+	case modeFIFO:
+		hdr.Typeflag = tar.TypeFifo
+	case modeSocket:
+		// sockets cannot be represented in a tar
+		return nil
+	default:
+		return fmt.Errorf("unknown file type for %q (mode=%o)", "/"+name, node.Mode)
+	}
+
+	return w.tw.WriteHeader(&hdr)
+}
+
+// extent maps a contiguous range of logical file blocks to physical blocks.
+type extent struct {
+	Logical  uint64
+	Physical uint64
+	Length   uint64
+	// Unwritten extents are allocated but read as zeros
+	Unwritten bool
+}
+
+// extentsEnd returns the logical block following the last block mapped by the given extents.
+func extentsEnd(extents []extent) int64 {
+	var end uint64
+	for _, ex := range extents {
+		if e := ex.Logical + ex.Length; e > end {
+			end = e
+		}
+	}
+	return int64(end)
+}
+
+// extentReader reads file contents described by a set of (sorted) extents, treating holes as zeros.
+type extentReader struct {
+	r         io.ReaderAt
+	blockSize int64
+	extents   []extent
+	size      int64
+	// physical converts a filesystem block number to a byte offset within the partition
+	physical func(block uint64) int64
+	pos      int64
+}
+
+func (e *extentReader) Read(p []byte) (int, error) {
+	if e.pos >= e.size {
+		return 0, io.EOF
+	}
+	if remaining := e.size - e.pos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	block := uint64(e.pos / e.blockSize)
+	within := e.pos % e.blockSize
+
+	// find the extent containing this block (or the next extent, if this is a hole)
+	var next *extent
+	for i := range e.extents {
+		ex := &e.extents[i]
+		if block >= ex.Logical && block < ex.Logical+ex.Length {
+			available := int64(ex.Logical+ex.Length-block)*e.blockSize - within
+			if int64(len(p)) > available {
+				p = p[:available]
+			}
+			if ex.Unwritten {
+				clear(p)
+				e.pos += int64(len(p))
+				return len(p), nil
+			}
+			offset := e.physical(ex.Physical+(block-ex.Logical)) + within
+			n, err := e.r.ReadAt(p, offset)
+			e.pos += int64(n)
+			if err == io.EOF && n == len(p) {
+				err = nil
+			}
+			return n, err
+		}
+		if ex.Logical > block && (next == nil || ex.Logical < next.Logical) {
+			next = ex
+		}
+	}
+
+	// within a hole (sparse region)
+	if next != nil {
+		hole := int64(next.Logical)*e.blockSize - e.pos
+		if int64(len(p)) > hole {
+			p = p[:hole]
+		}
+	}
+	clear(p)
+	e.pos += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,60 @@
+//go:build vmdisk
+
+package vmdisk
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFS is a filesystem of directories only, where each directory inode has the given entries.
+type fakeFS map[uint64][]dirEntry
+
+func (f fakeFS) Type() string { return "fake" }
+
+func (f fakeFS) Root() uint64 { return 1 }
+
+func (f fakeFS) Stat(ino uint64) (*inode, error) {
+	if _, ok := f[ino]; !ok {
+		return nil, fmt.Errorf("no inode %d", ino)
+	}
+	return &inode{Mode: modeDir | 0o755, Nlink: 2}, nil
+}
+
+func (f fakeFS) ReadDir(ino uint64) ([]dirEntry, error) { return f[ino], nil }
+
+func (f fakeFS) ReadLink(uint64) (string, error) { return "", fmt.Errorf("not a symlink") }
+
+func (f fakeFS) Open(uint64) (io.Reader, error) { return nil, fmt.Errorf("not a file") }
+
+func Test_writeTar_directoryCycles(t *testing.T) {
+	// every directory refers back to the root and to each other, which would otherwise be walked exponentially
+	fs := fakeFS{
+		1: {{Name: "a", Inode: 2}, {Name: "b", Inode: 3}},
+		2: {{Name: "b", Inode: 3}, {Name: "root", Inode: 1}},
+		3: {{Name: "a", Inode: 2}, {Name: "root", Inode: 1}},
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, writeTar(fs, tw))
+	require.NoError(t, tw.Close())
+
+	var names []string
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+	}
+	assert.Equal(t, []string{"a/", "a/b/", "a/b/a/", "a/b/root/", "a/root/", "b/"}, names)
+}
@@ -0,0 +1,186 @@
+//go:build vmdisk
+
+package vmdisk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	sectorSize = 512
+
+	mbrProtectiveGPT = 0xee
+	mbrExtendedCHS   = 0x05
+	mbrExtendedLBA   = 0x0f
+	mbrExtendedLinux = 0x85
+
+	// bounds for the (untrusted) GPT header fields describing the partition entry array
+	maxGPTEntries   = 1024
+	maxGPTEntrySize = 4096
+)
+
+var gptSignature = []byte("EFI PART")
+
+// partition is a region of the disk that may contain a filesystem.
+type partition struct {
+	Index  int
+	Offset int64
+	Size   int64
+}
+
+// readPartitions returns all partitions found within the MBR or GPT partition table of the disk. When there is no
+// partition table the entire disk is returned as a single partition.
+func readPartitions(d disk) ([]partition, error) {
+	mbr := make([]byte, sectorSize)
+	if _, err := d.ReadAt(mbr, 0); err != nil {
+		return nil, fmt.Errorf("unable to read disk MBR: %w", err)
+	}
+
+	if mbr[510] != 0x55 || mbr[511] != 0xaa {
+		return []partition{{Offset: 0, Size: d.Size()}}, nil
+	}
+
+	entries := mbrEntries(mbr)
+	for _, e := range entries {
+		if e.kind == mbrProtectiveGPT {
+			return readGPT(d)
+		}
+	}
+
+	var partitions []partition
+	for i, e := range entries {
+		switch e.kind {
+		case 0:
+			continue
+		case mbrExtendedCHS, mbrExtendedLBA, mbrExtendedLinux:
+			logical, err := readExtendedPartitions(d, e.start, len(entries)+1)
+			if err != nil {
+				return nil, err
+			}
+			partitions = append(partitions, logical...)
+		default:
+			partitions = append(partitions, partition{
+				Index:  i + 1,
+				Offset: e.start * sectorSize,
+				Size:   e.sectors * sectorSize,
+			})
+		}
+	}
+
+	// note: a filesystem (e.g. FAT) may start with a boot signature without any partition entries
+	if len(partitions) == 0 {
+		return []partition{{Offset: 0, Size: d.Size()}}, nil
+	}
+
+	return partitions, nil
+}
+
+type mbrEntry struct {
+	kind    byte
+	start   int64
+	sectors int64
+}
+
+func mbrEntries(sector []byte) []mbrEntry {
+	var entries []mbrEntry
+	for i := 0; i < 4; i++ {
+		raw := sector[446+i*16 : 446+(i+1)*16]
+		entries = append(entries, mbrEntry{
+			kind:    raw[4],
+			start:   int64(binary.LittleEndian.Uint32(raw[8:12])),
+			sectors: int64(binary.LittleEndian.Uint32(raw[12:16])),
+		})
+	}
+	return entries
+}
+
+// readExtendedPartitions follows the chain of extended boot records starting at the given LBA.
+func readExtendedPartitions(d disk, extendedStart int64, index int) ([]partition, error) {
+	var partitions []partition
+	ebrStart := extendedStart
+	seen := make(map[int64]struct{})
+	for {
+		if _, ok := seen[ebrStart]; ok {
+			return nil, fmt.Errorf("cycle detected in extended partition table")
+		}
+		seen[ebrStart] = struct{}{}
+
+		ebr := make([]byte, sectorSize)
+		if _, err := d.ReadAt(ebr, ebrStart*sectorSize); err != nil {
+			return nil, fmt.Errorf("unable to read extended boot record: %w", err)
+		}
+		if ebr[510] != 0x55 || ebr[511] != 0xaa {
+			return partitions, nil
+		}
+
+		entries := mbrEntries(ebr)
+		if entries[0].kind != 0 {
+			partitions = append(partitions, partition{
+				Index:  index,
+				Offset: (ebrStart + entries[0].start) * sectorSize,
+				Size:   entries[0].sectors * sectorSize,
+			})
+			index++
+		}
+
+		if entries[1].kind == 0 {
+			return partitions, nil
+		}
+		ebrStart = extendedStart + entries[1].start
+	}
+}
+
+func readGPT(d disk) ([]partition, error) {
+	header := make([]byte, 92)
+	if _, err := d.ReadAt(header, sectorSize); err != nil {
+		return nil, fmt.Errorf("unable to read GPT header: %w", err)
+	}
+
+	if !bytes.Equal(header[0:8], gptSignature) {
+		return nil, fmt.Errorf("invalid GPT signature")
+	}
+
+	le := binary.LittleEndian
+	entriesLBA := int64(le.Uint64(header[72:80]))
+	count := le.Uint32(header[80:84])
+	entrySize := le.Uint32(header[84:88])
+
+	entriesOffset := entriesLBA * sectorSize
+	if entrySize < 128 || entrySize > maxGPTEntrySize || count > maxGPTEntries ||
+		entriesOffset < 0 || entriesOffset > d.Size() || int64(count)*int64(entrySize) > d.Size()-entriesOffset {
+		return nil, fmt.Errorf("invalid GPT partition entries (count=%d size=%d)", count, entrySize)
+	}
+
+	raw := make([]byte, int(count)*int(entrySize))
+	if _, err := d.ReadAt(raw, entriesOffset); err != nil {
+		return nil, fmt.Errorf("unable to read GPT partition entries: %w", err)
+	}
+
+	var partitions []partition
+	for i := 0; i < int(count); i++ {
+		entry := raw[i*int(entrySize) : (i+1)*int(entrySize)]
+		if isZero(entry[0:16]) {
+			// unused entry (no partition type GUID)
+			continue
+		}
+		first := int64(le.Uint64(entry[32:40]))
+		last := int64(le.Uint64(entry[40:48]))
+		partitions = append(partitions, partition{
+			Index:  i + 1,
+			Offset: first * sectorSize,
+			Size:   (last - first + 1) * sectorSize,
+		})
+	}
+	return partitions, nil
+}
+
+func isZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
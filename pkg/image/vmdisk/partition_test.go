@@ -0,0 +1,75 @@
+//go:build vmdisk
+
+package vmdisk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memDisk struct {
+	*bytes.Reader
+}
+
+func (d memDisk) Size() int64 {
+	return d.Reader.Size()
+}
+
+func Test_readGPT(t *testing.T) {
+	gpt := func(entriesLBA uint64, count, entrySize uint32) []byte {
+		raw := make([]byte, 4*sectorSize)
+		header := raw[sectorSize:]
+		copy(header, gptSignature)
+		binary.LittleEndian.PutUint64(header[72:80], entriesLBA)
+		binary.LittleEndian.PutUint32(header[80:84], count)
+		binary.LittleEndian.PutUint32(header[84:88], entrySize)
+
+		entry := raw[2*sectorSize:]
+		entry[0] = 1 // partition type GUID
+		binary.LittleEndian.PutUint64(entry[32:40], 2048)
+		binary.LittleEndian.PutUint64(entry[40:48], 4095)
+		return raw
+	}
+
+	tests := []struct {
+		name    string
+		raw     []byte
+		want    []partition
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name: "valid entries",
+			raw:  gpt(2, 4, 128),
+			want: []partition{{Index: 1, Offset: 2048 * sectorSize, Size: 2048 * sectorSize}},
+		},
+		{
+			name:    "entry size exceeds maximum",
+			raw:     gpt(2, 1, 0xffffffff),
+			wantErr: require.Error,
+		},
+		{
+			name:    "entries exceed disk size",
+			raw:     gpt(2, 1024, 4096),
+			wantErr: require.Error,
+		},
+		{
+			name:    "entries beyond end of disk",
+			raw:     gpt(1<<40, 4, 128),
+			wantErr: require.Error,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.wantErr == nil {
+				test.wantErr = require.NoError
+			}
+			got, err := readGPT(memDisk{bytes.NewReader(test.raw)})
+			test.wantErr(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+//go:build vmdisk
+
+package vmdisk
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+var qcow2Magic = []byte{'Q', 'F', 'I', 0xfb}
+
+const (
+	qcow2OffsetMask     = 0x00fffffffffffe00
+	qcow2CompressedFlag = uint64(1) << 62
+	qcow2ZeroFlag       = uint64(1)
+)
+
+// qcow2Disk is a read-only view of a qcow2 (version 2 or 3) disk image. Backing files and encryption are not supported.
+type qcow2Disk struct {
+	r           io.ReaderAt
+	clusterBits uint32
+	size        int64
+	l1          []uint64
+}
+
+// newQcow2Disk reads the qcow2 header and L1 table from r, where fileSize is the size of the image file itself (bounding
+// every table read from it).
+func newQcow2Disk(r io.ReaderAt, fileSize int64) (*qcow2Disk, error) {
+	header := make([]byte, 72)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("unable to read qcow2 header: %w", err)
+	}
+
+	be := binary.BigEndian
+	version := be.Uint32(header[4:8])
+	if version != 2 && version != 3 {
+		return nil, fmt.Errorf("unsupported qcow2 version: %d", version)
+	}
+
+	if backingFileOffset := be.Uint64(header[8:16]); backingFileOffset != 0 {
+		return nil, fmt.Errorf("qcow2 images with backing files are not supported")
+	}
+
+	if method := be.Uint32(header[32:36]); method != 0 {
+		return nil, fmt.Errorf("encrypted qcow2 images are not supported")
+	}
+
+	d := &qcow2Disk{
+		r:           r,
+		clusterBits: be.Uint32(header[20:24]),
+		size:        int64(be.Uint64(header[24:32])),
+	}
+
+	if d.clusterBits < 9 || d.clusterBits > 21 {
+		return nil, fmt.Errorf("invalid qcow2 cluster size (bits=%d)", d.clusterBits)
+	}
+
+	l1Size := be.Uint32(header[36:40])
+	l1Offset := int64(be.Uint64(header[40:48]))
+
+	// the L1 table must be contained within the image file (the header is untrusted, refuse before allocating)
+	if l1Offset < 0 || l1Offset > fileSize || int64(l1Size)*8 > fileSize-l1Offset {
+		return nil, fmt.Errorf("invalid qcow2 L1 table (offset=%d entries=%d)", l1Offset, l1Size)
+	}
+
+	raw := make([]byte, int(l1Size)*8)
+	if _, err := r.ReadAt(raw, l1Offset); err != nil {
+		return nil, fmt.Errorf("unable to read qcow2 L1 table: %w", err)
+	}
+
+	d.l1 = make([]uint64, l1Size)
+	for i := range d.l1 {
+		d.l1[i] = be.Uint64(raw[i*8:])
+	}
+
+	return d, nil
+}
+
+func (d *qcow2Disk) Size() int64 {
+	return d.size
+}
+
+func (d *qcow2Disk) clusterSize() int64 {
+	return int64(1) << d.clusterBits
+}
+
+func (d *qcow2Disk) ReadAt(p []byte, off int64) (int, error) {
+	var n int
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= d.size {
+			return n, io.EOF
+		}
+
+		clusterOffset := pos & (d.clusterSize() - 1)
+		chunk := d.clusterSize() - clusterOffset
+		if remaining := int64(len(p) - n); chunk > remaining {
+			chunk = remaining
+		}
+		if remaining := d.size - pos; chunk > remaining {
+			chunk = remaining
+		}
+
+		if err := d.readCluster(p[n:n+int(chunk)], pos-clusterOffset, clusterOffset); err != nil {
+			return n, err
+		}
+		n += int(chunk)
+	}
+	return n, nil
+}
+
+// readCluster fills p with the contents of the guest cluster at the given (cluster aligned) offset.
+func (d *qcow2Disk) readCluster(p []byte, clusterStart, within int64) error {
+	l2Entries := d.clusterSize() / 8
+	clusterIndex := clusterStart >> d.clusterBits
+	l1Index := clusterIndex / l2Entries
+	l2Index := clusterIndex % l2Entries
+
+	if l1Index >= int64(len(d.l1)) {
+		clear(p)
+		return nil
+	}
+
+	l2Offset := int64(d.l1[l1Index] & qcow2OffsetMask)
+	if l2Offset == 0 {
+		clear(p)
+		return nil
+	}
+
+	raw := make([]byte, 8)
+	if _, err := d.r.ReadAt(raw, l2Offset+l2Index*8); err != nil {
+		return fmt.Errorf("unable to read qcow2 L2 table: %w", err)
+	}
+	entry := binary.BigEndian.Uint64(raw)
+
+	if entry&qcow2CompressedFlag != 0 {
+		return d.readCompressed(p, entry, within)
+	}
+
+	dataOffset := int64(entry & qcow2OffsetMask)
+	if dataOffset == 0 || entry&qcow2ZeroFlag != 0 {
+		clear(p)
+		return nil
+	}
+
+	_, err := d.r.ReadAt(p, dataOffset+within)
+	return err
+}
+
+func (d *qcow2Disk) readCompressed(p []byte, entry uint64, within int64) error {
+	// the compressed cluster descriptor is split at bit (62 - (cluster_bits - 8))
+	x := 62 - (d.clusterBits - 8)
+	offset := int64(entry & ((uint64(1) << x) - 1))
+	sectors := int64((entry>>x)&((uint64(1)<<(d.clusterBits-8))-1)) + 1
+	compressedSize := sectors*512 - (offset & 511)
+
+	compressed := make([]byte, compressedSize)
+	if _, err := d.r.ReadAt(compressed, offset); err != nil && err != io.EOF {
+		return fmt.Errorf("unable to read compressed qcow2 cluster: %w", err)
+	}
+
+	cluster := make([]byte, d.clusterSize())
+	if _, err := io.ReadFull(flate.NewReader(bytes.NewReader(compressed)), cluster); err != nil && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("unable to decompress qcow2 cluster: %w", err)
+	}
+
+	copy(p, cluster[within:])
+	return nil
+}
@@ -0,0 +1,38 @@
+//go:build vmdisk
+
+package vmdisk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newQcow2Disk_l1Bounds(t *testing.T) {
+	header := func(l1Size uint32, l1Offset uint64) []byte {
+		raw := make([]byte, 4096)
+		copy(raw, qcow2Magic)
+		binary.BigEndian.PutUint32(raw[4:8], 3)
+		binary.BigEndian.PutUint32(raw[20:24], 16)
+		binary.BigEndian.PutUint64(raw[24:32], 1<<30)
+		binary.BigEndian.PutUint32(raw[36:40], l1Size)
+		binary.BigEndian.PutUint64(raw[40:48], l1Offset)
+		return raw
+	}
+
+	raw := header(8, 1024)
+	d, err := newQcow2Disk(bytes.NewReader(raw), int64(len(raw)))
+	require.NoError(t, err)
+	assert.Len(t, d.l1, 8)
+
+	raw = header(0xffffffff, 1024)
+	_, err = newQcow2Disk(bytes.NewReader(raw), int64(len(raw)))
+	require.ErrorContains(t, err, "invalid qcow2 L1 table")
+
+	raw = header(8, 1<<40)
+	_, err = newQcow2Disk(bytes.NewReader(raw), int64(len(raw)))
+	require.ErrorContains(t, err, "invalid qcow2 L1 table")
+}
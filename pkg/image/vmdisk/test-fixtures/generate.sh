@@ -0,0 +1,25 @@
+#!/usr/bin/env bash
+# regenerates the filesystem fixtures (requires e2fsprogs)
+set -eux
+
+WORK=$(mktemp -d)
+trap 'rm -rf "$WORK"' EXIT
+
+ROOT="$WORK/root"
+mkdir -p "$ROOT/etc" "$ROOT/usr/bin" "$ROOT/var/lib/deep/nested"
+printf 'ID=test\nVERSION_ID=1.0\n' > "$ROOT/etc/os-release"
+# large enough to require indirect blocks (ext2) with a 1K block size
+head -c 20000 /dev/urandom | base64 > "$ROOT/usr/bin/tool"
+chmod 755 "$ROOT/usr/bin/tool"
+ln -s ../etc/os-release "$ROOT/usr/os-release"
+# long enough to not be a "fast" symlink
+ln -s /var/lib/deep/nested/this/is/a/very/long/symlink/target/that/exceeds/sixty/bytes "$ROOT/var/long-link"
+ln "$ROOT/usr/bin/tool" "$ROOT/usr/bin/tool-hardlink"
+echo nested > "$ROOT/var/lib/deep/nested/file.txt"
+# enough entries to span multiple directory blocks
+for i in $(seq 1 100); do echo "$i" > "$ROOT/var/lib/deep/f$i"; done
+
+for fs in ext4 ext2; do
+  "mkfs.$fs" -q -F -b 1024 -d "$ROOT" -E root_owner=0:0 "$WORK/$fs.img" 2048
+  gzip -9 -n -c "$WORK/$fs.img" > "$fs.img.gz"
+done
@@ -0,0 +1,499 @@
+//go:build vmdisk
+
+package vmdisk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	xfsMagic        = []byte("XFSB")
+	xfsInodeMagic   = []byte("IN")
+	xfsBmapMagicV4  = []byte("BMAP")
+	xfsBmapMagicV5  = []byte("BMA3")
+	xfsSymlinkMagic = []byte("XSLM")
+
+	xfsDirBlockMagics = map[string]int{
+		// magic: header size
+		"XD2B": 16,
+		"XD2D": 16,
+		"XDB3": 64,
+		"XDD3": 64,
+	}
+)
+
+const (
+	xfsFormatLocal   = 1
+	xfsFormatExtents = 2
+	xfsFormatBtree   = 3
+
+	xfsFeatures2Ftype        = 0x200
+	xfsIncompatFtype         = 0x1
+	xfsDirLeafOffset  uint64 = 32 << 30
+
+	// xfsMaxBlockSize bounds the (filesystem and directory) block sizes, the largest supported by XFS
+	xfsMaxBlockSize = 64 * 1024
+	// xfsMaxInodeSize is the largest inode size supported by XFS
+	xfsMaxInodeSize = 2048
+)
+
+// xfs is a read-only XFS (v4 and v5) filesystem reader.
+type xfs struct {
+	r          io.ReaderAt
+	v5         bool
+	ftype      bool
+	blockSize  int64
+	dirBlkSize int64
+	agBlocks   uint64
+	agBlkLog   uint8
+	inodeSize  int64
+	inoPBLog   uint8
+	rootIno    uint64
+}
+
+func isXFS(r io.ReaderAt) bool {
+	magic := make([]byte, 4)
+	if _, err := r.ReadAt(magic, 0); err != nil {
+		return false
+	}
+	return bytes.Equal(magic, xfsMagic)
+}
+
+func newXFS(r io.ReaderAt) (*xfs, error) {
+	sb := make([]byte, 256)
+	if _, err := r.ReadAt(sb, 0); err != nil {
+		return nil, fmt.Errorf("unable to read xfs superblock: %w", err)
+	}
+
+	if !bytes.Equal(sb[0:4], xfsMagic) {
+		return nil, fmt.Errorf("invalid xfs superblock magic")
+	}
+
+	be := binary.BigEndian
+	fs := &xfs{
+		r:         r,
+		blockSize: int64(be.Uint32(sb[4:8])),
+		rootIno:   be.Uint64(sb[56:64]),
+		agBlocks:  uint64(be.Uint32(sb[84:88])),
+		inodeSize: int64(be.Uint16(sb[104:106])),
+		inoPBLog:  sb[123],
+		agBlkLog:  sb[124],
+		v5:        be.Uint16(sb[100:102])&0xf == 5,
+	}
+	if dirBlkLog := sb[192]; dirBlkLog <= 7 {
+		fs.dirBlkSize = fs.blockSize << dirBlkLog
+	}
+
+	if fs.v5 {
+		fs.ftype = be.Uint32(sb[216:220])&xfsIncompatFtype != 0
+	} else {
+		fs.ftype = be.Uint32(sb[200:204])&xfsFeatures2Ftype != 0
+	}
+
+	if fs.blockSize < 512 || fs.blockSize > xfsMaxBlockSize || fs.dirBlkSize < fs.blockSize || fs.dirBlkSize > xfsMaxBlockSize {
+		return nil, fmt.Errorf("invalid xfs superblock (block size=%d, directory block size=%d)", fs.blockSize, fs.dirBlkSize)
+	}
+
+	if fs.inodeSize < 256 && fs.v5 || fs.inodeSize < 128 || fs.inodeSize > xfsMaxInodeSize {
+		return nil, fmt.Errorf("invalid xfs superblock (inode size=%d)", fs.inodeSize)
+	}
+
+	return fs, nil
+}
+
+func (fs *xfs) Type() string {
+	return "xfs"
+}
+
+func (fs *xfs) Root() uint64 {
+	return fs.rootIno
+}
+
+// fsblockOffset converts a filesystem block number (AG number + AG relative block) to a byte offset.
+func (fs *xfs) fsblockOffset(fsb uint64) int64 {
+	agno := fsb >> fs.agBlkLog
+	agbno := fsb & (uint64(1)<<fs.agBlkLog - 1)
+	return int64(agno*fs.agBlocks+agbno) * fs.blockSize
+}
+
+type xfsInode struct {
+	inode
+	format   uint8
+	fork     []byte
+	forkSize int
+}
+
+func (fs *xfs) readInode(ino uint64) (*xfsInode, error) {
+	offsetInBlock := ino & (uint64(1)<<fs.inoPBLog - 1)
+	fsb := ino >> fs.inoPBLog
+	offset := fs.fsblockOffset(fsb) + int64(offsetInBlock)*fs.inodeSize
+
+	raw := make([]byte, fs.inodeSize)
+	if _, err := fs.r.ReadAt(raw, offset); err != nil {
+		return nil, fmt.Errorf("unable to read inode %d: %w", ino, err)
+	}
+
+	if !bytes.Equal(raw[0:2], xfsInodeMagic) {
+		return nil, fmt.Errorf("invalid xfs inode magic (inode=%d)", ino)
+	}
+
+	be := binary.BigEndian
+	version := raw[4]
+	coreSize := 100
+	if version >= 3 {
+		coreSize = 176
+	}
+
+	nlink := be.Uint32(raw[16:20])
+	if version == 1 {
+		nlink = uint32(be.Uint16(raw[6:8]))
+	}
+
+	if coreSize > len(raw) {
+		return nil, fmt.Errorf("invalid xfs inode version %d for inode size %d (inode=%d)", version, fs.inodeSize, ino)
+	}
+
+	forkSize := len(raw) - coreSize
+	if forkOff := int(raw[82]); forkOff != 0 {
+		if forkOff*8 > forkSize {
+			return nil, fmt.Errorf("invalid xfs inode fork offset %d (inode=%d)", forkOff, ino)
+		}
+		forkSize = forkOff * 8
+	}
+
+	size := int64(be.Uint64(raw[56:64]))
+	if size < 0 {
+		return nil, fmt.Errorf("invalid xfs inode size %d (inode=%d)", size, ino)
+	}
+
+	return &xfsInode{
+		inode: inode{
+			Mode:  be.Uint16(raw[2:4]),
+			UID:   be.Uint32(raw[8:12]),
+			GID:   be.Uint32(raw[12:16]),
+			Nlink: nlink,
+			Mtime: time.Unix(int64(int32(be.Uint32(raw[40:44]))), int64(be.Uint32(raw[44:48]))).UTC(),
+			Size:  size,
+		},
+		format:   raw[5],
+		fork:     raw[coreSize : coreSize+forkSize],
+		forkSize: forkSize,
+	}, nil
+}
+
+func (fs *xfs) Stat(ino uint64) (*inode, error) {
+	in, err := fs.readInode(ino)
+	if err != nil {
+		return nil, err
+	}
+	return &in.inode, nil
+}
+
+// decodeXFSExtent decodes a packed 128-bit extent record.
+func decodeXFSExtent(raw []byte) extent {
+	be := binary.BigEndian
+	hi := be.Uint64(raw[0:8])
+	lo := be.Uint64(raw[8:16])
+	return extent{
+		Unwritten: hi>>63 == 1,
+		Logical:   (hi >> 9) & (uint64(1)<<54 - 1),
+		Physical:  (hi&0x1ff)<<43 | lo>>21,
+		Length:    lo & (uint64(1)<<21 - 1),
+	}
+}
+
+func (fs *xfs) extents(in *xfsInode) ([]extent, error) {
+	be := binary.BigEndian
+	switch in.format {
+	case xfsFormatExtents:
+		count := len(in.fork) / 16
+		var extents []extent
+		for i := 0; i < count; i++ {
+			ex := decodeXFSExtent(in.fork[i*16 : i*16+16])
+			if ex.Length == 0 {
+				break
+			}
+			extents = append(extents, ex)
+		}
+		return extents, nil
+
+	case xfsFormatBtree:
+		if len(in.fork) < 4 {
+			return nil, fmt.Errorf("invalid xfs bmap btree root")
+		}
+		numRecs := int(be.Uint16(in.fork[2:4]))
+		maxRecs := (in.forkSize - 4) / 16
+		var extents []extent
+		for i := 0; i < numRecs; i++ {
+			ptrOffset := 4 + maxRecs*8 + i*8
+			if ptrOffset+8 > len(in.fork) {
+				return nil, fmt.Errorf("invalid xfs bmap btree root pointers")
+			}
+			child, err := fs.bmapBlock(be.Uint64(in.fork[ptrOffset:ptrOffset+8]), 0)
+			if err != nil {
+				return nil, err
+			}
+			extents = append(extents, child...)
+		}
+		return extents, nil
+	}
+	return nil, fmt.Errorf("unsupported xfs data fork format: %d", in.format)
+}
+
+func (fs *xfs) bmapBlock(fsb uint64, depth int) ([]extent, error) {
+	if depth > 10 {
+		return nil, fmt.Errorf("xfs bmap btree is too deep")
+	}
+
+	block := make([]byte, fs.blockSize)
+	if _, err := fs.r.ReadAt(block, fs.fsblockOffset(fsb)); err != nil {
+		return nil, fmt.Errorf("unable to read xfs bmap block: %w", err)
+	}
+
+	headerSize := 24
+	switch {
+	case bytes.Equal(block[0:4], xfsBmapMagicV5):
+		headerSize = 72
+	case bytes.Equal(block[0:4], xfsBmapMagicV4):
+	default:
+		return nil, fmt.Errorf("invalid xfs bmap block magic")
+	}
+
+	be := binary.BigEndian
+	level := be.Uint16(block[4:6])
+	numRecs := int(be.Uint16(block[6:8]))
+
+	var extents []extent
+	if level == 0 {
+		for i := 0; i < numRecs; i++ {
+			offset := headerSize + i*16
+			if offset+16 > len(block) {
+				break
+			}
+			extents = append(extents, decodeXFSExtent(block[offset:offset+16]))
+		}
+		return extents, nil
+	}
+
+	maxRecs := (int(fs.blockSize) - headerSize) / 16
+	for i := 0; i < numRecs; i++ {
+		ptrOffset := headerSize + maxRecs*8 + i*8
+		child, err := fs.bmapBlock(be.Uint64(block[ptrOffset:ptrOffset+8]), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		extents = append(extents, child...)
+	}
+	return extents, nil
+}
+
+func (fs *xfs) Open(ino uint64) (io.Reader, error) {
+	in, err := fs.readInode(ino)
+	if err != nil {
+		return nil, err
+	}
+	return fs.open(in, in.Size)
+}
+
+func (fs *xfs) open(in *xfsInode, size int64) (io.Reader, error) {
+	if in.format == xfsFormatLocal {
+		data := in.fork
+		if size < int64(len(data)) {
+			data = data[:size]
+		}
+		return bytes.NewReader(data), nil
+	}
+
+	extents, err := fs.extents(in)
+	if err != nil {
+		return nil, err
+	}
+
+	return &extentReader{
+		r:         fs.r,
+		blockSize: fs.blockSize,
+		extents:   extents,
+		size:      size,
+		physical:  fs.fsblockOffset,
+	}, nil
+}
+
+func (fs *xfs) ReadDir(ino uint64) ([]dirEntry, error) {
+	in, err := fs.readInode(ino)
+	if err != nil {
+		return nil, err
+	}
+
+	if in.Mode&modeTypeMask != modeDir {
+		return nil, fmt.Errorf("inode %d is not a directory", ino)
+	}
+
+	if in.format == xfsFormatLocal {
+		return fs.shortformDir(in.fork)
+	}
+
+	extents, err := fs.extents(in)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []dirEntry
+	blocksPerDirBlock := uint64(fs.dirBlkSize / fs.blockSize)
+	leafBlock := xfsDirLeafOffset / uint64(fs.blockSize)
+	for _, ex := range extents {
+		// only data blocks contain entries (leaf and free-space index blocks are stored beyond the leaf offset)
+		if ex.Logical >= leafBlock {
+			continue
+		}
+		for b := uint64(0); b < ex.Length; b += blocksPerDirBlock {
+			block := make([]byte, fs.dirBlkSize)
+			if _, err := fs.r.ReadAt(block, fs.fsblockOffset(ex.Physical+b)); err != nil {
+				return nil, fmt.Errorf("unable to read xfs directory block: %w", err)
+			}
+			blockEntries, err := fs.dataBlockEntries(block)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, blockEntries...)
+		}
+	}
+	return entries, nil
+}
+
+func (fs *xfs) shortformDir(fork []byte) ([]dirEntry, error) {
+	if len(fork) < 6 {
+		return nil, fmt.Errorf("invalid xfs shortform directory")
+	}
+
+	count := int(fork[0])
+	inoSize := 4
+	if i8count := int(fork[1]); i8count > 0 {
+		count = i8count
+		inoSize = 8
+	}
+
+	offset := 2 + inoSize
+	var entries []dirEntry
+	for i := 0; i < count; i++ {
+		if offset >= len(fork) {
+			return nil, fmt.Errorf("truncated xfs shortform directory")
+		}
+		nameLen := int(fork[offset])
+		// skip the name length (1) and the (unused) directory offset tag (2)
+		nameStart := offset + 3
+		inoStart := nameStart + nameLen
+		if fs.ftype {
+			inoStart++
+		}
+		if inoStart+inoSize > len(fork) {
+			return nil, fmt.Errorf("truncated xfs shortform directory entry")
+		}
+
+		entries = append(entries, dirEntry{
+			Name:  string(fork[nameStart : nameStart+nameLen]),
+			Inode: readXFSIno(fork[inoStart : inoStart+inoSize]),
+		})
+		offset = inoStart + inoSize
+	}
+	return entries, nil
+}
+
+func readXFSIno(raw []byte) uint64 {
+	if len(raw) == 8 {
+		return binary.BigEndian.Uint64(raw)
+	}
+	return uint64(binary.BigEndian.Uint32(raw))
+}
+
+func (fs *xfs) dataBlockEntries(block []byte) ([]dirEntry, error) {
+	magic := string(block[0:4])
+	headerSize, ok := xfsDirBlockMagics[magic]
+	if !ok {
+		return nil, fmt.Errorf("invalid xfs directory block magic: %q", magic)
+	}
+
+	be := binary.BigEndian
+	end := len(block)
+	if magic == "XD2B" || magic == "XDB3" {
+		// single-block directories end with the leaf entries followed by a tail (count, stale)
+		count := int(be.Uint32(block[len(block)-8 : len(block)-4]))
+		end = len(block) - 8 - count*8
+	}
+
+	var entries []dirEntry
+	for offset := headerSize; offset+8 <= end; {
+		if be.Uint16(block[offset:offset+2]) == 0xffff {
+			// unused space
+			length := int(be.Uint16(block[offset+2 : offset+4]))
+			if length <= 0 {
+				return nil, fmt.Errorf("invalid xfs directory free space entry")
+			}
+			offset += length
+			continue
+		}
+
+		ino := be.Uint64(block[offset : offset+8])
+		nameLen := int(block[offset+8])
+		if offset+9+nameLen > end {
+			return nil, fmt.Errorf("truncated xfs directory entry")
+		}
+		entries = append(entries, dirEntry{
+			Name:  string(block[offset+9 : offset+9+nameLen]),
+			Inode: ino,
+		})
+
+		size := 8 + 1 + nameLen + 2
+		if fs.ftype {
+			size++
+		}
+		offset += (size + 7) &^ 7
+	}
+	return entries, nil
+}
+
+func (fs *xfs) ReadLink(ino uint64) (string, error) {
+	in, err := fs.readInode(ino)
+	if err != nil {
+		return "", err
+	}
+
+	if in.format == xfsFormatLocal {
+		if in.Size > int64(len(in.fork)) {
+			return "", fmt.Errorf("invalid xfs local symlink size")
+		}
+		return string(in.fork[:in.Size]), nil
+	}
+
+	extents, err := fs.extents(in)
+	if err != nil {
+		return "", err
+	}
+
+	var target []byte
+	for _, ex := range extents {
+		for b := uint64(0); b < ex.Length && int64(len(target)) < in.Size; b++ {
+			block := make([]byte, fs.blockSize)
+			if _, err := fs.r.ReadAt(block, fs.fsblockOffset(ex.Physical+b)); err != nil {
+				return "", fmt.Errorf("unable to read xfs symlink block: %w", err)
+			}
+			if fs.v5 && bytes.Equal(block[0:4], xfsSymlinkMagic) {
+				// v5 remote symlink blocks have a header (56 bytes) and describe the length of their content
+				length := int(binary.BigEndian.Uint32(block[8:12]))
+				if 56+length > len(block) {
+					return "", fmt.Errorf("invalid xfs symlink block")
+				}
+				block = block[56 : 56+length]
+			}
+			target = append(target, block...)
+		}
+	}
+
+	if int64(len(target)) > in.Size {
+		target = target[:in.Size]
+	}
+	return string(target), nil
+}
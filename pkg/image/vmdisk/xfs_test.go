@@ -0,0 +1,84 @@
+//go:build vmdisk
+
+package vmdisk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_decodeXFSExtent(t *testing.T) {
+	want := extent{Logical: 12, Physical: 3456, Length: 7}
+
+	raw := make([]byte, 16)
+	binary.BigEndian.PutUint64(raw[0:8], want.Logical<<9|want.Physical>>43)
+	binary.BigEndian.PutUint64(raw[8:16], want.Physical<<21|want.Length)
+
+	assert.Equal(t, want, decodeXFSExtent(raw))
+}
+
+func Test_xfs_shortformDir(t *testing.T) {
+	// count=2, i8count=0, parent=128, entries: (namelen, offset[2], name, ftype, ino[4])
+	fork := []byte{2, 0, 0, 0, 0, 128}
+	fork = append(fork, 3, 0, 0x60, 'e', 't', 'c', 2, 0, 0, 0, 131)
+	fork = append(fork, 3, 0, 0x70, 'u', 's', 'r', 2, 0, 0, 1, 0)
+
+	fs := &xfs{ftype: true}
+	entries, err := fs.shortformDir(fork)
+	require.NoError(t, err)
+	assert.Equal(t, []dirEntry{
+		{Name: "etc", Inode: 131},
+		{Name: "usr", Inode: 256},
+	}, entries)
+}
+
+func Test_xfs_readInode(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(raw []byte)
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name: "fork offset beyond the inode",
+			modify: func(raw []byte) {
+				raw[82] = 255
+			},
+			wantErr: require.Error,
+		},
+		{
+			name: "negative size",
+			modify: func(raw []byte) {
+				binary.BigEndian.PutUint64(raw[56:64], 1<<63)
+			},
+			wantErr: require.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				tt.wantErr = require.NoError
+			}
+
+			raw := make([]byte, 256)
+			copy(raw, xfsInodeMagic)
+			binary.BigEndian.PutUint16(raw[2:4], modeSymlink|0o777)
+			raw[4] = 3
+			raw[5] = xfsFormatLocal
+			binary.BigEndian.PutUint64(raw[56:64], 3)
+			if tt.modify != nil {
+				tt.modify(raw)
+			}
+
+			fs := &xfs{r: bytes.NewReader(raw), blockSize: 512, inodeSize: 256}
+			_, err := fs.readInode(0)
+			tt.wantErr(t, err)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package imagetest
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"math/rand"
+	"path"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/require"
+)
+
+// DeepImageConfig describes a generated image with many layers (see DeepImage).
+type DeepImageConfig struct {
+	// Layers is the number of layers within the image
+	Layers int
+	// FilesPerLayer is the number of file operations (add, overwrite, or delete) within each layer
+	FilesPerLayer int
+	// Paths is the number of distinct paths that file operations are spread across (smaller values result in more
+	// overwrites and deletions between layers)
+	Paths int
+	// Seed for all pseudo-random choices; the same configuration always generates the same image
+	Seed int64
+}
+
+// DeepImage generates an image with a deep chain of layers, where each layer adds, overwrites, or deletes (with
+// whiteouts) pseudo-randomly chosen paths. This is useful for exercising squashing behavior on images with many
+// layers without needing to build them with a container engine.
+func DeepImage(t testing.TB, cfg DeepImageConfig) v1.Image {
+	t.Helper()
+
+	if cfg.FilesPerLayer <= 0 {
+		cfg.FilesPerLayer = 1
+	}
+	if cfg.Paths <= 0 {
+		cfg.Paths = cfg.FilesPerLayer
+	}
+
+	rng := rand.New(rand.NewSource(cfg.Seed)) //nolint:gosec // deterministic test data
+
+	img := empty.Image
+	for l := 0; l < cfg.Layers; l++ {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     "deep/",
+			Mode:     0o755,
+		}))
+
+		written := make(map[string]struct{})
+		for f := 0; f < cfg.FilesPerLayer; f++ {
+			name := fmt.Sprintf("file-%d", rng.Intn(cfg.Paths))
+			if _, ok := written[name]; ok {
+				continue
+			}
+			written[name] = struct{}{}
+
+			// the first layer only adds files, all others may also delete files
+			if l > 0 && rng.Intn(4) == 0 {
+				require.NoError(t, tw.WriteHeader(&tar.Header{
+					Typeflag: tar.TypeReg,
+					Name:     path.Join("deep", ".wh."+name),
+					Mode:     0o644,
+				}))
+				continue
+			}
+
+			contents := []byte(fmt.Sprintf("layer=%d file=%s value=%d\n", l, name, rng.Int63()))
+			require.NoError(t, tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     path.Join("deep", name),
+				Mode:     0o644,
+				Size:     int64(len(contents)),
+			}))
+			_, err := tw.Write(contents)
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+
+		var err error
+		img, err = mutate.AppendLayers(img, static.NewLayer(buf.Bytes(), types.DockerUncompressedLayer))
+		require.NoError(t, err)
+	}
+
+	return img
+}
@@ -0,0 +1,274 @@
+// Package integration provides an opt-in test harness that runs real container runtimes (a registry, a docker daemon,
+// and containerd) within containers, and validates image providers against them. The harness is exported so that
+// downstream consumers can validate their own provider configurations.
+//
+// The harness requires the docker CLI (with the ability to run privileged containers) and is only enabled when the
+// STEREOSCOPE_INTEGRATION_HARNESS environment variable is set to "true"; otherwise all tests using it are skipped.
+package integration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// EnableEnvVar must be set to "true" to enable the harness
+	EnableEnvVar = "STEREOSCOPE_INTEGRATION_HARNESS"
+
+	// RegistryImage is the image used to run an OCI distribution registry
+	RegistryImage = "registry:2"
+	// DaemonImage is the image used to run dockerd and containerd
+	DaemonImage = "docker:24-dind"
+
+	// Namespace is the containerd namespace images are loaded into
+	Namespace = "default"
+
+	startupTimeout = 2 * time.Minute
+)
+
+// Config selects the services started by the harness.
+type Config struct {
+	Registry   bool
+	Docker     bool
+	Containerd bool
+}
+
+// AllServices starts every service supported by the harness.
+var AllServices = Config{Registry: true, Docker: true, Containerd: true}
+
+// Harness is a set of running container runtime services. All services are removed when the test completes.
+type Harness struct {
+	// RegistryHost is the host:port of the registry (accessible over plain HTTP)
+	RegistryHost string
+	// DockerHost is the address of the docker daemon (suitable for DOCKER_HOST)
+	DockerHost string
+	// ContainerdAddress is the socket path of containerd (suitable for CONTAINERD_ADDRESS)
+	ContainerdAddress string
+}
+
+// Enabled indicates if the harness has been opted into (see EnableEnvVar).
+func Enabled() bool {
+	return os.Getenv(EnableEnvVar) == "true"
+}
+
+// New starts the configured services, skipping the test when the harness is not enabled.
+func New(t testing.TB, cfg Config) *Harness {
+	t.Helper()
+
+	if !Enabled() {
+		t.Skipf("integration harness not enabled (set %s=true)", EnableEnvVar)
+	}
+
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("integration harness requires the docker CLI")
+	}
+
+	h := &Harness{}
+	if cfg.Registry {
+		h.RegistryHost = startRegistry(t)
+	}
+	if cfg.Docker {
+		h.DockerHost = startDocker(t)
+	}
+	if cfg.Containerd {
+		h.ContainerdAddress = startContainerd(t)
+	}
+	return h
+}
+
+// DockerClient returns a client for the harness docker daemon.
+func (h *Harness) DockerClient() (client.APIClient, error) {
+	if h.DockerHost == "" {
+		return nil, fmt.Errorf("docker is not running within the harness")
+	}
+	return client.NewClientWithOpts(client.WithHost(h.DockerHost), client.WithAPIVersionNegotiation())
+}
+
+// ContainerdClient returns a client for the harness containerd.
+func (h *Harness) ContainerdClient() (*containerd.Client, error) {
+	if h.ContainerdAddress == "" {
+		return nil, fmt.Errorf("containerd is not running within the harness")
+	}
+	return containerd.New(h.ContainerdAddress, containerd.WithDefaultNamespace(Namespace))
+}
+
+// PushImage pushes the given image to the harness registry, returning the full reference to the image.
+func (h *Harness) PushImage(t testing.TB, repoTag string, img v1.Image) string {
+	t.Helper()
+	require.NotEmpty(t, h.RegistryHost, "registry is not running within the harness")
+
+	ref, err := name.ParseReference(h.RegistryHost+"/"+repoTag, name.Insecure)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+	return ref.String()
+}
+
+// LoadDockerImage loads the given image into the harness docker daemon with the given tag.
+func (h *Harness) LoadDockerImage(t testing.TB, tag string, img v1.Image) string {
+	t.Helper()
+
+	apiClient, err := h.DockerClient()
+	require.NoError(t, err)
+	defer apiClient.Close()
+
+	archive := dockerArchive(t, tag, img)
+	resp, err := apiClient.ImageLoad(context.Background(), archive, true)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var out bytes.Buffer
+	_, err = out.ReadFrom(resp.Body)
+	require.NoError(t, err)
+	return tag
+}
+
+// LoadContainerdImage imports the given image into the harness containerd (within Namespace) with the given tag.
+func (h *Harness) LoadContainerdImage(t testing.TB, tag string, img v1.Image) string {
+	t.Helper()
+
+	c, err := h.ContainerdClient()
+	require.NoError(t, err)
+	defer c.Close()
+
+	ctx := namespaces.WithNamespace(context.Background(), Namespace)
+	_, err = c.Import(ctx, dockerArchive(t, tag, img), containerd.WithAllPlatforms(true))
+	require.NoError(t, err)
+	return tag
+}
+
+func dockerArchive(t testing.TB, tag string, img v1.Image) *bytes.Reader {
+	t.Helper()
+
+	ref, err := name.NewTag(tag)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, tarball.Write(ref, img, &buf))
+	return bytes.NewReader(buf.Bytes())
+}
+
+func startRegistry(t testing.TB) string {
+	id := runContainer(t, "-p", "127.0.0.1::5000", RegistryImage)
+	host := publishedAddress(t, id, "5000/tcp")
+
+	waitFor(t, "registry", func() error {
+		resp, err := http.Get("http://" + host + "/v2/") //nolint:noctx
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+		}
+		return nil
+	})
+	return host
+}
+
+func startDocker(t testing.TB) string {
+	id := runContainer(t, "--privileged", "-e", "DOCKER_TLS_CERTDIR=", "-p", "127.0.0.1::2375",
+		DaemonImage, "dockerd", "--host=tcp://0.0.0.0:2375", "--tls=false")
+	host := "tcp://" + publishedAddress(t, id, "2375/tcp")
+
+	h := &Harness{DockerHost: host}
+	waitFor(t, "docker", func() error {
+		apiClient, err := h.DockerClient()
+		if err != nil {
+			return err
+		}
+		defer apiClient.Close()
+		_, err = apiClient.Ping(context.Background())
+		return err
+	})
+	return host
+}
+
+func startContainerd(t testing.TB) string {
+	dir := t.TempDir()
+
+	// allow for the current user to access the socket created by root within the container
+	config := fmt.Sprintf("version = 2\n\n[grpc]\n  address = \"/run/containerd/containerd.sock\"\n  uid = %d\n  gid = %d\n", os.Getuid(), os.Getgid())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.toml"), []byte(config), 0o600))
+
+	runContainer(t, "--privileged", "-v", dir+":/run/containerd",
+		DaemonImage, "containerd", "--config", "/run/containerd/config.toml")
+	address := filepath.Join(dir, "containerd.sock")
+
+	h := &Harness{ContainerdAddress: address}
+	waitFor(t, "containerd", func() error {
+		c, err := h.ContainerdClient()
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		_, err = c.Version(context.Background())
+		return err
+	})
+	return address
+}
+
+// runContainer starts a detached container (removed when the test completes), returning the container ID.
+func runContainer(t testing.TB, args ...string) string {
+	t.Helper()
+
+	out := dockerCLI(t, append([]string{"run", "-d", "--rm"}, args...)...)
+	id := strings.TrimSpace(out)
+
+	t.Cleanup(func() {
+		if err := exec.Command("docker", "rm", "-f", id).Run(); err != nil {
+			t.Logf("unable to remove harness container %q: %+v", id, err)
+		}
+	})
+	return id
+}
+
+// publishedAddress returns the host address that the given container port is published to.
+func publishedAddress(t testing.TB, id, port string) string {
+	t.Helper()
+
+	out := strings.TrimSpace(dockerCLI(t, "port", id, port))
+	// there may be multiple addresses (e.g. IPv4 and IPv6), use the first
+	return strings.Split(out, "\n")[0]
+}
+
+func dockerCLI(t testing.TB, args ...string) string {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("docker", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	require.NoError(t, cmd.Run(), "docker %s: %s", strings.Join(args, " "), stderr.String())
+	return stdout.String()
+}
+
+func waitFor(t testing.TB, service string, check func() error) {
+	t.Helper()
+
+	deadline := time.Now().Add(startupTimeout)
+	var err error
+	for time.Now().Before(deadline) {
+		if err = check(); err == nil {
+			return
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	t.Fatalf("%s did not become ready within %s: %+v", service, startupTimeout, err)
+}
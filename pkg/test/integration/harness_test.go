@@ -0,0 +1,10 @@
+package integration
+
+import (
+	"testing"
+)
+
+func TestProviderMatrix(t *testing.T) {
+	h := New(t, AllServices)
+	h.RunProviderMatrix(t)
+}
@@ -0,0 +1,129 @@
+package integration
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/docker/docker/client"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/anchore/stereoscope/pkg/image/containerd"
+	"github.com/anchore/stereoscope/pkg/image/docker"
+	"github.com/anchore/stereoscope/pkg/image/oci"
+)
+
+const matrixLayers = 3
+
+// ProviderCase is a single provider configuration to validate against the harness.
+type ProviderCase struct {
+	Name string
+	// Seed makes the given image available to the provider (e.g. by pushing it to the registry), returning the user
+	// input that the provider should be given
+	Seed func(t testing.TB, h *Harness, img v1.Image) string
+	// Provider creates the provider under test for the given user input
+	Provider func(t testing.TB, h *Harness, tmpDirGen *file.TempDirGenerator, input string) image.Provider
+}
+
+// DefaultProviderCases returns the stereoscope provider configurations for every service running within the harness.
+func (h *Harness) DefaultProviderCases() []ProviderCase {
+	var cases []ProviderCase
+	if h.RegistryHost != "" {
+		cases = append(cases, ProviderCase{
+			Name: image.OciRegistrySource,
+			Seed: func(t testing.TB, h *Harness, img v1.Image) string {
+				return h.PushImage(t, "stereoscope/harness:latest", img)
+			},
+			Provider: func(_ testing.TB, _ *Harness, tmpDirGen *file.TempDirGenerator, input string) image.Provider {
+				return oci.NewRegistryProvider(tmpDirGen, image.RegistryOptions{InsecureUseHTTP: true}, input, nil)
+			},
+		})
+	}
+
+	if h.DockerHost != "" {
+		cases = append(cases, ProviderCase{
+			Name: image.DockerDaemonSource,
+			Seed: func(t testing.TB, h *Harness, img v1.Image) string {
+				return h.LoadDockerImage(t, "stereoscope/harness:latest", img)
+			},
+			Provider: func(_ testing.TB, h *Harness, tmpDirGen *file.TempDirGenerator, input string) image.Provider {
+				return docker.NewAPIClientProvider(docker.Daemon, tmpDirGen, input, nil, func() (client.APIClient, error) {
+					return h.DockerClient()
+				})
+			},
+		})
+	}
+
+	if h.ContainerdAddress != "" {
+		cases = append(cases, ProviderCase{
+			Name: image.ContainerdDaemonSource,
+			Seed: func(t testing.TB, h *Harness, img v1.Image) string {
+				return h.LoadContainerdImage(t, "stereoscope/harness:latest", img)
+			},
+			Provider: func(t testing.TB, h *Harness, tmpDirGen *file.TempDirGenerator, input string) image.Provider {
+				t.Setenv("CONTAINERD_ADDRESS", h.ContainerdAddress)
+				t.Setenv("CONTAINERD_NAMESPACE", Namespace)
+				return containerd.NewDaemonProvider(tmpDirGen, image.RegistryOptions{}, Namespace, input, nil)
+			},
+		})
+	}
+	return cases
+}
+
+// RunProviderMatrix seeds a generated image for each given provider case (or the default cases when none are given)
+// and verifies that the provided image matches the generated image.
+func (h *Harness) RunProviderMatrix(t *testing.T, cases ...ProviderCase) {
+	t.Helper()
+
+	if len(cases) == 0 {
+		cases = h.DefaultProviderCases()
+	}
+
+	img := matrixImage(t)
+	expectedID, err := img.ConfigName()
+	require.NoError(t, err)
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			input := tc.Seed(t, h, img)
+
+			tmpDirGen := file.NewTempDirGenerator("stereoscope-harness")
+			t.Cleanup(func() {
+				if err := tmpDirGen.Cleanup(); err != nil {
+					t.Logf("unable to cleanup temp dirs: %+v", err)
+				}
+			})
+
+			provided, err := tc.Provider(t, h, tmpDirGen, input).Provide(context.Background())
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = provided.Cleanup() })
+
+			assert.Equal(t, expectedID.String(), provided.Metadata.ID)
+			assert.Len(t, provided.Layers, matrixLayers)
+		})
+	}
+}
+
+// matrixImage generates a random image for the host platform.
+func matrixImage(t testing.TB) v1.Image {
+	t.Helper()
+
+	img, err := random.Image(1024, matrixLayers)
+	require.NoError(t, err)
+
+	cfg, err := img.ConfigFile()
+	require.NoError(t, err)
+	cfg = cfg.DeepCopy()
+	cfg.OS = "linux"
+	cfg.Architecture = runtime.GOARCH
+
+	img, err = mutate.ConfigFile(img, cfg)
+	require.NoError(t, err)
+	return img
+}
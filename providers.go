@@ -1,22 +1,32 @@
 package stereoscope
 
 import (
+	"slices"
+
 	"github.com/anchore/go-collections"
 	containerdClient "github.com/anchore/stereoscope/internal/containerd"
+	"github.com/anchore/stereoscope/pkg/file"
 	"github.com/anchore/stereoscope/pkg/image"
 	"github.com/anchore/stereoscope/pkg/image/containerd"
+	"github.com/anchore/stereoscope/pkg/image/cri"
+	"github.com/anchore/stereoscope/pkg/image/crio"
 	"github.com/anchore/stereoscope/pkg/image/docker"
+	"github.com/anchore/stereoscope/pkg/image/initramfs"
+	"github.com/anchore/stereoscope/pkg/image/iso"
+	"github.com/anchore/stereoscope/pkg/image/kaniko"
+	"github.com/anchore/stereoscope/pkg/image/lxd"
 	"github.com/anchore/stereoscope/pkg/image/oci"
 	"github.com/anchore/stereoscope/pkg/image/podman"
+	"github.com/anchore/stereoscope/pkg/image/raw"
 	"github.com/anchore/stereoscope/pkg/image/sif"
 )
 
 const (
-	FileTag     = "file"
-	DirTag      = "dir"
-	DaemonTag   = "daemon"
-	PullTag     = "pull"
-	RegistryTag = "registry"
+	FileTag     = image.FileTag
+	DirTag      = image.DirTag
+	DaemonTag   = image.DaemonTag
+	PullTag     = image.PullTag
+	RegistryTag = image.RegistryTag
 )
 
 // ImageProviderConfig is the uber-configuration containing all configuration needed by stereoscope image providers
@@ -24,29 +34,68 @@ type ImageProviderConfig struct {
 	UserInput string
 	Platform  *image.Platform
 	Registry  image.RegistryOptions
+	// EnvOverrides is the environment used for daemon discovery instead of the process environment (when set). Note:
+	// providers read these from the provide context, see image.ContextWithEnvOverrides.
+	EnvOverrides *image.EnvOverrides
 }
 
+// optionalProviders are providers that are only available when built with specific build tags (e.g. "vmdisk").
+var optionalProviders []func(*file.TempDirGenerator, ImageProviderConfig) collections.TaggedValue[image.Provider]
+
 func ImageProviders(cfg ImageProviderConfig) []collections.TaggedValue[image.Provider] {
 	tempDirGenerator := rootTempDirGenerator.NewGenerator()
-	return []collections.TaggedValue[image.Provider]{
+	namespace := containerdClient.NamespaceWithOverrides(cfg.EnvOverrides)
+	providers := []collections.TaggedValue[image.Provider]{
 		// file providers
-		taggedProvider(docker.NewArchiveProvider(tempDirGenerator, cfg.UserInput), FileTag),
-		taggedProvider(oci.NewArchiveProvider(tempDirGenerator, cfg.UserInput), FileTag),
-		taggedProvider(oci.NewDirectoryProvider(tempDirGenerator, cfg.UserInput), FileTag, DirTag),
-		taggedProvider(sif.NewArchiveProvider(tempDirGenerator, cfg.UserInput), FileTag),
+		taggedProvider(docker.NewPlatformArchiveProvider(tempDirGenerator, cfg.UserInput, cfg.Platform)),
+		taggedProvider(oci.NewPlatformArchiveProvider(tempDirGenerator, cfg.UserInput, cfg.Platform)),
+		taggedProvider(oci.NewPlatformDirectoryProvider(tempDirGenerator, cfg.UserInput, cfg.Platform)),
+		taggedProvider(sif.NewArchiveProvider(tempDirGenerator, cfg.UserInput)),
+		taggedProvider(oci.NewBazelProvider(tempDirGenerator, cfg.UserInput, cfg.Platform)),
+		taggedProvider(oci.NewBuildKitProvider(tempDirGenerator, cfg.UserInput, cfg.Platform)),
+		taggedProvider(kaniko.NewCacheProvider(tempDirGenerator, cfg.UserInput)),
+		taggedProvider(lxd.NewTarballProvider(tempDirGenerator, cfg.UserInput)),
+		taggedProvider(iso.NewArchiveProvider(tempDirGenerator, cfg.UserInput)),
+		taggedProvider(initramfs.NewArchiveProvider(tempDirGenerator, cfg.UserInput)),
+		// note: any tar is a raw tarball, thus this must follow all providers of tar based formats
+		taggedProvider(raw.NewTarballProvider(tempDirGenerator, cfg.UserInput)),
+		// note: any directory is a root filesystem, thus this is only used when explicitly requested (see
+		// image.SourceInfo.ExplicitOnly) and must follow all providers of directory based formats
+		taggedProvider(raw.NewDirectoryProvider(tempDirGenerator, cfg.UserInput)),
 
 		// daemon providers
-		taggedProvider(docker.NewDaemonProvider(tempDirGenerator, cfg.UserInput, cfg.Platform), DaemonTag, PullTag),
-		taggedProvider(podman.NewDaemonProvider(tempDirGenerator, cfg.UserInput, cfg.Platform), DaemonTag, PullTag),
-		taggedProvider(containerd.NewDaemonProvider(tempDirGenerator, cfg.Registry, containerdClient.Namespace(), cfg.UserInput, cfg.Platform), DaemonTag, PullTag),
+		taggedProvider(docker.NewDaemonProvider(tempDirGenerator, cfg.UserInput, cfg.Platform)),
+		taggedProvider(podman.NewDaemonProvider(tempDirGenerator, cfg.UserInput, cfg.Platform)),
+		taggedProvider(podman.NewLibpodProvider(tempDirGenerator, cfg.UserInput, cfg.Platform)),
+		taggedProvider(containerd.NewDaemonProvider(tempDirGenerator, cfg.Registry, namespace, cfg.UserInput, cfg.Platform)),
+		taggedProvider(containerd.NewSnapshotProvider(tempDirGenerator, namespace, cfg.UserInput, cfg.Platform)),
+		taggedProvider(crio.NewDaemonProvider(tempDirGenerator, cfg.UserInput, cfg.Platform)),
+		taggedProvider(cri.NewDaemonProvider(tempDirGenerator, cfg.Registry, cfg.UserInput, cfg.Platform)),
 
 		// registry providers
-		taggedProvider(oci.NewRegistryProvider(tempDirGenerator, cfg.Registry, cfg.UserInput, cfg.Platform), RegistryTag, PullTag),
+		taggedProvider(oci.NewRegistryProvider(tempDirGenerator, cfg.Registry, cfg.UserInput, cfg.Platform)),
+	}
+
+	for _, optional := range optionalProviders {
+		providers = append(providers, optional(tempDirGenerator, cfg))
 	}
+
+	return providers
 }
 
+// taggedProvider tags the given provider with the names, aliases, and tags of its registered source (see
+// image.RegisterSource) as well as any additional tags given.
 func taggedProvider(provider image.Provider, tags ...string) collections.TaggedValue[image.Provider] {
-	return collections.NewTaggedValue[image.Provider](provider, append([]string{provider.Name()}, tags...)...)
+	allTags := []string{provider.Name()}
+	if info, ok := image.LookupSource(provider.Name()); ok {
+		allTags = append(info.Names(), info.Tags...)
+	}
+	for _, tag := range tags {
+		if !slices.Contains(allTags, tag) {
+			allTags = append(allTags, tag)
+		}
+	}
+	return collections.NewTaggedValue[image.Provider](provider, allTags...)
 }
 
 func allProviderTags() []string {
@@ -0,0 +1,46 @@
+package stereoscope
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func TestImageProviders_registeredSources(t *testing.T) {
+	for _, provider := range ImageProviders(ImageProviderConfig{}) {
+		info, ok := image.LookupSource(provider.Value.Name())
+		if !assert.True(t, ok, "provider %q has no registered source", provider.Value.Name()) {
+			continue
+		}
+		for _, tag := range append(info.Names(), info.Tags...) {
+			assert.Contains(t, provider.Tags, tag)
+		}
+	}
+}
+
+func TestExtractSchemeSource_aliases(t *testing.T) {
+	source, input := ExtractSchemeSource("sif:/path/to/image.sif", allProviderTags()...)
+	assert.Equal(t, "sif", source)
+	assert.Equal(t, "/path/to/image.sif", input)
+}
+
+func TestDescribeProviders(t *testing.T) {
+	descriptions := DescribeProviders()
+	assert.Len(t, descriptions, len(ImageProviders(ImageProviderConfig{})))
+
+	var names []string
+	for _, d := range descriptions {
+		names = append(names, d.Name)
+		assert.NotEmpty(t, d.DisplayName, d.Name)
+		assert.NotEmpty(t, d.Examples, d.Name)
+		assert.Contains(t, d.Tags, d.Name)
+	}
+	assert.Contains(t, names, image.DockerTarballSource)
+	assert.Contains(t, names, image.OciRegistrySource)
+
+	tags := ProviderTags()
+	assert.Contains(t, tags, FileTag)
+	assert.Contains(t, tags, "sif")
+}
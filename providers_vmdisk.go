@@ -0,0 +1,16 @@
+//go:build vmdisk
+
+package stereoscope
+
+import (
+	"github.com/anchore/go-collections"
+	"github.com/anchore/stereoscope/pkg/file"
+	"github.com/anchore/stereoscope/pkg/image"
+	"github.com/anchore/stereoscope/pkg/image/vmdisk"
+)
+
+func init() {
+	optionalProviders = append(optionalProviders, func(tempDirGenerator *file.TempDirGenerator, cfg ImageProviderConfig) collections.TaggedValue[image.Provider] {
+		return taggedProvider(vmdisk.NewDiskProvider(tempDirGenerator, cfg.UserInput))
+	})
+}
@@ -0,0 +1,119 @@
+package stereoscope
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/anchore/stereoscope/internal/log"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// stateSchemaVersion is the version of the serialized state written by SaveState
+const stateSchemaVersion = 1
+
+// state is the serialized form of a provided image that can be reloaded with Reload.
+type state struct {
+	Schema int `json:"schema"`
+	image.ProviderInput
+}
+
+// ErrStateMismatch is returned by Reload when the re-provided image is not the same image that the state was saved from
+// (e.g. a tag has since been moved or an archive has been replaced).
+type ErrStateMismatch struct {
+	Expected string
+	Actual   string
+}
+
+func (e *ErrStateMismatch) Error() string {
+	return fmt.Sprintf("reloaded image does not match saved state: expected image ID %q, got %q", e.Expected, e.Actual)
+}
+
+// SaveState serializes the normalized input that the given image was provided from (see image.ProviderInput), which
+// can later be given to Reload to provide the exact same image again.
+func SaveState(img *image.Image) ([]byte, error) {
+	if img == nil || img.Metadata.ProviderInput == nil {
+		return nil, fmt.Errorf("image does not have provider input recorded (was it provided via GetImage?)")
+	}
+	return json.Marshal(state{
+		Schema:        stateSchemaVersion,
+		ProviderInput: *img.Metadata.ProviderInput,
+	})
+}
+
+// Reload provides the same image that the given state (from SaveState) was saved from, using the same source and
+// platform. If the image provided differs from the original image, ErrStateMismatch is returned. Callers are
+// responsible for cleaning up the returned image.
+func Reload(ctx context.Context, raw []byte, options ...Option) (*image.Image, error) {
+	var s state
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, fmt.Errorf("unable to parse image state: %w", err)
+	}
+
+	if s.Schema != stateSchemaVersion {
+		return nil, fmt.Errorf("unsupported image state schema version: %d", s.Schema)
+	}
+
+	if s.Source == "" || s.Reference == "" {
+		return nil, fmt.Errorf("image state is missing the source or reference")
+	}
+
+	if s.Platform != "" {
+		options = append(options, WithPlatform(s.Platform))
+	}
+	if s.ImageSelector != "" {
+		options = append(options, WithImageSelector(s.ImageSelector))
+	}
+
+	img, err := GetImageFromSource(ctx, s.Reference, s.Source, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.ID != "" && img.Metadata.ID != s.ID {
+		if cleanupErr := img.Cleanup(); cleanupErr != nil {
+			log.Warnf("unable to cleanup image: %+v", cleanupErr)
+		}
+		return nil, &ErrStateMismatch{Expected: s.ID, Actual: img.Metadata.ID}
+	}
+
+	// retain the original user input for any subsequent saves
+	img.Metadata.ProviderInput.UserInput = s.UserInput
+	return img, nil
+}
+
+// newProviderInput normalizes the input the given image was provided from (with the given config).
+func newProviderInput(img *image.Image, provider image.Provider, userInput string, fileBased bool, cfg config) *image.ProviderInput {
+	input := image.ProviderInput{
+		Source:        provider.Name(),
+		UserInput:     userInput,
+		Reference:     userInput,
+		Digest:        img.Metadata.ManifestDigest,
+		ID:            img.Metadata.ID,
+		ImageSelector: cfg.ImageSelector,
+	}
+
+	switch {
+	case fileBased:
+		if abs, err := filepath.Abs(userInput); err == nil {
+			input.Reference = abs
+		}
+	case len(img.Metadata.RepoDigests) > 0:
+		input.Reference = img.Metadata.RepoDigests[0]
+	case len(img.Metadata.Tags) > 0:
+		input.Reference = img.Metadata.Tags[0].Name()
+	}
+
+	if img.Metadata.OS != "" && img.Metadata.Architecture != "" {
+		input.Platform = (&image.Platform{
+			OS:           img.Metadata.OS,
+			Architecture: img.Metadata.Architecture,
+			Variant:      img.Metadata.Variant,
+		}).String()
+	} else if cfg.Platform != nil {
+		input.Platform = cfg.Platform.String()
+	}
+
+	return &input
+}
@@ -0,0 +1,110 @@
+package stereoscope
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func TestSaveState_Reload(t *testing.T) {
+	archivePath := writeDockerArchive(t)
+
+	// provide from a relative path to ensure the reference is normalized
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	relPath, err := filepath.Rel(wd, archivePath)
+	require.NoError(t, err)
+
+	provided, err := GetImage(context.Background(), relPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = provided.Cleanup() })
+
+	input := provided.Metadata.ProviderInput
+	require.NotNil(t, input)
+	assert.Equal(t, image.DockerTarballSource, input.Source)
+	assert.Equal(t, relPath, input.UserInput)
+	assert.Equal(t, archivePath, input.Reference)
+	assert.Equal(t, provided.Metadata.ID, input.ID)
+	assert.Equal(t, provided.Metadata.ManifestDigest, input.Digest)
+
+	saved, err := SaveState(provided)
+	require.NoError(t, err)
+
+	reloaded, err := Reload(context.Background(), saved)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = reloaded.Cleanup() })
+
+	assert.Equal(t, provided.Metadata.ID, reloaded.Metadata.ID)
+	assert.Equal(t, *input, *reloaded.Metadata.ProviderInput)
+
+	// replace the archive with a different image
+	replacement := writeDockerArchive(t)
+	contents, err := os.ReadFile(replacement)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(archivePath, contents, 0o644))
+
+	_, err = Reload(context.Background(), saved)
+	var mismatch *ErrStateMismatch
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, provided.Metadata.ID, mismatch.Expected)
+}
+
+func TestSaveState_Reload_ImageSelector(t *testing.T) {
+	// an OCI layout holding more than one image, where the image must be selected
+	layoutPath := t.TempDir()
+	p, err := layout.Write(layoutPath, empty.Index)
+	require.NoError(t, err)
+	for _, ref := range []string{"one", "two"} {
+		img, err := random.Image(64, 1)
+		require.NoError(t, err)
+		require.NoError(t, p.AppendImage(img, layout.WithAnnotations(map[string]string{v1.AnnotationRefName: ref})))
+	}
+
+	provided, err := GetImageFromSource(context.Background(), layoutPath, image.OciDirectorySource, WithImageSelector("two"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = provided.Cleanup() })
+	assert.Equal(t, "two", provided.Metadata.ProviderInput.ImageSelector)
+
+	saved, err := SaveState(provided)
+	require.NoError(t, err)
+
+	reloaded, err := Reload(context.Background(), saved)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = reloaded.Cleanup() })
+
+	assert.Equal(t, provided.Metadata.ID, reloaded.Metadata.ID)
+	assert.Equal(t, *provided.Metadata.ProviderInput, *reloaded.Metadata.ProviderInput)
+}
+
+func TestSaveState_WithoutProviderInput(t *testing.T) {
+	_, err := SaveState(&image.Image{})
+	require.Error(t, err)
+}
+
+func TestReload_InvalidState(t *testing.T) {
+	tests := []struct {
+		name  string
+		state string
+	}{
+		{name: "not json", state: "nope"},
+		{name: "unsupported schema", state: `{"schema":99,"source":"docker-archive","reference":"/image.tar"}`},
+		{name: "missing reference", state: `{"schema":1,"source":"docker-archive"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Reload(context.Background(), []byte(test.state))
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package stereoscope
+
+import (
+	"time"
+
+	"github.com/anchore/stereoscope/internal/inflight"
+	"github.com/anchore/stereoscope/internal/redact"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+// stages of an in-flight image provide (see ProvideStatus)
+const (
+	// StageResolving is selecting the image providers to attempt
+	StageResolving = inflight.StageResolving
+	// StageVerifying is checking the input against a user provided digest or checksum file
+	StageVerifying = inflight.StageVerifying
+	// StageProviding is acquiring the image from a provider (e.g. pulling or saving from a daemon)
+	StageProviding = inflight.StageProviding
+	// StageReading is reading layer contents and building file trees
+	StageReading = inflight.StageReading
+)
+
+// ProvideStatus describes an in-flight GetImage or GetImageFromSource call.
+type ProvideStatus struct {
+	// Input is the user input being provided (with any secrets redacted)
+	Input string
+	// Source is the requested image source (empty when detected automatically)
+	Source image.Source
+	// Provider is the name of the provider currently being attempted
+	Provider string
+	// Stage is the current stage of the provide (e.g. StageReading)
+	Stage string
+	// Started is when the provide began
+	Started time.Time
+	// StageStarted is when the current stage began
+	StageStarted time.Time
+	// Bytes is the number of layer content bytes read so far
+	Bytes int64
+}
+
+// InFlight returns the status of all image provides that are currently in progress (oldest first). This is useful for
+// services embedding stereoscope to answer health checks and to report in-flight operations.
+func InFlight() []ProvideStatus {
+	var out []ProvideStatus
+	for _, s := range inflight.All() {
+		out = append(out, ProvideStatus{
+			Input:        redact.Apply(s.Input),
+			Source:       s.Source,
+			Provider:     s.Provider,
+			Stage:        s.Stage,
+			Started:      s.Started,
+			StageStarted: s.StageStarted,
+			Bytes:        s.Bytes,
+		})
+	}
+	return out
+}
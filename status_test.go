@@ -0,0 +1,50 @@
+package stereoscope
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anchore/stereoscope/internal/inflight"
+	"github.com/anchore/stereoscope/internal/redact"
+	"github.com/anchore/stereoscope/pkg/image"
+)
+
+func TestInFlight(t *testing.T) {
+	redact.Add("s3cr3t-value")
+	op := inflight.Start("registry.example.com/app:s3cr3t-value", image.OciRegistrySource, StageResolving)
+	op.SetStage(StageProviding)
+	op.SetProvider(image.OciRegistrySource)
+
+	statuses := InFlight()
+	op.Done()
+
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "registry.example.com/app:*******", statuses[0].Input)
+	assert.Equal(t, image.OciRegistrySource, statuses[0].Source)
+	assert.Equal(t, image.OciRegistrySource, statuses[0].Provider)
+	assert.Equal(t, StageProviding, statuses[0].Stage)
+
+	assert.Empty(t, InFlight())
+}
+
+func TestInFlight_completedProvide(t *testing.T) {
+	img, err := random.Image(64, 2)
+	require.NoError(t, err)
+
+	archive := filepath.Join(t.TempDir(), "image.tar")
+	require.NoError(t, tarball.WriteToFile(archive, name.MustParseReference("anchore/test:latest"), img))
+
+	provided, err := GetImageFromSource(context.Background(), archive, image.DockerTarballSource)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = provided.Cleanup() })
+
+	// completed provides are no longer reported
+	assert.Empty(t, InFlight())
+}